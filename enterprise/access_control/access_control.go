@@ -0,0 +1,525 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package access_control implements einterfaces.AccessControlServiceInterface with CEL
+// expressions over the custom profile attributes of the users, and the access_control_sync job
+// which removes from the channels the members who no longer satisfy their policy.
+package access_control
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/google/cel-go/cel"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+	ejobs "github.com/mattermost/mattermost/server/v8/einterfaces/jobs"
+)
+
+const (
+	// actionAll matches the rules of every action
+	actionAll = "*"
+	// actionJoinChannel is the action checked when a user joins a channel, and which the members
+	// of the channel keep satisfying
+	actionJoinChannel = "join_channel"
+
+	// inheritedRulePrefix starts the rules of v0.1 channel policies standing for the rules of
+	// their imported parent policy
+	inheritedRulePrefix = "policies."
+
+	// scanPageSize is the number of users or members evaluated at once for the expressions
+	// which can't be translated to SQL
+	scanPageSize = 200
+)
+
+func init() {
+	app.RegisterAccessControlServiceInterface(func(a *app.App) einterfaces.AccessControlServiceInterface {
+		return New(a)
+	})
+	app.RegisterJobsAccessControlSyncJobInterface(func(s *app.Server) ejobs.AccessControlSyncJobInterface {
+		return &jobInterface{server: s}
+	})
+}
+
+// AccessControlService is the policy administration and decision point of the attribute based
+// access control of channels
+type AccessControlService struct {
+	app *app.App
+
+	mut sync.Mutex
+	env *cel.Env
+}
+
+func New(a *app.App) *AccessControlService {
+	return &AccessControlService{app: a}
+}
+
+func invalidExpressionError(where string, errs []model.CELExpressionError) *model.AppError {
+	messages := make([]string, 0, len(errs))
+	for _, err := range errs {
+		messages = append(messages, err.Message)
+	}
+	return model.NewAppError(where, "ent.access_control.invalid_expression.app_error", nil, strings.Join(messages, "; "), http.StatusBadRequest)
+}
+
+func (s *AccessControlService) Init(rctx request.CTX) *model.AppError {
+	_, appErr := s.celEnv()
+	return appErr
+}
+
+func (s *AccessControlService) celEnv() (*cel.Env, *model.AppError) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.env == nil {
+		env, err := newEnv()
+		if err != nil {
+			return nil, model.NewAppError("AccessControlService.Init", "ent.access_control.init.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		s.env = env
+	}
+
+	return s.env, nil
+}
+
+func (s *AccessControlService) compile(where, text string) (*expression, *model.AppError) {
+	env, appErr := s.celEnv()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	expr, errs := compile(env, text)
+	if len(errs) > 0 {
+		return nil, invalidExpressionError(where, errs)
+	}
+
+	return expr, nil
+}
+
+// fields returns the custom profile attributes by name
+func (s *AccessControlService) fields() (map[string]*model.CPAField, *model.AppError) {
+	fields, appErr := s.app.ListCPAFields()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	byName := make(map[string]*model.CPAField, len(fields))
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+
+	return byName, nil
+}
+
+func (s *AccessControlService) CheckExpression(rctx request.CTX, text string) ([]model.CELExpressionError, *model.AppError) {
+	env, appErr := s.celEnv()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	expr, errs := compile(env, text)
+	if len(errs) > 0 {
+		return errs, nil
+	}
+
+	fields, appErr := s.fields()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if errs := expr.checkAttributes(fields); len(errs) > 0 {
+		return errs, nil
+	}
+
+	return []model.CELExpressionError{}, nil
+}
+
+func (s *AccessControlService) ExpressionToVisualAST(rctx request.CTX, text string) (*model.VisualExpression, *model.AppError) {
+	visual := &model.VisualExpression{Conditions: []model.Condition{}}
+	if strings.TrimSpace(text) == "" {
+		return visual, nil
+	}
+
+	expr, appErr := s.compile("ExpressionToVisualAST", text)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	conditions, ok := expr.conditions()
+	if !ok {
+		return nil, model.NewAppError("ExpressionToVisualAST", "ent.access_control.visual_ast.unsupported.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	fields, appErr := s.fields()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	for _, c := range conditions {
+		visual.Conditions = append(visual.Conditions, c.toVisual(fields))
+	}
+
+	return visual, nil
+}
+
+// NormalizePolicy returns the policy as is, the expressions already refer to the attributes and
+// their options by name.
+func (s *AccessControlService) NormalizePolicy(rctx request.CTX, policy *model.AccessControlPolicy) (*model.AccessControlPolicy, *model.AppError) {
+	return policy, nil
+}
+
+func (s *AccessControlService) GetPolicy(rctx request.CTX, id string) (*model.AccessControlPolicy, *model.AppError) {
+	policy, err := s.app.Srv().Store().AccessControlPolicy().Get(rctx, id)
+	if err != nil {
+		var nfErr *store.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return nil, model.NewAppError("GetPolicy", "ent.access_control.get_policy.not_found.app_error", nil, "", http.StatusNotFound).Wrap(err)
+		}
+		return nil, model.NewAppError("GetPolicy", "ent.access_control.get_policy.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return policy, nil
+}
+
+func (s *AccessControlService) SavePolicy(rctx request.CTX, policy *model.AccessControlPolicy) (*model.AccessControlPolicy, *model.AppError) {
+	if appErr := policy.IsValid(); appErr != nil {
+		return nil, appErr
+	}
+
+	for _, rule := range policy.Rules {
+		if strings.HasPrefix(rule.Expression, inheritedRulePrefix) {
+			continue
+		}
+		errs, appErr := s.CheckExpression(rctx, rule.Expression)
+		if appErr != nil {
+			return nil, appErr
+		}
+		if len(errs) > 0 {
+			return nil, invalidExpressionError("SavePolicy", errs)
+		}
+	}
+
+	for _, parentID := range policy.Imports {
+		parent, appErr := s.GetPolicy(rctx, parentID)
+		if appErr != nil && appErr.StatusCode != http.StatusNotFound {
+			return nil, appErr
+		}
+		if parent == nil || parent.Type != model.AccessControlPolicyTypeParent {
+			return nil, model.NewAppError("SavePolicy", "ent.access_control.save_policy.invalid_import.app_error", nil, "import="+parentID, http.StatusBadRequest)
+		}
+	}
+
+	saved, err := s.app.Srv().Store().AccessControlPolicy().Save(rctx, policy)
+	if err != nil {
+		var appErr *model.AppError
+		if errors.As(err, &appErr) {
+			return nil, appErr
+		}
+		return nil, model.NewAppError("SavePolicy", "ent.access_control.save_policy.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return saved, nil
+}
+
+func (s *AccessControlService) DeletePolicy(rctx request.CTX, id string) *model.AppError {
+	policy, appErr := s.GetPolicy(rctx, id)
+	if appErr != nil {
+		return appErr
+	}
+
+	if policy.Type == model.AccessControlPolicyTypeParent {
+		_, count, err := s.app.Srv().Store().AccessControlPolicy().SearchPolicies(rctx, model.AccessControlPolicySearch{
+			Type:     model.AccessControlPolicyTypeChannel,
+			ParentID: id,
+			Limit:    1,
+		})
+		if err != nil {
+			return model.NewAppError("DeletePolicy", "ent.access_control.delete_policy.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		if count > 0 {
+			return model.NewAppError("DeletePolicy", "ent.access_control.delete_policy.in_use.app_error", nil, "", http.StatusBadRequest)
+		}
+	}
+
+	if err := s.app.Srv().Store().AccessControlPolicy().Delete(rctx, id); err != nil {
+		return model.NewAppError("DeletePolicy", "ent.access_control.delete_policy.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return nil
+}
+
+func ruleApplies(rule model.AccessControlPolicyRule, action string) bool {
+	return action == actionAll || slices.Contains(rule.Actions, actionAll) || slices.Contains(rule.Actions, action)
+}
+
+func ruleExpressions(policy *model.AccessControlPolicy, action string) []string {
+	var expressions []string
+	for _, rule := range policy.Rules {
+		if rule.Expression == "" || strings.HasPrefix(rule.Expression, inheritedRulePrefix) || !ruleApplies(rule, action) {
+			continue
+		}
+		expressions = append(expressions, rule.Expression)
+	}
+	return expressions
+}
+
+// resourceExpression returns the expression a user must satisfy for the action on the channel:
+// the rules of its policy and of the parent policies it imports. It is empty when the channel
+// has no policy.
+func (s *AccessControlService) resourceExpression(rctx request.CTX, channelID, action string) (string, *model.AppError) {
+	policy, appErr := s.GetPolicy(rctx, channelID)
+	if appErr != nil {
+		if appErr.StatusCode == http.StatusNotFound {
+			return "", nil
+		}
+		return "", appErr
+	}
+
+	expressions := ruleExpressions(policy, action)
+	for _, parentID := range policy.Imports {
+		parent, appErr := s.GetPolicy(rctx, parentID)
+		if appErr != nil {
+			if appErr.StatusCode == http.StatusNotFound {
+				rctx.Logger().Warn("Skipping the missing parent policy of a channel", mlog.String("channel_id", channelID), mlog.String("policy_id", parentID))
+				continue
+			}
+			return "", appErr
+		}
+		expressions = append(expressions, ruleExpressions(parent, action)...)
+	}
+
+	switch len(expressions) {
+	case 0:
+		return "", nil
+	case 1:
+		return expressions[0], nil
+	default:
+		return "(" + strings.Join(expressions, ") && (") + ")", nil
+	}
+}
+
+func (s *AccessControlService) GetPolicyRuleAttributes(rctx request.CTX, policyID string, action string) (map[string][]string, *model.AppError) {
+	text, appErr := s.resourceExpression(rctx, policyID, action)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	attributes := map[string][]string{}
+	if text == "" {
+		return attributes, nil
+	}
+
+	expr, appErr := s.compile("GetPolicyRuleAttributes", text)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	var collect func(n node)
+	collect = func(n node) {
+		switch n := n.(type) {
+		case *andNode:
+			collect(n.left)
+			collect(n.right)
+		case *orNode:
+			collect(n.left)
+			collect(n.right)
+		case *notNode:
+			collect(n.operand)
+		case *condition:
+			for _, value := range n.values {
+				if !slices.Contains(attributes[n.attribute], value) {
+					attributes[n.attribute] = append(attributes[n.attribute], value)
+				}
+			}
+		}
+	}
+	collect(expr.root)
+
+	return attributes, nil
+}
+
+func (s *AccessControlService) QueryUsersForExpression(rctx request.CTX, text string, opts model.SubjectSearchOptions) ([]*model.User, int64, *model.AppError) {
+	expr, appErr := s.compile("QueryUsersForExpression", text)
+	if appErr != nil {
+		return nil, 0, appErr
+	}
+
+	if query, args, ok := expr.toSQL(); ok {
+		opts.Query = query
+		opts.Args = args
+		users, count, err := s.app.Srv().Store().Attributes().SearchUsers(rctx, opts)
+		if err != nil {
+			return nil, 0, model.NewAppError("QueryUsersForExpression", "ent.access_control.query_users.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		return users, count, nil
+	}
+
+	return s.queryUsersInMemory(rctx, expr, opts)
+}
+
+// queryUsersInMemory evaluates the expression on every user matching the options, for the
+// expressions which can't be translated to SQL
+func (s *AccessControlService) queryUsersInMemory(rctx request.CTX, expr *expression, opts model.SubjectSearchOptions) ([]*model.User, int64, *model.AppError) {
+	groupID, err := s.app.CpaGroupID()
+	if err != nil {
+		return nil, 0, model.NewAppError("QueryUsersForExpression", "ent.access_control.query_users.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	scanOpts := opts
+	scanOpts.Query = ""
+	scanOpts.Args = nil
+	scanOpts.Limit = scanPageSize
+	scanOpts.IgnoreCount = true
+
+	matched := []*model.User{}
+	var count int64
+	for {
+		users, _, err := s.app.Srv().Store().Attributes().SearchUsers(rctx, scanOpts)
+		if err != nil {
+			return nil, 0, model.NewAppError("QueryUsersForExpression", "ent.access_control.query_users.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+
+		for _, user := range users {
+			attributes, appErr := s.subjectAttributes(rctx, user.Id, groupID)
+			if appErr != nil {
+				return nil, 0, appErr
+			}
+			if !expr.evaluate(attributes) {
+				continue
+			}
+
+			count++
+			if opts.Limit <= 0 || len(matched) < opts.Limit {
+				matched = append(matched, user)
+			} else if opts.IgnoreCount {
+				return matched, 0, nil
+			}
+		}
+
+		if len(users) < scanPageSize {
+			break
+		}
+		scanOpts.Cursor.TargetID = users[len(users)-1].Id
+	}
+
+	if opts.IgnoreCount {
+		count = 0
+	}
+
+	return matched, count, nil
+}
+
+func (s *AccessControlService) subjectAttributes(rctx request.CTX, userID, groupID string) (map[string]any, *model.AppError) {
+	subject, err := s.app.Srv().Store().Attributes().GetSubject(rctx, userID, groupID)
+	if err != nil {
+		var nfErr *store.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return map[string]any{}, nil
+		}
+		return nil, model.NewAppError("subjectAttributes", "ent.access_control.attributes.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return subject.Attributes, nil
+}
+
+func (s *AccessControlService) QueryUsersForResource(rctx request.CTX, resourceID, action string, opts model.SubjectSearchOptions) ([]*model.User, int64, *model.AppError) {
+	text, appErr := s.resourceExpression(rctx, resourceID, action)
+	if appErr != nil {
+		return nil, 0, appErr
+	}
+
+	if text == "" {
+		// Without a policy anyone can access the channel
+		text = "true"
+	}
+
+	return s.QueryUsersForExpression(rctx, text, opts)
+}
+
+func (s *AccessControlService) GetChannelMembersToRemove(rctx request.CTX, channelID string) ([]*model.ChannelMember, *model.AppError) {
+	text, appErr := s.resourceExpression(rctx, channelID, actionJoinChannel)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if text == "" {
+		return []*model.ChannelMember{}, nil
+	}
+
+	expr, appErr := s.compile("GetChannelMembersToRemove", text)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if query, args, ok := expr.toSQL(); ok {
+		members, err := s.app.Srv().Store().Attributes().GetChannelMembersToRemove(rctx, channelID, model.SubjectSearchOptions{
+			Query: query,
+			Args:  args,
+		})
+		if err != nil {
+			return nil, model.NewAppError("GetChannelMembersToRemove", "ent.access_control.channel_members.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		return members, nil
+	}
+
+	groupID, err := s.app.CpaGroupID()
+	if err != nil {
+		return nil, model.NewAppError("GetChannelMembersToRemove", "ent.access_control.channel_members.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	toRemove := []*model.ChannelMember{}
+	for offset := 0; ; offset += scanPageSize {
+		members, err := s.app.Srv().Store().Channel().GetMembers(model.ChannelMembersGetOptions{
+			ChannelID: channelID,
+			Offset:    offset,
+			Limit:     scanPageSize,
+		})
+		if err != nil {
+			return nil, model.NewAppError("GetChannelMembersToRemove", "ent.access_control.channel_members.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+
+		for i := range members {
+			attributes, appErr := s.subjectAttributes(rctx, members[i].UserId, groupID)
+			if appErr != nil {
+				return nil, appErr
+			}
+			if !expr.evaluate(attributes) {
+				toRemove = append(toRemove, &members[i])
+			}
+		}
+
+		if len(members) < scanPageSize {
+			break
+		}
+	}
+
+	return toRemove, nil
+}
+
+func (s *AccessControlService) AccessEvaluation(rctx request.CTX, accessRequest model.AccessRequest) (model.AccessDecision, *model.AppError) {
+	if accessRequest.Resource.Type != model.AccessControlPolicyTypeChannel {
+		return model.AccessDecision{}, model.NewAppError("AccessEvaluation", "ent.access_control.access_evaluation.resource_type.app_error", nil, "type="+accessRequest.Resource.Type, http.StatusBadRequest)
+	}
+
+	text, appErr := s.resourceExpression(rctx, accessRequest.Resource.ID, accessRequest.Action)
+	if appErr != nil {
+		return model.AccessDecision{}, appErr
+	}
+	if text == "" {
+		return model.AccessDecision{Decision: true}, nil
+	}
+
+	expr, appErr := s.compile("AccessEvaluation", text)
+	if appErr != nil {
+		return model.AccessDecision{}, appErr
+	}
+
+	return model.AccessDecision{Decision: expr.evaluate(accessRequest.Subject.Attributes)}, nil
+}
@@ -0,0 +1,563 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package access_control
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/operators"
+	"github.com/google/cel-go/common/overloads"
+	"github.com/google/cel-go/common/types"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	userVariable    = "user"
+	attributesField = "attributes"
+	attributePrefix = userVariable + "." + attributesField + "."
+)
+
+// The operators of the conditions, as named by the visual representation of the expressions
+const (
+	operatorEquals     = "=="
+	operatorNotEquals  = "!="
+	operatorStartsWith = "startsWith"
+	operatorEndsWith   = "endsWith"
+	operatorContains   = "contains"
+	operatorIn         = "in"
+)
+
+// newEnv declares the variables available to the expressions: the attributes of the user are
+// looked up by name from user.attributes.
+func newEnv() (*cel.Env, error) {
+	return cel.NewEnv(cel.Variable(userVariable, cel.MapType(cel.StringType, cel.DynType)))
+}
+
+// expression is a compiled policy expression. The expressions made of comparisons between the
+// attributes and string literals combined with &&, || and ! are converted to a tree of nodes
+// which can be translated to SQL, the others are only evaluated by the CEL program.
+type expression struct {
+	ast     *cel.Ast
+	program cel.Program
+	root    node
+}
+
+func compile(env *cel.Env, text string) (*expression, []model.CELExpressionError) {
+	checked, iss := env.Compile(text)
+	if iss.Err() != nil {
+		return nil, celErrors(iss)
+	}
+
+	if outputType := checked.OutputType(); !outputType.IsExactType(cel.BoolType) && !outputType.IsExactType(cel.DynType) {
+		return nil, []model.CELExpressionError{{
+			Line:    1,
+			Message: fmt.Sprintf("the expression must evaluate to a bool, not %s", outputType),
+		}}
+	}
+
+	program, err := env.Program(checked)
+	if err != nil {
+		return nil, []model.CELExpressionError{{Line: 1, Message: err.Error()}}
+	}
+
+	return &expression{
+		ast:     checked,
+		program: program,
+		root:    toNode(checked.NativeRep().Expr()),
+	}, nil
+}
+
+func celErrors(iss *cel.Issues) []model.CELExpressionError {
+	errs := make([]model.CELExpressionError, 0, len(iss.Errors()))
+	for _, err := range iss.Errors() {
+		errs = append(errs, model.CELExpressionError{
+			Line:    err.Location.Line(),
+			Column:  err.Location.Column(),
+			Message: err.Message,
+		})
+	}
+	return errs
+}
+
+// checkAttributes reports the attributes of the expression missing from the fields, as well as
+// the references to the user that aren't attributes.
+func (e *expression) checkAttributes(fields map[string]*model.CPAField) []model.CELExpressionError {
+	var errs []model.CELExpressionError
+	sourceInfo := e.ast.NativeRep().SourceInfo()
+	report := func(id int64, message string) {
+		location := sourceInfo.GetStartLocation(id)
+		errs = append(errs, model.CELExpressionError{Line: location.Line(), Column: location.Column(), Message: message})
+	}
+
+	visitor := ast.NewExprVisitor(func(expr ast.Expr) {
+		if expr.Kind() != ast.SelectKind {
+			return
+		}
+		sel := expr.AsSelect()
+		operand := sel.Operand()
+		switch {
+		case operand.Kind() == ast.IdentKind && operand.AsIdent() == userVariable && sel.FieldName() != attributesField:
+			report(expr.ID(), fmt.Sprintf("undefined field '%s', only user.attributes can be used", sel.FieldName()))
+		case isAttributes(operand):
+			if _, ok := fields[sel.FieldName()]; !ok {
+				report(expr.ID(), fmt.Sprintf("unknown attribute '%s'", sel.FieldName()))
+			}
+		}
+	})
+	ast.PostOrderVisit(e.ast.NativeRep().Expr(), visitor)
+
+	return errs
+}
+
+// evaluate tells whether the attributes of a subject satisfy the expression
+func (e *expression) evaluate(attributes map[string]any) bool {
+	if e.root != nil {
+		return e.root.evaluate(attributes)
+	}
+
+	if attributes == nil {
+		attributes = map[string]any{}
+	}
+	val, _, err := e.program.Eval(map[string]any{
+		userVariable: map[string]any{attributesField: attributes},
+	})
+	if err != nil {
+		// Like a missing attribute, an error denies access
+		return false
+	}
+
+	return val == types.True
+}
+
+// toSQL translates the expression to a condition on the Attributes column of the AttributeView,
+// with positional arguments. It returns false when the expression can't be translated.
+func (e *expression) toSQL() (string, []any, bool) {
+	if e.root == nil {
+		return "", nil, false
+	}
+
+	b := &sqlBuilder{}
+	query, ok := e.root.toSQL(b)
+	if !ok {
+		return "", nil, false
+	}
+
+	return query, b.args, true
+}
+
+// conditions returns the conditions of the expression when it is a conjunction of conditions
+func (e *expression) conditions() ([]*condition, bool) {
+	var conditions []*condition
+	var collect func(n node) bool
+	collect = func(n node) bool {
+		switch n := n.(type) {
+		case *andNode:
+			return collect(n.left) && collect(n.right)
+		case *condition:
+			conditions = append(conditions, n)
+			return true
+		default:
+			return false
+		}
+	}
+
+	if e.root == nil || !collect(e.root) {
+		return nil, false
+	}
+
+	return conditions, true
+}
+
+// node is an expression made of the supported constructs
+type node interface {
+	evaluate(attributes map[string]any) bool
+	toSQL(b *sqlBuilder) (string, bool)
+}
+
+type andNode struct {
+	left, right node
+}
+
+type orNode struct {
+	left, right node
+}
+
+type notNode struct {
+	operand node
+}
+
+type constNode struct {
+	value bool
+}
+
+// condition compares an attribute with string literals or another attribute. A condition on a
+// missing attribute is false.
+type condition struct {
+	attribute string
+	operator  string
+	values    []string
+	// valueAttribute is the attribute the attribute is compared with, instead of the values
+	valueAttribute string
+	// list tells whether the values were given as a list literal
+	list bool
+	// reversed is set when the values are looked up in the attribute, such as a multiselect one
+	reversed bool
+}
+
+func toNode(expr ast.Expr) node {
+	switch expr.Kind() {
+	case ast.LiteralKind:
+		if value, ok := expr.AsLiteral().(types.Bool); ok {
+			return &constNode{value: bool(value)}
+		}
+	case ast.CallKind:
+		return callToNode(expr.AsCall())
+	}
+
+	return nil
+}
+
+func callToNode(call ast.CallExpr) node {
+	args := call.Args()
+
+	switch call.FunctionName() {
+	case operators.LogicalAnd, operators.LogicalOr:
+		left, right := toNode(args[0]), toNode(args[1])
+		if left == nil || right == nil {
+			return nil
+		}
+		if call.FunctionName() == operators.LogicalAnd {
+			return &andNode{left: left, right: right}
+		}
+		return &orNode{left: left, right: right}
+	case operators.LogicalNot:
+		if operand := toNode(args[0]); operand != nil {
+			return &notNode{operand: operand}
+		}
+	case operators.Equals, operators.NotEquals:
+		operator := operatorEquals
+		if call.FunctionName() == operators.NotEquals {
+			operator = operatorNotEquals
+		}
+		left, right := args[0], args[1]
+		if _, ok := attributeName(left); !ok {
+			left, right = right, left
+		}
+		return comparison(operator, left, right)
+	case operators.In:
+		return inCondition(args[0], args[1])
+	case overloads.StartsWith, overloads.EndsWith, overloads.Contains:
+		if !call.IsMemberFunction() || len(args) != 1 {
+			return nil
+		}
+		return comparison(call.FunctionName(), call.Target(), args[0])
+	}
+
+	return nil
+}
+
+func comparison(operator string, left, right ast.Expr) node {
+	attribute, ok := attributeName(left)
+	if !ok {
+		return nil
+	}
+
+	if valueAttribute, ok := attributeName(right); ok {
+		return &condition{attribute: attribute, operator: operator, valueAttribute: valueAttribute}
+	}
+
+	if value, ok := stringLiteral(right); ok {
+		return &condition{attribute: attribute, operator: operator, values: []string{value}}
+	}
+
+	return nil
+}
+
+func inCondition(element, container ast.Expr) node {
+	// user.attributes.Team in ["a", "b"]
+	if attribute, ok := attributeName(element); ok {
+		if values, ok := stringList(container); ok {
+			return &condition{attribute: attribute, operator: operatorIn, values: values, list: true}
+		}
+		return nil
+	}
+
+	attribute, ok := attributeName(container)
+	if !ok {
+		return nil
+	}
+
+	// ["a", "b"] in user.attributes.Tags, true when any of the values is in the attribute
+	if values, ok := stringList(element); ok {
+		return &condition{attribute: attribute, operator: operatorIn, values: values, list: true, reversed: true}
+	}
+
+	// "a" in user.attributes.Tags
+	if value, ok := stringLiteral(element); ok {
+		return &condition{attribute: attribute, operator: operatorIn, values: []string{value}, reversed: true}
+	}
+
+	return nil
+}
+
+func isAttributes(expr ast.Expr) bool {
+	if expr.Kind() != ast.SelectKind {
+		return false
+	}
+	sel := expr.AsSelect()
+	operand := sel.Operand()
+	return sel.FieldName() == attributesField && operand.Kind() == ast.IdentKind && operand.AsIdent() == userVariable
+}
+
+// attributeName returns the name of the attribute for user.attributes.<name>
+func attributeName(expr ast.Expr) (string, bool) {
+	if expr.Kind() != ast.SelectKind || expr.AsSelect().IsTestOnly() || !isAttributes(expr.AsSelect().Operand()) {
+		return "", false
+	}
+	return expr.AsSelect().FieldName(), true
+}
+
+func stringLiteral(expr ast.Expr) (string, bool) {
+	if expr.Kind() != ast.LiteralKind {
+		return "", false
+	}
+	value, ok := expr.AsLiteral().(types.String)
+	return string(value), ok
+}
+
+func stringList(expr ast.Expr) ([]string, bool) {
+	if expr.Kind() != ast.ListKind {
+		return nil, false
+	}
+
+	elements := expr.AsList().Elements()
+	values := make([]string, 0, len(elements))
+	for _, element := range elements {
+		value, ok := stringLiteral(element)
+		if !ok {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+
+	return values, true
+}
+
+func (n *andNode) evaluate(attributes map[string]any) bool {
+	return n.left.evaluate(attributes) && n.right.evaluate(attributes)
+}
+
+func (n *orNode) evaluate(attributes map[string]any) bool {
+	return n.left.evaluate(attributes) || n.right.evaluate(attributes)
+}
+
+func (n *notNode) evaluate(attributes map[string]any) bool {
+	return !n.operand.evaluate(attributes)
+}
+
+func (n *constNode) evaluate(map[string]any) bool {
+	return n.value
+}
+
+func (c *condition) evaluate(attributes map[string]any) bool {
+	value, ok := attributes[c.attribute]
+	if !ok || value == nil {
+		return false
+	}
+
+	values := c.values
+	if c.valueAttribute != "" {
+		other, ok := attributes[c.valueAttribute].(string)
+		if !ok {
+			return false
+		}
+		values = []string{other}
+	}
+
+	if c.reversed {
+		return slices.ContainsFunc(attributeValues(value), func(v string) bool {
+			return slices.Contains(values, v)
+		})
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return false
+	}
+
+	switch c.operator {
+	case operatorEquals:
+		return str == values[0]
+	case operatorNotEquals:
+		return str != values[0]
+	case operatorStartsWith:
+		return strings.HasPrefix(str, values[0])
+	case operatorEndsWith:
+		return strings.HasSuffix(str, values[0])
+	case operatorContains:
+		return strings.Contains(str, values[0])
+	case operatorIn:
+		return slices.Contains(values, str)
+	}
+
+	return false
+}
+
+// attributeValues returns the values of a multiselect attribute, or the value of a single one
+func attributeValues(value any) []string {
+	switch value := value.(type) {
+	case string:
+		return []string{value}
+	case []string:
+		return value
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if str, ok := v.(string); ok {
+				values = append(values, str)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
+
+// sqlBuilder collects the arguments of the SQL translation of an expression
+type sqlBuilder struct {
+	args []any
+}
+
+func (b *sqlBuilder) arg(value string) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d::text", len(b.args))
+}
+
+func attributeSQL(name string) string {
+	return "Attributes ->> '" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+func (n *andNode) toSQL(b *sqlBuilder) (string, bool) {
+	left, ok := n.left.toSQL(b)
+	if !ok {
+		return "", false
+	}
+	right, ok := n.right.toSQL(b)
+	if !ok {
+		return "", false
+	}
+	return "(" + left + " AND " + right + ")", true
+}
+
+func (n *orNode) toSQL(b *sqlBuilder) (string, bool) {
+	left, ok := n.left.toSQL(b)
+	if !ok {
+		return "", false
+	}
+	right, ok := n.right.toSQL(b)
+	if !ok {
+		return "", false
+	}
+	return "(" + left + " OR " + right + ")", true
+}
+
+func (n *notNode) toSQL(b *sqlBuilder) (string, bool) {
+	operand, ok := n.operand.toSQL(b)
+	if !ok {
+		return "", false
+	}
+	return "(NOT " + operand + ")", true
+}
+
+func (n *constNode) toSQL(*sqlBuilder) (string, bool) {
+	if n.value {
+		return "TRUE", true
+	}
+	return "FALSE", true
+}
+
+// toSQL wraps the comparisons with COALESCE so that, like in evaluate, a condition on a missing
+// attribute is false rather than NULL.
+func (c *condition) toSQL(b *sqlBuilder) (string, bool) {
+	attribute := attributeSQL(c.attribute)
+
+	var sql string
+	switch {
+	case c.reversed:
+		placeholders := make([]string, 0, len(c.values))
+		for _, value := range c.values {
+			placeholders = append(placeholders, b.arg(value))
+		}
+		sql = fmt.Sprintf("jsonb_exists_any(Attributes -> '%s', ARRAY[%s])", strings.ReplaceAll(c.attribute, "'", "''"), strings.Join(placeholders, ", "))
+	case c.valueAttribute != "":
+		switch c.operator {
+		case operatorEquals:
+			sql = attribute + " = " + attributeSQL(c.valueAttribute)
+		case operatorNotEquals:
+			sql = attribute + " != " + attributeSQL(c.valueAttribute)
+		default:
+			return "", false
+		}
+	default:
+		switch c.operator {
+		case operatorEquals:
+			sql = attribute + " = " + b.arg(c.values[0])
+		case operatorNotEquals:
+			sql = attribute + " != " + b.arg(c.values[0])
+		case operatorStartsWith:
+			sql = attribute + " LIKE " + b.arg(escapeLike(c.values[0])+"%")
+		case operatorEndsWith:
+			sql = attribute + " LIKE " + b.arg("%"+escapeLike(c.values[0]))
+		case operatorContains:
+			sql = attribute + " LIKE " + b.arg("%"+escapeLike(c.values[0])+"%")
+		case operatorIn:
+			if len(c.values) == 0 {
+				return "FALSE", true
+			}
+			placeholders := make([]string, 0, len(c.values))
+			for _, value := range c.values {
+				placeholders = append(placeholders, b.arg(value))
+			}
+			sql = attribute + " IN (" + strings.Join(placeholders, ", ") + ")"
+		default:
+			return "", false
+		}
+	}
+
+	return "COALESCE(" + sql + ", FALSE)", true
+}
+
+// toVisual converts the condition to the representation of the table editor
+func (c *condition) toVisual(fields map[string]*model.CPAField) model.Condition {
+	visual := model.Condition{
+		Attribute: attributePrefix + c.attribute,
+		Operator:  c.operator,
+		ValueType: model.LiteralValue,
+	}
+
+	switch {
+	case c.valueAttribute != "":
+		visual.Value = attributePrefix + c.valueAttribute
+		visual.ValueType = model.AttrValue
+	case c.list || c.reversed:
+		visual.Value = c.values
+	default:
+		visual.Value = c.values[0]
+	}
+
+	if field, ok := fields[c.attribute]; ok {
+		visual.AttributeType = string(field.Type)
+	}
+
+	return visual
+}
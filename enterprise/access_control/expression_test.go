@@ -0,0 +1,171 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package access_control
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func compileForTest(t *testing.T, text string) *expression {
+	t.Helper()
+
+	env, err := newEnv()
+	require.NoError(t, err)
+
+	expr, errs := compile(env, text)
+	require.Empty(t, errs)
+	return expr
+}
+
+func TestCompileErrors(t *testing.T) {
+	env, err := newEnv()
+	require.NoError(t, err)
+
+	t.Run("syntax error", func(t *testing.T) {
+		_, errs := compile(env, `user.attributes.department == `)
+		require.NotEmpty(t, errs)
+		assert.Equal(t, 1, errs[0].Line)
+		assert.Equal(t, 30, errs[0].Column)
+	})
+
+	t.Run("undeclared variable", func(t *testing.T) {
+		_, errs := compile(env, "user.attributes.department == 'eng' &&\n  team.name == 'a'")
+		require.Len(t, errs, 1)
+		assert.Equal(t, 2, errs[0].Line)
+		assert.Equal(t, 2, errs[0].Column)
+	})
+
+	t.Run("not a bool", func(t *testing.T) {
+		_, errs := compile(env, `'engineering'`)
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Message, "bool")
+	})
+}
+
+func TestCheckAttributes(t *testing.T) {
+	fields := map[string]*model.CPAField{
+		"department": {PropertyField: model.PropertyField{Name: "department", Type: model.PropertyFieldTypeSelect}},
+	}
+
+	expr := compileForTest(t, `user.attributes.department == 'eng' && user.attributes.office == 'Paris' || user.roles == 'admin'`)
+	errs := expr.checkAttributes(fields)
+	require.Len(t, errs, 2)
+	messages := []string{errs[0].Message, errs[1].Message}
+	assert.Contains(t, messages, "unknown attribute 'office'")
+	assert.Contains(t, messages, "undefined field 'roles', only user.attributes can be used")
+	for _, err := range errs {
+		assert.Equal(t, 1, err.Line)
+		assert.NotZero(t, err.Column)
+	}
+
+	expr = compileForTest(t, `user.attributes.department in ['eng', 'sales']`)
+	assert.Empty(t, expr.checkAttributes(fields))
+}
+
+func TestEvaluate(t *testing.T) {
+	attributes := map[string]any{
+		"department": "engineering",
+		"office":     "Paris",
+		"manager":    "Paris",
+		"languages":  []any{"go", "typescript"},
+	}
+
+	for _, tc := range []struct {
+		expression string
+		expected   bool
+	}{
+		{`user.attributes.department == 'engineering'`, true},
+		{`user.attributes.department != 'engineering'`, false},
+		{`user.attributes.department.startsWith('eng')`, true},
+		{`user.attributes.department.endsWith('ring')`, true},
+		{`user.attributes.department.contains('gin')`, true},
+		{`user.attributes.department in ['sales', 'engineering']`, true},
+		{`['rust', 'go'] in user.attributes.languages`, true},
+		{`['rust'] in user.attributes.languages`, false},
+		{`user.attributes.office == user.attributes.manager`, true},
+		{`user.attributes.department == 'sales' || !(user.attributes.office == 'Berlin')`, true},
+		{`user.attributes.department == 'engineering' && user.attributes.office == 'Berlin'`, false},
+		{`user.attributes.missing == 'x'`, false},
+		{`user.attributes.missing != 'x'`, false},
+		{`true`, true},
+		// Not converted to nodes, evaluated by the CEL program
+		{`size(user.attributes.department) > 3`, true},
+		{`user.attributes.missing.size() > 3`, false},
+	} {
+		t.Run(tc.expression, func(t *testing.T) {
+			assert.Equal(t, tc.expected, compileForTest(t, tc.expression).evaluate(attributes))
+		})
+	}
+}
+
+func TestToSQL(t *testing.T) {
+	for _, tc := range []struct {
+		expression string
+		query      string
+		args       []any
+	}{
+		{
+			`user.attributes.department == 'engineering'`,
+			`COALESCE(Attributes ->> 'department' = $1::text, FALSE)`,
+			[]any{"engineering"},
+		},
+		{
+			`user.attributes.department.startsWith('eng_') && !(user.attributes.office in ['Paris', 'Berlin'])`,
+			`(COALESCE(Attributes ->> 'department' LIKE $1::text, FALSE) AND (NOT COALESCE(Attributes ->> 'office' IN ($2::text, $3::text), FALSE)))`,
+			[]any{`eng\_%`, "Paris", "Berlin"},
+		},
+		{
+			`['go', 'rust'] in user.attributes.languages || user.attributes.office != user.attributes.manager`,
+			`(COALESCE(jsonb_exists_any(Attributes -> 'languages', ARRAY[$1::text, $2::text]), FALSE) OR COALESCE(Attributes ->> 'office' != Attributes ->> 'manager', FALSE))`,
+			[]any{"go", "rust"},
+		},
+	} {
+		t.Run(tc.expression, func(t *testing.T) {
+			query, args, ok := compileForTest(t, tc.expression).toSQL()
+			require.True(t, ok)
+			assert.Equal(t, tc.query, query)
+			assert.Equal(t, tc.args, args)
+		})
+	}
+
+	for _, text := range []string{
+		`size(user.attributes.department) > 3`,
+		`user.attributes.office.startsWith(user.attributes.manager)`,
+	} {
+		t.Run(text, func(t *testing.T) {
+			_, _, ok := compileForTest(t, text).toSQL()
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestConditionsToVisual(t *testing.T) {
+	fields := map[string]*model.CPAField{
+		"department": {PropertyField: model.PropertyField{Name: "department", Type: model.PropertyFieldTypeSelect}},
+		"languages":  {PropertyField: model.PropertyField{Name: "languages", Type: model.PropertyFieldTypeMultiselect}},
+	}
+
+	expr := compileForTest(t, `user.attributes.department in ['eng', 'sales'] && ['go'] in user.attributes.languages && user.attributes.office == user.attributes.manager && user.attributes.team.endsWith('-a')`)
+	conditions, ok := expr.conditions()
+	require.True(t, ok)
+
+	visual := make([]model.Condition, 0, len(conditions))
+	for _, c := range conditions {
+		visual = append(visual, c.toVisual(fields))
+	}
+	assert.Equal(t, []model.Condition{
+		{Attribute: "user.attributes.department", Operator: "in", Value: []string{"eng", "sales"}, ValueType: model.LiteralValue, AttributeType: "select"},
+		{Attribute: "user.attributes.languages", Operator: "in", Value: []string{"go"}, ValueType: model.LiteralValue, AttributeType: "multiselect"},
+		{Attribute: "user.attributes.office", Operator: "==", Value: "user.attributes.manager", ValueType: model.AttrValue},
+		{Attribute: "user.attributes.team", Operator: "endsWith", Value: "-a", ValueType: model.LiteralValue},
+	}, visual)
+
+	_, ok = compileForTest(t, `user.attributes.department == 'eng' || user.attributes.department == 'sales'`).conditions()
+	assert.False(t, ok)
+}
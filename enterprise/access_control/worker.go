@@ -0,0 +1,176 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package access_control
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	ejobs "github.com/mattermost/mattermost/server/v8/einterfaces/jobs"
+)
+
+const (
+	workerName = "AccessControlSync"
+
+	// syncPeriod is how often the members of every channel with a policy are checked, to catch
+	// up with the attributes changed since the last run
+	syncPeriod = time.Hour
+
+	// policyIDKey is the job data naming the channel or parent policy to sync, every channel
+	// policy being synced without it
+	policyIDKey = "policy_id"
+
+	policiesPageSize = 100
+)
+
+// jobInterface builds the worker and scheduler of the access_control_sync job
+type jobInterface struct {
+	server *app.Server
+}
+
+func (ji *jobInterface) MakeWorker() model.Worker {
+	a := app.New(app.ServerConnector(ji.server.Channels()))
+	return MakeWorker(ji.server.Jobs, a, New(a))
+}
+
+func (ji *jobInterface) MakeScheduler() ejobs.Scheduler {
+	return MakeScheduler(ji.server.Jobs)
+}
+
+func isEnabled(cfg *model.Config) bool {
+	return *cfg.AccessControlSettings.EnableAttributeBasedAccessControl
+}
+
+// MakeScheduler runs the job every hour while attribute based access control is enabled
+func MakeScheduler(jobServer *jobs.JobServer) *jobs.PeriodicScheduler {
+	return jobs.NewPeriodicScheduler(jobServer, model.JobTypeAccessControlSync, syncPeriod, isEnabled)
+}
+
+func MakeWorker(jobServer *jobs.JobServer, a *app.App, service *AccessControlService) *jobs.SimpleWorker {
+	execute := func(logger mlog.LoggerIFace, job *model.Job) error {
+		defer jobServer.HandleJobPanic(logger, job)
+
+		if job.Data == nil {
+			job.Data = make(model.StringMap)
+		}
+
+		s := &syncer{
+			app:     a,
+			service: service,
+			rctx:    request.EmptyContext(logger),
+			progress: func(channels, removed int64) {
+				job.Data["channels_synced"] = strconv.FormatInt(channels, 10)
+				job.Data["members_removed"] = strconv.FormatInt(removed, 10)
+				if err := jobServer.UpdateInProgressJobData(job); err != nil {
+					logger.Warn("Failed to update the progress of the job", mlog.Err(err))
+				}
+			},
+		}
+		return s.sync(job.Data[policyIDKey])
+	}
+	return jobs.NewSimpleWorker(workerName, jobServer, execute, isEnabled)
+}
+
+// syncer removes from the channels with a policy the members who no longer satisfy it
+type syncer struct {
+	app      *app.App
+	service  *AccessControlService
+	rctx     request.CTX
+	progress func(channels, removed int64)
+
+	channels int64
+	removed  int64
+	remover  string
+}
+
+func (s *syncer) sync(policyID string) error {
+	if err := s.app.Srv().Store().Attributes().RefreshAttributes(); err != nil {
+		return errors.Wrap(err, "failed to refresh the attributes")
+	}
+
+	bot, appErr := s.app.GetSystemBot(s.rctx)
+	if appErr != nil {
+		return errors.Wrap(appErr, "failed to get the system bot")
+	}
+	s.remover = bot.UserId
+
+	if policyID != "" {
+		policy, appErr := s.service.GetPolicy(s.rctx, policyID)
+		if appErr != nil {
+			return errors.Wrapf(appErr, "failed to get the policy %s", policyID)
+		}
+		if policy.Type == model.AccessControlPolicyTypeChannel {
+			return s.syncChannel(policy.ID)
+		}
+	}
+
+	// A parent policy syncs the channels importing it, and no policy every channel with one
+	search := model.AccessControlPolicySearch{
+		Type:     model.AccessControlPolicyTypeChannel,
+		ParentID: policyID,
+		Limit:    policiesPageSize,
+	}
+	for {
+		policies, _, err := s.app.Srv().Store().AccessControlPolicy().SearchPolicies(s.rctx, search)
+		if err != nil {
+			return errors.Wrap(err, "failed to get the channel policies")
+		}
+
+		for _, policy := range policies {
+			if err := s.syncChannel(policy.ID); err != nil {
+				return err
+			}
+		}
+
+		if len(policies) < policiesPageSize {
+			return nil
+		}
+		search.Cursor.ID = policies[len(policies)-1].ID
+	}
+}
+
+// syncChannel removes the members of the channel who don't satisfy its policy, the channel
+// having the ID of its policy
+func (s *syncer) syncChannel(channelID string) error {
+	channel, appErr := s.app.GetChannel(s.rctx, channelID)
+	if appErr != nil {
+		return errors.Wrapf(appErr, "failed to get the channel %s", channelID)
+	}
+
+	members, appErr := s.service.GetChannelMembersToRemove(s.rctx, channelID)
+	if appErr != nil {
+		return errors.Wrapf(appErr, "failed to get the members to remove from the channel %s", channelID)
+	}
+
+	for _, member := range members {
+		user, appErr := s.app.GetUser(member.UserId)
+		if appErr != nil {
+			return errors.Wrapf(appErr, "failed to get the user %s", member.UserId)
+		}
+		// Bots don't have attributes, they are managed by the admins of the channel
+		if user.IsBot {
+			continue
+		}
+
+		if appErr := s.app.RemoveUserFromChannel(s.rctx, member.UserId, s.remover, channel); appErr != nil {
+			s.rctx.Logger().Warn("Failed to remove a user not satisfying the policy from the channel",
+				mlog.String("channel_id", channelID),
+				mlog.String("user_id", member.UserId),
+				mlog.Err(appErr))
+			continue
+		}
+		s.removed++
+	}
+
+	s.channels++
+	s.progress(s.channels, s.removed)
+	return nil
+}
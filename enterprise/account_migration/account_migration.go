@@ -0,0 +1,190 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package account_migration implements einterfaces.AccountMigrationInterface, which moves the
+// users of an authentication service to AD/LDAP or SAML by rewriting their AuthService and
+// AuthData. The users keep their accounts, and sign in with the new service from then on.
+package account_migration
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+)
+
+// Fields of the users which can be matched to their LDAP entries
+const (
+	matchFieldEmail    = "email"
+	matchFieldUsername = "username"
+)
+
+func init() {
+	app.RegisterAccountMigrationInterface(func(a *app.App) einterfaces.AccountMigrationInterface {
+		return New(a)
+	})
+}
+
+type AccountMigration struct {
+	app *app.App
+}
+
+func New(a *app.App) *AccountMigration {
+	return &AccountMigration{app: a}
+}
+
+// migration is the AuthData a user is moved to
+type migration struct {
+	user     *model.User
+	authData string
+}
+
+// matchLdapUsers pairs the users with the LDAP users having the same email or username.
+// Unmatched users are an error unless forced, in which case they are skipped, and so are the
+// users matching several LDAP users.
+func matchLdapUsers(users []*model.User, ldapUsers []*model.User, matchField string, force bool) ([]migration, *model.AppError) {
+	key := func(user *model.User) string {
+		if matchField == matchFieldUsername {
+			return strings.ToLower(user.Username)
+		}
+		return strings.ToLower(user.Email)
+	}
+
+	byKey := make(map[string][]*model.User, len(ldapUsers))
+	for _, ldapUser := range ldapUsers {
+		byKey[key(ldapUser)] = append(byKey[key(ldapUser)], ldapUser)
+	}
+
+	migrations := make([]migration, 0, len(users))
+	for _, user := range users {
+		matches := byKey[key(user)]
+		switch {
+		case len(matches) == 1 && matches[0].AuthData != nil:
+			migrations = append(migrations, migration{user: user, authData: *matches[0].AuthData})
+		case force:
+			continue
+		case len(matches) == 0:
+			return nil, model.NewAppError("MigrateToLdap", "ent.migration.migratetoldap.user_not_found", nil, matchField+"="+key(user), http.StatusBadRequest)
+		default:
+			return nil, model.NewAppError("MigrateToLdap", "ent.migration.migratetoldap.duplicate_field", nil, matchField+"="+key(user), http.StatusBadRequest)
+		}
+	}
+
+	return migrations, nil
+}
+
+// MigrateToLdap moves the users of fromAuthService, the empty string standing for email, to the
+// LDAP users having the same email or username
+func (am *AccountMigration) MigrateToLdap(rctx request.CTX, fromAuthService string, foreignUserFieldNameToMatch string, force bool, dryRun bool) *model.AppError {
+	if foreignUserFieldNameToMatch != matchFieldEmail && foreignUserFieldNameToMatch != matchFieldUsername {
+		return model.NewAppError("MigrateToLdap", "api.context.invalid_body_param.app_error", map[string]any{"Name": "match_field"}, "", http.StatusBadRequest)
+	}
+
+	ldapI := am.app.Ldap()
+	if ldapI == nil {
+		return model.NewAppError("MigrateToLdap", "ent.ldap.disabled.app_error", nil, "", http.StatusNotImplemented)
+	}
+
+	users, err := am.app.Srv().Store().User().GetAllUsingAuthService(fromAuthService)
+	if err != nil {
+		return model.NewAppError("MigrateToLdap", "ent.account_migration.get_all_failed", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	ldapUsers, appErr := ldapI.GetAllLdapUsers(rctx)
+	if appErr != nil {
+		return appErr
+	}
+
+	migrations, appErr := matchLdapUsers(users, ldapUsers, foreignUserFieldNameToMatch, force)
+	if appErr != nil {
+		return appErr
+	}
+
+	return am.migrate(rctx, migrations, model.UserAuthServiceLdap, dryRun)
+}
+
+// matchSamlUsers pairs the users with their SAML AuthData, read from usersMap by email, or taken
+// from their email when auto. LDAP users keep their AuthData when auto, since the IdP usually
+// reads them from the same directory.
+func matchSamlUsers(users []*model.User, fromAuthService string, usersMap map[string]string, auto bool) ([]migration, *model.AppError) {
+	migrations := make([]migration, 0, len(users))
+	seen := make(map[string]bool, len(users))
+
+	for _, user := range users {
+		var authData string
+		switch {
+		case auto && fromAuthService == model.UserAuthServiceLdap && user.AuthData != nil:
+			authData = *user.AuthData
+		case auto:
+			authData = user.Email
+		default:
+			var ok bool
+			if authData, ok = usersMap[user.Email]; !ok {
+				return nil, model.NewAppError("MigrateToSaml", "ent.migration.migratetosaml.user_not_found_in_users_mapping_file", nil, "email="+user.Email, http.StatusBadRequest)
+			}
+		}
+
+		if seen[authData] {
+			return nil, model.NewAppError("MigrateToSaml", "ent.migration.migratetosaml.email_already_used_by_other_user", nil, "email="+user.Email, http.StatusBadRequest)
+		}
+		seen[authData] = true
+
+		migrations = append(migrations, migration{user: user, authData: authData})
+	}
+
+	return migrations, nil
+}
+
+// MigrateToSaml moves the users of fromAuthService, the empty string standing for email, to
+// SAML
+func (am *AccountMigration) MigrateToSaml(rctx request.CTX, fromAuthService string, usersMap map[string]string, auto bool, dryRun bool) *model.AppError {
+	users, err := am.app.Srv().Store().User().GetAllUsingAuthService(fromAuthService)
+	if err != nil {
+		return model.NewAppError("MigrateToSaml", "ent.account_migration.get_all_failed", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	migrations, appErr := matchSamlUsers(users, fromAuthService, usersMap, auto)
+	if appErr != nil {
+		return appErr
+	}
+
+	samlUsers, err := am.app.Srv().Store().User().GetAllUsingAuthService(model.UserAuthServiceSaml)
+	if err != nil {
+		return model.NewAppError("MigrateToSaml", "ent.account_migration.get_saml_users_failed", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	taken := make(map[string]bool, len(samlUsers))
+	for _, samlUser := range samlUsers {
+		if samlUser.AuthData != nil {
+			taken[*samlUser.AuthData] = true
+		}
+	}
+	for _, m := range migrations {
+		if taken[m.authData] {
+			return model.NewAppError("MigrateToSaml", "ent.migration.migratetosaml.email_already_used_by_other_user", nil, "email="+m.user.Email, http.StatusBadRequest)
+		}
+	}
+
+	return am.migrate(rctx, migrations, model.UserAuthServiceSaml, dryRun)
+}
+
+func (am *AccountMigration) migrate(rctx request.CTX, migrations []migration, authService string, dryRun bool) *model.AppError {
+	for _, m := range migrations {
+		logger := rctx.Logger().With(mlog.String("user_id", m.user.Id), mlog.String("auth_service", authService))
+		if dryRun {
+			logger.Info("Would migrate the user")
+			continue
+		}
+
+		if _, err := am.app.Srv().Store().User().UpdateAuthData(m.user.Id, authService, &m.authData, "", false); err != nil {
+			return model.NewAppError("migrate", "app.user.update_auth_data.app_error", nil, "user_id="+m.user.Id, http.StatusInternalServerError).Wrap(err)
+		}
+		am.app.InvalidateCacheForUser(m.user.Id)
+		logger.Info("Migrated the user")
+	}
+
+	return nil
+}
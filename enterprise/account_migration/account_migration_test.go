@@ -0,0 +1,91 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package account_migration
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func newUser(username, email string, authData *string) *model.User {
+	return &model.User{Id: model.NewId(), Username: username, Email: email, AuthData: authData}
+}
+
+func TestMatchLdapUsers(t *testing.T) {
+	alice := newUser("alice", "alice@example.com", nil)
+	bob := newUser("bob", "Bob@example.com", nil)
+	carol := newUser("carol", "carol@example.com", nil)
+
+	ldapUsers := []*model.User{
+		newUser("alice.l", "alice@example.com", model.NewPointer("uid-alice")),
+		newUser("bob", "bob@example.com", model.NewPointer("uid-bob")),
+		newUser("carol", "carol.one@example.com", model.NewPointer("uid-carol-1")),
+		newUser("carol", "carol.two@example.com", model.NewPointer("uid-carol-2")),
+	}
+
+	t.Run("by email", func(t *testing.T) {
+		migrations, appErr := matchLdapUsers([]*model.User{alice, bob}, ldapUsers, matchFieldEmail, false)
+		require.Nil(t, appErr)
+		require.Len(t, migrations, 2)
+		assert.Equal(t, "uid-alice", migrations[0].authData)
+		assert.Equal(t, "uid-bob", migrations[1].authData)
+	})
+
+	t.Run("unmatched user", func(t *testing.T) {
+		_, appErr := matchLdapUsers([]*model.User{alice, carol}, ldapUsers, matchFieldEmail, false)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.migration.migratetoldap.user_not_found", appErr.Id)
+	})
+
+	t.Run("duplicate match", func(t *testing.T) {
+		_, appErr := matchLdapUsers([]*model.User{bob, carol}, ldapUsers, matchFieldUsername, false)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.migration.migratetoldap.duplicate_field", appErr.Id)
+	})
+
+	t.Run("forced runs skip the users", func(t *testing.T) {
+		migrations, appErr := matchLdapUsers([]*model.User{alice, bob, carol}, ldapUsers, matchFieldUsername, true)
+		require.Nil(t, appErr)
+		require.Len(t, migrations, 1)
+		assert.Equal(t, bob, migrations[0].user)
+		assert.Equal(t, "uid-bob", migrations[0].authData)
+	})
+}
+
+func TestMatchSamlUsers(t *testing.T) {
+	alice := newUser("alice", "alice@example.com", model.NewPointer("uid-alice"))
+	bob := newUser("bob", "bob@example.com", nil)
+
+	t.Run("users map", func(t *testing.T) {
+		migrations, appErr := matchSamlUsers([]*model.User{alice, bob}, "", map[string]string{"alice@example.com": "saml-alice", "bob@example.com": "saml-bob"}, false)
+		require.Nil(t, appErr)
+		require.Len(t, migrations, 2)
+		assert.Equal(t, "saml-alice", migrations[0].authData)
+		assert.Equal(t, "saml-bob", migrations[1].authData)
+
+		_, appErr = matchSamlUsers([]*model.User{alice, bob}, "", map[string]string{"alice@example.com": "saml-alice"}, false)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.migration.migratetosaml.user_not_found_in_users_mapping_file", appErr.Id)
+
+		_, appErr = matchSamlUsers([]*model.User{alice, bob}, "", map[string]string{"alice@example.com": "saml", "bob@example.com": "saml"}, false)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.migration.migratetosaml.email_already_used_by_other_user", appErr.Id)
+	})
+
+	t.Run("auto", func(t *testing.T) {
+		migrations, appErr := matchSamlUsers([]*model.User{alice, bob}, model.UserAuthServiceLdap, nil, true)
+		require.Nil(t, appErr)
+		require.Len(t, migrations, 2)
+		assert.Equal(t, "uid-alice", migrations[0].authData)
+		assert.Equal(t, "bob@example.com", migrations[1].authData)
+
+		migrations, appErr = matchSamlUsers([]*model.User{alice}, "", nil, true)
+		require.Nil(t, appErr)
+		assert.Equal(t, "alice@example.com", migrations[0].authData)
+	})
+}
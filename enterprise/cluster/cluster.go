@@ -0,0 +1,400 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package cluster implements einterfaces.ClusterInterface on top of the Redis server already
+// used as the cache backend. Messages are exchanged over Redis pub/sub: every node subscribes to
+// a broadcast channel shared by the cluster and to a channel of its own, used for messages sent
+// to a single node and for the replies to gossip requests. The nodes register themselves in a
+// sorted set, so that requests know how many replies to wait for, and the leader holds a lease in
+// Redis.
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app/platform"
+	"github.com/mattermost/mattermost/server/v8/config"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+
+	"github.com/redis/rueidis"
+)
+
+const (
+	// publishTimeout bounds a single PUBLISH to Redis
+	publishTimeout = 5 * time.Second
+
+	// reliablePublishAttempts is the number of times a reliable message is published before giving up
+	reliablePublishAttempts = 3
+
+	// maxReceiveBackoff is the longest wait before subscribing again after losing the subscription
+	maxReceiveBackoff = 30 * time.Second
+
+	// dispatchWorkers is the number of goroutines running the message handlers. The messages of an
+	// event are always handled by the same worker, in the order they were received, so that a slow
+	// handler only delays the events sharing its worker.
+	dispatchWorkers = 8
+
+	// inboundQueueSize is the number of received messages waiting for each worker
+	inboundQueueSize = 10000
+)
+
+// platformService is the part of platform.PlatformService used by the cluster
+type platformService interface {
+	Config() *model.Config
+	Log() mlog.LoggerIFace
+	Metrics() einterfaces.MetricsInterface
+	InvokeClusterLeaderChangedListeners()
+	TotalWebsocketConnections() int
+	TotalMasterDbConnections() int
+	TotalReadDbConnections() int
+	GetLogsSkipSend(rctx request.CTX, page, perPage int, logFilter *model.LogFilter) ([]string, *model.AppError)
+	GenerateSupportPacket(rctx request.CTX, options *model.SupportPacketOptions) ([]model.FileData, error)
+	GetPluginStatuses() (model.PluginStatuses, *model.AppError)
+	WebConnCountForUser(userID string) int
+	GetWSQueues(userID, connectionID string, seqNum int64) (*model.WSQueues, error)
+	ReloadConfig() error
+	DescribeConfig() string
+	ClientConfigHash() string
+	DatabaseTypeAndSchemaVersion() (string, string, error)
+}
+
+// platformAdapter exposes the store's connection counts on the PlatformService
+type platformAdapter struct {
+	*platform.PlatformService
+}
+
+func (a platformAdapter) TotalMasterDbConnections() int {
+	return a.Store.TotalMasterDbConnections()
+}
+
+func (a platformAdapter) TotalReadDbConnections() int {
+	return a.Store.TotalReadDbConnections()
+}
+
+func init() {
+	platform.RegisterClusterInterface(func(ps *platform.PlatformService) einterfaces.ClusterInterface {
+		if !*ps.Config().ClusterSettings.Enable {
+			return nil
+		}
+
+		client, ok := ps.GetRedisClient().(rueidis.Client)
+		if !ok {
+			ps.Log().Error("High availability requires Redis as the cache backend. Set CacheSettings.CacheType to redis to enable it.")
+			return nil
+		}
+
+		cluster := New(platformAdapter{ps}, client)
+		cluster.discovery = ps.NewClusterDiscoveryService()
+		cluster.discovery.Type = model.CDSTypeApp
+		cluster.discovery.ClusterName = *ps.Config().ClusterSettings.ClusterName
+		cluster.discovery.Hostname = cluster.hostname
+		cluster.discovery.GossipPort = int32(*ps.Config().ClusterSettings.GossipPort)
+
+		return cluster
+	})
+}
+
+// envelope is the payload published to Redis
+type envelope struct {
+	From    string                `json:"from"`
+	Message *model.ClusterMessage `json:"message"`
+}
+
+type RedisCluster struct {
+	ps        platformService
+	client    rueidis.Client
+	discovery *platform.ClusterDiscoveryService
+
+	id       string
+	hostname string
+	prefix   string
+
+	handlers    map[model.ClusterEvent]einterfaces.ClusterMessageHandler
+	handlersMut sync.RWMutex
+
+	pending    map[string]chan *model.ClusterMessage
+	pendingMut sync.Mutex
+
+	inbound         []chan *model.ClusterMessage
+	dropped         atomic.Int64
+	subscribed      atomic.Bool
+	isLeader        atomic.Bool
+	receiveFailures atomic.Int32
+
+	// leaseExpiresAt is when the lease held by the node expires at the latest, only used by holdLease
+	leaseExpiresAt time.Time
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a cluster node which communicates through the given Redis client.
+// Communication starts with StartInterNodeCommunication.
+func New(ps platformService, client rueidis.Client) *RedisCluster {
+	settings := ps.Config().ClusterSettings
+
+	hostname := *settings.OverrideHostname
+	if hostname == "" {
+		if *settings.UseIPAddress {
+			hostname = model.GetServerIPAddress(*settings.NetworkInterface)
+		} else if hn, err := os.Hostname(); err == nil {
+			hostname = hn
+		}
+	}
+
+	inbound := make([]chan *model.ClusterMessage, dispatchWorkers)
+	for i := range inbound {
+		inbound[i] = make(chan *model.ClusterMessage, inboundQueueSize)
+	}
+
+	return &RedisCluster{
+		ps:       ps,
+		client:   client,
+		id:       model.NewId(),
+		hostname: hostname,
+		prefix:   *ps.Config().CacheSettings.RedisCachePrefix + "cluster:" + *settings.ClusterName + ":",
+		handlers: make(map[model.ClusterEvent]einterfaces.ClusterMessageHandler),
+		pending:  make(map[string]chan *model.ClusterMessage),
+		inbound:  inbound,
+	}
+}
+
+func (c *RedisCluster) broadcastChannel() string {
+	return c.prefix + "broadcast"
+}
+
+func (c *RedisCluster) nodeChannel(nodeID string) string {
+	return c.prefix + "node:" + nodeID
+}
+
+func (c *RedisCluster) StartInterNodeCommunication() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+
+	c.wg.Add(3 + len(c.inbound))
+	go c.receive(ctx)
+	go c.heartbeat(ctx)
+	go c.holdLease(ctx)
+	for _, queue := range c.inbound {
+		go c.dispatch(ctx, queue)
+	}
+
+	if c.discovery != nil {
+		c.discovery.Start()
+	}
+
+	c.ps.Log().Info("Started inter-node communication through Redis",
+		mlog.String("cluster_id", c.id),
+		mlog.String("hostname", c.hostname),
+		mlog.String("cluster_name", *c.ps.Config().ClusterSettings.ClusterName),
+	)
+
+	if !config.IsDatabaseDSN(c.ps.DescribeConfig()) {
+		c.ps.Log().Warn("The configuration is read from a file: it must be shared by every node for the changes saved on one node to apply to the others")
+	}
+}
+
+func (c *RedisCluster) StopInterNodeCommunication() {
+	if c.cancel == nil {
+		return
+	}
+
+	if c.discovery != nil {
+		c.discovery.Stop()
+	}
+
+	c.cancel()
+	c.wg.Wait()
+	c.cancel = nil
+
+	c.releaseLease()
+	c.subscribed.Store(false)
+	c.deregister()
+
+	c.ps.Log().Info("Stopped inter-node communication through Redis", mlog.String("cluster_id", c.id))
+}
+
+// receive keeps the node subscribed to its channels until ctx is done
+func (c *RedisCluster) receive(ctx context.Context) {
+	defer c.wg.Done()
+
+	subscribe := c.client.B().Subscribe().Channel(c.broadcastChannel(), c.nodeChannel(c.id)).Build()
+	backoff := time.Second
+	for {
+		err := c.client.Receive(ctx, subscribe, func(msg rueidis.PubSubMessage) {
+			c.receiveFailures.Store(0)
+			c.NotifyMsg([]byte(msg.Message))
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		// Other nodes would wait for the replies of a node which can't receive their requests
+		if c.subscribed.Swap(false) {
+			c.deregister()
+		}
+
+		c.receiveFailures.Add(1)
+		c.ps.Log().Warn("Lost the cluster subscription, subscribing again", mlog.Duration("backoff", backoff), mlog.Err(err))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, maxReceiveBackoff)
+	}
+}
+
+// dispatch runs the registered handlers of the messages in queue, in the order they were received
+func (c *RedisCluster) dispatch(ctx context.Context, queue chan *model.ClusterMessage) {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-queue:
+			c.handlersMut.RLock()
+			handler := c.handlers[msg.Event]
+			c.handlersMut.RUnlock()
+
+			if handler == nil {
+				c.ps.Log().Debug("No handler registered for cluster message", mlog.String("event", string(msg.Event)))
+				continue
+			}
+			handler(msg)
+		}
+	}
+}
+
+func (c *RedisCluster) RegisterClusterMessageHandler(event model.ClusterEvent, crm einterfaces.ClusterMessageHandler) {
+	c.handlersMut.Lock()
+	defer c.handlersMut.Unlock()
+
+	c.handlers[event] = crm
+}
+
+func (c *RedisCluster) GetClusterId() string {
+	return c.id
+}
+
+// HealthScore is the number of consecutive failed attempts to subscribe to the cluster channels
+func (c *RedisCluster) HealthScore() int {
+	return int(c.receiveFailures.Load())
+}
+
+func (c *RedisCluster) GetMyClusterInfo() *model.ClusterInfo {
+	_, schemaVersion, err := c.ps.DatabaseTypeAndSchemaVersion()
+	if err != nil {
+		c.ps.Log().Warn("Failed to get the database schema version", mlog.Err(err))
+	}
+
+	return &model.ClusterInfo{
+		Id:            c.id,
+		Version:       model.CurrentVersion,
+		SchemaVersion: schemaVersion,
+		ConfigHash:    c.ps.ClientConfigHash(),
+		IPAddress:     model.GetServerIPAddress(*c.ps.Config().ClusterSettings.NetworkInterface),
+		Hostname:      c.hostname,
+	}
+}
+
+func (c *RedisCluster) SendClusterMessage(msg *model.ClusterMessage) {
+	if err := c.publish(c.broadcastChannel(), msg); err != nil {
+		c.ps.Log().Warn("Failed to send cluster message", mlog.String("event", string(msg.Event)), mlog.Err(err))
+	}
+}
+
+func (c *RedisCluster) SendClusterMessageToNode(nodeID string, msg *model.ClusterMessage) error {
+	return c.publish(c.nodeChannel(nodeID), msg)
+}
+
+// publish sends msg on channel, retrying reliable messages
+func (c *RedisCluster) publish(channel string, msg *model.ClusterMessage) error {
+	payload, err := json.Marshal(&envelope{From: c.id, Message: msg})
+	if err != nil {
+		return model.NewAppError("publish", "ent.cluster.json_encode.error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	attempts := 1
+	if msg.SendType == model.ClusterSendReliable {
+		attempts = reliablePublishAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+		err = c.client.Do(ctx, c.client.B().Publish().Channel(channel).Message(string(payload)).Build()).Error()
+		cancel()
+		if err == nil || attempt == attempts {
+			return err
+		}
+		time.Sleep(time.Duration(attempt) * 100 * time.Millisecond)
+	}
+}
+
+// NotifyMsg handles a message received from another node
+func (c *RedisCluster) NotifyMsg(buf []byte) {
+	var env envelope
+	if err := json.Unmarshal(buf, &env); err != nil {
+		c.ps.Log().Warn("Failed to decode cluster message", mlog.Err(err))
+		return
+	}
+
+	if env.Message == nil {
+		return
+	}
+
+	// Broadcasts are delivered to their sender as well, the node only handles its own probes
+	if env.From == c.id {
+		if env.Message.Event == eventProbe {
+			c.subscribed.Store(true)
+		}
+		return
+	}
+
+	msg := env.Message
+	event := string(msg.Event)
+	switch {
+	case strings.HasPrefix(event, gossipResponsePrefix):
+		c.deliverResponse(msg)
+	case strings.HasPrefix(event, gossipRequestPrefix):
+		go c.handleRequest(env.From, msg)
+	default:
+		select {
+		case c.inbound[c.queueIndex(msg.Event)] <- msg:
+		default:
+			c.dropMsg(msg)
+		}
+	}
+}
+
+// queueIndex returns the worker handling the messages of event
+func (c *RedisCluster) queueIndex(event model.ClusterEvent) int {
+	h := fnv.New32a()
+	h.Write([]byte(event))
+	return int(h.Sum32() % uint32(len(c.inbound)))
+}
+
+// dropMsg reports a message dropped because its worker has too many messages waiting
+func (c *RedisCluster) dropMsg(msg *model.ClusterMessage) {
+	dropped := c.dropped.Add(1)
+	if metrics := c.ps.Metrics(); metrics != nil {
+		metrics.IncrementClusterEventDropped(msg.Event)
+	}
+	c.ps.Log().Warn("Dropped cluster message, too many are waiting for their handlers",
+		mlog.String("event", string(msg.Event)),
+		mlog.Int("total_dropped", dropped),
+	)
+}
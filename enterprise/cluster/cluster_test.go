@@ -0,0 +1,386 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+	"github.com/mattermost/mattermost/server/v8/einterfaces/mocks"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/rueidis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePlatform answers the cluster's queries with fixed values
+type fakePlatform struct {
+	cfg            *model.Config
+	logger         mlog.LoggerIFace
+	metrics        einterfaces.MetricsInterface
+	leaderChanges  atomic.Int32
+	webConnCount   int
+	reloadedConfig atomic.Bool
+}
+
+func (p *fakePlatform) Config() *model.Config                 { return p.cfg }
+func (p *fakePlatform) Log() mlog.LoggerIFace                 { return p.logger }
+func (p *fakePlatform) Metrics() einterfaces.MetricsInterface { return p.metrics }
+func (p *fakePlatform) InvokeClusterLeaderChangedListeners()  { p.leaderChanges.Add(1) }
+func (p *fakePlatform) TotalWebsocketConnections() int        { return 3 }
+func (p *fakePlatform) TotalMasterDbConnections() int         { return 2 }
+func (p *fakePlatform) TotalReadDbConnections() int           { return 1 }
+func (p *fakePlatform) GetLogsSkipSend(_ request.CTX, _, _ int, _ *model.LogFilter) ([]string, *model.AppError) {
+	return []string{"line"}, nil
+}
+func (p *fakePlatform) GenerateSupportPacket(_ request.CTX, _ *model.SupportPacketOptions) ([]model.FileData, error) {
+	return []model.FileData{{Filename: "mattermost.log", Body: []byte("log")}}, nil
+}
+func (p *fakePlatform) GetPluginStatuses() (model.PluginStatuses, *model.AppError) {
+	return model.PluginStatuses{{PluginId: "plugin"}}, nil
+}
+func (p *fakePlatform) WebConnCountForUser(_ string) int { return p.webConnCount }
+func (p *fakePlatform) GetWSQueues(_, _ string, _ int64) (*model.WSQueues, error) {
+	return &model.WSQueues{ReuseCount: 1}, nil
+}
+func (p *fakePlatform) ReloadConfig() error {
+	p.reloadedConfig.Store(true)
+	return nil
+}
+func (p *fakePlatform) DescribeConfig() string   { return "postgres://mmuser@localhost/mattermost" }
+func (p *fakePlatform) ClientConfigHash() string { return "hash" }
+func (p *fakePlatform) DatabaseTypeAndSchemaVersion() (string, string, error) {
+	return "postgres", "150", nil
+}
+
+func newTestClient(t *testing.T) (rueidis.Client, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+
+	// miniredis doesn't run commands on the connection subscribed with RESP3
+	client, err := rueidis.NewClient(rueidis.ClientOption{
+		InitAddress:  []string{server.Addr()},
+		DisableCache: true,
+		AlwaysRESP2:  true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	return client, server
+}
+
+func newTestPlatform(t *testing.T, prefix, hostname string) *fakePlatform {
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	*cfg.CacheSettings.RedisCachePrefix = prefix
+	*cfg.ClusterSettings.OverrideHostname = hostname
+
+	return &fakePlatform{cfg: cfg, logger: mlog.CreateConsoleTestLogger(t)}
+}
+
+func newTestNode(t *testing.T, client rueidis.Client, prefix, hostname string) (*RedisCluster, *fakePlatform) {
+	ps := newTestPlatform(t, prefix, hostname)
+	node := New(ps, client)
+	node.StartInterNodeCommunication()
+	t.Cleanup(node.StopInterNodeCommunication)
+
+	return node, ps
+}
+
+func TestRedisCluster(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	setup := func(t *testing.T) (*RedisCluster, *fakePlatform, *RedisCluster, *fakePlatform) {
+		prefix := model.NewId() + ":"
+		node1, ps1 := newTestNode(t, client, prefix, "node1")
+		node2, ps2 := newTestNode(t, client, prefix, "node2")
+
+		// Wait for both nodes to be subscribed and registered
+		require.Eventually(t, func() bool {
+			count1, err1 := node1.otherNodeCount(context.Background())
+			count2, err2 := node2.otherNodeCount(context.Background())
+			return err1 == nil && err2 == nil && count1 == 1 && count2 == 1
+		}, 5*time.Second, 50*time.Millisecond)
+
+		return node1, ps1, node2, ps2
+	}
+
+	t.Run("delivers broadcasts and direct messages to the other nodes", func(t *testing.T) {
+		node1, _, node2, _ := setup(t)
+
+		received := make(chan *model.ClusterMessage, 2)
+		node2.RegisterClusterMessageHandler(model.ClusterEventInvalidateAllCaches, func(msg *model.ClusterMessage) {
+			received <- msg
+		})
+		node1.RegisterClusterMessageHandler(model.ClusterEventInvalidateAllCaches, func(msg *model.ClusterMessage) {
+			assert.Fail(t, "the sender shouldn't receive its own broadcast")
+		})
+
+		node1.SendClusterMessage(&model.ClusterMessage{Event: model.ClusterEventInvalidateAllCaches, Data: []byte("broadcast")})
+		require.NoError(t, node1.SendClusterMessageToNode(node2.GetClusterId(), &model.ClusterMessage{Event: model.ClusterEventInvalidateAllCaches, Data: []byte("direct")}))
+
+		for _, expected := range []string{"broadcast", "direct"} {
+			select {
+			case msg := <-received:
+				assert.Equal(t, expected, string(msg.Data))
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "timed out waiting for the message")
+			}
+		}
+	})
+
+	t.Run("elects a single leader and fails over", func(t *testing.T) {
+		node1, ps1, node2, ps2 := setup(t)
+
+		require.Eventually(t, func() bool {
+			return node1.IsLeader() != node2.IsLeader()
+		}, 5*time.Second, 50*time.Millisecond)
+
+		leader, follower, followerPs := node1, node2, ps2
+		if node2.IsLeader() {
+			leader, follower, followerPs = node2, node1, ps1
+		}
+
+		leader.StopInterNodeCommunication()
+		require.Eventually(t, follower.IsLeader, 2*leaseRenewInterval, 100*time.Millisecond)
+		assert.Equal(t, int32(1), followerPs.leaderChanges.Load())
+	})
+
+	t.Run("gathers the information of the other nodes", func(t *testing.T) {
+		node1, _, node2, ps2 := setup(t)
+		ps2.webConnCount = 2
+		rctx := request.EmptyContext(mlog.CreateConsoleTestLogger(t))
+
+		infos, err := node1.GetClusterInfos()
+		require.NoError(t, err)
+		require.Len(t, infos, 2)
+		assert.ElementsMatch(t, []string{"node1", "node2"}, []string{infos[0].Hostname, infos[1].Hostname})
+
+		stats, appErr := node1.GetClusterStats(rctx)
+		require.Nil(t, appErr)
+		require.Len(t, stats, 1)
+		assert.Equal(t, &model.ClusterStats{Id: node2.GetClusterId(), TotalWebsocketConnections: 3, TotalReadDbConnections: 1, TotalMasterDbConnections: 2}, stats[0])
+
+		logs, appErr := node1.QueryLogs(rctx, 0, 10)
+		require.Nil(t, appErr)
+		assert.Equal(t, map[string][]string{"node2": {"line"}}, logs)
+
+		files, err := node1.GenerateSupportPacket(rctx, &model.SupportPacketOptions{})
+		require.NoError(t, err)
+		require.Len(t, files["node2"], 1)
+		assert.Equal(t, "node2/mattermost.log", files["node2"][0].Filename)
+
+		statuses, appErr := node1.GetPluginStatuses()
+		require.Nil(t, appErr)
+		require.Len(t, statuses, 1)
+
+		count, appErr := node1.WebConnCountForUser(model.NewId())
+		require.Nil(t, appErr)
+		assert.Equal(t, 2, count)
+
+		queues, err := node1.GetWSQueues(model.NewId(), model.NewId(), 1)
+		require.NoError(t, err)
+		require.Contains(t, queues, node2.GetClusterId())
+		assert.Equal(t, 1, queues[node2.GetClusterId()].ReuseCount)
+	})
+
+	t.Run("tells the other nodes to reload the configuration without sending it", func(t *testing.T) {
+		node1, _, _, ps2 := setup(t)
+
+		// Listen to the broadcasts like another node would
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		sent := make(chan envelope, 100)
+		go func() {
+			_ = client.Receive(ctx, client.B().Subscribe().Channel(node1.broadcastChannel()).Build(), func(msg rueidis.PubSubMessage) {
+				var env envelope
+				if json.Unmarshal([]byte(msg.Message), &env) == nil {
+					sent <- env
+				}
+			})
+		}()
+
+		// Messages without content are ignored by the nodes
+		ping, err := json.Marshal(&envelope{From: "listener"})
+		require.NoError(t, err)
+		require.Eventually(t, func() bool {
+			if client.Do(ctx, client.B().Publish().Channel(node1.broadcastChannel()).Message(string(ping)).Build()).Error() != nil {
+				return false
+			}
+			select {
+			case <-sent:
+				return true
+			case <-time.After(10 * time.Millisecond):
+				return false
+			}
+		}, 5*time.Second, 50*time.Millisecond)
+
+		appErr := node1.ConfigChanged(node1.ps.Config(), node1.ps.Config(), true)
+		require.Nil(t, appErr)
+		require.Eventually(t, ps2.reloadedConfig.Load, 5*time.Second, 50*time.Millisecond)
+
+		for {
+			select {
+			case env := <-sent:
+				if env.Message == nil {
+					continue
+				}
+				assert.Equal(t, model.ClusterEvent(model.ClusterGossipEventRequestSaveConfig), env.Message.Event)
+				assert.Empty(t, env.Message.Data)
+				return
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "timed out waiting for the notice")
+			}
+		}
+	})
+
+	t.Run("stops counting the nodes which left", func(t *testing.T) {
+		node1, _, node2, _ := setup(t)
+
+		node2.StopInterNodeCommunication()
+		count, err := node1.otherNodeCount(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+	})
+
+	t.Run("ignores the expired registrations", func(t *testing.T) {
+		node1, _, _, _ := setup(t)
+
+		expired := float64(time.Now().Add(-time.Second).UnixMilli())
+		require.NoError(t, client.Do(context.Background(), client.B().Zadd().Key(node1.nodesKey()).ScoreMember().ScoreMember(expired, model.NewId()).Build()).Error())
+
+		count, err := node1.otherNodeCount(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
+
+func TestTryLease(t *testing.T) {
+	client, server := newTestClient(t)
+	node := New(newTestPlatform(t, model.NewId()+":", "node"), client)
+	ctx := context.Background()
+
+	require.True(t, node.tryLease(ctx), "the free lease is acquired")
+	node.setLeader(true)
+
+	t.Run("keeps the lease while Redis is unreachable", func(t *testing.T) {
+		server.SetError("ERR unavailable")
+		defer server.SetError("")
+
+		assert.True(t, node.tryLease(ctx))
+	})
+
+	t.Run("steps down when the lease could expire before the next renewal", func(t *testing.T) {
+		server.SetError("ERR unavailable")
+		defer server.SetError("")
+
+		expiresAt := node.leaseExpiresAt
+		defer func() { node.leaseExpiresAt = expiresAt }()
+		node.leaseExpiresAt = time.Now().Add(leaseRenewInterval / 2)
+
+		assert.False(t, node.tryLease(ctx))
+	})
+
+	t.Run("renews the lease it holds", func(t *testing.T) {
+		assert.True(t, node.tryLease(ctx))
+		holder, err := server.Get(node.leaseKey())
+		require.NoError(t, err)
+		assert.Equal(t, node.id, holder)
+	})
+
+	t.Run("steps down when another node holds the lease", func(t *testing.T) {
+		require.NoError(t, server.Set(node.leaseKey(), model.NewId()))
+
+		assert.False(t, node.tryLease(ctx))
+	})
+}
+
+func TestDispatch(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	// notify delivers msg to node as if it was sent by another node
+	notify := func(t *testing.T, node *RedisCluster, msg *model.ClusterMessage) {
+		buf, err := json.Marshal(&envelope{From: model.NewId(), Message: msg})
+		require.NoError(t, err)
+		node.NotifyMsg(buf)
+	}
+
+	t.Run("a slow handler doesn't delay the events of other workers", func(t *testing.T) {
+		node, _ := newTestNode(t, client, model.NewId()+":", "node")
+
+		slow := model.ClusterEventInvalidateAllCaches
+		var fast model.ClusterEvent
+		for _, event := range []model.ClusterEvent{model.ClusterEventPublish, model.ClusterEventUpdateStatus, model.ClusterEventInvalidateCacheForUser, model.ClusterEventClearSessionCacheForUser} {
+			if node.queueIndex(event) != node.queueIndex(slow) {
+				fast = event
+				break
+			}
+		}
+		require.NotEmpty(t, fast)
+
+		release := make(chan struct{})
+		defer close(release)
+		node.RegisterClusterMessageHandler(slow, func(msg *model.ClusterMessage) {
+			<-release
+		})
+		handled := make(chan struct{}, 1)
+		node.RegisterClusterMessageHandler(fast, func(msg *model.ClusterMessage) {
+			handled <- struct{}{}
+		})
+
+		notify(t, node, &model.ClusterMessage{Event: slow})
+		notify(t, node, &model.ClusterMessage{Event: fast})
+
+		select {
+		case <-handled:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "the message waited for the slow handler")
+		}
+	})
+
+	t.Run("messages of an event are handled in order", func(t *testing.T) {
+		node, _ := newTestNode(t, client, model.NewId()+":", "node")
+
+		handled := make(chan string, 100)
+		node.RegisterClusterMessageHandler(model.ClusterEventPublish, func(msg *model.ClusterMessage) {
+			handled <- string(msg.Data)
+		})
+
+		for i := range 100 {
+			notify(t, node, &model.ClusterMessage{Event: model.ClusterEventPublish, Data: []byte(strconv.Itoa(i))})
+		}
+		for i := range 100 {
+			select {
+			case data := <-handled:
+				require.Equal(t, strconv.Itoa(i), data)
+			case <-time.After(5 * time.Second):
+				require.Fail(t, "timed out waiting for the message")
+			}
+		}
+	})
+
+	t.Run("reports the dropped messages", func(t *testing.T) {
+		// Without starting the node, nothing empties the queues
+		ps := newTestPlatform(t, model.NewId()+":", "node")
+		metrics := &mocks.MetricsInterface{}
+		metrics.On("IncrementClusterEventDropped", model.ClusterEventPublish).Once()
+		ps.metrics = metrics
+		node := New(ps, client)
+
+		for range inboundQueueSize + 1 {
+			notify(t, node, &model.ClusterMessage{Event: model.ClusterEventPublish})
+		}
+
+		assert.Equal(t, int64(1), node.dropped.Load())
+		metrics.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,464 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	gossipRequestPrefix  = "gossip_request_"
+	gossipResponsePrefix = "gossip_response_"
+
+	// propRequestId links a response to its request
+	propRequestId = "request_id"
+
+	// propNodeId identifies the node which sent a response
+	propNodeId = "node_id"
+
+	// propError carries the error the node met while answering a request
+	propError = "error"
+
+	// requestTimeout is how long a request waits for the other nodes to reply
+	requestTimeout = 10 * time.Second
+
+	// wsRequestTimeout is shorter since websocket clients wait for the reply to reconnect
+	wsRequestTimeout = 2 * time.Second
+
+	// supportPacketTimeout leaves the nodes time to collect their profiles
+	supportPacketTimeout = 2 * time.Minute
+)
+
+// gossipResponses maps each request to the event of its responses
+var gossipResponses = map[model.ClusterEvent]model.ClusterEvent{
+	model.ClusterGossipEventRequestGetClusterInfo:        model.ClusterGossipEventResponseGetClusterInfo,
+	model.ClusterGossipEventRequestGetClusterStats:       model.ClusterGossipEventResponseGetClusterStats,
+	model.ClusterGossipEventRequestGetLogs:               model.ClusterGossipEventResponseGetLogs,
+	model.ClusterGossipEventRequestGenerateSupportPacket: model.ClusterGossipEventResponseGenerateSupportPacket,
+	model.ClusterGossipEventRequestGetPluginStatuses:     model.ClusterGossipEventResponseGetPluginStatuses,
+	model.ClusterGossipEventRequestWebConnCount:          model.ClusterGossipEventResponseWebConnCount,
+	model.ClusterGossipEventRequestWSQueues:              model.ClusterGossipEventResponseWSQueues,
+	model.ClusterGossipEventRequestSaveConfig:            model.ClusterGossipEventResponseSaveConfig,
+}
+
+type logsRequest struct {
+	Page    int `json:"page"`
+	PerPage int `json:"per_page"`
+}
+
+type logsResponse struct {
+	Hostname string   `json:"hostname"`
+	Lines    []string `json:"lines"`
+}
+
+type supportPacketResponse struct {
+	Hostname string           `json:"hostname"`
+	Files    []model.FileData `json:"files"`
+}
+
+type webConnCountRequest struct {
+	UserId string `json:"user_id"`
+}
+
+type wsQueuesRequest struct {
+	UserId       string `json:"user_id"`
+	ConnectionId string `json:"connection_id"`
+	SeqNum       int64  `json:"seq_num"`
+}
+
+// errIncomplete is returned along with the responses received when some nodes didn't reply in time
+var errIncomplete = errors.New("not all cluster nodes replied in time")
+
+// request broadcasts a gossip request and returns the responses of the other nodes. If some nodes
+// don't reply before the timeout, the responses received are returned along with errIncomplete.
+func (c *RedisCluster) request(ctx context.Context, event model.ClusterEvent, payload any, timeout time.Duration) ([]*model.ClusterMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	expected, err := c.otherNodeCount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if expected == 0 {
+		return nil, nil
+	}
+
+	var data []byte
+	if payload != nil {
+		if data, err = json.Marshal(payload); err != nil {
+			return nil, errors.Wrap(err, "failed to encode cluster request")
+		}
+	}
+
+	requestId := model.NewId()
+	responses := make(chan *model.ClusterMessage, expected)
+
+	c.pendingMut.Lock()
+	c.pending[requestId] = responses
+	c.pendingMut.Unlock()
+	defer func() {
+		c.pendingMut.Lock()
+		delete(c.pending, requestId)
+		c.pendingMut.Unlock()
+	}()
+
+	msg := &model.ClusterMessage{
+		Event:    event,
+		SendType: model.ClusterSendReliable,
+		Data:     data,
+		Props:    map[string]string{propRequestId: requestId},
+	}
+	if err = c.publish(c.broadcastChannel(), msg); err != nil {
+		return nil, errors.Wrap(err, "failed to send cluster request")
+	}
+
+	received := make([]*model.ClusterMessage, 0, expected)
+	for len(received) < expected {
+		select {
+		case response := <-responses:
+			received = append(received, response)
+		case <-ctx.Done():
+			c.ps.Log().Warn("Cluster request timed out",
+				mlog.String("event", string(event)),
+				mlog.Int("expected", expected),
+				mlog.Int("received", len(received)),
+			)
+			return received, errIncomplete
+		}
+	}
+
+	return received, nil
+}
+
+// deliverResponse passes a response to the request waiting for it, if any
+func (c *RedisCluster) deliverResponse(msg *model.ClusterMessage) {
+	c.pendingMut.Lock()
+	responses, ok := c.pending[msg.Props[propRequestId]]
+	c.pendingMut.Unlock()
+	if !ok {
+		return
+	}
+
+	// A node joining during the request may reply beyond the expected count
+	select {
+	case responses <- msg:
+	default:
+	}
+}
+
+// handleRequest answers a gossip request from another node
+func (c *RedisCluster) handleRequest(from string, msg *model.ClusterMessage) {
+	rctx := request.EmptyContext(c.ps.Log())
+
+	var result any
+	var err error
+	switch msg.Event {
+	case model.ClusterGossipEventRequestGetClusterInfo:
+		result = c.GetMyClusterInfo()
+	case model.ClusterGossipEventRequestGetClusterStats:
+		result = &model.ClusterStats{
+			Id:                        c.id,
+			TotalWebsocketConnections: c.ps.TotalWebsocketConnections(),
+			TotalReadDbConnections:    c.ps.TotalReadDbConnections(),
+			TotalMasterDbConnections:  c.ps.TotalMasterDbConnections(),
+		}
+	case model.ClusterGossipEventRequestGetLogs:
+		var req logsRequest
+		if err = json.Unmarshal(msg.Data, &req); err != nil {
+			break
+		}
+		lines, appErr := c.ps.GetLogsSkipSend(rctx, req.Page, req.PerPage, &model.LogFilter{})
+		if appErr != nil {
+			err = appErr
+			break
+		}
+		result = &logsResponse{Hostname: c.hostname, Lines: lines}
+	case model.ClusterGossipEventRequestGenerateSupportPacket:
+		var options model.SupportPacketOptions
+		if err = json.Unmarshal(msg.Data, &options); err != nil {
+			break
+		}
+		var files []model.FileData
+		if files, err = c.ps.GenerateSupportPacket(rctx, &options); err != nil {
+			break
+		}
+		result = &supportPacketResponse{Hostname: c.hostname, Files: files}
+	case model.ClusterGossipEventRequestGetPluginStatuses:
+		statuses, appErr := c.ps.GetPluginStatuses()
+		if appErr != nil {
+			err = appErr
+			break
+		}
+		result = statuses
+	case model.ClusterGossipEventRequestWebConnCount:
+		var req webConnCountRequest
+		if err = json.Unmarshal(msg.Data, &req); err != nil {
+			break
+		}
+		result = c.ps.WebConnCountForUser(req.UserId)
+	case model.ClusterGossipEventRequestWSQueues:
+		var req wsQueuesRequest
+		if err = json.Unmarshal(msg.Data, &req); err != nil {
+			break
+		}
+		result, err = c.ps.GetWSQueues(req.UserId, req.ConnectionId, req.SeqNum)
+	case model.ClusterGossipEventRequestSaveConfig:
+		// Nobody waits for a response
+		if err = c.ps.ReloadConfig(); err != nil {
+			c.ps.Log().Error("Failed to reload the configuration saved by another node", mlog.Err(err))
+		}
+		return
+	default:
+		c.ps.Log().Warn("Unknown cluster request", mlog.String("event", string(msg.Event)))
+		return
+	}
+
+	response := &model.ClusterMessage{
+		Event:    gossipResponses[msg.Event],
+		SendType: model.ClusterSendReliable,
+		Props: map[string]string{
+			propRequestId: msg.Props[propRequestId],
+			propNodeId:    c.id,
+		},
+	}
+	if err == nil {
+		response.Data, err = json.Marshal(result)
+	}
+	if err != nil {
+		c.ps.Log().Warn("Failed to answer cluster request", mlog.String("event", string(msg.Event)), mlog.Err(err))
+		response.Props[propError] = err.Error()
+	}
+
+	if err := c.SendClusterMessageToNode(from, response); err != nil {
+		c.ps.Log().Warn("Failed to send cluster response", mlog.String("event", string(msg.Event)), mlog.Err(err))
+	}
+}
+
+// decodeResponses decodes the data of each successful response with decode, logging the failed ones
+func (c *RedisCluster) decodeResponses(responses []*model.ClusterMessage, decode func(data []byte) error) {
+	for _, response := range responses {
+		if errMsg, ok := response.Props[propError]; ok {
+			c.ps.Log().Warn("Cluster node failed to answer request", mlog.String("event", string(response.Event)), mlog.String("error", errMsg))
+			continue
+		}
+		if err := decode(response.Data); err != nil {
+			c.ps.Log().Warn("Failed to decode cluster response", mlog.String("event", string(response.Event)), mlog.Err(err))
+		}
+	}
+}
+
+// appError converts the error of a request into an AppError
+func appError(where string, err error) *model.AppError {
+	if errors.Is(err, errIncomplete) {
+		return model.NewAppError(where, "ent.cluster.timeout.error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return model.NewAppError(where, "ent.cluster.request.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+}
+
+// GetClusterInfos returns the information of every node in the cluster, the current one included
+func (c *RedisCluster) GetClusterInfos() ([]*model.ClusterInfo, error) {
+	infos := []*model.ClusterInfo{c.GetMyClusterInfo()}
+
+	responses, err := c.request(context.Background(), model.ClusterGossipEventRequestGetClusterInfo, nil, requestTimeout)
+	if err != nil && !errors.Is(err, errIncomplete) {
+		return nil, err
+	}
+
+	c.decodeResponses(responses, func(data []byte) error {
+		var info model.ClusterInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			return err
+		}
+		infos = append(infos, &info)
+		return nil
+	})
+
+	return infos, nil
+}
+
+// GetClusterStats returns the stats of the other nodes
+func (c *RedisCluster) GetClusterStats(rctx request.CTX) ([]*model.ClusterStats, *model.AppError) {
+	responses, err := c.request(rctx.Context(), model.ClusterGossipEventRequestGetClusterStats, nil, requestTimeout)
+	if err != nil && !errors.Is(err, errIncomplete) {
+		return nil, appError("GetClusterStats", err)
+	}
+
+	stats := make([]*model.ClusterStats, 0, len(responses))
+	c.decodeResponses(responses, func(data []byte) error {
+		var stat model.ClusterStats
+		if err := json.Unmarshal(data, &stat); err != nil {
+			return err
+		}
+		stats = append(stats, &stat)
+		return nil
+	})
+
+	return stats, nil
+}
+
+// queryLogs returns the logs of each other node by hostname
+func (c *RedisCluster) queryLogs(rctx request.CTX, page, perPage int) (map[string][]string, *model.AppError) {
+	responses, err := c.request(rctx.Context(), model.ClusterGossipEventRequestGetLogs, &logsRequest{Page: page, PerPage: perPage}, requestTimeout)
+	if err != nil && !errors.Is(err, errIncomplete) {
+		return nil, appError("QueryLogs", err)
+	}
+
+	logs := make(map[string][]string, len(responses))
+	c.decodeResponses(responses, func(data []byte) error {
+		var res logsResponse
+		if err := json.Unmarshal(data, &res); err != nil {
+			return err
+		}
+		logs[res.Hostname] = res.Lines
+		return nil
+	})
+
+	return logs, nil
+}
+
+// GetLogs returns the logs of the other nodes, each preceded by the node's hostname
+func (c *RedisCluster) GetLogs(rctx request.CTX, page, perPage int) ([]string, *model.AppError) {
+	logs, appErr := c.queryLogs(rctx, page, perPage)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	var lines []string
+	for hostname, nodeLines := range logs {
+		lines = append(lines,
+			"-----------------------------------------------------------------------------------------------------------",
+			"-----------------------------------------------------------------------------------------------------------",
+			hostname,
+			"-----------------------------------------------------------------------------------------------------------",
+			"-----------------------------------------------------------------------------------------------------------",
+		)
+		lines = append(lines, nodeLines...)
+	}
+
+	return lines, nil
+}
+
+// QueryLogs returns the logs of each other node by hostname
+func (c *RedisCluster) QueryLogs(rctx request.CTX, page, perPage int) (map[string][]string, *model.AppError) {
+	return c.queryLogs(rctx, page, perPage)
+}
+
+// GenerateSupportPacket returns the support packet files of each other node by hostname. The files
+// are placed in a directory named after the node.
+func (c *RedisCluster) GenerateSupportPacket(rctx request.CTX, options *model.SupportPacketOptions) (map[string][]model.FileData, error) {
+	responses, err := c.request(rctx.Context(), model.ClusterGossipEventRequestGenerateSupportPacket, options, supportPacketTimeout)
+	if err != nil && !errors.Is(err, errIncomplete) {
+		return nil, err
+	}
+
+	files := make(map[string][]model.FileData, len(responses))
+	c.decodeResponses(responses, func(data []byte) error {
+		var res supportPacketResponse
+		if err := json.Unmarshal(data, &res); err != nil {
+			return err
+		}
+		for _, file := range res.Files {
+			files[res.Hostname] = append(files[res.Hostname], model.FileData{
+				Filename: filepath.Join(res.Hostname, file.Filename),
+				Body:     file.Body,
+			})
+		}
+		return nil
+	})
+
+	return files, err
+}
+
+// GetPluginStatuses returns the statuses of the plugins on the other nodes
+func (c *RedisCluster) GetPluginStatuses() (model.PluginStatuses, *model.AppError) {
+	responses, err := c.request(context.Background(), model.ClusterGossipEventRequestGetPluginStatuses, nil, requestTimeout)
+	if err != nil && !errors.Is(err, errIncomplete) {
+		return nil, appError("GetPluginStatuses", err)
+	}
+
+	var statuses model.PluginStatuses
+	c.decodeResponses(responses, func(data []byte) error {
+		var nodeStatuses model.PluginStatuses
+		if err := json.Unmarshal(data, &nodeStatuses); err != nil {
+			return err
+		}
+		statuses = append(statuses, nodeStatuses...)
+		return nil
+	})
+
+	return statuses, nil
+}
+
+// ConfigChanged tells the other nodes to reload the saved configuration from the store. The
+// configuration itself isn't sent, since it holds secrets.
+func (c *RedisCluster) ConfigChanged(previousConfig *model.Config, newConfig *model.Config, sendToOtherServer bool) *model.AppError {
+	if !sendToOtherServer {
+		return nil
+	}
+
+	// The saving node doesn't wait for the others to reload it
+	c.SendClusterMessage(&model.ClusterMessage{
+		Event:    model.ClusterGossipEventRequestSaveConfig,
+		SendType: model.ClusterSendReliable,
+		Props:    map[string]string{propRequestId: model.NewId()},
+	})
+
+	return nil
+}
+
+// WebConnCountForUser returns the number of websocket connections of the user on the other nodes.
+// It fails unless every node replied, so that the user isn't set offline by mistake.
+func (c *RedisCluster) WebConnCountForUser(userID string) (int, *model.AppError) {
+	responses, err := c.request(context.Background(), model.ClusterGossipEventRequestWebConnCount, &webConnCountRequest{UserId: userID}, wsRequestTimeout)
+	if err != nil {
+		return 0, appError("WebConnCountForUser", err)
+	}
+
+	count := 0
+	c.decodeResponses(responses, func(data []byte) error {
+		var nodeCount int
+		if err := json.Unmarshal(data, &nodeCount); err != nil {
+			return err
+		}
+		count += nodeCount
+		return nil
+	})
+
+	return count, nil
+}
+
+// GetWSQueues returns the websocket queues of the connection on each other node by cluster id.
+// It fails unless every node replied, since a missing queue could hide lost messages.
+func (c *RedisCluster) GetWSQueues(userID, connectionID string, seqNum int64) (map[string]*model.WSQueues, error) {
+	req := &wsQueuesRequest{UserId: userID, ConnectionId: connectionID, SeqNum: seqNum}
+	responses, err := c.request(context.Background(), model.ClusterGossipEventRequestWSQueues, req, wsRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	queues := make(map[string]*model.WSQueues, len(responses))
+	for _, response := range responses {
+		if errMsg, ok := response.Props[propError]; ok {
+			return nil, errors.New(errMsg)
+		}
+
+		var nodeQueues *model.WSQueues
+		if err := json.Unmarshal(response.Data, &nodeQueues); err != nil {
+			return nil, errors.Wrap(err, "failed to decode websocket queues")
+		}
+		queues[response.Props[propNodeId]] = nodeQueues
+	}
+
+	return queues, nil
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package cluster
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+
+	"github.com/redis/rueidis"
+)
+
+const (
+	// leaseTTL is how long the leader keeps its lease without renewing it
+	leaseTTL = 15 * time.Second
+
+	// leaseRenewInterval is how often nodes renew or try to acquire the lease
+	leaseRenewInterval = 5 * time.Second
+)
+
+var (
+	// renewLeaseScript extends the lease if it's still held by the node
+	renewLeaseScript = rueidis.NewLuaScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	// releaseLeaseScript deletes the lease if it's still held by the node
+	releaseLeaseScript = rueidis.NewLuaScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+func (c *RedisCluster) leaseKey() string {
+	return c.prefix + "leader"
+}
+
+func (c *RedisCluster) IsLeader() bool {
+	return c.isLeader.Load()
+}
+
+// holdLease renews the lease while the node is the leader and tries to acquire it otherwise,
+// until ctx is done
+func (c *RedisCluster) holdLease(ctx context.Context) {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(leaseRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		c.setLeader(c.tryLease(ctx))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tryLease renews or acquires the lease and reports whether the node holds it. Failing to reach
+// Redis doesn't mean the lease was lost, so the leader only steps down when another node holds
+// the lease or when its own could expire before the next renewal.
+func (c *RedisCluster) tryLease(ctx context.Context) bool {
+	start := time.Now()
+	ttl := leaseTTL.Milliseconds()
+
+	if c.IsLeader() {
+		// Stop waiting for Redis once the lease may have expired
+		deadline := start.Add(leaseRenewInterval)
+		if c.leaseExpiresAt.Before(deadline) {
+			deadline = c.leaseExpiresAt
+		}
+		ctx, cancel := context.WithDeadline(ctx, deadline)
+		defer cancel()
+
+		renewed, err := renewLeaseScript.Exec(ctx, c.client, []string{c.leaseKey()}, []string{c.id, strconv.FormatInt(ttl, 10)}).AsInt64()
+		if err != nil {
+			if time.Now().Add(leaseRenewInterval).Before(c.leaseExpiresAt) {
+				c.ps.Log().Warn("Failed to renew the cluster leader lease, retrying", mlog.Duration("remaining", time.Until(c.leaseExpiresAt)), mlog.Err(err))
+				return true
+			}
+			c.ps.Log().Warn("Failed to renew the cluster leader lease before it expired, stepping down", mlog.Err(err))
+			return false
+		}
+		if renewed != 1 {
+			return false
+		}
+		c.leaseExpiresAt = start.Add(leaseTTL)
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, leaseRenewInterval)
+	defer cancel()
+
+	err := c.client.Do(ctx, c.client.B().Set().Key(c.leaseKey()).Value(c.id).Nx().PxMilliseconds(ttl).Build()).Error()
+	if rueidis.IsRedisNil(err) {
+		return false
+	} else if err != nil {
+		c.ps.Log().Warn("Failed to acquire the cluster leader lease", mlog.Err(err))
+		return false
+	}
+	c.leaseExpiresAt = start.Add(leaseTTL)
+	return true
+}
+
+func (c *RedisCluster) setLeader(leader bool) {
+	if c.isLeader.Swap(leader) != leader {
+		c.ps.Log().Info("Cluster leadership changed", mlog.String("cluster_id", c.id), mlog.Bool("leader", leader))
+		c.ps.InvokeClusterLeaderChangedListeners()
+	}
+}
+
+// releaseLease gives up the lease so that another node takes over without waiting for it to expire
+func (c *RedisCluster) releaseLease() {
+	if !c.IsLeader() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	if err := releaseLeaseScript.Exec(ctx, c.client, []string{c.leaseKey()}, []string{c.id}).Error(); err != nil {
+		c.ps.Log().Warn("Failed to release the cluster leader lease", mlog.Err(err))
+	}
+	c.setLeader(false)
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package cluster
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// nodeTTL is how long a node stays registered without refreshing its registration
+	nodeTTL = 3 * leaseRenewInterval
+
+	// probeInterval is how often a node not yet registered checks whether it receives its messages
+	probeInterval = 100 * time.Millisecond
+
+	// eventProbe is sent by a node to itself to find out when its subscription is ready
+	eventProbe model.ClusterEvent = "cluster_probe"
+)
+
+// nodesKey is the sorted set of the registered nodes, scored by the time their registration
+// expires. Unlike PUBSUB NUMSUB, which only counts the subscribers of the Redis Cluster node it's
+// sent to, a single key gives every node the same view of the cluster.
+func (c *RedisCluster) nodesKey() string {
+	return c.prefix + "nodes"
+}
+
+// heartbeat registers the node once it receives its own messages and refreshes its registration
+// until ctx is done
+func (c *RedisCluster) heartbeat(ctx context.Context) {
+	defer c.wg.Done()
+
+	for {
+		wait := leaseRenewInterval
+		if c.subscribed.Load() {
+			c.register(ctx)
+		} else {
+			c.probe()
+			wait = probeInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// probe sends a message to the node itself, marking it as subscribed once received
+func (c *RedisCluster) probe() {
+	if err := c.SendClusterMessageToNode(c.id, &model.ClusterMessage{Event: eventProbe}); err != nil {
+		c.ps.Log().Debug("Failed to probe the cluster subscription", mlog.Err(err))
+	}
+}
+
+// register adds the node to the cluster, or extends its registration, and forgets the nodes whose
+// registration expired
+func (c *RedisCluster) register(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, publishTimeout)
+	defer cancel()
+
+	now := time.Now()
+	cmds := c.client.DoMulti(ctx,
+		c.client.B().Zremrangebyscore().Key(c.nodesKey()).Min("-inf").Max(strconv.FormatInt(now.UnixMilli(), 10)).Build(),
+		c.client.B().Zadd().Key(c.nodesKey()).ScoreMember().ScoreMember(float64(now.Add(nodeTTL).UnixMilli()), c.id).Build(),
+		c.client.B().Pexpire().Key(c.nodesKey()).Milliseconds(nodeTTL.Milliseconds()).Build(),
+	)
+	for _, cmd := range cmds {
+		if err := cmd.Error(); err != nil {
+			c.ps.Log().Warn("Failed to register the cluster node", mlog.Err(err))
+			return
+		}
+	}
+}
+
+// deregister removes the node from the cluster, so that the other nodes stop waiting for its replies
+func (c *RedisCluster) deregister() {
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeout)
+	defer cancel()
+
+	if err := c.client.Do(ctx, c.client.B().Zrem().Key(c.nodesKey()).Member(c.id).Build()).Error(); err != nil {
+		c.ps.Log().Warn("Failed to deregister the cluster node", mlog.Err(err))
+	}
+}
+
+// otherNodeCount returns the number of other nodes registered in the cluster
+func (c *RedisCluster) otherNodeCount(ctx context.Context) (int, error) {
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	nodes, err := c.client.Do(ctx, c.client.B().Zrangebyscore().Key(c.nodesKey()).Min("("+now).Max("+inf").Build()).AsStrSlice()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to count cluster nodes")
+	}
+
+	count := 0
+	for _, node := range nodes {
+		if node != c.id {
+			count++
+		}
+	}
+	return count, nil
+}
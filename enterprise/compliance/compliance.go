@@ -0,0 +1,217 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package compliance implements einterfaces.ComplianceInterface. A compliance report is a zipped
+// CSV of the posts matching its date range, keywords and emails, written to
+// ComplianceSettings.Directory where the API serves it from. A daily report of the previous day
+// is created every night when ComplianceSettings.EnableDaily is set.
+package compliance
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+// reportFileName is the name of the CSV in the zip of a report
+const reportFileName = "posts.csv"
+
+func init() {
+	app.RegisterComplianceInterface(func(a *app.App) einterfaces.ComplianceInterface {
+		return New(a)
+	})
+}
+
+type Compliance struct {
+	app *app.App
+}
+
+func New(a *app.App) *Compliance {
+	return &Compliance{app: a}
+}
+
+// reportPath is where the API reads the report from, relative to ComplianceSettings.Directory
+func reportPath(job *model.Compliance) string {
+	return "compliance/" + job.JobName() + ".zip"
+}
+
+func (c *Compliance) isEnabled() bool {
+	license := c.app.Srv().License()
+	return *c.app.Config().ComplianceSettings.Enable && license != nil && *license.Features.Compliance
+}
+
+func (c *Compliance) RunComplianceJob(rctx request.CTX, job *model.Compliance) *model.AppError {
+	settings := c.app.Config().ComplianceSettings
+
+	backend, err := filestore.NewFileBackend(filestore.FileBackendSettings{
+		DriverName: model.ImageDriverLocal,
+		Directory:  *settings.Directory,
+	})
+	if err != nil {
+		return model.NewAppError("RunComplianceJob", "ent.compliance.run_failed.error", map[string]any{"JobName": job.JobName(), "FilePath": *settings.Directory}, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return runReport(rctx, c.app.Srv().Store().Compliance(), backend, job, *settings.BatchSize)
+}
+
+// runReport exports the report and records its progress in its status
+func runReport(rctx request.CTX, complianceStore store.ComplianceStore, backend filestore.FileBackend, job *model.Compliance, batchSize int) *model.AppError {
+	rctx = rctx.WithLogger(rctx.Logger().With(job.LoggerFields()...))
+	rctx.Logger().Info("Starting compliance export")
+
+	updateStatus := func(status string) {
+		job.Status = status
+		if _, err := complianceStore.Update(job); err != nil {
+			rctx.Logger().Warn("Failed to update the status of the compliance export", mlog.String("status", status), mlog.Err(err))
+		}
+	}
+
+	updateStatus(model.ComplianceStatusRunning)
+
+	count, err := exportReport(complianceStore, backend, job, batchSize)
+	if err != nil {
+		updateStatus(model.ComplianceStatusFailed)
+		return model.NewAppError("RunComplianceJob", "ent.compliance.run_failed.error", map[string]any{"JobName": job.JobName(), "FilePath": reportPath(job)}, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	job.Count = count
+	updateStatus(model.ComplianceStatusFinished)
+	rctx.Logger().Info("Finished compliance export", mlog.Int("count", count))
+
+	return nil
+}
+
+// exportReport streams the zipped CSV of the report to the file backend and returns the number
+// of posts it contains
+func exportReport(complianceStore store.ComplianceStore, backend filestore.FileBackend, job *model.Compliance, batchSize int) (int, error) {
+	type result struct {
+		count int
+		err   error
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan result, 1)
+	go func() {
+		count, err := writeReport(pw, complianceStore, job, batchSize)
+		pw.CloseWithError(err)
+		done <- result{count, err}
+	}()
+
+	_, err := backend.WriteFile(pr, reportPath(job))
+	// Unblocks the writer when the backend failed before reading everything
+	pr.Close()
+
+	res := <-done
+	if res.err != nil {
+		return 0, res.err
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to write the compliance report")
+	}
+
+	return res.count, nil
+}
+
+func writeReport(w io.Writer, complianceStore store.ComplianceStore, job *model.Compliance, batchSize int) (int, error) {
+	zw := zip.NewWriter(w)
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     reportFileName,
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to create the compliance report")
+	}
+
+	cw := csv.NewWriter(f)
+	if err := cw.Write(model.CompliancePostHeader()); err != nil {
+		return 0, errors.Wrap(err, "failed to write the compliance report")
+	}
+
+	count := 0
+	cursor := model.ComplianceExportCursor{}
+	for !cursor.ChannelsQueryCompleted || !cursor.DirectMessagesQueryCompleted {
+		var posts []*model.CompliancePost
+		posts, cursor, err = complianceStore.ComplianceExport(job, cursor, batchSize)
+		if err != nil {
+			return 0, errors.Wrap(err, "failed to get the posts of the compliance report")
+		}
+
+		for _, post := range posts {
+			if err := cw.Write(post.Row()); err != nil {
+				return 0, errors.Wrap(err, "failed to write the compliance report")
+			}
+		}
+		count += len(posts)
+	}
+
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return 0, errors.Wrap(err, "failed to write the compliance report")
+	}
+	if err := zw.Close(); err != nil {
+		return 0, errors.Wrap(err, "failed to write the compliance report")
+	}
+
+	return count, nil
+}
+
+// StartComplianceDailyJob creates the daily report of the previous day every night. It never
+// returns, the server runs it in its own goroutine.
+func (c *Compliance) StartComplianceDailyJob() {
+	for {
+		now := time.Now()
+		time.Sleep(nextDailyRun(now).Sub(now))
+
+		if !c.isEnabled() || !*c.app.Config().ComplianceSettings.EnableDaily || !c.app.IsLeader() {
+			continue
+		}
+		c.runDailyReport(dailyReport(time.Now()))
+	}
+}
+
+// nextDailyRun is the next midnight, when the previous day is over
+func nextDailyRun(now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+}
+
+// dailyReport is the report of the posts of the day before now
+func dailyReport(now time.Time) *model.Compliance {
+	year, month, day := now.Date()
+	end := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	start := end.AddDate(0, 0, -1)
+
+	return &model.Compliance{
+		Desc:    start.Format("2006-01-02"),
+		Type:    model.ComplianceTypeDaily,
+		StartAt: start.UnixMilli(),
+		EndAt:   end.UnixMilli(),
+	}
+}
+
+func (c *Compliance) runDailyReport(job *model.Compliance) {
+	logger := c.app.Log()
+
+	job, err := c.app.Srv().Store().Compliance().Save(job)
+	if err != nil {
+		logger.Error("Failed to save the daily compliance report", mlog.Err(err))
+		return
+	}
+
+	if appErr := c.RunComplianceJob(request.EmptyContext(logger), job); appErr != nil {
+		logger.Error("Failed to run the daily compliance report", mlog.Err(appErr))
+	}
+}
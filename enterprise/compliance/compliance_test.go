@@ -0,0 +1,120 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package compliance
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+func newReport() *model.Compliance {
+	job := &model.Compliance{Desc: "audit", Type: model.ComplianceTypeAdhoc, StartAt: 100, EndAt: 1000, Keywords: "secret"}
+	job.PreSave()
+	return job
+}
+
+func readReport(t *testing.T, backend filestore.FileBackend, job *model.Compliance) [][]string {
+	t.Helper()
+
+	data, err := backend.ReadFile(reportPath(job))
+	require.NoError(t, err)
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 1)
+	assert.Equal(t, reportFileName, zr.File[0].Name)
+
+	f, err := zr.File[0].Open()
+	require.NoError(t, err)
+	defer f.Close()
+
+	rows, err := csv.NewReader(f).ReadAll()
+	require.NoError(t, err)
+	return rows
+}
+
+func TestRunReport(t *testing.T) {
+	rctx := request.TestContext(t)
+
+	post := func(id, message string) *model.CompliancePost {
+		return &model.CompliancePost{TeamName: "team", ChannelName: "town-square", UserUsername: "bob", PostId: id, PostCreateAt: 200, PostUpdateAt: 200, PostMessage: message}
+	}
+
+	t.Run("exports every batch", func(t *testing.T) {
+		backend, err := filestore.NewFileBackend(filestore.FileBackendSettings{DriverName: model.ImageDriverLocal, Directory: t.TempDir()})
+		require.NoError(t, err)
+
+		job := newReport()
+		complianceStore := &mocks.ComplianceStore{}
+		var statuses []string
+		complianceStore.On("Update", job).Run(func(args mock.Arguments) {
+			statuses = append(statuses, args.Get(0).(*model.Compliance).Status)
+		}).Return(job, nil)
+
+		firstCursor := model.ComplianceExportCursor{LastChannelsQueryPostCreateAt: 200, LastChannelsQueryPostID: "b"}
+		complianceStore.On("ComplianceExport", job, model.ComplianceExportCursor{}, 2).
+			Return([]*model.CompliancePost{post("a", "a secret"), post("b", "=cmd secret")}, firstCursor, nil)
+		complianceStore.On("ComplianceExport", job, firstCursor, 2).
+			Return([]*model.CompliancePost{post("c", "another secret")}, model.ComplianceExportCursor{ChannelsQueryCompleted: true, DirectMessagesQueryCompleted: true}, nil)
+
+		require.Nil(t, runReport(rctx, complianceStore, backend, job, 2))
+
+		assert.Equal(t, []string{model.ComplianceStatusRunning, model.ComplianceStatusFinished}, statuses)
+		assert.Equal(t, 3, job.Count)
+
+		rows := readReport(t, backend, job)
+		require.Len(t, rows, 4)
+		assert.Equal(t, model.CompliancePostHeader(), rows[0])
+		assert.Equal(t, "a", rows[1][9])
+		assert.Equal(t, "'=cmd secret", rows[2][15])
+		assert.Equal(t, "c", rows[3][9])
+	})
+
+	t.Run("marks the report as failed", func(t *testing.T) {
+		backend, err := filestore.NewFileBackend(filestore.FileBackendSettings{DriverName: model.ImageDriverLocal, Directory: t.TempDir()})
+		require.NoError(t, err)
+
+		job := newReport()
+		complianceStore := &mocks.ComplianceStore{}
+		complianceStore.On("Update", job).Return(job, nil)
+		complianceStore.On("ComplianceExport", job, model.ComplianceExportCursor{}, 2).
+			Return(nil, model.ComplianceExportCursor{}, errors.New("database is down"))
+
+		appErr := runReport(rctx, complianceStore, backend, job, 2)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.compliance.run_failed.error", appErr.Id)
+		assert.Equal(t, model.ComplianceStatusFailed, job.Status)
+		assert.Zero(t, job.Count)
+	})
+}
+
+func TestDailyReport(t *testing.T) {
+	loc := time.FixedZone("test", 2*60*60)
+	now := time.Date(2026, time.March, 1, 0, 0, 5, 0, loc)
+
+	job := dailyReport(now)
+	assert.Equal(t, model.ComplianceTypeDaily, job.Type)
+	assert.Equal(t, "2026-02-28", job.Desc)
+	assert.Equal(t, time.Date(2026, time.February, 28, 0, 0, 0, 0, loc).UnixMilli(), job.StartAt)
+	assert.Equal(t, time.Date(2026, time.March, 1, 0, 0, 0, 0, loc).UnixMilli(), job.EndAt)
+
+	job.PreSave()
+	assert.Nil(t, job.IsValid())
+
+	assert.Equal(t, time.Date(2026, time.March, 2, 0, 0, 0, 0, loc), nextDailyRun(now))
+	assert.Equal(t, time.Date(2027, time.January, 1, 0, 0, 0, 0, loc), nextDailyRun(time.Date(2026, time.December, 31, 23, 59, 0, 0, loc)))
+}
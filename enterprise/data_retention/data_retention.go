@@ -0,0 +1,264 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package data_retention implements einterfaces.DataRetentionInterface on top of the retention
+// policy store, and the data_retention job which permanently deletes the content which outlived
+// the global policy from DataRetentionSettings or the granular policy of its team or channel.
+package data_retention
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+	ejobs "github.com/mattermost/mattermost/server/v8/einterfaces/jobs"
+)
+
+func init() {
+	app.RegisterDataRetentionInterface(func(a *app.App) einterfaces.DataRetentionInterface {
+		return New(a.Srv().Store(), a.Config)
+	})
+	app.RegisterJobsDataRetentionJobInterface(func(s *app.Server) ejobs.DataRetentionJobInterface {
+		return &jobInterface{server: s}
+	})
+}
+
+// DataRetention manages the global policy configured in DataRetentionSettings and the granular
+// policies applied to teams and channels
+type DataRetention struct {
+	store  store.Store
+	config func() *model.Config
+}
+
+func New(store store.Store, config func() *model.Config) *DataRetention {
+	return &DataRetention{
+		store:  store,
+		config: config,
+	}
+}
+
+func internalError(where string, err error) *model.AppError {
+	return model.NewAppError(where, "ent.data_retention.policies.internal_error", nil, "", http.StatusInternalServerError).Wrap(err)
+}
+
+func invalidPolicyError(where string, details string) *model.AppError {
+	return model.NewAppError(where, "ent.data_retention.policies.invalid_policy", nil, details, http.StatusBadRequest)
+}
+
+// storeError maps the errors of the retention policy store: the policy being missing is a 404,
+// a team or channel being missing makes the request invalid
+func storeError(where string, err error) *model.AppError {
+	var nfErr *store.ErrNotFound
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return model.NewAppError(where, "ent.data_retention.policies.not_found", nil, "", http.StatusNotFound).Wrap(err)
+	case errors.As(err, &nfErr):
+		return invalidPolicyError(where, nfErr.Error())
+	default:
+		return internalError(where, err)
+	}
+}
+
+// isValidPostDuration reports whether a policy keeps posts forever (-1) or for at least a day
+func isValidPostDuration(days *int64) bool {
+	return days != nil && (*days == -1 || *days >= 1)
+}
+
+func (dr *DataRetention) GetGlobalPolicy() (*model.GlobalRetentionPolicy, *model.AppError) {
+	settings := dr.config().DataRetentionSettings
+	now := time.Now()
+
+	policy := &model.GlobalRetentionPolicy{
+		MessageDeletionEnabled: *settings.EnableMessageDeletion,
+		FileDeletionEnabled:    *settings.EnableFileDeletion,
+	}
+	if policy.MessageDeletionEnabled {
+		policy.MessageRetentionCutoff = now.Add(-time.Duration(settings.GetMessageRetentionHours()) * time.Hour).UnixMilli()
+	}
+	if policy.FileDeletionEnabled {
+		policy.FileRetentionCutoff = now.Add(-time.Duration(settings.GetFileRetentionHours()) * time.Hour).UnixMilli()
+	}
+
+	return policy, nil
+}
+
+func (dr *DataRetention) GetPolicies(offset, limit int) (*model.RetentionPolicyWithTeamAndChannelCountsList, *model.AppError) {
+	policies, err := dr.store.RetentionPolicy().GetAll(offset, limit)
+	if err != nil {
+		return nil, internalError("DataRetention.GetPolicies", err)
+	}
+	count, err := dr.store.RetentionPolicy().GetCount()
+	if err != nil {
+		return nil, internalError("DataRetention.GetPolicies", err)
+	}
+
+	return &model.RetentionPolicyWithTeamAndChannelCountsList{
+		Policies:   policies,
+		TotalCount: count,
+	}, nil
+}
+
+func (dr *DataRetention) GetPoliciesCount() (int64, *model.AppError) {
+	count, err := dr.store.RetentionPolicy().GetCount()
+	if err != nil {
+		return 0, internalError("DataRetention.GetPoliciesCount", err)
+	}
+	return count, nil
+}
+
+func (dr *DataRetention) GetPolicy(policyID string) (*model.RetentionPolicyWithTeamAndChannelCounts, *model.AppError) {
+	policy, err := dr.store.RetentionPolicy().Get(policyID)
+	if err != nil {
+		return nil, storeError("DataRetention.GetPolicy", err)
+	}
+	return policy, nil
+}
+
+func (dr *DataRetention) CreatePolicy(policy *model.RetentionPolicyWithTeamAndChannelIDs) (*model.RetentionPolicyWithTeamAndChannelCounts, *model.AppError) {
+	if policy.DisplayName == "" {
+		return nil, invalidPolicyError("DataRetention.CreatePolicy", "display_name is required")
+	}
+	if !isValidPostDuration(policy.PostDurationDays) {
+		return nil, invalidPolicyError("DataRetention.CreatePolicy", "post_duration must be -1 or at least 1")
+	}
+
+	// The id is generated by the store
+	policy.ID = ""
+	newPolicy, err := dr.store.RetentionPolicy().Save(policy)
+	if err != nil {
+		return nil, storeError("DataRetention.CreatePolicy", err)
+	}
+	return newPolicy, nil
+}
+
+func (dr *DataRetention) PatchPolicy(patch *model.RetentionPolicyWithTeamAndChannelIDs) (*model.RetentionPolicyWithTeamAndChannelCounts, *model.AppError) {
+	if patch.PostDurationDays != nil && !isValidPostDuration(patch.PostDurationDays) {
+		return nil, invalidPolicyError("DataRetention.PatchPolicy", "post_duration must be -1 or at least 1")
+	}
+
+	if _, err := dr.store.RetentionPolicy().Get(patch.ID); err != nil {
+		return nil, storeError("DataRetention.PatchPolicy", err)
+	}
+
+	policy, err := dr.store.RetentionPolicy().Patch(patch)
+	if err != nil {
+		return nil, storeError("DataRetention.PatchPolicy", err)
+	}
+	return policy, nil
+}
+
+func (dr *DataRetention) DeletePolicy(policyID string) *model.AppError {
+	if _, err := dr.store.RetentionPolicy().Get(policyID); err != nil {
+		return storeError("DataRetention.DeletePolicy", err)
+	}
+
+	if err := dr.store.RetentionPolicy().Delete(policyID); err != nil {
+		return internalError("DataRetention.DeletePolicy", err)
+	}
+	return nil
+}
+
+func (dr *DataRetention) GetTeamsForPolicy(policyID string, offset, limit int) (*model.TeamsWithCount, *model.AppError) {
+	teams, err := dr.store.RetentionPolicy().GetTeams(policyID, offset, limit)
+	if err != nil {
+		return nil, internalError("DataRetention.GetTeamsForPolicy", err)
+	}
+	count, err := dr.store.RetentionPolicy().GetTeamsCount(policyID)
+	if err != nil {
+		return nil, internalError("DataRetention.GetTeamsForPolicy", err)
+	}
+
+	return &model.TeamsWithCount{
+		Teams:      teams,
+		TotalCount: count,
+	}, nil
+}
+
+func (dr *DataRetention) AddTeamsToPolicy(policyID string, teamIDs []string) *model.AppError {
+	if _, err := dr.store.RetentionPolicy().Get(policyID); err != nil {
+		return storeError("DataRetention.AddTeamsToPolicy", err)
+	}
+
+	if err := dr.store.RetentionPolicy().AddTeams(policyID, teamIDs); err != nil {
+		return storeError("DataRetention.AddTeamsToPolicy", err)
+	}
+	return nil
+}
+
+func (dr *DataRetention) RemoveTeamsFromPolicy(policyID string, teamIDs []string) *model.AppError {
+	if err := dr.store.RetentionPolicy().RemoveTeams(policyID, teamIDs); err != nil {
+		return internalError("DataRetention.RemoveTeamsFromPolicy", err)
+	}
+	return nil
+}
+
+func (dr *DataRetention) GetChannelsForPolicy(policyID string, offset, limit int) (*model.ChannelsWithCount, *model.AppError) {
+	channels, err := dr.store.RetentionPolicy().GetChannels(policyID, offset, limit)
+	if err != nil {
+		return nil, internalError("DataRetention.GetChannelsForPolicy", err)
+	}
+	count, err := dr.store.RetentionPolicy().GetChannelsCount(policyID)
+	if err != nil {
+		return nil, internalError("DataRetention.GetChannelsForPolicy", err)
+	}
+
+	return &model.ChannelsWithCount{
+		Channels:   channels,
+		TotalCount: count,
+	}, nil
+}
+
+func (dr *DataRetention) AddChannelsToPolicy(policyID string, channelIDs []string) *model.AppError {
+	if _, err := dr.store.RetentionPolicy().Get(policyID); err != nil {
+		return storeError("DataRetention.AddChannelsToPolicy", err)
+	}
+
+	if err := dr.store.RetentionPolicy().AddChannels(policyID, channelIDs); err != nil {
+		return storeError("DataRetention.AddChannelsToPolicy", err)
+	}
+	return nil
+}
+
+func (dr *DataRetention) RemoveChannelsFromPolicy(policyID string, channelIDs []string) *model.AppError {
+	if err := dr.store.RetentionPolicy().RemoveChannels(policyID, channelIDs); err != nil {
+		return internalError("DataRetention.RemoveChannelsFromPolicy", err)
+	}
+	return nil
+}
+
+func (dr *DataRetention) GetTeamPoliciesForUser(userID string, offset, limit int) (*model.RetentionPolicyForTeamList, *model.AppError) {
+	policies, err := dr.store.RetentionPolicy().GetTeamPoliciesForUser(userID, offset, limit)
+	if err != nil {
+		return nil, internalError("DataRetention.GetTeamPoliciesForUser", err)
+	}
+	count, err := dr.store.RetentionPolicy().GetTeamPoliciesCountForUser(userID)
+	if err != nil {
+		return nil, internalError("DataRetention.GetTeamPoliciesForUser", err)
+	}
+
+	return &model.RetentionPolicyForTeamList{
+		Policies:   policies,
+		TotalCount: count,
+	}, nil
+}
+
+func (dr *DataRetention) GetChannelPoliciesForUser(userID string, offset, limit int) (*model.RetentionPolicyForChannelList, *model.AppError) {
+	policies, err := dr.store.RetentionPolicy().GetChannelPoliciesForUser(userID, offset, limit)
+	if err != nil {
+		return nil, internalError("DataRetention.GetChannelPoliciesForUser", err)
+	}
+	count, err := dr.store.RetentionPolicy().GetChannelPoliciesCountForUser(userID)
+	if err != nil {
+		return nil, internalError("DataRetention.GetChannelPoliciesForUser", err)
+	}
+
+	return &model.RetentionPolicyForChannelList{
+		Policies:   policies,
+		TotalCount: count,
+	}, nil
+}
@@ -0,0 +1,142 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package data_retention
+
+import (
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
+)
+
+func setupDataRetention(t *testing.T) (*DataRetention, *mocks.RetentionPolicyStore, *model.Config) {
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+
+	mockStore := &mocks.Store{}
+	mockPolicyStore := &mocks.RetentionPolicyStore{}
+	mockStore.On("RetentionPolicy").Return(mockPolicyStore)
+	t.Cleanup(func() {
+		mockPolicyStore.AssertExpectations(t)
+	})
+
+	return New(mockStore, func() *model.Config { return cfg }), mockPolicyStore, cfg
+}
+
+func TestGetGlobalPolicy(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		dr, _, _ := setupDataRetention(t)
+
+		policy, appErr := dr.GetGlobalPolicy()
+		require.Nil(t, appErr)
+		assert.Equal(t, &model.GlobalRetentionPolicy{}, policy)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		dr, _, cfg := setupDataRetention(t)
+		cfg.DataRetentionSettings.EnableMessageDeletion = model.NewPointer(true)
+		cfg.DataRetentionSettings.MessageRetentionDays = model.NewPointer(730)
+		cfg.DataRetentionSettings.EnableFileDeletion = model.NewPointer(true)
+		cfg.DataRetentionSettings.FileRetentionDays = model.NewPointer(90)
+
+		before := time.Now()
+		policy, appErr := dr.GetGlobalPolicy()
+		require.Nil(t, appErr)
+		assert.True(t, policy.MessageDeletionEnabled)
+		assert.True(t, policy.FileDeletionEnabled)
+		assert.InDelta(t, before.Add(-730*24*time.Hour).UnixMilli(), policy.MessageRetentionCutoff, float64(time.Minute.Milliseconds()))
+		assert.InDelta(t, before.Add(-90*24*time.Hour).UnixMilli(), policy.FileRetentionCutoff, float64(time.Minute.Milliseconds()))
+	})
+}
+
+func TestCreatePolicy(t *testing.T) {
+	t.Run("invalid policies", func(t *testing.T) {
+		dr, _, _ := setupDataRetention(t)
+
+		for name, policy := range map[string]model.RetentionPolicy{
+			"no display name":  {PostDurationDays: model.NewPointer(int64(90))},
+			"no post duration": {DisplayName: "Legal"},
+			"zero days":        {DisplayName: "Legal", PostDurationDays: model.NewPointer(int64(0))},
+			"negative days":    {DisplayName: "Legal", PostDurationDays: model.NewPointer(int64(-2))},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, appErr := dr.CreatePolicy(&model.RetentionPolicyWithTeamAndChannelIDs{RetentionPolicy: policy})
+				require.NotNil(t, appErr)
+				assert.Equal(t, "ent.data_retention.policies.invalid_policy", appErr.Id)
+				assert.Equal(t, http.StatusBadRequest, appErr.StatusCode)
+			})
+		}
+	})
+
+	t.Run("saves the policy", func(t *testing.T) {
+		dr, mockPolicyStore, _ := setupDataRetention(t)
+		policy := &model.RetentionPolicyWithTeamAndChannelIDs{
+			RetentionPolicy: model.RetentionPolicy{DisplayName: "Legal", PostDurationDays: model.NewPointer(int64(730))},
+			TeamIDs:         []string{model.NewId()},
+		}
+		saved := &model.RetentionPolicyWithTeamAndChannelCounts{RetentionPolicy: policy.RetentionPolicy, TeamCount: 1}
+		mockPolicyStore.On("Save", policy).Return(saved, nil)
+
+		newPolicy, appErr := dr.CreatePolicy(policy)
+		require.Nil(t, appErr)
+		assert.Equal(t, saved, newPolicy)
+	})
+
+	t.Run("missing channel", func(t *testing.T) {
+		dr, mockPolicyStore, _ := setupDataRetention(t)
+		channelID := model.NewId()
+		mockPolicyStore.On("Save", mock.Anything).Return(nil, store.NewErrNotFound("Channel", channelID))
+
+		_, appErr := dr.CreatePolicy(&model.RetentionPolicyWithTeamAndChannelIDs{
+			RetentionPolicy: model.RetentionPolicy{DisplayName: "Legal", PostDurationDays: model.NewPointer(int64(90))},
+			ChannelIDs:      []string{channelID},
+		})
+		require.NotNil(t, appErr)
+		assert.Equal(t, http.StatusBadRequest, appErr.StatusCode)
+	})
+}
+
+func TestPolicyNotFound(t *testing.T) {
+	dr, mockPolicyStore, _ := setupDataRetention(t)
+	policyID := model.NewId()
+	mockPolicyStore.On("Get", policyID).Return(nil, sql.ErrNoRows)
+
+	_, appErr := dr.GetPolicy(policyID)
+	require.NotNil(t, appErr)
+	assert.Equal(t, http.StatusNotFound, appErr.StatusCode)
+
+	_, appErr = dr.PatchPolicy(&model.RetentionPolicyWithTeamAndChannelIDs{RetentionPolicy: model.RetentionPolicy{ID: policyID}})
+	require.NotNil(t, appErr)
+	assert.Equal(t, http.StatusNotFound, appErr.StatusCode)
+
+	appErr = dr.DeletePolicy(policyID)
+	require.NotNil(t, appErr)
+	assert.Equal(t, http.StatusNotFound, appErr.StatusCode)
+
+	appErr = dr.AddChannelsToPolicy(policyID, []string{model.NewId()})
+	require.NotNil(t, appErr)
+	assert.Equal(t, http.StatusNotFound, appErr.StatusCode)
+}
+
+func TestGetPolicies(t *testing.T) {
+	dr, mockPolicyStore, _ := setupDataRetention(t)
+	policies := []*model.RetentionPolicyWithTeamAndChannelCounts{
+		{RetentionPolicy: model.RetentionPolicy{ID: model.NewId(), DisplayName: "Legal", PostDurationDays: model.NewPointer(int64(730))}},
+	}
+	mockPolicyStore.On("GetAll", 0, 10).Return(policies, nil)
+	mockPolicyStore.On("GetCount").Return(int64(3), nil)
+
+	list, appErr := dr.GetPolicies(0, 10)
+	require.Nil(t, appErr)
+	assert.Equal(t, policies, list.Policies)
+	assert.Equal(t, int64(3), list.TotalCount)
+}
@@ -0,0 +1,298 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package data_retention
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	ejobs "github.com/mattermost/mattermost/server/v8/einterfaces/jobs"
+	"github.com/mattermost/mattermost/server/v8/platform/services/searchengine"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+const workerName = "DataRetention"
+
+// jobInterface builds the worker and scheduler of the data_retention job
+type jobInterface struct {
+	server *app.Server
+}
+
+func (ji *jobInterface) MakeWorker() model.Worker {
+	return MakeWorker(ji.server.Jobs, ji.server.Store(), ji.server.FileBackend(), ji.server.Platform().SearchEngine)
+}
+
+func (ji *jobInterface) MakeScheduler() ejobs.Scheduler {
+	return MakeScheduler(ji.server.Jobs)
+}
+
+// MakeScheduler runs the job every day at DataRetentionSettings.DeletionJobStartTime. It is always
+// enabled since the granular policies apply whatever the global ones are.
+func MakeScheduler(jobServer *jobs.JobServer) *jobs.DailyScheduler {
+	startTime := func(cfg *model.Config) *time.Time {
+		parsedTime, err := time.Parse("15:04", *cfg.DataRetentionSettings.DeletionJobStartTime)
+		if err == nil {
+			return &parsedTime
+		}
+		return nil
+	}
+	isEnabled := func(cfg *model.Config) bool {
+		return true
+	}
+	return jobs.NewDailyScheduler(jobServer, model.JobTypeDataRetention, startTime, isEnabled)
+}
+
+func MakeWorker(jobServer *jobs.JobServer, store store.Store, fileBackend filestore.FileBackend, searchEngine *searchengine.Broker) *jobs.SimpleWorker {
+	isEnabled := func(cfg *model.Config) bool {
+		return true
+	}
+	execute := func(logger mlog.LoggerIFace, job *model.Job) error {
+		defer jobServer.HandleJobPanic(logger, job)
+
+		if job.Data == nil {
+			job.Data = make(model.StringMap)
+		}
+
+		var engines []searchengine.SearchEngineInterface
+		if searchEngine != nil {
+			engines = searchEngine.GetActiveEngines()
+		}
+
+		d, err := newDeleter(store, fileBackend, engines, request.EmptyContext(logger), jobServer.Config().DataRetentionSettings, time.Now())
+		if err != nil {
+			return err
+		}
+		d.progress = func(key string, count int64) {
+			job.Data[key] = strconv.FormatInt(count, 10)
+			if err := jobServer.UpdateInProgressJobData(job); err != nil {
+				logger.Warn("Failed to update the progress of the job", mlog.Err(err))
+			}
+		}
+		return d.deleteAll()
+	}
+	return jobs.NewSimpleWorker(workerName, jobServer, execute, isEnabled)
+}
+
+// deleter permanently deletes, one batch at a time, the content past the cutoff of its policy
+type deleter struct {
+	store         store.Store
+	fileBackend   filestore.FileBackend
+	engines       []searchengine.SearchEngineInterface
+	rctx          request.CTX
+	settings      model.DataRetentionSettings
+	configs       model.RetentionPolicyBatchConfigs
+	fileCutoff    int64
+	messageCutoff int64
+	sleep         func(time.Duration)
+	progress      func(key string, count int64)
+	counts        map[string]int64
+}
+
+func newDeleter(store store.Store, fileBackend filestore.FileBackend, engines []searchengine.SearchEngineInterface, rctx request.CTX, settings model.DataRetentionSettings, now time.Time) (*deleter, error) {
+	d := &deleter{
+		store:       store,
+		fileBackend: fileBackend,
+		engines:     engines,
+		rctx:        rctx,
+		settings:    settings,
+		configs: model.RetentionPolicyBatchConfigs{
+			Limit:               int64(*settings.BatchSize),
+			PreservePinnedPosts: *settings.PreservePinnedPosts,
+		},
+		sleep:    time.Sleep,
+		progress: func(string, int64) {},
+		counts:   make(map[string]int64),
+	}
+
+	// Granular policies are skipped altogether when there are none
+	count, err := store.RetentionPolicy().GetCount()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to count the retention policies")
+	}
+	if count > 0 {
+		d.configs.Now = now.UnixMilli()
+	}
+
+	if *settings.EnableMessageDeletion {
+		d.messageCutoff = now.Add(-time.Duration(settings.GetMessageRetentionHours()) * time.Hour).UnixMilli()
+		d.configs.GlobalPolicyEndTime = d.messageCutoff
+	}
+	if *settings.EnableFileDeletion {
+		d.fileCutoff = now.Add(-time.Duration(settings.GetFileRetentionHours()) * time.Hour).UnixMilli()
+	}
+
+	return d, nil
+}
+
+func (d *deleter) add(key string, count int64) {
+	d.counts[key] += count
+	d.progress(key, d.counts[key])
+}
+
+func (d *deleter) pause() {
+	d.sleep(time.Duration(*d.settings.TimeBetweenBatchesMilliseconds) * time.Millisecond)
+}
+
+func (d *deleter) deleteAll() error {
+	for _, step := range []struct {
+		name        string
+		deleteBatch func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error)
+	}{
+		{"posts", d.store.Post().PermanentDeleteBatchForRetentionPolicies},
+		{"threads", d.store.Thread().PermanentDeleteBatchForRetentionPolicies},
+		{"thread_memberships", d.store.Thread().PermanentDeleteBatchThreadMembershipsForRetentionPolicies},
+		{"channel_read_cursors", d.store.ChannelReadCursor().PermanentDeleteBatchForRetentionPolicies},
+		{"thread_read_cursors", d.store.ChannelReadCursor().PermanentDeleteBatchThreadCursorsForRetentionPolicies},
+	} {
+		if err := d.deleteForPolicies(step.name, step.deleteBatch); err != nil {
+			return err
+		}
+	}
+
+	if err := d.deletePostsDependencies(); err != nil {
+		return err
+	}
+
+	if err := d.deleteFilesForPolicies(); err != nil {
+		return err
+	}
+
+	if _, err := d.store.RetentionPolicy().DeleteOrphanedRows(int(d.configs.Limit)); err != nil {
+		return errors.Wrap(err, "failed to delete the orphaned retention policy rows")
+	}
+
+	if d.messageCutoff > 0 {
+		for _, engine := range d.engines {
+			if appErr := engine.DataRetentionDeleteIndexes(d.rctx, time.UnixMilli(d.messageCutoff)); appErr != nil {
+				d.rctx.Logger().Warn("Failed to delete the search indexes past the retention period", mlog.String("engine", engine.GetName()), mlog.Err(appErr))
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteForPolicies runs a store deletion one batch at a time until every policy is done with
+func (d *deleter) deleteForPolicies(name string, deleteBatch func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error)) error {
+	cursor := model.RetentionPolicyCursor{}
+	for {
+		deleted, next, err := deleteBatch(d.configs, cursor)
+		if err != nil {
+			return errors.Wrapf(err, "failed to delete a batch of %s", name)
+		}
+		d.add(name+"_deleted", deleted)
+		cursor = next
+		if cursor.ChannelPoliciesDone && cursor.TeamPoliciesDone && cursor.GlobalPoliciesDone {
+			return nil
+		}
+		d.pause()
+	}
+}
+
+// deletePostsDependencies deletes the files and reactions of the posts deleted by the policies,
+// which the post store records in RetentionIdsForDeletion
+func (d *deleter) deletePostsDependencies() error {
+	for {
+		rows, err := d.store.RetentionPolicy().GetIdsForDeletionByTableName("Posts", *d.settings.RetentionIdsBatchSize)
+		if err != nil {
+			return errors.Wrap(err, "failed to get the ids of the deleted posts")
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		for _, row := range rows {
+			files, err := d.store.FileInfo().GetForPostIds(row.Ids)
+			if err != nil {
+				return errors.Wrap(err, "failed to get the files of the deleted posts")
+			}
+			if err := d.deleteFiles(files); err != nil {
+				return err
+			}
+
+			for _, engine := range d.engines {
+				for _, postID := range row.Ids {
+					if appErr := engine.DeletePost(&model.Post{Id: postID}); appErr != nil {
+						d.rctx.Logger().Warn("Failed to remove a deleted post from the search index", mlog.String("engine", engine.GetName()), mlog.String("post_id", postID), mlog.Err(appErr))
+					}
+				}
+			}
+
+			// This also removes the row, so it comes last
+			deleted, err := d.store.Reaction().DeleteOrphanedRowsByIds(row)
+			if err != nil {
+				return errors.Wrap(err, "failed to delete the reactions of the deleted posts")
+			}
+			d.add("reactions_deleted", deleted)
+		}
+		d.pause()
+	}
+}
+
+// deleteFilesForPolicies deletes the files past the retention policy of their post's channel, one
+// batch at a time. The global policy uses the file retention period rather than the message one.
+func (d *deleter) deleteFilesForPolicies() error {
+	configs := d.configs
+	configs.GlobalPolicyEndTime = d.fileCutoff
+
+	cursor := model.RetentionPolicyCursor{}
+	for {
+		files, next, err := d.store.FileInfo().GetBatchForRetentionPolicies(configs, cursor)
+		if err != nil {
+			return errors.Wrap(err, "failed to get a batch of files past the retention period")
+		}
+		if err := d.deleteFiles(files); err != nil {
+			return err
+		}
+		cursor = next
+		if cursor.ChannelPoliciesDone && cursor.TeamPoliciesDone && cursor.GlobalPoliciesDone {
+			return nil
+		}
+		if len(files) > 0 {
+			d.pause()
+		}
+	}
+}
+
+// deleteFiles removes the blobs of the files from the file backend, then their file infos. A blob
+// which can't be removed is logged rather than failing the job, so that it doesn't stop the
+// deletion of the rows.
+func (d *deleter) deleteFiles(files []*model.FileInfo) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	fileIDs := make([]string, len(files))
+	for i, file := range files {
+		fileIDs[i] = file.Id
+		for _, path := range []string{file.Path, file.ThumbnailPath, file.PreviewPath} {
+			if path == "" {
+				continue
+			}
+			if err := d.fileBackend.RemoveFile(path); err != nil {
+				d.rctx.Logger().Warn("Failed to remove a file past the retention period", mlog.String("file_id", file.Id), mlog.String("path", path), mlog.Err(err))
+			}
+		}
+		for _, engine := range d.engines {
+			if appErr := engine.DeleteFile(file.Id); appErr != nil {
+				d.rctx.Logger().Warn("Failed to remove a deleted file from the search index", mlog.String("engine", engine.GetName()), mlog.String("file_id", file.Id), mlog.Err(appErr))
+			}
+		}
+	}
+
+	deleted, err := d.store.FileInfo().PermanentDeleteByIds(d.rctx, fileIDs)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete the file infos")
+	}
+	d.add("files_deleted", deleted)
+	return nil
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package data_retention
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
+	fmocks "github.com/mattermost/mattermost/server/v8/platform/shared/filestore/mocks"
+)
+
+var doneCursor = model.RetentionPolicyCursor{ChannelPoliciesDone: true, TeamPoliciesDone: true, GlobalPoliciesDone: true}
+
+type deleterMocks struct {
+	store       *mocks.Store
+	posts       *mocks.PostStore
+	threads     *mocks.ThreadStore
+	readCursors *mocks.ChannelReadCursorStore
+	policies    *mocks.RetentionPolicyStore
+	files       *mocks.FileInfoStore
+	reactions   *mocks.ReactionStore
+	fileBackend *fmocks.FileBackend
+}
+
+func newDeleterMocks(t *testing.T) *deleterMocks {
+	m := &deleterMocks{
+		store:       &mocks.Store{},
+		posts:       &mocks.PostStore{},
+		threads:     &mocks.ThreadStore{},
+		readCursors: &mocks.ChannelReadCursorStore{},
+		policies:    &mocks.RetentionPolicyStore{},
+		files:       &mocks.FileInfoStore{},
+		reactions:   &mocks.ReactionStore{},
+		fileBackend: &fmocks.FileBackend{},
+	}
+	m.store.On("Post").Return(m.posts)
+	m.store.On("Thread").Return(m.threads)
+	m.store.On("ChannelReadCursor").Return(m.readCursors)
+	m.store.On("RetentionPolicy").Return(m.policies)
+	m.store.On("FileInfo").Return(m.files)
+	m.store.On("Reaction").Return(m.reactions)
+	t.Cleanup(func() {
+		mock.AssertExpectationsForObjects(t, m.posts, m.threads, m.readCursors, m.policies, m.files, m.reactions, m.fileBackend)
+	})
+	return m
+}
+
+// expectNothingForPolicies makes every deletion for policies other than the posts one finish at once
+func (m *deleterMocks) expectNothingForPolicies(configs any) {
+	m.threads.On("PermanentDeleteBatchForRetentionPolicies", configs, mock.Anything).Return(int64(0), doneCursor, nil)
+	m.threads.On("PermanentDeleteBatchThreadMembershipsForRetentionPolicies", configs, mock.Anything).Return(int64(0), doneCursor, nil)
+	m.readCursors.On("PermanentDeleteBatchForRetentionPolicies", configs, mock.Anything).Return(int64(0), doneCursor, nil)
+	m.readCursors.On("PermanentDeleteBatchThreadCursorsForRetentionPolicies", configs, mock.Anything).Return(int64(0), doneCursor, nil)
+}
+
+func defaultSettings() model.DataRetentionSettings {
+	settings := model.DataRetentionSettings{}
+	settings.SetDefaults()
+	return settings
+}
+
+func TestDeleteAll(t *testing.T) {
+	now := time.Now()
+
+	t.Run("granular and global message policies", func(t *testing.T) {
+		m := newDeleterMocks(t)
+		settings := defaultSettings()
+		settings.EnableMessageDeletion = model.NewPointer(true)
+		settings.MessageRetentionDays = model.NewPointer(730)
+		settings.BatchSize = model.NewPointer(2)
+
+		expectedConfigs := model.RetentionPolicyBatchConfigs{
+			Now:                 now.UnixMilli(),
+			GlobalPolicyEndTime: now.Add(-730 * 24 * time.Hour).UnixMilli(),
+			Limit:               2,
+		}
+		m.policies.On("GetCount").Return(int64(2), nil)
+
+		channelsDone := model.RetentionPolicyCursor{ChannelPoliciesDone: true}
+		m.posts.On("PermanentDeleteBatchForRetentionPolicies", expectedConfigs, model.RetentionPolicyCursor{}).Return(int64(2), channelsDone, nil).Once()
+		m.posts.On("PermanentDeleteBatchForRetentionPolicies", expectedConfigs, channelsDone).Return(int64(1), doneCursor, nil).Once()
+		m.expectNothingForPolicies(expectedConfigs)
+
+		postIDs := []string{model.NewId(), model.NewId(), model.NewId()}
+		row := &model.RetentionIdsForDeletion{Id: model.NewId(), TableName: "Posts", Ids: postIDs}
+		m.policies.On("GetIdsForDeletionByTableName", "Posts", 100).Return([]*model.RetentionIdsForDeletion{row}, nil).Once()
+		m.policies.On("GetIdsForDeletionByTableName", "Posts", 100).Return([]*model.RetentionIdsForDeletion{}, nil).Once()
+		file := &model.FileInfo{Id: model.NewId(), PostId: postIDs[0], Path: "data/file.png", ThumbnailPath: "data/file_thumb.jpg"}
+		m.files.On("GetForPostIds", postIDs).Return([]*model.FileInfo{file}, nil)
+		m.fileBackend.On("RemoveFile", "data/file.png").Return(nil)
+		m.fileBackend.On("RemoveFile", "data/file_thumb.jpg").Return(errors.New("already removed"))
+		m.files.On("PermanentDeleteByIds", mock.Anything, []string{file.Id}).Return(int64(1), nil)
+		m.reactions.On("DeleteOrphanedRowsByIds", row).Return(int64(4), nil)
+		// Files follow the granular policies, but there is no global file policy
+		fileConfigs := expectedConfigs
+		fileConfigs.GlobalPolicyEndTime = 0
+		m.files.On("GetBatchForRetentionPolicies", fileConfigs, model.RetentionPolicyCursor{}).Return([]*model.FileInfo{}, doneCursor, nil)
+		m.policies.On("DeleteOrphanedRows", 2).Return(int64(0), nil)
+
+		d, err := newDeleter(m.store, m.fileBackend, nil, request.TestContext(t), settings, now)
+		require.NoError(t, err)
+		var pauses int
+		d.sleep = func(time.Duration) { pauses++ }
+
+		require.NoError(t, d.deleteAll())
+		assert.Equal(t, map[string]int64{
+			"posts_deleted":                3,
+			"threads_deleted":              0,
+			"thread_memberships_deleted":   0,
+			"channel_read_cursors_deleted": 0,
+			"thread_read_cursors_deleted":  0,
+			"files_deleted":                1,
+			"reactions_deleted":            4,
+		}, d.counts)
+		assert.Equal(t, 2, pauses)
+	})
+
+	t.Run("global file policy only", func(t *testing.T) {
+		m := newDeleterMocks(t)
+		settings := defaultSettings()
+		settings.EnableFileDeletion = model.NewPointer(true)
+		settings.FileRetentionHours = model.NewPointer(24)
+		settings.BatchSize = model.NewPointer(2)
+
+		// Neither granular nor global message policies apply
+		expectedConfigs := model.RetentionPolicyBatchConfigs{Limit: 2}
+		m.policies.On("GetCount").Return(int64(0), nil)
+		m.posts.On("PermanentDeleteBatchForRetentionPolicies", expectedConfigs, mock.Anything).Return(int64(0), doneCursor, nil)
+		m.expectNothingForPolicies(expectedConfigs)
+		m.policies.On("GetIdsForDeletionByTableName", "Posts", 100).Return([]*model.RetentionIdsForDeletion{}, nil)
+
+		fileConfigs := model.RetentionPolicyBatchConfigs{GlobalPolicyEndTime: now.Add(-24 * time.Hour).UnixMilli(), Limit: 2}
+		granularDone := model.RetentionPolicyCursor{ChannelPoliciesDone: true, TeamPoliciesDone: true}
+		first := []*model.FileInfo{{Id: model.NewId(), Path: "a"}, {Id: model.NewId(), Path: "b"}}
+		second := []*model.FileInfo{{Id: model.NewId(), Path: "c"}}
+		m.files.On("GetBatchForRetentionPolicies", fileConfigs, model.RetentionPolicyCursor{}).Return(first, granularDone, nil).Once()
+		m.files.On("GetBatchForRetentionPolicies", fileConfigs, granularDone).Return(second, doneCursor, nil).Once()
+		m.fileBackend.On("RemoveFile", mock.Anything).Return(nil).Times(3)
+		m.files.On("PermanentDeleteByIds", mock.Anything, []string{first[0].Id, first[1].Id}).Return(int64(2), nil)
+		m.files.On("PermanentDeleteByIds", mock.Anything, []string{second[0].Id}).Return(int64(1), nil)
+		m.policies.On("DeleteOrphanedRows", 2).Return(int64(0), nil)
+
+		d, err := newDeleter(m.store, m.fileBackend, nil, request.TestContext(t), settings, now)
+		require.NoError(t, err)
+		d.sleep = func(time.Duration) {}
+
+		require.NoError(t, d.deleteAll())
+		assert.Equal(t, int64(0), d.counts["posts_deleted"])
+		assert.Equal(t, int64(3), d.counts["files_deleted"])
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		m := newDeleterMocks(t)
+		m.policies.On("GetCount").Return(int64(1), nil)
+		m.posts.On("PermanentDeleteBatchForRetentionPolicies", mock.Anything, mock.Anything).Return(int64(0), model.RetentionPolicyCursor{}, errors.New("connection refused"))
+
+		d, err := newDeleter(m.store, m.fileBackend, nil, request.TestContext(t), defaultSettings(), now)
+		require.NoError(t, err)
+
+		err = d.deleteAll()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to delete a batch of posts")
+	})
+}
@@ -0,0 +1,17 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package enterprise
+
+import (
+	// Needed to ensure the init() method in each implementation gets run
+	_ "github.com/mattermost/mattermost/server/v8/enterprise/access_control"
+	_ "github.com/mattermost/mattermost/server/v8/enterprise/account_migration"
+	_ "github.com/mattermost/mattermost/server/v8/enterprise/cluster"
+	_ "github.com/mattermost/mattermost/server/v8/enterprise/compliance"
+	_ "github.com/mattermost/mattermost/server/v8/enterprise/data_retention"
+	_ "github.com/mattermost/mattermost/server/v8/enterprise/ldap"
+	_ "github.com/mattermost/mattermost/server/v8/enterprise/message_export"
+	_ "github.com/mattermost/mattermost/server/v8/enterprise/metrics"
+	_ "github.com/mattermost/mattermost/server/v8/enterprise/saml"
+)
@@ -0,0 +1,279 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package ldap
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/ldap"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+)
+
+// defaultGroupFilter matches the usual group object classes when LdapSettings.GroupFilter is
+// empty
+const defaultGroupFilter = "(|(objectClass=group)(objectClass=groupOfNames)(objectClass=groupOfUniqueNames)(objectClass=posixGroup))"
+
+// Attributes of the group entries listing their members, by DN or by username
+var (
+	memberDNAttributes       = []string{"member", "uniqueMember"}
+	memberUsernameAttributes = []string{"memberUid"}
+)
+
+// configFileFunc reads a file uploaded to the configuration store, such as the certificates
+// referenced by LdapSettings
+type configFileFunc func(name string) ([]byte, error)
+
+// dial opens a connection to the server of the settings, securing it as configured, and binds
+// with BindUsername or anonymously
+func dial(settings *model.LdapSettings, configFile configFileFunc) (*ldap.Conn, *model.AppError) {
+	tlsConfig := &tls.Config{
+		ServerName:         *settings.LdapServer,
+		InsecureSkipVerify: *settings.SkipCertificateVerification,
+	}
+	if *settings.PublicCertificateFile != "" && *settings.PrivateKeyFile != "" {
+		certificate, appErr := clientCertificate(settings, configFile)
+		if appErr != nil {
+			return nil, appErr
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	address := net.JoinHostPort(*settings.LdapServer, strconv.Itoa(*settings.LdapPort))
+
+	var conn *ldap.Conn
+	var err error
+	if *settings.ConnectionSecurity == model.ConnSecurityTLS {
+		conn, err = ldap.DialTLS("tcp", address, tlsConfig)
+	} else {
+		conn, err = ldap.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, model.NewAppError("dial", "ent.ldap.do_login.unable_to_connect.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	conn.Start()
+	conn.SetTimeout(time.Duration(*settings.QueryTimeout) * time.Second)
+
+	if *settings.ConnectionSecurity == model.ConnSecurityStarttls {
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, model.NewAppError("dial", "ent.ldap.do_login.unable_to_connect.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+	}
+
+	if *settings.BindUsername != "" {
+		err = conn.Bind(*settings.BindUsername, *settings.BindPassword)
+	} else {
+		err = conn.UnauthenticatedBind("")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, model.NewAppError("dial", "ent.ldap.do_login.bind_admin_user.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return conn, nil
+}
+
+func clientCertificate(settings *model.LdapSettings, configFile configFileFunc) (tls.Certificate, *model.AppError) {
+	certPEM, err := configFile(*settings.PublicCertificateFile)
+	if err != nil {
+		return tls.Certificate{}, model.NewAppError("dial", "ent.ldap.do_login.certificate.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	keyPEM, err := configFile(*settings.PrivateKeyFile)
+	if err != nil {
+		return tls.Certificate{}, model.NewAppError("dial", "ent.ldap.do_login.key.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return tls.Certificate{}, model.NewAppError("dial", "ent.ldap.do_login.x509.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return certificate, nil
+}
+
+// normalizeFilter wraps the filter in parentheses, which admins often leave out
+func normalizeFilter(filter string) string {
+	filter = strings.TrimSpace(filter)
+	if filter == "" || strings.HasPrefix(filter, "(") {
+		return filter
+	}
+	return "(" + filter + ")"
+}
+
+// andFilters combines the non empty filters
+func andFilters(filters ...string) string {
+	var nonEmpty []string
+	for _, filter := range filters {
+		if filter = normalizeFilter(filter); filter != "" {
+			nonEmpty = append(nonEmpty, filter)
+		}
+	}
+
+	switch len(nonEmpty) {
+	case 0:
+		return "(objectClass=*)"
+	case 1:
+		return nonEmpty[0]
+	default:
+		return "(&" + strings.Join(nonEmpty, "") + ")"
+	}
+}
+
+func equalityFilter(attribute, value string) string {
+	return "(" + attribute + "=" + ldap.EscapeFilter(value) + ")"
+}
+
+// search runs a subtree search from BaseDN, in pages of MaxPageSize entries when set
+func search(conn *ldap.Conn, settings *model.LdapSettings, filter string, attributes []string) ([]*ldap.Entry, *model.AppError) {
+	request := ldap.NewSearchRequest(
+		*settings.BaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		*settings.QueryTimeout,
+		false,
+		filter,
+		attributes,
+		nil,
+	)
+
+	var result *ldap.SearchResult
+	var err error
+	if *settings.MaxPageSize > 0 {
+		result, err = conn.SearchWithPaging(request, uint32(*settings.MaxPageSize))
+	} else {
+		result, err = conn.Search(request)
+	}
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+			return nil, model.NewAppError("search", "ent.ldap.syncronize.search_failure_size_exceeded.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		return nil, model.NewAppError("search", "ent.ldap.syncronize.search_failure.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return result.Entries, nil
+}
+
+// matchesFilter tells whether the entry with the DN matches the filter
+func matchesFilter(conn *ldap.Conn, dn string, filter string) (bool, error) {
+	if filter == "" {
+		return false, nil
+	}
+
+	request := ldap.NewSearchRequest(dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, normalizeFilter(filter), []string{"1.1"}, nil)
+	result, err := conn.Search(request)
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return false, nil
+		}
+		return false, err
+	}
+	return len(result.Entries) > 0, nil
+}
+
+// userFilter is the filter of the entries allowed to log in
+func userFilter(settings *model.LdapSettings, filters ...string) string {
+	return andFilters(append([]string{*settings.UserFilter}, filters...)...)
+}
+
+func groupFilter(settings *model.LdapSettings, filters ...string) string {
+	base := *settings.GroupFilter
+	if base == "" {
+		base = defaultGroupFilter
+	}
+	return andFilters(append([]string{base}, filters...)...)
+}
+
+// userAttributes are the attributes read from the user entries
+func userAttributes(settings *model.LdapSettings) []string {
+	return nonEmpty(
+		*settings.IdAttribute,
+		*settings.LoginIdAttribute,
+		*settings.UsernameAttribute,
+		*settings.EmailAttribute,
+		*settings.FirstNameAttribute,
+		*settings.LastNameAttribute,
+		*settings.NicknameAttribute,
+		*settings.PositionAttribute,
+	)
+}
+
+func groupAttributes(settings *model.LdapSettings) []string {
+	attributes := nonEmpty(*settings.GroupIdAttribute, *settings.GroupDisplayNameAttribute)
+	attributes = append(attributes, memberDNAttributes...)
+	return append(attributes, memberUsernameAttributes...)
+}
+
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
+// attributeValue reads the first value of the attribute. Active Directory's objectGUID is
+// binary, and is read as hex.
+func attributeValue(entry *ldap.Entry, attribute string) string {
+	if attribute == "" {
+		return ""
+	}
+	if strings.EqualFold(attribute, "objectGUID") {
+		if raw := entry.GetRawAttributeValue(attribute); len(raw) > 0 {
+			return hex.EncodeToString(raw)
+		}
+	}
+	return entry.GetAttributeValue(attribute)
+}
+
+// userFromEntry maps the entry to a Mattermost user following the attributes of the settings.
+// The user is not saved.
+func userFromEntry(logger mlog.LoggerIFace, settings *model.LdapSettings, entry *ldap.Entry) *model.User {
+	authData := attributeValue(entry, *settings.IdAttribute)
+	username := attributeValue(entry, *settings.UsernameAttribute)
+	if username == "" {
+		username = authData
+	}
+
+	return &model.User{
+		AuthService:   model.UserAuthServiceLdap,
+		AuthData:      model.NewPointer(authData),
+		Username:      model.CleanUsername(logger, username),
+		Email:         strings.ToLower(attributeValue(entry, *settings.EmailAttribute)),
+		FirstName:     attributeValue(entry, *settings.FirstNameAttribute),
+		LastName:      attributeValue(entry, *settings.LastNameAttribute),
+		Nickname:      attributeValue(entry, *settings.NicknameAttribute),
+		Position:      attributeValue(entry, *settings.PositionAttribute),
+		EmailVerified: true,
+	}
+}
+
+// applyAttributes copies the attributes managed by LDAP from ldapUser to user, and tells
+// whether any of them changed
+func applyAttributes(settings *model.LdapSettings, user *model.User, ldapUser *model.User) bool {
+	changed := false
+	apply := func(attribute string, dest *string, value string) {
+		if attribute != "" && *dest != value {
+			*dest = value
+			changed = true
+		}
+	}
+
+	apply(*settings.UsernameAttribute, &user.Username, ldapUser.Username)
+	apply(*settings.EmailAttribute, &user.Email, ldapUser.Email)
+	apply(*settings.FirstNameAttribute, &user.FirstName, ldapUser.FirstName)
+	apply(*settings.LastNameAttribute, &user.LastName, ldapUser.LastName)
+	apply(*settings.NicknameAttribute, &user.Nickname, ldapUser.Nickname)
+	apply(*settings.PositionAttribute, &user.Position, ldapUser.Position)
+
+	return changed
+}
@@ -0,0 +1,231 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package ldap
+
+import (
+	"net/http"
+
+	"github.com/mattermost/ldap"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+)
+
+// diagnosticSampleSize is the number of entries returned along with the results of a test
+const diagnosticSampleSize = 5
+
+// Diagnostic tests the connection to the LDAP server and how the settings match its entries
+type Diagnostic struct {
+	config     func() *model.Config
+	configFile configFileFunc
+}
+
+func NewDiagnostic(config func() *model.Config, configFile func(name string) ([]byte, error)) *Diagnostic {
+	return &Diagnostic{config: config, configFile: configFile}
+}
+
+// RunTest checks that the saved settings find users
+func (d *Diagnostic) RunTest(rctx request.CTX) *model.AppError {
+	settings := &d.config().LdapSettings
+
+	conn, appErr := dial(settings, d.configFile)
+	if appErr != nil {
+		return appErr
+	}
+	defer conn.Close()
+
+	entries, appErr := search(conn, settings, userFilter(settings), []string{"1.1"})
+	if appErr != nil {
+		return appErr
+	}
+	if len(entries) == 0 {
+		return model.NewAppError("RunTest", "ent.ldap.no.users.checkcertificate", nil, "", http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// RunTestConnection checks that the server can be connected to and bound with the settings,
+// which don't need to be saved
+func (d *Diagnostic) RunTestConnection(rctx request.CTX, settings model.LdapSettings) *model.AppError {
+	settings.SetDefaults()
+
+	conn, appErr := dial(&settings, d.configFile)
+	if appErr != nil {
+		params := map[string]any{
+			"Server":             *settings.LdapServer,
+			"Port":               *settings.LdapPort,
+			"ConnectionType":     *settings.ConnectionSecurity,
+			"PrivateKeyFilename": *settings.PrivateKeyFile,
+			"PublicCertFilename": *settings.PublicCertificateFile,
+			"BindUsername":       *settings.BindUsername,
+			"Error":              appErr.Error(),
+		}
+		return model.NewAppError("RunTestConnection", "ent.ldap.connection.test_failed", params, "", http.StatusBadRequest).Wrap(appErr)
+	}
+	conn.Close()
+
+	return nil
+}
+
+// GetVendorNameAndVendorVersion reads the vendor advertised by the root DSE of the server
+func (d *Diagnostic) GetVendorNameAndVendorVersion(rctx request.CTX) (string, string, error) {
+	settings := &d.config().LdapSettings
+
+	conn, appErr := dial(settings, d.configFile)
+	if appErr != nil {
+		return "", "", appErr
+	}
+	defer conn.Close()
+
+	searchRequest := ldap.NewSearchRequest("", ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false, "(objectClass=*)", []string{"vendorName", "vendorVersion"}, nil)
+	result, err := conn.Search(searchRequest)
+	if err != nil {
+		return "", "", err
+	}
+	if len(result.Entries) == 0 {
+		return "", "", nil
+	}
+
+	return result.Entries[0].GetAttributeValue("vendorName"), result.Entries[0].GetAttributeValue("vendorVersion"), nil
+}
+
+// RunTestDiagnostics reports how many entries the filters match, or how many users or groups
+// have a value for each of the mapped attributes, along with a sample of them
+func (d *Diagnostic) RunTestDiagnostics(rctx request.CTX, testType model.LdapDiagnosticTestType, settings model.LdapSettings) ([]model.LdapDiagnosticResult, *model.AppError) {
+	settings.SetDefaults()
+
+	conn, appErr := dial(&settings, d.configFile)
+	if appErr != nil {
+		return nil, appErr
+	}
+	defer conn.Close()
+
+	switch testType {
+	case model.LdapDiagnosticTestTypeFilters:
+		return testFilters(conn, &settings), nil
+	case model.LdapDiagnosticTestTypeAttributes:
+		return testAttributes(conn, &settings, userFilter(&settings), []namedAttribute{
+			{"IdAttribute", *settings.IdAttribute},
+			{"LoginIdAttribute", *settings.LoginIdAttribute},
+			{"UsernameAttribute", *settings.UsernameAttribute},
+			{"EmailAttribute", *settings.EmailAttribute},
+			{"FirstNameAttribute", *settings.FirstNameAttribute},
+			{"LastNameAttribute", *settings.LastNameAttribute},
+			{"NicknameAttribute", *settings.NicknameAttribute},
+			{"PositionAttribute", *settings.PositionAttribute},
+			{"PictureAttribute", *settings.PictureAttribute},
+		}), nil
+	case model.LdapDiagnosticTestTypeGroupAttributes:
+		return testAttributes(conn, &settings, groupFilter(&settings), []namedAttribute{
+			{"GroupIdAttribute", *settings.GroupIdAttribute},
+			{"GroupDisplayNameAttribute", *settings.GroupDisplayNameAttribute},
+		}), nil
+	default:
+		return nil, model.NewAppError("RunTestDiagnostics", "api.context.invalid_body_param.app_error", map[string]any{"Name": "test"}, "", http.StatusBadRequest)
+	}
+}
+
+func testFilters(conn *ldap.Conn, settings *model.LdapSettings) []model.LdapDiagnosticResult {
+	filters := []struct {
+		name   string
+		value  string
+		filter string
+		group  bool
+	}{
+		{"UserFilter", *settings.UserFilter, userFilter(settings), false},
+		{"GroupFilter", *settings.GroupFilter, groupFilter(settings), true},
+		{"GuestFilter", *settings.GuestFilter, userFilter(settings, *settings.GuestFilter), false},
+		{"AdminFilter", *settings.AdminFilter, userFilter(settings, *settings.AdminFilter), false},
+	}
+
+	var results []model.LdapDiagnosticResult
+	for _, f := range filters {
+		if f.value == "" && f.name != "UserFilter" {
+			continue
+		}
+
+		result := model.LdapDiagnosticResult{TestName: f.name, TestValue: f.value}
+		attributes := userAttributes(settings)
+		if f.group {
+			attributes = nonEmpty(*settings.GroupIdAttribute, *settings.GroupDisplayNameAttribute)
+		}
+
+		entries, appErr := search(conn, settings, f.filter, attributes)
+		if appErr != nil {
+			result.Error = appErr.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.TotalCount = len(entries)
+		for _, entry := range entries[:min(len(entries), diagnosticSampleSize)] {
+			result.SampleResults = append(result.SampleResults, sampleEntry(settings, entry, f.group))
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// namedAttribute is an attribute mapped by the setting of the given name
+type namedAttribute struct {
+	setting   string
+	attribute string
+}
+
+func testAttributes(conn *ldap.Conn, settings *model.LdapSettings, filter string, attributes []namedAttribute) []model.LdapDiagnosticResult {
+	var names []string
+	for _, a := range attributes {
+		names = append(names, a.attribute)
+	}
+	entries, appErr := search(conn, settings, filter, nonEmpty(names...))
+
+	var results []model.LdapDiagnosticResult
+	for _, a := range attributes {
+		if a.attribute == "" {
+			continue
+		}
+
+		result := model.LdapDiagnosticResult{TestName: a.setting, TestValue: a.attribute}
+		if appErr != nil {
+			result.Error = appErr.Error()
+			results = append(results, result)
+			continue
+		}
+
+		result.TotalCount = len(entries)
+		for _, entry := range entries {
+			if len(entry.GetRawAttributeValue(a.attribute)) == 0 {
+				continue
+			}
+			result.EntriesWithValue++
+			if len(result.SampleResults) < diagnosticSampleSize {
+				result.SampleResults = append(result.SampleResults, model.LdapSampleEntry{
+					DN:                  entry.DN,
+					AvailableAttributes: map[string]string{a.attribute: attributeValue(entry, a.attribute)},
+				})
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func sampleEntry(settings *model.LdapSettings, entry *ldap.Entry, group bool) model.LdapSampleEntry {
+	if group {
+		return model.LdapSampleEntry{
+			DN:          entry.DN,
+			ID:          attributeValue(entry, *settings.GroupIdAttribute),
+			DisplayName: attributeValue(entry, *settings.GroupDisplayNameAttribute),
+		}
+	}
+	return model.LdapSampleEntry{
+		DN:        entry.DN,
+		ID:        attributeValue(entry, *settings.IdAttribute),
+		Username:  attributeValue(entry, *settings.UsernameAttribute),
+		Email:     attributeValue(entry, *settings.EmailAttribute),
+		FirstName: attributeValue(entry, *settings.FirstNameAttribute),
+		LastName:  attributeValue(entry, *settings.LastNameAttribute),
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package ldap
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/ldap"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+)
+
+// groupFromEntry maps the group entry to a Mattermost group, which is not saved
+func groupFromEntry(settings *model.LdapSettings, entry *ldap.Entry) *model.Group {
+	remoteID := attributeValue(entry, *settings.GroupIdAttribute)
+	displayName := attributeValue(entry, *settings.GroupDisplayNameAttribute)
+	if displayName == "" {
+		displayName = remoteID
+	}
+
+	return &model.Group{
+		Source:      model.GroupSourceLdap,
+		RemoteId:    model.NewPointer(remoteID),
+		DisplayName: displayName,
+	}
+}
+
+// findGroupEntry searches the group with the given GroupIdAttribute
+func findGroupEntry(conn *ldap.Conn, settings *model.LdapSettings, groupUID string) (*ldap.Entry, *model.AppError) {
+	if groupUID == "" || *settings.GroupIdAttribute == "" {
+		return nil, model.NewAppError("findGroupEntry", "ent.ldap_groups.invalid_ldap_id", nil, "", http.StatusBadRequest)
+	}
+
+	entries, appErr := search(conn, settings, groupFilter(settings, equalityFilter(*settings.GroupIdAttribute, groupUID)), groupAttributes(settings))
+	if appErr != nil {
+		return nil, model.NewAppError("findGroupEntry", "ent.ldap_groups.group_search_error", nil, "", http.StatusInternalServerError).Wrap(appErr)
+	}
+	if len(entries) == 0 {
+		return nil, model.NewAppError("findGroupEntry", "ent.ldap_groups.no_rows", nil, "", http.StatusNotFound)
+	}
+
+	return entries[0], nil
+}
+
+// isMember tells whether the user with the DN and username is listed by the group entry
+func isMember(groupEntry *ldap.Entry, dn string, username string) bool {
+	for _, attribute := range memberDNAttributes {
+		for _, member := range groupEntry.GetAttributeValues(attribute) {
+			if strings.EqualFold(normalizeDN(member), normalizeDN(dn)) {
+				return true
+			}
+		}
+	}
+	for _, attribute := range memberUsernameAttributes {
+		for _, member := range groupEntry.GetAttributeValues(attribute) {
+			if strings.EqualFold(member, username) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// normalizeDN removes the spaces around the separators of the DN, which servers don't agree on
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, part := range parts {
+		parts[i] = strings.TrimSpace(part)
+	}
+	return strings.ToLower(strings.Join(parts, ","))
+}
+
+// GetGroup returns the LDAP group with the given GroupIdAttribute, without saving it
+func (l *Ldap) GetGroup(rctx request.CTX, groupUID string) (*model.Group, *model.AppError) {
+	settings := l.settings()
+
+	conn, appErr := l.connect()
+	if appErr != nil {
+		return nil, appErr
+	}
+	defer conn.Close()
+
+	entry, appErr := findGroupEntry(conn, settings, groupUID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return groupFromEntry(settings, entry), nil
+}
+
+// GetAllGroupsPage lists the LDAP groups sorted by display name. The groups already linked to
+// Mattermost are returned with their Mattermost id and whether they have syncables.
+func (l *Ldap) GetAllGroupsPage(rctx request.CTX, page int, perPage int, opts model.LdapGroupSearchOpts) ([]*model.Group, int, *model.AppError) {
+	settings := l.settings()
+
+	conn, appErr := l.connect()
+	if appErr != nil {
+		return nil, 0, appErr
+	}
+	defer conn.Close()
+
+	var nameFilter string
+	if opts.Q != "" && *settings.GroupDisplayNameAttribute != "" {
+		nameFilter = "(" + *settings.GroupDisplayNameAttribute + "=*" + ldap.EscapeFilter(opts.Q) + "*)"
+	}
+
+	entries, appErr := search(conn, settings, groupFilter(settings, nameFilter), nonEmpty(*settings.GroupIdAttribute, *settings.GroupDisplayNameAttribute))
+	if appErr != nil {
+		return nil, 0, model.NewAppError("GetAllGroupsPage", "ent.ldap_groups.groups_search_error", nil, "", http.StatusInternalServerError).Wrap(appErr)
+	}
+
+	linked, appErr := l.app.GetGroupsBySource(model.GroupSourceLdap)
+	if appErr != nil {
+		return nil, 0, appErr
+	}
+	linkedByRemoteID := make(map[string]*model.Group, len(linked))
+	for _, group := range linked {
+		if group.DeleteAt == 0 && group.RemoteId != nil {
+			linkedByRemoteID[*group.RemoteId] = group
+		}
+	}
+
+	var groups []*model.Group
+	for _, entry := range entries {
+		group := groupFromEntry(settings, entry)
+		if *group.RemoteId == "" {
+			continue
+		}
+
+		if mmGroup, ok := linkedByRemoteID[*group.RemoteId]; ok {
+			group.Id = mmGroup.Id
+			hasSyncables, appErr := l.hasSyncables(mmGroup.Id)
+			if appErr != nil {
+				return nil, 0, appErr
+			}
+			group.HasSyncables = hasSyncables
+		}
+
+		isLinked := group.Id != ""
+		if opts.IsLinked != nil && *opts.IsLinked != isLinked {
+			continue
+		}
+		if opts.IsConfigured != nil && *opts.IsConfigured != group.HasSyncables {
+			continue
+		}
+
+		groups = append(groups, group)
+	}
+
+	sort.Slice(groups, func(i, j int) bool {
+		return strings.ToLower(groups[i].DisplayName) < strings.ToLower(groups[j].DisplayName)
+	})
+
+	total := len(groups)
+	start := min(page*perPage, total)
+	end := min(start+perPage, total)
+
+	return groups[start:end], total, nil
+}
+
+func (l *Ldap) hasSyncables(groupID string) (bool, *model.AppError) {
+	for _, syncableType := range []model.GroupSyncableType{model.GroupSyncableTypeTeam, model.GroupSyncableTypeChannel} {
+		syncables, appErr := l.app.GetGroupSyncables(groupID, syncableType)
+		if appErr != nil {
+			return false, appErr
+		}
+		if len(syncables) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,567 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package ldap implements einterfaces.LdapInterface and einterfaces.LdapDiagnosticInterface on
+// top of an AD/LDAP server configured in LdapSettings, and the ldap_sync job which keeps the
+// attributes of the LDAP users and the members of the linked LDAP groups in sync with the server.
+//
+// Users are looked up by LoginIdAttribute when logging in, and identified by IdAttribute, which
+// is stored as their AuthData. Groups are identified by GroupIdAttribute, stored as their
+// RemoteId, and list their members by DN in member or uniqueMember, or by username in memberUid.
+package ldap
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mattermost/ldap"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/channels/app/platform"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+	ejobs "github.com/mattermost/mattermost/server/v8/einterfaces/jobs"
+)
+
+// jobPollInterval is how often StartSynchronizeJob checks whether the job it waits for is done
+const jobPollInterval = time.Second
+
+func init() {
+	app.RegisterLdapInterface(func(a *app.App) einterfaces.LdapInterface {
+		return New(a)
+	})
+	app.RegisterJobsLdapSyncInterface(func(a *app.App) ejobs.LdapSyncInterface {
+		return &jobInterface{app: a}
+	})
+	platform.RegisterLdapDiagnosticInterface(func(ps *platform.PlatformService) einterfaces.LdapDiagnosticInterface {
+		return NewDiagnostic(ps.Config, ps.GetConfigFile)
+	})
+}
+
+type Ldap struct {
+	app *app.App
+}
+
+func New(a *app.App) *Ldap {
+	return &Ldap{app: a}
+}
+
+func (l *Ldap) settings() *model.LdapSettings {
+	return &l.app.Config().LdapSettings
+}
+
+func (l *Ldap) isLicensed() bool {
+	license := l.app.Srv().License()
+	return license != nil && *license.Features.LDAP
+}
+
+func (l *Ldap) connect() (*ldap.Conn, *model.AppError) {
+	if !l.isLicensed() {
+		return nil, model.NewAppError("connect", "ent.ldap.do_login.licence_disable.app_error", nil, "", http.StatusNotImplemented)
+	}
+	return dial(l.settings(), l.app.GetConfigFile)
+}
+
+// findEntry searches the user whose attribute has the value, failing when there is none or more
+// than one. The user filter is not applied.
+func findEntry(conn *ldap.Conn, settings *model.LdapSettings, attribute, value string) (*ldap.Entry, *model.AppError) {
+	if value == "" {
+		return nil, model.NewAppError("findEntry", "ent.ldap.do_login.invalid_id", nil, "", http.StatusBadRequest)
+	}
+
+	entries, appErr := search(conn, settings, equalityFilter(attribute, value), userAttributes(settings))
+	if appErr != nil {
+		return nil, model.NewAppError("findEntry", "ent.ldap.do_login.search_ldap_server.app_error", nil, "", http.StatusInternalServerError).Wrap(appErr)
+	}
+
+	switch len(entries) {
+	case 0:
+		return nil, model.NewAppError("findEntry", "ent.ldap.do_login.user_not_registered.app_error", nil, "", http.StatusBadRequest)
+	case 1:
+		return entries[0], nil
+	default:
+		return nil, model.NewAppError("findEntry", "ent.ldap.do_login.matched_to_many_users.app_error", nil, "", http.StatusBadRequest)
+	}
+}
+
+// findAllowedEntry is findEntry for users who must pass the user filter
+func findAllowedEntry(conn *ldap.Conn, settings *model.LdapSettings, attribute, value string) (*ldap.Entry, *model.AppError) {
+	entry, appErr := findEntry(conn, settings, attribute, value)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if *settings.UserFilter != "" {
+		allowed, err := matchesFilter(conn, entry.DN, *settings.UserFilter)
+		if err != nil {
+			return nil, model.NewAppError("findAllowedEntry", "ent.ldap.do_login.search_ldap_server.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		if !allowed {
+			return nil, model.NewAppError("findAllowedEntry", "ent.ldap.do_login.user_filtered.app_error", nil, "", http.StatusBadRequest)
+		}
+	}
+
+	return entry, nil
+}
+
+// userRoles tells whether the user is a guest or an admin following the guest and admin filters
+func userRoles(conn *ldap.Conn, settings *model.LdapSettings, dn string) (isGuest bool, isAdmin bool, err error) {
+	if *settings.GuestFilter != "" {
+		if isGuest, err = matchesFilter(conn, dn, *settings.GuestFilter); err != nil {
+			return false, false, err
+		}
+	}
+	if *settings.EnableAdminFilter && *settings.AdminFilter != "" {
+		if isAdmin, err = matchesFilter(conn, dn, *settings.AdminFilter); err != nil {
+			return false, false, err
+		}
+	}
+	return isGuest, isAdmin, nil
+}
+
+// DoLogin authenticates the user with the given IdAttribute against the server, creating its
+// Mattermost account on its first login and updating it on the next ones
+func (l *Ldap) DoLogin(rctx request.CTX, id string, password string) (*model.User, *model.AppError) {
+	settings := l.settings()
+
+	conn, appErr := l.connect()
+	if appErr != nil {
+		return nil, appErr
+	}
+	defer conn.Close()
+
+	entry, appErr := findAllowedEntry(conn, settings, *settings.IdAttribute, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	isGuest, isAdmin, err := userRoles(conn, settings, entry.DN)
+	if err != nil {
+		return nil, model.NewAppError("DoLogin", "ent.ldap.do_login.search_ldap_server.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	if password == "" {
+		return nil, model.NewAppError("DoLogin", "ent.ldap.do_login.invalid_password.app_error", nil, "", http.StatusUnauthorized)
+	}
+	if err = conn.Bind(entry.DN, password); err != nil {
+		return nil, model.NewAppError("DoLogin", "ent.ldap.do_login.invalid_password.app_error", nil, "", http.StatusUnauthorized).Wrap(err)
+	}
+
+	ldapUser := userFromEntry(rctx.Logger(), settings, entry)
+
+	user, appErr := l.app.GetUserByAuth(ldapUser.AuthData, model.UserAuthServiceLdap)
+	if appErr != nil {
+		if appErr.Id != app.MissingAuthAccountError {
+			return nil, appErr
+		}
+		return l.createUser(rctx, ldapUser, isGuest, isAdmin)
+	}
+
+	return l.updateUser(rctx, user, ldapUser, isGuest, isAdmin)
+}
+
+func (l *Ldap) createUser(rctx request.CTX, ldapUser *model.User, isGuest, isAdmin bool) (*model.User, *model.AppError) {
+	if _, appErr := l.app.GetUserByEmail(ldapUser.Email); appErr == nil {
+		return nil, model.NewAppError("createUser", "ent.ldap.save_user.email_exists.ldap_app_error", nil, "", http.StatusBadRequest)
+	}
+	if _, appErr := l.app.GetUserByUsername(ldapUser.Username); appErr == nil {
+		return nil, model.NewAppError("createUser", "ent.ldap.save_user.username_exists.ldap_app_error", nil, "", http.StatusBadRequest)
+	}
+
+	var user *model.User
+	var appErr *model.AppError
+	if isGuest {
+		user, appErr = l.app.CreateGuest(rctx, ldapUser)
+	} else {
+		user, appErr = l.app.CreateUser(rctx, ldapUser)
+	}
+	if appErr != nil {
+		return nil, model.NewAppError("createUser", "ent.ldap.create_fail", nil, "", http.StatusInternalServerError).Wrap(appErr)
+	}
+
+	if isAdmin && !isGuest {
+		if user, appErr = l.app.UpdateUserRoles(rctx, user.Id, user.Roles+" "+model.SystemAdminRoleId, false); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	rctx.Logger().Info("Created LDAP user", mlog.String("user_id", user.Id))
+	return user, nil
+}
+
+// updateUser brings the user in line with its LDAP entry
+func (l *Ldap) updateUser(rctx request.CTX, user *model.User, ldapUser *model.User, isGuest, isAdmin bool) (*model.User, *model.AppError) {
+	settings := l.settings()
+
+	if applyAttributes(settings, user, ldapUser) {
+		updated, appErr := l.app.UpdateUser(rctx, user, false)
+		if appErr != nil {
+			return nil, appErr
+		}
+		user = updated
+	}
+
+	return l.updateRoles(rctx, user, isGuest, isAdmin)
+}
+
+// updateRoles promotes or demotes the user following the guest and admin filters. Admins
+// promoted by hand are kept when the admin filter is disabled.
+func (l *Ldap) updateRoles(rctx request.CTX, user *model.User, isGuest, isAdmin bool) (*model.User, *model.AppError) {
+	settings := l.settings()
+
+	if *settings.GuestFilter != "" && isGuest != user.IsGuest() {
+		var appErr *model.AppError
+		if isGuest {
+			appErr = l.app.DemoteUserToGuest(rctx, user)
+		} else {
+			appErr = l.app.PromoteGuestToUser(rctx, user, "")
+		}
+		if appErr != nil {
+			return nil, appErr
+		}
+		if user, appErr = l.app.GetUser(user.Id); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	if *settings.EnableAdminFilter && !user.IsGuest() && isAdmin != user.IsSystemAdmin() {
+		roles := strings.Fields(user.Roles)
+		if isAdmin {
+			roles = append(roles, model.SystemAdminRoleId)
+		} else {
+			roles = removeRole(roles, model.SystemAdminRoleId)
+		}
+
+		var appErr *model.AppError
+		if user, appErr = l.app.UpdateUserRoles(rctx, user.Id, strings.Join(roles, " "), true); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	return user, nil
+}
+
+func removeRole(roles []string, role string) []string {
+	result := make([]string, 0, len(roles))
+	for _, r := range roles {
+		if r != role {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// GetUser returns the LDAP user with the given LoginIdAttribute, without saving it
+func (l *Ldap) GetUser(rctx request.CTX, id string) (*model.User, *model.AppError) {
+	settings := l.settings()
+
+	conn, appErr := l.connect()
+	if appErr != nil {
+		return nil, appErr
+	}
+	defer conn.Close()
+
+	entry, appErr := findAllowedEntry(conn, settings, *settings.LoginIdAttribute, id)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return userFromEntry(rctx.Logger(), settings, entry), nil
+}
+
+// GetLDAPUserForMMUser returns the LDAP user and the DN of the entry matching the AuthData of
+// the Mattermost user
+func (l *Ldap) GetLDAPUserForMMUser(rctx request.CTX, mmUser *model.User) (*model.User, string, *model.AppError) {
+	if mmUser.AuthData == nil || *mmUser.AuthData == "" {
+		return nil, "", model.NewAppError("GetLDAPUserForMMUser", "ent.ldap.do_login.invalid_id", nil, "", http.StatusBadRequest)
+	}
+
+	settings := l.settings()
+
+	conn, appErr := l.connect()
+	if appErr != nil {
+		return nil, "", appErr
+	}
+	defer conn.Close()
+
+	entry, appErr := findAllowedEntry(conn, settings, *settings.IdAttribute, *mmUser.AuthData)
+	if appErr != nil {
+		return nil, "", appErr
+	}
+
+	return userFromEntry(rctx.Logger(), settings, entry), entry.DN, nil
+}
+
+// GetUserAttributes reads the given attributes of the user with the given IdAttribute
+func (l *Ldap) GetUserAttributes(rctx request.CTX, id string, attributes []string) (map[string]string, *model.AppError) {
+	settings := l.settings()
+
+	conn, appErr := l.connect()
+	if appErr != nil {
+		return nil, appErr
+	}
+	defer conn.Close()
+
+	entries, appErr := search(conn, settings, userFilter(settings, equalityFilter(*settings.IdAttribute, id)), attributes)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if len(entries) != 1 {
+		return nil, model.NewAppError("GetUserAttributes", "ent.ldap.do_login.user_not_registered.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	values := make(map[string]string, len(attributes))
+	for _, attribute := range attributes {
+		values[attribute] = attributeValue(entries[0], attribute)
+	}
+	return values, nil
+}
+
+// CheckProviderAttributes returns the name of the field the patch would change though it is
+// synchronized from LDAP, or an empty string
+func (l *Ldap) CheckProviderAttributes(rctx request.CTX, LS *model.LdapSettings, ouser *model.User, patch *model.UserPatch) string {
+	tryingToChange := func(attribute *string, userValue string, patchValue *string) bool {
+		return *attribute != "" && patchValue != nil && *patchValue != userValue
+	}
+
+	switch {
+	case tryingToChange(LS.FirstNameAttribute, ouser.FirstName, patch.FirstName),
+		tryingToChange(LS.LastNameAttribute, ouser.LastName, patch.LastName):
+		return "full name"
+	case tryingToChange(LS.NicknameAttribute, ouser.Nickname, patch.Nickname):
+		return "nickname"
+	case tryingToChange(LS.EmailAttribute, ouser.Email, patch.Email):
+		return "email"
+	case tryingToChange(LS.PositionAttribute, ouser.Position, patch.Position):
+		return "position"
+	default:
+		return ""
+	}
+}
+
+// SwitchToLdap moves the user to LDAP authentication, once the LDAP credentials are verified
+func (l *Ldap) SwitchToLdap(rctx request.CTX, userID, ldapID, ldapPassword string) *model.AppError {
+	settings := l.settings()
+
+	conn, appErr := l.connect()
+	if appErr != nil {
+		return appErr
+	}
+	defer conn.Close()
+
+	entry, appErr := findAllowedEntry(conn, settings, *settings.LoginIdAttribute, ldapID)
+	if appErr != nil {
+		return appErr
+	}
+
+	if ldapPassword == "" {
+		return model.NewAppError("SwitchToLdap", "ent.ldap.do_login.invalid_password.app_error", nil, "", http.StatusUnauthorized)
+	}
+	if err := conn.Bind(entry.DN, ldapPassword); err != nil {
+		return model.NewAppError("SwitchToLdap", "ent.ldap.do_login.invalid_password.app_error", nil, "", http.StatusUnauthorized).Wrap(err)
+	}
+
+	ldapUser := userFromEntry(rctx.Logger(), settings, entry)
+	if existing, appErr := l.app.GetUserByAuth(ldapUser.AuthData, model.UserAuthServiceLdap); appErr == nil && existing.Id != userID {
+		return model.NewAppError("SwitchToLdap", "ent.ldap.switch_to_ldap.already_linked.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if _, err := l.app.Srv().Store().User().UpdateAuthData(userID, model.UserAuthServiceLdap, ldapUser.AuthData, "", false); err != nil {
+		return model.NewAppError("SwitchToLdap", "app.user.update_auth_data.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	l.app.InvalidateCacheForUser(userID)
+
+	return nil
+}
+
+// StartSynchronizeJob creates an ldap_sync job, and waits for it to be done if asked to
+func (l *Ldap) StartSynchronizeJob(rctx request.CTX, waitForJobToFinish bool) (*model.Job, *model.AppError) {
+	job, appErr := l.app.Srv().Jobs.CreateJob(rctx, model.JobTypeLdapSync, nil)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	for waitForJobToFinish {
+		time.Sleep(jobPollInterval)
+
+		if job, appErr = l.app.Srv().Jobs.GetJob(rctx, job.Id); appErr != nil {
+			return nil, appErr
+		}
+		switch job.Status {
+		case model.JobStatusSuccess, model.JobStatusError, model.JobStatusCanceled, model.JobStatusWarning:
+			return job, nil
+		}
+	}
+
+	return job, nil
+}
+
+// GetAllLdapUsers returns the users passing the user filter, without saving them
+func (l *Ldap) GetAllLdapUsers(rctx request.CTX) ([]*model.User, *model.AppError) {
+	settings := l.settings()
+
+	conn, appErr := l.connect()
+	if appErr != nil {
+		return nil, appErr
+	}
+	defer conn.Close()
+
+	entries, appErr := search(conn, settings, userFilter(settings), userAttributes(settings))
+	if appErr != nil {
+		return nil, model.NewAppError("GetAllLdapUsers", "ent.ldap.syncronize.get_all.app_error", nil, "", http.StatusInternalServerError).Wrap(appErr)
+	}
+
+	users := make([]*model.User, 0, len(entries))
+	for _, entry := range entries {
+		users = append(users, userFromEntry(rctx.Logger(), settings, entry))
+	}
+	return users, nil
+}
+
+// MigrateIDAttribute changes the AuthData of the LDAP users to the value of another attribute,
+// then makes it the IdAttribute. Nothing is migrated unless every value is found and unique.
+func (l *Ldap) MigrateIDAttribute(rctx request.CTX, toAttribute string) error {
+	if toAttribute == "" {
+		return model.NewAppError("MigrateIDAttribute", "ent.ldap_id_migrate.app_error", nil, "empty attribute", http.StatusBadRequest)
+	}
+
+	settings := l.settings()
+
+	conn, appErr := l.connect()
+	if appErr != nil {
+		return appErr
+	}
+	defer conn.Close()
+
+	users, err := l.app.Srv().Store().User().GetAllUsingAuthService(model.UserAuthServiceLdap)
+	if err != nil {
+		return model.NewAppError("MigrateIDAttribute", "ent.ldap.syncronize.get_all.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	entries, appErr := search(conn, settings, userFilter(settings), []string{*settings.IdAttribute, toAttribute})
+	if appErr != nil {
+		return appErr
+	}
+	newIDs := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		newIDs[attributeValue(entry, *settings.IdAttribute)] = attributeValue(entry, toAttribute)
+	}
+
+	migrated := make(map[string]string, len(users))
+	seen := make(map[string]bool, len(users))
+	for _, user := range users {
+		if user.AuthData == nil {
+			continue
+		}
+		newID := newIDs[*user.AuthData]
+		if newID == "" || seen[newID] {
+			return model.NewAppError("MigrateIDAttribute", "ent.ldap_id_migrate.app_error", nil, "user_id="+user.Id, http.StatusBadRequest)
+		}
+		seen[newID] = true
+		migrated[user.Id] = newID
+	}
+
+	for _, user := range users {
+		newID, ok := migrated[user.Id]
+		if !ok {
+			continue
+		}
+		if _, err := l.app.Srv().Store().User().UpdateAuthData(user.Id, model.UserAuthServiceLdap, &newID, "", false); err != nil {
+			return model.NewAppError("MigrateIDAttribute", "ent.ldap_id_migrate.app_error", nil, "user_id="+user.Id, http.StatusInternalServerError).Wrap(err)
+		}
+		l.app.InvalidateCacheForUser(user.Id)
+	}
+
+	l.app.UpdateConfig(func(cfg *model.Config) {
+		*cfg.LdapSettings.IdAttribute = toAttribute
+	})
+
+	rctx.Logger().Info("Migrated the LDAP id attribute", mlog.String("attribute", toAttribute), mlog.Int("users", len(migrated)))
+	return nil
+}
+
+// FirstLoginSync adds the user to its linked LDAP groups, and to their teams and channels,
+// without waiting for the next ldap_sync job
+func (l *Ldap) FirstLoginSync(rctx request.CTX, user *model.User) *model.AppError {
+	if user.AuthData == nil {
+		return nil
+	}
+
+	settings := l.settings()
+
+	conn, appErr := l.connect()
+	if appErr != nil {
+		return appErr
+	}
+	defer conn.Close()
+
+	entry, appErr := findAllowedEntry(conn, settings, *settings.IdAttribute, *user.AuthData)
+	if appErr != nil {
+		return appErr
+	}
+
+	groups, appErr := l.app.GetGroupsBySource(model.GroupSourceLdap)
+	if appErr != nil {
+		return appErr
+	}
+
+	for _, group := range groups {
+		if group.DeleteAt != 0 || group.RemoteId == nil {
+			continue
+		}
+
+		groupEntry, appErr := findGroupEntry(conn, settings, *group.RemoteId)
+		if appErr != nil {
+			rctx.Logger().Warn("Failed to read the LDAP group", mlog.String("group_id", group.Id), mlog.Err(appErr))
+			continue
+		}
+		if !isMember(groupEntry, entry.DN, userFromEntry(rctx.Logger(), settings, entry).Username) {
+			continue
+		}
+		if _, appErr := l.app.UpsertGroupMember(group.Id, user.Id); appErr != nil {
+			return appErr
+		}
+	}
+
+	params := model.CreateDefaultMembershipParams{ReAddRemovedMembers: *settings.ReAddRemovedMembers, ScopedUserID: &user.Id}
+	if err := l.app.CreateDefaultMemberships(rctx, params); err != nil {
+		return model.NewAppError("FirstLoginSync", "ent.ldap.syncronize.populate_syncables", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return nil
+}
+
+// UpdateProfilePictureIfNecessary sets the profile picture of the user from PictureAttribute.
+// The picture is only written when it differs from the current one.
+func (l *Ldap) UpdateProfilePictureIfNecessary(rctx request.CTX, user model.User, session model.Session) {
+	settings := l.settings()
+	if *settings.PictureAttribute == "" || user.AuthData == nil {
+		return
+	}
+
+	conn, appErr := l.connect()
+	if appErr != nil {
+		rctx.Logger().Warn("Failed to connect to the LDAP server to update the profile picture", mlog.String("user_id", user.Id), mlog.Err(appErr))
+		return
+	}
+	defer conn.Close()
+
+	entries, appErr := search(conn, settings, userFilter(settings, equalityFilter(*settings.IdAttribute, *user.AuthData)), []string{*settings.PictureAttribute})
+	if appErr != nil || len(entries) != 1 {
+		rctx.Logger().Warn("Failed to read the LDAP profile picture", mlog.String("user_id", user.Id), mlog.Err(appErr))
+		return
+	}
+
+	picture := entries[0].GetRawAttributeValue(*settings.PictureAttribute)
+	if len(picture) == 0 {
+		return
+	}
+
+	if appErr := l.app.SetProfileImageFromFile(rctx, user.Id, bytes.NewReader(picture)); appErr != nil {
+		rctx.Logger().Warn("Failed to set the LDAP profile picture", mlog.String("user_id", user.Id), mlog.Err(appErr))
+	}
+}
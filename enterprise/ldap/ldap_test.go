@@ -0,0 +1,422 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package ldap
+
+import (
+	"testing"
+
+	"github.com/mattermost/ldap"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/enterprise/ldap/ldaptest"
+)
+
+const (
+	testBaseDN   = "dc=mm,dc=test,dc=com"
+	testBindDN   = "cn=admin,dc=mm,dc=test,dc=com"
+	testPassword = "Password1"
+)
+
+func newTestServer(t *testing.T) *ldaptest.Server {
+	t.Helper()
+
+	server, err := ldaptest.NewServer()
+	require.NoError(t, err)
+	t.Cleanup(func() { server.Close() })
+
+	server.AddEntry(testBindDN, map[string][]string{
+		"objectClass":  {"organizationalRole"},
+		"cn":           {"admin"},
+		"userPassword": {testPassword},
+	})
+	addUser := func(uid, email, title string, objectClasses ...string) {
+		server.AddEntry("uid="+uid+",ou=testusers,"+testBaseDN, map[string][]string{
+			"objectClass":  append([]string{"inetOrgPerson"}, objectClasses...),
+			"uid":          {uid},
+			"cn":           {uid},
+			"sn":           {"Test"},
+			"givenName":    {uid},
+			"mail":         {email},
+			"title":        {title},
+			"userPassword": {testPassword},
+		})
+	}
+	addUser("test.one", "Test.One@simulator.amazonses.com", "Engineer")
+	addUser("test.two", "test.two@simulator.amazonses.com", "Manager", "mmAdmin")
+	addUser("test.guest", "test.guest@simulator.amazonses.com", "Contractor", "mmGuest")
+	addUser("dev.ops", "dev.ops@simulator.amazonses.com", "Operator", "disabled")
+
+	server.AddEntry("cn=developers,ou=testgroups,"+testBaseDN, map[string][]string{
+		"objectClass":  {"groupOfUniqueNames"},
+		"cn":           {"developers"},
+		"entryUUID":    {"developers-uuid"},
+		"uniqueMember": {"uid=test.one, ou=testusers," + testBaseDN, "uid=unknown,ou=testusers," + testBaseDN},
+	})
+	server.AddEntry("cn=ops,ou=testgroups,"+testBaseDN, map[string][]string{
+		"objectClass": {"posixGroup"},
+		"cn":          {"ops"},
+		"entryUUID":   {"ops-uuid"},
+		"memberUid":   {"Dev.Ops", "test.two"},
+	})
+
+	return server
+}
+
+func testSettings(server *ldaptest.Server) *model.LdapSettings {
+	settings := &model.LdapSettings{}
+	settings.SetDefaults()
+	settings.Enable = model.NewPointer(true)
+	settings.LdapServer = model.NewPointer(server.Host())
+	settings.LdapPort = model.NewPointer(server.Port())
+	settings.BaseDN = model.NewPointer(testBaseDN)
+	settings.BindUsername = model.NewPointer(testBindDN)
+	settings.BindPassword = model.NewPointer(testPassword)
+	settings.UserFilter = model.NewPointer("(objectClass=inetOrgPerson)")
+	settings.IdAttribute = model.NewPointer("uid")
+	settings.LoginIdAttribute = model.NewPointer("uid")
+	settings.UsernameAttribute = model.NewPointer("uid")
+	settings.EmailAttribute = model.NewPointer("mail")
+	settings.FirstNameAttribute = model.NewPointer("givenName")
+	settings.LastNameAttribute = model.NewPointer("sn")
+	settings.PositionAttribute = model.NewPointer("title")
+	settings.GroupFilter = model.NewPointer("(|(objectClass=groupOfUniqueNames)(objectClass=posixGroup))")
+	settings.GroupIdAttribute = model.NewPointer("entryUUID")
+	settings.GroupDisplayNameAttribute = model.NewPointer("cn")
+	return settings
+}
+
+func testDial(t *testing.T, settings *model.LdapSettings) *ldap.Conn {
+	t.Helper()
+
+	conn, appErr := dial(settings, nil)
+	require.Nil(t, appErr)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestDial(t *testing.T) {
+	server := newTestServer(t)
+
+	t.Run("binds as the bind user", func(t *testing.T) {
+		testDial(t, testSettings(server))
+	})
+
+	t.Run("binds anonymously without a bind user", func(t *testing.T) {
+		settings := testSettings(server)
+		settings.BindUsername = model.NewPointer("")
+		settings.BindPassword = model.NewPointer("")
+		testDial(t, settings)
+	})
+
+	t.Run("wrong bind password", func(t *testing.T) {
+		settings := testSettings(server)
+		settings.BindPassword = model.NewPointer("wrong")
+
+		_, appErr := dial(settings, nil)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.ldap.do_login.bind_admin_user.app_error", appErr.Id)
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		settings := testSettings(server)
+		settings.LdapPort = model.NewPointer(1)
+
+		_, appErr := dial(settings, nil)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.ldap.do_login.unable_to_connect.app_error", appErr.Id)
+	})
+}
+
+func TestFindAllowedEntry(t *testing.T) {
+	server := newTestServer(t)
+	settings := testSettings(server)
+	settings.UserFilter = model.NewPointer("(!(objectClass=disabled))")
+	conn := testDial(t, settings)
+
+	t.Run("found", func(t *testing.T) {
+		entry, appErr := findAllowedEntry(conn, settings, "uid", "test.one")
+		require.Nil(t, appErr)
+		assert.Equal(t, "uid=test.one,ou=testusers,"+testBaseDN, entry.DN)
+		assert.Equal(t, "Engineer", entry.GetAttributeValue("title"))
+		assert.Empty(t, entry.GetAttributeValue("userPassword"))
+	})
+
+	t.Run("not registered", func(t *testing.T) {
+		_, appErr := findAllowedEntry(conn, settings, "uid", "nobody")
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.ldap.do_login.user_not_registered.app_error", appErr.Id)
+	})
+
+	t.Run("matching several entries", func(t *testing.T) {
+		_, appErr := findAllowedEntry(conn, settings, "sn", "Test")
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.ldap.do_login.matched_to_many_users.app_error", appErr.Id)
+	})
+
+	t.Run("filtered out", func(t *testing.T) {
+		_, appErr := findAllowedEntry(conn, settings, "uid", "dev.ops")
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.ldap.do_login.user_filtered.app_error", appErr.Id)
+
+		_, appErr = findEntry(conn, settings, "uid", "dev.ops")
+		require.Nil(t, appErr)
+	})
+
+	t.Run("escapes the value", func(t *testing.T) {
+		_, appErr := findAllowedEntry(conn, settings, "uid", "*")
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.ldap.do_login.user_not_registered.app_error", appErr.Id)
+	})
+
+	t.Run("the user binds with their password", func(t *testing.T) {
+		entry, appErr := findAllowedEntry(conn, settings, "uid", "test.one")
+		require.Nil(t, appErr)
+
+		userConn := testDial(t, settings)
+		require.NoError(t, userConn.Bind(entry.DN, testPassword))
+		require.Error(t, userConn.Bind(entry.DN, "wrong"))
+	})
+}
+
+func TestUserRoles(t *testing.T) {
+	server := newTestServer(t)
+	settings := testSettings(server)
+	settings.GuestFilter = model.NewPointer("(objectClass=mmGuest)")
+	settings.AdminFilter = model.NewPointer("(objectClass=mmAdmin)")
+	conn := testDial(t, settings)
+
+	for _, tc := range []struct {
+		uid         string
+		adminFilter bool
+		isGuest     bool
+		isAdmin     bool
+	}{
+		{"test.one", true, false, false},
+		{"test.guest", true, true, false},
+		{"test.two", true, false, true},
+		{"test.two", false, false, false},
+	} {
+		settings.EnableAdminFilter = model.NewPointer(tc.adminFilter)
+
+		isGuest, isAdmin, err := userRoles(conn, settings, "uid="+tc.uid+",ou=testusers,"+testBaseDN)
+		require.NoError(t, err)
+		assert.Equal(t, tc.isGuest, isGuest, tc.uid)
+		assert.Equal(t, tc.isAdmin, isAdmin, tc.uid)
+	}
+}
+
+func TestUserFromEntry(t *testing.T) {
+	server := newTestServer(t)
+	settings := testSettings(server)
+	settings.NicknameAttribute = model.NewPointer("cn")
+	conn := testDial(t, settings)
+
+	entry, appErr := findEntry(conn, settings, "uid", "test.one")
+	require.Nil(t, appErr)
+
+	user := userFromEntry(mlog.CreateConsoleTestLogger(t), settings, entry)
+	assert.Equal(t, model.UserAuthServiceLdap, user.AuthService)
+	assert.Equal(t, "test.one", *user.AuthData)
+	assert.Equal(t, "test.one", user.Username)
+	assert.Equal(t, "test.one@simulator.amazonses.com", user.Email)
+	assert.Equal(t, "test.one", user.FirstName)
+	assert.Equal(t, "Test", user.LastName)
+	assert.Equal(t, "test.one", user.Nickname)
+	assert.Equal(t, "Engineer", user.Position)
+	assert.True(t, user.EmailVerified)
+
+	t.Run("only the mapped attributes are applied", func(t *testing.T) {
+		settings.NicknameAttribute = model.NewPointer("")
+		existing := &model.User{Username: "test.one", Email: "old@simulator.amazonses.com", Nickname: "kept", FirstName: "test.one", LastName: "Test", Position: "Engineer"}
+
+		assert.True(t, applyAttributes(settings, existing, user))
+		assert.Equal(t, "test.one@simulator.amazonses.com", existing.Email)
+		assert.Equal(t, "kept", existing.Nickname)
+
+		assert.False(t, applyAttributes(settings, existing, user))
+	})
+}
+
+func TestGroups(t *testing.T) {
+	server := newTestServer(t)
+	settings := testSettings(server)
+	conn := testDial(t, settings)
+
+	t.Run("find a group", func(t *testing.T) {
+		entry, appErr := findGroupEntry(conn, settings, "developers-uuid")
+		require.Nil(t, appErr)
+
+		group := groupFromEntry(settings, entry)
+		assert.Equal(t, model.GroupSourceLdap, group.Source)
+		assert.Equal(t, "developers-uuid", *group.RemoteId)
+		assert.Equal(t, "developers", group.DisplayName)
+
+		assert.True(t, isMember(entry, "uid=test.one,ou=testusers,"+testBaseDN, "test.one"))
+		assert.False(t, isMember(entry, "uid=test.two,ou=testusers,"+testBaseDN, "test.two"))
+	})
+
+	t.Run("members by username", func(t *testing.T) {
+		entry, appErr := findGroupEntry(conn, settings, "ops-uuid")
+		require.Nil(t, appErr)
+
+		assert.True(t, isMember(entry, "uid=dev.ops,ou=testusers,"+testBaseDN, "dev.ops"))
+		assert.False(t, isMember(entry, "uid=test.one,ou=testusers,"+testBaseDN, "test.one"))
+	})
+
+	t.Run("unknown group", func(t *testing.T) {
+		_, appErr := findGroupEntry(conn, settings, "unknown-uuid")
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.ldap_groups.no_rows", appErr.Id)
+	})
+
+	t.Run("group filter applies", func(t *testing.T) {
+		groupFilter := settings.GroupFilter
+		settings.GroupFilter = model.NewPointer("(objectClass=posixGroup)")
+		defer func() { settings.GroupFilter = groupFilter }()
+
+		_, appErr := findGroupEntry(conn, settings, "developers-uuid")
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.ldap_groups.no_rows", appErr.Id)
+	})
+}
+
+func TestPlanUserSync(t *testing.T) {
+	settings := &model.LdapSettings{}
+	settings.SetDefaults()
+	settings.UsernameAttribute = model.NewPointer("uid")
+	settings.EmailAttribute = model.NewPointer("mail")
+	settings.PositionAttribute = model.NewPointer("title")
+
+	newUser := func(authData, position string, deleteAt int64) *model.User {
+		return &model.User{Id: model.NewId(), Username: authData, Email: authData + "@simulator.amazonses.com", Position: position, AuthService: model.UserAuthServiceLdap, AuthData: model.NewPointer(authData), DeleteAt: deleteAt}
+	}
+	unchanged := newUser("unchanged", "Engineer", 0)
+	moved := newUser("moved", "Engineer", 0)
+	removed := newUser("removed", "Engineer", 0)
+	alreadyRemoved := newUser("already.removed", "Engineer", 100)
+	returning := newUser("returning", "Engineer", 100)
+
+	entries := map[string]*ldapUser{
+		"unchanged": {user: newUser("unchanged", "Engineer", 0)},
+		"moved":     {user: newUser("moved", "Manager", 0)},
+		"returning": {user: newUser("returning", "Engineer", 0)},
+	}
+
+	changes := planUserSync(settings, []*model.User{unchanged, moved, removed, alreadyRemoved, returning, {Id: model.NewId()}}, entries)
+	require.Len(t, changes, 4)
+
+	byID := make(map[string]*userChange)
+	for _, change := range changes {
+		byID[change.user.Id] = change
+	}
+
+	assert.False(t, byID[unchanged.Id].updated)
+	assert.False(t, byID[unchanged.Id].deactivate)
+
+	assert.True(t, byID[moved.Id].updated)
+	assert.Equal(t, "Manager", byID[moved.Id].user.Position)
+	assert.Equal(t, "Engineer", moved.Position, "the users are not modified")
+
+	assert.True(t, byID[removed.Id].deactivate)
+	assert.NotContains(t, byID, alreadyRemoved.Id)
+
+	assert.True(t, byID[returning.Id].reactivate)
+	assert.False(t, byID[returning.Id].updated)
+}
+
+func TestPlanGroupMembers(t *testing.T) {
+	entry := ldap.NewEntry("cn=developers,ou=testgroups,"+testBaseDN, map[string][]string{
+		"member":    {"uid=test.one, ou=testusers," + testBaseDN, "uid=unknown,ou=testusers," + testBaseDN},
+		"memberUid": {"Test.Two"},
+	})
+	userIDs := map[string]string{
+		normalizeDN("uid=test.one,ou=testusers," + testBaseDN): "one",
+		"test.two":   "two",
+		"test.three": "three",
+	}
+
+	add, remove := planGroupMembers(entry, userIDs, []string{"two", "three", "former"})
+	assert.ElementsMatch(t, []string{"one"}, add)
+	assert.ElementsMatch(t, []string{"three", "former"}, remove)
+
+	add, remove = planGroupMembers(entry, userIDs, []string{"one", "two"})
+	assert.Empty(t, add)
+	assert.Empty(t, remove)
+}
+
+func TestDiagnostic(t *testing.T) {
+	server := newTestServer(t)
+	rctx := request.TestContext(t)
+
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	cfg.LdapSettings = *testSettings(server)
+	d := NewDiagnostic(func() *model.Config { return cfg }, nil)
+
+	t.Run("run test", func(t *testing.T) {
+		require.Nil(t, d.RunTest(rctx))
+
+		cfg.LdapSettings.UserFilter = model.NewPointer("(objectClass=nobody)")
+		defer func() { cfg.LdapSettings.UserFilter = model.NewPointer("(objectClass=inetOrgPerson)") }()
+
+		appErr := d.RunTest(rctx)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.ldap.no.users.checkcertificate", appErr.Id)
+	})
+
+	t.Run("run test connection", func(t *testing.T) {
+		settings := *testSettings(server)
+		require.Nil(t, d.RunTestConnection(rctx, settings))
+
+		settings.BindPassword = model.NewPointer("wrong")
+		appErr := d.RunTestConnection(rctx, settings)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.ldap.connection.test_failed", appErr.Id)
+	})
+
+	t.Run("vendor", func(t *testing.T) {
+		name, version, err := d.GetVendorNameAndVendorVersion(rctx)
+		require.NoError(t, err)
+		assert.Equal(t, ldaptest.VendorName, name)
+		assert.Equal(t, ldaptest.VendorVersion, version)
+	})
+
+	t.Run("filters", func(t *testing.T) {
+		settings := *testSettings(server)
+		settings.AdminFilter = model.NewPointer("(objectClass=mmAdmin)")
+
+		results, appErr := d.RunTestDiagnostics(rctx, model.LdapDiagnosticTestTypeFilters, settings)
+		require.Nil(t, appErr)
+		require.Len(t, results, 3)
+
+		assert.Equal(t, "UserFilter", results[0].TestName)
+		assert.Equal(t, 4, results[0].TotalCount)
+		assert.Len(t, results[0].SampleResults, 4)
+		assert.Equal(t, "GroupFilter", results[1].TestName)
+		assert.Equal(t, 2, results[1].TotalCount)
+		assert.Equal(t, "AdminFilter", results[2].TestName)
+		assert.Equal(t, 1, results[2].TotalCount)
+		assert.Equal(t, "test.two", results[2].SampleResults[0].Username)
+	})
+
+	t.Run("attributes", func(t *testing.T) {
+		settings := *testSettings(server)
+		settings.NicknameAttribute = model.NewPointer("displayName")
+
+		results, appErr := d.RunTestDiagnostics(rctx, model.LdapDiagnosticTestTypeAttributes, settings)
+		require.Nil(t, appErr)
+
+		byName := make(map[string]model.LdapDiagnosticResult)
+		for _, result := range results {
+			byName[result.TestName] = result
+		}
+		assert.Equal(t, 4, byName["EmailAttribute"].EntriesWithValue)
+		assert.Equal(t, 0, byName["NicknameAttribute"].EntriesWithValue)
+		assert.NotContains(t, byName, "PictureAttribute")
+	})
+}
@@ -0,0 +1,451 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package ldaptest provides an in-process LDAP server for the tests of the LDAP integration,
+// standing in for an OpenLDAP container. It supports simple binds against the userPassword of
+// its entries, and searches with the and, or, not, equality, presence and substrings filters,
+// which is what the LDAP integration relies on.
+package ldaptest
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+// Tags of the protocol operations
+const (
+	applicationBindRequest       = 0
+	applicationBindResponse      = 1
+	applicationUnbindRequest     = 2
+	applicationSearchRequest     = 3
+	applicationSearchResultEntry = 4
+	applicationSearchResultDone  = 5
+	applicationExtendedRequest   = 23
+	applicationExtendedResponse  = 24
+)
+
+// Tags of the search filters
+const (
+	filterAnd           = 0
+	filterOr            = 1
+	filterNot           = 2
+	filterEqualityMatch = 3
+	filterSubstrings    = 4
+	filterPresent       = 7
+
+	substringInitial = 0
+	substringAny     = 1
+	substringFinal   = 2
+)
+
+const (
+	scopeBaseObject   = 0
+	scopeSingleLevel  = 1
+	scopeWholeSubtree = 2
+)
+
+const (
+	resultSuccess            = 0
+	resultProtocolError      = 2
+	resultNoSuchObject       = 32
+	resultInvalidCredentials = 49
+	resultUnwillingToPerform = 53
+)
+
+// passwordAttribute holds the password an entry is bound to with
+const passwordAttribute = "userPassword"
+
+// VendorName and VendorVersion are advertised by the root DSE
+const (
+	VendorName    = "ldaptest"
+	VendorVersion = "1.0"
+)
+
+// Entry is an object of the directory
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// values returns the values of the attribute, whose name is case insensitive
+func (e *Entry) values(name string) []string {
+	for attr, values := range e.Attributes {
+		if strings.EqualFold(attr, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// Server is an LDAP server listening on the loopback interface
+type Server struct {
+	listener net.Listener
+
+	mut     sync.RWMutex
+	entries []*Entry
+	binds   int
+
+	wg sync.WaitGroup
+}
+
+// NewServer starts a server with no entries
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{listener: listener}
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Host is the address the server listens on
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+	return host
+}
+
+// Port is the port the server listens on
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	p, _ := strconv.Atoi(port)
+	return p
+}
+
+// Close stops accepting connections. The open connections are closed by their clients.
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+// AddEntry adds or replaces the entry with the given DN. An entry can be bound to with the
+// value of its userPassword attribute.
+func (s *Server) AddEntry(dn string, attributes map[string][]string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for i, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) {
+			s.entries[i] = &Entry{DN: dn, Attributes: attributes}
+			return
+		}
+	}
+	s.entries = append(s.entries, &Entry{DN: dn, Attributes: attributes})
+}
+
+// RemoveEntry removes the entry with the given DN
+func (s *Server) RemoveEntry(dn string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for i, entry := range s.entries {
+		if strings.EqualFold(entry.DN, dn) {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Binds is the number of successful binds received
+func (s *Server) Binds() int {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+	return s.binds
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	for {
+		packet, err := ber.ReadPacket(reader)
+		if err != nil {
+			return
+		}
+		if len(packet.Children) < 2 {
+			return
+		}
+
+		messageID, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		var responses []*ber.Packet
+		switch op.Tag {
+		case applicationBindRequest:
+			responses = []*ber.Packet{s.bind(op)}
+		case applicationUnbindRequest:
+			return
+		case applicationSearchRequest:
+			responses = s.search(op)
+		case applicationExtendedRequest:
+			// StartTLS and the other extended operations aren't supported
+			responses = []*ber.Packet{result(applicationExtendedResponse, resultUnwillingToPerform, "unsupported extended operation")}
+		default:
+			return
+		}
+
+		for _, response := range responses {
+			envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+			envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+			envelope.AppendChild(response)
+			if _, err := conn.Write(envelope.Bytes()); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func result(application ber.Tag, code int, message string) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, application, nil, "Result")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, "resultCode"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, "diagnosticMessage"))
+	return packet
+}
+
+func (s *Server) bind(op *ber.Packet) *ber.Packet {
+	if len(op.Children) < 3 {
+		return result(applicationBindResponse, resultProtocolError, "malformed bind request")
+	}
+
+	dn, _ := op.Children[1].Value.(string)
+	password := op.Children[2].Data.String()
+	if dn == "" && password == "" {
+		return result(applicationBindResponse, resultSuccess, "")
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	for _, entry := range s.entries {
+		if !strings.EqualFold(entry.DN, dn) {
+			continue
+		}
+		for _, value := range entry.values(passwordAttribute) {
+			if password != "" && value == password {
+				s.binds++
+				return result(applicationBindResponse, resultSuccess, "")
+			}
+		}
+	}
+
+	return result(applicationBindResponse, resultInvalidCredentials, "invalid credentials")
+}
+
+func (s *Server) search(op *ber.Packet) []*ber.Packet {
+	if len(op.Children) < 8 {
+		return []*ber.Packet{result(applicationSearchResultDone, resultProtocolError, "malformed search request")}
+	}
+
+	baseDN, _ := op.Children[0].Value.(string)
+	scope, _ := op.Children[1].Value.(int64)
+	filter := op.Children[6]
+	var attributes []string
+	for _, child := range op.Children[7].Children {
+		if name, ok := child.Value.(string); ok {
+			attributes = append(attributes, name)
+		}
+	}
+
+	if baseDN == "" && scope == scopeBaseObject {
+		rootDSE := &Entry{Attributes: map[string][]string{
+			"vendorName":    {VendorName},
+			"vendorVersion": {VendorVersion},
+		}}
+		return []*ber.Packet{searchResultEntry(rootDSE, attributes), result(applicationSearchResultDone, resultSuccess, "")}
+	}
+
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	var responses []*ber.Packet
+	baseFound := false
+	for _, entry := range s.entries {
+		if strings.EqualFold(entry.DN, baseDN) {
+			baseFound = true
+		}
+		if !inScope(entry.DN, baseDN, scope) || !matches(entry, filter) {
+			continue
+		}
+		responses = append(responses, searchResultEntry(entry, attributes))
+	}
+
+	if !baseFound && !s.hasSuffix(baseDN) {
+		return []*ber.Packet{result(applicationSearchResultDone, resultNoSuchObject, "no such object: "+baseDN)}
+	}
+
+	return append(responses, result(applicationSearchResultDone, resultSuccess, ""))
+}
+
+// hasSuffix tells whether some entry is under the base DN, so that searching a naming context
+// without an entry of its own succeeds like it does on the real servers
+func (s *Server) hasSuffix(baseDN string) bool {
+	for _, entry := range s.entries {
+		if inScope(entry.DN, baseDN, scopeWholeSubtree) {
+			return true
+		}
+	}
+	return false
+}
+
+func searchResultEntry(entry *Entry, attributes []string) *ber.Packet {
+	packet := ber.Encode(ber.ClassApplication, ber.TypeConstructed, applicationSearchResultEntry, nil, "Search Result Entry")
+	packet.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "objectName"))
+
+	all := len(attributes) == 0
+	for _, name := range attributes {
+		if name == "*" {
+			all = true
+		}
+	}
+
+	list := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attributes")
+	for name, values := range entry.Attributes {
+		if strings.EqualFold(name, passwordAttribute) {
+			continue
+		}
+		if !all && !containsFold(attributes, name) {
+			continue
+		}
+
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "vals")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		attribute.AppendChild(set)
+		list.AppendChild(attribute)
+	}
+	packet.AppendChild(list)
+
+	return packet
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// inScope tells whether the DN is within the scope of the search from the base DN
+func inScope(dn, baseDN string, scope int64) bool {
+	dn = strings.ToLower(dn)
+	baseDN = strings.ToLower(baseDN)
+
+	switch {
+	case dn == baseDN:
+		return scope != scopeSingleLevel
+	case scope == scopeBaseObject:
+		return false
+	case baseDN == "":
+		return scope != scopeSingleLevel || !strings.Contains(dn, ",")
+	case !strings.HasSuffix(dn, ","+baseDN):
+		return false
+	case scope == scopeSingleLevel:
+		return !strings.Contains(strings.TrimSuffix(dn, ","+baseDN), ",")
+	default:
+		return true
+	}
+}
+
+// matches evaluates the filter against the entry
+func matches(entry *Entry, filter *ber.Packet) bool {
+	switch filter.Tag {
+	case filterAnd:
+		for _, child := range filter.Children {
+			if !matches(entry, child) {
+				return false
+			}
+		}
+		return true
+	case filterOr:
+		for _, child := range filter.Children {
+			if matches(entry, child) {
+				return true
+			}
+		}
+		return false
+	case filterNot:
+		return len(filter.Children) == 1 && !matches(entry, filter.Children[0])
+	case filterEqualityMatch:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		name, _ := filter.Children[0].Value.(string)
+		value, _ := filter.Children[1].Value.(string)
+		if strings.EqualFold(name, "objectClass") && strings.EqualFold(value, "*") {
+			return true
+		}
+		for _, v := range entry.values(name) {
+			if strings.EqualFold(v, value) {
+				return true
+			}
+		}
+		return false
+	case filterPresent:
+		name := filter.Data.String()
+		return strings.EqualFold(name, "objectClass") || len(entry.values(name)) > 0
+	case filterSubstrings:
+		if len(filter.Children) != 2 {
+			return false
+		}
+		name, _ := filter.Children[0].Value.(string)
+		for _, v := range entry.values(name) {
+			if matchesSubstrings(strings.ToLower(v), filter.Children[1].Children) {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+func matchesSubstrings(value string, substrings []*ber.Packet) bool {
+	for _, substring := range substrings {
+		part := strings.ToLower(substring.Data.String())
+		switch substring.Tag {
+		case substringInitial:
+			if !strings.HasPrefix(value, part) {
+				return false
+			}
+			value = value[len(part):]
+		case substringAny:
+			i := strings.Index(value, part)
+			if i < 0 {
+				return false
+			}
+			value = value[i+len(part):]
+		case substringFinal:
+			if !strings.HasSuffix(value, part) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,407 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package ldap
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattermost/ldap"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	ejobs "github.com/mattermost/mattermost/server/v8/einterfaces/jobs"
+)
+
+const workerName = "LdapSync"
+
+// jobInterface builds the worker and scheduler of the ldap_sync job
+type jobInterface struct {
+	app *app.App
+}
+
+func (ji *jobInterface) MakeWorker() model.Worker {
+	return MakeWorker(ji.app.Srv().Jobs, New(ji.app))
+}
+
+func (ji *jobInterface) MakeScheduler() ejobs.Scheduler {
+	return MakeScheduler(ji.app.Srv().Jobs)
+}
+
+func isEnabled(cfg *model.Config) bool {
+	return *cfg.LdapSettings.EnableSync
+}
+
+// MakeScheduler runs the job every LdapSettings.SyncIntervalMinutes while the sync is enabled
+func MakeScheduler(jobServer *jobs.JobServer) *jobs.PeriodicScheduler {
+	interval := time.Duration(*jobServer.Config().LdapSettings.SyncIntervalMinutes) * time.Minute
+	return jobs.NewPeriodicScheduler(jobServer, model.JobTypeLdapSync, interval, isEnabled)
+}
+
+func MakeWorker(jobServer *jobs.JobServer, l *Ldap) *jobs.SimpleWorker {
+	execute := func(logger mlog.LoggerIFace, job *model.Job) error {
+		defer jobServer.HandleJobPanic(logger, job)
+
+		if job.Data == nil {
+			job.Data = make(model.StringMap)
+		}
+
+		s := &syncer{
+			ldap: l,
+			rctx: request.EmptyContext(logger),
+			progress: func(key string, count int) {
+				job.Data[key] = strconv.Itoa(count)
+				if err := jobServer.UpdateInProgressJobData(job); err != nil {
+					logger.Warn("Failed to update the progress of the job", mlog.Err(err))
+				}
+			},
+		}
+		return s.sync()
+	}
+	return jobs.NewSimpleWorker(workerName, jobServer, execute, isEnabled)
+}
+
+// ldapUser is a user entry passing the user filter
+type ldapUser struct {
+	user    *model.User
+	dn      string
+	isGuest bool
+	isAdmin bool
+}
+
+// userChange is what the sync does to a Mattermost user
+type userChange struct {
+	user       *model.User
+	ldapUser   *ldapUser
+	updated    bool
+	deactivate bool
+	reactivate bool
+}
+
+// planUserSync compares the LDAP users of Mattermost to their entries by AuthData. The users
+// without an entry are deactivated, and the deactivated users with one reactivated.
+func planUserSync(settings *model.LdapSettings, users []*model.User, entries map[string]*ldapUser) []*userChange {
+	changes := make([]*userChange, 0, len(users))
+	for _, user := range users {
+		if user.AuthData == nil {
+			continue
+		}
+
+		entry, ok := entries[*user.AuthData]
+		if !ok {
+			if user.DeleteAt == 0 {
+				changes = append(changes, &userChange{user: user, deactivate: true})
+			}
+			continue
+		}
+
+		updated := user.DeepCopy()
+		changes = append(changes, &userChange{
+			user:       updated,
+			ldapUser:   entry,
+			updated:    applyAttributes(settings, updated, entry.user),
+			reactivate: user.DeleteAt != 0,
+		})
+	}
+	return changes
+}
+
+// planGroupMembers compares the members of a group to the users listed by its entry. userIDs
+// maps the DNs and the usernames of the users to their Mattermost id.
+func planGroupMembers(groupEntry *ldap.Entry, userIDs map[string]string, current []string) (add []string, remove []string) {
+	desired := make(map[string]bool)
+	for _, attribute := range memberDNAttributes {
+		for _, dn := range groupEntry.GetAttributeValues(attribute) {
+			if id, ok := userIDs[normalizeDN(dn)]; ok {
+				desired[id] = true
+			}
+		}
+	}
+	for _, attribute := range memberUsernameAttributes {
+		for _, username := range groupEntry.GetAttributeValues(attribute) {
+			if id, ok := userIDs[strings.ToLower(username)]; ok {
+				desired[id] = true
+			}
+		}
+	}
+
+	existing := make(map[string]bool, len(current))
+	for _, id := range current {
+		existing[id] = true
+		if !desired[id] {
+			remove = append(remove, id)
+		}
+	}
+	for id := range desired {
+		if !existing[id] {
+			add = append(add, id)
+		}
+	}
+	return add, remove
+}
+
+// syncer updates the LDAP users from their entries, then the members of the linked groups,
+// then the teams and channels synced with them
+type syncer struct {
+	ldap     *Ldap
+	rctx     request.CTX
+	progress func(key string, count int)
+
+	conn     *ldap.Conn
+	settings *model.LdapSettings
+}
+
+func (s *syncer) sync() error {
+	s.settings = s.ldap.settings()
+
+	conn, appErr := s.ldap.connect()
+	if appErr != nil {
+		return appErr
+	}
+	defer conn.Close()
+	s.conn = conn
+
+	entries, appErr := s.readUsers()
+	if appErr != nil {
+		return appErr
+	}
+	s.progress("ldap_users", len(entries))
+
+	users, err := s.ldap.app.Srv().Store().User().GetAllUsingAuthService(model.UserAuthServiceLdap)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the LDAP users")
+	}
+
+	userIDs := s.syncUsers(users, entries)
+
+	if err := s.syncGroups(userIDs); err != nil {
+		return err
+	}
+
+	return s.syncMemberships()
+}
+
+// readUsers reads the entries passing the user filter, by AuthData
+func (s *syncer) readUsers() (map[string]*ldapUser, *model.AppError) {
+	entries, appErr := search(s.conn, s.settings, userFilter(s.settings), userAttributes(s.settings))
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	guests, appErr := s.matchingDNs(*s.settings.GuestFilter)
+	if appErr != nil {
+		return nil, appErr
+	}
+	var admins map[string]bool
+	if *s.settings.EnableAdminFilter {
+		if admins, appErr = s.matchingDNs(*s.settings.AdminFilter); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	users := make(map[string]*ldapUser, len(entries))
+	for _, entry := range entries {
+		user := userFromEntry(s.rctx.Logger(), s.settings, entry)
+		if *user.AuthData == "" {
+			continue
+		}
+		dn := normalizeDN(entry.DN)
+		users[*user.AuthData] = &ldapUser{user: user, dn: dn, isGuest: guests[dn], isAdmin: admins[dn]}
+	}
+	return users, nil
+}
+
+// matchingDNs is the set of the DNs of the users passing both the user filter and the filter
+func (s *syncer) matchingDNs(filter string) (map[string]bool, *model.AppError) {
+	dns := make(map[string]bool)
+	if filter == "" {
+		return dns, nil
+	}
+
+	entries, appErr := search(s.conn, s.settings, userFilter(s.settings, filter), []string{"1.1"})
+	if appErr != nil {
+		return nil, appErr
+	}
+	for _, entry := range entries {
+		dns[normalizeDN(entry.DN)] = true
+	}
+	return dns, nil
+}
+
+// syncUsers applies the changes to the users, and returns the ids of the active users by DN and
+// by username
+func (s *syncer) syncUsers(users []*model.User, entries map[string]*ldapUser) map[string]string {
+	a := s.ldap.app
+	userIDs := make(map[string]string, 2*len(entries))
+	var updated, deactivated int
+
+	for _, change := range planUserSync(s.settings, users, entries) {
+		logger := s.rctx.Logger().With(mlog.String("user_id", change.user.Id))
+		user := change.user
+
+		if change.deactivate {
+			if _, appErr := a.UpdateActive(s.rctx, user, false); appErr != nil {
+				logger.Warn("Failed to deactivate the LDAP user missing from the server", mlog.Err(appErr))
+			} else {
+				deactivated++
+			}
+			continue
+		}
+
+		// Reactivating the user saves its updated attributes as well
+		if change.reactivate {
+			reactivated, appErr := a.UpdateActive(s.rctx, user, true)
+			if appErr != nil {
+				logger.Warn("Failed to reactivate the LDAP user", mlog.Err(appErr))
+				continue
+			}
+			user = reactivated
+		} else if change.updated {
+			saved, appErr := a.UpdateUser(s.rctx, user, false)
+			if appErr != nil {
+				logger.Warn("Failed to update the LDAP user", mlog.Err(appErr))
+				continue
+			}
+			user = saved
+			updated++
+		}
+
+		if roled, appErr := s.ldap.updateRoles(s.rctx, user, change.ldapUser.isGuest, change.ldapUser.isAdmin); appErr != nil {
+			logger.Warn("Failed to update the roles of the LDAP user", mlog.Err(appErr))
+		} else {
+			user = roled
+		}
+
+		userIDs[change.ldapUser.dn] = user.Id
+		userIDs[strings.ToLower(user.Username)] = user.Id
+	}
+
+	s.progress("updated_users", updated)
+	s.progress("deactivated_users", deactivated)
+
+	return userIDs
+}
+
+// syncGroups updates the linked groups from their entries. The groups removed from the server
+// are deleted.
+func (s *syncer) syncGroups(userIDs map[string]string) error {
+	a := s.ldap.app
+
+	groups, appErr := a.GetGroupsBySource(model.GroupSourceLdap)
+	if appErr != nil {
+		return appErr
+	}
+
+	var synced, added, removed int
+	for _, group := range groups {
+		if group.DeleteAt != 0 || group.RemoteId == nil {
+			continue
+		}
+		logger := s.rctx.Logger().With(mlog.String("group_id", group.Id))
+
+		entry, appErr := findGroupEntry(s.conn, s.settings, *group.RemoteId)
+		if appErr != nil {
+			if appErr.Id == "ent.ldap_groups.no_rows" {
+				if _, appErr = a.DeleteGroup(group.Id); appErr != nil {
+					logger.Warn("Failed to delete the LDAP group missing from the server", mlog.Err(appErr))
+				}
+				continue
+			}
+			return appErr
+		}
+
+		if displayName := groupFromEntry(s.settings, entry).DisplayName; displayName != group.DisplayName {
+			group.DisplayName = displayName
+			if _, appErr = a.UpdateGroup(group); appErr != nil {
+				logger.Warn("Failed to update the display name of the LDAP group", mlog.Err(appErr))
+			}
+		}
+
+		members, appErr := a.GetGroupMemberUsers(group.Id)
+		if appErr != nil {
+			return appErr
+		}
+		current := make([]string, 0, len(members))
+		for _, member := range members {
+			current = append(current, member.Id)
+		}
+
+		toAdd, toRemove := planGroupMembers(entry, userIDs, current)
+		if len(toAdd) > 0 {
+			if _, appErr = a.UpsertGroupMembers(group.Id, toAdd); appErr != nil {
+				return appErr
+			}
+		}
+		if len(toRemove) > 0 {
+			if _, appErr = a.DeleteGroupMembers(group.Id, toRemove); appErr != nil {
+				return appErr
+			}
+		}
+
+		synced++
+		added += len(toAdd)
+		removed += len(toRemove)
+	}
+
+	s.progress("groups", synced)
+	s.progress("group_members_added", added)
+	s.progress("group_members_removed", removed)
+
+	return nil
+}
+
+// syncMemberships adds the group members to the teams and channels synced with their groups,
+// removes the others from the group constrained ones, and updates their roles
+func (s *syncer) syncMemberships() error {
+	a := s.ldap.app
+
+	var since int64
+	if lastJob, appErr := a.Srv().Jobs.GetLastSuccessfulJobByType(model.JobTypeLdapSync); appErr == nil && lastJob != nil {
+		since = lastJob.StartAt
+	}
+
+	params := model.CreateDefaultMembershipParams{Since: since, ReAddRemovedMembers: *s.settings.ReAddRemovedMembers}
+	if err := a.CreateDefaultMemberships(s.rctx, params); err != nil {
+		return errors.Wrap(err, "failed to create the default memberships")
+	}
+
+	if err := a.DeleteGroupConstrainedMemberships(s.rctx); err != nil {
+		return errors.Wrap(err, "failed to delete the group constrained memberships")
+	}
+
+	groups, appErr := a.GetGroupsBySource(model.GroupSourceLdap)
+	if appErr != nil {
+		return appErr
+	}
+
+	synced := make(map[string]bool)
+	for _, group := range groups {
+		if group.DeleteAt != 0 {
+			continue
+		}
+		for _, syncableType := range []model.GroupSyncableType{model.GroupSyncableTypeTeam, model.GroupSyncableTypeChannel} {
+			syncables, appErr := a.GetGroupSyncables(group.Id, syncableType)
+			if appErr != nil {
+				return appErr
+			}
+			for _, syncable := range syncables {
+				if synced[syncable.SyncableId] {
+					continue
+				}
+				synced[syncable.SyncableId] = true
+
+				if appErr := a.SyncSyncableRoles(s.rctx, syncable.SyncableId, syncableType); appErr != nil {
+					s.rctx.Logger().Warn("Failed to sync the roles of the syncable", mlog.String("syncable_id", syncable.SyncableId), mlog.Err(appErr))
+				}
+			}
+		}
+	}
+
+	return nil
+}
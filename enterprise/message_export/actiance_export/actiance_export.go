@@ -0,0 +1,211 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package actiance_export writes a batch as the XML conversations Actiance Vantage imports, next
+// to the attachments of the batch.
+package actiance_export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"path"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+)
+
+const (
+	FileName = "actiance_export.xml"
+
+	xmlNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+type FileDump struct {
+	XMLName  xml.Name `xml:"FileDump"`
+	XMLNS    string   `xml:"xmlns:xsi,attr"`
+	Channels []ChannelExport
+}
+
+type ChannelExport struct {
+	XMLName     xml.Name `xml:"Conversation"`
+	Perspective string   `xml:"Perspective,attr"`
+	ChannelId   string   `xml:"-"`
+	RoomId      string   `xml:"RoomID"`
+	StartTime   int64    `xml:"StartTimeUTC"`
+	Elements    []any
+	EndTime     int64 `xml:"EndTimeUTC"`
+}
+
+type JoinExport struct {
+	XMLName          xml.Name `xml:"ParticipantEntered"`
+	UserEmail        string   `xml:"LoginName"`
+	UserType         string   `xml:"UserType"`
+	JoinTime         int64    `xml:"DateTimeUTC"`
+	CorporateEmailID string   `xml:"CorporateEmailID"`
+}
+
+type LeaveExport struct {
+	XMLName          xml.Name `xml:"ParticipantLeft"`
+	UserEmail        string   `xml:"LoginName"`
+	UserType         string   `xml:"UserType"`
+	LeaveTime        int64    `xml:"DateTimeUTC"`
+	CorporateEmailID string   `xml:"CorporateEmailID"`
+}
+
+type PostExport struct {
+	XMLName        xml.Name `xml:"Message"`
+	UserEmail      string   `xml:"LoginName"`
+	UserType       string   `xml:"UserType"`
+	PostTime       int64    `xml:"DateTimeUTC"`
+	Message        string   `xml:"Content"`
+	PreviewsPost   string   `xml:"PreviewsPost"`
+	EditedByPostId string   `xml:"EditedByPostId,omitempty"`
+	PostId         string   `xml:"PostId"`
+	PostType       string   `xml:"PostType"`
+}
+
+type FileUploadStartExport struct {
+	XMLName         xml.Name `xml:"FileTransferStarted"`
+	UserEmail       string   `xml:"LoginName"`
+	UploadStartTime int64    `xml:"DateTimeUTC"`
+	Filename        string   `xml:"UserFileName"`
+	FilePath        string   `xml:"FileName"`
+}
+
+type FileUploadStopExport struct {
+	XMLName        xml.Name `xml:"FileTransferEnded"`
+	UserEmail      string   `xml:"LoginName"`
+	UploadStopTime int64    `xml:"DateTimeUTC"`
+	Filename       string   `xml:"UserFileName"`
+	FilePath       string   `xml:"FileName"`
+	Status         string   `xml:"Status"`
+}
+
+type Exporter struct{}
+
+func (Exporter) Export(rctx request.CTX, params *shared.ExportParams) (shared.ExportResults, error) {
+	var results shared.ExportResults
+
+	dump := FileDump{XMLNS: xmlNamespace}
+	for _, channel := range params.Channels {
+		conversation := ChannelExport{
+			Perspective: channel.ChannelDisplayName,
+			ChannelId:   channel.ChannelId,
+			RoomId:      roomID(channel),
+			StartTime:   channel.StartTime,
+			EndTime:     channel.EndTime,
+		}
+
+		for _, event := range channel.Events {
+			switch event.Type {
+			case shared.EventJoin:
+				conversation.Elements = append(conversation.Elements, JoinExport{
+					UserEmail:        event.User.Email,
+					UserType:         event.User.UserType(),
+					JoinTime:         event.Time,
+					CorporateEmailID: event.User.Email,
+				})
+			case shared.EventLeave:
+				conversation.Elements = append(conversation.Elements, LeaveExport{
+					UserEmail:        event.User.Email,
+					UserType:         event.User.UserType(),
+					LeaveTime:        event.Time,
+					CorporateEmailID: event.User.Email,
+				})
+			case shared.EventMessage, shared.EventEdit, shared.EventDelete:
+				message := event.Message
+				if event.Type == shared.EventDelete {
+					message = "delete " + message
+				}
+				conversation.Elements = append(conversation.Elements, PostExport{
+					UserEmail:      event.User.Email,
+					UserType:       event.User.UserType(),
+					PostTime:       event.Time,
+					Message:        message,
+					PreviewsPost:   event.PreviewsPostId,
+					EditedByPostId: event.EditedByPostId,
+					PostId:         event.PostId,
+					PostType:       event.PostType,
+				})
+				if event.Type != shared.EventDelete {
+					results.Messages++
+				}
+			case shared.EventFileUpload:
+				status := "Completed"
+				filePath, err := shared.CopyAttachment(params, event.File)
+				if err != nil {
+					rctx.Logger().Warn("Failed to export an attachment", mlog.String("file_id", event.File.Id), mlog.Err(err))
+					results.Warnings++
+					status = "Missing"
+				} else {
+					results.Files++
+				}
+				conversation.Elements = append(conversation.Elements,
+					FileUploadStartExport{
+						UserEmail:       event.User.Email,
+						UploadStartTime: event.Time,
+						Filename:        event.File.Name,
+						FilePath:        shared.AttachmentPath(event.File),
+					},
+					FileUploadStopExport{
+						UserEmail:      event.User.Email,
+						UploadStopTime: event.Time,
+						Filename:       event.File.Name,
+						FilePath:       filePath,
+						Status:         status,
+					},
+				)
+			case shared.EventFileDelete:
+				conversation.Elements = append(conversation.Elements, PostExport{
+					UserEmail:    event.User.Email,
+					UserType:     event.User.UserType(),
+					PostTime:     event.Time,
+					Message:      "delete " + event.File.Name,
+					PreviewsPost: event.PreviewsPostId,
+					PostId:       event.PostId,
+					PostType:     event.PostType,
+				})
+			}
+		}
+
+		dump.Channels = append(dump.Channels, conversation)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buf)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(dump); err != nil {
+		return results, errors.Wrap(err, "failed to encode the actiance export")
+	}
+
+	if _, err := params.ExportBackend.WriteFile(&buf, path.Join(params.BatchDir, FileName)); err != nil {
+		return results, errors.Wrap(err, "failed to save the actiance export")
+	}
+
+	return results, nil
+}
+
+// roomID identifies the conversation of a channel, prefixed with its type as Actiance expects
+func roomID(channel *shared.ChannelExport) string {
+	var prefix string
+	switch channel.ChannelType {
+	case model.ChannelTypeDirect:
+		prefix = "direct"
+	case model.ChannelTypeGroup:
+		prefix = "group"
+	case model.ChannelTypePrivate:
+		prefix = "private"
+	default:
+		prefix = "public"
+	}
+	name := channel.ChannelName
+	if name == "" {
+		name = channel.ChannelId
+	}
+	return prefix + " - " + name + " - " + channel.ChannelId
+}
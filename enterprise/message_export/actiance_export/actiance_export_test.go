@@ -0,0 +1,102 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package actiance_export
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+func TestExport(t *testing.T) {
+	newBackend := func() filestore.FileBackend {
+		backend, err := filestore.NewFileBackend(filestore.FileBackendSettings{DriverName: model.ImageDriverLocal, Directory: t.TempDir()})
+		require.NoError(t, err)
+		return backend
+	}
+	exportBackend := newBackend()
+	attachmentBackend := newBackend()
+	_, err := attachmentBackend.WriteFile(bytes.NewReader([]byte("report")), "data/report.pdf")
+	require.NoError(t, err)
+
+	alice := shared.User{Id: model.NewId(), Username: "alice", Email: "alice@example.com"}
+	bot := shared.User{Id: model.NewId(), Username: "bot", Email: "bot@example.com", IsBot: true}
+	file := &model.FileInfo{Id: model.NewId(), PostId: "post", Name: "report.pdf", Path: "data/report.pdf"}
+	params := &shared.ExportParams{
+		Channels: []*shared.ChannelExport{{
+			ChannelId:          "channel",
+			ChannelName:        "secret",
+			ChannelDisplayName: "Secret",
+			ChannelType:        model.ChannelTypePrivate,
+			StartTime:          1,
+			EndTime:            10,
+			Participants:       []shared.User{alice, bot},
+			Events: []*shared.Event{
+				{Type: shared.EventJoin, Time: 1, User: bot},
+				{Type: shared.EventMessage, Time: 2, User: alice, PostId: "post", Message: "<b>hello</b>", EditedByPostId: "edit"},
+				{Type: shared.EventFileUpload, Time: 2, User: alice, PostId: "post", File: file},
+				{Type: shared.EventDelete, Time: 5, User: alice, PostId: "post", Message: "hello"},
+				{Type: shared.EventLeave, Time: 6, User: bot},
+			},
+		}},
+		BatchDir:          "export/batch001",
+		ExportBackend:     exportBackend,
+		AttachmentBackend: attachmentBackend,
+	}
+
+	results, err := Exporter{}.Export(request.TestContext(t), params)
+	require.NoError(t, err)
+	assert.Equal(t, shared.ExportResults{Messages: 1, Files: 1}, results)
+
+	data, err := exportBackend.ReadFile("export/batch001/" + FileName)
+	require.NoError(t, err)
+
+	var dump struct {
+		Conversations []struct {
+			Perspective string `xml:"Perspective,attr"`
+			RoomID      string `xml:"RoomID"`
+			Entered     []struct {
+				LoginName string `xml:"LoginName"`
+				UserType  string `xml:"UserType"`
+			} `xml:"ParticipantEntered"`
+			Messages []struct {
+				Content        string `xml:"Content"`
+				EditedByPostId string `xml:"EditedByPostId"`
+			} `xml:"Message"`
+			Transfers []struct {
+				FileName string `xml:"FileName"`
+				Status   string `xml:"Status"`
+			} `xml:"FileTransferEnded"`
+			Left []struct {
+				DateTimeUTC int64 `xml:"DateTimeUTC"`
+			} `xml:"ParticipantLeft"`
+			EndTime int64 `xml:"EndTimeUTC"`
+		} `xml:"Conversation"`
+	}
+	require.NoError(t, xml.Unmarshal(data, &dump))
+	require.Len(t, dump.Conversations, 1)
+	conversation := dump.Conversations[0]
+	assert.Equal(t, "Secret", conversation.Perspective)
+	assert.Equal(t, "private - secret - channel", conversation.RoomID)
+	require.Len(t, conversation.Entered, 1)
+	assert.Equal(t, "bot", conversation.Entered[0].UserType)
+	require.Len(t, conversation.Messages, 2)
+	assert.Equal(t, "<b>hello</b>", conversation.Messages[0].Content)
+	assert.Equal(t, "edit", conversation.Messages[0].EditedByPostId)
+	assert.Equal(t, "delete hello", conversation.Messages[1].Content)
+	require.Len(t, conversation.Transfers, 1)
+	assert.Equal(t, shared.AttachmentPath(file), conversation.Transfers[0].FileName)
+	assert.Equal(t, "Completed", conversation.Transfers[0].Status)
+	require.Len(t, conversation.Left, 1)
+	assert.Equal(t, int64(6), conversation.Left[0].DateTimeUTC)
+	assert.Equal(t, int64(10), conversation.EndTime)
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package csv_export writes a batch as a CSV file with one row per event, next to the attachments
+// of the batch.
+package csv_export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+)
+
+const FileName = "csv_export.csv"
+
+var header = []string{
+	"Post Creation Time",
+	"Team Id",
+	"Team Name",
+	"Team Display Name",
+	"Channel Id",
+	"Channel Name",
+	"Channel Display Name",
+	"Channel Type",
+	"User Id",
+	"User Email",
+	"Username",
+	"Post Id",
+	"Edited By Post Id",
+	"Replied to Post Id",
+	"Post Message",
+	"Post Type",
+	"User Type",
+	"Previews Post Id",
+}
+
+type Exporter struct{}
+
+func (Exporter) Export(rctx request.CTX, params *shared.ExportParams) (shared.ExportResults, error) {
+	var results shared.ExportResults
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return results, errors.Wrap(err, "failed to write the csv header")
+	}
+
+	for _, channel := range params.Channels {
+		for _, event := range channel.Events {
+			postType, message := event.PostType, event.Message
+			switch event.Type {
+			case shared.EventJoin:
+				postType, message = "join", "User "+event.User.Username+" ("+event.User.Email+") joined the channel"
+			case shared.EventLeave:
+				postType, message = "leave", "User "+event.User.Username+" ("+event.User.Email+") left the channel"
+			case shared.EventMessage:
+				if postType == "" {
+					postType = "message"
+				}
+				results.Messages++
+			case shared.EventEdit:
+				postType = "edit"
+				results.Messages++
+			case shared.EventDelete:
+				postType = "deleted"
+			case shared.EventFileUpload:
+				postType = "attachment"
+				attachmentPath, err := shared.CopyAttachment(params, event.File)
+				if err != nil {
+					rctx.Logger().Warn("Failed to export an attachment", mlog.String("file_id", event.File.Id), mlog.Err(err))
+					results.Warnings++
+					message = event.File.Name
+				} else {
+					message = attachmentPath
+					results.Files++
+				}
+			case shared.EventFileDelete:
+				postType, message = "deleted attachment", event.File.Name
+			}
+
+			row := []string{
+				strconv.FormatInt(event.Time, 10),
+				channel.TeamId,
+				channel.TeamName,
+				channel.TeamDisplayName,
+				channel.ChannelId,
+				channel.ChannelName,
+				channel.ChannelDisplayName,
+				string(channel.ChannelType),
+				event.User.Id,
+				event.User.Email,
+				event.User.Username,
+				event.PostId,
+				event.EditedByPostId,
+				event.RootId,
+				message,
+				postType,
+				event.User.UserType(),
+				event.PreviewsPostId,
+			}
+			if err := w.Write(row); err != nil {
+				return results, errors.Wrap(err, "failed to write a csv row")
+			}
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return results, errors.Wrap(err, "failed to write the csv file")
+	}
+
+	if _, err := params.ExportBackend.WriteFile(&buf, path.Join(params.BatchDir, FileName)); err != nil {
+		return results, errors.Wrap(err, "failed to save the csv file")
+	}
+
+	return results, nil
+}
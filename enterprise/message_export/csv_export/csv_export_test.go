@@ -0,0 +1,75 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package csv_export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+func newBackend(t *testing.T) filestore.FileBackend {
+	backend, err := filestore.NewFileBackend(filestore.FileBackendSettings{DriverName: model.ImageDriverLocal, Directory: t.TempDir()})
+	require.NoError(t, err)
+	return backend
+}
+
+func TestExport(t *testing.T) {
+	exportBackend := newBackend(t)
+	attachmentBackend := newBackend(t)
+	_, err := attachmentBackend.WriteFile(bytes.NewReader([]byte("report")), "data/report.pdf")
+	require.NoError(t, err)
+
+	user := shared.User{Id: model.NewId(), Username: "alice", Email: "alice@example.com"}
+	found := &model.FileInfo{Id: model.NewId(), PostId: "post", Name: "report.pdf", Path: "data/report.pdf"}
+	missing := &model.FileInfo{Id: model.NewId(), PostId: "post", Name: "missing.pdf", Path: "data/missing.pdf"}
+	params := &shared.ExportParams{
+		Channels: []*shared.ChannelExport{{
+			ChannelId:   "channel",
+			ChannelName: "town-square",
+			ChannelType: model.ChannelTypeOpen,
+			TeamName:    "team",
+			Events: []*shared.Event{
+				{Type: shared.EventJoin, Time: 1, User: user},
+				{Type: shared.EventMessage, Time: 2, User: user, PostId: "post", Message: "hello, world"},
+				{Type: shared.EventFileUpload, Time: 2, User: user, PostId: "post", File: found},
+				{Type: shared.EventFileUpload, Time: 2, User: user, PostId: "post", File: missing},
+				{Type: shared.EventEdit, Time: 3, User: user, PostId: "post", Message: "hello"},
+			},
+		}},
+		BatchDir:          "export/batch001",
+		ExportBackend:     exportBackend,
+		AttachmentBackend: attachmentBackend,
+	}
+
+	results, err := Exporter{}.Export(request.TestContext(t), params)
+	require.NoError(t, err)
+	assert.Equal(t, shared.ExportResults{Messages: 2, Files: 1, Warnings: 1}, results)
+
+	data, err := exportBackend.ReadFile("export/batch001/" + FileName)
+	require.NoError(t, err)
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 6)
+	assert.Equal(t, header, rows[0])
+
+	// Post Message and Post Type
+	assert.Equal(t, []string{"User alice (alice@example.com) joined the channel", "join"}, rows[1][14:16])
+	assert.Equal(t, []string{"hello, world", "message"}, rows[2][14:16])
+	assert.Equal(t, []string{shared.AttachmentPath(found), "attachment"}, rows[3][14:16])
+	assert.Equal(t, []string{"missing.pdf", "attachment"}, rows[4][14:16])
+	assert.Equal(t, []string{"hello", "edit"}, rows[5][14:16])
+
+	copied, err := exportBackend.ReadFile("export/batch001/" + shared.AttachmentPath(found))
+	require.NoError(t, err)
+	assert.Equal(t, "report", string(copied))
+}
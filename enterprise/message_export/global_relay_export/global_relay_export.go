@@ -0,0 +1,295 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package global_relay_export writes a batch as one email per channel, which is either delivered
+// to GlobalRelay over SMTP or saved in a zip file for a manual upload.
+package global_relay_export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"io"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	gomail "gopkg.in/mail.v2"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/mail"
+)
+
+const (
+	ZipFileName = "global_relay_export.zip"
+
+	a9Server  = "mailarchivesa9.globalrelay.com"
+	a10Server = "feeds.globalrelay.com"
+	smtpPort  = "25"
+
+	// fallbackSender is used for channels without any participant, which can't have events
+	// anyway, and for participants without an email address
+	fallbackSender = "no-reply@mattermost.com"
+)
+
+// SMTPConfig returns how to reach the GlobalRelay server of the customer type
+func SMTPConfig(settings *model.GlobalRelayMessageExportSettings) *mail.SMTPConfig {
+	config := &mail.SMTPConfig{
+		ConnectionSecurity: mail.StartTLS,
+		Port:               smtpPort,
+		ServerTimeout:      *settings.SMTPServerTimeout,
+		Username:           *settings.SMTPUsername,
+		Password:           *settings.SMTPPassword,
+		EnableSMTPAuth:     true,
+	}
+	switch *settings.CustomerType {
+	case model.GlobalrelayCustomerTypeA9:
+		config.Server = a9Server
+	case model.GlobalrelayCustomerTypeA10:
+		config.Server = a10Server
+	case model.GlobalrelayCustomerTypeCustom:
+		config.Server = *settings.CustomSMTPServerName
+		config.Port = *settings.CustomSMTPPort
+	}
+	config.ServerName = config.Server
+	return config
+}
+
+// SMTPExporter delivers the emails of a batch to the GlobalRelay archive
+type SMTPExporter struct {
+	Config *mail.SMTPConfig
+	To     string
+}
+
+func (e SMTPExporter) Export(rctx request.CTX, params *shared.ExportParams) (shared.ExportResults, error) {
+	emails, results := buildEmails(rctx, params)
+	if len(emails) == 0 {
+		return results, nil
+	}
+
+	conn, err := mail.ConnectToSMTPServer(e.Config)
+	if err != nil {
+		return results, errors.Wrap(err, "failed to connect to the GlobalRelay SMTP server")
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(e.Config.ServerTimeout)*time.Second)
+	defer cancel()
+	client, err := mail.NewSMTPClient(ctx, conn, e.Config)
+	if err != nil {
+		return results, errors.Wrap(err, "failed to connect to the GlobalRelay SMTP server")
+	}
+	defer client.Close()
+
+	for _, email := range emails {
+		if err := client.Mail(email.from); err != nil {
+			return results, errors.Wrapf(err, "failed to send the email of channel %s", email.channelID)
+		}
+		if err := client.Rcpt(e.To); err != nil {
+			return results, errors.Wrapf(err, "failed to send the email of channel %s", email.channelID)
+		}
+		w, err := client.Data()
+		if err != nil {
+			return results, errors.Wrapf(err, "failed to send the email of channel %s", email.channelID)
+		}
+		if _, err := email.message.WriteTo(w); err != nil {
+			w.Close()
+			return results, errors.Wrapf(err, "failed to send the email of channel %s", email.channelID)
+		}
+		if err := w.Close(); err != nil {
+			return results, errors.Wrapf(err, "failed to send the email of channel %s", email.channelID)
+		}
+	}
+
+	if err := client.Quit(); err != nil {
+		rctx.Logger().Warn("Failed to close the connection to the GlobalRelay SMTP server", mlog.Err(err))
+	}
+
+	return results, nil
+}
+
+// ZipExporter saves the emails of a batch in a zip file of the batch directory
+type ZipExporter struct{}
+
+func (ZipExporter) Export(rctx request.CTX, params *shared.ExportParams) (shared.ExportResults, error) {
+	emails, results := buildEmails(rctx, params)
+
+	// The zip is streamed to the export backend rather than held in memory since the attachments
+	// are part of it
+	pr, pw := io.Pipe()
+	go func() {
+		zw := zip.NewWriter(pw)
+		for _, email := range emails {
+			w, err := zw.Create(email.fileName)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := email.message.WriteTo(w); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(zw.Close())
+	}()
+
+	if _, err := params.ExportBackend.WriteFile(pr, path.Join(params.BatchDir, ZipFileName)); err != nil {
+		pr.CloseWithError(err)
+		return results, errors.Wrap(err, "failed to save the GlobalRelay zip file")
+	}
+
+	return results, nil
+}
+
+type email struct {
+	channelID string
+	fileName  string
+	from      string
+	message   *gomail.Message
+}
+
+type messageRow struct {
+	Time     string
+	Username string
+	Email    string
+	UserType string
+	Action   string
+	Message  string
+}
+
+type channelBody struct {
+	Channel      *shared.ChannelExport
+	StartTime    string
+	EndTime      string
+	Participants []shared.User
+	Rows         []messageRow
+}
+
+var bodyTemplate = template.Must(template.New("body").Parse(`<html>
+<body>
+<h2>{{.Channel.ChannelDisplayName}}</h2>
+<p>Team: {{.Channel.TeamDisplayName}}<br>Channel: {{.Channel.ChannelName}} ({{.Channel.ChannelId}})<br>From {{.StartTime}} to {{.EndTime}}</p>
+<h3>Participants</h3>
+<ul>
+{{- range .Participants}}
+<li>{{.Username}} ({{.Email}}, {{.UserType}})</li>
+{{- end}}
+</ul>
+<h3>Messages</h3>
+<table>
+<tr><th>Time</th><th>User</th><th>Action</th><th>Message</th></tr>
+{{- range .Rows}}
+<tr><td>{{.Time}}</td><td>{{.Username}} ({{.Email}}, {{.UserType}})</td><td>{{.Action}}</td><td>{{.Message}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+func formatTime(millis int64) string {
+	return time.UnixMilli(millis).UTC().Format(time.RFC1123Z)
+}
+
+// buildEmails builds an email for each channel with events. Attachments which can't be found in
+// the file backend are counted as warnings and left out of the email.
+func buildEmails(rctx request.CTX, params *shared.ExportParams) ([]*email, shared.ExportResults) {
+	var results shared.ExportResults
+	var emails []*email
+
+	for _, channel := range params.Channels {
+		if len(channel.Events) == 0 {
+			continue
+		}
+
+		body := channelBody{
+			Channel:      channel,
+			StartTime:    formatTime(channel.StartTime),
+			EndTime:      formatTime(channel.EndTime),
+			Participants: channel.Participants,
+		}
+		message := gomail.NewMessage(gomail.SetCharset("UTF-8"))
+
+		for _, event := range channel.Events {
+			row := messageRow{
+				Time:     formatTime(event.Time),
+				Username: event.User.Username,
+				Email:    event.User.Email,
+				UserType: event.User.UserType(),
+				Action:   string(event.Type),
+				Message:  event.Message,
+			}
+			switch event.Type {
+			case shared.EventJoin:
+				row.Message = "joined the channel"
+			case shared.EventLeave:
+				row.Message = "left the channel"
+			case shared.EventMessage, shared.EventEdit:
+				results.Messages++
+			case shared.EventFileUpload:
+				row.Message = event.File.Name
+				file := event.File
+				exists, err := params.AttachmentBackend.FileExists(file.Path)
+				if err == nil && !exists {
+					err = errors.New("the file doesn't exist")
+				}
+				if err != nil {
+					rctx.Logger().Warn("Failed to export an attachment", mlog.String("file_id", file.Id), mlog.Err(err))
+					results.Warnings++
+					break
+				}
+				message.Attach(path.Base(shared.AttachmentPath(file)), gomail.SetCopyFunc(func(w io.Writer) error {
+					return shared.WriteAttachment(params, file, w)
+				}))
+				results.Files++
+			case shared.EventFileDelete:
+				row.Message = event.File.Name
+			}
+			body.Rows = append(body.Rows, row)
+		}
+
+		var html bytes.Buffer
+		if err := bodyTemplate.Execute(&html, body); err != nil {
+			// The template only fails when it can't write to the buffer
+			rctx.Logger().Warn("Failed to render the GlobalRelay email", mlog.String("channel_id", channel.ChannelId), mlog.Err(err))
+			results.Warnings++
+			continue
+		}
+
+		from := fallbackSender
+		to := make([]string, 0, len(channel.Participants))
+		for _, participant := range channel.Participants {
+			if participant.Email == "" {
+				continue
+			}
+			if from == fallbackSender {
+				from = participant.Email
+			}
+			to = append(to, participant.Email)
+		}
+		if len(to) == 0 {
+			to = append(to, fallbackSender)
+		}
+
+		message.SetHeader("From", from)
+		message.SetHeader("To", to...)
+		message.SetHeader("Subject", fmt.Sprintf("Mattermost Compliance Export: %s", channel.ChannelDisplayName))
+		message.SetHeader("X-Mattermost-ChannelType", string(channel.ChannelType))
+		message.SetHeader("X-Mattermost-ChannelID", channel.ChannelId)
+		message.SetDateHeader("Date", time.UnixMilli(channel.EndTime))
+		message.SetBody("text/html", html.String())
+
+		emails = append(emails, &email{
+			channelID: channel.ChannelId,
+			fileName:  path.Join(channel.ChannelId, fmt.Sprintf("%d-%d.eml", channel.StartTime, channel.EndTime)),
+			from:      from,
+			message:   message,
+		})
+	}
+
+	return emails, results
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package global_relay_export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"mime/quotedprintable"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/mail"
+)
+
+func newParams(t *testing.T) (*shared.ExportParams, *model.FileInfo) {
+	newBackend := func() filestore.FileBackend {
+		backend, err := filestore.NewFileBackend(filestore.FileBackendSettings{DriverName: model.ImageDriverLocal, Directory: t.TempDir()})
+		require.NoError(t, err)
+		return backend
+	}
+	attachmentBackend := newBackend()
+	_, err := attachmentBackend.WriteFile(bytes.NewReader([]byte("report")), "data/report.pdf")
+	require.NoError(t, err)
+
+	alice := shared.User{Id: model.NewId(), Username: "alice", Email: "alice-" + model.NewId() + "@example.com"}
+	bob := shared.User{Id: model.NewId(), Username: "bob", Email: "bob@example.com"}
+	file := &model.FileInfo{Id: model.NewId(), PostId: "post", Name: "report.pdf", Path: "data/report.pdf"}
+	missing := &model.FileInfo{Id: model.NewId(), PostId: "post", Name: "missing.pdf", Path: "data/missing.pdf"}
+
+	return &shared.ExportParams{
+		Channels: []*shared.ChannelExport{
+			{
+				ChannelId:          "channel",
+				ChannelName:        "town-square",
+				ChannelDisplayName: "Town Square",
+				ChannelType:        model.ChannelTypeOpen,
+				StartTime:          1000,
+				EndTime:            5000,
+				Participants:       []shared.User{alice, bob},
+				Events: []*shared.Event{
+					{Type: shared.EventJoin, Time: 1000, User: bob},
+					{Type: shared.EventMessage, Time: 2000, User: alice, PostId: "post", Message: "<script>hello</script>"},
+					{Type: shared.EventFileUpload, Time: 2000, User: alice, PostId: "post", File: file},
+					{Type: shared.EventFileUpload, Time: 2000, User: alice, PostId: "post", File: missing},
+				},
+			},
+			// Channels without events aren't sent
+			{ChannelId: "quiet", Participants: []shared.User{bob}},
+		},
+		BatchDir:          "export/batch001",
+		ExportBackend:     newBackend(),
+		AttachmentBackend: attachmentBackend,
+	}, file
+}
+
+func TestZipExporter(t *testing.T) {
+	params, file := newParams(t)
+
+	results, err := ZipExporter{}.Export(request.TestContext(t), params)
+	require.NoError(t, err)
+	assert.Equal(t, shared.ExportResults{Messages: 1, Files: 1, Warnings: 1}, results)
+
+	data, err := params.ExportBackend.ReadFile("export/batch001/" + ZipFileName)
+	require.NoError(t, err)
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, zr.File, 1)
+	assert.Equal(t, "channel/1000-5000.eml", zr.File[0].Name)
+
+	f, err := zr.File[0].Open()
+	require.NoError(t, err)
+	defer f.Close()
+	eml, err := io.ReadAll(quotedprintable.NewReader(f))
+	require.NoError(t, err)
+
+	alice := params.Channels[0].Participants[0]
+	assert.Contains(t, string(eml), "From: "+alice.Email)
+	assert.Contains(t, string(eml), "Subject: Mattermost Compliance Export: Town Square")
+	assert.Contains(t, string(eml), "X-Mattermost-ChannelID: channel")
+	assert.Contains(t, string(eml), "&lt;script&gt;hello&lt;/script&gt;")
+	// The missing attachment is left out
+	assert.Equal(t, 1, strings.Count(string(eml), "Content-Disposition: attachment"))
+	assert.Contains(t, string(eml), file.Id+"-report.pdf")
+}
+
+func TestSMTPConfig(t *testing.T) {
+	settings := &model.GlobalRelayMessageExportSettings{}
+	settings.SetDefaults()
+
+	config := SMTPConfig(settings)
+	assert.Equal(t, a9Server, config.Server)
+	assert.Equal(t, "25", config.Port)
+	assert.True(t, config.EnableSMTPAuth)
+
+	settings.CustomerType = model.NewPointer(model.GlobalrelayCustomerTypeCustom)
+	settings.CustomSMTPServerName = model.NewPointer("smtp.example.com")
+	settings.CustomSMTPPort = model.NewPointer("2525")
+	config = SMTPConfig(settings)
+	assert.Equal(t, "smtp.example.com", config.Server)
+	assert.Equal(t, "2525", config.Port)
+}
+
+func TestSMTPExporter(t *testing.T) {
+	server := os.Getenv("MM_EMAILSETTINGS_SMTPSERVER")
+	if server == "" {
+		server = "localhost"
+	}
+	port := os.Getenv("MM_EMAILSETTINGS_SMTPPORT")
+	if port == "" {
+		port = "10025"
+	}
+	config := &mail.SMTPConfig{
+		Hostname:      "localhost",
+		ServerName:    server,
+		Server:        server,
+		Port:          port,
+		ServerTimeout: 10,
+	}
+	if err := mail.TestConnection(config); err != nil {
+		t.Skipf("no SMTP server available: %v", err)
+	}
+
+	params, _ := newParams(t)
+	to := "globalrelay-" + model.NewId() + "@example.com"
+	t.Cleanup(func() { mail.DeleteMailBox(to) })
+
+	results, err := SMTPExporter{Config: config, To: to}.Export(request.TestContext(t), params)
+	require.NoError(t, err)
+	assert.Equal(t, shared.ExportResults{Messages: 1, Files: 1, Warnings: 1}, results)
+
+	var mailbox mail.JSONMessageHeaderInbucket
+	require.NoError(t, mail.RetryInbucket(5, func() error {
+		var err error
+		mailbox, err = mail.GetMailBox(to)
+		return err
+	}))
+	require.Len(t, mailbox, 1)
+	assert.Equal(t, "Mattermost Compliance Export: Town Square", mailbox[0].Subject)
+
+	message, err := mail.GetMessageFromMailbox(to, mailbox[0].ID)
+	require.NoError(t, err)
+	require.Len(t, message.Attachments, 1)
+	assert.Equal(t, "report", string(message.Attachments[0].Bytes))
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package message_export implements the message_export job, which exports the posts, edits,
+// deletions, attachments and channel membership changes since the previous run in the format
+// configured in MessageExportSettings, one batch of posts at a time.
+package message_export
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+	ejobs "github.com/mattermost/mattermost/server/v8/einterfaces/jobs"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+)
+
+func init() {
+	app.RegisterMessageExportInterface(func(a *app.App) einterfaces.MessageExportInterface {
+		return &MessageExport{jobServer: a.Srv().Jobs}
+	})
+	app.RegisterJobsMessageExportJobInterface(func(s *app.Server) ejobs.MessageExportJobInterface {
+		return &jobInterface{server: s}
+	})
+}
+
+// MessageExport starts message_export jobs on demand
+type MessageExport struct {
+	jobServer *jobs.JobServer
+}
+
+// StartSynchronizeJob creates a message_export job. When exportFromTimestamp is set, the job
+// exports from then rather than from the end of the previous job.
+func (me *MessageExport) StartSynchronizeJob(rctx request.CTX, exportFromTimestamp int64) (*model.Job, *model.AppError) {
+	data := map[string]string{}
+	if exportFromTimestamp > 0 {
+		data[shared.JobDataJobStartTime] = strconv.FormatInt(exportFromTimestamp, 10)
+	}
+
+	job, appErr := me.jobServer.CreateJob(rctx, model.JobTypeMessageExport, data)
+	if appErr != nil {
+		return nil, model.NewAppError("StartSynchronizeJob", "ent.message_export.start_synchronize_job.app_error", nil, "", http.StatusInternalServerError).Wrap(appErr)
+	}
+	return job, nil
+}
+
+// jobInterface builds the worker and scheduler of the message_export job
+type jobInterface struct {
+	server *app.Server
+}
+
+func (ji *jobInterface) MakeWorker() model.Worker {
+	return MakeWorker(ji.server.Jobs, ji.server.Store(), ji.server.ExportFileBackend, ji.server.FileBackend)
+}
+
+func (ji *jobInterface) MakeScheduler() ejobs.Scheduler {
+	return MakeScheduler(ji.server.Jobs)
+}
+
+// MakeScheduler runs the job every day at MessageExportSettings.DailyRunTime
+func MakeScheduler(jobServer *jobs.JobServer) *jobs.DailyScheduler {
+	startTime := func(cfg *model.Config) *time.Time {
+		parsedTime, err := time.Parse("15:04", *cfg.MessageExportSettings.DailyRunTime)
+		if err == nil {
+			return &parsedTime
+		}
+		return nil
+	}
+	isEnabled := func(cfg *model.Config) bool {
+		return *cfg.MessageExportSettings.EnableExport
+	}
+	return jobs.NewDailyScheduler(jobServer, model.JobTypeMessageExport, startTime, isEnabled)
+}
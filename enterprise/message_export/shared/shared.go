@@ -1,16 +1,358 @@
 // Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
 // See LICENSE.enterprise for license information.
 
+// Package shared holds what the message export job and its formatters have in common: the job data
+// keys and the per channel view of a batch that every format is written from.
 package shared
 
+import (
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
 // Job data keys for message export
 const (
-	JobDataInitiatedBy     = "initiated_by"
-	JobDataExportType      = "export_type"
-	JobDataBatchStartId    = "batch_start_id"
-	JobDataJobStartId      = "job_start_id"
-	JobDataBatchStartTime  = "batch_start_time"
-	JobDataJobStartTime    = "job_start_time"
-	JobDataJobEndTime      = "job_end_time"
-	JobDataExportDir       = "export_dir"
+	JobDataInitiatedBy      = "initiated_by"
+	JobDataExportType       = "export_type"
+	JobDataBatchStartId     = "batch_start_id"
+	JobDataJobStartId       = "job_start_id"
+	JobDataBatchStartTime   = "batch_start_time"
+	JobDataJobStartTime     = "job_start_time"
+	JobDataJobEndTime       = "job_end_time"
+	JobDataExportDir        = "export_dir"
+	JobDataBatchNumber      = "batch_number"
+	JobDataMessagesExported = "messages_exported"
+	JobDataWarningCount     = "warning_count"
+	JobDataIsDownloadable   = "is_downloadable"
 )
+
+// EventType is what happened in a channel during the exported period
+type EventType string
+
+const (
+	// EventJoin and EventLeave come from the channel member history
+	EventJoin  EventType = "join"
+	EventLeave EventType = "leave"
+
+	// EventMessage is a post created during the period. When the post was edited afterwards,
+	// EditedByPostId is the id of the post holding the new message.
+	EventMessage EventType = "message"
+
+	// EventEdit is a post edited during the period, with its new message
+	EventEdit EventType = "edit"
+
+	// EventDelete is a post deleted during the period, with the message it had
+	EventDelete EventType = "delete"
+
+	// EventFileUpload and EventFileDelete are attachments of the posts
+	EventFileUpload EventType = "file_upload"
+	EventFileDelete EventType = "file_delete"
+)
+
+// User is the author of an event
+type User struct {
+	Id       string
+	Username string
+	Email    string
+	IsBot    bool
+}
+
+// UserType is how the export formats describe the user
+func (u User) UserType() string {
+	if u.IsBot {
+		return "bot"
+	}
+	return "user"
+}
+
+// Event is a single entry of a channel's export
+type Event struct {
+	Type EventType
+	Time int64
+	User User
+
+	PostId         string
+	RootId         string
+	EditedByPostId string
+	PreviewsPostId string
+	PostType       string
+	Message        string
+
+	File *model.FileInfo
+}
+
+// ChannelExport is what happened in a channel during a batch, in chronological order
+type ChannelExport struct {
+	ChannelId          string
+	ChannelName        string
+	ChannelDisplayName string
+	ChannelType        model.ChannelType
+	TeamId             string
+	TeamName           string
+	TeamDisplayName    string
+
+	StartTime int64
+	EndTime   int64
+
+	// Participants are the users who were members of the channel at some point of the batch
+	Participants []User
+	Events       []*Event
+}
+
+// Batch is everything read from the database for a batch. StartTime and EndTime bound the
+// membership events; posts are exported whatever their time since they were selected by the
+// time they were last updated at, and each post is only read once per job.
+type Batch struct {
+	Number    int
+	StartTime int64
+	EndTime   int64
+
+	// JobStartTime tells apart the posts created, edited or deleted during the job from the ones
+	// only updated during the job
+	JobStartTime int64
+
+	Posts     []*model.MessageExport
+	Histories []*model.ChannelMemberHistoryResult
+	Channels  map[string]*model.Channel
+	Teams     map[string]*model.Team
+	Files     map[string][]*model.FileInfo
+}
+
+// Name is the directory of the batch in the export
+func (b *Batch) Name() string {
+	return fmt.Sprintf("batch%03d-%d-%d", b.Number, b.StartTime, b.EndTime)
+}
+
+func (b *Batch) inWindow(t int64) bool {
+	return t >= b.StartTime && t <= b.EndTime
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func derefInt64(i *int64) int64 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+// BuildChannelExports groups the posts and membership events of the batch by channel
+func BuildChannelExports(batch *Batch) []*ChannelExport {
+	exports := make(map[string]*ChannelExport)
+	getExport := func(channelID string) *ChannelExport {
+		if export, ok := exports[channelID]; ok {
+			return export
+		}
+		export := &ChannelExport{
+			ChannelId: channelID,
+			StartTime: batch.StartTime,
+			EndTime:   batch.EndTime,
+		}
+		if channel, ok := batch.Channels[channelID]; ok {
+			export.ChannelName = channel.Name
+			export.ChannelDisplayName = channel.DisplayName
+			export.ChannelType = channel.Type
+			export.TeamId = channel.TeamId
+			if team, ok := batch.Teams[channel.TeamId]; ok {
+				export.TeamName = team.Name
+				export.TeamDisplayName = team.DisplayName
+			}
+		}
+		exports[channelID] = export
+		return export
+	}
+
+	for _, post := range batch.Posts {
+		export := getExport(derefString(post.ChannelId))
+		// The display name of direct and group messages is computed by the query
+		if post.ChannelDisplayName != nil {
+			export.ChannelDisplayName = *post.ChannelDisplayName
+		}
+		export.Events = append(export.Events, postEvents(batch, post)...)
+	}
+
+	participants := make(map[string]map[string]bool)
+	for _, history := range batch.Histories {
+		export := getExport(history.ChannelId)
+		user := User{Id: history.UserId, Username: history.Username, Email: history.UserEmail, IsBot: history.IsBot}
+
+		if participants[history.ChannelId] == nil {
+			participants[history.ChannelId] = make(map[string]bool)
+		}
+		if !participants[history.ChannelId][user.Id] {
+			participants[history.ChannelId][user.Id] = true
+			export.Participants = append(export.Participants, user)
+		}
+
+		if batch.inWindow(history.JoinTime) {
+			export.Events = append(export.Events, &Event{Type: EventJoin, Time: history.JoinTime, User: user})
+		}
+		if history.LeaveTime != nil && batch.inWindow(*history.LeaveTime) {
+			export.Events = append(export.Events, &Event{Type: EventLeave, Time: *history.LeaveTime, User: user})
+		}
+	}
+
+	result := make([]*ChannelExport, 0, len(exports))
+	for _, export := range exports {
+		// Joins come first and leaves last when they happen at the same time as other events
+		sort.SliceStable(export.Events, func(i, j int) bool {
+			if export.Events[i].Time != export.Events[j].Time {
+				return export.Events[i].Time < export.Events[j].Time
+			}
+			return eventOrder(export.Events[i].Type) < eventOrder(export.Events[j].Type)
+		})
+		result = append(result, export)
+	}
+	slices.SortFunc(result, func(a, b *ChannelExport) int {
+		return strings.Compare(a.ChannelId, b.ChannelId)
+	})
+
+	return result
+}
+
+func eventOrder(eventType EventType) int {
+	switch eventType {
+	case EventJoin:
+		return 0
+	case EventLeave:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// postEvents returns the events of a post. Editing a post saves its previous version as a deleted
+// post whose OriginalId is the id of the edited post, so previous versions are exported as the
+// message created at the time of the post rather than as deletions.
+func postEvents(batch *Batch, post *model.MessageExport) []*Event {
+	user := User{
+		Id:       derefString(post.UserId),
+		Username: derefString(post.Username),
+		Email:    derefString(post.UserEmail),
+		IsBot:    post.IsBot,
+	}
+	newEvent := func(eventType EventType, time int64) *Event {
+		return &Event{
+			Type:           eventType,
+			Time:           time,
+			User:           user,
+			PostId:         derefString(post.PostId),
+			RootId:         derefString(post.PostRootId),
+			PreviewsPostId: post.PreviewID(),
+			PostType:       derefString(post.PostType),
+			Message:        derefString(post.PostMessage),
+		}
+	}
+
+	createAt := derefInt64(post.PostCreateAt)
+	editAt := derefInt64(post.PostEditAt)
+	deleteAt := derefInt64(post.PostDeleteAt)
+	createdInBatch := createAt >= batch.JobStartTime
+
+	var events []*Event
+	if originalID := derefString(post.PostOriginalId); originalID != "" {
+		if createdInBatch {
+			event := newEvent(EventMessage, createAt)
+			event.EditedByPostId = originalID
+			events = append(events, event)
+		}
+		return events
+	}
+
+	editedInBatch := editAt > 0 && editAt >= batch.JobStartTime
+	if editedInBatch {
+		events = append(events, newEvent(EventEdit, editAt))
+	} else if createdInBatch {
+		events = append(events, newEvent(EventMessage, createAt))
+	}
+
+	for _, file := range batch.Files[derefString(post.PostId)] {
+		if createdInBatch {
+			event := newEvent(EventFileUpload, createAt)
+			event.File = file
+			events = append(events, event)
+		}
+		if file.DeleteAt > 0 && file.DeleteAt >= batch.JobStartTime {
+			event := newEvent(EventFileDelete, file.DeleteAt)
+			event.File = file
+			events = append(events, event)
+		}
+	}
+
+	if deleteAt > 0 && deleteAt >= batch.JobStartTime {
+		events = append(events, newEvent(EventDelete, deleteAt))
+	}
+
+	return events
+}
+
+// ExportParams is what a formatter needs to export a batch
+type ExportParams struct {
+	Batch    *Batch
+	Channels []*ChannelExport
+
+	// BatchDir is the directory of the batch in ExportBackend
+	BatchDir          string
+	ExportBackend     filestore.FileBackend
+	AttachmentBackend filestore.FileBackend
+}
+
+// ExportResults counts what a formatter exported
+type ExportResults struct {
+	Messages int
+	Files    int
+
+	// Warnings counts the attachments which couldn't be read from the file backend
+	Warnings int
+}
+
+// Exporter writes a batch in one of the export formats
+type Exporter interface {
+	Export(rctx request.CTX, params *ExportParams) (ExportResults, error)
+}
+
+// AttachmentPath is where an attachment is copied in the batch directory
+func AttachmentPath(file *model.FileInfo) string {
+	return path.Join("files", file.PostId, file.Id+"-"+path.Base(file.Path))
+}
+
+// CopyAttachment copies an attachment from the file backend into the batch directory and returns
+// its path relative to the batch directory
+func CopyAttachment(params *ExportParams, file *model.FileInfo) (string, error) {
+	reader, err := params.AttachmentBackend.Reader(file.Path)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	attachmentPath := AttachmentPath(file)
+	if _, err := params.ExportBackend.WriteFile(reader, path.Join(params.BatchDir, attachmentPath)); err != nil {
+		return "", err
+	}
+	return attachmentPath, nil
+}
+
+// WriteAttachment streams an attachment from the file backend into w
+func WriteAttachment(params *ExportParams, file *model.FileInfo, w io.Writer) error {
+	reader, err := params.AttachmentBackend.Reader(file.Path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	_, err = io.Copy(w, reader)
+	return err
+}
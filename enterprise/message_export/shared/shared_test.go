@@ -0,0 +1,113 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package shared
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestBuildChannelExports(t *testing.T) {
+	channel := &model.Channel{Id: model.NewId(), TeamId: model.NewId(), Name: "town-square", DisplayName: "Town Square", Type: model.ChannelTypeOpen}
+	team := &model.Team{Id: channel.TeamId, Name: "team", DisplayName: "Team"}
+	alice := &model.ChannelMemberHistoryResult{ChannelId: channel.Id, UserId: model.NewId(), Username: "alice", UserEmail: "alice@example.com", JoinTime: 50}
+	bob := &model.ChannelMemberHistoryResult{ChannelId: channel.Id, UserId: model.NewId(), Username: "bob", UserEmail: "bob@example.com", IsBot: true, JoinTime: 150, LeaveTime: model.NewPointer(int64(300))}
+
+	post := func(id string, createAt, updateAt int64) *model.MessageExport {
+		return &model.MessageExport{
+			ChannelId:    &channel.Id,
+			UserId:       &alice.UserId,
+			Username:     &alice.Username,
+			UserEmail:    &alice.UserEmail,
+			PostId:       model.NewPointer(id),
+			PostCreateAt: model.NewPointer(createAt),
+			PostUpdateAt: model.NewPointer(updateAt),
+			PostMessage:  model.NewPointer("message " + id),
+			PostType:     model.NewPointer(""),
+		}
+	}
+
+	created := post("created", 200, 200)
+	edited := post("edited", 80, 220)
+	edited.PostEditAt = model.NewPointer(int64(220))
+	previous := post("previous", 80, 220)
+	previous.PostOriginalId = model.NewPointer("edited")
+	previous.PostDeleteAt = model.NewPointer(int64(220))
+	deleted := post("deleted", 120, 250)
+	deleted.PostDeleteAt = model.NewPointer(int64(250))
+	deleted.PostFileIds = []string{"file"}
+	// Created before the job, only its reactions changed
+	untouched := post("untouched", 10, 260)
+
+	file := &model.FileInfo{Id: "file", PostId: "deleted", Name: "report.pdf", DeleteAt: 250}
+
+	batch := &Batch{
+		Number:       1,
+		StartTime:    100,
+		EndTime:      400,
+		JobStartTime: 100,
+		Posts:        []*model.MessageExport{created, edited, previous, deleted, untouched},
+		Histories:    []*model.ChannelMemberHistoryResult{alice, bob},
+		Channels:     map[string]*model.Channel{channel.Id: channel},
+		Teams:        map[string]*model.Team{team.Id: team},
+		Files:        map[string][]*model.FileInfo{"deleted": {file}},
+	}
+
+	exports := BuildChannelExports(batch)
+	require.Len(t, exports, 1)
+	export := exports[0]
+	assert.Equal(t, "town-square", export.ChannelName)
+	assert.Equal(t, "Team", export.TeamDisplayName)
+	assert.Equal(t, []User{
+		{Id: alice.UserId, Username: "alice", Email: "alice@example.com"},
+		{Id: bob.UserId, Username: "bob", Email: "bob@example.com", IsBot: true},
+	}, export.Participants)
+
+	type summary struct {
+		Type   EventType
+		Time   int64
+		PostId string
+	}
+	var events []summary
+	for _, event := range export.Events {
+		events = append(events, summary{event.Type, event.Time, event.PostId})
+	}
+	assert.Equal(t, []summary{
+		{EventMessage, 120, "deleted"},
+		{EventFileUpload, 120, "deleted"},
+		// alice joined before the batch, so only bob is seen joining
+		{EventJoin, 150, ""},
+		{EventMessage, 200, "created"},
+		{EventEdit, 220, "edited"},
+		{EventFileDelete, 250, "deleted"},
+		{EventDelete, 250, "deleted"},
+		{EventLeave, 300, ""},
+	}, events)
+
+	assert.Equal(t, "bot", export.Events[len(export.Events)-1].User.UserType())
+}
+
+func TestBuildChannelExportsPreviousVersion(t *testing.T) {
+	channelID := model.NewId()
+	previous := &model.MessageExport{
+		ChannelId:          &channelID,
+		ChannelDisplayName: model.NewPointer("Direct Message"),
+		PostId:             model.NewPointer("previous"),
+		PostCreateAt:       model.NewPointer(int64(150)),
+		PostMessage:        model.NewPointer("before the edit"),
+		PostOriginalId:     model.NewPointer("edited"),
+	}
+
+	exports := BuildChannelExports(&Batch{StartTime: 100, EndTime: 200, JobStartTime: 100, Posts: []*model.MessageExport{previous}})
+	require.Len(t, exports, 1)
+	assert.Equal(t, "Direct Message", exports[0].ChannelDisplayName)
+	require.Len(t, exports[0].Events, 1)
+	assert.Equal(t, EventMessage, exports[0].Events[0].Type)
+	assert.Equal(t, "edited", exports[0].Events[0].EditedByPostId)
+	assert.Equal(t, "before the edit", exports[0].Events[0].Message)
+}
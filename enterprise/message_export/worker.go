@@ -0,0 +1,496 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package message_export
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/actiance_export"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/csv_export"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/global_relay_export"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+const (
+	workerName = "MessageExport"
+
+	TimeBetweenBatches = 100
+
+	// previousJobsPageSize is how many jobs are read at a time when looking for the previous
+	// scheduled job
+	previousJobsPageSize = 100
+)
+
+// Worker runs the message_export jobs one batch at a time, storing its cursor in the data of the
+// job so that an interrupted job resumes from the last exported batch
+type Worker struct {
+	name              string
+	stop              chan struct{}
+	stopped           chan bool
+	jobs              chan model.Job
+	jobServer         *jobs.JobServer
+	logger            mlog.LoggerIFace
+	store             store.Store
+	exportBackend     func() filestore.FileBackend
+	attachmentBackend func() filestore.FileBackend
+	closed            int32
+}
+
+func MakeWorker(jobServer *jobs.JobServer, store store.Store, exportBackend, attachmentBackend func() filestore.FileBackend) *Worker {
+	worker := Worker{
+		name:              workerName,
+		stop:              make(chan struct{}),
+		stopped:           make(chan bool, 1),
+		jobs:              make(chan model.Job),
+		jobServer:         jobServer,
+		logger:            jobServer.Logger().With(mlog.String("worker_name", workerName)),
+		store:             store,
+		exportBackend:     exportBackend,
+		attachmentBackend: attachmentBackend,
+	}
+
+	return &worker
+}
+
+func (worker *Worker) Run() {
+	// Set to open if closed before. We are not bothered about multiple opens.
+	if atomic.CompareAndSwapInt32(&worker.closed, 1, 0) {
+		worker.stop = make(chan struct{})
+	}
+	worker.logger.Debug("Worker started")
+
+	defer func() {
+		worker.logger.Debug("Worker finished")
+		worker.stopped <- true
+	}()
+
+	for {
+		select {
+		case <-worker.stop:
+			worker.logger.Debug("Worker received stop signal")
+			return
+		case job := <-worker.jobs:
+			worker.DoJob(&job)
+		}
+	}
+}
+
+func (worker *Worker) Stop() {
+	// Set to close, and if already closed before, then return.
+	if !atomic.CompareAndSwapInt32(&worker.closed, 0, 1) {
+		return
+	}
+	worker.logger.Debug("Worker stopping")
+	close(worker.stop)
+	<-worker.stopped
+}
+
+func (worker *Worker) JobChannel() chan<- model.Job {
+	return worker.jobs
+}
+
+func (worker *Worker) IsEnabled(cfg *model.Config) bool {
+	return *cfg.MessageExportSettings.EnableExport
+}
+
+func (worker *Worker) DoJob(job *model.Job) {
+	logger := worker.logger.With(jobs.JobLoggerFields(job)...)
+	logger.Debug("Worker: Received a new candidate job.")
+
+	defer worker.jobServer.HandleJobPanic(logger, job)
+
+	var appErr *model.AppError
+	job, appErr = worker.jobServer.ClaimJob(job)
+	if appErr != nil {
+		logger.Warn("Worker experienced an error while trying to claim job", mlog.Err(appErr))
+		return
+	} else if job == nil {
+		return
+	}
+
+	var cancelContext request.CTX = request.EmptyContext(worker.logger)
+	cancelCtx, cancelCancelWatcher := context.WithCancel(context.Background())
+	cancelWatcherChan := make(chan struct{}, 1)
+	cancelContext = cancelContext.WithContext(cancelCtx)
+	go worker.jobServer.CancellationWatcher(cancelContext, job.Id, cancelWatcherChan)
+	defer cancelCancelWatcher()
+
+	cfg := worker.jobServer.Config()
+	if job.Data == nil {
+		job.Data = make(model.StringMap)
+	}
+	if err := initJobData(cancelContext, worker.store, cfg, job, time.Now()); err != nil {
+		logger.Error("Worker: Failed to initialize the job", mlog.Err(err))
+		worker.setJobError(logger, job, model.NewAppError("DoJob", "ent.message_export.job_data_conversion.app_error", nil, "", http.StatusInternalServerError).Wrap(err))
+		return
+	}
+	if appErr := worker.jobServer.UpdateInProgressJobData(job); appErr != nil {
+		logger.Error("Worker: Failed to update the data of the job", mlog.Err(appErr))
+		worker.setJobError(logger, job, appErr)
+		return
+	}
+
+	e := &exporter{
+		store:             worker.store,
+		exportBackend:     worker.exportBackend(),
+		attachmentBackend: worker.attachmentBackend(),
+		formatter:         newFormatter(job.Data[shared.JobDataExportType], &cfg.MessageExportSettings),
+		settings:          cfg.MessageExportSettings,
+	}
+	if e.formatter == nil {
+		worker.setJobError(logger, job, model.NewAppError("DoJob", "ent.message_export.unknown_export_type.app_error", map[string]any{"ExportType": job.Data[shared.JobDataExportType]}, "", http.StatusBadRequest))
+		return
+	}
+
+	for {
+		select {
+		case <-cancelWatcherChan:
+			logger.Debug("Worker: Job has been canceled via CancellationWatcher")
+			worker.setJobCanceled(logger, job)
+			return
+
+		case <-worker.stop:
+			logger.Debug("Worker: Job has been canceled via Worker Stop")
+			worker.setJobCanceled(logger, job)
+			return
+
+		case <-time.After(TimeBetweenBatches * time.Millisecond):
+			done, err := e.exportBatch(cancelContext.WithLogger(logger), job)
+			if err != nil {
+				logger.Error("Worker: Failed to export a batch", mlog.Err(err))
+				worker.setJobError(logger, job, model.NewAppError("DoJob", "ent.message_export.run_export.app_error", nil, "", http.StatusInternalServerError).Wrap(err))
+				return
+			}
+
+			if done {
+				job.Progress = 100
+			}
+			if appErr := worker.jobServer.UpdateInProgressJobData(job); appErr != nil {
+				logger.Error("Worker: Failed to update the data of the job", mlog.Err(appErr))
+				worker.setJobError(logger, job, appErr)
+				return
+			}
+
+			if done {
+				if job.Data[shared.JobDataWarningCount] != "0" {
+					logger.Warn("Worker: Job is complete with warnings", mlog.String("warning_count", job.Data[shared.JobDataWarningCount]))
+					worker.setJobWarning(logger, job)
+					return
+				}
+				logger.Info("Worker: Job is complete")
+				worker.setJobSuccess(logger, job)
+				return
+			}
+		}
+	}
+}
+
+func (worker *Worker) setJobSuccess(logger mlog.LoggerIFace, job *model.Job) {
+	if err := worker.jobServer.SetJobSuccess(job); err != nil {
+		logger.Error("Worker: Failed to set success for job", mlog.Err(err))
+		worker.setJobError(logger, job, err)
+	}
+}
+
+func (worker *Worker) setJobWarning(logger mlog.LoggerIFace, job *model.Job) {
+	if err := worker.jobServer.SetJobWarning(job); err != nil {
+		logger.Error("Worker: Failed to set warning for job", mlog.Err(err))
+		worker.setJobError(logger, job, err)
+	}
+}
+
+func (worker *Worker) setJobError(logger mlog.LoggerIFace, job *model.Job, appError *model.AppError) {
+	if err := worker.jobServer.SetJobError(job, appError); err != nil {
+		logger.Error("Worker: Failed to set job error", mlog.Err(err))
+	}
+}
+
+func (worker *Worker) setJobCanceled(logger mlog.LoggerIFace, job *model.Job) {
+	if err := worker.jobServer.SetJobCanceled(job); err != nil {
+		logger.Error("Worker: Failed to mark job as canceled", mlog.Err(err))
+	}
+}
+
+// newFormatter returns the exporter of a format, or nil when the format is unknown
+func newFormatter(exportType string, settings *model.MessageExportSettings) shared.Exporter {
+	switch exportType {
+	case model.ComplianceExportTypeCsv:
+		return csv_export.Exporter{}
+	case model.ComplianceExportTypeActiance:
+		return actiance_export.Exporter{}
+	case model.ComplianceExportTypeGlobalrelayZip:
+		return global_relay_export.ZipExporter{}
+	case model.ComplianceExportTypeGlobalrelay:
+		return global_relay_export.SMTPExporter{
+			Config: global_relay_export.SMTPConfig(settings.GlobalRelaySettings),
+			To:     *settings.GlobalRelaySettings.EmailAddress,
+		}
+	}
+	return nil
+}
+
+func getInt64(data model.StringMap, key string) (int64, error) {
+	value, err := strconv.ParseInt(data[key], 10, 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "invalid %s in the job data", key)
+	}
+	return value, nil
+}
+
+// initJobData fills in what the job was created without. A job created by the scheduler exports
+// from the end of the previous scheduled job, or from MessageExportSettings.ExportFromTimestamp
+// for the first one; jobs created by mmctl export a period of their own and don't move the start
+// of the scheduled ones.
+func initJobData(rctx request.CTX, ss store.Store, cfg *model.Config, job *model.Job, now time.Time) error {
+	if job.Data[shared.JobDataExportType] == "" {
+		job.Data[shared.JobDataExportType] = *cfg.MessageExportSettings.ExportFormat
+	}
+
+	if job.Data[shared.JobDataJobStartTime] == "" {
+		start := *cfg.MessageExportSettings.ExportFromTimestamp
+		previous, err := previousScheduledJob(rctx, ss, job.Id)
+		if err != nil {
+			return err
+		}
+		if previous != nil {
+			previousEnd, err := getInt64(previous.Data, shared.JobDataJobEndTime)
+			if err != nil {
+				return err
+			}
+			start = previousEnd + 1
+		}
+		job.Data[shared.JobDataJobStartTime] = strconv.FormatInt(start, 10)
+	}
+
+	if job.Data[shared.JobDataJobEndTime] == "" {
+		job.Data[shared.JobDataJobEndTime] = strconv.FormatInt(now.UnixMilli(), 10)
+	}
+
+	if job.Data[shared.JobDataExportDir] == "" {
+		job.Data[shared.JobDataExportDir] = path.Join(model.ComplianceExportPath, fmt.Sprintf("%s-%s-%s", now.Format(model.ComplianceExportDirectoryFormat), job.Data[shared.JobDataJobStartTime], job.Data[shared.JobDataJobEndTime]))
+	}
+
+	for _, key := range []string{shared.JobDataBatchNumber, shared.JobDataMessagesExported, shared.JobDataWarningCount} {
+		if job.Data[key] == "" {
+			job.Data[key] = "0"
+		}
+	}
+
+	return nil
+}
+
+// previousScheduledJob returns the newest completed job which wasn't created by mmctl, if any
+func previousScheduledJob(rctx request.CTX, ss store.Store, currentJobID string) (*model.Job, error) {
+	for offset := 0; ; offset += previousJobsPageSize {
+		page, err := ss.Job().GetAllByTypePage(rctx, model.JobTypeMessageExport, offset, previousJobsPageSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get the previous jobs")
+		}
+		for _, job := range page {
+			if job.Id == currentJobID || job.Data[shared.JobDataInitiatedBy] == "mmctl" {
+				continue
+			}
+			if job.Status == model.JobStatusSuccess || job.Status == model.JobStatusWarning {
+				return job, nil
+			}
+		}
+		if len(page) < previousJobsPageSize {
+			return nil, nil
+		}
+	}
+}
+
+// exporter exports the batches of a job in one of the formats
+type exporter struct {
+	store             store.Store
+	exportBackend     filestore.FileBackend
+	attachmentBackend filestore.FileBackend
+	formatter         shared.Exporter
+	settings          model.MessageExportSettings
+}
+
+// exportBatch exports the posts following the cursor stored in the data of the job, along with
+// the membership changes up to the last of them, then moves the cursor past them. It returns
+// whether the job is done.
+func (e *exporter) exportBatch(rctx request.CTX, job *model.Job) (bool, error) {
+	jobStart, err := getInt64(job.Data, shared.JobDataJobStartTime)
+	if err != nil {
+		return false, err
+	}
+	jobEnd, err := getInt64(job.Data, shared.JobDataJobEndTime)
+	if err != nil {
+		return false, err
+	}
+	batchNumber, err := getInt64(job.Data, shared.JobDataBatchNumber)
+	if err != nil {
+		return false, err
+	}
+
+	cursor := model.MessageExportCursor{
+		LastPostUpdateAt: jobStart,
+		UntilUpdateAt:    jobEnd,
+	}
+	batch := &shared.Batch{Number: int(batchNumber) + 1, JobStartTime: jobStart, StartTime: jobStart}
+	if lastPostID := job.Data[shared.JobDataBatchStartId]; lastPostID != "" {
+		lastUpdateAt, err := getInt64(job.Data, shared.JobDataBatchStartTime)
+		if err != nil {
+			return false, err
+		}
+		cursor.LastPostUpdateAt = lastUpdateAt
+		cursor.LastPostId = lastPostID
+		batch.StartTime = lastUpdateAt
+	}
+
+	posts, nextCursor, err := e.store.Compliance().MessageExport(rctx, cursor, *e.settings.BatchSize)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get a batch of posts")
+	}
+	batch.Posts = posts
+
+	// A full batch stops short of the time of its last post since the next batch may hold posts
+	// updated at the same time, which is where the next batch starts. The last batch goes on
+	// until the end of the job.
+	done := len(posts) < *e.settings.BatchSize
+	batch.EndTime = jobEnd
+	if !done {
+		batch.EndTime = nextCursor.LastPostUpdateAt - 1
+	}
+
+	if err := e.loadBatch(batch); err != nil {
+		return false, err
+	}
+
+	results, err := e.formatter.Export(rctx, &shared.ExportParams{
+		Batch:             batch,
+		Channels:          shared.BuildChannelExports(batch),
+		BatchDir:          path.Join(job.Data[shared.JobDataExportDir], batch.Name()),
+		ExportBackend:     e.exportBackend,
+		AttachmentBackend: e.attachmentBackend,
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to export batch %d", batch.Number)
+	}
+
+	messages, err := getInt64(job.Data, shared.JobDataMessagesExported)
+	if err != nil {
+		return false, err
+	}
+	warnings, err := getInt64(job.Data, shared.JobDataWarningCount)
+	if err != nil {
+		return false, err
+	}
+	job.Data[shared.JobDataBatchNumber] = strconv.Itoa(batch.Number)
+	job.Data[shared.JobDataMessagesExported] = strconv.FormatInt(messages+int64(results.Messages), 10)
+	job.Data[shared.JobDataWarningCount] = strconv.FormatInt(warnings+int64(results.Warnings), 10)
+	if len(posts) > 0 {
+		job.Data[shared.JobDataBatchStartTime] = strconv.FormatInt(nextCursor.LastPostUpdateAt, 10)
+		job.Data[shared.JobDataBatchStartId] = nextCursor.LastPostId
+	}
+	if jobEnd > jobStart {
+		job.Progress = max(0, min((batch.EndTime-jobStart)*100/(jobEnd-jobStart), 99))
+	}
+
+	if done {
+		// The GlobalRelay emails are delivered, so there's nothing to download
+		job.Data[shared.JobDataIsDownloadable] = strconv.FormatBool(job.Data[shared.JobDataExportType] != model.ComplianceExportTypeGlobalrelay)
+	}
+
+	rctx.Logger().Info("Exported a batch of messages",
+		mlog.Int("batch_number", batch.Number),
+		mlog.Int("messages", results.Messages),
+		mlog.Int("files", results.Files),
+		mlog.Int("warnings", results.Warnings),
+	)
+
+	return done, nil
+}
+
+// loadBatch reads the membership changes and the metadata of the channels, teams and files
+// of a batch
+func (e *exporter) loadBatch(batch *shared.Batch) error {
+	channelIDs, err := e.store.ChannelMemberHistory().GetChannelsWithActivityDuring(batch.StartTime, batch.EndTime)
+	if err != nil {
+		return errors.Wrap(err, "failed to get the channels with activity")
+	}
+	seen := make(map[string]bool, len(channelIDs))
+	for _, channelID := range channelIDs {
+		seen[channelID] = true
+	}
+	// Posts edited or deleted during the batch may be in channels without activity since the
+	// query selects the posts by their UpdateAt
+	var fileIDs []string
+	for _, post := range batch.Posts {
+		if post.ChannelId != nil && !seen[*post.ChannelId] {
+			seen[*post.ChannelId] = true
+			channelIDs = append(channelIDs, *post.ChannelId)
+		}
+		fileIDs = append(fileIDs, post.PostFileIds...)
+	}
+
+	for chunk := range slices.Chunk(channelIDs, *e.settings.ChannelHistoryBatchSize) {
+		histories, err := e.store.ChannelMemberHistory().GetUsersInChannelDuring(batch.StartTime, batch.EndTime, chunk)
+		if err != nil {
+			return errors.Wrap(err, "failed to get the channel member history")
+		}
+		batch.Histories = append(batch.Histories, histories...)
+	}
+
+	batch.Channels = make(map[string]*model.Channel, len(channelIDs))
+	teamIDs := make(map[string]bool)
+	for chunk := range slices.Chunk(channelIDs, *e.settings.ChannelBatchSize) {
+		channels, err := e.store.Channel().GetChannelsByIds(chunk, true)
+		if err != nil {
+			return errors.Wrap(err, "failed to get the channels")
+		}
+		for _, channel := range channels {
+			batch.Channels[channel.Id] = channel
+			if channel.TeamId != "" {
+				teamIDs[channel.TeamId] = true
+			}
+		}
+	}
+
+	batch.Teams = make(map[string]*model.Team, len(teamIDs))
+	if len(teamIDs) > 0 {
+		ids := make([]string, 0, len(teamIDs))
+		for teamID := range teamIDs {
+			ids = append(ids, teamID)
+		}
+		teams, err := e.store.Team().GetMany(ids)
+		if err != nil {
+			return errors.Wrap(err, "failed to get the teams")
+		}
+		for _, team := range teams {
+			batch.Teams[team.Id] = team
+		}
+	}
+
+	batch.Files = make(map[string][]*model.FileInfo)
+	if len(fileIDs) > 0 {
+		files, err := e.store.FileInfo().GetByIds(fileIDs, true, false)
+		if err != nil {
+			return errors.Wrap(err, "failed to get the files")
+		}
+		for _, file := range files {
+			batch.Files[file.PostId] = append(batch.Files[file.PostId], file)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package message_export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/csv_export"
+	"github.com/mattermost/mattermost/server/v8/enterprise/message_export/shared"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+func TestInitJobData(t *testing.T) {
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	cfg.MessageExportSettings.ExportFormat = model.NewPointer(model.ComplianceExportTypeCsv)
+	cfg.MessageExportSettings.ExportFromTimestamp = model.NewPointer(int64(100))
+	now := time.UnixMilli(5000)
+
+	t.Run("first scheduled job", func(t *testing.T) {
+		mockStore := &mocks.Store{}
+		mockJobStore := &mocks.JobStore{}
+		mockStore.On("Job").Return(mockJobStore)
+		job := &model.Job{Id: model.NewId(), Data: model.StringMap{}}
+		mockJobStore.On("GetAllByTypePage", mock.Anything, model.JobTypeMessageExport, 0, previousJobsPageSize).Return([]*model.Job{job}, nil)
+
+		require.NoError(t, initJobData(request.TestContext(t), mockStore, cfg, job, now))
+		assert.Equal(t, model.ComplianceExportTypeCsv, job.Data[shared.JobDataExportType])
+		assert.Equal(t, "100", job.Data[shared.JobDataJobStartTime])
+		assert.Equal(t, "5000", job.Data[shared.JobDataJobEndTime])
+		assert.True(t, strings.HasPrefix(job.Data[shared.JobDataExportDir], model.ComplianceExportPath+"/"))
+		assert.True(t, strings.HasSuffix(job.Data[shared.JobDataExportDir], "-100-5000"))
+		assert.Equal(t, "0", job.Data[shared.JobDataBatchNumber])
+	})
+
+	t.Run("after a scheduled job", func(t *testing.T) {
+		mockStore := &mocks.Store{}
+		mockJobStore := &mocks.JobStore{}
+		mockStore.On("Job").Return(mockJobStore)
+		job := &model.Job{Id: model.NewId(), Data: model.StringMap{}}
+		previous := []*model.Job{
+			job,
+			{Id: model.NewId(), Status: model.JobStatusSuccess, Data: model.StringMap{shared.JobDataInitiatedBy: "mmctl", shared.JobDataJobEndTime: "4000"}},
+			{Id: model.NewId(), Status: model.JobStatusError, Data: model.StringMap{shared.JobDataJobEndTime: "3000"}},
+			{Id: model.NewId(), Status: model.JobStatusWarning, Data: model.StringMap{shared.JobDataJobEndTime: "2000"}},
+		}
+		mockJobStore.On("GetAllByTypePage", mock.Anything, model.JobTypeMessageExport, 0, previousJobsPageSize).Return(previous, nil)
+
+		require.NoError(t, initJobData(request.TestContext(t), mockStore, cfg, job, now))
+		assert.Equal(t, "2001", job.Data[shared.JobDataJobStartTime])
+	})
+
+	t.Run("created by mmctl", func(t *testing.T) {
+		job := &model.Job{Id: model.NewId(), Data: model.StringMap{
+			shared.JobDataInitiatedBy:  "mmctl",
+			shared.JobDataExportType:   model.ComplianceExportTypeActiance,
+			shared.JobDataJobStartTime: "10",
+			shared.JobDataJobEndTime:   "20",
+			shared.JobDataExportDir:    "export/cli",
+		}}
+
+		require.NoError(t, initJobData(request.TestContext(t), &mocks.Store{}, cfg, job, now))
+		assert.Equal(t, model.ComplianceExportTypeActiance, job.Data[shared.JobDataExportType])
+		assert.Equal(t, "10", job.Data[shared.JobDataJobStartTime])
+		assert.Equal(t, "20", job.Data[shared.JobDataJobEndTime])
+		assert.Equal(t, "export/cli", job.Data[shared.JobDataExportDir])
+	})
+}
+
+func TestExportBatch(t *testing.T) {
+	mockStore := &mocks.Store{}
+	mockComplianceStore := &mocks.ComplianceStore{}
+	mockHistoryStore := &mocks.ChannelMemberHistoryStore{}
+	mockChannelStore := &mocks.ChannelStore{}
+	mockTeamStore := &mocks.TeamStore{}
+	mockFileInfoStore := &mocks.FileInfoStore{}
+	mockStore.On("Compliance").Return(mockComplianceStore)
+	mockStore.On("ChannelMemberHistory").Return(mockHistoryStore)
+	mockStore.On("Channel").Return(mockChannelStore)
+	mockStore.On("Team").Return(mockTeamStore)
+	mockStore.On("FileInfo").Return(mockFileInfoStore)
+	t.Cleanup(func() {
+		mock.AssertExpectationsForObjects(t, mockComplianceStore, mockHistoryStore, mockChannelStore, mockTeamStore)
+	})
+
+	channel := &model.Channel{Id: model.NewId(), TeamId: model.NewId(), Name: "town-square", Type: model.ChannelTypeOpen}
+	team := &model.Team{Id: channel.TeamId, Name: "team"}
+	userID := model.NewId()
+	post := func(id string, updateAt int64) *model.MessageExport {
+		return &model.MessageExport{
+			ChannelId:    &channel.Id,
+			UserId:       &userID,
+			PostId:       model.NewPointer(id),
+			PostCreateAt: model.NewPointer(updateAt),
+			PostUpdateAt: model.NewPointer(updateAt),
+			PostMessage:  model.NewPointer("message " + id),
+		}
+	}
+
+	// The first batch is full and ends with a post at 300, so the second one starts at 300
+	first := []*model.MessageExport{post("a", 200), post("b", 300)}
+	second := []*model.MessageExport{post("c", 300)}
+	mockComplianceStore.On("MessageExport", mock.Anything, model.MessageExportCursor{LastPostUpdateAt: 100, UntilUpdateAt: 1000}, 2).
+		Return(first, model.MessageExportCursor{LastPostUpdateAt: 300, LastPostId: "b", UntilUpdateAt: 1000}, nil)
+	mockComplianceStore.On("MessageExport", mock.Anything, model.MessageExportCursor{LastPostUpdateAt: 300, LastPostId: "b", UntilUpdateAt: 1000}, 2).
+		Return(second, model.MessageExportCursor{LastPostUpdateAt: 300, LastPostId: "c", UntilUpdateAt: 1000}, nil)
+
+	mockHistoryStore.On("GetChannelsWithActivityDuring", int64(100), int64(299)).Return([]string{channel.Id}, nil)
+	mockHistoryStore.On("GetChannelsWithActivityDuring", int64(300), int64(1000)).Return([]string{}, nil)
+	joined := &model.ChannelMemberHistoryResult{ChannelId: channel.Id, UserId: model.NewId(), Username: "bob", JoinTime: 500}
+	mockHistoryStore.On("GetUsersInChannelDuring", int64(100), int64(299), []string{channel.Id}).Return([]*model.ChannelMemberHistoryResult{}, nil)
+	mockHistoryStore.On("GetUsersInChannelDuring", int64(300), int64(1000), []string{channel.Id}).Return([]*model.ChannelMemberHistoryResult{joined}, nil)
+	mockChannelStore.On("GetChannelsByIds", []string{channel.Id}, true).Return([]*model.Channel{channel}, nil)
+	mockTeamStore.On("GetMany", []string{team.Id}).Return([]*model.Team{team}, nil)
+
+	exportBackend, err := filestore.NewFileBackend(filestore.FileBackendSettings{DriverName: model.ImageDriverLocal, Directory: t.TempDir()})
+	require.NoError(t, err)
+	settings := model.MessageExportSettings{}
+	settings.SetDefaults()
+	settings.BatchSize = model.NewPointer(2)
+	e := &exporter{
+		store:         mockStore,
+		exportBackend: exportBackend,
+		formatter:     csv_export.Exporter{},
+		settings:      settings,
+	}
+
+	job := &model.Job{Data: model.StringMap{
+		shared.JobDataExportType:       model.ComplianceExportTypeCsv,
+		shared.JobDataJobStartTime:     "100",
+		shared.JobDataJobEndTime:       "1000",
+		shared.JobDataExportDir:        "export/job",
+		shared.JobDataBatchNumber:      "0",
+		shared.JobDataMessagesExported: "0",
+		shared.JobDataWarningCount:     "0",
+	}}
+
+	done, err := e.exportBatch(request.TestContext(t), job)
+	require.NoError(t, err)
+	assert.False(t, done)
+	assert.Equal(t, "1", job.Data[shared.JobDataBatchNumber])
+	assert.Equal(t, "2", job.Data[shared.JobDataMessagesExported])
+	assert.Equal(t, "300", job.Data[shared.JobDataBatchStartTime])
+	assert.Equal(t, "b", job.Data[shared.JobDataBatchStartId])
+	assert.Empty(t, job.Data[shared.JobDataIsDownloadable])
+
+	done, err = e.exportBatch(request.TestContext(t), job)
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, "2", job.Data[shared.JobDataBatchNumber])
+	assert.Equal(t, "3", job.Data[shared.JobDataMessagesExported])
+	assert.Equal(t, "c", job.Data[shared.JobDataBatchStartId])
+	assert.Equal(t, "true", job.Data[shared.JobDataIsDownloadable])
+
+	data, err := exportBackend.ReadFile(path.Join("export/job", "batch002-300-1000", csv_export.FileName))
+	require.NoError(t, err)
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	require.NoError(t, err)
+	// The header, the post created at 300 and bob joining
+	require.Len(t, rows, 3)
+	assert.Equal(t, "message c", rows[1][14])
+	assert.Equal(t, "join", rows[2][15])
+}
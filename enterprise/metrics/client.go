@@ -0,0 +1,221 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	subsystemClient        = "webapp"
+	subsystemMobileClient  = "mobileapp"
+	subsystemDesktopClient = "desktopapp"
+)
+
+// clientMetrics are the performance metrics reported by the web, desktop and mobile apps. The
+// user IDs passed along with them are left out of the labels, which would grow with every user.
+type clientMetrics struct {
+	timeToFirstByte        *prometheus.HistogramVec
+	timeToLastByte         *prometheus.HistogramVec
+	timeToDomInteractive   *prometheus.HistogramVec
+	splashScreenEnd        *prometheus.HistogramVec
+	firstContentfulPaint   *prometheus.HistogramVec
+	largestContentfulPaint *prometheus.HistogramVec
+	interactionToNextPaint *prometheus.HistogramVec
+	cumulativeLayoutShift  *prometheus.HistogramVec
+	longTasks              *prometheus.CounterVec
+	pageLoad               *prometheus.HistogramVec
+	channelSwitch          *prometheus.HistogramVec
+	teamSwitch             *prometheus.HistogramVec
+	rhsLoad                *prometheus.HistogramVec
+	globalThreadsLoad      *prometheus.HistogramVec
+
+	mobileLoad                    *prometheus.HistogramVec
+	mobileChannelSwitch           *prometheus.HistogramVec
+	mobileTeamSwitch              *prometheus.HistogramVec
+	mobileAverageSpeed            *prometheus.HistogramVec
+	mobileEffectiveLatency        *prometheus.HistogramVec
+	mobileElapsedTime             *prometheus.HistogramVec
+	mobileLatency                 *prometheus.HistogramVec
+	mobileTotalCompressedSize     *prometheus.HistogramVec
+	mobileTotalParallelRequests   *prometheus.HistogramVec
+	mobileTotalRequests           *prometheus.HistogramVec
+	mobileTotalSequentialRequests *prometheus.HistogramVec
+	mobileTotalSize               *prometheus.HistogramVec
+	mobileSessionMetadata         *prometheus.GaugeVec
+
+	desktopCPUUsage    *prometheus.GaugeVec
+	desktopMemoryUsage *prometheus.GaugeVec
+}
+
+var (
+	// clientDurationBuckets cover the durations measured by the clients, in seconds
+	clientDurationBuckets = []float64{0.1, 0.25, 0.5, 1, 2, 3, 5, 7.5, 10, 15, 20, 30}
+	// layoutShiftBuckets cover the cumulative layout shift scores
+	layoutShiftBuckets = []float64{0.025, 0.05, 0.1, 0.15, 0.2, 0.25, 0.3, 0.5, 1}
+	// networkCountBuckets cover the number of requests made by the mobile app for a group
+	networkCountBuckets = []float64{1, 2, 5, 10, 20, 50, 100, 200}
+	// networkSizeBuckets cover the sizes in bytes of the responses to the mobile app for a group
+	networkSizeBuckets = prometheus.ExponentialBuckets(1024, 4, 10)
+	// networkSpeedBuckets cover the average speeds in bytes per second of the mobile app requests
+	networkSpeedBuckets = prometheus.ExponentialBuckets(1024, 4, 10)
+)
+
+func newClientMetrics(m *MetricsInterfaceImpl) *clientMetrics {
+	networkLabels := []string{"platform", "agent", "network_request_group"}
+
+	return &clientMetrics{
+		timeToFirstByte:        m.newHistogramVec(subsystemClient, "time_to_first_byte", "The time to the first byte of the page.", clientDurationBuckets, "platform", "agent"),
+		timeToLastByte:         m.newHistogramVec(subsystemClient, "time_to_last_byte", "The time to the last byte of the page.", clientDurationBuckets, "platform", "agent"),
+		timeToDomInteractive:   m.newHistogramVec(subsystemClient, "dom_interactive", "The time until the page is interactive.", clientDurationBuckets, "platform", "agent"),
+		splashScreenEnd:        m.newHistogramVec(subsystemClient, "splash_screen", "The time until the splash screen is removed.", clientDurationBuckets, "platform", "agent", "page_type"),
+		firstContentfulPaint:   m.newHistogramVec(subsystemClient, "first_contentful_paint", "The time to the first contentful paint.", clientDurationBuckets, "platform", "agent"),
+		largestContentfulPaint: m.newHistogramVec(subsystemClient, "largest_contentful_paint", "The time to the largest contentful paint.", clientDurationBuckets, "platform", "agent", "region"),
+		interactionToNextPaint: m.newHistogramVec(subsystemClient, "interaction_to_next_paint", "The time from an interaction to the next paint.", clientDurationBuckets, "platform", "agent", "interaction"),
+		cumulativeLayoutShift:  m.newHistogramVec(subsystemClient, "cumulative_layout_shift", "The cumulative layout shift score of the page.", layoutShiftBuckets, "platform", "agent"),
+		longTasks:              m.newCounterVec(subsystemClient, "long_tasks", "The total number of long tasks.", "platform", "agent"),
+		pageLoad:               m.newHistogramVec(subsystemClient, "page_load", "The time to load the page.", clientDurationBuckets, "platform", "agent"),
+		channelSwitch:          m.newHistogramVec(subsystemClient, "channel_switch", "The time to switch channels.", clientDurationBuckets, "platform", "agent", "fresh"),
+		teamSwitch:             m.newHistogramVec(subsystemClient, "team_switch", "The time to switch teams.", clientDurationBuckets, "platform", "agent", "fresh"),
+		rhsLoad:                m.newHistogramVec(subsystemClient, "rhs_load", "The time to load the right hand side.", clientDurationBuckets, "platform", "agent"),
+		globalThreadsLoad:      m.newHistogramVec(subsystemClient, "global_threads_load", "The time to load the threads view.", clientDurationBuckets, "platform", "agent"),
+
+		mobileLoad:                    m.newHistogramVec(subsystemMobileClient, "load", "The time to load the mobile app.", clientDurationBuckets, "platform"),
+		mobileChannelSwitch:           m.newHistogramVec(subsystemMobileClient, "channel_switch", "The time to switch channels in the mobile app.", clientDurationBuckets, "platform"),
+		mobileTeamSwitch:              m.newHistogramVec(subsystemMobileClient, "team_switch", "The time to switch teams in the mobile app.", clientDurationBuckets, "platform"),
+		mobileAverageSpeed:            m.newHistogramVec(subsystemMobileClient, "network_requests_average_speed", "The average speed of the requests of the mobile app.", networkSpeedBuckets, networkLabels...),
+		mobileEffectiveLatency:        m.newHistogramVec(subsystemMobileClient, "network_requests_effective_latency", "The effective latency of the requests of the mobile app.", clientDurationBuckets, networkLabels...),
+		mobileElapsedTime:             m.newHistogramVec(subsystemMobileClient, "network_requests_elapsed_time", "The time taken by the requests of the mobile app.", clientDurationBuckets, networkLabels...),
+		mobileLatency:                 m.newHistogramVec(subsystemMobileClient, "network_requests_latency", "The latency of the requests of the mobile app.", clientDurationBuckets, networkLabels...),
+		mobileTotalCompressedSize:     m.newHistogramVec(subsystemMobileClient, "network_requests_total_compressed_size", "The compressed size of the responses to the mobile app.", networkSizeBuckets, networkLabels...),
+		mobileTotalParallelRequests:   m.newHistogramVec(subsystemMobileClient, "network_requests_total_parallel_requests", "The number of requests the mobile app made in parallel.", networkCountBuckets, networkLabels...),
+		mobileTotalRequests:           m.newHistogramVec(subsystemMobileClient, "network_requests_total_requests", "The number of requests the mobile app made.", networkCountBuckets, networkLabels...),
+		mobileTotalSequentialRequests: m.newHistogramVec(subsystemMobileClient, "network_requests_total_sequential_requests", "The number of requests the mobile app made one after the other.", networkCountBuckets, networkLabels...),
+		mobileTotalSize:               m.newHistogramVec(subsystemMobileClient, "network_requests_total_size", "The size of the responses to the mobile app.", networkSizeBuckets, networkLabels...),
+		mobileSessionMetadata:         m.newGaugeVec(subsystemMobileClient, "session_metadata", "The number of mobile sessions by app version, platform and notification state.", "version", "platform", "notifications_disabled"),
+
+		desktopCPUUsage:    m.newGaugeVec(subsystemDesktopClient, "cpu_usage", "The CPU usage of the processes of the desktop app.", "platform", "version", "process"),
+		desktopMemoryUsage: m.newGaugeVec(subsystemDesktopClient, "memory_usage", "The memory usage of the processes of the desktop app.", "platform", "version", "process"),
+	}
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientTimeToFirstByte(platform, agent, userID string, elapsed float64) {
+	m.client.timeToFirstByte.WithLabelValues(platform, agent).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientTimeToLastByte(platform, agent, userID string, elapsed float64) {
+	m.client.timeToLastByte.WithLabelValues(platform, agent).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientTimeToDomInteractive(platform, agent, userID string, elapsed float64) {
+	m.client.timeToDomInteractive.WithLabelValues(platform, agent).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientSplashScreenEnd(platform, agent, pageType, userID string, elapsed float64) {
+	m.client.splashScreenEnd.WithLabelValues(platform, agent, pageType).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientFirstContentfulPaint(platform, agent, userID string, elapsed float64) {
+	m.client.firstContentfulPaint.WithLabelValues(platform, agent).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientLargestContentfulPaint(platform, agent, region, userID string, elapsed float64) {
+	m.client.largestContentfulPaint.WithLabelValues(platform, agent, region).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientInteractionToNextPaint(platform, agent, interaction, userID string, elapsed float64) {
+	m.client.interactionToNextPaint.WithLabelValues(platform, agent, interaction).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientCumulativeLayoutShift(platform, agent, userID string, elapsed float64) {
+	m.client.cumulativeLayoutShift.WithLabelValues(platform, agent).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) IncrementClientLongTasks(platform, agent, userID string, inc float64) {
+	m.client.longTasks.WithLabelValues(platform, agent).Add(inc)
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientPageLoadDuration(platform, agent, userID string, elapsed float64) {
+	m.client.pageLoad.WithLabelValues(platform, agent).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientChannelSwitchDuration(platform, agent, fresh, userID string, elapsed float64) {
+	m.client.channelSwitch.WithLabelValues(platform, agent, fresh).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientTeamSwitchDuration(platform, agent, fresh, userID string, elapsed float64) {
+	m.client.teamSwitch.WithLabelValues(platform, agent, fresh).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveClientRHSLoadDuration(platform, agent, userID string, elapsed float64) {
+	m.client.rhsLoad.WithLabelValues(platform, agent).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveGlobalThreadsLoadDuration(platform, agent, userID string, elapsed float64) {
+	m.client.globalThreadsLoad.WithLabelValues(platform, agent).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientLoadDuration(platform string, elapsed float64) {
+	m.client.mobileLoad.WithLabelValues(platform).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientChannelSwitchDuration(platform string, elapsed float64) {
+	m.client.mobileChannelSwitch.WithLabelValues(platform).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientTeamSwitchDuration(platform string, elapsed float64) {
+	m.client.mobileTeamSwitch.WithLabelValues(platform).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientNetworkRequestsAverageSpeed(platform, agent, networkRequestGroup string, speed float64) {
+	m.client.mobileAverageSpeed.WithLabelValues(platform, agent, networkRequestGroup).Observe(speed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientNetworkRequestsEffectiveLatency(platform, agent, networkRequestGroup string, latency float64) {
+	m.client.mobileEffectiveLatency.WithLabelValues(platform, agent, networkRequestGroup).Observe(latency)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientNetworkRequestsElapsedTime(platform, agent, networkRequestGroup string, elapsedTime float64) {
+	m.client.mobileElapsedTime.WithLabelValues(platform, agent, networkRequestGroup).Observe(elapsedTime)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientNetworkRequestsLatency(platform, agent, networkRequestGroup string, latency float64) {
+	m.client.mobileLatency.WithLabelValues(platform, agent, networkRequestGroup).Observe(latency)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientNetworkRequestsTotalCompressedSize(platform, agent, networkRequestGroup string, size float64) {
+	m.client.mobileTotalCompressedSize.WithLabelValues(platform, agent, networkRequestGroup).Observe(size)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientNetworkRequestsTotalParallelRequests(platform, agent, networkRequestGroup string, count float64) {
+	m.client.mobileTotalParallelRequests.WithLabelValues(platform, agent, networkRequestGroup).Observe(count)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientNetworkRequestsTotalRequests(platform, agent, networkRequestGroup string, count float64) {
+	m.client.mobileTotalRequests.WithLabelValues(platform, agent, networkRequestGroup).Observe(count)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientNetworkRequestsTotalSequentialRequests(platform, agent, networkRequestGroup string, count float64) {
+	m.client.mobileTotalSequentialRequests.WithLabelValues(platform, agent, networkRequestGroup).Observe(count)
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientNetworkRequestsTotalSize(platform, agent, networkRequestGroup string, size float64) {
+	m.client.mobileTotalSize.WithLabelValues(platform, agent, networkRequestGroup).Observe(size)
+}
+
+func (m *MetricsInterfaceImpl) ClearMobileClientSessionMetadata() {
+	m.client.mobileSessionMetadata.Reset()
+}
+
+func (m *MetricsInterfaceImpl) ObserveMobileClientSessionMetadata(version string, platform string, value float64, notificationDisabled string) {
+	m.client.mobileSessionMetadata.WithLabelValues(version, platform, notificationDisabled).Set(value)
+}
+
+func (m *MetricsInterfaceImpl) ObserveDesktopCpuUsage(platform, version, process string, usage float64) {
+	m.client.desktopCPUUsage.WithLabelValues(platform, version, process).Set(usage)
+}
+
+func (m *MetricsInterfaceImpl) ObserveDesktopMemoryUsage(platform, version, process string, usage float64) {
+	m.client.desktopMemoryUsage.WithLabelValues(platform, version, process).Set(usage)
+}
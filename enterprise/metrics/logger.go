@@ -0,0 +1,49 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+)
+
+// loggerCollector gives the log targets the metrics they update
+type loggerCollector struct {
+	queueSize *prometheus.GaugeVec
+	logged    *prometheus.CounterVec
+	errors    *prometheus.CounterVec
+	dropped   *prometheus.CounterVec
+	blocked   *prometheus.CounterVec
+}
+
+func newLoggerCollector(m *MetricsInterfaceImpl) *loggerCollector {
+	return &loggerCollector{
+		queueSize: m.newGaugeVec(subsystemLogging, "queue_size", "The number of log records waiting to be written by each target.", "target"),
+		logged:    m.newCounterVec(subsystemLogging, "logged_total", "The total number of log records written by each target.", "target"),
+		errors:    m.newCounterVec(subsystemLogging, "errors_total", "The total number of errors of each target.", "target"),
+		dropped:   m.newCounterVec(subsystemLogging, "dropped_total", "The total number of log records dropped by each target.", "target"),
+		blocked:   m.newCounterVec(subsystemLogging, "blocked_total", "The total number of times each target blocked because its queue was full.", "target"),
+	}
+}
+
+func (c *loggerCollector) QueueSizeGauge(target string) (mlog.Gauge, error) {
+	return c.queueSize.GetMetricWithLabelValues(target)
+}
+
+func (c *loggerCollector) LoggedCounter(target string) (mlog.Counter, error) {
+	return c.logged.GetMetricWithLabelValues(target)
+}
+
+func (c *loggerCollector) ErrorCounter(target string) (mlog.Counter, error) {
+	return c.errors.GetMetricWithLabelValues(target)
+}
+
+func (c *loggerCollector) DroppedCounter(target string) (mlog.Counter, error) {
+	return c.dropped.GetMetricWithLabelValues(target)
+}
+
+func (c *loggerCollector) BlockedCounter(target string) (mlog.Counter, error) {
+	return c.blocked.GetMetricWithLabelValues(target)
+}
@@ -0,0 +1,723 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package metrics implements einterfaces.MetricsInterface with the Prometheus client. The
+// metrics are served on /metrics by the metrics server listening on MetricsSettings.ListenAddress,
+// labelled with the cluster and the server they come from.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/app/platform"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+)
+
+const namespace = "mattermost"
+
+const (
+	subsystemAPI            = "api"
+	subsystemAccessControl  = "access_control"
+	subsystemCache          = "cache"
+	subsystemCluster        = "cluster"
+	subsystemDB             = "db"
+	subsystemHTTP           = "http"
+	subsystemJobs           = "jobs"
+	subsystemLogging        = "logging"
+	subsystemLogin          = "login"
+	subsystemNotifications  = "notifications"
+	subsystemPlugin         = "plugin"
+	subsystemPost           = "post"
+	subsystemReadCursor     = "read_cursor"
+	subsystemRedis          = "redis"
+	subsystemRemoteCluster  = "remote_cluster"
+	subsystemSearch         = "search"
+	subsystemSharedChannels = "shared_channels"
+	subsystemSystem         = "system"
+	subsystemWebsocket      = "websocket"
+)
+
+// storeMethodBuckets cover the durations of the store calls timed by the timerlayer, from a
+// cached lookup to a slow query
+var storeMethodBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+func init() {
+	platform.RegisterMetricsInterface(func(ps *platform.PlatformService, driver, dataSource string) einterfaces.MetricsInterface {
+		return New(ps)
+	})
+}
+
+// platformService is the part of platform.PlatformService used by the metrics
+type platformService interface {
+	Config() *model.Config
+	HandleMetrics(route string, h http.Handler)
+}
+
+// MetricsInterfaceImpl holds the Prometheus collectors. They are created once and registered
+// with a new registry every time the metrics server starts, so that the cluster labels follow
+// the configuration.
+type MetricsInterfaceImpl struct {
+	ps         platformService
+	collectors []prometheus.Collector
+
+	mut          sync.Mutex
+	registerer   prometheus.Registerer
+	dbCollectors map[string]prometheus.Collector
+
+	logger *loggerCollector
+
+	PostCreate         prometheus.Counter
+	WebhookPost        prometheus.Counter
+	PostSentEmail      prometheus.Counter
+	PostSentPush       prometheus.Counter
+	PostBroadcast      prometheus.Counter
+	PostFileAttachment prometheus.Counter
+
+	HTTPRequest prometheus.Counter
+	HTTPError   prometheus.Counter
+
+	ClusterRequest         prometheus.Counter
+	ClusterRequestDuration prometheus.Histogram
+	ClusterEventType       *prometheus.CounterVec
+	ClusterEventDropped    *prometheus.CounterVec
+
+	Login     prometheus.Counter
+	LoginFail prometheus.Counter
+
+	EtagHit  *prometheus.CounterVec
+	EtagMiss *prometheus.CounterVec
+
+	MemCacheHit          *prometheus.CounterVec
+	MemCacheMiss         *prometheus.CounterVec
+	MemCacheInvalidation *prometheus.CounterVec
+
+	WebsocketEvent           *prometheus.CounterVec
+	WebsocketBroadcast       *prometheus.CounterVec
+	WebsocketBroadcastBuffer *prometheus.GaugeVec
+	WebsocketBroadcastUsers  *prometheus.GaugeVec
+	WebsocketReconnect       *prometheus.CounterVec
+	HTTPWebsockets           *prometheus.GaugeVec
+
+	PostsSearch         prometheus.Counter
+	PostsSearchDuration prometheus.Histogram
+	FilesSearch         prometheus.Counter
+	FilesSearchDuration prometheus.Histogram
+	PostIndex           prometheus.Counter
+	FileIndex           prometheus.Counter
+	UserIndex           prometheus.Counter
+	ChannelIndex        prometheus.Counter
+
+	StoreMethodDuration   *prometheus.HistogramVec
+	APIEndpointDuration   *prometheus.HistogramVec
+	RedisEndpointDuration *prometheus.HistogramVec
+
+	PluginHookDuration               *prometheus.HistogramVec
+	PluginMultiHookIterationDuration *prometheus.HistogramVec
+	PluginMultiHookDuration          prometheus.Histogram
+	PluginAPIDuration                *prometheus.HistogramVec
+
+	EnabledUsers prometheus.Gauge
+
+	RemoteClusterMsgSent         *prometheus.CounterVec
+	RemoteClusterMsgReceived     *prometheus.CounterVec
+	RemoteClusterMsgErrors       *prometheus.CounterVec
+	RemoteClusterPingDuration    *prometheus.HistogramVec
+	RemoteClusterClockSkew       *prometheus.GaugeVec
+	RemoteClusterConnStateChange *prometheus.CounterVec
+
+	SharedChannelsSync                   *prometheus.CounterVec
+	SharedChannelsTaskInQueueDuration    prometheus.Histogram
+	SharedChannelsQueueSize              prometheus.Gauge
+	SharedChannelsSyncCollectionDuration *prometheus.HistogramVec
+	SharedChannelsSyncSendDuration       *prometheus.HistogramVec
+	SharedChannelsSyncCollectionStep     *prometheus.HistogramVec
+	SharedChannelsSyncSendStep           *prometheus.HistogramVec
+
+	JobActive *prometheus.GaugeVec
+
+	ReplicaLagAbsolute *prometheus.GaugeVec
+	ReplicaLagTime     *prometheus.GaugeVec
+
+	ReadCursorAdvanced              *prometheus.CounterVec
+	ReadCursorPublishFailures       prometheus.Counter
+	ReadCursorOutboxPending         prometheus.Gauge
+	ReadCursorOutboxOldestAge       prometheus.Gauge
+	ReadCursorOutboxPublished       prometheus.Counter
+	ReadCursorOutboxPublishFailures prometheus.Counter
+
+	Notification            *prometheus.CounterVec
+	NotificationAck         *prometheus.CounterVec
+	NotificationSuccess     *prometheus.CounterVec
+	NotificationError       *prometheus.CounterVec
+	NotificationNotSent     *prometheus.CounterVec
+	NotificationUnsupported *prometheus.CounterVec
+
+	AccessControlSearchQueryDuration       prometheus.Histogram
+	AccessControlExpressionCompileDuration prometheus.Histogram
+	AccessControlEvaluateDuration          prometheus.Histogram
+	AccessControlCacheInvalidation         prometheus.Counter
+
+	client *clientMetrics
+}
+
+func New(ps platformService) *MetricsInterfaceImpl {
+	m := &MetricsInterfaceImpl{
+		ps:           ps,
+		dbCollectors: make(map[string]prometheus.Collector),
+	}
+
+	m.PostCreate = m.newCounter(subsystemPost, "total", "The total number of posts created.")
+	m.WebhookPost = m.newCounter(subsystemPost, "webhooks_total", "The total number of posts created by webhooks.")
+	m.PostSentEmail = m.newCounter(subsystemPost, "emails_sent_total", "The total number of email notifications sent for posts.")
+	m.PostSentPush = m.newCounter(subsystemPost, "pushes_sent_total", "The total number of push notifications sent for posts.")
+	m.PostBroadcast = m.newCounter(subsystemPost, "broadcasts_total", "The total number of websocket broadcasts sent for posts.")
+	m.PostFileAttachment = m.newCounter(subsystemPost, "file_attachments_total", "The total number of files attached to posts.")
+
+	m.HTTPRequest = m.newCounter(subsystemHTTP, "requests_total", "The total number of HTTP requests.")
+	m.HTTPError = m.newCounter(subsystemHTTP, "errors_total", "The total number of HTTP requests answered with an error.")
+
+	m.ClusterRequest = m.newCounter(subsystemCluster, "cluster_requests_total", "The total number of requests sent to the other nodes of the cluster.")
+	m.ClusterRequestDuration = m.newHistogram(subsystemCluster, "cluster_request_duration_seconds", "The duration of the requests sent to the other nodes of the cluster.", prometheus.DefBuckets)
+	m.ClusterEventType = m.newCounterVec(subsystemCluster, "cluster_event_type_totals", "The total number of cluster messages sent by event type.", "name")
+	m.ClusterEventDropped = m.newCounterVec(subsystemCluster, "cluster_event_dropped_totals", "The total number of received cluster messages dropped before reaching their handler, by event type.", "name")
+
+	m.Login = m.newCounter(subsystemLogin, "logins_total", "The total number of successful logins.")
+	m.LoginFail = m.newCounter(subsystemLogin, "logins_fail_total", "The total number of failed logins.")
+
+	m.EtagHit = m.newCounterVec(subsystemAPI, "etag_hit_total", "The total number of requests answered as not modified by their ETag.", "route")
+	m.EtagMiss = m.newCounterVec(subsystemAPI, "etag_miss_total", "The total number of requests whose ETag didn't match.", "route")
+
+	m.MemCacheHit = m.newCounterVec(subsystemCache, "mem_hit_total", "The total number of cache hits by cache.", "name")
+	m.MemCacheMiss = m.newCounterVec(subsystemCache, "mem_miss_total", "The total number of cache misses by cache.", "name")
+	m.MemCacheInvalidation = m.newCounterVec(subsystemCache, "mem_invalidation_total", "The total number of cache invalidations by cache.", "name")
+
+	m.WebsocketEvent = m.newCounterVec(subsystemWebsocket, "event_total", "The total number of websocket events sent by type.", "type")
+	m.WebsocketBroadcast = m.newCounterVec(subsystemWebsocket, "broadcasts_total", "The total number of websocket broadcasts by event type.", "type")
+	m.WebsocketBroadcastBuffer = m.newGaugeVec(subsystemWebsocket, "broadcast_buffer_size", "The number of events waiting in the broadcast buffer of each hub.", "hub")
+	m.WebsocketBroadcastUsers = m.newGaugeVec(subsystemWebsocket, "broadcast_users_registered", "The number of users registered with each hub.", "hub")
+	m.WebsocketReconnect = m.newCounterVec(subsystemWebsocket, "reconnects_total", "The total number of websocket reconnections by disconnection error code.", "type", "disconnect_err_code")
+	m.HTTPWebsockets = m.newGaugeVec(subsystemHTTP, "websockets_total", "The number of open websocket connections by client.", "origin_client")
+
+	m.PostsSearch = m.newCounter(subsystemSearch, "posts_searches_total", "The total number of post searches.")
+	m.PostsSearchDuration = m.newHistogram(subsystemSearch, "posts_searches_duration_seconds", "The duration of the post searches.", prometheus.DefBuckets)
+	m.FilesSearch = m.newCounter(subsystemSearch, "files_searches_total", "The total number of file searches.")
+	m.FilesSearchDuration = m.newHistogram(subsystemSearch, "files_searches_duration_seconds", "The duration of the file searches.", prometheus.DefBuckets)
+	m.PostIndex = m.newCounter(subsystemSearch, "post_index_total", "The total number of posts indexed.")
+	m.FileIndex = m.newCounter(subsystemSearch, "file_index_total", "The total number of files indexed.")
+	m.UserIndex = m.newCounter(subsystemSearch, "user_index_total", "The total number of users indexed.")
+	m.ChannelIndex = m.newCounter(subsystemSearch, "channel_index_total", "The total number of channels indexed.")
+
+	m.StoreMethodDuration = m.newHistogramVec(subsystemDB, "store_time", "The duration of the store methods.", storeMethodBuckets, "method", "success")
+	m.APIEndpointDuration = m.newHistogramVec(subsystemAPI, "time", "The duration of the API handlers.", prometheus.DefBuckets, "handler", "method", "status_code", "origin_client", "page_load_context")
+	m.RedisEndpointDuration = m.newHistogramVec(subsystemRedis, "time", "The duration of the Redis cache operations.", storeMethodBuckets, "cache_name", "operation")
+
+	m.PluginHookDuration = m.newHistogramVec(subsystemPlugin, "hook_time", "The duration of the plugin hooks.", prometheus.DefBuckets, "plugin_id", "hook_name", "success")
+	m.PluginMultiHookIterationDuration = m.newHistogramVec(subsystemPlugin, "multi_hook_time", "The duration of a plugin in a hook run on every plugin.", prometheus.DefBuckets, "plugin_id")
+	m.PluginMultiHookDuration = m.newHistogram(subsystemPlugin, "multi_hook_server_time", "The duration of the hooks run on every plugin.", prometheus.DefBuckets)
+	m.PluginAPIDuration = m.newHistogramVec(subsystemPlugin, "api_time", "The duration of the plugin API calls.", prometheus.DefBuckets, "plugin_id", "api_name", "success")
+
+	m.EnabledUsers = m.newGauge(subsystemSystem, "enabled_users", "The number of users who aren't deactivated.")
+
+	m.RemoteClusterMsgSent = m.newCounterVec(subsystemRemoteCluster, "msg_sent_total", "The total number of messages sent to the remote cluster.", "remote_id")
+	m.RemoteClusterMsgReceived = m.newCounterVec(subsystemRemoteCluster, "msg_received_total", "The total number of messages received from the remote cluster.", "remote_id")
+	m.RemoteClusterMsgErrors = m.newCounterVec(subsystemRemoteCluster, "msg_errors_total", "The total number of messages which couldn't be sent to the remote cluster.", "remote_id", "timeout")
+	m.RemoteClusterPingDuration = m.newHistogramVec(subsystemRemoteCluster, "ping_time", "The round trip time of the pings of the remote cluster.", prometheus.DefBuckets, "remote_id")
+	m.RemoteClusterClockSkew = m.newGaugeVec(subsystemRemoteCluster, "clock_skew", "The difference between the clocks of the remote cluster and of this node, in milliseconds.", "remote_id")
+	m.RemoteClusterConnStateChange = m.newCounterVec(subsystemRemoteCluster, "conn_state_change_total", "The total number of times the remote cluster went online or offline.", "remote_id", "online")
+
+	m.SharedChannelsSync = m.newCounterVec(subsystemSharedChannels, "sync_count", "The total number of shared channels synchronizations.", "remote_id")
+	m.SharedChannelsTaskInQueueDuration = m.newHistogram(subsystemSharedChannels, "task_in_queue_duration_seconds", "The time the synchronization tasks wait in the queue.", prometheus.DefBuckets)
+	m.SharedChannelsQueueSize = m.newGauge(subsystemSharedChannels, "task_queue_size", "The number of synchronization tasks in the queue.")
+	m.SharedChannelsSyncCollectionDuration = m.newHistogramVec(subsystemSharedChannels, "sync_collection_duration_seconds", "The time spent collecting the data to synchronize.", prometheus.DefBuckets, "remote_id")
+	m.SharedChannelsSyncSendDuration = m.newHistogramVec(subsystemSharedChannels, "sync_send_duration_seconds", "The time spent sending the data to synchronize.", prometheus.DefBuckets, "remote_id")
+	m.SharedChannelsSyncCollectionStep = m.newHistogramVec(subsystemSharedChannels, "sync_collection_step_duration_seconds", "The time spent on each step of the collection of the data to synchronize.", prometheus.DefBuckets, "remote_id", "step")
+	m.SharedChannelsSyncSendStep = m.newHistogramVec(subsystemSharedChannels, "sync_send_step_duration_seconds", "The time spent on each step of the sending of the data to synchronize.", prometheus.DefBuckets, "remote_id", "step")
+
+	m.JobActive = m.newGaugeVec(subsystemJobs, "active", "The number of jobs running by type.", "type")
+
+	m.ReplicaLagAbsolute = m.newGaugeVec(subsystemDB, "replica_lag_abs", "The absolute lag of each replica, as measured by ReplicaLagSettings.", "node")
+	m.ReplicaLagTime = m.newGaugeVec(subsystemDB, "replica_lag_time", "The time lag of each replica, as measured by ReplicaLagSettings.", "node")
+
+	m.ReadCursorAdvanced = m.newCounterVec(subsystemReadCursor, "advanced_total", "The total number of read cursors moved forward, by cursor type.", "type")
+	m.ReadCursorPublishFailures = m.newCounter(subsystemReadCursor, "publish_failures_total", "The total number of read cursor events which couldn't be published when the cursor moved.")
+	m.ReadCursorOutboxPending = m.newGauge(subsystemReadCursor, "outbox_pending", "The number of read cursor events waiting in the outbox.")
+	m.ReadCursorOutboxOldestAge = m.newGauge(subsystemReadCursor, "outbox_oldest_age_seconds", "The age of the oldest read cursor event waiting in the outbox.")
+	m.ReadCursorOutboxPublished = m.newCounter(subsystemReadCursor, "outbox_published_total", "The total number of read cursor events published by the outbox job.")
+	m.ReadCursorOutboxPublishFailures = m.newCounter(subsystemReadCursor, "outbox_publish_failures_total", "The total number of read cursor events the outbox job couldn't publish.")
+
+	m.Notification = m.newCounterVec(subsystemNotifications, "total", "The total number of notifications.", "type", "platform")
+	m.NotificationAck = m.newCounterVec(subsystemNotifications, "total_ack", "The total number of notifications acknowledged by the clients.", "type", "platform")
+	m.NotificationSuccess = m.newCounterVec(subsystemNotifications, "success", "The total number of notifications sent.", "type", "platform")
+	m.NotificationError = m.newCounterVec(subsystemNotifications, "error", "The total number of notifications which failed.", "type", "reason", "platform")
+	m.NotificationNotSent = m.newCounterVec(subsystemNotifications, "not_sent", "The total number of notifications purposely not sent.", "type", "reason", "platform")
+	m.NotificationUnsupported = m.newCounterVec(subsystemNotifications, "unsupported", "The total number of notifications not sent because the client doesn't support them.", "type", "reason", "platform")
+
+	m.AccessControlSearchQueryDuration = m.newHistogram(subsystemAccessControl, "search_query_duration_seconds", "The duration of the queries for the users matching an expression.", prometheus.DefBuckets)
+	m.AccessControlExpressionCompileDuration = m.newHistogram(subsystemAccessControl, "expression_compile_duration_seconds", "The duration of the compilation of the expressions.", storeMethodBuckets)
+	m.AccessControlEvaluateDuration = m.newHistogram(subsystemAccessControl, "evaluate_duration_seconds", "The duration of the access evaluations.", storeMethodBuckets)
+	m.AccessControlCacheInvalidation = m.newCounter(subsystemAccessControl, "cache_invalidation_total", "The total number of invalidations of the access control cache.")
+
+	m.logger = newLoggerCollector(m)
+	m.client = newClientMetrics(m)
+
+	return m
+}
+
+func (m *MetricsInterfaceImpl) add(c prometheus.Collector) {
+	m.collectors = append(m.collectors, c)
+}
+
+func (m *MetricsInterfaceImpl) newCounter(subsystem, name, help string) prometheus.Counter {
+	c := prometheus.NewCounter(prometheus.CounterOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help})
+	m.add(c)
+	return c
+}
+
+func (m *MetricsInterfaceImpl) newCounterVec(subsystem, name, help string, labels ...string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help}, labels)
+	m.add(c)
+	return c
+}
+
+func (m *MetricsInterfaceImpl) newGauge(subsystem, name, help string) prometheus.Gauge {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help})
+	m.add(g)
+	return g
+}
+
+func (m *MetricsInterfaceImpl) newGaugeVec(subsystem, name, help string, labels ...string) *prometheus.GaugeVec {
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help}, labels)
+	m.add(g)
+	return g
+}
+
+func (m *MetricsInterfaceImpl) newHistogram(subsystem, name, help string, buckets []float64) prometheus.Histogram {
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help, Buckets: buckets})
+	m.add(h)
+	return h
+}
+
+func (m *MetricsInterfaceImpl) newHistogramVec(subsystem, name, help string, buckets []float64, labels ...string) *prometheus.HistogramVec {
+	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Namespace: namespace, Subsystem: subsystem, Name: name, Help: help, Buckets: buckets}, labels)
+	m.add(h)
+	return h
+}
+
+// clusterLabels identify the server the metrics come from, so that the metrics of the nodes of a
+// cluster scraped through a single target can be told apart
+func (m *MetricsInterfaceImpl) clusterLabels() prometheus.Labels {
+	labels := prometheus.Labels{}
+
+	cfg := m.ps.Config()
+	if *cfg.ClusterSettings.Enable && *cfg.ClusterSettings.ClusterName != "" {
+		labels["cluster"] = *cfg.ClusterSettings.ClusterName
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		labels["server"] = hostname
+	}
+
+	return labels
+}
+
+// Register registers the collectors with a new registry served on /metrics. It is called every
+// time the metrics server starts.
+func (m *MetricsInterfaceImpl) Register() {
+	registry := prometheus.NewRegistry()
+	registerer := prometheus.WrapRegistererWith(m.clusterLabels(), registry)
+
+	registerer.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{Namespace: namespace}),
+	)
+	registerer.MustRegister(m.collectors...)
+
+	m.mut.Lock()
+	m.registerer = registerer
+	for name, c := range m.dbCollectors {
+		if err := registerer.Register(c); err != nil {
+			mlog.Warn("Failed to register the database collector", mlog.String("name", name), mlog.Err(err))
+		}
+	}
+	m.mut.Unlock()
+
+	m.ps.HandleMetrics("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+}
+
+// RegisterDBCollector exports the connection pool statistics of the database. The store opens
+// its connections before the metrics server starts, so the collectors are kept to be registered
+// again by Register.
+func (m *MetricsInterfaceImpl) RegisterDBCollector(db *sql.DB, name string) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if old, ok := m.dbCollectors[name]; ok && m.registerer != nil {
+		m.registerer.Unregister(old)
+	}
+
+	c := collectors.NewDBStatsCollector(db, name)
+	m.dbCollectors[name] = c
+	if m.registerer != nil {
+		if err := m.registerer.Register(c); err != nil {
+			mlog.Warn("Failed to register the database collector", mlog.String("name", name), mlog.Err(err))
+		}
+	}
+}
+
+func (m *MetricsInterfaceImpl) UnregisterDBCollector(db *sql.DB, name string) {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	c, ok := m.dbCollectors[name]
+	if !ok {
+		return
+	}
+	delete(m.dbCollectors, name)
+	if m.registerer != nil {
+		m.registerer.Unregister(c)
+	}
+}
+
+func (m *MetricsInterfaceImpl) IncrementPostCreate() {
+	m.PostCreate.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementWebhookPost() {
+	m.WebhookPost.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementPostSentEmail() {
+	m.PostSentEmail.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementPostSentPush() {
+	m.PostSentPush.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementPostBroadcast() {
+	m.PostBroadcast.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementPostFileAttachment(count int) {
+	m.PostFileAttachment.Add(float64(count))
+}
+
+func (m *MetricsInterfaceImpl) IncrementHTTPRequest() {
+	m.HTTPRequest.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementHTTPError() {
+	m.HTTPError.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementClusterRequest() {
+	m.ClusterRequest.Inc()
+}
+
+func (m *MetricsInterfaceImpl) ObserveClusterRequestDuration(elapsed float64) {
+	m.ClusterRequestDuration.Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) IncrementClusterEventType(eventType model.ClusterEvent) {
+	m.ClusterEventType.WithLabelValues(string(eventType)).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementClusterEventDropped(eventType model.ClusterEvent) {
+	m.ClusterEventDropped.WithLabelValues(string(eventType)).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementLogin() {
+	m.Login.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementLoginFail() {
+	m.LoginFail.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementEtagHitCounter(route string) {
+	m.EtagHit.WithLabelValues(route).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementEtagMissCounter(route string) {
+	m.EtagMiss.WithLabelValues(route).Inc()
+}
+
+// sessionCacheName labels the session cache, which has dedicated methods
+const sessionCacheName = "Session"
+
+func (m *MetricsInterfaceImpl) IncrementMemCacheHitCounter(cacheName string) {
+	m.MemCacheHit.WithLabelValues(cacheName).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementMemCacheMissCounter(cacheName string) {
+	m.MemCacheMiss.WithLabelValues(cacheName).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementMemCacheInvalidationCounter(cacheName string) {
+	m.MemCacheInvalidation.WithLabelValues(cacheName).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementMemCacheMissCounterSession() {
+	m.MemCacheMiss.WithLabelValues(sessionCacheName).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementMemCacheHitCounterSession() {
+	m.MemCacheHit.WithLabelValues(sessionCacheName).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementMemCacheInvalidationCounterSession() {
+	m.MemCacheInvalidation.WithLabelValues(sessionCacheName).Inc()
+}
+
+func (m *MetricsInterfaceImpl) AddMemCacheHitCounter(cacheName string, amount float64) {
+	m.MemCacheHit.WithLabelValues(cacheName).Add(amount)
+}
+
+func (m *MetricsInterfaceImpl) AddMemCacheMissCounter(cacheName string, amount float64) {
+	m.MemCacheMiss.WithLabelValues(cacheName).Add(amount)
+}
+
+func (m *MetricsInterfaceImpl) IncrementWebsocketEvent(eventType model.WebsocketEventType) {
+	m.WebsocketEvent.WithLabelValues(string(eventType)).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementWebSocketBroadcast(eventType model.WebsocketEventType) {
+	m.WebsocketBroadcast.WithLabelValues(string(eventType)).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementWebSocketBroadcastBufferSize(hub string, amount float64) {
+	m.WebsocketBroadcastBuffer.WithLabelValues(hub).Add(amount)
+}
+
+func (m *MetricsInterfaceImpl) DecrementWebSocketBroadcastBufferSize(hub string, amount float64) {
+	m.WebsocketBroadcastBuffer.WithLabelValues(hub).Sub(amount)
+}
+
+func (m *MetricsInterfaceImpl) IncrementWebSocketBroadcastUsersRegistered(hub string, amount float64) {
+	m.WebsocketBroadcastUsers.WithLabelValues(hub).Add(amount)
+}
+
+func (m *MetricsInterfaceImpl) DecrementWebSocketBroadcastUsersRegistered(hub string, amount float64) {
+	m.WebsocketBroadcastUsers.WithLabelValues(hub).Sub(amount)
+}
+
+func (m *MetricsInterfaceImpl) IncrementWebsocketReconnectEventWithDisconnectErrCode(eventType string, disconnectErrCode string) {
+	m.WebsocketReconnect.WithLabelValues(eventType, disconnectErrCode).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementHTTPWebSockets(originClient string) {
+	m.HTTPWebsockets.WithLabelValues(originClient).Inc()
+}
+
+func (m *MetricsInterfaceImpl) DecrementHTTPWebSockets(originClient string) {
+	m.HTTPWebsockets.WithLabelValues(originClient).Dec()
+}
+
+func (m *MetricsInterfaceImpl) IncrementPostsSearchCounter() {
+	m.PostsSearch.Inc()
+}
+
+func (m *MetricsInterfaceImpl) ObservePostsSearchDuration(elapsed float64) {
+	m.PostsSearchDuration.Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) IncrementFilesSearchCounter() {
+	m.FilesSearch.Inc()
+}
+
+func (m *MetricsInterfaceImpl) ObserveFilesSearchDuration(elapsed float64) {
+	m.FilesSearchDuration.Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveStoreMethodDuration(method, success string, elapsed float64) {
+	m.StoreMethodDuration.WithLabelValues(method, success).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveAPIEndpointDuration(endpoint, method, statusCode, originClient, pageLoadContext string, elapsed float64) {
+	m.APIEndpointDuration.WithLabelValues(endpoint, method, statusCode, originClient, pageLoadContext).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveRedisEndpointDuration(cacheName, operation string, elapsed float64) {
+	m.RedisEndpointDuration.WithLabelValues(cacheName, operation).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) IncrementPostIndexCounter() {
+	m.PostIndex.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementFileIndexCounter() {
+	m.FileIndex.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementUserIndexCounter() {
+	m.UserIndex.Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementChannelIndexCounter() {
+	m.ChannelIndex.Inc()
+}
+
+func (m *MetricsInterfaceImpl) ObservePluginHookDuration(pluginID, hookName string, success bool, elapsed float64) {
+	m.PluginHookDuration.WithLabelValues(pluginID, hookName, strconv.FormatBool(success)).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObservePluginMultiHookIterationDuration(pluginID string, elapsed float64) {
+	m.PluginMultiHookIterationDuration.WithLabelValues(pluginID).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObservePluginMultiHookDuration(elapsed float64) {
+	m.PluginMultiHookDuration.Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObservePluginAPIDuration(pluginID, apiName string, success bool, elapsed float64) {
+	m.PluginAPIDuration.WithLabelValues(pluginID, apiName, strconv.FormatBool(success)).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveEnabledUsers(users int64) {
+	m.EnabledUsers.Set(float64(users))
+}
+
+func (m *MetricsInterfaceImpl) GetLoggerMetricsCollector() mlog.MetricsCollector {
+	return m.logger
+}
+
+func (m *MetricsInterfaceImpl) IncrementRemoteClusterMsgSentCounter(remoteID string) {
+	m.RemoteClusterMsgSent.WithLabelValues(remoteID).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementRemoteClusterMsgReceivedCounter(remoteID string) {
+	m.RemoteClusterMsgReceived.WithLabelValues(remoteID).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementRemoteClusterMsgErrorsCounter(remoteID string, timeout bool) {
+	m.RemoteClusterMsgErrors.WithLabelValues(remoteID, strconv.FormatBool(timeout)).Inc()
+}
+
+func (m *MetricsInterfaceImpl) ObserveRemoteClusterPingDuration(remoteID string, elapsed float64) {
+	m.RemoteClusterPingDuration.WithLabelValues(remoteID).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveRemoteClusterClockSkew(remoteID string, skew float64) {
+	m.RemoteClusterClockSkew.WithLabelValues(remoteID).Set(skew)
+}
+
+func (m *MetricsInterfaceImpl) IncrementRemoteClusterConnStateChangeCounter(remoteID string, online bool) {
+	m.RemoteClusterConnStateChange.WithLabelValues(remoteID, strconv.FormatBool(online)).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementSharedChannelsSyncCounter(remoteID string) {
+	m.SharedChannelsSync.WithLabelValues(remoteID).Inc()
+}
+
+func (m *MetricsInterfaceImpl) ObserveSharedChannelsTaskInQueueDuration(elapsed float64) {
+	m.SharedChannelsTaskInQueueDuration.Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveSharedChannelsQueueSize(size int64) {
+	m.SharedChannelsQueueSize.Set(float64(size))
+}
+
+func (m *MetricsInterfaceImpl) ObserveSharedChannelsSyncCollectionDuration(remoteID string, elapsed float64) {
+	m.SharedChannelsSyncCollectionDuration.WithLabelValues(remoteID).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveSharedChannelsSyncSendDuration(remoteID string, elapsed float64) {
+	m.SharedChannelsSyncSendDuration.WithLabelValues(remoteID).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveSharedChannelsSyncCollectionStepDuration(remoteID string, step string, elapsed float64) {
+	m.SharedChannelsSyncCollectionStep.WithLabelValues(remoteID, step).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) ObserveSharedChannelsSyncSendStepDuration(remoteID string, step string, elapsed float64) {
+	m.SharedChannelsSyncSendStep.WithLabelValues(remoteID, step).Observe(elapsed)
+}
+
+func (m *MetricsInterfaceImpl) IncrementJobActive(jobType string) {
+	m.JobActive.WithLabelValues(jobType).Inc()
+}
+
+func (m *MetricsInterfaceImpl) DecrementJobActive(jobType string) {
+	m.JobActive.WithLabelValues(jobType).Dec()
+}
+
+func (m *MetricsInterfaceImpl) SetReplicaLagAbsolute(node string, value float64) {
+	m.ReplicaLagAbsolute.WithLabelValues(node).Set(value)
+}
+
+func (m *MetricsInterfaceImpl) SetReplicaLagTime(node string, value float64) {
+	m.ReplicaLagTime.WithLabelValues(node).Set(value)
+}
+
+func (m *MetricsInterfaceImpl) IncrementReadCursorAdvanced(cursorType string) {
+	m.ReadCursorAdvanced.WithLabelValues(cursorType).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementReadCursorPublishFailures() {
+	m.ReadCursorPublishFailures.Inc()
+}
+
+func (m *MetricsInterfaceImpl) SetReadCursorOutboxPending(count int64) {
+	m.ReadCursorOutboxPending.Set(float64(count))
+}
+
+func (m *MetricsInterfaceImpl) SetReadCursorOutboxOldestAge(seconds float64) {
+	m.ReadCursorOutboxOldestAge.Set(seconds)
+}
+
+func (m *MetricsInterfaceImpl) IncrementReadCursorOutboxPublished(count int) {
+	m.ReadCursorOutboxPublished.Add(float64(count))
+}
+
+func (m *MetricsInterfaceImpl) IncrementReadCursorOutboxPublishFailures(count int) {
+	m.ReadCursorOutboxPublishFailures.Add(float64(count))
+}
+
+func (m *MetricsInterfaceImpl) IncrementNotificationCounter(notificationType model.NotificationType, platform string) {
+	m.Notification.WithLabelValues(string(notificationType), platform).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementNotificationAckCounter(notificationType model.NotificationType, platform string) {
+	m.NotificationAck.WithLabelValues(string(notificationType), platform).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementNotificationSuccessCounter(notificationType model.NotificationType, platform string) {
+	m.NotificationSuccess.WithLabelValues(string(notificationType), platform).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementNotificationErrorCounter(notificationType model.NotificationType, errorReason model.NotificationReason, platform string) {
+	m.NotificationError.WithLabelValues(string(notificationType), string(errorReason), platform).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementNotificationNotSentCounter(notificationType model.NotificationType, notSentReason model.NotificationReason, platform string) {
+	m.NotificationNotSent.WithLabelValues(string(notificationType), string(notSentReason), platform).Inc()
+}
+
+func (m *MetricsInterfaceImpl) IncrementNotificationUnsupportedCounter(notificationType model.NotificationType, notSentReason model.NotificationReason, platform string) {
+	m.NotificationUnsupported.WithLabelValues(string(notificationType), string(notSentReason), platform).Inc()
+}
+
+func (m *MetricsInterfaceImpl) ObserveAccessControlSearchQueryDuration(value float64) {
+	m.AccessControlSearchQueryDuration.Observe(value)
+}
+
+func (m *MetricsInterfaceImpl) ObserveAccessControlExpressionCompileDuration(value float64) {
+	m.AccessControlExpressionCompileDuration.Observe(value)
+}
+
+func (m *MetricsInterfaceImpl) ObserveAccessControlEvaluateDuration(value float64) {
+	m.AccessControlEvaluateDuration.Observe(value)
+}
+
+func (m *MetricsInterfaceImpl) IncrementAccessControlCacheInvalidation() {
+	m.AccessControlCacheInvalidation.Inc()
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+type fakePlatform struct {
+	cfg      *model.Config
+	handlers map[string]http.Handler
+}
+
+func newFakePlatform() *fakePlatform {
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	return &fakePlatform{cfg: cfg, handlers: map[string]http.Handler{}}
+}
+
+func (p *fakePlatform) Config() *model.Config {
+	return p.cfg
+}
+
+func (p *fakePlatform) HandleMetrics(route string, h http.Handler) {
+	p.handlers[route] = h
+}
+
+func (p *fakePlatform) scrape(t *testing.T) string {
+	t.Helper()
+
+	handler, ok := p.handlers["/metrics"]
+	require.True(t, ok, "the metrics aren't served")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+type fakeConnector struct{}
+
+func (fakeConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("not connected")
+}
+
+func (fakeConnector) Driver() driver.Driver {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	p := newFakePlatform()
+	p.cfg.ClusterSettings.Enable = model.NewPointer(true)
+	p.cfg.ClusterSettings.ClusterName = model.NewPointer("production")
+
+	m := New(p)
+	m.Register()
+
+	m.IncrementPostCreate()
+	m.ObserveStoreMethodDuration("PostStore.Get", "true", 0.003)
+	m.IncrementReadCursorAdvanced("thread")
+	m.IncrementReadCursorPublishFailures()
+	m.IncrementMemCacheHitCounterSession()
+
+	body := p.scrape(t)
+	assert.Regexp(t, `mattermost_post_total\{cluster="production",server="[^"]+"\} 1`, body)
+	assert.Contains(t, body, `mattermost_db_store_time_bucket{cluster="production",method="PostStore.Get"`)
+	assert.Regexp(t, `mattermost_read_cursor_advanced_total\{cluster="production",server="[^"]+",type="thread"\} 1`, body)
+	assert.Regexp(t, `mattermost_read_cursor_publish_failures_total\{cluster="production",server="[^"]+"\} 1`, body)
+	assert.Regexp(t, `mattermost_cache_mem_hit_total\{cluster="production",name="Session",server="[^"]+"\} 1`, body)
+	assert.Contains(t, body, "go_goroutines")
+
+	t.Run("registering again follows the configuration and keeps the values", func(t *testing.T) {
+		p.cfg.ClusterSettings.Enable = model.NewPointer(false)
+		m.Register()
+
+		body := p.scrape(t)
+		assert.Regexp(t, `mattermost_post_total\{server="[^"]+"\} 1`, body)
+		assert.NotContains(t, body, "production")
+	})
+}
+
+func TestDBCollector(t *testing.T) {
+	db := sql.OpenDB(fakeConnector{})
+	defer db.Close()
+
+	p := newFakePlatform()
+	m := New(p)
+
+	// The store registers its connections before the metrics server starts
+	m.RegisterDBCollector(db, "master")
+	m.Register()
+	assert.Contains(t, p.scrape(t), `go_sql_open_connections{db_name="master"`)
+
+	m.RegisterDBCollector(db, "replica-0")
+	assert.Contains(t, p.scrape(t), `go_sql_open_connections{db_name="replica-0"`)
+
+	m.UnregisterDBCollector(db, "replica-0")
+	body := p.scrape(t)
+	assert.NotContains(t, body, `db_name="replica-0"`)
+	assert.Contains(t, body, `db_name="master"`)
+}
+
+func TestLoggerMetricsCollector(t *testing.T) {
+	p := newFakePlatform()
+	m := New(p)
+	m.Register()
+
+	collector := m.GetLoggerMetricsCollector()
+	counter, err := collector.LoggedCounter("console")
+	require.NoError(t, err)
+	counter.Add(3)
+	gauge, err := collector.QueueSizeGauge("console")
+	require.NoError(t, err)
+	gauge.Set(7)
+
+	body := p.scrape(t)
+	assert.Regexp(t, `mattermost_logging_logged_total\{server="[^"]+",target="console"\} 3`, body)
+	assert.Regexp(t, `mattermost_logging_queue_size\{server="[^"]+",target="console"\} 7`, body)
+}
@@ -0,0 +1,340 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package saml
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/beevik/etree"
+	saml2 "github.com/mattermost/gosaml2"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+)
+
+// hasEncryptedAssertion tells whether the response carries its assertion encrypted
+func hasEncryptedAssertion(encodedXML string) (bool, error) {
+	raw, err := base64.StdEncoding.DecodeString(encodedXML)
+	if err != nil {
+		return false, err
+	}
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(raw); err != nil {
+		return false, err
+	}
+	return doc.FindElement("//EncryptedAssertion") != nil, nil
+}
+
+// retrieveAssertion validates the response of the identity provider, decrypting its assertion
+// when encryption is enabled
+func retrieveAssertion(sp *saml2.SAMLServiceProvider, settings *model.SamlSettings, encodedXML string) (*saml2.AssertionInfo, *model.AppError) {
+	if encodedXML == "" {
+		return nil, model.NewAppError("retrieveAssertion", "ent.saml.do_login.empty_response.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	encrypted, err := hasEncryptedAssertion(encodedXML)
+	if err != nil {
+		return nil, model.NewAppError("retrieveAssertion", "ent.saml.do_login.parse.app_error", nil, "", http.StatusBadRequest).Wrap(err)
+	}
+	if *settings.Encrypt && !encrypted {
+		return nil, model.NewAppError("retrieveAssertion", "ent.saml.configure.not_encrypted_response.app_error", nil, "", http.StatusBadRequest)
+	}
+	if !*settings.Encrypt && encrypted {
+		return nil, model.NewAppError("retrieveAssertion", "ent.saml.configure.encryption_not_enabled.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	assertionInfo, err := sp.RetrieveAssertionInfo(encodedXML)
+	if err != nil {
+		var verificationErr saml2.ErrVerification
+		if errors.As(err, &verificationErr) {
+			return nil, model.NewAppError("retrieveAssertion", "ent.saml.do_login.invalid_signature.app_error", nil, "", http.StatusBadRequest).Wrap(err)
+		}
+		return nil, model.NewAppError("retrieveAssertion", "ent.saml.do_login.parse.app_error", nil, "", http.StatusBadRequest).Wrap(err)
+	}
+
+	if assertionInfo.WarningInfo.InvalidTime {
+		return nil, model.NewAppError("retrieveAssertion", "ent.saml.do_login.invalid_time.app_error", nil, "", http.StatusBadRequest)
+	}
+	if assertionInfo.WarningInfo.NotInAudience {
+		return nil, model.NewAppError("retrieveAssertion", "ent.saml.do_login.invalid_audience.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	return assertionInfo, nil
+}
+
+// validateResponse retrieves the assertion of the response, checking that it answers a request
+// of the service provider and wasn't used before
+func validateResponse(sp *saml2.SAMLServiceProvider, settings *model.SamlSettings, tokens tokenStore, encodedXML string) (*saml2.AssertionInfo, *model.AppError) {
+	assertionInfo, appErr := retrieveAssertion(sp, settings, encodedXML)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if len(assertionInfo.Assertions) == 0 {
+		return nil, model.NewAppError("validateResponse", "ent.saml.do_login.parse.app_error", nil, "missing assertion", http.StatusBadRequest)
+	}
+
+	if appErr := consumeAssertion(tokens, assertionInfo); appErr != nil {
+		return nil, appErr
+	}
+	if appErr := consumeRequest(tokens, assertionInfo); appErr != nil {
+		return nil, appErr
+	}
+
+	return assertionInfo, nil
+}
+
+// userFromAssertion maps the attributes of the assertion to a Mattermost user following the
+// settings. The user is not saved.
+func userFromAssertion(logger mlog.LoggerIFace, settings *model.SamlSettings, assertionInfo *saml2.AssertionInfo) (*model.User, *model.AppError) {
+	value := func(attribute string) string {
+		if attribute == "" {
+			return ""
+		}
+		return strings.TrimSpace(assertionInfo.Values.Get(attribute))
+	}
+	required := func(attribute string) (string, *model.AppError) {
+		v := value(attribute)
+		if v == "" {
+			return "", model.NewAppError("userFromAssertion", "ent.saml.attribute.app_error", nil, "missing attribute="+attribute, http.StatusBadRequest)
+		}
+		return v, nil
+	}
+
+	email, appErr := required(*settings.EmailAttribute)
+	if appErr != nil {
+		return nil, appErr
+	}
+	username, appErr := required(*settings.UsernameAttribute)
+	if appErr != nil {
+		return nil, appErr
+	}
+	authData := strings.ToLower(email)
+	if *settings.IdAttribute != "" {
+		if authData, appErr = required(*settings.IdAttribute); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	return &model.User{
+		AuthService:   model.UserAuthServiceSaml,
+		AuthData:      model.NewPointer(authData),
+		Username:      model.CleanUsername(logger, username),
+		Email:         strings.ToLower(email),
+		FirstName:     value(*settings.FirstNameAttribute),
+		LastName:      value(*settings.LastNameAttribute),
+		Nickname:      value(*settings.NicknameAttribute),
+		Position:      value(*settings.PositionAttribute),
+		Locale:        value(*settings.LocaleAttribute),
+		EmailVerified: true,
+	}, nil
+}
+
+// hasAttribute tells whether the assertion has the attribute of the "name=value" setting, such
+// as GuestAttribute
+func hasAttribute(values saml2.Values, setting string) bool {
+	name, expected, ok := strings.Cut(setting, "=")
+	if !ok {
+		return false
+	}
+
+	name, expected = strings.TrimSpace(name), strings.TrimSpace(expected)
+	for _, v := range values.GetAll(name) {
+		if strings.TrimSpace(v) == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAttributes copies the attributes managed by the identity provider from samlUser to user,
+// and tells whether any of them changed
+func applyAttributes(settings *model.SamlSettings, user *model.User, samlUser *model.User) bool {
+	changed := false
+	apply := func(attribute string, dest *string, value string) {
+		if attribute != "" && *dest != value {
+			*dest = value
+			changed = true
+		}
+	}
+
+	apply(*settings.UsernameAttribute, &user.Username, samlUser.Username)
+	apply(*settings.EmailAttribute, &user.Email, samlUser.Email)
+	apply(*settings.FirstNameAttribute, &user.FirstName, samlUser.FirstName)
+	apply(*settings.LastNameAttribute, &user.LastName, samlUser.LastName)
+	apply(*settings.NicknameAttribute, &user.Nickname, samlUser.Nickname)
+	apply(*settings.PositionAttribute, &user.Position, samlUser.Position)
+	if samlUser.Locale != "" {
+		apply(*settings.LocaleAttribute, &user.Locale, samlUser.Locale)
+	}
+
+	return changed
+}
+
+// DoLogin validates the response posted by the identity provider, creating the account of the
+// user on its first login and updating it on the next ones. Users switching from email are
+// linked to the SAML identity instead.
+func (s *Saml) DoLogin(rctx request.CTX, encodedXML string, relayState map[string]string) (*model.User, *saml2.AssertionInfo, *model.AppError) {
+	sp, appErr := s.serviceProvider()
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+	settings := s.settings()
+
+	assertionInfo, appErr := validateResponse(sp, settings, s.app.Srv().Store().Token(), encodedXML)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	samlUser, appErr := userFromAssertion(rctx.Logger(), settings, assertionInfo)
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+	isGuest := *settings.GuestAttribute != "" && hasAttribute(assertionInfo.Values, *settings.GuestAttribute)
+	isAdmin := *settings.EnableAdminAttribute && hasAttribute(assertionInfo.Values, *settings.AdminAttribute)
+
+	if *settings.EnableSyncWithLdap && s.app.Ldap() != nil {
+		if _, _, appErr = s.app.Ldap().GetLDAPUserForMMUser(rctx, samlUser); appErr != nil {
+			return nil, nil, model.NewAppError("DoLogin", "ent.saml.login.ldap_user_missing", nil, "", http.StatusBadRequest).Wrap(appErr)
+		}
+	}
+
+	var user *model.User
+	if relayState["action"] == model.OAuthActionEmailToSSO {
+		user, appErr = s.switchFromEmail(rctx, relayState["email_token"], samlUser, isGuest, isAdmin)
+	} else {
+		user, appErr = s.app.GetUserByAuth(samlUser.AuthData, model.UserAuthServiceSaml)
+		switch {
+		case appErr == nil:
+			user, appErr = s.updateUser(rctx, user, samlUser, isGuest, isAdmin)
+		case appErr.Id == app.MissingAuthAccountError:
+			user, appErr = s.createUser(rctx, samlUser, isGuest, isAdmin)
+		}
+	}
+	if appErr != nil {
+		return nil, nil, appErr
+	}
+
+	return user, assertionInfo, nil
+}
+
+// switchFromEmail moves the user who requested the email token to SAML
+func (s *Saml) switchFromEmail(rctx request.CTX, emailToken string, samlUser *model.User, isGuest, isAdmin bool) (*model.User, *model.AppError) {
+	token, appErr := s.app.GetSamlEmailToken(emailToken)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	user, appErr := s.app.GetUserByEmail(token.Extra)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if existing, appErr := s.app.GetUserByAuth(samlUser.AuthData, model.UserAuthServiceSaml); appErr == nil && existing.Id != user.Id {
+		return nil, model.NewAppError("switchFromEmail", "ent.saml.switch_to_saml.already_linked.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if _, err := s.app.Srv().Store().User().UpdateAuthData(user.Id, model.UserAuthServiceSaml, samlUser.AuthData, "", false); err != nil {
+		return nil, model.NewAppError("switchFromEmail", "app.user.update_auth_data.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	s.app.InvalidateCacheForUser(user.Id)
+
+	if appErr = s.app.DeleteToken(token); appErr != nil {
+		rctx.Logger().Warn("Failed to delete the SAML email token", mlog.String("user_id", user.Id), mlog.Err(appErr))
+	}
+
+	if user, appErr = s.app.GetUser(user.Id); appErr != nil {
+		return nil, appErr
+	}
+	return s.updateUser(rctx, user, samlUser, isGuest, isAdmin)
+}
+
+func (s *Saml) createUser(rctx request.CTX, samlUser *model.User, isGuest, isAdmin bool) (*model.User, *model.AppError) {
+	if _, appErr := s.app.GetUserByEmail(samlUser.Email); appErr == nil {
+		return nil, model.NewAppError("createUser", "ent.saml.save_user.email_exists.saml_app_error", nil, "", http.StatusBadRequest)
+	}
+	if _, appErr := s.app.GetUserByUsername(samlUser.Username); appErr == nil {
+		return nil, model.NewAppError("createUser", "ent.saml.save_user.username_exists.saml_app_error", nil, "", http.StatusBadRequest)
+	}
+
+	var user *model.User
+	var appErr *model.AppError
+	if isGuest {
+		user, appErr = s.app.CreateGuest(rctx, samlUser)
+	} else {
+		user, appErr = s.app.CreateUser(rctx, samlUser)
+	}
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if isAdmin && !isGuest {
+		if user, appErr = s.app.UpdateUserRoles(rctx, user.Id, user.Roles+" "+model.SystemAdminRoleId, false); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	rctx.Logger().Info("Created SAML user", mlog.String("user_id", user.Id))
+	return user, nil
+}
+
+// updateUser brings the user in line with the assertion
+func (s *Saml) updateUser(rctx request.CTX, user *model.User, samlUser *model.User, isGuest, isAdmin bool) (*model.User, *model.AppError) {
+	settings := s.settings()
+
+	if applyAttributes(settings, user, samlUser) {
+		updated, appErr := s.app.UpdateUser(rctx, user, false)
+		if appErr != nil {
+			return nil, appErr
+		}
+		user = updated
+	}
+
+	if *settings.GuestAttribute != "" && isGuest != user.IsGuest() {
+		var appErr *model.AppError
+		if isGuest {
+			appErr = s.app.DemoteUserToGuest(rctx, user)
+		} else {
+			appErr = s.app.PromoteGuestToUser(rctx, user, "")
+		}
+		if appErr != nil {
+			return nil, appErr
+		}
+		if user, appErr = s.app.GetUser(user.Id); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	// Admins promoted by hand are kept when the admin attribute is disabled
+	if *settings.EnableAdminAttribute && !user.IsGuest() && isAdmin != user.IsSystemAdmin() {
+		roles := strings.Fields(user.Roles)
+		if isAdmin {
+			roles = append(roles, model.SystemAdminRoleId)
+		} else {
+			roles = removeRole(roles, model.SystemAdminRoleId)
+		}
+
+		var appErr *model.AppError
+		if user, appErr = s.app.UpdateUserRoles(rctx, user.Id, strings.Join(roles, " "), true); appErr != nil {
+			return nil, appErr
+		}
+	}
+
+	return user, nil
+}
+
+func removeRole(roles []string, role string) []string {
+	result := make([]string, 0, len(roles))
+	for _, r := range roles {
+		if r != role {
+			result = append(result, r)
+		}
+	}
+	return result
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package saml
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"net/http"
+	"strings"
+
+	saml2 "github.com/mattermost/gosaml2"
+	"github.com/mattermost/gosaml2/types"
+	dsig "github.com/russellhaering/goxmldsig"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// configFileFunc reads a file uploaded to the configuration store, such as the certificates
+// referenced by SamlSettings
+type configFileFunc func(name string) ([]byte, error)
+
+// newServiceProvider builds the service provider of the settings. idpMetadata, when given,
+// completes the settings: its SSO URL and entity id stand in for an empty IdpURL and
+// IdpDescriptorURL, and its signing certificates are trusted along with IdpCertificateFile.
+func newServiceProvider(settings *model.SamlSettings, configFile configFileFunc, idpMetadata *types.EntityDescriptor) (*saml2.SAMLServiceProvider, *model.AppError) {
+	sp := &saml2.SAMLServiceProvider{
+		IdentityProviderSSOURL:      *settings.IdpURL,
+		IdentityProviderSSOBinding:  saml2.BindingHttpRedirect,
+		IdentityProviderIssuer:      *settings.IdpDescriptorURL,
+		ServiceProviderIssuer:       *settings.ServiceProviderIdentifier,
+		AssertionConsumerServiceURL: *settings.AssertionConsumerServiceURL,
+		AudienceURI:                 *settings.ServiceProviderIdentifier,
+		SkipSignatureValidation:     !*settings.Verify,
+		ScopingIDPProviderId:        *settings.ScopingIDPProviderId,
+		ScopingIDPProviderName:      *settings.ScopingIDPName,
+	}
+
+	var idpCertificates []*x509.Certificate
+	if idpMetadata != nil && idpMetadata.IDPSSODescriptor != nil {
+		if sp.IdentityProviderSSOURL == "" {
+			sp.IdentityProviderSSOURL = ssoURL(idpMetadata.IDPSSODescriptor)
+		}
+		if sp.IdentityProviderIssuer == "" {
+			sp.IdentityProviderIssuer = idpMetadata.EntityID
+		}
+		idpCertificates = signingCertificates(idpMetadata.IDPSSODescriptor)
+	}
+
+	if *settings.IdpCertificateFile != "" {
+		certificates, appErr := readCertificates(configFile, *settings.IdpCertificateFile)
+		if appErr != nil {
+			return nil, appErr
+		}
+		idpCertificates = append(idpCertificates, certificates...)
+	}
+	if *settings.Verify && len(idpCertificates) == 0 {
+		return nil, model.NewAppError("newServiceProvider", "ent.saml.configure.certificate_parse_error.app_error", nil, "no identity provider certificate", http.StatusInternalServerError)
+	}
+	sp.IDPCertificateStore = &dsig.MemoryX509CertificateStore{Roots: idpCertificates}
+
+	if *settings.Encrypt || *settings.SignRequest {
+		keyStore, appErr := readKeyPair(configFile, *settings.PublicCertificateFile, *settings.PrivateKeyFile)
+		if appErr != nil {
+			return nil, appErr
+		}
+
+		// gosaml2 requires every assertion to be encrypted once the encryption key is set
+		if *settings.Encrypt {
+			sp.SPKeyStore = keyStore
+		}
+		if *settings.SignRequest {
+			sp.SPSigningKeyStore = keyStore
+			sp.SignAuthnRequests = true
+			sp.SignAuthnRequestsAlgorithm = signatureMethod(*settings.SignatureAlgorithm)
+			sp.SignAuthnRequestsCanonicalizer = canonicalizer(*settings.CanonicalAlgorithm)
+		}
+	}
+
+	return sp, nil
+}
+
+// readCertificates reads the PEM encoded certificates of the file
+func readCertificates(configFile configFileFunc, name string) ([]*x509.Certificate, *model.AppError) {
+	data, err := configFile(name)
+	if err != nil {
+		return nil, model.NewAppError("readCertificates", "ent.saml.configure.certificate_parse_error.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	var certificates []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, model.NewAppError("readCertificates", "ent.saml.configure.certificate_parse_error.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) == 0 {
+		return nil, model.NewAppError("readCertificates", "ent.saml.configure.certificate_parse_error.app_error", nil, "file="+name, http.StatusInternalServerError)
+	}
+
+	return certificates, nil
+}
+
+// readKeyPair reads the certificate and the private key of the service provider
+func readKeyPair(configFile configFileFunc, certificateName, keyName string) (dsig.X509KeyStore, *model.AppError) {
+	certificatePEM, err := configFile(certificateName)
+	if err != nil {
+		return nil, model.NewAppError("readKeyPair", "ent.saml.configure.certificate_parse_error.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	keyPEM, err := configFile(keyName)
+	if err != nil {
+		return nil, model.NewAppError("readKeyPair", "ent.saml.configure.load_private_key.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	keyPair, err := tls.X509KeyPair(certificatePEM, keyPEM)
+	if err != nil {
+		return nil, model.NewAppError("readKeyPair", "ent.saml.configure.load_private_key.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return dsig.TLSCertKeyStore(keyPair), nil
+}
+
+func signatureMethod(algorithm string) string {
+	switch algorithm {
+	case model.SamlSettingsSignatureAlgorithmSha1:
+		return dsig.RSASHA1SignatureMethod
+	case model.SamlSettingsSignatureAlgorithmSha512:
+		return dsig.RSASHA512SignatureMethod
+	default:
+		return dsig.RSASHA256SignatureMethod
+	}
+}
+
+func canonicalizer(algorithm string) dsig.Canonicalizer {
+	if algorithm == model.SamlSettingsCanonicalAlgorithmC14n11 {
+		return dsig.MakeC14N11Canonicalizer()
+	}
+	return dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+}
+
+// parseIdpMetadata reads the entity descriptor published by the identity provider
+func parseIdpMetadata(data []byte) (*types.EntityDescriptor, *model.AppError) {
+	var descriptor types.EntityDescriptor
+	if err := xml.Unmarshal(data, &descriptor); err != nil {
+		return nil, model.NewAppError("parseIdpMetadata", "app.admin.saml.failure_decode_metadata_xml_from_idp.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	if descriptor.IDPSSODescriptor == nil {
+		return nil, model.NewAppError("parseIdpMetadata", "api.admin.saml.invalid_xml_missing_idpssodescriptors.app_error", nil, "", http.StatusInternalServerError)
+	}
+	return &descriptor, nil
+}
+
+// ssoURL prefers the SSO service of the redirect binding, which BuildRequest uses
+func ssoURL(descriptor *types.IDPSSODescriptor) string {
+	for _, service := range descriptor.SingleSignOnServices {
+		if service.Binding == saml2.BindingHttpRedirect {
+			return service.Location
+		}
+	}
+	if len(descriptor.SingleSignOnServices) > 0 {
+		return descriptor.SingleSignOnServices[0].Location
+	}
+	return ""
+}
+
+// signingCertificates returns the certificates the identity provider signs with. There are
+// several while it rolls its keys over.
+func signingCertificates(descriptor *types.IDPSSODescriptor) []*x509.Certificate {
+	var certificates []*x509.Certificate
+	for _, keyDescriptor := range descriptor.KeyDescriptors {
+		if keyDescriptor.Use != "" && keyDescriptor.Use != "signing" {
+			continue
+		}
+		for _, data := range keyDescriptor.KeyInfo.X509Data.X509Certificates {
+			der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(data.Data), ""))
+			if err != nil {
+				continue
+			}
+			if certificate, err := x509.ParseCertificate(der); err == nil {
+				certificates = append(certificates, certificate)
+			}
+		}
+	}
+	return certificates
+}
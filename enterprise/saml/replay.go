@@ -0,0 +1,127 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package saml
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	saml2 "github.com/mattermost/gosaml2"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+const (
+	// tokenTypeRequest marks the authentication requests sent to the identity provider, which
+	// its responses must answer
+	tokenTypeRequest = "saml_request"
+
+	// tokenTypeAssertion marks the assertions already used to log in
+	tokenTypeAssertion = "saml_assertion"
+
+	// requestLifetime is how long the identity provider has to answer an authentication request
+	requestLifetime = time.Hour
+)
+
+// tokenStore is the subset of store.TokenStore keeping the issued requests and the consumed
+// assertions. Both are kept in the Tokens table, so that they are shared by the nodes of a
+// cluster.
+type tokenStore interface {
+	Save(token *model.Token) error
+	GetByToken(token string) (*model.Token, error)
+	ConsumeOnce(tokenType, tokenStr string) (*model.Token, error)
+}
+
+// tokenKey maps an identifier chosen by either party to a token of model.TokenSize characters
+func tokenKey(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordRequest remembers an authentication request until its response arrives
+func recordRequest(tokens tokenStore, requestId string) *model.AppError {
+	token := &model.Token{Token: tokenKey(requestId), CreateAt: model.GetMillis(), Type: tokenTypeRequest}
+	if err := tokens.Save(token); err != nil {
+		return model.NewAppError("recordRequest", "ent.saml.build_request.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return nil
+}
+
+// consumeRequest checks that the assertion answers an authentication request sent less than
+// requestLifetime ago, and forgets the request so that it can't be answered twice. Responses
+// the identity provider sends unsolicited are refused.
+func consumeRequest(tokens tokenStore, assertionInfo *saml2.AssertionInfo) *model.AppError {
+	var inResponseTo string
+	if subject := assertionInfo.Assertions[0].Subject; subject != nil && subject.SubjectConfirmation != nil && subject.SubjectConfirmation.SubjectConfirmationData != nil {
+		inResponseTo = subject.SubjectConfirmation.SubjectConfirmationData.InResponseTo
+	}
+	if inResponseTo == "" {
+		return model.NewAppError("consumeRequest", "ent.saml.do_login.unsolicited.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	token, err := tokens.ConsumeOnce(tokenTypeRequest, tokenKey(inResponseTo))
+	if err != nil {
+		var nfErr *store.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return model.NewAppError("consumeRequest", "ent.saml.do_login.unknown_request.app_error", nil, "in_response_to="+inResponseTo, http.StatusBadRequest)
+		}
+		return model.NewAppError("consumeRequest", "ent.saml.do_login.parse.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	if token.CreateAt < model.GetMillis()-requestLifetime.Milliseconds() {
+		return model.NewAppError("consumeRequest", "ent.saml.do_login.unknown_request.app_error", nil, "in_response_to="+inResponseTo, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// consumeAssertion records the assertion as used, failing when it already was. The assertion is
+// remembered until it expires: tokens are cleaned up model.MaxTokenExipryTime after their
+// creation, so the creation time of assertions valid for longer is moved forward.
+func consumeAssertion(tokens tokenStore, assertionInfo *saml2.AssertionInfo) *model.AppError {
+	assertion := assertionInfo.Assertions[0]
+	if assertion.ID == "" {
+		return model.NewAppError("consumeAssertion", "ent.saml.do_login.parse.app_error", nil, "missing assertion id", http.StatusBadRequest)
+	}
+
+	createAt := model.GetMillis()
+	if notOnOrAfter := assertionNotOnOrAfter(assertionInfo); !notOnOrAfter.IsZero() {
+		createAt = max(createAt, notOnOrAfter.UnixMilli()-model.MaxTokenExipryTime)
+	}
+
+	key := tokenKey(assertion.ID)
+	if err := tokens.Save(&model.Token{Token: key, CreateAt: createAt, Type: tokenTypeAssertion}); err != nil {
+		if used, getErr := tokens.GetByToken(key); getErr == nil && used.Type == tokenTypeAssertion {
+			return model.NewAppError("consumeAssertion", "ent.saml.do_login.replayed.app_error", nil, "assertion_id="+assertion.ID, http.StatusBadRequest)
+		}
+		return model.NewAppError("consumeAssertion", "ent.saml.do_login.parse.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return nil
+}
+
+// assertionNotOnOrAfter returns the latest expiry of the assertion, from its conditions and its
+// subject confirmation, or the zero time when it has none
+func assertionNotOnOrAfter(assertionInfo *saml2.AssertionInfo) time.Time {
+	assertion := assertionInfo.Assertions[0]
+
+	var values []string
+	if assertion.Conditions != nil {
+		values = append(values, assertion.Conditions.NotOnOrAfter)
+	}
+	if subject := assertion.Subject; subject != nil && subject.SubjectConfirmation != nil && subject.SubjectConfirmation.SubjectConfirmationData != nil {
+		values = append(values, subject.SubjectConfirmation.SubjectConfirmationData.NotOnOrAfter)
+	}
+
+	var latest time.Time
+	for _, value := range values {
+		if t, err := time.Parse(time.RFC3339, value); err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+// Package saml implements einterfaces.SamlInterface, a SAML 2.0 service provider signing users
+// in through the identity provider configured in SamlSettings.
+//
+// Logins are initiated by the service provider with the redirect binding, and the responses
+// are posted back to AssertionConsumerServiceURL, where they must answer a request sent by the
+// cluster and carry an assertion not used before. Users are identified by IdAttribute, or by
+// their email when it is empty, which is stored as their AuthData.
+package saml
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"net/http"
+	"sync"
+
+	saml2 "github.com/mattermost/gosaml2"
+	"github.com/mattermost/gosaml2/types"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+)
+
+func init() {
+	app.RegisterSamlInterface(func(a *app.App) einterfaces.SamlInterface {
+		return New(a)
+	})
+}
+
+type Saml struct {
+	app *app.App
+
+	mut sync.RWMutex
+	sp  *saml2.SAMLServiceProvider
+}
+
+func New(a *app.App) *Saml {
+	return &Saml{app: a}
+}
+
+func (s *Saml) settings() *model.SamlSettings {
+	return &s.app.Config().SamlSettings
+}
+
+func (s *Saml) isLicensed() bool {
+	license := s.app.Srv().License()
+	return license != nil && *license.Features.SAML
+}
+
+// serviceProvider returns the service provider configured by the last ConfigureSP, failing
+// when SAML is not licensed or configured
+func (s *Saml) serviceProvider() (*saml2.SAMLServiceProvider, *model.AppError) {
+	if !s.isLicensed() {
+		return nil, model.NewAppError("serviceProvider", "ent.saml.license_disable.app_error", nil, "", http.StatusNotImplemented)
+	}
+
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	if s.sp == nil {
+		return nil, model.NewAppError("serviceProvider", "ent.saml.service_disable.app_error", nil, "", http.StatusNotImplemented)
+	}
+	return s.sp, nil
+}
+
+// ConfigureSP builds the service provider from SamlSettings. It is called on startup and
+// whenever the configuration changes. The metadata of the identity provider is only fetched
+// when the settings lack its URL, issuer or certificate, so that a fully configured server
+// doesn't depend on the identity provider to start.
+func (s *Saml) ConfigureSP(rctx request.CTX) error {
+	settings := s.settings()
+
+	if !*settings.Enable {
+		s.setServiceProvider(nil)
+		return nil
+	}
+
+	var idpMetadata *types.EntityDescriptor
+	if *settings.IdpMetadataURL != "" && (*settings.IdpURL == "" || *settings.IdpDescriptorURL == "" || *settings.IdpCertificateFile == "") {
+		var appErr *model.AppError
+		if idpMetadata, appErr = s.fetchIdpMetadata(*settings.IdpMetadataURL); appErr != nil {
+			rctx.Logger().Warn("Failed to fetch the metadata of the SAML identity provider", mlog.String("url", *settings.IdpMetadataURL), mlog.Err(appErr))
+		}
+	}
+
+	sp, appErr := newServiceProvider(settings, s.app.GetConfigFile, idpMetadata)
+	s.setServiceProvider(sp)
+	if appErr != nil {
+		return appErr
+	}
+
+	return nil
+}
+
+func (s *Saml) setServiceProvider(sp *saml2.SAMLServiceProvider) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.sp = sp
+}
+
+func (s *Saml) fetchIdpMetadata(url string) (*types.EntityDescriptor, *model.AppError) {
+	data, appErr := s.app.FetchSamlMetadataFromIdp(url)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return parseIdpMetadata(data)
+}
+
+// BuildRequest builds the URL redirecting the user to the identity provider with an
+// authentication request, signed when SignRequest is enabled. The request is recorded so that
+// only its response is accepted.
+func (s *Saml) BuildRequest(rctx request.CTX, relayState string) (*model.SamlAuthRequest, *model.AppError) {
+	sp, appErr := s.serviceProvider()
+	if appErr != nil {
+		return nil, appErr
+	}
+	return buildRequest(sp, s.app.Srv().Store().Token(), relayState)
+}
+
+func buildRequest(sp *saml2.SAMLServiceProvider, tokens tokenStore, relayState string) (*model.SamlAuthRequest, *model.AppError) {
+	doc, err := sp.BuildAuthRequestDocumentNoSig()
+	if err != nil {
+		return nil, model.NewAppError("BuildRequest", "ent.saml.build_request.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	if appErr := recordRequest(tokens, doc.Root().SelectAttrValue("ID", "")); appErr != nil {
+		return nil, appErr
+	}
+	authRequest, err := doc.WriteToString()
+	if err != nil {
+		return nil, model.NewAppError("BuildRequest", "ent.saml.build_request.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	// The redirect binding signs the query string rather than the request
+	url, err := sp.BuildAuthURLRedirect(relayState, doc)
+	if err != nil {
+		return nil, model.NewAppError("BuildRequest", "ent.saml.build_request.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return &model.SamlAuthRequest{
+		Base64AuthRequest: base64.StdEncoding.EncodeToString([]byte(authRequest)),
+		URL:               url,
+		RelayState:        relayState,
+	}, nil
+}
+
+// GetMetadata returns the metadata of the service provider, to be registered with the identity
+// provider
+func (s *Saml) GetMetadata(rctx request.CTX) (string, *model.AppError) {
+	sp, appErr := s.serviceProvider()
+	if appErr != nil {
+		return "", appErr
+	}
+	return metadata(sp)
+}
+
+func metadata(sp *saml2.SAMLServiceProvider) (string, *model.AppError) {
+	descriptor, err := sp.Metadata()
+	if err != nil {
+		return "", model.NewAppError("GetMetadata", "ent.saml.metadata.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	data, err := xml.MarshalIndent(descriptor, "", "  ")
+	if err != nil {
+		return "", model.NewAppError("GetMetadata", "ent.saml.metadata.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return xml.Header + string(data), nil
+}
+
+// CheckProviderAttributes returns the name of the field the patch would change though it is
+// synchronized from the identity provider, or an empty string
+func (s *Saml) CheckProviderAttributes(rctx request.CTX, SS *model.SamlSettings, ouser *model.User, patch *model.UserPatch) string {
+	tryingToChange := func(attribute *string, userValue string, patchValue *string) bool {
+		return *attribute != "" && patchValue != nil && *patchValue != userValue
+	}
+
+	switch {
+	case tryingToChange(SS.FirstNameAttribute, ouser.FirstName, patch.FirstName),
+		tryingToChange(SS.LastNameAttribute, ouser.LastName, patch.LastName):
+		return "full name"
+	case tryingToChange(SS.NicknameAttribute, ouser.Nickname, patch.Nickname):
+		return "nickname"
+	case tryingToChange(SS.EmailAttribute, ouser.Email, patch.Email):
+		return "email"
+	case tryingToChange(SS.UsernameAttribute, ouser.Username, patch.Username):
+		return "username"
+	case tryingToChange(SS.PositionAttribute, ouser.Position, patch.Position):
+		return "position"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,735 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.enterprise for license information.
+
+package saml
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	saml2 "github.com/mattermost/gosaml2"
+	"github.com/mattermost/gosaml2/types"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+const (
+	testIdpURL    = "https://idp.example.com/sso"
+	testIdpIssuer = "https://idp.example.com"
+	testSPIssuer  = "https://mattermost.example.com"
+	testACSURL    = "https://mattermost.example.com/login/sso/saml"
+)
+
+type testKeyPair struct {
+	certificatePEM []byte
+	keyPEM         []byte
+	certificate    tls.Certificate
+}
+
+func newTestKeyPair(t *testing.T, commonName string) testKeyPair {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	certificatePEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	certificate, err := tls.X509KeyPair(certificatePEM, keyPEM)
+	require.NoError(t, err)
+
+	return testKeyPair{certificatePEM: certificatePEM, keyPEM: keyPEM, certificate: certificate}
+}
+
+func configFiles(files map[string][]byte) configFileFunc {
+	return func(name string) ([]byte, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, os.ErrNotExist
+		}
+		return data, nil
+	}
+}
+
+func testSettings() *model.SamlSettings {
+	settings := &model.SamlSettings{}
+	settings.SetDefaults()
+	settings.Enable = model.NewPointer(true)
+	settings.Verify = model.NewPointer(true)
+	settings.Encrypt = model.NewPointer(false)
+	settings.SignRequest = model.NewPointer(false)
+	settings.IdpURL = model.NewPointer(testIdpURL)
+	settings.IdpDescriptorURL = model.NewPointer(testIdpIssuer)
+	settings.ServiceProviderIdentifier = model.NewPointer(testSPIssuer)
+	settings.AssertionConsumerServiceURL = model.NewPointer(testACSURL)
+	settings.IdpCertificateFile = model.NewPointer("saml-idp.crt")
+	settings.PublicCertificateFile = model.NewPointer("saml-public.crt")
+	settings.PrivateKeyFile = model.NewPointer("saml-private.key")
+	settings.IdAttribute = model.NewPointer("id")
+	settings.EmailAttribute = model.NewPointer("email")
+	settings.UsernameAttribute = model.NewPointer("username")
+	settings.FirstNameAttribute = model.NewPointer("firstName")
+	settings.LastNameAttribute = model.NewPointer("lastName")
+	settings.PositionAttribute = model.NewPointer("title")
+	settings.GuestAttribute = model.NewPointer("role=guest")
+	settings.AdminAttribute = model.NewPointer("role=admin")
+	return settings
+}
+
+// responseOptions alter the response built by newResponse
+type responseOptions struct {
+	unsigned     bool
+	inResponseTo string
+	encryptFor   *testKeyPair
+	beforeSign   func(assertion *etree.Element)
+	afterSign    func(assertion *etree.Element)
+}
+
+// newResponse builds the response of the identity provider asserting the attributes, signed
+// with its key
+func newResponse(t *testing.T, idp testKeyPair, attributes map[string][]string, opts responseOptions) string {
+	t.Helper()
+
+	now := time.Now().UTC()
+	format := func(t time.Time) string { return t.Format(time.RFC3339) }
+
+	assertion := etree.NewElement("saml:Assertion")
+	assertion.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	assertion.CreateAttr("ID", "_"+model.NewId())
+	assertion.CreateAttr("Version", "2.0")
+	assertion.CreateAttr("IssueInstant", format(now))
+	assertion.CreateElement("saml:Issuer").SetText(testIdpIssuer)
+
+	subject := assertion.CreateElement("saml:Subject")
+	subject.CreateElement("saml:NameID").SetText(attributes["email"][0])
+	confirmation := subject.CreateElement("saml:SubjectConfirmation")
+	confirmation.CreateAttr("Method", "urn:oasis:names:tc:SAML:2.0:cm:bearer")
+	confirmationData := confirmation.CreateElement("saml:SubjectConfirmationData")
+	confirmationData.CreateAttr("Recipient", testACSURL)
+	confirmationData.CreateAttr("NotOnOrAfter", format(now.Add(5*time.Minute)))
+	if opts.inResponseTo != "" {
+		confirmationData.CreateAttr("InResponseTo", opts.inResponseTo)
+	}
+
+	conditions := assertion.CreateElement("saml:Conditions")
+	conditions.CreateAttr("NotBefore", format(now.Add(-5*time.Minute)))
+	conditions.CreateAttr("NotOnOrAfter", format(now.Add(5*time.Minute)))
+	conditions.CreateElement("saml:AudienceRestriction").CreateElement("saml:Audience").SetText(testSPIssuer)
+
+	authnStatement := assertion.CreateElement("saml:AuthnStatement")
+	authnStatement.CreateAttr("AuthnInstant", format(now))
+	authnStatement.CreateAttr("SessionIndex", "_session")
+
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	attributeStatement := assertion.CreateElement("saml:AttributeStatement")
+	for _, name := range names {
+		attribute := attributeStatement.CreateElement("saml:Attribute")
+		attribute.CreateAttr("Name", name)
+		for _, value := range attributes[name] {
+			attribute.CreateElement("saml:AttributeValue").SetText(value)
+		}
+	}
+
+	if opts.beforeSign != nil {
+		opts.beforeSign(assertion)
+	}
+	if !opts.unsigned {
+		// Identity providers sign with the exclusive canonicalization, as SAML recommends
+		signingContext := dsig.NewDefaultSigningContext(dsig.TLSCertKeyStore(idp.certificate))
+		signingContext.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+		signed, err := signingContext.SignEnveloped(assertion)
+		require.NoError(t, err)
+		assertion = signed
+	}
+	if opts.afterSign != nil {
+		opts.afterSign(assertion)
+	}
+	if opts.encryptFor != nil {
+		assertion = encryptAssertion(t, assertion, *opts.encryptFor)
+	}
+
+	response := etree.NewElement("samlp:Response")
+	response.CreateAttr("xmlns:samlp", "urn:oasis:names:tc:SAML:2.0:protocol")
+	response.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	response.CreateAttr("ID", "_"+model.NewId())
+	response.CreateAttr("Version", "2.0")
+	response.CreateAttr("IssueInstant", format(now))
+	response.CreateAttr("Destination", testACSURL)
+	response.CreateElement("saml:Issuer").SetText(testIdpIssuer)
+	response.CreateElement("samlp:Status").CreateElement("samlp:StatusCode").CreateAttr("Value", saml2.StatusCodeSuccess)
+	response.AddChild(assertion)
+
+	doc := etree.NewDocument()
+	doc.SetRoot(response)
+	data, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+// encryptAssertion encrypts the assertion for the service provider the way identity providers
+// do: with a random AES-GCM key, itself encrypted with the public key of the service provider
+func encryptAssertion(t *testing.T, assertion *etree.Element, sp testKeyPair) *etree.Element {
+	t.Helper()
+
+	doc := etree.NewDocument()
+	doc.SetRoot(assertion.Copy())
+	plaintext, err := doc.WriteToBytes()
+	require.NoError(t, err)
+
+	key := make([]byte, 32)
+	_, err = rand.Read(key)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	require.NoError(t, err)
+	ciphertext := gcm.Seal(nonce, nonce, plaintext, nil)
+
+	certificate, err := x509.ParseCertificate(sp.certificate.Certificate[0])
+	require.NoError(t, err)
+	encryptedKey, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, certificate.PublicKey.(*rsa.PublicKey), key, nil)
+	require.NoError(t, err)
+
+	encrypted := etree.NewElement("saml:EncryptedAssertion")
+	encrypted.CreateAttr("xmlns:saml", "urn:oasis:names:tc:SAML:2.0:assertion")
+	data := encrypted.CreateElement("xenc:EncryptedData")
+	data.CreateAttr("xmlns:xenc", "http://www.w3.org/2001/04/xmlenc#")
+	data.CreateAttr("Type", "http://www.w3.org/2001/04/xmlenc#Element")
+	data.CreateElement("xenc:EncryptionMethod").CreateAttr("Algorithm", types.MethodAES256GCM)
+	keyInfo := data.CreateElement("ds:KeyInfo")
+	keyInfo.CreateAttr("xmlns:ds", "http://www.w3.org/2000/09/xmldsig#")
+	encryptedKeyElement := keyInfo.CreateElement("xenc:EncryptedKey")
+	encryptedKeyElement.CreateElement("xenc:EncryptionMethod").CreateAttr("Algorithm", types.MethodRSAOAEP)
+	encryptedKeyElement.CreateElement("xenc:CipherData").CreateElement("xenc:CipherValue").SetText(base64.StdEncoding.EncodeToString(encryptedKey))
+	data.CreateElement("xenc:CipherData").CreateElement("xenc:CipherValue").SetText(base64.StdEncoding.EncodeToString(ciphertext))
+
+	return encrypted
+}
+
+// memoryTokenStore keeps tokens the way the Tokens table does
+type memoryTokenStore struct {
+	tokens map[string]*model.Token
+}
+
+func newMemoryTokenStore() *memoryTokenStore {
+	return &memoryTokenStore{tokens: map[string]*model.Token{}}
+}
+
+func (s *memoryTokenStore) Save(token *model.Token) error {
+	if err := token.IsValid(); err != nil {
+		return err
+	}
+	if _, ok := s.tokens[token.Token]; ok {
+		return errors.New("duplicate key")
+	}
+	s.tokens[token.Token] = token
+	return nil
+}
+
+func (s *memoryTokenStore) GetByToken(token string) (*model.Token, error) {
+	if t, ok := s.tokens[token]; ok {
+		return t, nil
+	}
+	return nil, store.NewErrNotFound("Token", token)
+}
+
+func (s *memoryTokenStore) ConsumeOnce(tokenType, token string) (*model.Token, error) {
+	t, ok := s.tokens[token]
+	if !ok || t.Type != tokenType {
+		return nil, store.NewErrNotFound("Token", token)
+	}
+	delete(s.tokens, token)
+	return t, nil
+}
+
+func testAttributes() map[string][]string {
+	return map[string][]string{
+		"id":        {"f1b2c3"},
+		"email":     {"Alice@Example.com"},
+		"username":  {"alice"},
+		"firstName": {"Alice"},
+		"lastName":  {"Liddell"},
+		"title":     {"Engineer"},
+		"role":      {"staff", "admin"},
+	}
+}
+
+func TestNewServiceProvider(t *testing.T) {
+	idp := newTestKeyPair(t, "idp")
+	sp := newTestKeyPair(t, "sp")
+	files := configFiles(map[string][]byte{
+		"saml-idp.crt":     idp.certificatePEM,
+		"saml-public.crt":  sp.certificatePEM,
+		"saml-private.key": sp.keyPEM,
+	})
+
+	t.Run("verifying", func(t *testing.T) {
+		provider, appErr := newServiceProvider(testSettings(), files, nil)
+		require.Nil(t, appErr)
+		assert.Equal(t, testIdpURL, provider.IdentityProviderSSOURL)
+		assert.Equal(t, testIdpIssuer, provider.IdentityProviderIssuer)
+		assert.Equal(t, testSPIssuer, provider.ServiceProviderIssuer)
+		assert.Equal(t, testSPIssuer, provider.AudienceURI)
+		assert.False(t, provider.SkipSignatureValidation)
+		assert.False(t, provider.SignAuthnRequests)
+		assert.Nil(t, provider.SPKeyStore)
+
+		roots, err := provider.IDPCertificateStore.Certificates()
+		require.NoError(t, err)
+		require.Len(t, roots, 1)
+		assert.Equal(t, "idp", roots[0].Subject.CommonName)
+	})
+
+	t.Run("missing identity provider certificate", func(t *testing.T) {
+		_, appErr := newServiceProvider(testSettings(), configFiles(nil), nil)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.configure.certificate_parse_error.app_error", appErr.Id)
+
+		settings := testSettings()
+		settings.Verify = model.NewPointer(false)
+		settings.IdpCertificateFile = model.NewPointer("")
+		provider, appErr := newServiceProvider(settings, configFiles(nil), nil)
+		require.Nil(t, appErr)
+		assert.True(t, provider.SkipSignatureValidation)
+	})
+
+	t.Run("encrypting and signing", func(t *testing.T) {
+		settings := testSettings()
+		settings.Encrypt = model.NewPointer(true)
+		settings.SignRequest = model.NewPointer(true)
+		settings.SignatureAlgorithm = model.NewPointer(model.SamlSettingsSignatureAlgorithmSha512)
+
+		provider, appErr := newServiceProvider(settings, files, nil)
+		require.Nil(t, appErr)
+		assert.NotNil(t, provider.SPKeyStore)
+		assert.NotNil(t, provider.SPSigningKeyStore)
+		assert.True(t, provider.SignAuthnRequests)
+		assert.Equal(t, dsig.RSASHA512SignatureMethod, provider.SignAuthnRequestsAlgorithm)
+	})
+
+	t.Run("missing private key", func(t *testing.T) {
+		settings := testSettings()
+		settings.Encrypt = model.NewPointer(true)
+
+		_, appErr := newServiceProvider(settings, configFiles(map[string][]byte{
+			"saml-idp.crt":    idp.certificatePEM,
+			"saml-public.crt": sp.certificatePEM,
+		}), nil)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.configure.load_private_key.app_error", appErr.Id)
+	})
+}
+
+func TestIdpMetadata(t *testing.T) {
+	idp := newTestKeyPair(t, "idp")
+	rollover := newTestKeyPair(t, "idp-next")
+
+	encode := func(pair testKeyPair) string {
+		encoded := base64.StdEncoding.EncodeToString(pair.certificate.Certificate[0])
+		// Identity providers commonly wrap the certificates
+		return encoded[:64] + "\n        " + encoded[64:]
+	}
+	data := fmt.Sprintf(`<?xml version="1.0"?>
+<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" xmlns:ds="http://www.w3.org/2000/09/xmldsig#" entityID="%s">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:KeyDescriptor><ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:KeyDescriptor use="encryption"><ds:KeyInfo><ds:X509Data><ds:X509Certificate>%s</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="%s/post"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="%s"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`, testIdpIssuer, encode(idp), encode(rollover), encode(newTestKeyPair(t, "encryption")), testIdpURL, testIdpURL)
+
+	descriptor, appErr := parseIdpMetadata([]byte(data))
+	require.Nil(t, appErr)
+
+	settings := testSettings()
+	settings.IdpURL = model.NewPointer("")
+	settings.IdpDescriptorURL = model.NewPointer("")
+	settings.IdpCertificateFile = model.NewPointer("")
+
+	provider, appErr := newServiceProvider(settings, configFiles(nil), descriptor)
+	require.Nil(t, appErr)
+	assert.Equal(t, testIdpURL, provider.IdentityProviderSSOURL)
+	assert.Equal(t, testIdpIssuer, provider.IdentityProviderIssuer)
+
+	roots, err := provider.IDPCertificateStore.Certificates()
+	require.NoError(t, err)
+	require.Len(t, roots, 2)
+	assert.Equal(t, "idp", roots[0].Subject.CommonName)
+	assert.Equal(t, "idp-next", roots[1].Subject.CommonName)
+
+	for _, pair := range []testKeyPair{idp, rollover} {
+		_, appErr = retrieveAssertion(provider, settings, newResponse(t, pair, testAttributes(), responseOptions{}))
+		require.Nil(t, appErr)
+	}
+
+	t.Run("not an identity provider", func(t *testing.T) {
+		_, appErr := parseIdpMetadata([]byte(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="sp"/>`))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "api.admin.saml.invalid_xml_missing_idpssodescriptors.app_error", appErr.Id)
+	})
+}
+
+func TestBuildRequest(t *testing.T) {
+	idp := newTestKeyPair(t, "idp")
+	sp := newTestKeyPair(t, "sp")
+	files := configFiles(map[string][]byte{
+		"saml-idp.crt":     idp.certificatePEM,
+		"saml-public.crt":  sp.certificatePEM,
+		"saml-private.key": sp.keyPEM,
+	})
+
+	for _, signed := range []bool{false, true} {
+		t.Run(fmt.Sprintf("signed=%t", signed), func(t *testing.T) {
+			settings := testSettings()
+			settings.SignRequest = model.NewPointer(signed)
+			provider, appErr := newServiceProvider(settings, files, nil)
+			require.Nil(t, appErr)
+
+			tokens := newMemoryTokenStore()
+			request, appErr := buildRequest(provider, tokens, "relay")
+			require.Nil(t, appErr)
+			assert.Equal(t, "relay", request.RelayState)
+
+			u, err := url.Parse(request.URL)
+			require.NoError(t, err)
+			assert.Equal(t, testIdpURL, u.Scheme+"://"+u.Host+u.Path)
+			assert.Equal(t, "relay", u.Query().Get("RelayState"))
+			assert.NotEmpty(t, u.Query().Get("SAMLRequest"))
+			assert.Equal(t, signed, u.Query().Get("Signature") != "")
+
+			authRequest, err := base64.StdEncoding.DecodeString(request.Base64AuthRequest)
+			require.NoError(t, err)
+			assert.Contains(t, string(authRequest), testSPIssuer)
+			assert.Contains(t, string(authRequest), testACSURL)
+
+			doc := etree.NewDocument()
+			require.NoError(t, doc.ReadFromBytes(authRequest))
+			token, err := tokens.GetByToken(tokenKey(doc.Root().SelectAttrValue("ID", "")))
+			require.NoError(t, err, "the request is recorded")
+			assert.Equal(t, tokenTypeRequest, token.Type)
+		})
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	idp := newTestKeyPair(t, "idp")
+	sp := newTestKeyPair(t, "sp")
+
+	settings := testSettings()
+	settings.Encrypt = model.NewPointer(true)
+	settings.SignRequest = model.NewPointer(true)
+	provider, appErr := newServiceProvider(settings, configFiles(map[string][]byte{
+		"saml-idp.crt":     idp.certificatePEM,
+		"saml-public.crt":  sp.certificatePEM,
+		"saml-private.key": sp.keyPEM,
+	}), nil)
+	require.Nil(t, appErr)
+
+	data, appErr := metadata(provider)
+	require.Nil(t, appErr)
+
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(data))
+	assert.Equal(t, testSPIssuer, doc.Root().SelectAttrValue("entityID", ""))
+	assert.Equal(t, testACSURL, doc.FindElement("//AssertionConsumerService").SelectAttrValue("Location", ""))
+	assert.Equal(t, "true", doc.FindElement("//SPSSODescriptor").SelectAttrValue("AuthnRequestsSigned", ""))
+
+	var uses []string
+	for _, keyDescriptor := range doc.FindElements("//KeyDescriptor") {
+		uses = append(uses, keyDescriptor.SelectAttrValue("use", ""))
+	}
+	assert.ElementsMatch(t, []string{"signing", "encryption"}, uses)
+}
+
+func TestRetrieveAssertion(t *testing.T) {
+	idp := newTestKeyPair(t, "idp")
+	settings := testSettings()
+	provider, appErr := newServiceProvider(settings, configFiles(map[string][]byte{"saml-idp.crt": idp.certificatePEM}), nil)
+	require.Nil(t, appErr)
+
+	t.Run("valid", func(t *testing.T) {
+		assertionInfo, appErr := retrieveAssertion(provider, settings, newResponse(t, idp, testAttributes(), responseOptions{}))
+		require.Nil(t, appErr)
+		assert.Equal(t, "Alice@Example.com", assertionInfo.NameID)
+		assert.Equal(t, "alice", assertionInfo.Values.Get("username"))
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		_, appErr := retrieveAssertion(provider, settings, "")
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.do_login.empty_response.app_error", appErr.Id)
+	})
+
+	t.Run("not xml", func(t *testing.T) {
+		_, appErr := retrieveAssertion(provider, settings, base64.StdEncoding.EncodeToString([]byte("<Response")))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.do_login.parse.app_error", appErr.Id)
+	})
+
+	for name, tc := range map[string]struct {
+		key  testKeyPair
+		opts responseOptions
+	}{
+		"unsigned":        {idp, responseOptions{unsigned: true}},
+		"signed by other": {newTestKeyPair(t, "other"), responseOptions{}},
+		"tampered": {idp, responseOptions{afterSign: func(assertion *etree.Element) {
+			assertion.FindElement("//Attribute[@Name='email']/AttributeValue").SetText("mallory@example.com")
+		}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, appErr := retrieveAssertion(provider, settings, newResponse(t, tc.key, testAttributes(), tc.opts))
+			require.NotNil(t, appErr)
+			assert.Equal(t, "ent.saml.do_login.invalid_signature.app_error", appErr.Id)
+		})
+	}
+
+	t.Run("expired", func(t *testing.T) {
+		_, appErr := retrieveAssertion(provider, settings, newResponse(t, idp, testAttributes(), responseOptions{beforeSign: func(assertion *etree.Element) {
+			assertion.FindElement("//Conditions").CreateAttr("NotOnOrAfter", time.Now().Add(-time.Minute).UTC().Format(time.RFC3339))
+		}}))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.do_login.invalid_time.app_error", appErr.Id)
+	})
+
+	t.Run("other audience", func(t *testing.T) {
+		_, appErr := retrieveAssertion(provider, settings, newResponse(t, idp, testAttributes(), responseOptions{beforeSign: func(assertion *etree.Element) {
+			assertion.FindElement("//Audience").SetText("https://other.example.com")
+		}}))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.do_login.invalid_audience.app_error", appErr.Id)
+	})
+
+	t.Run("encryption", func(t *testing.T) {
+		encryptSettings := testSettings()
+		encryptSettings.Encrypt = model.NewPointer(true)
+
+		_, appErr := retrieveAssertion(provider, encryptSettings, newResponse(t, idp, testAttributes(), responseOptions{}))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.configure.not_encrypted_response.app_error", appErr.Id)
+
+		encrypted := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"><saml:EncryptedAssertion/></samlp:Response>`
+		_, appErr = retrieveAssertion(provider, settings, base64.StdEncoding.EncodeToString([]byte(encrypted)))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.configure.encryption_not_enabled.app_error", appErr.Id)
+	})
+}
+
+func TestValidateResponse(t *testing.T) {
+	idp := newTestKeyPair(t, "idp")
+	sp := newTestKeyPair(t, "sp")
+
+	settings := testSettings()
+	settings.Encrypt = model.NewPointer(true)
+	provider, appErr := newServiceProvider(settings, configFiles(map[string][]byte{
+		"saml-idp.crt":     idp.certificatePEM,
+		"saml-public.crt":  sp.certificatePEM,
+		"saml-private.key": sp.keyPEM,
+	}), nil)
+	require.Nil(t, appErr)
+
+	// login sends an authentication request and returns the id the identity provider answers
+	login := func(t *testing.T, tokens tokenStore) string {
+		t.Helper()
+		request, appErr := buildRequest(provider, tokens, "")
+		require.Nil(t, appErr)
+		authRequest, err := base64.StdEncoding.DecodeString(request.Base64AuthRequest)
+		require.NoError(t, err)
+		doc := etree.NewDocument()
+		require.NoError(t, doc.ReadFromBytes(authRequest))
+		return doc.Root().SelectAttrValue("ID", "")
+	}
+
+	t.Run("encrypted assertion answering a request", func(t *testing.T) {
+		tokens := newMemoryTokenStore()
+		response := newResponse(t, idp, testAttributes(), responseOptions{inResponseTo: login(t, tokens), encryptFor: &sp})
+
+		encrypted, err := hasEncryptedAssertion(response)
+		require.NoError(t, err)
+		require.True(t, encrypted)
+
+		assertionInfo, appErr := validateResponse(provider, settings, tokens, response)
+		require.Nil(t, appErr)
+		assert.Equal(t, "Alice@Example.com", assertionInfo.NameID)
+		assert.Equal(t, "alice", assertionInfo.Values.Get("username"))
+
+		_, appErr = validateResponse(provider, settings, tokens, response)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.do_login.replayed.app_error", appErr.Id)
+	})
+
+	t.Run("encrypted for another service provider", func(t *testing.T) {
+		tokens := newMemoryTokenStore()
+		other := newTestKeyPair(t, "other")
+		response := newResponse(t, idp, testAttributes(), responseOptions{inResponseTo: login(t, tokens), encryptFor: &other})
+
+		_, appErr := validateResponse(provider, settings, tokens, response)
+		require.NotNil(t, appErr)
+	})
+
+	t.Run("answering a request twice", func(t *testing.T) {
+		tokens := newMemoryTokenStore()
+		requestId := login(t, tokens)
+
+		_, appErr := validateResponse(provider, settings, tokens, newResponse(t, idp, testAttributes(), responseOptions{inResponseTo: requestId, encryptFor: &sp}))
+		require.Nil(t, appErr)
+
+		_, appErr = validateResponse(provider, settings, tokens, newResponse(t, idp, testAttributes(), responseOptions{inResponseTo: requestId, encryptFor: &sp}))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.do_login.unknown_request.app_error", appErr.Id)
+	})
+
+	t.Run("unknown request", func(t *testing.T) {
+		tokens := newMemoryTokenStore()
+		_, appErr := validateResponse(provider, settings, tokens, newResponse(t, idp, testAttributes(), responseOptions{inResponseTo: "_" + model.NewId(), encryptFor: &sp}))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.do_login.unknown_request.app_error", appErr.Id)
+	})
+
+	t.Run("expired request", func(t *testing.T) {
+		tokens := newMemoryTokenStore()
+		requestId := login(t, tokens)
+		tokens.tokens[tokenKey(requestId)].CreateAt -= requestLifetime.Milliseconds() + 1
+
+		_, appErr := validateResponse(provider, settings, tokens, newResponse(t, idp, testAttributes(), responseOptions{inResponseTo: requestId, encryptFor: &sp}))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.do_login.unknown_request.app_error", appErr.Id)
+	})
+
+	t.Run("unsolicited", func(t *testing.T) {
+		tokens := newMemoryTokenStore()
+		_, appErr := validateResponse(provider, settings, tokens, newResponse(t, idp, testAttributes(), responseOptions{encryptFor: &sp}))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.do_login.unsolicited.app_error", appErr.Id)
+	})
+
+	t.Run("long lived assertions are remembered until they expire", func(t *testing.T) {
+		tokens := newMemoryTokenStore()
+		notOnOrAfter := time.Now().Add(72 * time.Hour).UTC()
+		response := newResponse(t, idp, testAttributes(), responseOptions{inResponseTo: login(t, tokens), encryptFor: &sp, beforeSign: func(assertion *etree.Element) {
+			assertion.FindElement("//Conditions").CreateAttr("NotOnOrAfter", notOnOrAfter.Format(time.RFC3339))
+		}})
+
+		assertionInfo, appErr := validateResponse(provider, settings, tokens, response)
+		require.Nil(t, appErr)
+
+		token, err := tokens.GetByToken(tokenKey(assertionInfo.Assertions[0].ID))
+		require.NoError(t, err)
+		assert.Equal(t, notOnOrAfter.Truncate(time.Second).UnixMilli(), token.CreateAt+model.MaxTokenExipryTime)
+	})
+}
+
+func TestUserFromAssertion(t *testing.T) {
+	idp := newTestKeyPair(t, "idp")
+	settings := testSettings()
+	provider, appErr := newServiceProvider(settings, configFiles(map[string][]byte{"saml-idp.crt": idp.certificatePEM}), nil)
+	require.Nil(t, appErr)
+	logger := mlog.CreateConsoleTestLogger(t)
+
+	assertion := func(attributes map[string][]string) *saml2.AssertionInfo {
+		assertionInfo, appErr := retrieveAssertion(provider, settings, newResponse(t, idp, attributes, responseOptions{}))
+		require.Nil(t, appErr)
+		return assertionInfo
+	}
+
+	t.Run("mapped attributes", func(t *testing.T) {
+		assertionInfo := assertion(testAttributes())
+
+		user, appErr := userFromAssertion(logger, settings, assertionInfo)
+		require.Nil(t, appErr)
+		assert.Equal(t, model.UserAuthServiceSaml, user.AuthService)
+		assert.Equal(t, "f1b2c3", *user.AuthData)
+		assert.Equal(t, "alice@example.com", user.Email)
+		assert.Equal(t, "alice", user.Username)
+		assert.Equal(t, "Alice", user.FirstName)
+		assert.Equal(t, "Liddell", user.LastName)
+		assert.Equal(t, "Engineer", user.Position)
+		assert.Empty(t, user.Nickname)
+		assert.True(t, user.EmailVerified)
+
+		assert.True(t, hasAttribute(assertionInfo.Values, *settings.AdminAttribute))
+		assert.False(t, hasAttribute(assertionInfo.Values, *settings.GuestAttribute))
+		assert.False(t, hasAttribute(assertionInfo.Values, "role"))
+	})
+
+	t.Run("email as auth data", func(t *testing.T) {
+		idSettings := testSettings()
+		idSettings.IdAttribute = model.NewPointer("")
+
+		user, appErr := userFromAssertion(logger, idSettings, assertion(testAttributes()))
+		require.Nil(t, appErr)
+		assert.Equal(t, "alice@example.com", *user.AuthData)
+	})
+
+	t.Run("missing attribute", func(t *testing.T) {
+		attributes := testAttributes()
+		delete(attributes, "username")
+
+		_, appErr := userFromAssertion(logger, settings, assertion(attributes))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "ent.saml.attribute.app_error", appErr.Id)
+	})
+
+	t.Run("applied attributes", func(t *testing.T) {
+		samlUser, appErr := userFromAssertion(logger, settings, assertion(testAttributes()))
+		require.Nil(t, appErr)
+
+		user := &model.User{Username: "alice", Email: "alice@example.com", FirstName: "Alice", LastName: "Liddell", Position: "Intern", Nickname: "kept", Locale: "fr"}
+		assert.True(t, applyAttributes(settings, user, samlUser))
+		assert.Equal(t, "Engineer", user.Position)
+		assert.Equal(t, "kept", user.Nickname)
+		assert.Equal(t, "fr", user.Locale)
+		assert.False(t, applyAttributes(settings, user, samlUser))
+	})
+
+	t.Run("username is cleaned", func(t *testing.T) {
+		attributes := testAttributes()
+		attributes["username"] = []string{"Alice Liddell"}
+
+		user, appErr := userFromAssertion(logger, settings, assertion(attributes))
+		require.Nil(t, appErr)
+		assert.True(t, model.IsValidUsername(user.Username))
+		assert.True(t, strings.HasPrefix(user.Username, "alice"))
+	})
+}
@@ -11,8 +11,11 @@ import (
 	"time"
 
 	"github.com/mattermost/mattermost-read-index-service/internal/api"
+	"github.com/mattermost/mattermost-read-index-service/internal/bootstrap"
 	"github.com/mattermost/mattermost-read-index-service/internal/consumer"
 	"github.com/mattermost/mattermost-read-index-service/internal/index"
+	"github.com/mattermost/mattermost-read-index-service/internal/shard"
+	"github.com/mattermost/mattermost-read-index-service/internal/snapshot"
 )
 
 func main() {
@@ -21,28 +24,102 @@ func main() {
 	// 配置
 	redisURL := getEnv("REDIS_URL", "redis://localhost:6379/0")
 	port := getEnv("PORT", "8066")
-	windowSize := getEnvInt("WINDOW_SIZE", 1000)
+	segmentSize := getEnvInt("SEGMENT_SIZE", int(index.DefaultSegmentSize))
+	snapshotDir := getEnv("SNAPSHOT_DIR", "./data")
+	snapshotInterval := getEnvDuration("SNAPSHOT_INTERVAL", time.Minute)
+	databaseURL := getEnv("DATABASE_URL", "")         // 只读 Postgres 连接，用于冷启动重建
+	bootstrapMode := getEnv("BOOTSTRAP_MODE", "auto") // auto | always | never
+	adminToken := getEnv("ADMIN_TOKEN", "")
+	shardID := getEnv("SHARD_ID", "")       // 本副本的 ID，需出现在 SHARD_PEERS 中
+	shardPeers := getEnv("SHARD_PEERS", "") // 全部副本：replica-0=http://host0:8066,replica-1=http://host1:8066
+	consumerName := getEnv("CONSUMER_NAME", defaultConsumerName())
+	claimMinIdle := getEnvDuration("CLAIM_MIN_IDLE", consumer.DefaultClaimMinIdle)
+	lockTTL := getEnvDuration("CONSUMER_LOCK_TTL", consumer.DefaultLockTTL)
+
+	// 分片
+	peers, err := shard.ParsePeers(shardPeers)
+	if err != nil {
+		log.Fatalf("Invalid SHARD_PEERS: %v", err)
+	}
+	ring, err := shard.NewRing(shardID, peers, shard.DefaultVirtualNodes)
+	if err != nil {
+		log.Fatalf("Invalid shard configuration: %v", err)
+	}
+
+	groupName := consumer.DefaultGroupName
+	if ring.Sharded() {
+		// 每个分片使用独立的消费者组，各自收到完整的 Stream 并只处理自己负责的频道
+		groupName = consumer.DefaultGroupName + "-" + shardID
+		log.Printf("Sharding enabled: shard=%s, nodes=%v", shardID, ring.Nodes())
+	}
 
 	// 创建索引服务
-	indexService := index.NewService(windowSize)
+	indexService := index.NewService(int64(segmentSize))
 
 	// 创建 Redis 消费者
-	redisConsumer := consumer.NewRedisConsumer(redisURL, indexService)
+	redisConsumer := consumer.NewRedisConsumer(redisURL, indexService, consumer.Options{
+		GroupName:    groupName,
+		ConsumerName: consumerName,
+		Owns:         ring.Owns,
+		ClaimMinIdle: claimMinIdle,
+		LockTTL:      lockTTL,
+	})
+
+	// 创建快照管理器
+	snapshotManager := snapshot.NewManager(snapshotDir, snapshotInterval, indexService, redisConsumer, ring)
+
+	// 创建数据库重建器（可选）
+	var rebuilder *bootstrap.Rebuilder
+	if databaseURL != "" {
+		rebuilder, err = bootstrap.NewRebuilder(databaseURL, indexService, ring.Owns)
+		if err != nil {
+			log.Fatalf("Failed to open database: %v", err)
+		}
+		defer rebuilder.Close()
+	}
 
 	// 创建 HTTP API
 	apiServer := api.NewServer(indexService, port)
+	if ring.Sharded() {
+		apiServer.SetRing(ring)
+	}
+	if rebuilder != nil {
+		if err := apiServer.SetRebuilder(rebuilder, adminToken); err != nil {
+			log.Printf("Rebuild endpoint disabled: %v", err)
+		}
+	}
 
 	// 启动服务
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// 加载快照并回放快照之后的事件
+	streamID, err := snapshotManager.Load()
+	if err != nil {
+		log.Printf("Failed to load snapshot, starting with empty index: %v", err)
+	}
+
+	if needsRebuild(ctx, bootstrapMode, streamID, redisConsumer) && rebuilder != nil {
+		// 数据库是最新的源头，重建后直接从消费者组继续消费即可
+		if err := rebuilder.RebuildAll(ctx); err != nil {
+			log.Fatalf("Failed to rebuild index from database: %v", err)
+		}
+	} else if _, err := redisConsumer.Replay(ctx, streamID); err != nil {
+		log.Printf("Failed to replay stream: %v", err)
+	}
+
 	// 启动 Redis 消费者
+	consumerDone := make(chan struct{})
 	go func() {
+		defer close(consumerDone)
 		if err := redisConsumer.Start(ctx); err != nil {
 			log.Printf("Redis consumer error: %v", err)
 		}
 	}()
 
+	// 定期保存快照
+	go snapshotManager.Start(ctx)
+
 	// 启动 HTTP 服务器
 	go func() {
 		log.Printf("HTTP server listening on :%s", port)
@@ -65,9 +142,49 @@ func main() {
 	}
 
 	cancel() // 停止 Redis 消费者
+	<-consumerDone
+
+	if err := snapshotManager.Save(); err != nil {
+		log.Printf("Failed to save final snapshot: %v", err)
+	}
+
 	log.Println("Service stopped")
 }
 
+// streamPosition 判断 Stream 中某个位置之后的事件是否已被裁剪
+type streamPosition interface {
+	TrimmedSince(ctx context.Context, id string) (bool, error)
+}
+
+// needsRebuild 判断启动时是否需要从数据库重建：没有快照或快照之后的事件已被裁剪
+func needsRebuild(ctx context.Context, mode, streamID string, stream streamPosition) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+
+	if streamID == "" {
+		return true
+	}
+
+	trimmed, err := stream.TrimmedSince(ctx, streamID)
+	if err != nil {
+		log.Printf("Failed to check stream position: %v", err)
+		return false
+	}
+	return trimmed
+}
+
+// defaultConsumerName 使用主机名作为消费者名，使替换同一分片的实例时新旧实例互不冲突
+func defaultConsumerName() string {
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		return hostname
+	}
+	return consumer.DefaultConsumerName
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -84,3 +201,12 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type fakeStreamPosition struct {
+	trimmed bool
+	err     error
+}
+
+func (f fakeStreamPosition) TrimmedSince(ctx context.Context, id string) (bool, error) {
+	return f.trimmed, f.err
+}
+
+func TestNeedsRebuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		streamID string
+		stream   fakeStreamPosition
+		expected bool
+	}{
+		{name: "always rebuilds with a snapshot", mode: "always", streamID: "1-0", expected: true},
+		{name: "never rebuilds without a snapshot", mode: "never", streamID: "", stream: fakeStreamPosition{trimmed: true}},
+		{name: "auto rebuilds without a snapshot", mode: "auto", streamID: "", expected: true},
+		{name: "auto replays a snapshot still covered by the stream", mode: "auto", streamID: "1-0"},
+		{name: "auto rebuilds when the stream was trimmed past the snapshot", mode: "auto", streamID: "1-0", stream: fakeStreamPosition{trimmed: true}, expected: true},
+		{name: "auto replays when the stream position can't be checked", mode: "auto", streamID: "1-0", stream: fakeStreamPosition{err: errors.New("unavailable")}},
+		{name: "unknown modes behave as auto", mode: "", streamID: "", expected: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := needsRebuild(context.Background(), tt.mode, tt.streamID, tt.stream); got != tt.expected {
+				t.Fatalf("needsRebuild(%q, %q) = %v, expected %v", tt.mode, tt.streamID, got, tt.expected)
+			}
+		})
+	}
+}
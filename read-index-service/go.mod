@@ -4,8 +4,10 @@ go 1.21
 
 require (
 	github.com/RoaringBitmap/roaring v1.9.0
+	github.com/alicebob/miniredis/v2 v2.37.0
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/gorilla/mux v1.8.1
+	github.com/lib/pq v1.10.9
 )
 
 require (
@@ -13,4 +15,5 @@ require (
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/mschoch/smat v0.2.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 )
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// ForwardedByHeader 标记请求已被其他副本转发过，收到该请求的副本只在本地处理，避免转发循环
+const ForwardedByHeader = "X-Read-Index-Forwarded-By"
+
+// forward 将不归本副本负责的频道请求转发给负责的副本，返回 true 表示请求已处理
+func (s *Server) forward(w http.ResponseWriter, r *http.Request, channelID string) bool {
+	if s.ring == nil || !s.ring.Sharded() || s.ring.Owns(channelID) {
+		return false
+	}
+
+	owner := s.ring.Owner(channelID)
+	if from := r.Header.Get(ForwardedByHeader); from != "" {
+		// 转发方认为本副本负责该频道，说明两边的 SHARD_PEERS 不一致
+		log.Printf("Rejecting request for channel %s forwarded by %s: owned by %s", channelID, from, owner)
+		http.Error(w, fmt.Sprintf("channel %s is owned by %s, check SHARD_PEERS", channelID, owner), http.StatusMisdirectedRequest)
+		return true
+	}
+
+	proxy, ok := s.proxies[owner]
+	if !ok {
+		http.Error(w, fmt.Sprintf("no route to shard %s", owner), http.StatusBadGateway)
+		return true
+	}
+
+	r.Header.Set(ForwardedByHeader, s.ring.Self())
+	proxy.ServeHTTP(w, r)
+	return true
+}
+
+func newPeerProxy(target *url.URL) *httputil.ReverseProxy {
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		log.Printf("Error forwarding %s to %s: %v", r.URL.Path, target, err)
+		http.Error(w, "Shard unavailable", http.StatusBadGateway)
+	}
+	return proxy
+}
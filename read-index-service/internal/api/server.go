@@ -1,18 +1,33 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"crypto/subtle"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"io"
 	"net/http"
+	"net/http/httputil"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 	"github.com/mattermost/mattermost-read-index-service/internal/index"
+	"github.com/mattermost/mattermost-read-index-service/internal/shard"
 )
 
+// ChannelRebuilder 从数据源头重建单个频道的索引
+type ChannelRebuilder interface {
+	RebuildChannel(ctx context.Context, channelID string) (int, error)
+}
+
 type Server struct {
 	indexService *index.Service
+	rebuilder    ChannelRebuilder
+	adminToken   string
+	ring         *shard.Ring
+	proxies      map[string]*httputil.ReverseProxy
 	router       *mux.Router
 	httpServer   *http.Server
 	port         string
@@ -25,8 +40,17 @@ type ReadersResponse struct {
 }
 
 type ReadCountsRequest struct {
-	ChannelID string  `json:"channel_id"`
-	Seqs      []int64 `json:"seqs"`
+	ChannelID string           `json:"channel_id"`
+	Seqs      []int64          `json:"seqs"`
+	Queries   []ReadCountQuery `json:"queries,omitempty"`
+}
+
+// ReadCountQuery 单条计数查询，ExcludeUserID（通常是消息作者）不计入已读人数。
+// RootID 非空时查询的是线程中的回复，线程游标同样计入
+type ReadCountQuery struct {
+	Seq           int64  `json:"seq"`
+	RootID        string `json:"root_id,omitempty"`
+	ExcludeUserID string `json:"exclude_user_id,omitempty"`
 }
 
 func NewServer(indexService *index.Service, port string) *Server {
@@ -39,6 +63,31 @@ func NewServer(indexService *index.Service, port string) *Server {
 	return s
 }
 
+// SetRebuilder 启用管理端重建接口。接口需要 adminToken 认证，adminToken 为空时拒绝启用
+func (s *Server) SetRebuilder(rebuilder ChannelRebuilder, adminToken string) error {
+	if adminToken == "" {
+		return errors.New("an admin token is required to enable the rebuild endpoint")
+	}
+
+	s.rebuilder = rebuilder
+	s.adminToken = adminToken
+
+	// Admin: rebuild a single channel from the database
+	s.router.HandleFunc("/rebuild", s.handleRebuild).Methods("POST")
+	return nil
+}
+
+// SetRing 启用分片路由：不归本副本负责的频道请求会被转发给负责的副本
+func (s *Server) SetRing(ring *shard.Ring) {
+	s.ring = ring
+	s.proxies = make(map[string]*httputil.ReverseProxy)
+	for _, id := range ring.Nodes() {
+		if id != ring.Self() {
+			s.proxies[id] = newPeerProxy(ring.PeerURL(id))
+		}
+	}
+}
+
 func (s *Server) setupRoutes() {
 	s.router = mux.NewRouter()
 
@@ -82,6 +131,10 @@ func (s *Server) handleGetReaders(w http.ResponseWriter, r *http.Request) {
 	channelID := vars["channel_id"]
 	seqStr := vars["seq"]
 
+	if s.forward(w, r, channelID) {
+		return
+	}
+
 	seq, err := strconv.ParseInt(seqStr, 10, 64)
 	if err != nil {
 		http.Error(w, "Invalid sequence number", http.StatusBadRequest)
@@ -95,12 +148,43 @@ func (s *Server) handleGetReaders(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	readers, count, err := s.indexService.GetReadersForSeq(channelID, seq, limit)
+	excludeUserID := r.URL.Query().Get("exclude_user_id")
+	rootID := r.URL.Query().Get("root_id")
+	fetchLimit := limit
+	if excludeUserID != "" {
+		fetchLimit++ // 多取一个，过滤掉被排除的用户后仍能返回 limit 个
+	}
+
+	var (
+		readers []string
+		count   int
+	)
+	if rootID != "" {
+		readers, count, err = s.indexService.GetThreadReadersForSeq(channelID, rootID, seq, fetchLimit)
+	} else {
+		readers, count, err = s.indexService.GetReadersForSeq(channelID, seq, fetchLimit)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	if excludeUserID != "" {
+		filtered := make([]string, 0, len(readers))
+		for _, userID := range readers {
+			if userID != excludeUserID {
+				filtered = append(filtered, userID)
+			}
+		}
+		if s.isReader(channelID, rootID, excludeUserID, seq) {
+			count--
+		}
+		if len(filtered) > limit {
+			filtered = filtered[:limit]
+		}
+		readers = filtered
+	}
+
 	resp := ReadersResponse{
 		Count:     count,
 		Readers:   readers,
@@ -112,21 +196,104 @@ func (s *Server) handleGetReaders(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleGetReadCounts(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
 	var req ReadCountsRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := json.Unmarshal(body, &req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	// 转发时需要重新提供已读取的请求体
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	if s.forward(w, r, req.ChannelID) {
+		return
+	}
+
+	// 新格式：按 queries 顺序返回计数数组
+	if len(req.Queries) > 0 {
+		// 按线程分组查询，频道级查询的 root_id 为空
+		seqsByRoot := make(map[string][]int64)
+		for _, q := range req.Queries {
+			seqsByRoot[q.RootID] = append(seqsByRoot[q.RootID], q.Seq)
+		}
+		bySeq := make(map[string]map[int64]int, len(seqsByRoot))
+		for rootID, seqs := range seqsByRoot {
+			if rootID == "" {
+				bySeq[rootID] = s.indexService.GetReadCounts(req.ChannelID, seqs)
+			} else {
+				bySeq[rootID] = s.indexService.GetThreadReadCounts(req.ChannelID, rootID, seqs)
+			}
+		}
+
+		counts := make([]int, len(req.Queries))
+		for i, q := range req.Queries {
+			counts[i] = bySeq[q.RootID][q.Seq]
+			if q.ExcludeUserID != "" && s.isReader(req.ChannelID, q.RootID, q.ExcludeUserID, q.Seq) {
+				counts[i]--
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(counts)
+		return
+	}
+
 	counts := s.indexService.GetReadCounts(req.ChannelID, req.Seqs)
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(counts)
 }
 
+// isReader 判断用户是否读过频道消息，rootID 非空时为线程中的回复
+func (s *Server) isReader(channelID, rootID, userID string, seq int64) bool {
+	if rootID != "" {
+		return s.indexService.IsThreadReader(channelID, rootID, userID, seq)
+	}
+	return s.indexService.IsReader(channelID, userID, seq)
+}
+
 func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
 	stats := s.indexService.GetStats()
+	if s.ring != nil && s.ring.Sharded() {
+		stats["shard_id"] = s.ring.Self()
+		stats["shard_nodes"] = s.ring.Nodes()
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(stats)
 }
+
+func (s *Server) handleRebuild(w http.ResponseWriter, r *http.Request) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	channelID := r.URL.Query().Get("channel_id")
+	if channelID == "" {
+		http.Error(w, "Missing channel_id", http.StatusBadRequest)
+		return
+	}
+
+	if s.forward(w, r, channelID) {
+		return
+	}
+
+	cursors, err := s.rebuilder.RebuildChannel(r.Context(), channelID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channel_id": channelID,
+		"cursors":    cursors,
+	})
+}
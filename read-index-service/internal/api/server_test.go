@@ -0,0 +1,191 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/mattermost/mattermost-read-index-service/internal/index"
+	"github.com/mattermost/mattermost-read-index-service/internal/shard"
+)
+
+// startReplicas 启动 n 个分片副本，每个副本只索引自己负责的频道
+func startReplicas(t *testing.T, n int) ([]*Server, []*httptest.Server, []*shard.Ring) {
+	t.Helper()
+
+	handlers := make([]http.Handler, n)
+	httpServers := make([]*httptest.Server, n)
+	peers := make(map[string]*url.URL)
+	for i := 0; i < n; i++ {
+		i := i
+		httpServers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handlers[i].ServeHTTP(w, r)
+		}))
+		t.Cleanup(httpServers[i].Close)
+
+		u, err := url.Parse(httpServers[i].URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		peers[fmt.Sprintf("replica-%d", i)] = u
+	}
+
+	servers := make([]*Server, n)
+	rings := make([]*shard.Ring, n)
+	for i := 0; i < n; i++ {
+		ring, err := shard.NewRing(fmt.Sprintf("replica-%d", i), peers, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		servers[i] = NewServer(index.NewService(0), "")
+		servers[i].SetRing(ring)
+		handlers[i] = servers[i].router
+		rings[i] = ring
+	}
+
+	return servers, httpServers, rings
+}
+
+func TestQueriesAreRoutedToOwningShard(t *testing.T) {
+	servers, httpServers, rings := startReplicas(t, 3)
+
+	// 事件只由负责的副本处理
+	channels := make([]string, 0)
+	for i := 0; i < 20; i++ {
+		channelID := fmt.Sprintf("channel-%d", i)
+		channels = append(channels, channelID)
+		for j, s := range servers {
+			if !rings[j].Owns(channelID) {
+				continue
+			}
+			for u := 0; u <= i; u++ {
+				err := s.indexService.HandleEvent(&index.ReadCursorEvent{ChannelID: channelID, UserID: fmt.Sprintf("u%d", u), NewLastSeq: 100})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+
+	for i, channelID := range channels {
+		for _, hs := range httpServers {
+			resp, err := http.Get(hs.URL + "/channels/" + channelID + "/posts/100/readers?limit=100&exclude_user_id=u0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var readers ReadersResponse
+			err = json.NewDecoder(resp.Body).Decode(&readers)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if readers.Count != i || len(readers.Readers) != i {
+				t.Fatalf("%s via %s: expected %d readers, got %+v", channelID, hs.URL, i, readers)
+			}
+
+			body, _ := json.Marshal(ReadCountsRequest{ChannelID: channelID, Queries: []ReadCountQuery{{Seq: 100}, {Seq: 101}}})
+			resp, err = http.Post(hs.URL+"/read-counts", "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var counts []int
+			err = json.NewDecoder(resp.Body).Decode(&counts)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(counts) != 2 || counts[0] != i+1 || counts[1] != 0 {
+				t.Fatalf("%s via %s: unexpected counts %v", channelID, hs.URL, counts)
+			}
+		}
+	}
+}
+
+func TestForwardedRequestIsNotForwardedAgain(t *testing.T) {
+	_, httpServers, rings := startReplicas(t, 2)
+
+	// 找一个不归 replica-0 负责的频道，模拟被错误转发到 replica-0 的请求
+	channelID := ""
+	for i := 0; channelID == ""; i++ {
+		if id := fmt.Sprintf("channel-%d", i); !rings[0].Owns(id) {
+			channelID = id
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, httpServers[0].URL+"/channels/"+channelID+"/posts/1/readers", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(ForwardedByHeader, "replica-1")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMisdirectedRequest {
+		t.Fatalf("expected %d, got %d", http.StatusMisdirectedRequest, resp.StatusCode)
+	}
+}
+
+type fakeRebuilder struct {
+	rebuilt []string
+}
+
+func (r *fakeRebuilder) RebuildChannel(ctx context.Context, channelID string) (int, error) {
+	r.rebuilt = append(r.rebuilt, channelID)
+	return 1, nil
+}
+
+func TestRebuildRequiresAdminToken(t *testing.T) {
+	t.Run("not enabled without a token", func(t *testing.T) {
+		s := NewServer(index.NewService(0), "")
+		if err := s.SetRebuilder(&fakeRebuilder{}, ""); err == nil {
+			t.Fatal("expected the rebuild endpoint to be refused without a token")
+		}
+
+		rec := httptest.NewRecorder()
+		s.router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rebuild?channel_id=c1", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("expected %d, got %d", http.StatusNotFound, rec.Code)
+		}
+	})
+
+	rebuilder := &fakeRebuilder{}
+	s := NewServer(index.NewService(0), "")
+	if err := s.SetRebuilder(rebuilder, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		authorization string
+		status        int
+	}{
+		"missing token": {"", http.StatusUnauthorized},
+		"wrong token":   {"Bearer wrong", http.StatusUnauthorized},
+		"not a bearer":  {"secret", http.StatusUnauthorized},
+		"valid token":   {"Bearer secret", http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/rebuild?channel_id=c1", nil)
+			if tc.authorization != "" {
+				req.Header.Set("Authorization", tc.authorization)
+			}
+
+			rec := httptest.NewRecorder()
+			s.router.ServeHTTP(rec, req)
+			if rec.Code != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, rec.Code)
+			}
+		})
+	}
+
+	if len(rebuilder.rebuilt) != 1 || rebuilder.rebuilt[0] != "c1" {
+		t.Fatalf("expected only the authorized request to rebuild, got %v", rebuilder.rebuilt)
+	}
+}
@@ -0,0 +1,324 @@
+package bootstrap
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	_ "github.com/lib/pq"
+
+	"github.com/mattermost/mattermost-read-index-service/internal/index"
+)
+
+const defaultPageSize = 5000
+
+// cursorRow 游标表中的一行，频道游标的 RootID 为空
+type cursorRow struct {
+	ChannelID string
+	RootID    string
+	UserID    string
+	Seq       int64
+}
+
+// cursorSource 按键集分页读取全部游标，RebuildAll 只依赖这两个操作
+type cursorSource interface {
+	// channelCursorsAfter 按 (channel_id, user_id) 排序返回键大于 after 的至多 limit 个频道游标
+	channelCursorsAfter(ctx context.Context, after cursorRow, limit int) ([]cursorRow, error)
+	// threadCursorsAfter 按 (channel_id, root_id, user_id) 排序返回键大于 after 的至多 limit 个线程游标
+	threadCursorsAfter(ctx context.Context, after cursorRow, limit int) ([]cursorRow, error)
+}
+
+// Rebuilder 从 Mattermost 的 channel_read_cursors 和 thread_read_cursors 表（数据源头）重建索引
+type Rebuilder struct {
+	db           *sql.DB
+	source       cursorSource
+	indexService *index.Service
+	owns         func(channelID string) bool
+	pageSize     int
+}
+
+// NewRebuilder 使用只读的 Postgres 连接创建重建器。
+// owns 判断频道是否归本副本负责，RebuildAll 会跳过其他频道；nil 表示负责所有频道。
+func NewRebuilder(databaseURL string, indexService *index.Service, owns func(channelID string) bool) (*Rebuilder, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(2)
+
+	return &Rebuilder{
+		db:           db,
+		source:       sqlCursorSource{db: db},
+		indexService: indexService,
+		owns:         owns,
+		pageSize:     defaultPageSize,
+	}, nil
+}
+
+// Close 关闭数据库连接
+func (r *Rebuilder) Close() error {
+	return r.db.Close()
+}
+
+// RebuildAll 按 (channel_id, user_id) 键集分页读取全部游标，逐个频道重建索引。
+// 内存中的现有状态（例如过时的快照）先全部丢弃，只在消费者启动之前调用
+func (r *Rebuilder) RebuildAll(ctx context.Context) error {
+	start := time.Now()
+	r.indexService.Retain(func(string) bool { return false })
+
+	var (
+		last      cursorRow
+		channelID string
+		cursors   = make(map[string]int64)
+		channels  int
+		total     int
+	)
+
+	flush := func() {
+		if channelID == "" {
+			return
+		}
+		if r.owns != nil && !r.owns(channelID) {
+			cursors = make(map[string]int64)
+			return
+		}
+		// 线程游标在之后的 rebuildAllThreads 中加载
+		r.indexService.RebuildChannel(channelID, cursors, nil)
+		channels++
+		cursors = make(map[string]int64)
+	}
+
+	for {
+		rows, err := r.source.channelCursorsAfter(ctx, last, r.pageSize)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			if row.ChannelID != channelID {
+				flush()
+				channelID = row.ChannelID
+			}
+			cursors[row.UserID] = row.Seq
+		}
+
+		total += len(rows)
+		if len(rows) < r.pageSize {
+			break
+		}
+		last = rows[len(rows)-1]
+	}
+	flush()
+
+	threadCursors, err := r.rebuildAllThreads(ctx)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Rebuilt index from database: channels=%d, cursors=%d, thread_cursors=%d, took=%s", channels, total, threadCursors, time.Since(start))
+	return nil
+}
+
+// rebuildAllThreads 按 (channel_id, root_id, user_id) 键集分页读取全部线程游标，逐个频道重建
+func (r *Rebuilder) rebuildAllThreads(ctx context.Context) (int, error) {
+	var (
+		last      cursorRow
+		channelID string
+		threads   = make(map[string]map[string]int64)
+		total     int
+	)
+
+	flush := func() {
+		if channelID != "" && (r.owns == nil || r.owns(channelID)) {
+			r.indexService.RebuildThreads(channelID, threads)
+		}
+		threads = make(map[string]map[string]int64)
+	}
+
+	for {
+		rows, err := r.source.threadCursorsAfter(ctx, last, r.pageSize)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, row := range rows {
+			if row.ChannelID != channelID {
+				flush()
+				channelID = row.ChannelID
+			}
+			addThreadCursor(threads, row.RootID, row.UserID, row.Seq)
+		}
+
+		total += len(rows)
+		if len(rows) < r.pageSize {
+			break
+		}
+		last = rows[len(rows)-1]
+	}
+	flush()
+
+	return total, nil
+}
+
+// threadCursors 读取频道的全部线程游标，同时返回游标数
+func (r *Rebuilder) threadCursors(ctx context.Context, channelID string) (map[string]map[string]int64, int, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT root_id, user_id, last_post_seq
+		FROM thread_read_cursors
+		WHERE channel_id = $1`, channelID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query thread cursors: %w", err)
+	}
+	defer rows.Close()
+
+	threads := make(map[string]map[string]int64)
+	n := 0
+	for rows.Next() {
+		var (
+			rootID string
+			userID string
+			seq    int64
+		)
+		if err := rows.Scan(&rootID, &userID, &seq); err != nil {
+			return nil, 0, fmt.Errorf("scan thread cursor: %w", err)
+		}
+		addThreadCursor(threads, rootID, userID, seq)
+		n++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("iterate thread cursors: %w", err)
+	}
+
+	return threads, n, nil
+}
+
+func addThreadCursor(threads map[string]map[string]int64, rootID, userID string, seq int64) {
+	if threads[rootID] == nil {
+		threads[rootID] = make(map[string]int64)
+	}
+	threads[rootID][userID] = seq
+}
+
+// RebuildChannel 重建单个频道的频道游标和线程游标，替换内存中的现有状态
+func (r *Rebuilder) RebuildChannel(ctx context.Context, channelID string) (int, error) {
+	r.indexService.BeginRebuild(channelID)
+	cursors, err := r.channelCursors(ctx, channelID)
+	if err != nil {
+		r.indexService.CancelRebuild(channelID)
+		return 0, err
+	}
+	threads, threadCursors, err := r.threadCursors(ctx, channelID)
+	if err != nil {
+		r.indexService.CancelRebuild(channelID)
+		return 0, err
+	}
+	r.indexService.RebuildChannel(channelID, cursors, threads)
+
+	log.Printf("Rebuilt channel %s from database: cursors=%d, thread_cursors=%d", channelID, len(cursors), threadCursors)
+	return len(cursors) + threadCursors, nil
+}
+
+// channelCursors 读取频道的全部游标
+func (r *Rebuilder) channelCursors(ctx context.Context, channelID string) (map[string]int64, error) {
+	cursors := make(map[string]int64)
+	lastUserID := ""
+
+	for {
+		rows, err := r.db.QueryContext(ctx, `
+			SELECT user_id, last_post_seq
+			FROM channel_read_cursors
+			WHERE channel_id = $1 AND user_id > $2
+			ORDER BY user_id
+			LIMIT $3`, channelID, lastUserID, r.pageSize)
+		if err != nil {
+			return nil, fmt.Errorf("query cursors: %w", err)
+		}
+
+		n := 0
+		for rows.Next() {
+			var (
+				userID string
+				seq    int64
+			)
+			if err := rows.Scan(&userID, &seq); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan cursor: %w", err)
+			}
+			cursors[userID] = seq
+			lastUserID = userID
+			n++
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("iterate cursors: %w", err)
+		}
+
+		if n < r.pageSize {
+			break
+		}
+	}
+
+	return cursors, nil
+}
+
+// sqlCursorSource 从 Postgres 分页读取游标
+type sqlCursorSource struct {
+	db *sql.DB
+}
+
+func (s sqlCursorSource) channelCursorsAfter(ctx context.Context, after cursorRow, limit int) ([]cursorRow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT channel_id, user_id, last_post_seq
+		FROM channel_read_cursors
+		WHERE (channel_id, user_id) > ($1, $2)
+		ORDER BY channel_id, user_id
+		LIMIT $3`, after.ChannelID, after.UserID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query cursors: %w", err)
+	}
+	defer rows.Close()
+
+	var cursors []cursorRow
+	for rows.Next() {
+		var row cursorRow
+		if err := rows.Scan(&row.ChannelID, &row.UserID, &row.Seq); err != nil {
+			return nil, fmt.Errorf("scan cursor: %w", err)
+		}
+		cursors = append(cursors, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate cursors: %w", err)
+	}
+
+	return cursors, nil
+}
+
+func (s sqlCursorSource) threadCursorsAfter(ctx context.Context, after cursorRow, limit int) ([]cursorRow, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT channel_id, root_id, user_id, last_post_seq
+		FROM thread_read_cursors
+		WHERE (channel_id, root_id, user_id) > ($1, $2, $3)
+		ORDER BY channel_id, root_id, user_id
+		LIMIT $4`, after.ChannelID, after.RootID, after.UserID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("query thread cursors: %w", err)
+	}
+	defer rows.Close()
+
+	var cursors []cursorRow
+	for rows.Next() {
+		var row cursorRow
+		if err := rows.Scan(&row.ChannelID, &row.RootID, &row.UserID, &row.Seq); err != nil {
+			return nil, fmt.Errorf("scan thread cursor: %w", err)
+		}
+		cursors = append(cursors, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate thread cursors: %w", err)
+	}
+
+	return cursors, nil
+}
@@ -0,0 +1,126 @@
+package bootstrap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/mattermost/mattermost-read-index-service/internal/index"
+)
+
+// fakeSource 内存中的游标表，按键集分页返回，并记录读取的页数
+type fakeSource struct {
+	channelRows []cursorRow
+	threadRows  []cursorRow
+	pages       int
+	err         error
+}
+
+func (f *fakeSource) page(rows []cursorRow, after cursorRow, limit int) ([]cursorRow, error) {
+	f.pages++
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	key := func(row cursorRow) string {
+		return row.ChannelID + "\x00" + row.RootID + "\x00" + row.UserID
+	}
+	sort.Slice(rows, func(i, j int) bool { return key(rows[i]) < key(rows[j]) })
+
+	var result []cursorRow
+	for _, row := range rows {
+		if key(row) > key(after) && len(result) < limit {
+			result = append(result, row)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeSource) channelCursorsAfter(ctx context.Context, after cursorRow, limit int) ([]cursorRow, error) {
+	return f.page(f.channelRows, after, limit)
+}
+
+func (f *fakeSource) threadCursorsAfter(ctx context.Context, after cursorRow, limit int) ([]cursorRow, error) {
+	return f.page(f.threadRows, after, limit)
+}
+
+func TestRebuildAll(t *testing.T) {
+	source := &fakeSource{}
+	for c := 0; c < 4; c++ {
+		for u := 0; u < 3; u++ {
+			channelID := fmt.Sprintf("c%d", c)
+			source.channelRows = append(source.channelRows, cursorRow{ChannelID: channelID, UserID: fmt.Sprintf("u%d", u), Seq: int64(100 * (u + 1))})
+			source.threadRows = append(source.threadRows, cursorRow{ChannelID: channelID, RootID: "root", UserID: fmt.Sprintf("t%d", u), Seq: 500})
+		}
+	}
+
+	indexService := index.NewService(0)
+	// 过时的状态在重建时被丢弃
+	if err := indexService.HandleEvent(&index.ReadCursorEvent{ChannelID: "stale", UserID: "u1", NewLastSeq: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 页大小与每个频道的游标数不对齐，频道会跨页
+	r := &Rebuilder{
+		source:       source,
+		indexService: indexService,
+		owns:         func(channelID string) bool { return channelID != "c2" },
+		pageSize:     5,
+	}
+	if err := r.RebuildAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每张表 12 行，每页 5 行，共读取 3 页
+	if source.pages != 6 {
+		t.Fatalf("expected 6 pages read, got %d", source.pages)
+	}
+	if indexService.IsReader("stale", "u1", 0) {
+		t.Fatal("expected the stale channel to be dropped")
+	}
+
+	for c := 0; c < 4; c++ {
+		channelID := fmt.Sprintf("c%d", c)
+		owned := channelID != "c2"
+
+		_, count, err := indexService.GetReadersForSeq(channelID, 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if owned && count != 3 {
+			t.Fatalf("expected 3 readers in %s, got %d", channelID, count)
+		}
+		if !owned && count != 0 {
+			t.Fatalf("expected %s not owned to be skipped, got %d readers", channelID, count)
+		}
+
+		for u := 0; u < 3; u++ {
+			if got := indexService.IsThreadReader(channelID, "root", fmt.Sprintf("t%d", u), 500); got != owned {
+				t.Fatalf("expected thread cursor of t%d in %s loaded to be %v", u, channelID, owned)
+			}
+		}
+	}
+}
+
+func TestRebuildAllEmpty(t *testing.T) {
+	source := &fakeSource{}
+	r := &Rebuilder{source: source, indexService: index.NewService(0), pageSize: 5}
+
+	if err := r.RebuildAll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if source.pages != 2 {
+		t.Fatalf("expected one page read per table, got %d", source.pages)
+	}
+}
+
+func TestRebuildAllError(t *testing.T) {
+	source := &fakeSource{err: errors.New("unavailable")}
+	r := &Rebuilder{source: source, indexService: index.NewService(0), pageSize: 5}
+
+	if err := r.RebuildAll(context.Background()); !errors.Is(err, source.err) {
+		t.Fatalf("expected the source error, got %v", err)
+	}
+}
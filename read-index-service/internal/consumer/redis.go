@@ -3,22 +3,76 @@ package consumer
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/mattermost/mattermost-read-index-service/internal/index"
 )
 
+const (
+	DefaultGroupName    = "read-index-service"
+	DefaultConsumerName = "consumer-1"
+	DefaultClaimMinIdle = time.Minute
+	DefaultLockTTL      = 30 * time.Second
+)
+
+var (
+	// renewLockScript 只在锁仍由本消费者持有时延长锁
+	renewLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	// releaseLockScript 只在锁仍由本消费者持有时删除锁
+	releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+// Options 消费者配置
+type Options struct {
+	// GroupName 消费者组名。分片部署时每个分片使用独立的组，都能收到完整的 Stream
+	GroupName string
+	// ConsumerName 组内的消费者名，替换实例时新旧实例需不同
+	ConsumerName string
+	// Owns 判断本副本是否负责该频道，nil 表示负责所有频道
+	Owns func(channelID string) bool
+	// ClaimMinIdle 其他消费者的未确认消息空闲超过该时长后被本消费者接管
+	ClaimMinIdle time.Duration
+	// LockTTL 消费者锁的有效期。持有者停止续期超过该时长后，同一分片的其他实例才能接替消费
+	LockTTL time.Duration
+}
+
+// streamRanger 按 ID 区间读取 Stream，回放只依赖这一操作
+type streamRanger interface {
+	XRangeN(ctx context.Context, stream, start, stop string, count int64) *redis.XMessageSliceCmd
+}
+
 type RedisConsumer struct {
 	client       *redis.Client
+	stream       streamRanger
 	indexService *index.Service
 	streamName   string
 	groupName    string
 	consumerName string
+	owns         func(channelID string) bool
+	claimMinIdle time.Duration
+	lockTTL      time.Duration
+
+	// lockedUntil 本消费者持有的锁最晚的过期时间，只在 Start 中使用
+	lockedUntil time.Time
+
+	lastAckedID string
+	mu          sync.RWMutex
 }
 
-func NewRedisConsumer(redisURL string, indexService *index.Service) *RedisConsumer {
+func NewRedisConsumer(redisURL string, indexService *index.Service, opts Options) *RedisConsumer {
 	opt, err := redis.ParseURL(redisURL)
 	if err != nil {
 		log.Fatalf("Failed to parse Redis URL: %v", err)
@@ -26,12 +80,29 @@ func NewRedisConsumer(redisURL string, indexService *index.Service) *RedisConsum
 
 	client := redis.NewClient(opt)
 
+	if opts.GroupName == "" {
+		opts.GroupName = DefaultGroupName
+	}
+	if opts.ConsumerName == "" {
+		opts.ConsumerName = DefaultConsumerName
+	}
+	if opts.ClaimMinIdle <= 0 {
+		opts.ClaimMinIdle = DefaultClaimMinIdle
+	}
+	if opts.LockTTL <= 0 {
+		opts.LockTTL = DefaultLockTTL
+	}
+
 	return &RedisConsumer{
 		client:       client,
+		stream:       client,
 		indexService: indexService,
 		streamName:   "read_cursor_events",
-		groupName:    "read-index-service",
-		consumerName: "consumer-1",
+		groupName:    opts.GroupName,
+		consumerName: opts.ConsumerName,
+		owns:         opts.Owns,
+		claimMinIdle: opts.ClaimMinIdle,
+		lockTTL:      opts.LockTTL,
 	}
 }
 
@@ -47,14 +118,45 @@ func (c *RedisConsumer) Start(ctx context.Context) error {
 	// 创建消费者组（如果不存在）
 	c.client.XGroupCreateMkStream(ctx, c.streamName, c.groupName, "0")
 
-	log.Printf("Starting Redis consumer: stream=%s, group=%s", c.streamName, c.groupName)
+	// 每个实例在内存中维护完整的索引，组内的多个消费者会瓜分 Stream，各自丢失对方读到的事件。
+	// 因此同一个组只允许一个消费者，其他实例等待持有者退出或锁过期后再接替
+	if err := c.acquireLock(ctx); err != nil {
+		log.Println("Redis consumer stopped")
+		return nil
+	}
+	defer c.releaseLock()
+
+	log.Printf("Starting Redis consumer: stream=%s, group=%s, consumer=%s", c.streamName, c.groupName, c.consumerName)
 
+	// 先处理本消费者已投递但未确认的消息（上次异常退出时遗留）
+	if err := c.consumePending(ctx); err != nil {
+		log.Printf("Error consuming pending messages: %v", err)
+	}
+
+	lastClaim := time.Time{}
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("Redis consumer stopped")
 			return nil
 		default:
+			if !c.holdLock(ctx) {
+				log.Printf("Lost the consumer lock of group %s, waiting to take it back", c.groupName)
+				if err := c.acquireLock(ctx); err != nil {
+					log.Println("Redis consumer stopped")
+					return nil
+				}
+				lastClaim = time.Time{}
+			}
+
+			// 定期接管组内崩溃消费者遗留的消息
+			if time.Since(lastClaim) >= c.claimMinIdle/2 {
+				if err := c.reclaim(ctx); err != nil {
+					log.Printf("Error reclaiming pending messages: %v", err)
+				}
+				lastClaim = time.Now()
+			}
+
 			if err := c.consumeBatch(ctx); err != nil {
 				log.Printf("Error consuming batch: %v", err)
 				time.Sleep(time.Second) // 错误后等待
@@ -63,6 +165,75 @@ func (c *RedisConsumer) Start(ctx context.Context) error {
 	}
 }
 
+// lockKey 消费者组的锁，值为持有者的消费者名
+func (c *RedisConsumer) lockKey() string {
+	return c.streamName + ":lock:" + c.groupName
+}
+
+// acquireLock 等待并获取消费者组的锁，直到 ctx 结束
+func (c *RedisConsumer) acquireLock(ctx context.Context) error {
+	waiting := false
+	for {
+		start := time.Now()
+		acquired, err := c.client.SetNX(ctx, c.lockKey(), c.consumerName, c.lockTTL).Result()
+		if err == nil && !acquired {
+			// 同名实例重启时锁可能仍由自己持有
+			var holder string
+			holder, err = c.client.Get(ctx, c.lockKey()).Result()
+			if err == nil && holder == c.consumerName {
+				acquired, err = c.client.PExpire(ctx, c.lockKey(), c.lockTTL).Result()
+			} else if err == nil && !waiting {
+				log.Printf("Group %s is consumed by %s, waiting for it to stop", c.groupName, holder)
+				waiting = true
+			}
+		}
+		if err != nil && err != redis.Nil {
+			log.Printf("Error acquiring the consumer lock: %v", err)
+		}
+		if err == nil && acquired {
+			c.lockedUntil = start.Add(c.lockTTL)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(c.lockTTL / 3):
+		}
+	}
+}
+
+// holdLock 每 lockTTL/3 续期一次锁，返回本消费者是否仍持有锁。
+// 续期失败时只要锁在下次续期前不会过期就继续消费，锁被其他实例持有时立即停止
+func (c *RedisConsumer) holdLock(ctx context.Context) bool {
+	renewAt := c.lockedUntil.Add(-c.lockTTL * 2 / 3)
+	if time.Now().Before(renewAt) {
+		return true
+	}
+
+	start := time.Now()
+	renewed, err := renewLockScript.Run(ctx, c.client, []string{c.lockKey()}, c.consumerName, c.lockTTL.Milliseconds()).Int64()
+	if err != nil {
+		log.Printf("Error renewing the consumer lock: %v", err)
+		return time.Now().Add(c.lockTTL / 3).Before(c.lockedUntil)
+	}
+	if renewed != 1 {
+		return false
+	}
+	c.lockedUntil = start.Add(c.lockTTL)
+	return true
+}
+
+// releaseLock 退出时释放锁，使等待的实例立即接替
+func (c *RedisConsumer) releaseLock() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := releaseLockScript.Run(ctx, c.client, []string{c.lockKey()}, c.consumerName).Err(); err != nil {
+		log.Printf("Error releasing the consumer lock: %v", err)
+	}
+}
+
 func (c *RedisConsumer) consumeBatch(ctx context.Context) error {
 	streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
 		Group:    c.groupName,
@@ -86,7 +257,7 @@ func (c *RedisConsumer) consumeBatch(ctx context.Context) error {
 				// 继续处理其他消息
 			} else {
 				// ACK 消息
-				c.client.XAck(ctx, c.streamName, c.groupName, message.ID)
+				c.ack(ctx, message.ID)
 			}
 		}
 	}
@@ -94,6 +265,228 @@ func (c *RedisConsumer) consumeBatch(ctx context.Context) error {
 	return nil
 }
 
+// consumePending 重新处理本消费者 PEL 中的消息
+func (c *RedisConsumer) consumePending(ctx context.Context) error {
+	for {
+		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.groupName,
+			Consumer: c.consumerName,
+			Streams:  []string{c.streamName, "0"},
+			Count:    100,
+		}).Result()
+		if err != nil {
+			if err == redis.Nil {
+				return nil
+			}
+			return err
+		}
+
+		processed := 0
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				if err := c.processMessage(ctx, message); err != nil {
+					log.Printf("Error processing pending message %s: %v", message.ID, err)
+					continue
+				}
+				c.ack(ctx, message.ID)
+				processed++
+			}
+		}
+		if processed == 0 {
+			return nil
+		}
+	}
+}
+
+// reclaim 使用 XAUTOCLAIM 接管组内空闲超过 claimMinIdle 的未确认消息。
+// 同一分片的实例被替换（例如主机名变化）后，旧消费者的 PEL 不会再被读取，需要由存活的消费者接管。
+func (c *RedisConsumer) reclaim(ctx context.Context) error {
+	start := "0-0"
+	claimed := 0
+	for {
+		messages, next, err := c.xAutoClaim(ctx, start, 100)
+		if err != nil {
+			return err
+		}
+
+		for _, message := range messages {
+			if err := c.processMessage(ctx, message); err != nil {
+				log.Printf("Error processing claimed message %s: %v", message.ID, err)
+				continue
+			}
+			c.ack(ctx, message.ID)
+			claimed++
+		}
+
+		if next == "0-0" || next == "" {
+			break
+		}
+		start = next
+	}
+
+	if claimed > 0 {
+		log.Printf("Reclaimed %d pending messages in group %s", claimed, c.groupName)
+	}
+	return nil
+}
+
+// xAutoClaim 执行 XAUTOCLAIM，返回接管的消息和下次扫描的起点。
+// go-redis v8 只能解析 Redis 6.2 的两元素回复，Redis 7 起回复末尾多了已删除的消息 ID，因此自行解析
+func (c *RedisConsumer) xAutoClaim(ctx context.Context, start string, count int64) ([]redis.XMessage, string, error) {
+	reply, err := c.client.Do(ctx, "XAUTOCLAIM", c.streamName, c.groupName, c.consumerName,
+		c.claimMinIdle.Milliseconds(), start, "COUNT", count).Slice()
+	if err != nil {
+		return nil, "", err
+	}
+	if len(reply) < 2 {
+		return nil, "", fmt.Errorf("unexpected XAUTOCLAIM reply with %d elements", len(reply))
+	}
+
+	next, ok := reply[0].(string)
+	if !ok {
+		return nil, "", fmt.Errorf("unexpected XAUTOCLAIM cursor %v", reply[0])
+	}
+	entries, ok := reply[1].([]interface{})
+	if !ok {
+		return nil, "", fmt.Errorf("unexpected XAUTOCLAIM entries %v", reply[1])
+	}
+
+	messages := make([]redis.XMessage, 0, len(entries))
+	for _, entry := range entries {
+		// Redis 6.2 以 nil 表示已从 Stream 删除的消息，它们同时被移出 PEL
+		fields, ok := entry.([]interface{})
+		if !ok || len(fields) != 2 {
+			continue
+		}
+		id, ok := fields[0].(string)
+		if !ok {
+			return nil, "", fmt.Errorf("unexpected XAUTOCLAIM message ID %v", fields[0])
+		}
+
+		values := make(map[string]interface{})
+		pairs, _ := fields[1].([]interface{})
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if key, ok := pairs[i].(string); ok {
+				values[key] = pairs[i+1]
+			}
+		}
+		messages = append(messages, redis.XMessage{ID: id, Values: values})
+	}
+
+	return messages, next, nil
+}
+
+// Replay 从 fromID（不含）开始按顺序重放 Stream 中的事件，用于快照加载后补齐状态。
+// 事件处理是幂等的（游标只前进），因此与之后的消费者组读取重叠不会产生错误结果。
+func (c *RedisConsumer) Replay(ctx context.Context, fromID string) (int, error) {
+	if fromID == "" {
+		fromID = "-"
+	} else {
+		trimmed, err := c.TrimmedSince(ctx, fromID)
+		if err != nil {
+			return 0, err
+		}
+		if trimmed {
+			log.Printf("Stream %s was trimmed past snapshot position %s, replay may be incomplete",
+				c.streamName, fromID)
+		}
+		fromID = "(" + fromID
+	}
+
+	replayed := 0
+	for {
+		messages, err := c.stream.XRangeN(ctx, c.streamName, fromID, "+", 1000).Result()
+		if err != nil {
+			return replayed, err
+		}
+		if len(messages) == 0 {
+			break
+		}
+
+		for _, message := range messages {
+			if err := c.processMessage(ctx, message); err != nil {
+				log.Printf("Error replaying message %s: %v", message.ID, err)
+			}
+			replayed++
+		}
+
+		last := messages[len(messages)-1].ID
+		c.setLastAckedID(last)
+		fromID = "(" + last
+	}
+
+	log.Printf("Replayed %d events from stream %s", replayed, c.streamName)
+	return replayed, nil
+}
+
+// TrimmedSince 判断 Stream 中最早的消息是否已晚于 id，即 id 之后的事件可能已被裁剪
+func (c *RedisConsumer) TrimmedSince(ctx context.Context, id string) (bool, error) {
+	first, err := c.stream.XRangeN(ctx, c.streamName, "-", "+", 1).Result()
+	if err != nil {
+		return false, err
+	}
+	if len(first) == 0 {
+		return false, nil
+	}
+	return streamIDLess(id, first[0].ID)
+}
+
+// LastAckedID 返回最后一条已处理并确认的消息 ID
+func (c *RedisConsumer) LastAckedID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.lastAckedID
+}
+
+func (c *RedisConsumer) ack(ctx context.Context, id string) {
+	if err := c.client.XAck(ctx, c.streamName, c.groupName, id).Err(); err != nil {
+		log.Printf("Error acking message %s: %v", id, err)
+		return
+	}
+	c.setLastAckedID(id)
+}
+
+func (c *RedisConsumer) setLastAckedID(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	less, err := streamIDLess(c.lastAckedID, id)
+	if err != nil {
+		log.Printf("Error updating last acked ID: %v", err)
+		return
+	}
+	if less {
+		c.lastAckedID = id
+	}
+}
+
+// streamIDLess 比较两个 Stream ID（格式 ms-seq），空 ID 小于任何 ID
+func streamIDLess(a, b string) (bool, error) {
+	if a == "" {
+		return b != "", nil
+	}
+	aMs, aSeq, err := parseStreamID(a)
+	if err != nil {
+		return false, err
+	}
+	bMs, bSeq, err := parseStreamID(b)
+	if err != nil {
+		return false, err
+	}
+	if aMs != bMs {
+		return aMs < bMs, nil
+	}
+	return aSeq < bSeq, nil
+}
+
+// parseStreamID 解析 ms-seq 格式的 Stream ID
+func parseStreamID(id string) (ms, seq int64, err error) {
+	var rest string
+	if n, _ := fmt.Sscanf(id, "%d-%d%s", &ms, &seq, &rest); n != 2 {
+		return 0, 0, fmt.Errorf("invalid stream ID %q", id)
+	}
+	return ms, seq, nil
+}
+
 func (c *RedisConsumer) processMessage(ctx context.Context, msg redis.XMessage) error {
 	data, ok := msg.Values["data"].(string)
 	if !ok {
@@ -105,13 +498,18 @@ func (c *RedisConsumer) processMessage(ctx context.Context, msg redis.XMessage)
 		return err
 	}
 
+	// 其他分片负责的频道直接跳过，消息仍会被确认
+	if c.owns != nil && !c.owns(event.ChannelID) {
+		return nil
+	}
+
 	// 处理事件
 	if err := c.indexService.HandleEvent(&event); err != nil {
 		return err
 	}
 
-	log.Printf("Processed event: channel=%s, user=%s, seq=%d", 
-		event.ChannelID, event.UserID, event.NewLastSeq)
+	log.Printf("Processed event: type=%s, channel=%s, root=%s, user=%s, seq=%d",
+		event.Type, event.ChannelID, event.RootID, event.UserID, event.NewLastSeq)
 
 	return nil
 }
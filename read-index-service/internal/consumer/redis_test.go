@@ -0,0 +1,369 @@
+package consumer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/mattermost/mattermost-read-index-service/internal/index"
+)
+
+func TestParseStreamID(t *testing.T) {
+	tests := []struct {
+		id      string
+		ms, seq int64
+		valid   bool
+	}{
+		{id: "1700000000000-0", ms: 1700000000000, valid: true},
+		{id: "5-12", ms: 5, seq: 12, valid: true},
+		{id: ""},
+		{id: "abc"},
+		{id: "12"},
+		{id: "12-"},
+		{id: "1-2x"},
+		{id: "1-2 3"},
+		{id: "-"},
+	}
+
+	for _, tt := range tests {
+		ms, seq, err := parseStreamID(tt.id)
+		if !tt.valid {
+			if err == nil {
+				t.Errorf("parseStreamID(%q): expected an error", tt.id)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseStreamID(%q): %v", tt.id, err)
+			continue
+		}
+		if ms != tt.ms || seq != tt.seq {
+			t.Errorf("parseStreamID(%q) = %d, %d, expected %d, %d", tt.id, ms, seq, tt.ms, tt.seq)
+		}
+	}
+}
+
+func TestStreamIDLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		less bool
+	}{
+		{a: "", b: "1-0", less: true},
+		{a: "", b: ""},
+		{a: "1-0", b: "1-1", less: true},
+		{a: "1-1", b: "1-0"},
+		{a: "2-0", b: "10-0", less: true},
+		{a: "10-0", b: "2-5"},
+		{a: "3-3", b: "3-3"},
+	}
+
+	for _, tt := range tests {
+		less, err := streamIDLess(tt.a, tt.b)
+		if err != nil {
+			t.Errorf("streamIDLess(%q, %q): %v", tt.a, tt.b, err)
+			continue
+		}
+		if less != tt.less {
+			t.Errorf("streamIDLess(%q, %q) = %v, expected %v", tt.a, tt.b, less, tt.less)
+		}
+	}
+
+	if _, err := streamIDLess("1-0", "bogus"); err == nil {
+		t.Error("expected an error for an invalid stream ID")
+	}
+}
+
+// fakeStream 内存中的 Stream，按 XRANGE 的语义返回消息
+type fakeStream struct {
+	messages []redis.XMessage
+}
+
+func (f *fakeStream) add(t *testing.T, id string, event index.ReadCursorEvent) {
+	t.Helper()
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.messages = append(f.messages, redis.XMessage{ID: id, Values: map[string]interface{}{"data": string(data)}})
+}
+
+func (f *fakeStream) XRangeN(ctx context.Context, stream, start, stop string, count int64) *redis.XMessageSliceCmd {
+	if stop != "+" {
+		return redis.NewXMessageSliceCmdResult(nil, fmt.Errorf("unsupported stop %q", stop))
+	}
+
+	exclusive := strings.HasPrefix(start, "(")
+	start = strings.TrimPrefix(start, "(")
+
+	var result []redis.XMessage
+	for _, message := range f.messages {
+		if start != "-" {
+			before, err := streamIDLess(message.ID, start)
+			if err != nil {
+				return redis.NewXMessageSliceCmdResult(nil, err)
+			}
+			if before || (exclusive && message.ID == start) {
+				continue
+			}
+		}
+		if int64(len(result)) == count {
+			break
+		}
+		result = append(result, message)
+	}
+	return redis.NewXMessageSliceCmdResult(result, nil)
+}
+
+func newReplayConsumer(stream streamRanger) *RedisConsumer {
+	return &RedisConsumer{
+		stream:       stream,
+		indexService: index.NewService(0),
+		streamName:   "read_cursor_events",
+	}
+}
+
+func TestReplay(t *testing.T) {
+	stream := &fakeStream{}
+	for i := 1; i <= 5; i++ {
+		stream.add(t, fmt.Sprintf("%d-0", i), index.ReadCursorEvent{ChannelID: "c1", UserID: fmt.Sprintf("u%d", i), NewLastSeq: int64(i * 100)})
+	}
+
+	t.Run("resumes after the last acked ID", func(t *testing.T) {
+		c := newReplayConsumer(stream)
+
+		replayed, err := c.Replay(context.Background(), "2-0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replayed != 3 {
+			t.Fatalf("expected 3 replayed events, got %d", replayed)
+		}
+		if c.LastAckedID() != "5-0" {
+			t.Fatalf("expected last acked ID 5-0, got %q", c.LastAckedID())
+		}
+		for i := 1; i <= 5; i++ {
+			if got, expected := c.indexService.IsReader("c1", fmt.Sprintf("u%d", i), 0), i > 2; got != expected {
+				t.Fatalf("expected u%d replayed to be %v", i, expected)
+			}
+		}
+	})
+
+	t.Run("replays the whole stream without a position", func(t *testing.T) {
+		c := newReplayConsumer(stream)
+
+		replayed, err := c.Replay(context.Background(), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if replayed != 5 {
+			t.Fatalf("expected 5 replayed events, got %d", replayed)
+		}
+	})
+
+	t.Run("fails on an invalid position", func(t *testing.T) {
+		c := newReplayConsumer(stream)
+
+		if _, err := c.Replay(context.Background(), "bogus"); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestTrimmedSince(t *testing.T) {
+	stream := &fakeStream{}
+	c := newReplayConsumer(stream)
+
+	trimmed, err := c.TrimmedSince(context.Background(), "1-0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trimmed {
+		t.Fatal("an empty stream is not trimmed")
+	}
+
+	stream.add(t, "3-0", index.ReadCursorEvent{ChannelID: "c1", UserID: "u1", NewLastSeq: 100})
+	for id, expected := range map[string]bool{"1-0": true, "2-5": true, "3-0": false, "4-0": false} {
+		trimmed, err := c.TrimmedSince(context.Background(), id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trimmed != expected {
+			t.Fatalf("TrimmedSince(%q) = %v, expected %v", id, trimmed, expected)
+		}
+	}
+}
+
+func newTestConsumer(t *testing.T, mr *miniredis.Miniredis, opts Options) *RedisConsumer {
+	t.Helper()
+	c := NewRedisConsumer("redis://"+mr.Addr(), index.NewService(0), opts)
+	t.Cleanup(func() { c.client.Close() })
+	return c
+}
+
+// addEvent 向 Stream 追加事件，返回消息 ID
+func addEvent(t *testing.T, c *RedisConsumer, event index.ReadCursorEvent) string {
+	t.Helper()
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := c.client.XAdd(context.Background(), &redis.XAddArgs{Stream: c.streamName, Values: map[string]interface{}{"data": data}}).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func pendingCount(t *testing.T, c *RedisConsumer) int64 {
+	t.Helper()
+	pending, err := c.client.XPending(context.Background(), c.streamName, c.groupName).Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pending.Count
+}
+
+func TestConsumerLock(t *testing.T) {
+	mr := miniredis.RunT(t)
+	const ttl = 300 * time.Millisecond
+	a := newTestConsumer(t, mr, Options{ConsumerName: "a", LockTTL: ttl})
+	b := newTestConsumer(t, mr, Options{ConsumerName: "b", LockTTL: ttl})
+
+	if err := a.acquireLock(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("a second consumer can't take the lock while it is held", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), ttl)
+		defer cancel()
+
+		if err := b.acquireLock(ctx); err == nil {
+			t.Fatal("expected b to wait for the lock")
+		}
+		if holder, _ := mr.Get(a.lockKey()); holder != "a" {
+			t.Fatalf("expected the lock held by a, got %q", holder)
+		}
+	})
+
+	t.Run("the lock is renewed while held", func(t *testing.T) {
+		a.lockedUntil = time.Now()
+		if !a.holdLock(context.Background()) {
+			t.Fatal("expected a to keep the lock")
+		}
+		if !a.lockedUntil.After(time.Now()) {
+			t.Fatal("expected the lock to be extended")
+		}
+	})
+
+	t.Run("renewal errors keep the lock until it may expire", func(t *testing.T) {
+		mr.SetError("unavailable")
+		defer mr.SetError("")
+
+		a.lockedUntil = time.Now().Add(ttl / 2)
+		if !a.holdLock(context.Background()) {
+			t.Fatal("expected a to keep the lock until the next renewal")
+		}
+
+		a.lockedUntil = time.Now().Add(ttl / 4)
+		if a.holdLock(context.Background()) {
+			t.Fatal("expected a to stop once the lock may expire before the next renewal")
+		}
+	})
+
+	t.Run("the lock is lost when another consumer holds it", func(t *testing.T) {
+		if err := mr.Set(a.lockKey(), "b"); err != nil {
+			t.Fatal(err)
+		}
+		defer mr.Set(a.lockKey(), "a")
+
+		a.lockedUntil = time.Now()
+		if a.holdLock(context.Background()) {
+			t.Fatal("expected a to lose the lock")
+		}
+	})
+
+	t.Run("releasing the lock lets the next consumer take it", func(t *testing.T) {
+		a.releaseLock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), ttl)
+		defer cancel()
+		if err := b.acquireLock(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if holder, _ := mr.Get(b.lockKey()); holder != "b" {
+			t.Fatalf("expected the lock held by b, got %q", holder)
+		}
+	})
+}
+
+func TestReclaim(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestConsumer(t, mr, Options{ConsumerName: "b", ClaimMinIdle: 10 * time.Millisecond})
+	ctx := context.Background()
+
+	if err := c.client.XGroupCreateMkStream(ctx, c.streamName, c.groupName, "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	id := addEvent(t, c, index.ReadCursorEvent{ChannelID: "c1", UserID: "u1", NewLastSeq: 100})
+
+	// 崩溃的消费者读取后未确认
+	if err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    c.groupName,
+		Consumer: "crashed",
+		Streams:  []string{c.streamName, ">"},
+	}).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if pendingCount(t, c) != 1 {
+		t.Fatal("expected the message pending for the crashed consumer")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if err := c.reclaim(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.indexService.IsReader("c1", "u1", 100) {
+		t.Fatal("expected the reclaimed event to be applied")
+	}
+	if pendingCount(t, c) != 0 {
+		t.Fatal("expected the reclaimed message to be acked")
+	}
+	if c.LastAckedID() != id {
+		t.Fatalf("expected last acked ID %s, got %q", id, c.LastAckedID())
+	}
+}
+
+func TestConsumeBatchSkipsForeignChannels(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := newTestConsumer(t, mr, Options{Owns: func(channelID string) bool { return channelID == "mine" }})
+	ctx := context.Background()
+
+	if err := c.client.XGroupCreateMkStream(ctx, c.streamName, c.groupName, "0").Err(); err != nil {
+		t.Fatal(err)
+	}
+	addEvent(t, c, index.ReadCursorEvent{ChannelID: "mine", UserID: "u1", NewLastSeq: 100})
+	last := addEvent(t, c, index.ReadCursorEvent{ChannelID: "foreign", UserID: "u1", NewLastSeq: 100})
+
+	if err := c.consumeBatch(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.indexService.IsReader("mine", "u1", 100) {
+		t.Fatal("expected the event of an owned channel to be applied")
+	}
+	if c.indexService.IsReader("foreign", "u1", 0) {
+		t.Fatal("expected the event of a foreign channel to be skipped")
+	}
+	if pendingCount(t, c) != 0 {
+		t.Fatal("expected both messages to be acked")
+	}
+	if c.LastAckedID() != last {
+		t.Fatalf("expected last acked ID %s, got %q", last, c.LastAckedID())
+	}
+}
@@ -0,0 +1,174 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// naiveIndex 逐个扫描游标的参考实现
+type naiveIndex map[string]map[string]int64
+
+func (n naiveIndex) apply(e *ReadCursorEvent) {
+	if n[e.ChannelID] == nil {
+		n[e.ChannelID] = make(map[string]int64)
+	}
+	if e.NewLastSeq > n[e.ChannelID][e.UserID] {
+		n[e.ChannelID][e.UserID] = e.NewLastSeq
+	}
+}
+
+func (n naiveIndex) readers(channelID string, seq int64) []string {
+	readers := []string{}
+	for userID, cursor := range n[channelID] {
+		if cursor >= seq {
+			readers = append(readers, userID)
+		}
+	}
+	sort.Strings(readers)
+	return readers
+}
+
+func TestExactReadersMatchNaiveScan(t *testing.T) {
+	base := int64(1_700_000_000_000) // 与 post.CreateAt 同量级的毫秒时间戳
+
+	for _, segmentSize := range []int64{1, 7, 100, 1000, DefaultSegmentSize} {
+		for seed := int64(1); seed <= 20; seed++ {
+			t.Run(fmt.Sprintf("segment=%d/seed=%d", segmentSize, seed), func(t *testing.T) {
+				rng := rand.New(rand.NewSource(seed))
+				s := NewService(segmentSize)
+				naive := naiveIndex{}
+
+				channels := []string{"c1", "c2", "c3"}
+				users := make([]string, 1+rng.Intn(60))
+				for i := range users {
+					users[i] = fmt.Sprintf("u%d", i)
+				}
+				span := int64(1 + rng.Intn(500_000))
+
+				var seqs []int64
+				for i := 0; i < 500; i++ {
+					e := &ReadCursorEvent{
+						ChannelID:  channels[rng.Intn(len(channels))],
+						UserID:     users[rng.Intn(len(users))],
+						NewLastSeq: base + rng.Int63n(span),
+					}
+					seqs = append(seqs, e.NewLastSeq)
+
+					if err := s.HandleEvent(e); err != nil {
+						t.Fatal(err)
+					}
+					naive.apply(e)
+
+					if i%50 == 0 {
+						assertMatchesNaive(t, s, naive, channels, users, seqs, rng)
+					}
+				}
+				assertMatchesNaive(t, s, naive, channels, users, seqs, rng)
+
+				// 快照恢复后结果不变
+				var buf bytes.Buffer
+				if err := s.WriteSnapshot(&buf, SnapshotHeader{}); err != nil {
+					t.Fatal(err)
+				}
+				restored := NewService(segmentSize)
+				if _, err := restored.ReadSnapshot(&buf); err != nil {
+					t.Fatal(err)
+				}
+				assertMatchesNaive(t, restored, naive, channels, users, seqs, rng)
+
+				// 按游标重建后结果不变
+				rebuilt := NewService(segmentSize * 3)
+				for channelID, cursors := range naive {
+					rebuilt.RebuildChannel(channelID, cursors, nil)
+				}
+				assertMatchesNaive(t, rebuilt, naive, channels, users, seqs, rng)
+			})
+		}
+	}
+}
+
+func assertMatchesNaive(t *testing.T, s *Service, naive naiveIndex, channels, users []string, seqs []int64, rng *rand.Rand) {
+	t.Helper()
+
+	// 既检查恰好等于某个游标的 seq，也检查其前后相邻的值
+	probes := []int64{0, 1}
+	for i := 0; i < 30 && len(seqs) > 0; i++ {
+		seq := seqs[rng.Intn(len(seqs))]
+		probes = append(probes, seq-1, seq, seq+1)
+	}
+
+	for _, channelID := range channels {
+		counts := s.GetReadCounts(channelID, probes)
+
+		for _, seq := range probes {
+			want := naive.readers(channelID, seq)
+
+			got, count, err := s.GetReadersForSeq(channelID, seq, len(users)+1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(got)
+
+			if count != len(want) || counts[seq] != len(want) {
+				t.Fatalf("channel %s seq %d: want count %d, got %d (batch %d)", channelID, seq, len(want), count, counts[seq])
+			}
+			if fmt.Sprint(got) != fmt.Sprint(want) {
+				t.Fatalf("channel %s seq %d: want readers %v, got %v", channelID, seq, want, got)
+			}
+
+			for _, userID := range users {
+				cursor, ok := naive[channelID][userID]
+				if wantReader := ok && cursor >= seq; s.IsReader(channelID, userID, seq) != wantReader {
+					t.Fatalf("channel %s seq %d user %s: want IsReader %v", channelID, seq, userID, wantReader)
+				}
+			}
+
+			if len(want) > 1 {
+				limited, _, _ := s.GetReadersForSeq(channelID, seq, len(want)-1)
+				if len(limited) != len(want)-1 {
+					t.Fatalf("channel %s seq %d: limit not respected, got %d readers", channelID, seq, len(limited))
+				}
+			}
+		}
+	}
+}
+
+func TestReaderBeyondCursorIsNotReported(t *testing.T) {
+	s := NewService(100)
+	if err := s.HandleEvent(&ReadCursorEvent{ChannelID: "c1", UserID: "u1", NewLastSeq: 150}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, count, _ := s.GetReadersForSeq("c1", 150, 10); count != 1 {
+		t.Fatalf("expected u1 to have read seq 150, got count %d", count)
+	}
+	if _, count, _ := s.GetReadersForSeq("c1", 199, 10); count != 0 {
+		t.Fatalf("expected no readers for seq 199, got %d", count)
+	}
+	if s.IsReader("c1", "u1", 151) {
+		t.Fatal("expected u1 not to have read seq 151")
+	}
+}
+
+func TestSegmentsStayBoundedByUsers(t *testing.T) {
+	s := NewService(100)
+	base := int64(1_700_000_000_000)
+
+	// 游标分散在很大的时间跨度上，段数量仍不超过用户数
+	for i := 0; i < 1000; i++ {
+		e := &ReadCursorEvent{ChannelID: "c1", UserID: fmt.Sprintf("u%d", i%10), NewLastSeq: base + int64(i)*86_400_000}
+		if err := s.HandleEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.mu.RLock()
+	cs := s.channels["c1"]
+	s.mu.RUnlock()
+	if len(cs.Segments) > 10 || len(cs.segmentKeys) != len(cs.Segments) {
+		t.Fatalf("expected at most 10 segments, got %d (%d keys)", len(cs.Segments), len(cs.segmentKeys))
+	}
+}
@@ -1,109 +1,255 @@
 package index
 
 import (
+	"sort"
 	"sync"
 
 	"github.com/RoaringBitmap/roaring"
 )
 
+// DefaultSegmentSize 默认段大小。seq 是帖子的 CreateAt 毫秒时间戳，默认一分钟一个段
+const DefaultSegmentSize int64 = 60 * 1000
+
 // Service 管理所有频道的读索引
 type Service struct {
-	channels   map[string]*ChannelState
-	mu         sync.RWMutex
-	windowSize int64
+	channels    map[string]*ChannelState
+	mu          sync.RWMutex
+	segmentSize int64
+
+	// rebuilding 正在重建的频道在重建期间收到的事件，重建完成时在新状态上重放
+	rebuilding map[string][]*ReadCursorEvent
+	rebuildMu  sync.Mutex
 }
 
-// ChannelState 表示一个频道的读索引状态
+// ChannelState 表示一个频道的读索引状态。
+//
+// 每个用户只属于一个段：游标所在的 seq 区间。"读过 seq" 即 "游标 >= seq"，
+// 因此查询时合并 seq 所在段之后的所有段，再对 seq 所在的边界段逐个比较游标，结果是精确的。
+// 段只在有游标落入时才存在，数量不超过用户数，与 seq 的取值范围无关。
 type ChannelState struct {
-	ChannelID   string
-	MaxSeq      int64
-	UserCursors map[string]int64  // user_id -> last_seq
-	UserIndex   map[string]uint32 // user_id -> bitmap index
-	IndexToUser []string          // index -> user_id (反向映射)
-	Segments    []*ReadSegment
-	WindowSize  int64
-	mu          sync.RWMutex
+	ChannelID    string
+	MaxSeq       int64
+	UserCursors  map[string]int64  // user_id -> last_seq
+	UserIndex    map[string]uint32 // user_id -> bitmap index
+	IndexToUser  []string          // index -> user_id (反向映射)
+	IndexCursors []int64           // index -> last_seq，用于边界段的精确比较
+	// ThreadCursors 折叠回复线程（CRT）中的线程级游标：root_id -> user_id -> last_seq。
+	// 回复的已读判断为频道游标或其所在线程的游标 >= seq
+	ThreadCursors map[string]map[string]int64
+	// RemovedAt 最近一次删除整个频道游标的事件时间戳，UserRemovedAt 为每个用户最近一次删除事件的时间戳。
+	// outbox 重试的推进事件可能晚于删除事件到达，时间戳不晚于删除的推进事件被忽略，
+	// 已关闭共享的用户不会因此被重新加入
+	RemovedAt     int64
+	UserRemovedAt map[string]int64
+	Segments      map[int64]*ReadSegment
+	SegmentSize   int64
+	segmentKeys   []int64 // 升序排列的段编号
+	mu            sync.RWMutex
 }
 
-// ReadSegment 表示一个消息段的读索引
+// ReadSegment 表示一个 seq 区间内的游标集合
 type ReadSegment struct {
 	StartSeq int64
 	EndSeq   int64
-	Readers  *roaring.Bitmap // 读过此段的用户位图
+	Readers  *roaring.Bitmap // 游标落在 [StartSeq, EndSeq] 内的用户位图
 }
 
+// 事件类型
+const (
+	// EventTypeAdvanced 游标前移
+	EventTypeAdvanced = "channel_read_advanced"
+	// EventTypeRemoved 游标被删除：用户关闭了已读回执共享，或频道禁用了已读回执。
+	// UserID 为空时删除整个频道的游标。之后到达的、时间戳不晚于该事件的推进事件被忽略
+	EventTypeRemoved = "channel_read_cursor_removed"
+	// EventTypeExpired 数据保留策略删除了早于保留期限的单个游标：RootID 非空时为线程游标，
+	// 否则为频道游标。用户的其他游标保持不变
+	EventTypeExpired = "channel_read_cursor_expired"
+)
+
 // ReadCursorEvent 读游标事件
 type ReadCursorEvent struct {
 	Type        string `json:"type"`
 	EventID     string `json:"event_id"`
 	ChannelID   string `json:"channel_id"`
+	RootID      string `json:"root_id,omitempty"` // 非空时为线程游标
 	UserID      string `json:"user_id"`
 	PrevLastSeq int64  `json:"prev_last_seq"`
 	NewLastSeq  int64  `json:"new_last_seq"`
 	Timestamp   int64  `json:"timestamp"`
 }
 
-// NewService 创建新的索引服务
-func NewService(windowSize int64) *Service {
+// NewService 创建新的索引服务，segmentSize <= 0 时使用默认段大小
+func NewService(segmentSize int64) *Service {
+	if segmentSize <= 0 {
+		segmentSize = DefaultSegmentSize
+	}
 	return &Service{
-		channels:   make(map[string]*ChannelState),
-		windowSize: windowSize,
+		channels:    make(map[string]*ChannelState),
+		segmentSize: segmentSize,
+		rebuilding:  make(map[string][]*ReadCursorEvent),
 	}
 }
 
 // HandleEvent 处理读游标事件
 func (s *Service) HandleEvent(event *ReadCursorEvent) error {
+	s.journal(event)
+
+	switch {
+	case event.Type == EventTypeRemoved && event.UserID == "":
+		s.removeChannel(event.ChannelID, event.Timestamp)
+		return nil
+	case event.Type == EventTypeExpired:
+		s.expire(event.ChannelID, event.RootID, event.UserID)
+		return nil
+	}
+
 	s.mu.RLock()
 	cs, exists := s.channels[event.ChannelID]
 	s.mu.RUnlock()
 
 	if !exists {
+		// 没有时间戳的删除无需记录，频道不存在时什么也不做
+		if event.Type == EventTypeRemoved && event.Timestamp <= 0 {
+			return nil
+		}
 		cs = s.createChannelState(event.ChannelID)
 	}
 
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
 
-	// 确保用户有位图索引
-	userIdx, exists := cs.UserIndex[event.UserID]
+	cs.handle(event)
+
+	return nil
+}
+
+// removeChannel 删除整个频道的游标。删除事件带有时间戳时保留一个空的频道状态记录删除时间，
+// 否则直接移除频道
+func (s *Service) removeChannel(channelID string, removedAt int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if removedAt <= 0 {
+		delete(s.channels, channelID)
+		return
+	}
+
+	cs := s.newChannelState(channelID)
+	if old, exists := s.channels[channelID]; exists {
+		old.mu.RLock()
+		cs.RemovedAt = max(old.RemovedAt, removedAt)
+		old.mu.RUnlock()
+	} else {
+		cs.RemovedAt = removedAt
+	}
+	s.channels[channelID] = cs
+}
+
+// expire 只删除用户的单个游标：rootID 非空时为线程游标，否则为频道游标
+func (s *Service) expire(channelID, rootID, userID string) {
+	s.mu.RLock()
+	cs, exists := s.channels[channelID]
+	s.mu.RUnlock()
+
 	if !exists {
-		userIdx = uint32(len(cs.IndexToUser))
-		cs.UserIndex[event.UserID] = userIdx
-		cs.IndexToUser = append(cs.IndexToUser, event.UserID)
+		return
 	}
 
-	// 获取旧游标
-	oldSeq := cs.UserCursors[event.UserID]
-	if event.NewLastSeq <= oldSeq {
-		return nil // 序号没有增加
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if rootID != "" {
+		cs.dropThread(rootID, userID)
+		return
 	}
+	cs.dropCursor(userID)
+}
 
-	// 更新游标
-	cs.UserCursors[event.UserID] = event.NewLastSeq
+// journal 记录正在重建的频道收到的事件
+func (s *Service) journal(event *ReadCursorEvent) {
+	s.rebuildMu.Lock()
+	defer s.rebuildMu.Unlock()
 
-	// 更新段位图
-	for _, seg := range cs.Segments {
-		if seg.StartSeq > event.NewLastSeq {
-			break
-		}
-		if seg.EndSeq > oldSeq {
-			seg.Readers.Add(userIdx)
+	if events, ok := s.rebuilding[event.ChannelID]; ok {
+		s.rebuilding[event.ChannelID] = append(events, event)
+	}
+}
+
+// BeginRebuild 在从数据源头读取频道游标之前调用，此后收到的事件会在 RebuildChannel 时重放，
+// 不会被读取时已过时的游标覆盖
+func (s *Service) BeginRebuild(channelID string) {
+	s.rebuildMu.Lock()
+	defer s.rebuildMu.Unlock()
+
+	s.rebuilding[channelID] = []*ReadCursorEvent{}
+}
+
+// CancelRebuild 放弃 BeginRebuild 开始的重建，频道状态保持不变
+func (s *Service) CancelRebuild(channelID string) {
+	s.rebuildMu.Lock()
+	defer s.rebuildMu.Unlock()
+
+	delete(s.rebuilding, channelID)
+}
+
+// RebuildChannel 用给定的频道游标（user_id -> last_seq）和线程游标（root_id -> user_id -> last_seq）
+// 重建频道状态并替换现有状态，现有的游标全部丢弃。BeginRebuild 之后收到的事件会在新状态上重放。
+func (s *Service) RebuildChannel(channelID string, cursors map[string]int64, threads map[string]map[string]int64) {
+	// 持有 rebuildMu 直到新状态生效，期间到达的事件要么进入重放，要么应用到新状态上
+	s.rebuildMu.Lock()
+	defer s.rebuildMu.Unlock()
+
+	events := s.rebuilding[channelID]
+	delete(s.rebuilding, channelID)
+
+	cs := s.newChannelState(channelID)
+	cs.load(cursors)
+	cs.loadThreads(threads)
+
+	// 数据源中的游标是权威的，但仍在重试的旧推进事件可能在重建之后到达，删除记录需要保留
+	s.mu.RLock()
+	old, exists := s.channels[channelID]
+	s.mu.RUnlock()
+	if exists {
+		old.mu.RLock()
+		cs.RemovedAt = old.RemovedAt
+		for userID, removedAt := range old.UserRemovedAt {
+			cs.UserRemovedAt[userID] = removedAt
 		}
+		old.mu.RUnlock()
 	}
 
-	// 更新最大序号
-	if event.NewLastSeq > cs.MaxSeq {
-		cs.MaxSeq = event.NewLastSeq
-		cs.ensureSegmentsCover(event.NewLastSeq)
+	for _, event := range events {
+		if event.Type == EventTypeRemoved && event.UserID == "" {
+			removedAt := max(cs.RemovedAt, event.Timestamp)
+			cs = s.newChannelState(channelID)
+			cs.RemovedAt = removedAt
+			continue
+		}
+		cs.handle(event)
 	}
 
-	// 清理旧段
-	cs.pruneOldSegments()
+	s.mu.Lock()
+	s.channels[channelID] = cs
+	s.mu.Unlock()
+}
 
-	return nil
+// Retain 只保留 keep 返回 true 的频道，返回被移除的频道数。分片归属变化后用于丢弃不再负责的频道
+func (s *Service) Retain(keep func(channelID string) bool) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for channelID := range s.channels {
+		if !keep(channelID) {
+			delete(s.channels, channelID)
+			removed++
+		}
+	}
+	return removed
 }
 
-// GetReadersForSeq 获取读过某条消息的用户列表
+// GetReadersForSeq 获取读过某条消息的用户列表（游标 >= seq），按游标从新到旧排列
 func (s *Service) GetReadersForSeq(channelID string, seq int64, limit int) ([]string, int, error) {
 	s.mu.RLock()
 	cs, exists := s.channels[channelID]
@@ -116,26 +262,24 @@ func (s *Service) GetReadersForSeq(channelID string, seq int64, limit int) ([]st
 	cs.mu.RLock()
 	defer cs.mu.RUnlock()
 
-	// 合并所有 EndSeq >= seq 的段
-	merged := roaring.New()
-	for _, seg := range cs.Segments {
-		if seg.EndSeq >= seq {
-			merged.Or(seg.Readers)
-		}
-	}
+	return cs.readers(seq, limit), cs.countReaders(seq), nil
+}
 
-	count := int(merged.GetCardinality())
-	readers := make([]string, 0, min(count, limit))
+// IsReader 判断用户是否读过某条消息
+func (s *Service) IsReader(channelID, userID string, seq int64) bool {
+	s.mu.RLock()
+	cs, exists := s.channels[channelID]
+	s.mu.RUnlock()
 
-	iter := merged.Iterator()
-	for iter.HasNext() && len(readers) < limit {
-		userIdx := iter.Next()
-		if int(userIdx) < len(cs.IndexToUser) {
-			readers = append(readers, cs.IndexToUser[userIdx])
-		}
+	if !exists {
+		return false
 	}
 
-	return readers, count, nil
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	cursor, exists := cs.UserCursors[userID]
+	return exists && cursor >= seq
 }
 
 // GetReadCounts 批量获取已读计数
@@ -156,13 +300,7 @@ func (s *Service) GetReadCounts(channelID string, seqs []int64) map[int64]int {
 	defer cs.mu.RUnlock()
 
 	for _, seq := range seqs {
-		merged := roaring.New()
-		for _, seg := range cs.Segments {
-			if seg.EndSeq >= seq {
-				merged.Or(seg.Readers)
-			}
-		}
-		result[seq] = int(merged.GetCardinality())
+		result[seq] = cs.countReaders(seq)
 	}
 
 	return result
@@ -177,10 +315,12 @@ func (s *Service) GetStats() map[string]interface{} {
 	for _, cs := range s.channels {
 		cs.mu.RLock()
 		channelStats := map[string]interface{}{
-			"channel_id":  cs.ChannelID,
-			"max_seq":     cs.MaxSeq,
-			"users_count": len(cs.UserCursors),
-			"segments":    len(cs.Segments),
+			"channel_id":   cs.ChannelID,
+			"max_seq":      cs.MaxSeq,
+			"users_count":  len(cs.UserCursors),
+			"segments":     len(cs.Segments),
+			"segment_size": cs.SegmentSize,
+			"threads":      len(cs.ThreadCursors),
 		}
 		cs.mu.RUnlock()
 		channels = append(channels, channelStats)
@@ -188,6 +328,7 @@ func (s *Service) GetStats() map[string]interface{} {
 
 	return map[string]interface{}{
 		"channels_count": len(s.channels),
+		"segment_size":   s.segmentSize,
 		"channels":       channels,
 	}
 }
@@ -198,61 +339,236 @@ func (s *Service) createChannelState(channelID string) *ChannelState {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	cs := &ChannelState{
-		ChannelID:   channelID,
-		MaxSeq:      0,
-		UserCursors: make(map[string]int64),
-		UserIndex:   make(map[string]uint32),
-		IndexToUser: make([]string, 0),
-		Segments:    make([]*ReadSegment, 0),
-		WindowSize:  s.windowSize,
+	// 可能已被其他 goroutine 创建
+	if cs, exists := s.channels[channelID]; exists {
+		return cs
 	}
 
+	cs := s.newChannelState(channelID)
 	s.channels[channelID] = cs
 	return cs
 }
 
-func (cs *ChannelState) ensureSegmentsCover(maxSeq int64) {
-	segmentSize := int64(100) // 每段 100 条消息
+func (s *Service) newChannelState(channelID string) *ChannelState {
+	return &ChannelState{
+		ChannelID:     channelID,
+		MaxSeq:        0,
+		UserCursors:   make(map[string]int64),
+		UserIndex:     make(map[string]uint32),
+		IndexToUser:   make([]string, 0),
+		IndexCursors:  make([]int64, 0),
+		ThreadCursors: make(map[string]map[string]int64),
+		UserRemovedAt: make(map[string]int64),
+		Segments:      make(map[int64]*ReadSegment),
+		SegmentSize:   s.segmentSize,
+		segmentKeys:   make([]int64, 0),
+	}
+}
 
-	if len(cs.Segments) == 0 {
-		cs.Segments = append(cs.Segments, &ReadSegment{
-			StartSeq: 0,
-			EndSeq:   segmentSize - 1,
-			Readers:  roaring.New(),
-		})
+// apply 将用户游标推进到 newSeq，调用方需持有写锁
+func (cs *ChannelState) apply(userID string, newSeq int64) {
+	// 获取旧游标
+	oldSeq, hadCursor := cs.UserCursors[userID]
+	if newSeq <= oldSeq || newSeq <= 0 {
+		return // 序号没有增加
 	}
 
-	lastSeg := cs.Segments[len(cs.Segments)-1]
-	for lastSeg.EndSeq < maxSeq {
-		newSeg := &ReadSegment{
-			StartSeq: lastSeg.EndSeq + 1,
-			EndSeq:   lastSeg.EndSeq + segmentSize,
-			Readers:  roaring.New(),
-		}
-		cs.Segments = append(cs.Segments, newSeg)
-		lastSeg = newSeg
+	// 确保用户有位图索引
+	userIdx, exists := cs.UserIndex[userID]
+	if !exists {
+		userIdx = uint32(len(cs.IndexToUser))
+		cs.UserIndex[userID] = userIdx
+		cs.IndexToUser = append(cs.IndexToUser, userID)
+		cs.IndexCursors = append(cs.IndexCursors, 0)
+	}
+
+	// 更新游标
+	cs.UserCursors[userID] = newSeq
+	cs.IndexCursors[userIdx] = newSeq
+
+	// 把用户从旧游标所在的段移到新游标所在的段
+	oldKey, newKey := cs.segmentKey(oldSeq), cs.segmentKey(newSeq)
+	if hadCursor && oldKey != newKey {
+		cs.removeFromSegment(oldKey, userIdx)
+	}
+	if !hadCursor || oldKey != newKey {
+		cs.segment(newKey).Readers.Add(userIdx)
 	}
+
+	// 更新最大序号
+	if newSeq > cs.MaxSeq {
+		cs.MaxSeq = newSeq
+	}
+}
+
+// handle 应用单个用户的事件，调用方需持有写锁
+func (cs *ChannelState) handle(event *ReadCursorEvent) {
+	switch {
+	case event.Type == EventTypeRemoved:
+		cs.remove(event.UserID, event.Timestamp)
+	case event.Type == EventTypeExpired && event.RootID != "":
+		cs.dropThread(event.RootID, event.UserID)
+	case event.Type == EventTypeExpired:
+		cs.dropCursor(event.UserID)
+	case cs.stale(event):
+		return
+	case event.RootID != "":
+		cs.applyThread(event.RootID, event.UserID, event.NewLastSeq)
+	default:
+		cs.apply(event.UserID, event.NewLastSeq)
+	}
+}
+
+// stale 判断推进事件是否发生在用户或整个频道的游标被删除之前，调用方需持有锁。
+// 没有时间戳的事件不参与比较
+func (cs *ChannelState) stale(event *ReadCursorEvent) bool {
+	if event.Timestamp <= 0 {
+		return false
+	}
+	return event.Timestamp <= cs.RemovedAt || event.Timestamp <= cs.UserRemovedAt[event.UserID]
 }
 
-func (cs *ChannelState) pruneOldSegments() {
-	threshold := cs.MaxSeq - cs.WindowSize
-	if threshold <= 0 {
+// remove 删除用户的游标并记录删除时间，调用方需持有写锁
+func (cs *ChannelState) remove(userID string, removedAt int64) {
+	cs.drop(userID)
+	if removedAt > cs.UserRemovedAt[userID] {
+		cs.UserRemovedAt[userID] = removedAt
+	}
+}
+
+// drop 删除用户的频道游标和线程游标，调用方需持有写锁。位图索引保留，用户再次推进游标时复用
+func (cs *ChannelState) drop(userID string) {
+	cs.dropThreads(userID)
+	cs.dropCursor(userID)
+}
+
+// dropCursor 只删除用户的频道游标，调用方需持有写锁
+func (cs *ChannelState) dropCursor(userID string) {
+	seq, hadCursor := cs.UserCursors[userID]
+	if !hadCursor {
 		return
 	}
 
-	newSegments := make([]*ReadSegment, 0)
-	for _, seg := range cs.Segments {
-		if seg.EndSeq >= threshold {
-			newSegments = append(newSegments, seg)
+	userIdx := cs.UserIndex[userID]
+	cs.removeFromSegment(cs.segmentKey(seq), userIdx)
+	delete(cs.UserCursors, userID)
+	cs.IndexCursors[userIdx] = 0
+}
+
+// load 批量加载游标
+func (cs *ChannelState) load(cursors map[string]int64) {
+	for userID, seq := range cursors {
+		cs.apply(userID, seq)
+	}
+}
+
+// readers 返回至多 limit 个游标 >= seq 的用户，按游标从新到旧排列，调用方需持有读锁
+func (cs *ChannelState) readers(seq int64, limit int) []string {
+	readers := make([]string, 0)
+
+	first := cs.firstSegmentFor(seq)
+	for i := len(cs.segmentKeys) - 1; i >= first && len(readers) < limit; i-- {
+		// 段按游标从新到旧遍历，段内位图按用户索引排列，需按游标重新排序后再截断
+		var candidates []uint32
+		iter := cs.Segments[cs.segmentKeys[i]].Readers.Iterator()
+		for iter.HasNext() {
+			userIdx := iter.Next()
+			if cs.IndexCursors[userIdx] >= seq {
+				candidates = append(candidates, userIdx)
+			}
+		}
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return cs.IndexCursors[candidates[a]] > cs.IndexCursors[candidates[b]]
+		})
+
+		for _, userIdx := range candidates[:min(len(candidates), limit-len(readers))] {
+			readers = append(readers, cs.IndexToUser[userIdx])
+		}
+	}
+
+	return readers
+}
+
+// countReaders 统计游标 >= seq 的用户数，调用方需持有读锁
+func (cs *ChannelState) countReaders(seq int64) int {
+	first := cs.firstSegmentFor(seq)
+	if first >= len(cs.segmentKeys) {
+		return 0
+	}
+
+	count := 0
+	start := first
+	// 边界段需要逐个比较游标
+	if cs.segmentKeys[first] == cs.segmentKey(seq) {
+		iter := cs.Segments[cs.segmentKeys[first]].Readers.Iterator()
+		for iter.HasNext() {
+			if cs.IndexCursors[iter.Next()] >= seq {
+				count++
+			}
 		}
+		start++
+	}
+
+	// 之后的段中所有用户的游标都 >= seq
+	for i := start; i < len(cs.segmentKeys); i++ {
+		count += int(cs.Segments[cs.segmentKeys[i]].Readers.GetCardinality())
+	}
+
+	return count
+}
+
+// firstSegmentFor 返回第一个可能包含游标 >= seq 的段在 segmentKeys 中的位置
+func (cs *ChannelState) firstSegmentFor(seq int64) int {
+	key := cs.segmentKey(seq)
+	return sort.Search(len(cs.segmentKeys), func(i int) bool {
+		return cs.segmentKeys[i] >= key
+	})
+}
+
+func (cs *ChannelState) segmentKey(seq int64) int64 {
+	return seq / cs.SegmentSize
+}
+
+// segment 返回编号为 key 的段，不存在时创建
+func (cs *ChannelState) segment(key int64) *ReadSegment {
+	if seg, exists := cs.Segments[key]; exists {
+		return seg
+	}
+
+	seg := &ReadSegment{
+		StartSeq: key * cs.SegmentSize,
+		EndSeq:   (key+1)*cs.SegmentSize - 1,
+		Readers:  roaring.New(),
 	}
-	cs.Segments = newSegments
+	cs.Segments[key] = seg
+
+	i := sort.Search(len(cs.segmentKeys), func(i int) bool {
+		return cs.segmentKeys[i] >= key
+	})
+	cs.segmentKeys = append(cs.segmentKeys, 0)
+	copy(cs.segmentKeys[i+1:], cs.segmentKeys[i:])
+	cs.segmentKeys[i] = key
+
+	return seg
 }
 
-func min(a, b int) int {
-	if a < b {
-		return a
+// removeFromSegment 从段中移除用户，段为空时删除
+func (cs *ChannelState) removeFromSegment(key int64, userIdx uint32) {
+	seg, exists := cs.Segments[key]
+	if !exists {
+		return
+	}
+
+	seg.Readers.Remove(userIdx)
+	if !seg.Readers.IsEmpty() {
+		return
+	}
+
+	delete(cs.Segments, key)
+	i := sort.Search(len(cs.segmentKeys), func(i int) bool {
+		return cs.segmentKeys[i] >= key
+	})
+	if i < len(cs.segmentKeys) && cs.segmentKeys[i] == key {
+		cs.segmentKeys = append(cs.segmentKeys[:i], cs.segmentKeys[i+1:]...)
 	}
-	return b
 }
@@ -0,0 +1,266 @@
+package index
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRebuildChannel(t *testing.T) {
+	s := NewService(1000)
+
+	s.RebuildChannel("c1", map[string]int64{
+		"u1": 150,
+		"u2": 320,
+		"u3": 40,
+	}, nil)
+
+	if _, count, _ := s.GetReadersForSeq("c1", 300, 100); count != 1 {
+		t.Fatalf("expected 1 reader at seq 300, got %d", count)
+	}
+	if _, count, _ := s.GetReadersForSeq("c1", 0, 100); count != 3 {
+		t.Fatalf("expected 3 readers at seq 0, got %d", count)
+	}
+
+	t.Run("keeps cursors advanced by events during the rebuild", func(t *testing.T) {
+		s.BeginRebuild("c1")
+		if err := s.HandleEvent(&ReadCursorEvent{ChannelID: "c1", UserID: "u3", NewLastSeq: 330}); err != nil {
+			t.Fatal(err)
+		}
+
+		// 数据库中的游标较旧
+		s.RebuildChannel("c1", map[string]int64{
+			"u1": 150,
+			"u2": 320,
+			"u3": 40,
+		}, nil)
+
+		if _, count, _ := s.GetReadersForSeq("c1", 300, 100); count != 2 {
+			t.Fatalf("expected 2 readers at seq 300, got %d", count)
+		}
+	})
+
+	t.Run("replays removals during the rebuild", func(t *testing.T) {
+		s.BeginRebuild("c1")
+		if err := s.HandleEvent(&ReadCursorEvent{Type: EventTypeRemoved, ChannelID: "c1", UserID: "u2"}); err != nil {
+			t.Fatal(err)
+		}
+
+		s.RebuildChannel("c1", map[string]int64{
+			"u1": 150,
+			"u2": 320,
+			"u3": 330,
+		}, nil)
+
+		if s.IsReader("c1", "u2", 0) {
+			t.Fatal("expected u2 removed during the rebuild to stay removed")
+		}
+		if _, count, _ := s.GetReadersForSeq("c1", 0, 100); count != 2 {
+			t.Fatalf("expected 2 readers at seq 0, got %d", count)
+		}
+	})
+
+	t.Run("cancelled rebuilds keep the state", func(t *testing.T) {
+		s.BeginRebuild("c1")
+		s.CancelRebuild("c1")
+
+		if _, count, _ := s.GetReadersForSeq("c1", 0, 100); count != 2 {
+			t.Fatalf("expected 2 readers at seq 0, got %d", count)
+		}
+	})
+
+	t.Run("replaces corrupted state", func(t *testing.T) {
+		s.mu.RLock()
+		cs := s.channels["c1"]
+		s.mu.RUnlock()
+		for _, seg := range cs.Segments {
+			seg.Readers.Clear()
+		}
+
+		s.RebuildChannel("c1", map[string]int64{"u1": 150}, nil)
+
+		readers, count, _ := s.GetReadersForSeq("c1", 100, 100)
+		if count != 1 || len(readers) != 1 || readers[0] != "u1" {
+			t.Fatalf("expected only u1 to read seq 100, got %v (%d)", readers, count)
+		}
+		if s.IsReader("c1", "u3", 0) {
+			t.Fatal("expected the cursors missing from the rebuild to be dropped")
+		}
+	})
+}
+
+func TestRemovedEvents(t *testing.T) {
+	s := NewService(1000)
+	for _, e := range []*ReadCursorEvent{
+		{Type: EventTypeAdvanced, ChannelID: "c1", UserID: "u1", NewLastSeq: 150},
+		{Type: EventTypeAdvanced, ChannelID: "c1", UserID: "u2", NewLastSeq: 320},
+		{Type: EventTypeAdvanced, ChannelID: "c2", UserID: "u1", NewLastSeq: 500},
+	} {
+		if err := s.HandleEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("removes a single user", func(t *testing.T) {
+		if err := s.HandleEvent(&ReadCursorEvent{Type: EventTypeRemoved, ChannelID: "c1", UserID: "u2"}); err != nil {
+			t.Fatal(err)
+		}
+
+		readers, count, _ := s.GetReadersForSeq("c1", 0, 100)
+		if count != 1 || len(readers) != 1 || readers[0] != "u1" {
+			t.Fatalf("expected only u1 to remain, got %v (%d)", readers, count)
+		}
+		if s.IsReader("c1", "u2", 0) {
+			t.Fatal("expected u2 to no longer be a reader")
+		}
+	})
+
+	t.Run("user can read again after removal", func(t *testing.T) {
+		if err := s.HandleEvent(&ReadCursorEvent{Type: EventTypeAdvanced, ChannelID: "c1", UserID: "u2", NewLastSeq: 100}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, count, _ := s.GetReadersForSeq("c1", 100, 100); count != 2 {
+			t.Fatalf("expected 2 readers at seq 100, got %d", count)
+		}
+		if _, count, _ := s.GetReadersForSeq("c1", 200, 100); count != 0 {
+			t.Fatalf("expected the old cursor of u2 to be gone, got %d readers", count)
+		}
+	})
+
+	t.Run("removes a whole channel", func(t *testing.T) {
+		if err := s.HandleEvent(&ReadCursorEvent{Type: EventTypeRemoved, ChannelID: "c1"}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, count, _ := s.GetReadersForSeq("c1", 0, 100); count != 0 {
+			t.Fatalf("expected no readers in c1, got %d", count)
+		}
+		if _, count, _ := s.GetReadersForSeq("c2", 0, 100); count != 1 {
+			t.Fatalf("expected c2 to be untouched, got %d readers", count)
+		}
+	})
+}
+
+func TestLateAdvanceAfterRemoval(t *testing.T) {
+	s := NewService(1000)
+	for _, e := range []*ReadCursorEvent{
+		{Type: EventTypeAdvanced, ChannelID: "c1", UserID: "u1", NewLastSeq: 150, Timestamp: 1000},
+		{Type: EventTypeRemoved, ChannelID: "c1", UserID: "u1", Timestamp: 2000},
+	} {
+		if err := s.HandleEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("ignores advances of a removed user that happened before the removal", func(t *testing.T) {
+		// outbox 重试的推进事件晚于删除事件到达
+		for _, e := range []*ReadCursorEvent{
+			{Type: EventTypeAdvanced, ChannelID: "c1", UserID: "u1", NewLastSeq: 200, Timestamp: 1500},
+			{Type: EventTypeAdvanced, ChannelID: "c1", RootID: "r1", UserID: "u1", NewLastSeq: 200, Timestamp: 2000},
+		} {
+			if err := s.HandleEvent(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if s.IsReader("c1", "u1", 0) {
+			t.Fatal("expected u1 to stay removed")
+		}
+		if s.IsThreadReader("c1", "r1", "u1", 0) {
+			t.Fatal("expected the thread cursor of u1 to stay removed")
+		}
+	})
+
+	t.Run("keeps the removal across a rebuild and a snapshot", func(t *testing.T) {
+		s.BeginRebuild("c1")
+		s.RebuildChannel("c1", map[string]int64{"u2": 100}, nil)
+
+		var buf bytes.Buffer
+		if err := s.WriteSnapshot(&buf, SnapshotHeader{}); err != nil {
+			t.Fatal(err)
+		}
+		restored := NewService(1000)
+		if _, err := restored.ReadSnapshot(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, svc := range []*Service{s, restored} {
+			if err := svc.HandleEvent(&ReadCursorEvent{Type: EventTypeAdvanced, ChannelID: "c1", UserID: "u1", NewLastSeq: 200, Timestamp: 1800}); err != nil {
+				t.Fatal(err)
+			}
+			if svc.IsReader("c1", "u1", 0) {
+				t.Fatal("expected u1 to stay removed")
+			}
+		}
+	})
+
+	t.Run("accepts advances after the removal", func(t *testing.T) {
+		if err := s.HandleEvent(&ReadCursorEvent{Type: EventTypeAdvanced, ChannelID: "c1", UserID: "u1", NewLastSeq: 100, Timestamp: 2500}); err != nil {
+			t.Fatal(err)
+		}
+
+		if !s.IsReader("c1", "u1", 100) {
+			t.Fatal("expected u1 to read again after sharing its read state again")
+		}
+	})
+
+	t.Run("ignores advances that happened before the channel was removed", func(t *testing.T) {
+		if err := s.HandleEvent(&ReadCursorEvent{Type: EventTypeRemoved, ChannelID: "c1", Timestamp: 3000}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.HandleEvent(&ReadCursorEvent{Type: EventTypeAdvanced, ChannelID: "c1", UserID: "u2", NewLastSeq: 300, Timestamp: 2900}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, count, _ := s.GetReadersForSeq("c1", 0, 100); count != 0 {
+			t.Fatalf("expected no readers in c1, got %d", count)
+		}
+	})
+
+	t.Run("ignores advances of a user removed before the channel existed", func(t *testing.T) {
+		for _, e := range []*ReadCursorEvent{
+			{Type: EventTypeRemoved, ChannelID: "c2", UserID: "u1", Timestamp: 2000},
+			{Type: EventTypeAdvanced, ChannelID: "c2", UserID: "u1", NewLastSeq: 100, Timestamp: 1000},
+		} {
+			if err := s.HandleEvent(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if s.IsReader("c2", "u1", 0) {
+			t.Fatal("expected u1 to stay removed")
+		}
+	})
+}
+
+func TestReadersOrder(t *testing.T) {
+	s := NewService(1000)
+
+	// 同一段内用户索引按游标从旧到新分配
+	for _, cursor := range []struct {
+		userID string
+		seq    int64
+	}{{"u1", 1100}, {"u2", 1500}, {"u3", 1900}, {"u4", 2500}, {"u5", 100}} {
+		if err := s.HandleEvent(&ReadCursorEvent{ChannelID: "c1", UserID: cursor.userID, NewLastSeq: cursor.seq}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	readers, count, err := s.GetReadersForSeq("c1", 1000, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Fatalf("expected 4 readers at seq 1000, got %d", count)
+	}
+
+	expected := []string{"u4", "u3", "u2"}
+	if len(readers) != len(expected) {
+		t.Fatalf("expected readers %v, got %v", expected, readers)
+	}
+	for i := range expected {
+		if readers[i] != expected[i] {
+			t.Fatalf("expected readers %v, got %v", expected, readers)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package index
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/RoaringBitmap/roaring"
+)
+
+// SnapshotVersion 快照格式版本，格式不兼容时递增。
+// 版本 1 的段表示"读过此段"，版本 2 起段表示"游标落在此段"。
+const SnapshotVersion = 2
+
+// SnapshotHeader 快照文件头
+type SnapshotHeader struct {
+	Version   int
+	StreamID  string // 快照时已确认的最后一条 Redis Stream 消息 ID
+	CreatedAt int64
+	Channels  int
+	Layout    string // 快照时的分片布局，未分片时为空
+}
+
+// ChannelSnapshot 单个频道的可序列化状态
+type ChannelSnapshot struct {
+	ChannelID   string
+	MaxSeq      int64
+	UserCursors map[string]int64
+	IndexToUser []string
+	SegmentSize int64
+	Segments    []SegmentSnapshot
+	// ThreadCursors 线程游标，旧快照中没有该字段
+	ThreadCursors map[string]map[string]int64
+	// RemovedAt、UserRemovedAt 游标删除记录，旧快照中没有这两个字段
+	RemovedAt     int64
+	UserRemovedAt map[string]int64
+}
+
+// SegmentSnapshot 单个段的可序列化状态，Readers 使用 roaring 原生序列化格式
+type SegmentSnapshot struct {
+	StartSeq int64
+	EndSeq   int64
+	Readers  []byte
+}
+
+// WriteSnapshot 将所有频道的状态写入 w
+func (s *Service) WriteSnapshot(w io.Writer, header SnapshotHeader) error {
+	s.mu.RLock()
+	channels := make([]*ChannelState, 0, len(s.channels))
+	for _, cs := range s.channels {
+		channels = append(channels, cs)
+	}
+	s.mu.RUnlock()
+
+	header.Version = SnapshotVersion
+	header.Channels = len(channels)
+
+	enc := gob.NewEncoder(w)
+	if err := enc.Encode(&header); err != nil {
+		return fmt.Errorf("encode snapshot header: %w", err)
+	}
+
+	for _, cs := range channels {
+		snap, err := cs.snapshot()
+		if err != nil {
+			return fmt.Errorf("snapshot channel %s: %w", cs.ChannelID, err)
+		}
+		if err := enc.Encode(snap); err != nil {
+			return fmt.Errorf("encode channel %s: %w", cs.ChannelID, err)
+		}
+	}
+
+	return nil
+}
+
+// ReadSnapshot 从 r 中加载快照，替换当前内存中的全部频道状态
+func (s *Service) ReadSnapshot(r io.Reader) (*SnapshotHeader, error) {
+	dec := gob.NewDecoder(r)
+
+	var header SnapshotHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, fmt.Errorf("decode snapshot header: %w", err)
+	}
+	if header.Version < 1 || header.Version > SnapshotVersion {
+		return nil, fmt.Errorf("unsupported snapshot version %d", header.Version)
+	}
+
+	channels := make(map[string]*ChannelState, header.Channels)
+	for i := 0; i < header.Channels; i++ {
+		var snap ChannelSnapshot
+		if err := dec.Decode(&snap); err != nil {
+			return nil, fmt.Errorf("decode channel %d: %w", i, err)
+		}
+		cs, err := s.restoreChannelState(&snap, header.Version)
+		if err != nil {
+			return nil, fmt.Errorf("restore channel %s: %w", snap.ChannelID, err)
+		}
+		channels[cs.ChannelID] = cs
+	}
+
+	s.mu.Lock()
+	s.channels = channels
+	s.mu.Unlock()
+
+	return &header, nil
+}
+
+func (cs *ChannelState) snapshot() (*ChannelSnapshot, error) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	snap := &ChannelSnapshot{
+		ChannelID:   cs.ChannelID,
+		MaxSeq:      cs.MaxSeq,
+		UserCursors: make(map[string]int64, len(cs.UserCursors)),
+		IndexToUser: make([]string, len(cs.IndexToUser)),
+		SegmentSize: cs.SegmentSize,
+		Segments:    make([]SegmentSnapshot, 0, len(cs.Segments)),
+		RemovedAt:   cs.RemovedAt,
+	}
+	for userID, seq := range cs.UserCursors {
+		snap.UserCursors[userID] = seq
+	}
+	copy(snap.IndexToUser, cs.IndexToUser)
+
+	snap.ThreadCursors = make(map[string]map[string]int64, len(cs.ThreadCursors))
+	for rootID, cursors := range cs.ThreadCursors {
+		snap.ThreadCursors[rootID] = make(map[string]int64, len(cursors))
+		for userID, seq := range cursors {
+			snap.ThreadCursors[rootID][userID] = seq
+		}
+	}
+
+	snap.UserRemovedAt = make(map[string]int64, len(cs.UserRemovedAt))
+	for userID, removedAt := range cs.UserRemovedAt {
+		snap.UserRemovedAt[userID] = removedAt
+	}
+
+	for _, key := range cs.segmentKeys {
+		seg := cs.Segments[key]
+		data, err := seg.Readers.ToBytes()
+		if err != nil {
+			return nil, err
+		}
+		snap.Segments = append(snap.Segments, SegmentSnapshot{
+			StartSeq: seg.StartSeq,
+			EndSeq:   seg.EndSeq,
+			Readers:  data,
+		})
+	}
+
+	return snap, nil
+}
+
+func (s *Service) restoreChannelState(snap *ChannelSnapshot, version int) (*ChannelState, error) {
+	cs := s.newChannelState(snap.ChannelID)
+	cs.RemovedAt = snap.RemovedAt
+	for userID, removedAt := range snap.UserRemovedAt {
+		cs.UserRemovedAt[userID] = removedAt
+	}
+	for rootID, cursors := range snap.ThreadCursors {
+		for userID, seq := range cursors {
+			cs.applyThread(rootID, userID, seq)
+		}
+	}
+
+	// 旧格式或段大小已调整时，段位图无法直接复用，按游标重新构建
+	if version < SnapshotVersion || snap.SegmentSize != s.segmentSize {
+		cs.load(snap.UserCursors)
+		return cs, nil
+	}
+
+	cs.MaxSeq = snap.MaxSeq
+	if snap.UserCursors != nil {
+		cs.UserCursors = snap.UserCursors
+	}
+	if snap.IndexToUser != nil {
+		cs.IndexToUser = snap.IndexToUser
+	}
+	cs.IndexCursors = make([]int64, len(cs.IndexToUser))
+	for idx, userID := range cs.IndexToUser {
+		cs.UserIndex[userID] = uint32(idx)
+		cs.IndexCursors[idx] = cs.UserCursors[userID]
+	}
+
+	for _, segSnap := range snap.Segments {
+		readers := roaring.New()
+		if err := readers.UnmarshalBinary(segSnap.Readers); err != nil {
+			return nil, err
+		}
+		key := cs.segmentKey(segSnap.StartSeq)
+		cs.Segments[key] = &ReadSegment{
+			StartSeq: segSnap.StartSeq,
+			EndSeq:   segSnap.EndSeq,
+			Readers:  readers,
+		}
+		cs.segmentKeys = append(cs.segmentKeys, key)
+	}
+	sort.Slice(cs.segmentKeys, func(i, j int) bool { return cs.segmentKeys[i] < cs.segmentKeys[j] })
+
+	return cs, nil
+}
@@ -0,0 +1,63 @@
+package index
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	s := NewService(1000)
+	events := []*ReadCursorEvent{
+		{ChannelID: "c1", UserID: "u1", NewLastSeq: 150},
+		{ChannelID: "c1", UserID: "u2", NewLastSeq: 320},
+		{ChannelID: "c1", UserID: "u3", NewLastSeq: 40},
+		{ChannelID: "c2", UserID: "u1", NewLastSeq: 90},
+	}
+	for _, e := range events {
+		if err := s.HandleEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := s.WriteSnapshot(&buf, SnapshotHeader{StreamID: "1700000000000-3", CreatedAt: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewService(1000)
+	header, err := restored.ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.StreamID != "1700000000000-3" {
+		t.Fatalf("unexpected stream id %q", header.StreamID)
+	}
+	if header.Channels != 2 {
+		t.Fatalf("expected 2 channels, got %d", header.Channels)
+	}
+
+	for _, channelID := range []string{"c1", "c2"} {
+		for _, seq := range []int64{0, 40, 90, 150, 151, 320} {
+			want, wantCount, _ := s.GetReadersForSeq(channelID, seq, 100)
+			got, gotCount, _ := restored.GetReadersForSeq(channelID, seq, 100)
+			if wantCount != gotCount || !reflect.DeepEqual(want, got) {
+				t.Fatalf("channel %s seq %d: want %v (%d), got %v (%d)", channelID, seq, want, wantCount, got, gotCount)
+			}
+		}
+	}
+
+	// 恢复后的状态继续接收事件，结果应与原状态一致
+	next := &ReadCursorEvent{ChannelID: "c1", UserID: "u4", NewLastSeq: 320}
+	if err := s.HandleEvent(next); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.HandleEvent(next); err != nil {
+		t.Fatal(err)
+	}
+	_, wantCount, _ := s.GetReadersForSeq("c1", 300, 100)
+	_, gotCount, _ := restored.GetReadersForSeq("c1", 300, 100)
+	if wantCount != gotCount {
+		t.Fatalf("expected %d readers after restore, got %d", wantCount, gotCount)
+	}
+}
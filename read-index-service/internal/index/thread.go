@@ -0,0 +1,178 @@
+package index
+
+// 线程级游标
+//
+// 开启折叠回复线程（CRT）后，用户在线程视图中阅读回复，频道游标不会随之前移。
+// 回复 (root_id, seq) 的读者是频道游标 >= seq 或该线程游标 >= seq 的用户。
+// 线程的参与者通常远少于频道成员，因此计数时在频道计数的基础上，
+// 只需逐个检查线程中游标 >= seq 但频道游标 < seq 的用户。
+
+// RebuildThreads 用给定的线程游标集合（root_id -> user_id -> last_seq）替换频道的线程游标，
+// 现有的线程游标全部丢弃，频道游标保持不变。与 RebuildChannel 一样，
+// BeginRebuild 之后收到的线程事件会在新状态上重放。
+func (s *Service) RebuildThreads(channelID string, threads map[string]map[string]int64) {
+	// 持有 rebuildMu 直到新状态生效，期间到达的事件要么进入重放，要么应用到新状态上
+	s.rebuildMu.Lock()
+	defer s.rebuildMu.Unlock()
+
+	events := s.rebuilding[channelID]
+	delete(s.rebuilding, channelID)
+
+	s.mu.RLock()
+	cs, exists := s.channels[channelID]
+	s.mu.RUnlock()
+
+	if !exists {
+		cs = s.createChannelState(channelID)
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.ThreadCursors = make(map[string]map[string]int64, len(threads))
+	cs.loadThreads(threads)
+
+	// 频道游标的事件已应用到现有状态上，只重放线程游标的部分
+	for _, event := range events {
+		switch {
+		case event.Type == EventTypeRemoved && event.UserID == "":
+			cs.ThreadCursors = make(map[string]map[string]int64)
+		case event.Type == EventTypeRemoved:
+			cs.dropThreads(event.UserID)
+		case event.RootID != "":
+			cs.handle(event)
+		}
+	}
+}
+
+// GetThreadReadersForSeq 获取读过线程中某条回复的用户列表。
+// 只在线程中读过的用户排在前面，其后是按频道游标从新到旧排列的用户。
+// 频道游标和线程游标在同一个读锁下读取，同一用户不会因两者之间的推进被重复计数。
+func (s *Service) GetThreadReadersForSeq(channelID, rootID string, seq int64, limit int) ([]string, int, error) {
+	s.mu.RLock()
+	cs, exists := s.channels[channelID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return []string{}, 0, nil
+	}
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	threadOnly := cs.threadOnlyReaders(rootID, seq)
+	count := cs.countReaders(seq) + len(threadOnly)
+
+	merged := make([]string, 0, min(count, limit))
+	for _, userID := range threadOnly {
+		if len(merged) == limit {
+			break
+		}
+		merged = append(merged, userID)
+	}
+	merged = append(merged, cs.readers(seq, limit-len(merged))...)
+
+	return merged, count, nil
+}
+
+// IsThreadReader 判断用户是否读过线程中的某条回复
+func (s *Service) IsThreadReader(channelID, rootID, userID string, seq int64) bool {
+	s.mu.RLock()
+	cs, exists := s.channels[channelID]
+	s.mu.RUnlock()
+
+	if !exists {
+		return false
+	}
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	if cursor, ok := cs.UserCursors[userID]; ok && cursor >= seq {
+		return true
+	}
+	cursor, ok := cs.ThreadCursors[rootID][userID]
+	return ok && cursor >= seq
+}
+
+// GetThreadReadCounts 批量获取线程中回复的已读计数
+func (s *Service) GetThreadReadCounts(channelID, rootID string, seqs []int64) map[int64]int {
+	s.mu.RLock()
+	cs, exists := s.channels[channelID]
+	s.mu.RUnlock()
+
+	result := make(map[int64]int)
+	if !exists {
+		for _, seq := range seqs {
+			result[seq] = 0
+		}
+		return result
+	}
+
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+
+	for _, seq := range seqs {
+		result[seq] = cs.countReaders(seq) + len(cs.threadOnlyReaders(rootID, seq))
+	}
+
+	return result
+}
+
+// applyThread 将用户在线程中的游标推进到 newSeq，调用方需持有写锁
+func (cs *ChannelState) applyThread(rootID, userID string, newSeq int64) {
+	if newSeq <= 0 {
+		return
+	}
+
+	cursors, exists := cs.ThreadCursors[rootID]
+	if !exists {
+		cursors = make(map[string]int64)
+		cs.ThreadCursors[rootID] = cursors
+	}
+	if newSeq > cursors[userID] {
+		cursors[userID] = newSeq
+	}
+}
+
+// loadThreads 批量加载线程游标，调用方需持有写锁
+func (cs *ChannelState) loadThreads(threads map[string]map[string]int64) {
+	for rootID, cursors := range threads {
+		for userID, seq := range cursors {
+			cs.applyThread(rootID, userID, seq)
+		}
+	}
+}
+
+// threadOnlyReaders 返回线程游标 >= seq 但频道游标 < seq 的用户，调用方需持有读锁
+func (cs *ChannelState) threadOnlyReaders(rootID string, seq int64) []string {
+	var readers []string
+	for userID, cursor := range cs.ThreadCursors[rootID] {
+		if cursor >= seq && cs.UserCursors[userID] < seq {
+			readers = append(readers, userID)
+		}
+	}
+	return readers
+}
+
+// dropThread 删除用户在一个线程中的游标，调用方需持有写锁
+func (cs *ChannelState) dropThread(rootID, userID string) {
+	cursors, exists := cs.ThreadCursors[rootID]
+	if !exists {
+		return
+	}
+	delete(cursors, userID)
+	if len(cursors) == 0 {
+		delete(cs.ThreadCursors, rootID)
+	}
+}
+
+// dropThreads 删除用户的全部线程游标，调用方需持有写锁
+func (cs *ChannelState) dropThreads(userID string) {
+	for rootID, cursors := range cs.ThreadCursors {
+		delete(cursors, userID)
+		if len(cursors) == 0 {
+			delete(cs.ThreadCursors, rootID)
+		}
+	}
+}
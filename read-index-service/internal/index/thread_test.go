@@ -0,0 +1,212 @@
+package index
+
+import (
+	"bytes"
+	"sort"
+	"testing"
+)
+
+func TestThreadReaders(t *testing.T) {
+	s := NewService(1000)
+	for _, e := range []*ReadCursorEvent{
+		// u1 读完了整个频道
+		{ChannelID: "c1", UserID: "u1", NewLastSeq: 500},
+		// u2 只在频道里读到回复之前，但在线程中读完了回复
+		{ChannelID: "c1", UserID: "u2", NewLastSeq: 100},
+		{ChannelID: "c1", RootID: "r1", UserID: "u2", NewLastSeq: 300},
+		// u3 只读了另一个线程
+		{ChannelID: "c1", RootID: "r2", UserID: "u3", NewLastSeq: 300},
+	} {
+		if err := s.HandleEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("replies count channel and thread cursors", func(t *testing.T) {
+		readers, count, err := s.GetThreadReadersForSeq("c1", "r1", 250, 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(readers)
+		if count != 2 || len(readers) != 2 || readers[0] != "u1" || readers[1] != "u2" {
+			t.Fatalf("expected u1 and u2, got %v (%d)", readers, count)
+		}
+
+		if counts := s.GetThreadReadCounts("c1", "r1", []int64{250, 400}); counts[250] != 2 || counts[400] != 1 {
+			t.Fatalf("unexpected thread read counts %v", counts)
+		}
+	})
+
+	t.Run("thread cursors do not count for the channel", func(t *testing.T) {
+		if _, count, _ := s.GetReadersForSeq("c1", 250, 100); count != 1 {
+			t.Fatalf("expected 1 channel reader, got %d", count)
+		}
+		if s.IsReader("c1", "u2", 250) {
+			t.Fatal("expected u2 not to have read the channel at seq 250")
+		}
+	})
+
+	t.Run("thread cursors only count for their thread", func(t *testing.T) {
+		if s.IsThreadReader("c1", "r1", "u3", 250) {
+			t.Fatal("expected u3 not to have read r1")
+		}
+		if !s.IsThreadReader("c1", "r2", "u3", 250) {
+			t.Fatal("expected u3 to have read r2")
+		}
+		if !s.IsThreadReader("c1", "r2", "u1", 250) {
+			t.Fatal("expected u1 to have read r2 through the channel")
+		}
+	})
+
+	t.Run("users are not counted twice", func(t *testing.T) {
+		if err := s.HandleEvent(&ReadCursorEvent{ChannelID: "c1", UserID: "u2", NewLastSeq: 500}); err != nil {
+			t.Fatal(err)
+		}
+		if _, count, _ := s.GetThreadReadersForSeq("c1", "r1", 250, 100); count != 2 {
+			t.Fatalf("expected 2 readers, got %d", count)
+		}
+	})
+
+	t.Run("limit applies to the merged readers", func(t *testing.T) {
+		readers, count, _ := s.GetThreadReadersForSeq("c1", "r2", 250, 1)
+		if count != 3 || len(readers) != 1 {
+			t.Fatalf("expected 1 of 3 readers, got %v (%d)", readers, count)
+		}
+	})
+
+	t.Run("survives snapshots and channel rebuilds", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := s.WriteSnapshot(&buf, SnapshotHeader{}); err != nil {
+			t.Fatal(err)
+		}
+		restored := NewService(1000)
+		if _, err := restored.ReadSnapshot(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !restored.IsThreadReader("c1", "r2", "u3", 300) {
+			t.Fatal("expected thread cursors to be restored from the snapshot")
+		}
+
+		restored.RebuildChannel("c1", map[string]int64{"u1": 500}, map[string]map[string]int64{
+			"r2": {"u3": 300},
+		})
+		if !restored.IsThreadReader("c1", "r2", "u3", 300) {
+			t.Fatal("expected thread cursors to be rebuilt with the channel")
+		}
+		if restored.IsThreadReader("c1", "r1", "u2", 300) {
+			t.Fatal("expected the thread cursors missing from the rebuild to be dropped")
+		}
+	})
+
+	t.Run("removing a user drops their thread cursors", func(t *testing.T) {
+		if err := s.HandleEvent(&ReadCursorEvent{Type: EventTypeRemoved, ChannelID: "c1", UserID: "u3"}); err != nil {
+			t.Fatal(err)
+		}
+		if s.IsThreadReader("c1", "r2", "u3", 0) {
+			t.Fatal("expected u3 to be removed from the thread")
+		}
+	})
+}
+
+func TestRebuildThreads(t *testing.T) {
+	s := NewService(1000)
+	for _, e := range []*ReadCursorEvent{
+		{ChannelID: "c1", UserID: "u1", NewLastSeq: 100},
+		{ChannelID: "c1", RootID: "r1", UserID: "u1", NewLastSeq: 400},
+		{ChannelID: "c1", RootID: "r2", UserID: "u3", NewLastSeq: 400},
+	} {
+		if err := s.HandleEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s.BeginRebuild("c1")
+	if err := s.HandleEvent(&ReadCursorEvent{ChannelID: "c1", RootID: "r1", UserID: "u2", NewLastSeq: 500}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 数据库中 u2 的游标较旧，u3 的游标已被删除
+	s.RebuildThreads("c1", map[string]map[string]int64{
+		"r1": {"u1": 200, "u2": 300},
+	})
+
+	if !s.IsThreadReader("c1", "r1", "u1", 200) || s.IsThreadReader("c1", "r1", "u1", 400) {
+		t.Fatal("expected the cursor of u1 to be replaced by the one from the database")
+	}
+	if !s.IsThreadReader("c1", "r1", "u2", 500) {
+		t.Fatal("expected the cursor advanced during the rebuild to be kept")
+	}
+	if s.IsThreadReader("c1", "r2", "u3", 0) {
+		t.Fatal("expected the thread cursors missing from the rebuild to be dropped")
+	}
+	if !s.IsReader("c1", "u1", 100) {
+		t.Fatal("expected the channel cursors to be kept")
+	}
+}
+
+func TestThreadReadersSnapshot(t *testing.T) {
+	// u1 在频道中读到回复之前，在线程中读完了回复。频道游标在查询期间前移时，u1 只能被计数一次
+	for i := 0; i < 200; i++ {
+		s := NewService(1000)
+		for _, e := range []*ReadCursorEvent{
+			{ChannelID: "c1", UserID: "u1", NewLastSeq: 100},
+			{ChannelID: "c1", RootID: "r1", UserID: "u1", NewLastSeq: 300},
+		} {
+			if err := s.HandleEvent(e); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_ = s.HandleEvent(&ReadCursorEvent{ChannelID: "c1", UserID: "u1", NewLastSeq: 500})
+		}()
+		readers, count, err := s.GetThreadReadersForSeq("c1", "r1", 250, 100)
+		<-done
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 || len(readers) != 1 {
+			t.Fatalf("expected u1 to be counted once, got %v (%d)", readers, count)
+		}
+	}
+}
+
+func TestExpiredEvents(t *testing.T) {
+	s := NewService(1000)
+	for _, e := range []*ReadCursorEvent{
+		{Type: EventTypeAdvanced, ChannelID: "c1", UserID: "u1", NewLastSeq: 150},
+		{Type: EventTypeAdvanced, ChannelID: "c1", RootID: "r1", UserID: "u1", NewLastSeq: 400},
+		{Type: EventTypeAdvanced, ChannelID: "c1", RootID: "r2", UserID: "u1", NewLastSeq: 500},
+	} {
+		if err := s.HandleEvent(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("an expired channel cursor keeps the thread cursors", func(t *testing.T) {
+		if err := s.HandleEvent(&ReadCursorEvent{Type: EventTypeExpired, ChannelID: "c1", UserID: "u1"}); err != nil {
+			t.Fatal(err)
+		}
+		if s.IsReader("c1", "u1", 0) {
+			t.Fatal("expected the channel cursor of u1 to be gone")
+		}
+		if !s.IsThreadReader("c1", "r1", "u1", 400) {
+			t.Fatal("expected the thread cursors of u1 to be kept")
+		}
+	})
+
+	t.Run("an expired thread cursor keeps the other threads", func(t *testing.T) {
+		if err := s.HandleEvent(&ReadCursorEvent{Type: EventTypeExpired, ChannelID: "c1", RootID: "r1", UserID: "u1"}); err != nil {
+			t.Fatal(err)
+		}
+		if s.IsThreadReader("c1", "r1", "u1", 0) {
+			t.Fatal("expected the cursor of u1 in r1 to be gone")
+		}
+		if !s.IsThreadReader("c1", "r2", "u1", 500) {
+			t.Fatal("expected the cursor of u1 in r2 to be kept")
+		}
+	})
+}
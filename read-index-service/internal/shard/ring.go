@@ -0,0 +1,150 @@
+package shard
+
+import (
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultVirtualNodes 每个副本在哈希环上的虚拟节点数
+const DefaultVirtualNodes = 128
+
+// Ring 一致性哈希环，把频道分配给副本。
+// 所有副本使用相同的节点列表时，对同一个频道得出相同的归属；
+// 增减副本时只有约 1/N 的频道需要迁移。
+type Ring struct {
+	self   string
+	nodes  []string
+	peers  map[string]*url.URL
+	hashes []uint64
+	owners map[uint64]string
+}
+
+// NewRing 创建哈希环。self 是本副本的 ID，peers 为全部副本（包含自身）的 ID -> 基础 URL。
+// peers 为空时表示未分片，本副本拥有所有频道。
+func NewRing(self string, peers map[string]*url.URL, virtualNodes int) (*Ring, error) {
+	if virtualNodes <= 0 {
+		virtualNodes = DefaultVirtualNodes
+	}
+
+	r := &Ring{
+		self:   self,
+		peers:  peers,
+		owners: make(map[uint64]string),
+	}
+	if len(peers) == 0 {
+		return r, nil
+	}
+	if _, ok := peers[self]; !ok {
+		return nil, fmt.Errorf("shard %q is not in the peer list", self)
+	}
+
+	for id := range peers {
+		r.nodes = append(r.nodes, id)
+	}
+	sort.Strings(r.nodes)
+
+	for _, id := range r.nodes {
+		for i := 0; i < virtualNodes; i++ {
+			h := hash(fmt.Sprintf("%s#%d", id, i))
+			// 哈希冲突时保留字典序较小的节点，保证各副本结果一致
+			if owner, exists := r.owners[h]; exists && owner < id {
+				continue
+			}
+			r.owners[h] = id
+		}
+	}
+	for h := range r.owners {
+		r.hashes = append(r.hashes, h)
+	}
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+
+	return r, nil
+}
+
+// Sharded 是否启用了分片
+func (r *Ring) Sharded() bool {
+	return len(r.nodes) > 0
+}
+
+// Self 返回本副本的 ID
+func (r *Ring) Self() string {
+	return r.self
+}
+
+// Nodes 返回按字典序排列的全部副本 ID
+func (r *Ring) Nodes() []string {
+	return r.nodes
+}
+
+// Owner 返回拥有该频道的副本 ID
+func (r *Ring) Owner(channelID string) string {
+	if !r.Sharded() {
+		return r.self
+	}
+
+	h := hash(channelID)
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
+
+// Owns 判断本副本是否拥有该频道
+func (r *Ring) Owns(channelID string) bool {
+	return r.Owner(channelID) == r.self
+}
+
+// PeerURL 返回副本的基础 URL
+func (r *Ring) PeerURL(id string) *url.URL {
+	return r.peers[id]
+}
+
+// Layout 返回环的描述，节点列表变化时随之变化，用于判断快照是否仍然适用
+func (r *Ring) Layout() string {
+	if !r.Sharded() {
+		return ""
+	}
+	return r.self + "@" + strings.Join(r.nodes, ",")
+}
+
+// ParsePeers 解析 "id=url,id=url" 格式的副本列表
+func ParsePeers(s string) (map[string]*url.URL, error) {
+	peers := make(map[string]*url.URL)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		id, rawURL, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || rawURL == "" {
+			return nil, fmt.Errorf("invalid peer %q, expected id=url", entry)
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid url for peer %q: %s", id, rawURL)
+		}
+		if _, exists := peers[id]; exists {
+			return nil, fmt.Errorf("duplicate peer %q", id)
+		}
+		peers[id] = u
+	}
+	return peers, nil
+}
+
+// hash 在 FNV-1a 之后做一次 murmur3 的终结混合，相近的字符串（如 "id#1"、"id#2"）也能均匀分布
+func hash(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	x := h.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
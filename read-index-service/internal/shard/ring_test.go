@@ -0,0 +1,116 @@
+package shard
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func peers(t *testing.T, ids ...string) map[string]*url.URL {
+	t.Helper()
+	m := make(map[string]*url.URL)
+	for _, id := range ids {
+		u, err := url.Parse("http://" + id + ":8066")
+		if err != nil {
+			t.Fatal(err)
+		}
+		m[id] = u
+	}
+	return m
+}
+
+func TestRingAssignsEveryChannelToExactlyOneReplica(t *testing.T) {
+	ids := []string{"replica-0", "replica-1", "replica-2"}
+	rings := make([]*Ring, len(ids))
+	for i, id := range ids {
+		r, err := NewRing(id, peers(t, ids...), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rings[i] = r
+	}
+
+	perReplica := make(map[string]int)
+	for i := 0; i < 30000; i++ {
+		channelID := fmt.Sprintf("channel-%d", i)
+
+		owners := 0
+		for _, r := range rings {
+			if r.Owns(channelID) {
+				owners++
+			}
+			if r.Owner(channelID) != rings[0].Owner(channelID) {
+				t.Fatalf("replicas disagree on the owner of %s", channelID)
+			}
+		}
+		if owners != 1 {
+			t.Fatalf("expected exactly one owner for %s, got %d", channelID, owners)
+		}
+		perReplica[rings[0].Owner(channelID)]++
+	}
+
+	// 虚拟节点让分布大致均匀
+	for id, n := range perReplica {
+		if n < 7000 || n > 13000 {
+			t.Fatalf("unbalanced ring: %s owns %d of 30000 channels", id, n)
+		}
+	}
+}
+
+func TestRingMovesFewChannelsWhenAddingReplica(t *testing.T) {
+	before, err := NewRing("replica-0", peers(t, "replica-0", "replica-1", "replica-2"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := NewRing("replica-0", peers(t, "replica-0", "replica-1", "replica-2", "replica-3"), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	moved := 0
+	for i := 0; i < 10000; i++ {
+		channelID := fmt.Sprintf("channel-%d", i)
+		if before.Owner(channelID) != after.Owner(channelID) {
+			if after.Owner(channelID) != "replica-3" {
+				t.Fatalf("%s moved between existing replicas", channelID)
+			}
+			moved++
+		}
+	}
+	if moved > 3500 {
+		t.Fatalf("expected about a quarter of channels to move, got %d of 10000", moved)
+	}
+	if before.Layout() == after.Layout() {
+		t.Fatal("expected layout to change with the peer list")
+	}
+}
+
+func TestUnshardedRingOwnsEverything(t *testing.T) {
+	r, err := NewRing("", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Sharded() || !r.Owns("any-channel") || r.Layout() != "" {
+		t.Fatal("expected an unsharded ring to own every channel")
+	}
+}
+
+func TestParsePeers(t *testing.T) {
+	p, err := ParsePeers("replica-0=http://a:8066, replica-1=http://b:8066")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p) != 2 || p["replica-1"].Host != "b:8066" {
+		t.Fatalf("unexpected peers: %v", p)
+	}
+
+	for _, invalid := range []string{"replica-0", "replica-0=", "replica-0=not a url", "a=http://a,a=http://b"} {
+		if _, err := ParsePeers(invalid); err == nil {
+			t.Fatalf("expected error for %q", invalid)
+		}
+	}
+
+	if _, err := NewRing("replica-9", p, 0); err == nil {
+		t.Fatal("expected error when self is not a peer")
+	}
+}
@@ -0,0 +1,141 @@
+package snapshot
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mattermost/mattermost-read-index-service/internal/index"
+	"github.com/mattermost/mattermost-read-index-service/internal/shard"
+)
+
+const snapshotFileName = "index.snap"
+
+// PositionSource 返回当前已确认的最后一条 Stream 消息 ID
+type PositionSource interface {
+	LastAckedID() string
+}
+
+// Manager 负责定期将索引快照持久化到本地目录，并在启动时加载
+type Manager struct {
+	dir          string
+	interval     time.Duration
+	indexService *index.Service
+	position     PositionSource
+	ring         *shard.Ring
+}
+
+// NewManager 创建快照管理器
+func NewManager(dir string, interval time.Duration, indexService *index.Service, position PositionSource, ring *shard.Ring) *Manager {
+	return &Manager{
+		dir:          dir,
+		interval:     interval,
+		indexService: indexService,
+		position:     position,
+		ring:         ring,
+	}
+}
+
+// Load 加载最近一次快照，返回快照对应的 Stream 消息 ID。
+// 快照不存在时返回空字符串且不报错。
+// 分片布局与快照时不同时，只保留仍归本副本负责的频道，并返回空字符串，
+// 由调用方重建或完整回放新分配到本副本的频道。
+func (m *Manager) Load() (string, error) {
+	f, err := os.Open(m.path())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	defer f.Close()
+
+	header, err := m.indexService.ReadSnapshot(bufio.NewReader(f))
+	if err != nil {
+		return "", err
+	}
+
+	log.Printf("Loaded snapshot: channels=%d, stream_id=%s, created_at=%s",
+		header.Channels, header.StreamID, time.UnixMilli(header.CreatedAt).Format(time.RFC3339))
+
+	if header.Layout != m.ring.Layout() {
+		removed := m.indexService.Retain(m.ring.Owns)
+		log.Printf("Shard layout changed from %q to %q, dropped %d channels no longer owned",
+			header.Layout, m.ring.Layout(), removed)
+		return "", nil
+	}
+
+	return header.StreamID, nil
+}
+
+// Save 将当前索引写入快照文件。先写临时文件再原子替换，避免中途崩溃留下损坏的快照。
+func (m *Manager) Save() error {
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	// 先记录位置再导出状态：回放是幂等的，重复应用快照之后的事件不会出错
+	header := index.SnapshotHeader{
+		StreamID:  m.position.LastAckedID(),
+		CreatedAt: time.Now().UnixMilli(),
+		Layout:    m.ring.Layout(),
+	}
+
+	tmp, err := os.CreateTemp(m.dir, snapshotFileName+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := m.indexService.WriteSnapshot(w, header); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmp.Name(), m.path()); err != nil {
+		return fmt.Errorf("replace snapshot: %w", err)
+	}
+
+	return nil
+}
+
+// Start 按固定间隔保存快照，直到 ctx 取消
+func (m *Manager) Start(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			start := time.Now()
+			if err := m.Save(); err != nil {
+				log.Printf("Failed to save snapshot: %v", err)
+				continue
+			}
+			log.Printf("Saved snapshot in %s", time.Since(start))
+		}
+	}
+}
+
+func (m *Manager) path() string {
+	return filepath.Join(m.dir, snapshotFileName)
+}
@@ -0,0 +1,152 @@
+package snapshot
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mattermost/mattermost-read-index-service/internal/index"
+	"github.com/mattermost/mattermost-read-index-service/internal/shard"
+)
+
+type fixedPosition string
+
+func (p fixedPosition) LastAckedID() string {
+	return string(p)
+}
+
+func newRing(t *testing.T, self string, ids ...string) *shard.Ring {
+	t.Helper()
+	peers := make(map[string]*url.URL)
+	for _, id := range ids {
+		u, err := url.Parse("http://" + id + ":8066")
+		if err != nil {
+			t.Fatal(err)
+		}
+		peers[id] = u
+	}
+	r, err := shard.NewRing(self, peers, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func newIndex(t *testing.T, channels int) *index.Service {
+	t.Helper()
+	s := index.NewService(0)
+	for i := 0; i < channels; i++ {
+		event := &index.ReadCursorEvent{ChannelID: fmt.Sprintf("c%d", i), UserID: "u1", NewLastSeq: 100}
+		if err := s.HandleEvent(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestLoadWithoutSnapshot(t *testing.T) {
+	m := NewManager(t.TempDir(), 0, index.NewService(0), fixedPosition(""), newRing(t, "r0"))
+
+	streamID, err := m.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamID != "" {
+		t.Fatalf("expected no stream ID, got %q", streamID)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	ring := newRing(t, "r0")
+
+	saved := NewManager(dir, 0, newIndex(t, 10), fixedPosition("42-1"), ring)
+	if err := saved.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 临时文件已被替换为快照
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != snapshotFileName {
+		t.Fatalf("expected only %s in the directory, got %v", snapshotFileName, entries)
+	}
+
+	loaded := NewManager(dir, 0, index.NewService(0), fixedPosition(""), ring)
+	streamID, err := loaded.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamID != "42-1" {
+		t.Fatalf("expected stream ID 42-1, got %q", streamID)
+	}
+	for i := 0; i < 10; i++ {
+		if !loaded.indexService.IsReader(fmt.Sprintf("c%d", i), "u1", 100) {
+			t.Fatalf("expected the cursor of channel c%d to be restored", i)
+		}
+	}
+
+	t.Run("a new snapshot replaces the previous one", func(t *testing.T) {
+		saved.position = fixedPosition("43-0")
+		if err := saved.Save(); err != nil {
+			t.Fatal(err)
+		}
+
+		streamID, err := NewManager(dir, 0, index.NewService(0), fixedPosition(""), ring).Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if streamID != "43-0" {
+			t.Fatalf("expected stream ID 43-0, got %q", streamID)
+		}
+	})
+
+	t.Run("a corrupted snapshot fails to load", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, snapshotFileName), []byte("corrupted"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := NewManager(dir, 0, index.NewService(0), fixedPosition(""), ring).Load(); err == nil {
+			t.Fatal("expected an error")
+		}
+	})
+}
+
+func TestLoadAfterLayoutChange(t *testing.T) {
+	dir := t.TempDir()
+	const channels = 100
+
+	saved := NewManager(dir, 0, newIndex(t, channels), fixedPosition("42-1"), newRing(t, "r0", "r0", "r1"))
+	if err := saved.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	ring := newRing(t, "r0", "r0", "r1", "r2")
+	loaded := NewManager(dir, 0, index.NewService(0), fixedPosition(""), ring)
+	streamID, err := loaded.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if streamID != "" {
+		t.Fatalf("expected no stream ID after a layout change, got %q", streamID)
+	}
+
+	owned := 0
+	for i := 0; i < channels; i++ {
+		channelID := fmt.Sprintf("c%d", i)
+		if got, expected := loaded.indexService.IsReader(channelID, "u1", 100), ring.Owns(channelID); got != expected {
+			t.Fatalf("expected channel %s kept to be %v", channelID, expected)
+		}
+		if ring.Owns(channelID) {
+			owned++
+		}
+	}
+	if owned == 0 || owned == channels {
+		t.Fatalf("expected the new layout to own some of the channels, got %d", owned)
+	}
+}
@@ -86,6 +86,7 @@ func (api *API) InitChannel() {
 	api.BaseRoutes.Channel.Handle("/read_cursor/advance", api.APISessionRequired(advanceReadCursor)).Methods(http.MethodPost)
 	api.BaseRoutes.Channel.Handle("/read_cursor", api.APISessionRequired(getReadCursor)).Methods(http.MethodGet)
 	api.BaseRoutes.Channel.Handle("/read_cursors", api.APISessionRequired(getChannelReadCursors)).Methods(http.MethodGet)
+	api.BaseRoutes.Channel.Handle("/read_receipts/summary", api.APISessionRequired(getChannelReadReceiptsSummary)).Methods(http.MethodGet)
 }
 
 func createChannel(c *Context, w http.ResponseWriter, r *http.Request) {
@@ -388,6 +389,12 @@ func patchChannel(c *Context, w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Only channel admins can turn read receipts on or off
+	if patch.ReadReceiptsDisabled != nil && !c.App.SessionHasPermissionToChannel(c.AppContext, *c.AppContext.Session(), c.Params.ChannelId, model.PermissionManageChannelRoles) {
+		c.SetPermissionError(model.PermissionManageChannelRoles)
+		return
+	}
+
 	rchannel, appErr := c.App.PatchChannel(c.AppContext, oldChannel, patch, c.AppContext.Session().UserId)
 	if appErr != nil {
 		c.Err = appErr
@@ -2618,6 +2625,12 @@ func advanceReadCursor(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if cursor == nil {
+		// Not recorded because of the read receipts privacy settings
+		ReturnStatusOK(w)
+		return
+	}
+
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(cursor); err != nil {
 		c.Logger.Warn("Error while writing response", mlog.Err(err))
@@ -2659,7 +2672,18 @@ func getChannelReadCursors(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cursors, appErr := c.App.GetChannelReadCursors(c.AppContext, c.Params.ChannelId)
+	channel, appErr := c.App.GetChannel(c.AppContext, c.Params.ChannelId)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	if appErr = c.App.CheckReadReceiptsVisible(c.AppContext, c.AppContext.Session().UserId, channel); appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	cursors, appErr := c.App.GetChannelReadCursors(c.AppContext, channel)
 	if appErr != nil {
 		c.Err = appErr
 		return
@@ -2669,3 +2693,47 @@ func getChannelReadCursors(c *Context, w http.ResponseWriter, r *http.Request) {
 		c.Logger.Warn("Error while writing response", mlog.Err(err))
 	}
 }
+
+// getChannelReadReceiptsSummary returns the daily read percentage of the channel's recent posts
+// GET /api/v4/channels/{channel_id}/read_receipts/summary?days=30
+func getChannelReadReceiptsSummary(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireChannelId()
+	if c.Err != nil {
+		return
+	}
+
+	days := 30
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		var err error
+		if days, err = strconv.Atoi(daysStr); err != nil {
+			c.SetInvalidParamWithErr("days", err)
+			return
+		}
+	}
+
+	if !c.App.SessionHasPermissionToChannel(c.AppContext, *c.AppContext.Session(), c.Params.ChannelId, model.PermissionReadChannelContent) {
+		c.SetPermissionError(model.PermissionReadChannelContent)
+		return
+	}
+
+	channel, appErr := c.App.GetChannel(c.AppContext, c.Params.ChannelId)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	if appErr = c.App.CheckReadReceiptsVisible(c.AppContext, c.AppContext.Session().UserId, channel); appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	summary, appErr := c.App.GetChannelReadReceiptsSummary(c.AppContext, channel, days)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(summary); err != nil {
+		c.Logger.Warn("Error while writing response", mlog.Err(err))
+	}
+}
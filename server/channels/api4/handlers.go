@@ -219,10 +219,18 @@ func (api *API) APILocal(h handlerFunc, opts ...APIHandlerOption) http.Handler {
 	return handler
 }
 
-func (api *API) RateLimitedHandler(apiHandler http.Handler, settings model.RateLimitSettings) http.Handler {
+// RateLimitedHandler limits the requests of the handler to the quota of settings, tracked in the
+// rate limit store of the configuration under name
+func (api *API) RateLimitedHandler(apiHandler http.Handler, name string, settings model.RateLimitSettings) http.Handler {
 	settings.SetDefaults()
 
-	rateLimiter, err := app.NewRateLimiter(&settings, []string{})
+	store, err := api.srv.NewRateLimitStore(&settings, name)
+	if err != nil {
+		api.srv.Log().Error("getRateLimitedHandler", mlog.Err(err))
+		return nil
+	}
+
+	rateLimiter, err := app.NewRateLimiter(&settings, []string{}, store)
 	if err != nil {
 		api.srv.Log().Error("getRateLimitedHandler", mlog.Err(err))
 		return nil
@@ -56,6 +56,8 @@ func (api *API) InitPost() {
 	api.BaseRoutes.Post.Handle("/read_receipts", api.APISessionRequired(getPostReadReceipts)).Methods(http.MethodGet)
 	api.BaseRoutes.Post.Handle("/read_receipts/count", api.APISessionRequired(getPostReadReceiptsCount)).Methods(http.MethodGet)
 	api.BaseRoutes.Posts.Handle("/read_receipts/counts", api.APISessionRequired(getBatchPostReadReceiptsCounts)).Methods(http.MethodPost)
+	api.BaseRoutes.Post.Handle("/read_receipts/non_readers", api.APISessionRequired(getPostNonReaders)).Methods(http.MethodGet)
+	api.BaseRoutes.Post.Handle("/read_receipts/remind", api.APISessionRequired(remindPostNonReaders)).Methods(http.MethodPost)
 }
 
 func createPostChecks(where string, c *Context, post *model.Post) {
@@ -462,6 +464,7 @@ func getPost(c *Context, w http.ResponseWriter, r *http.Request) {
 	}
 
 	post = c.App.PreparePostForClientWithEmbedsAndImages(c.AppContext, post, &model.PreparePostForClientOpts{IncludePriority: true})
+	c.App.AddPostTranslationsForUser(c.AppContext, []*model.Post{post}, c.AppContext.Session().UserId)
 	post, err = c.App.SanitizePostMetadataForUser(c.AppContext, post, c.AppContext.Session().UserId)
 	if err != nil {
 		c.Err = err
@@ -1418,6 +1421,9 @@ func rewriteMessage(c *Context, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxReadReceiptsReaders caps the readers returned when the client does not ask for a page size
+const maxReadReceiptsReaders = 1000
+
 // getPostReadReceipts returns the list of users who have read a post
 func getPostReadReceipts(c *Context, w http.ResponseWriter, r *http.Request) {
 	c.RequirePostId()
@@ -1425,38 +1431,46 @@ func getPostReadReceipts(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	postId := c.Params.PostId
-
-	// Get the post to find its channel and timestamp
-	post, appErr := c.App.GetSinglePost(c.AppContext, postId, false)
+	post, appErr := c.App.GetSinglePost(c.AppContext, c.Params.PostId, false)
 	if appErr != nil {
 		c.Err = appErr
 		return
 	}
 
-	// Check permission to read channel
 	if !c.App.SessionHasPermissionToChannel(c.AppContext, *c.AppContext.Session(), post.ChannelId, model.PermissionReadChannelContent) {
 		c.SetPermissionError(model.PermissionReadChannelContent)
 		return
 	}
 
-	// Get all read cursors for this channel
-	cursors, appErr := c.App.GetChannelReadCursors(c.AppContext, post.ChannelId)
+	channel, appErr := c.App.GetChannel(c.AppContext, post.ChannelId)
 	if appErr != nil {
 		c.Err = appErr
 		return
 	}
 
-	// Filter cursors that have read this post (cursor >= post.CreateAt)
-	var readReceipts []map[string]interface{}
+	if appErr = c.App.CheckReadReceiptsVisible(c.AppContext, c.AppContext.Session().UserId, channel); appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	limit := maxReadReceiptsReaders
+	if r.URL.Query().Get("per_page") != "" {
+		limit = c.Params.PerPage
+	}
+
+	cursors, _, appErr := c.App.GetPostReadReceipts(c.AppContext, channel, post, limit)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	readReceipts := make([]map[string]any, 0, len(cursors))
 	for _, cursor := range cursors {
-		if cursor.UserId != post.UserId && cursor.LastPostSeq >= post.CreateAt {
-			readReceipts = append(readReceipts, map[string]interface{}{
-				"user_id":       cursor.UserId,
-				"last_post_seq": cursor.LastPostSeq,
-				"read_at":       cursor.UpdatedAt,
-			})
-		}
+		readReceipts = append(readReceipts, map[string]any{
+			"user_id":       cursor.UserId,
+			"last_post_seq": cursor.LastPostSeq,
+			"read_at":       cursor.UpdatedAt,
+		})
 	}
 
 	if err := json.NewEncoder(w).Encode(readReceipts); err != nil {
@@ -1471,49 +1485,64 @@ func getPostReadReceiptsCount(c *Context, w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	postId := c.Params.PostId
-
-	// TODO: Add caching layer for better performance
-	// For now, we rely on database query which is acceptable with proper indexes
-
-	// Get the post to find its channel and timestamp
-	post, appErr := c.App.GetSinglePost(c.AppContext, postId, false)
+	post, appErr := c.App.GetSinglePost(c.AppContext, c.Params.PostId, false)
 	if appErr != nil {
 		c.Err = appErr
 		return
 	}
 
-	// Check permission to read channel
 	if !c.App.SessionHasPermissionToChannel(c.AppContext, *c.AppContext.Session(), post.ChannelId, model.PermissionReadChannelContent) {
 		c.SetPermissionError(model.PermissionReadChannelContent)
 		return
 	}
 
-	// Get all read cursors for this channel
-	cursors, appErr := c.App.GetChannelReadCursors(c.AppContext, post.ChannelId)
+	channel, appErr := c.App.GetChannel(c.AppContext, post.ChannelId)
 	if appErr != nil {
 		c.Err = appErr
 		return
 	}
 
-	// Count cursors that have read this post
-	// Exclude the post author (users don't count as reading their own messages)
-	count := 0
-	for _, cursor := range cursors {
-		if cursor.UserId != post.UserId && cursor.LastPostSeq >= post.CreateAt {
-			count++
-		}
+	if appErr = c.App.CheckReadReceiptsVisible(c.AppContext, c.AppContext.Session().UserId, channel); appErr != nil {
+		c.Err = appErr
+		return
 	}
 
-	// TODO: Cache the result for better performance
+	count, appErr := c.App.GetPostReadReceiptsCount(c.AppContext, post)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
 
-	response := map[string]int{"count": count}
+	response := map[string]int64{"count": count}
 	if err := json.NewEncoder(w).Encode(response); err != nil {
 		c.Logger.Warn("Error encoding read count", mlog.Err(err))
 	}
 }
 
-// getBatchPostReadReceiptsCounts returns read counts for multiple posts in one request
+// readReceiptsCountErrorForChannel returns why the session user can't see read counts in the
+// channel, or an empty string if they can
+func readReceiptsCountErrorForChannel(c *Context, channelId string) (string, *model.AppError) {
+	if !c.App.SessionHasPermissionToChannel(c.AppContext, *c.AppContext.Session(), channelId, model.PermissionReadChannelContent) {
+		return model.ReadReceiptsCountErrorForbidden, nil
+	}
+
+	channel, appErr := c.App.GetChannel(c.AppContext, channelId)
+	if appErr != nil {
+		return "", appErr
+	}
+
+	if appErr = c.App.CheckReadReceiptsVisible(c.AppContext, c.AppContext.Session().UserId, channel); appErr != nil {
+		if appErr.StatusCode == http.StatusForbidden {
+			return model.ReadReceiptsCountErrorHidden, nil
+		}
+		return "", appErr
+	}
+
+	return "", nil
+}
+
+// getBatchPostReadReceiptsCounts returns read counts for multiple posts in one request. Posts
+// whose count can't be returned are listed in the errors map with the reason.
 func getBatchPostReadReceiptsCounts(c *Context, w http.ResponseWriter, r *http.Request) {
 	var postIds []string
 	if err := json.NewDecoder(r.Body).Decode(&postIds); err != nil {
@@ -1533,49 +1562,146 @@ func getBatchPostReadReceiptsCounts(c *Context, w http.ResponseWriter, r *http.R
 		return
 	}
 
-	results := make(map[string]int)
-	channelCursorsCache := make(map[string][]*model.ChannelReadCursor)
-
 	for _, postId := range postIds {
-		// TODO: Check cache first for better performance
-
-		// Get post
-		post, appErr := c.App.GetSinglePost(c.AppContext, postId, false)
-		if appErr != nil {
-			// Skip posts that can't be fetched
-			continue
+		if !model.IsValidId(postId) {
+			c.SetInvalidParam("post_ids")
+			return
 		}
+	}
 
-		// Check permission
-		if !c.App.SessionHasPermissionToChannel(c.AppContext, *c.AppContext.Session(), post.ChannelId, model.PermissionReadChannelContent) {
-			continue
-		}
+	posts, appErr := c.App.GetPostsForReadReceipts(postIds)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
 
-		// Get cursors for this channel (cached per channel)
-		cursors, exists := channelCursorsCache[post.ChannelId]
-		if !exists {
-			var appErr *model.AppError
-			cursors, appErr = c.App.GetChannelReadCursors(c.AppContext, post.ChannelId)
+	response := &model.PostsReadReceiptsCounts{Errors: make(map[string]string)}
+	for _, postId := range postIds {
+		response.Errors[postId] = model.ReadReceiptsCountErrorNotFound
+	}
+
+	// Permissions and read receipts visibility are checked once per channel
+	reasons := make(map[string]string)
+	visiblePosts := make([]*model.Post, 0, len(posts))
+	for _, post := range posts {
+		reason, checked := reasons[post.ChannelId]
+		if !checked {
+			reason, appErr = readReceiptsCountErrorForChannel(c, post.ChannelId)
 			if appErr != nil {
-				continue
+				c.Err = appErr
+				return
 			}
-			channelCursorsCache[post.ChannelId] = cursors
+			reasons[post.ChannelId] = reason
 		}
 
-		// Count
-		count := 0
-		for _, cursor := range cursors {
-			if cursor.UserId != post.UserId && cursor.LastPostSeq >= post.CreateAt {
-				count++
-			}
+		if reason != "" {
+			response.Errors[post.Id] = reason
+			continue
 		}
 
-		results[postId] = count
+		delete(response.Errors, post.Id)
+		visiblePosts = append(visiblePosts, post)
+	}
 
-		// TODO: Cache the result for better performance
+	response.Counts, appErr = c.App.GetPostsReadReceiptsCounts(c.AppContext, visiblePosts)
+	if appErr != nil {
+		c.Err = appErr
+		return
 	}
 
-	if err := json.NewEncoder(w).Encode(results); err != nil {
+	if err := json.NewEncoder(w).Encode(response); err != nil {
 		c.Logger.Warn("Error encoding batch read counts", mlog.Err(err))
 	}
 }
+
+// postWithVisibleReadReceipts loads the post from the request and checks that the session user
+// may see read receipts for it
+func postWithVisibleReadReceipts(c *Context) (*model.Post, *model.Channel) {
+	c.RequirePostId()
+	if c.Err != nil {
+		return nil, nil
+	}
+
+	post, appErr := c.App.GetSinglePost(c.AppContext, c.Params.PostId, false)
+	if appErr != nil {
+		c.Err = appErr
+		return nil, nil
+	}
+
+	if !c.App.SessionHasPermissionToChannel(c.AppContext, *c.AppContext.Session(), post.ChannelId, model.PermissionReadChannelContent) {
+		c.SetPermissionError(model.PermissionReadChannelContent)
+		return nil, nil
+	}
+
+	channel, appErr := c.App.GetChannel(c.AppContext, post.ChannelId)
+	if appErr != nil {
+		c.Err = appErr
+		return nil, nil
+	}
+
+	if appErr = c.App.CheckReadReceiptsVisible(c.AppContext, c.AppContext.Session().UserId, channel); appErr != nil {
+		c.Err = appErr
+		return nil, nil
+	}
+
+	return post, channel
+}
+
+// getPostNonReaders returns a page of the channel members who haven't read a post
+func getPostNonReaders(c *Context, w http.ResponseWriter, r *http.Request) {
+	post, channel := postWithVisibleReadReceipts(c)
+	if c.Err != nil {
+		return
+	}
+
+	nonReaders, appErr := c.App.GetPostNonReaders(c.AppContext, channel, post, c.Params.Page, c.Params.PerPage)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	for _, user := range nonReaders.Users {
+		c.App.SanitizeProfile(user, c.IsSystemAdmin())
+	}
+
+	if err := json.NewEncoder(w).Encode(nonReaders); err != nil {
+		c.Logger.Warn("Error encoding non readers", mlog.Err(err))
+	}
+}
+
+// remindPostNonReaders schedules a direct message to the channel members who haven't read a post
+func remindPostNonReaders(c *Context, w http.ResponseWriter, r *http.Request) {
+	post, _ := postWithVisibleReadReceipts(c)
+	if c.Err != nil {
+		return
+	}
+
+	auditRec := c.MakeAuditRecord(model.AuditEventRemindPostNonReaders, model.AuditStatusFail)
+	defer c.LogAuditRec(auditRec)
+	model.AddEventParameterToAuditRec(auditRec, "post_id", post.Id)
+
+	if !c.App.CanRemindPostNonReaders(c.AppContext, *c.AppContext.Session(), post) {
+		c.SetPermissionError(model.PermissionManageChannelRoles)
+		return
+	}
+
+	user, appErr := c.App.GetUser(c.AppContext.Session().UserId)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	job, appErr := c.App.RemindPostNonReaders(c.AppContext, post, user)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	auditRec.Success()
+	auditRec.AddMeta("job_id", job.Id)
+
+	w.WriteHeader(http.StatusAccepted)
+	if err := json.NewEncoder(w).Encode(map[string]string{"job_id": job.Id}); err != nil {
+		c.Logger.Warn("Error encoding reminder job", mlog.Err(err))
+	}
+}
@@ -580,6 +580,9 @@ func testS3(c *Context, w http.ResponseWriter, r *http.Request) {
 	if *cfg.FileSettings.AmazonS3SecretAccessKey == model.FakeSetting {
 		cfg.FileSettings.AmazonS3SecretAccessKey = c.App.Config().FileSettings.AmazonS3SecretAccessKey
 	}
+	if cfg.FileSettings.EncryptionKey != nil && *cfg.FileSettings.EncryptionKey == model.FakeSetting {
+		cfg.FileSettings.EncryptionKey = c.App.Config().FileSettings.EncryptionKey
+	}
 
 	appErr = c.App.TestFileStoreConnectionWithConfig(&cfg.FileSettings)
 	if appErr != nil {
@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/blang/semver/v4"
+	"github.com/gorilla/mux"
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/shared/mlog"
 
@@ -63,10 +64,17 @@ func (api *API) InitUser() {
 
 	api.BaseRoutes.User.Handle("/mfa", api.APISessionRequiredMfa(updateUserMfa)).Methods(http.MethodPut)
 	api.BaseRoutes.User.Handle("/mfa/generate", api.APISessionRequiredMfa(generateMfaSecret)).Methods(http.MethodPost)
+	api.BaseRoutes.User.Handle("/mfa/factors", api.APISessionRequiredMfa(getUserMfaFactors)).Methods(http.MethodGet)
+	api.BaseRoutes.User.Handle("/mfa/factors", api.APISessionRequiredMfa(resetUserMfaFactors)).Methods(http.MethodDelete)
+	api.BaseRoutes.User.Handle("/mfa/webauthn/register/begin", api.APISessionRequiredMfa(beginWebAuthnRegistration)).Methods(http.MethodPost)
+	api.BaseRoutes.User.Handle("/mfa/webauthn/register/finish", api.APISessionRequiredMfa(finishWebAuthnRegistration)).Methods(http.MethodPost)
+	api.BaseRoutes.User.Handle("/mfa/webauthn/{credential_id:[A-Za-z0-9]+}", api.APISessionRequiredMfa(deleteWebAuthnCredential)).Methods(http.MethodDelete)
+	api.BaseRoutes.User.Handle("/mfa/recovery_codes", api.APISessionRequiredMfa(generateMfaRecoveryCodes)).Methods(http.MethodPost)
 
 	api.BaseRoutes.Users.Handle("/login", api.APIHandler(login)).Methods(http.MethodPost)
 	api.BaseRoutes.Users.Handle("/login/sso/code-exchange", api.APIHandler(loginSSOCodeExchange)).Methods(http.MethodPost)
-	api.BaseRoutes.Users.Handle("/login/desktop_token", api.RateLimitedHandler(api.APIHandler(loginWithDesktopToken), model.RateLimitSettings{PerSec: model.NewPointer(2), MaxBurst: model.NewPointer(1)})).Methods(http.MethodPost)
+	api.BaseRoutes.Users.Handle("/login/webauthn", api.APIHandler(loginWebAuthnChallenge)).Methods(http.MethodPost)
+	api.BaseRoutes.Users.Handle("/login/desktop_token", api.RateLimitedHandler(api.APIHandler(loginWithDesktopToken), "login_desktop_token", model.RateLimitSettings{PerSec: model.NewPointer(2), MaxBurst: model.NewPointer(1)})).Methods(http.MethodPost)
 	api.BaseRoutes.Users.Handle("/login/switch", api.APIHandler(switchAccountType)).Methods(http.MethodPost)
 	api.BaseRoutes.Users.Handle("/login/cws", api.APIHandlerTrustRequester(loginCWS)).Methods(http.MethodPost)
 	api.BaseRoutes.Users.Handle("/logout", api.APIHandler(logout)).Methods(http.MethodPost)
@@ -1817,6 +1825,211 @@ func generateMfaSecret(c *Context, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// requireMfaFactorsPermission checks that the session can manage the second factors of the user
+// in the path. Only the user themself can add factors, which they will be asked for on login.
+func requireMfaFactorsPermission(c *Context, selfOnly bool) bool {
+	if c.AppContext.Session().IsOAuth {
+		c.SetPermissionError(model.PermissionEditOtherUsers)
+		c.Err.DetailedError += ", attempted access by oauth app"
+		return false
+	}
+
+	if selfOnly && c.AppContext.Session().UserId != c.Params.UserId {
+		c.SetPermissionError(model.PermissionEditOtherUsers)
+		return false
+	}
+
+	if !c.App.SessionHasPermissionToUser(*c.AppContext.Session(), c.Params.UserId) {
+		c.SetPermissionError(model.PermissionEditOtherUsers)
+		return false
+	}
+
+	if appErr := c.App.MFARequired(c.AppContext); !c.AppContext.Session().Local && c.AppContext.Session().UserId != c.Params.UserId && appErr != nil {
+		c.Err = appErr
+		return false
+	}
+
+	return true
+}
+
+func getUserMfaFactors(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireUserId()
+	if c.Err != nil {
+		return
+	}
+
+	if !requireMfaFactorsPermission(c, false) {
+		return
+	}
+
+	factors, appErr := c.App.GetMfaFactors(c.Params.UserId)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	if err := json.NewEncoder(w).Encode(factors); err != nil {
+		c.Logger.Warn("Error while writing response", mlog.Err(err))
+	}
+}
+
+func resetUserMfaFactors(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireUserId()
+	if c.Err != nil {
+		return
+	}
+
+	auditRec := c.MakeAuditRecord(model.AuditEventResetUserMfaFactors, model.AuditStatusFail)
+	defer c.LogAuditRec(auditRec)
+
+	if !requireMfaFactorsPermission(c, false) {
+		return
+	}
+
+	if user, appErr := c.App.GetUser(c.Params.UserId); appErr == nil {
+		model.AddEventParameterAuditableToAuditRec(auditRec, "user", user)
+	}
+
+	if appErr := c.App.UpdateMfa(c.AppContext, false, c.Params.UserId, ""); appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	auditRec.Success()
+	c.LogAudit("success - mfa factors reset")
+
+	ReturnStatusOK(w)
+}
+
+func beginWebAuthnRegistration(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireUserId()
+	if c.Err != nil {
+		return
+	}
+
+	if !requireMfaFactorsPermission(c, true) {
+		return
+	}
+
+	ceremony, appErr := c.App.BeginWebAuthnRegistration(c.Params.UserId)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := json.NewEncoder(w).Encode(ceremony); err != nil {
+		c.Logger.Warn("Error while writing response", mlog.Err(err))
+	}
+}
+
+func finishWebAuthnRegistration(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireUserId()
+	if c.Err != nil {
+		return
+	}
+
+	var registration model.WebAuthnRegistration
+	if err := json.NewDecoder(r.Body).Decode(&registration); err != nil {
+		c.SetInvalidParamWithErr("registration", err)
+		return
+	}
+
+	if registration.SessionId == "" {
+		c.SetInvalidParam("session_id")
+		return
+	}
+
+	if len(registration.Credential) == 0 {
+		c.SetInvalidParam("credential")
+		return
+	}
+
+	auditRec := c.MakeAuditRecord(model.AuditEventCreateWebAuthnCredential, model.AuditStatusFail)
+	defer c.LogAuditRec(auditRec)
+	model.AddEventParameterToAuditRec(auditRec, "name", registration.Name)
+
+	if !requireMfaFactorsPermission(c, true) {
+		return
+	}
+
+	credential, appErr := c.App.FinishWebAuthnRegistration(c.AppContext, c.Params.UserId, &registration)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	auditRec.Success()
+	auditRec.AddMeta("credential_id", credential.Id)
+	c.LogAudit("success - webauthn credential registered")
+
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(credential); err != nil {
+		c.Logger.Warn("Error while writing response", mlog.Err(err))
+	}
+}
+
+func deleteWebAuthnCredential(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireUserId()
+	if c.Err != nil {
+		return
+	}
+
+	credentialID := mux.Vars(r)["credential_id"]
+	if !model.IsValidId(credentialID) {
+		c.SetInvalidURLParam("credential_id")
+		return
+	}
+
+	auditRec := c.MakeAuditRecord(model.AuditEventDeleteWebAuthnCredential, model.AuditStatusFail)
+	defer c.LogAuditRec(auditRec)
+	model.AddEventParameterToAuditRec(auditRec, "credential_id", credentialID)
+
+	if !requireMfaFactorsPermission(c, false) {
+		return
+	}
+
+	if appErr := c.App.DeleteWebAuthnCredential(c.AppContext, c.Params.UserId, credentialID); appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	auditRec.Success()
+	c.LogAudit("success - webauthn credential revoked")
+
+	ReturnStatusOK(w)
+}
+
+func generateMfaRecoveryCodes(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireUserId()
+	if c.Err != nil {
+		return
+	}
+
+	auditRec := c.MakeAuditRecord(model.AuditEventGenerateMfaRecoveryCodes, model.AuditStatusFail)
+	defer c.LogAuditRec(auditRec)
+
+	if !requireMfaFactorsPermission(c, true) {
+		return
+	}
+
+	codes, appErr := c.App.GenerateMfaRecoveryCodes(c.Params.UserId)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	auditRec.Success()
+	c.LogAudit("success - mfa recovery codes generated")
+
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Expires", "0")
+	if err := json.NewEncoder(w).Encode(codes); err != nil {
+		c.Logger.Warn("Error while writing response", mlog.Err(err))
+	}
+}
+
 func updatePassword(c *Context, w http.ResponseWriter, r *http.Request) {
 	c.RequireUserId()
 	if c.Err != nil {
@@ -1939,66 +2152,71 @@ func sendPasswordReset(c *Context, w http.ResponseWriter, r *http.Request) {
 	ReturnStatusOK(w)
 }
 
-func login(c *Context, w http.ResponseWriter, r *http.Request) {
-	// Mask all sensitive errors, with the exception of the following
-	defer func() {
-		if c.Err == nil {
-			return
-		}
+// maskLoginError masks all sensitive login errors, with the exception of the ones telling the
+// user what to do next.
+func maskLoginError(c *Context) {
+	if c.Err == nil {
+		return
+	}
 
-		unmaskedErrors := []string{
-			"mfa.validate_token.authenticate.app_error",
-			"api.user.check_user_mfa.bad_code.app_error",
-			"api.user.login.blank_pwd.app_error",
-			"api.user.login.bot_login_forbidden.app_error",
-			"api.user.login.remote_users.login.error",
-			"api.user.login.client_side_cert.certificate.app_error",
-			"api.user.login.inactive.app_error",
-			"api.user.login.not_verified.app_error",
-			"api.user.check_user_login_attempts.too_many.app_error",
-			"app.team.join_user_to_team.max_accounts.app_error",
-			"store.sql_user.save.max_accounts.app_error",
-			"api.user.check_user_login_attempts.too_many_ldap.app_error",
-		}
+	unmaskedErrors := []string{
+		"mfa.validate_token.authenticate.app_error",
+		"api.user.check_user_mfa.bad_code.app_error",
+		"api.user.login.blank_pwd.app_error",
+		"api.user.login.bot_login_forbidden.app_error",
+		"api.user.login.remote_users.login.error",
+		"api.user.login.client_side_cert.certificate.app_error",
+		"api.user.login.inactive.app_error",
+		"api.user.login.not_verified.app_error",
+		"api.user.check_user_login_attempts.too_many.app_error",
+		"app.team.join_user_to_team.max_accounts.app_error",
+		"store.sql_user.save.max_accounts.app_error",
+		"api.user.check_user_login_attempts.too_many_ldap.app_error",
+		"app.mfa_factor.webauthn.not_registered.app_error",
+		"app.mfa_factor.webauthn.site_url.app_error",
+	}
 
-		maskError := true
+	maskError := true
 
-		for _, unmaskedError := range unmaskedErrors {
-			if c.Err.Id == unmaskedError {
-				maskError = false
-			}
+	for _, unmaskedError := range unmaskedErrors {
+		if c.Err.Id == unmaskedError {
+			maskError = false
 		}
+	}
 
-		if !maskError {
-			return
-		}
+	if !maskError {
+		return
+	}
 
-		config := c.App.Config()
-		enableUsername := *config.EmailSettings.EnableSignInWithUsername
-		enableEmail := *config.EmailSettings.EnableSignInWithEmail
-		samlEnabled := *config.SamlSettings.Enable
-		gitlabEnabled := *config.GitLabSettings.Enable
-		openidEnabled := *config.OpenIdSettings.Enable
-		googleEnabled := *config.GoogleSettings.Enable
-		office365Enabled := *config.Office365Settings.Enable
+	config := c.App.Config()
+	enableUsername := *config.EmailSettings.EnableSignInWithUsername
+	enableEmail := *config.EmailSettings.EnableSignInWithEmail
+	samlEnabled := *config.SamlSettings.Enable
+	gitlabEnabled := *config.GitLabSettings.Enable
+	openidEnabled := *config.OpenIdSettings.Enable
+	googleEnabled := *config.GoogleSettings.Enable
+	office365Enabled := *config.Office365Settings.Enable
 
-		if samlEnabled || gitlabEnabled || googleEnabled || office365Enabled || openidEnabled {
-			c.Err = model.NewAppError("login", "api.user.login.invalid_credentials_sso", nil, "", http.StatusUnauthorized)
-			return
-		}
+	if samlEnabled || gitlabEnabled || googleEnabled || office365Enabled || openidEnabled {
+		c.Err = model.NewAppError("login", "api.user.login.invalid_credentials_sso", nil, "", http.StatusUnauthorized)
+		return
+	}
 
-		if enableUsername && !enableEmail {
-			c.Err = model.NewAppError("login", "api.user.login.invalid_credentials_username", nil, "", http.StatusUnauthorized)
-			return
-		}
+	if enableUsername && !enableEmail {
+		c.Err = model.NewAppError("login", "api.user.login.invalid_credentials_username", nil, "", http.StatusUnauthorized)
+		return
+	}
 
-		if !enableUsername && enableEmail {
-			c.Err = model.NewAppError("login", "api.user.login.invalid_credentials_email", nil, "", http.StatusUnauthorized)
-			return
-		}
+	if !enableUsername && enableEmail {
+		c.Err = model.NewAppError("login", "api.user.login.invalid_credentials_email", nil, "", http.StatusUnauthorized)
+		return
+	}
 
-		c.Err = model.NewAppError("login", "api.user.login.invalid_credentials_email_username", nil, "", http.StatusUnauthorized)
-	}()
+	c.Err = model.NewAppError("login", "api.user.login.invalid_credentials_email_username", nil, "", http.StatusUnauthorized)
+}
+
+func login(c *Context, w http.ResponseWriter, r *http.Request) {
+	defer maskLoginError(c)
 
 	props := model.MapFromJSON(r.Body)
 	id := props["id"]
@@ -2074,6 +2292,29 @@ func login(c *Context, w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// loginWebAuthnChallenge checks the credentials of a user with MFA active and returns the
+// challenge to sign with one of their WebAuthn credentials. The signed challenge is then sent
+// as the MFA token to the login endpoint.
+func loginWebAuthnChallenge(c *Context, w http.ResponseWriter, r *http.Request) {
+	defer maskLoginError(c)
+
+	props := model.MapFromJSON(r.Body)
+	id := props["id"]
+	loginId := props["login_id"]
+	password := props["password"]
+
+	ceremony, appErr := c.App.BeginWebAuthnLogin(c.AppContext, id, loginId, password)
+	if appErr != nil {
+		c.Err = appErr
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := json.NewEncoder(w).Encode(ceremony); err != nil {
+		c.Logger.Warn("Error while writing response", mlog.Err(err))
+	}
+}
+
 func loginWithDesktopToken(c *Context, w http.ResponseWriter, r *http.Request) {
 	props := model.MapFromJSON(r.Body)
 	token := props["token"]
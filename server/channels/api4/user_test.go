@@ -3624,6 +3624,127 @@ func TestGenerateMfaSecret(t *testing.T) {
 	CheckUnauthorizedStatus(t, resp)
 }
 
+func TestUserMfaFactors(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	th.App.Srv().SetLicense(model.NewTestLicense("mfa"))
+	th.App.UpdateConfig(func(cfg *model.Config) {
+		*cfg.ServiceSettings.EnableMultifactorAuthentication = true
+		*cfg.ServiceSettings.SiteURL = "http://localhost:8065"
+	})
+
+	credential, err := th.App.Srv().Store().MfaFactor().SaveWebAuthnCredential(&model.WebAuthnCredential{
+		UserId:       th.BasicUser.Id,
+		Name:         "Security key",
+		CredentialId: model.NewId(),
+		Credential:   `{"id":"` + model.NewId() + `"}`,
+	})
+	require.NoError(t, err)
+	require.NoError(t, th.App.Srv().Store().User().UpdateMfaActive(th.BasicUser.Id, true))
+	th.App.InvalidateCacheForUser(th.BasicUser.Id)
+
+	t.Run("get factors", func(t *testing.T) {
+		factors, _, err := th.Client.GetUserMfaFactors(context.Background(), th.BasicUser.Id)
+		require.NoError(t, err)
+		assert.True(t, factors.MfaActive)
+		require.Len(t, factors.WebAuthnCredentials, 1)
+		assert.Equal(t, credential.Id, factors.WebAuthnCredentials[0].Id)
+
+		_, resp, err := th.Client.GetUserMfaFactors(context.Background(), th.BasicUser2.Id)
+		require.Error(t, err)
+		CheckForbiddenStatus(t, resp)
+
+		_, _, err = th.SystemAdminClient.GetUserMfaFactors(context.Background(), th.BasicUser.Id)
+		require.NoError(t, err)
+	})
+
+	t.Run("begin registration", func(t *testing.T) {
+		ceremony, _, err := th.Client.BeginWebAuthnRegistration(context.Background(), th.BasicUser.Id)
+		require.NoError(t, err)
+		require.NotEmpty(t, ceremony.SessionId)
+
+		_, resp, err := th.SystemAdminClient.BeginWebAuthnRegistration(context.Background(), th.BasicUser.Id)
+		require.Error(t, err)
+		CheckForbiddenStatus(t, resp)
+	})
+
+	t.Run("finish registration with an invalid response", func(t *testing.T) {
+		ceremony, _, err := th.Client.BeginWebAuthnRegistration(context.Background(), th.BasicUser.Id)
+		require.NoError(t, err)
+
+		_, resp, err := th.Client.FinishWebAuthnRegistration(context.Background(), th.BasicUser.Id, &model.WebAuthnRegistration{
+			SessionId:  ceremony.SessionId,
+			Name:       "Security key",
+			Credential: json.RawMessage(`{}`),
+		})
+		require.Error(t, err)
+		CheckBadRequestStatus(t, resp)
+	})
+
+	t.Run("generate recovery codes", func(t *testing.T) {
+		codes, _, err := th.Client.GenerateMfaRecoveryCodes(context.Background(), th.BasicUser.Id)
+		require.NoError(t, err)
+		assert.Len(t, codes.Codes, model.MfaRecoveryCodeCount)
+
+		_, resp, err := th.SystemAdminClient.GenerateMfaRecoveryCodes(context.Background(), th.BasicUser.Id)
+		require.Error(t, err)
+		CheckForbiddenStatus(t, resp)
+	})
+
+	t.Run("login with a recovery code", func(t *testing.T) {
+		codes, _, err := th.Client.GenerateMfaRecoveryCodes(context.Background(), th.BasicUser.Id)
+		require.NoError(t, err)
+
+		client := th.CreateClient()
+		_, _, err = client.LoginWithMFA(context.Background(), th.BasicUser.Email, th.BasicUser.Password, codes.Codes[0])
+		require.NoError(t, err)
+
+		_, resp, err := client.LoginWithMFA(context.Background(), th.BasicUser.Email, th.BasicUser.Password, codes.Codes[0])
+		require.Error(t, err)
+		CheckUnauthorizedStatus(t, resp)
+	})
+
+	t.Run("get a login challenge", func(t *testing.T) {
+		client := th.CreateClient()
+		ceremony, _, err := client.GetWebAuthnLoginChallenge(context.Background(), th.BasicUser.Email, th.BasicUser.Password)
+		require.NoError(t, err)
+		require.NotEmpty(t, ceremony.SessionId)
+
+		_, resp, err := client.GetWebAuthnLoginChallenge(context.Background(), th.BasicUser.Email, "wrong password")
+		require.Error(t, err)
+		CheckUnauthorizedStatus(t, resp)
+	})
+
+	t.Run("delete credential", func(t *testing.T) {
+		resp, err := th.Client.DeleteWebAuthnCredential(context.Background(), th.BasicUser2.Id, credential.Id)
+		require.Error(t, err)
+		CheckForbiddenStatus(t, resp)
+
+		_, err = th.SystemAdminClient.DeleteWebAuthnCredential(context.Background(), th.BasicUser.Id, credential.Id)
+		require.NoError(t, err)
+
+		resp, err = th.SystemAdminClient.DeleteWebAuthnCredential(context.Background(), th.BasicUser.Id, credential.Id)
+		require.Error(t, err)
+		CheckNotFoundStatus(t, resp)
+	})
+
+	t.Run("reset factors", func(t *testing.T) {
+		resp, err := th.Client.ResetUserMfaFactors(context.Background(), th.BasicUser2.Id)
+		require.Error(t, err)
+		CheckForbiddenStatus(t, resp)
+
+		_, err = th.SystemAdminClient.ResetUserMfaFactors(context.Background(), th.BasicUser.Id)
+		require.NoError(t, err)
+
+		factors, _, err := th.SystemAdminClient.GetUserMfaFactors(context.Background(), th.BasicUser.Id)
+		require.NoError(t, err)
+		assert.False(t, factors.MfaActive)
+		assert.Empty(t, factors.WebAuthnCredentials)
+		assert.Zero(t, factors.RecoveryCodesRemaining)
+	})
+}
+
 func TestUpdateUserPassword(t *testing.T) {
 	mainHelper.Parallel(t)
 	th := Setup(t).InitBasic(t)
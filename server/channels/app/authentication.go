@@ -62,6 +62,23 @@ func (a *App) IsPasswordValid(rctx request.CTX, password string) *model.AppError
 	return nil
 }
 
+// passwordHasher returns the hasher of new passwords, as configured in the PasswordSettings. The
+// passwords hashed differently are migrated to it on login.
+func (a *App) passwordHasher() (hashers.PasswordHasher, error) {
+	return passwordHasherFromSettings(&a.Config().PasswordSettings)
+}
+
+func passwordHasherFromSettings(settings *model.PasswordSettings) (hashers.PasswordHasher, error) {
+	switch *settings.HashingAlgorithm {
+	case model.PasswordHashingAlgorithmArgon2id:
+		return hashers.NewArgon2id(*settings.Argon2idMemory, *settings.Argon2idIterations, *settings.Argon2idParallelism)
+	case model.PasswordHashingAlgorithmPBKDF2:
+		return hashers.DefaultPBKDF2(), nil
+	default:
+		return nil, errors.New("unknown password hashing algorithm " + *settings.HashingAlgorithm)
+	}
+}
+
 func (a *App) checkUserPassword(user *model.User, password string, invalidateCache bool) *model.AppError {
 	if user.Password == "" || password == "" {
 		return model.NewAppError("checkUserPassword", "api.user.check_user_password.invalid.app_error", nil, "user_id="+user.Id, http.StatusUnauthorized)
@@ -91,8 +108,12 @@ func (a *App) checkUserPassword(user *model.User, password string, invalidateCac
 		return model.NewAppError("checkUserPassword", "app.valid_password_generic.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
 
-	// Migrate the password if needed
-	if !hashers.IsLatestHasher(hasher) {
+	// Migrate the password if it wasn't hashed with the configured hasher and parameters
+	configured, err := a.passwordHasher()
+	if err != nil {
+		return model.NewAppError("checkUserPassword", "app.user.check_user_password.failed_migration", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	if !configured.IsPHCValid(phc) {
 		return a.migratePassword(user, password)
 	}
 
@@ -100,10 +121,15 @@ func (a *App) checkUserPassword(user *model.User, password string, invalidateCac
 }
 
 // migratePassword updates the database with the user's password hashed with the
-// latest hashing method. It assumes that the password has been already validated.
+// configured hashing method. It assumes that the password has been already validated.
 func (a *App) migratePassword(user *model.User, password string) *model.AppError {
-	// Compute the new hash with the latest hashing method
-	newHash, err := hashers.Hash(password)
+	// Compute the new hash with the configured hashing method
+	hasher, err := a.passwordHasher()
+	if err != nil {
+		return model.NewAppError("migratePassword", "app.user.check_user_password.failed_migration", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	newHash, err := hasher.Hash(password)
 	if err != nil {
 		return model.NewAppError("migratePassword", "app.user.check_user_password.failed_migration", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
@@ -312,9 +338,27 @@ func (a *App) CheckUserMfa(rctx request.CTX, user *model.User, token string) *mo
 		return model.NewAppError("CheckUserMfa", "mfa.mfa_disabled.app_error", nil, "", http.StatusNotImplemented)
 	}
 
-	ok, err := mfa.New(a.Srv().Store().User()).ValidateToken(user, token)
+	if token == "" {
+		return model.NewAppError("CheckUserMfa", mfaTokenRequiredErrorId, nil, "", http.StatusBadRequest)
+	}
+
+	// The token is either a WebAuthn assertion, a recovery code or a TOTP token
+	var ok bool
+	var err error
+	switch {
+	case strings.HasPrefix(token, "{"):
+		ok, err = a.validateWebAuthnAssertion(user, token)
+	case mfa.IsRecoveryCode(token):
+		ok, err = mfa.NewRecoveryCodes(a.Srv().Store().MfaFactor()).Validate(user.Id, token)
+	case user.MfaSecret == "":
+		// Users with only WebAuthn credentials have no TOTP secret, and a code computed with
+		// an empty key must not be accepted.
+		ok = false
+	default:
+		ok, err = mfa.New(a.Srv().Store().User()).ValidateToken(user, token)
+	}
 	if err != nil {
-		return model.NewAppError("CheckUserMfa", "mfa.validate_token.authenticate.app_error", nil, "", http.StatusBadRequest).Wrap(err)
+		return model.NewAppError("CheckUserMfa", mfaTokenRequiredErrorId, nil, "", http.StatusBadRequest).Wrap(err)
 	}
 
 	if !ok {
@@ -372,7 +372,7 @@ func TestCheckUserPassword(t *testing.T) {
 	pwdBcryptBytes, err := bcrypt.GenerateFromPassword([]byte(pwd), 10)
 	require.NoError(t, err)
 	pwdBcrypt := string(pwdBcryptBytes)
-	pwdPBKDF2, err := hashers.Hash(pwd)
+	pwdArgon2id, err := hashers.Hash(pwd)
 	require.NoError(t, err)
 
 	createUserWithHash := func(hash string) *model.User {
@@ -393,19 +393,19 @@ func TestCheckUserPassword(t *testing.T) {
 	}
 
 	t.Run("valid password with current hashing", func(t *testing.T) {
-		user := createUserWithHash(pwdPBKDF2)
+		user := createUserWithHash(pwdArgon2id)
 		err := th.App.checkUserPassword(user, pwd, false)
 		require.Nil(t, err)
 	})
 
 	t.Run("valid password with current hashing and cache invalidation", func(t *testing.T) {
-		user := createUserWithHash(pwdPBKDF2)
+		user := createUserWithHash(pwdArgon2id)
 		err := th.App.checkUserPassword(user, pwd, true)
 		require.Nil(t, err)
 	})
 
 	t.Run("invalid password", func(t *testing.T) {
-		user := createUserWithHash(pwdPBKDF2)
+		user := createUserWithHash(pwdArgon2id)
 
 		err := th.App.checkUserPassword(user, "wrongpassword", false)
 		require.NotNil(t, err)
@@ -419,7 +419,7 @@ func TestCheckUserPassword(t *testing.T) {
 	t.Run("password migration from outdated hash", func(t *testing.T) {
 		user := createUserWithHash(pwdBcrypt)
 		require.Contains(t, user.Password, "$2a$10")
-		require.NotContains(t, user.Password, "pbkdf2")
+		require.NotContains(t, user.Password, "argon2id")
 
 		err := th.App.checkUserPassword(user, pwd, false)
 		require.Nil(t, err)
@@ -427,7 +427,7 @@ func TestCheckUserPassword(t *testing.T) {
 		updatedUser, err := th.App.GetUser(user.Id)
 		require.Nil(t, err)
 		require.NotEqual(t, pwdBcrypt, updatedUser.Password)
-		require.Contains(t, updatedUser.Password, "$pbkdf2")
+		require.Contains(t, updatedUser.Password, "$argon2id")
 
 		// Re-check with updated password
 		err = th.App.checkUserPassword(user, pwd, false)
@@ -447,7 +447,7 @@ func TestCheckUserPassword(t *testing.T) {
 	})
 
 	t.Run("empty password", func(t *testing.T) {
-		user := createUserWithHash(pwdPBKDF2)
+		user := createUserWithHash(pwdArgon2id)
 
 		user, err := th.App.GetUser(user.Id)
 		require.Nil(t, err)
@@ -468,8 +468,8 @@ func TestCheckUserPassword(t *testing.T) {
 		require.Equal(t, "api.user.check_user_password.invalid.app_error", err.Id)
 	})
 
-	t.Run("successful migration from PBKDF2 with old parameter to new parameter", func(t *testing.T) {
-		// Create a PBKDF2 hasher with work factor = 10000 instead of the default 60000
+	t.Run("successful migration from PBKDF2 to Argon2id", func(t *testing.T) {
+		// Create a PBKDF2 hasher with work factor = 10000 instead of the default 600000
 		oldParamPBKDF2, err := hashers.NewPBKDF2(10000, 32)
 		require.NoError(t, err)
 
@@ -486,13 +486,50 @@ func TestCheckUserPassword(t *testing.T) {
 
 		updatedUser, appErr := th.App.GetUser(user.Id)
 		require.Nil(t, appErr)
-		require.NotEqual(t, pwdBcrypt, updatedUser.Password)
+		require.NotEqual(t, pwdOldParamPBKDF2, updatedUser.Password)
+		require.Contains(t, updatedUser.Password, "$argon2id")
+		// The new user hash contains the configured parameters
+		require.Contains(t, updatedUser.Password, "m=19456,t=2,p=1")
+
+		// Re-check with updated password
+		appErr = th.App.checkUserPassword(updatedUser, pwd, false)
+		require.Nil(t, appErr)
+	})
+
+	t.Run("successful migration to the configured hasher", func(t *testing.T) {
+		defer th.App.UpdateConfig(func(cfg *model.Config) {
+			cfg.PasswordSettings.HashingAlgorithm = model.NewPointer(model.PasswordHashingAlgorithmArgon2id)
+			cfg.PasswordSettings.Argon2idIterations = model.NewPointer(model.PasswordArgon2idDefaultIterations)
+		})
+
+		// New Argon2id parameters
+		th.App.UpdateConfig(func(cfg *model.Config) {
+			cfg.PasswordSettings.Argon2idIterations = model.NewPointer(3)
+		})
+
+		user := createUserWithHash(pwdArgon2id)
+
+		appErr := th.App.checkUserPassword(user, pwd, false)
+		require.Nil(t, appErr)
+
+		updatedUser, appErr := th.App.GetUser(user.Id)
+		require.Nil(t, appErr)
+		require.Contains(t, updatedUser.Password, "m=19456,t=3,p=1")
+
+		// New hashing algorithm
+		th.App.UpdateConfig(func(cfg *model.Config) {
+			cfg.PasswordSettings.HashingAlgorithm = model.NewPointer(model.PasswordHashingAlgorithmPBKDF2)
+		})
+
+		appErr = th.App.checkUserPassword(updatedUser, pwd, false)
+		require.Nil(t, appErr)
+
+		updatedUser, appErr = th.App.GetUser(user.Id)
+		require.Nil(t, appErr)
 		require.Contains(t, updatedUser.Password, "$pbkdf2")
-		// The new user hash contains the new parameter
-		require.Contains(t, updatedUser.Password, "w=60000")
 
 		// Re-check with updated password
-		appErr = th.App.checkUserPassword(user, pwd, false)
+		appErr = th.App.checkUserPassword(updatedUser, pwd, false)
 		require.Nil(t, appErr)
 	})
 }
@@ -522,7 +559,7 @@ func TestMigratePassword(t *testing.T) {
 		return updatedUser
 	}
 
-	t.Run("successful migration from BCrypt to PBKDF2", func(t *testing.T) {
+	t.Run("successful migration from BCrypt to Argon2id", func(t *testing.T) {
 		user := createUserWithHash(pwdBcrypt)
 
 		err := th.App.migratePassword(user, pwd)
@@ -531,7 +568,7 @@ func TestMigratePassword(t *testing.T) {
 		updatedUser, err := th.App.GetUser(user.Id)
 		require.Nil(t, err)
 		require.NotEqual(t, pwdBcrypt, updatedUser.Password)
-		require.Contains(t, updatedUser.Password, "$pbkdf2")
+		require.Contains(t, updatedUser.Password, "$argon2id")
 
 		// Re-check with updated password
 		err = th.App.checkUserPassword(user, pwd, false)
@@ -0,0 +1,259 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app/autotranslation"
+)
+
+// maxConcurrentTranslations bounds the number of requests made to the translation provider at once
+const maxConcurrentTranslations = 8
+
+func (a *App) IsAutoTranslationEnabled() bool {
+	cfg := a.Config()
+	return cfg.FeatureFlags.AutoTranslation &&
+		*cfg.AutoTranslationSettings.Enable &&
+		*cfg.AutoTranslationSettings.Provider == model.AutoTranslationProviderLibreTranslate
+}
+
+func (a *App) autoTranslationProvider() autotranslation.Provider {
+	return autotranslation.NewLibreTranslate(a.Config().AutoTranslationSettings.LibreTranslate, a.HTTPService().MakeClient(true))
+}
+
+func (a *App) userLocaleForTranslation(user *model.User) string {
+	if user.Locale != "" {
+		return user.Locale
+	}
+	return *a.Config().LocalizationSettings.DefaultClientLocale
+}
+
+func isPostTranslatable(post *model.Post) bool {
+	return post.DeleteAt == 0 && post.Message != "" && !post.IsSystemMessage()
+}
+
+// translatePost translates the post from the source language into the locale and caches the result
+func (a *App) translatePost(ctx context.Context, provider autotranslation.Provider, post *model.Post, source, locale string) (*model.PostTranslation, error) {
+	// The post may be edited while it is being translated, so the translation is dated from before
+	// the provider is called to be seen as stale in that case
+	translation := &model.PostTranslation{
+		PostId:       post.Id,
+		Locale:       locale,
+		SourceLocale: locale,
+		Message:      post.Message,
+		CreateAt:     model.GetMillis(),
+	}
+
+	if target := autotranslation.LanguageForLocale(locale); source != target {
+		message, err := provider.Translate(ctx, post.Message, source, target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to translate post into %s", locale)
+		}
+		translation.SourceLocale = source
+		translation.Message = message
+	}
+
+	saved, err := a.Srv().Store().PostTranslation().Save(translation)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to save post translation")
+	}
+
+	return saved, nil
+}
+
+// translatePostInto detects the language of the post once and translates it into each locale
+func (a *App) translatePostInto(ctx context.Context, provider autotranslation.Provider, post *model.Post, locales []string) (map[string]*model.PostTranslation, error) {
+	source, err := provider.Detect(ctx, post.Message)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to detect post language")
+	}
+
+	var (
+		mut          sync.Mutex
+		wg           sync.WaitGroup
+		translations = make(map[string]*model.PostTranslation, len(locales))
+		errs         []error
+	)
+	for _, locale := range locales {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			translation, err := a.translatePost(ctx, provider, post, source, locale)
+
+			mut.Lock()
+			defer mut.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			translations[locale] = translation
+		}()
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return translations, errs[0]
+	}
+
+	return translations, nil
+}
+
+// getPostTranslations returns the translations of the posts into the locale, translating the posts
+// missing from the cache within the timeout. Posts that couldn't be translated in time are left out.
+func (a *App) getPostTranslations(rctx request.CTX, posts []*model.Post, locale string, timeout time.Duration) map[string]*model.PostTranslation {
+	postIDs := make([]string, 0, len(posts))
+	for _, post := range posts {
+		postIDs = append(postIDs, post.Id)
+	}
+
+	cached, err := a.Srv().Store().PostTranslation().GetForPosts(postIDs, locale)
+	if err != nil {
+		rctx.Logger().Warn("Failed to get cached post translations", mlog.String("locale", locale), mlog.Err(err))
+		return nil
+	}
+
+	translations := make(map[string]*model.PostTranslation, len(posts))
+	for _, translation := range cached {
+		translations[translation.PostId] = translation
+	}
+
+	var missing []*model.Post
+	for _, post := range posts {
+		if translation, ok := translations[post.Id]; !ok || translation.IsStaleFor(post) {
+			delete(translations, post.Id)
+			missing = append(missing, post)
+		}
+	}
+	if len(missing) == 0 {
+		return translations
+	}
+
+	ctx, cancel := context.WithTimeout(rctx.Context(), timeout)
+	defer cancel()
+
+	provider := a.autoTranslationProvider()
+	var (
+		mut sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, maxConcurrentTranslations)
+	)
+	for _, post := range missing {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			translated, err := a.translatePostInto(ctx, provider, post, []string{locale})
+			if err != nil {
+				rctx.Logger().Debug("Failed to translate post", mlog.String("post_id", post.Id), mlog.String("locale", locale), mlog.Err(err))
+				return
+			}
+
+			mut.Lock()
+			defer mut.Unlock()
+			translations[post.Id] = translated[locale]
+		}()
+	}
+	wg.Wait()
+
+	return translations
+}
+
+// AddPostTranslationsForUser attaches to the metadata of the posts their translation into the
+// locale of the user, for the posts written in another language by someone else.
+func (a *App) AddPostTranslationsForUser(rctx request.CTX, posts []*model.Post, userID string) {
+	if !a.IsAutoTranslationEnabled() || userID == "" {
+		return
+	}
+
+	user, appErr := a.GetUser(userID)
+	if appErr != nil {
+		rctx.Logger().Warn("Failed to get user to translate posts", mlog.String("user_id", userID), mlog.Err(appErr))
+		return
+	}
+
+	translatable := make([]*model.Post, 0, len(posts))
+	for _, post := range posts {
+		if post.UserId != userID && isPostTranslatable(post) {
+			translatable = append(translatable, post)
+		}
+	}
+	if len(translatable) == 0 {
+		return
+	}
+
+	timeout := time.Duration(*a.Config().AutoTranslationSettings.TimeoutsMs.Fetch) * time.Millisecond
+	translations := a.getPostTranslations(rctx, translatable, a.userLocaleForTranslation(user), timeout)
+	for _, post := range translatable {
+		translation, ok := translations[post.Id]
+		if !ok || !translation.IsTranslated() {
+			continue
+		}
+
+		if post.Metadata == nil {
+			post.Metadata = &model.PostMetadata{}
+		}
+		post.Metadata.Translation = translation
+	}
+}
+
+// translateNewPost translates a new post into the locales of the users about to be notified so
+// that the notifications can use the cached translations.
+func (a *App) translateNewPost(rctx request.CTX, post *model.Post, profileMap map[string]*model.User) {
+	if !a.IsAutoTranslationEnabled() || !isPostTranslatable(post) {
+		return
+	}
+
+	seen := make(map[string]bool)
+	var locales []string
+	for _, profile := range profileMap {
+		if profile.Id == post.UserId {
+			continue
+		}
+		if locale := a.userLocaleForTranslation(profile); !seen[locale] {
+			seen[locale] = true
+			locales = append(locales, locale)
+		}
+	}
+	if len(locales) == 0 {
+		return
+	}
+
+	timeout := time.Duration(*a.Config().AutoTranslationSettings.TimeoutsMs.NewPost) * time.Millisecond
+	ctx, cancel := context.WithTimeout(rctx.Context(), timeout)
+	defer cancel()
+
+	if _, err := a.translatePostInto(ctx, a.autoTranslationProvider(), post, locales); err != nil {
+		rctx.Logger().Debug("Failed to translate new post", mlog.String("post_id", post.Id), mlog.Err(err))
+	}
+}
+
+// postMessageForNotification returns the message of the post translated into the locale of the
+// user receiving a notification for it, or the original message if it can't be translated in time.
+func (a *App) postMessageForNotification(rctx request.CTX, post *model.Post, user *model.User) string {
+	if !a.IsAutoTranslationEnabled() || post.UserId == user.Id || !isPostTranslatable(post) {
+		return post.Message
+	}
+
+	// Notifications are sent after the request creating the post has completed
+	rctx = rctx.WithContext(context.Background())
+	timeout := time.Duration(*a.Config().AutoTranslationSettings.TimeoutsMs.Notification) * time.Millisecond
+	translations := a.getPostTranslations(rctx, []*model.Post{post}, a.userLocaleForTranslation(user), timeout)
+	if translation, ok := translations[post.Id]; ok {
+		return translation.Message
+	}
+
+	return post.Message
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// newFakeLibreTranslate serves /detect and /translate for a few English and Chinese phrases and
+// counts the translations it made
+func newFakeLibreTranslate(t *testing.T) (*httptest.Server, *atomic.Int32) {
+	languages := map[string]string{"hello": "en", "goodbye": "en", "你好": "zh"}
+	dictionary := map[string]string{"hello": "你好", "goodbye": "再见", "你好": "hello"}
+	var translations atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Q      string `json:"q"`
+			Source string `json:"source"`
+			Target string `json:"target"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		switch r.URL.Path {
+		case "/detect":
+			_ = json.NewEncoder(w).Encode([]map[string]any{{"language": languages[req.Q], "confidence": 90}})
+		case "/translate":
+			translations.Add(1)
+			_ = json.NewEncoder(w).Encode(map[string]string{"translatedText": dictionary[req.Q]})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &translations
+}
+
+func setupAutoTranslation(t *testing.T) (*TestHelper, *atomic.Int32) {
+	server, translations := newFakeLibreTranslate(t)
+	th := SetupConfig(t, func(cfg *model.Config) {
+		cfg.FeatureFlags.AutoTranslation = true
+		cfg.AutoTranslationSettings.Enable = model.NewPointer(true)
+		cfg.AutoTranslationSettings.Provider = model.NewPointer(model.AutoTranslationProviderLibreTranslate)
+		cfg.AutoTranslationSettings.LibreTranslate.URL = model.NewPointer(server.URL)
+	}).InitBasic(t)
+
+	th.BasicUser2.Locale = "zh-CN"
+	user, appErr := th.App.UpdateUser(th.Context, th.BasicUser2, false)
+	require.Nil(t, appErr)
+	th.BasicUser2 = user
+
+	return th, translations
+}
+
+func TestAddPostTranslationsForUser(t *testing.T) {
+	mainHelper.Parallel(t)
+	th, translations := setupAutoTranslation(t)
+
+	post := th.CreateMessagePost(t, th.BasicChannel, "hello")
+	translationsAfterPost := translations.Load()
+
+	t.Run("translates into the locale of the user", func(t *testing.T) {
+		posts := []*model.Post{post.Clone()}
+		th.App.AddPostTranslationsForUser(th.Context, posts, th.BasicUser2.Id)
+		require.NotNil(t, posts[0].Metadata.Translation)
+		assert.Equal(t, "你好", posts[0].Metadata.Translation.Message)
+		assert.Equal(t, "en", posts[0].Metadata.Translation.SourceLocale)
+		// The translation was made when the post was created
+		assert.Equal(t, translationsAfterPost, translations.Load())
+	})
+
+	t.Run("leaves posts in the locale of the user untouched", func(t *testing.T) {
+		posts := []*model.Post{post.Clone()}
+		th.App.AddPostTranslationsForUser(th.Context, posts, th.BasicUser.Id)
+		assert.Nil(t, posts[0].Metadata.Translation)
+	})
+
+	t.Run("translates again after an edit", func(t *testing.T) {
+		edited := post.Clone()
+		edited.Message = "goodbye"
+		edited, appErr := th.App.UpdatePost(th.Context, edited, nil)
+		require.Nil(t, appErr)
+
+		_, err := th.App.Srv().Store().PostTranslation().Get(post.Id, th.BasicUser2.Locale)
+		require.Error(t, err)
+
+		posts := []*model.Post{edited}
+		th.App.AddPostTranslationsForUser(th.Context, posts, th.BasicUser2.Id)
+		require.NotNil(t, posts[0].Metadata.Translation)
+		assert.Equal(t, "再见", posts[0].Metadata.Translation.Message)
+		assert.Equal(t, translationsAfterPost+1, translations.Load())
+	})
+}
+
+func TestPostMessageForNotification(t *testing.T) {
+	mainHelper.Parallel(t)
+	th, _ := setupAutoTranslation(t)
+
+	post := th.CreateMessagePost(t, th.BasicChannel, "hello")
+
+	assert.Equal(t, "你好", th.App.postMessageForNotification(th.Context, post, th.BasicUser2))
+	assert.Equal(t, "hello", th.App.postMessageForNotification(th.Context, post, th.BasicUser))
+
+	th.App.UpdateConfig(func(cfg *model.Config) {
+		cfg.FeatureFlags.AutoTranslation = false
+	})
+	assert.Equal(t, "hello", th.App.postMessageForNotification(th.Context, post, th.BasicUser2))
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package autotranslation
+
+import (
+	"context"
+	"strings"
+)
+
+// Provider detects the language of a text and translates it between languages. Languages are
+// identified by the codes returned from LanguageForLocale.
+type Provider interface {
+	Detect(ctx context.Context, text string) (string, error)
+	Translate(ctx context.Context, text, source, target string) (string, error)
+}
+
+// LanguageForLocale returns the language code of a Mattermost locale, such as "zh" for "zh-CN"
+func LanguageForLocale(locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+
+	switch locale {
+	case "zh-cn", "zh-hans":
+		return "zh"
+	case "zh-tw", "zh-hant":
+		return "zt"
+	}
+
+	language, _, _ := strings.Cut(locale, "-")
+	return language
+}
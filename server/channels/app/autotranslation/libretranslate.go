@@ -0,0 +1,117 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package autotranslation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// LibreTranslate is a Provider backed by the LibreTranslate HTTP API
+type LibreTranslate struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+func NewLibreTranslate(settings *model.LibreTranslateProviderSettings, client *http.Client) *LibreTranslate {
+	return &LibreTranslate{
+		url:    strings.TrimRight(model.SafeDereference(settings.URL), "/"),
+		apiKey: model.SafeDereference(settings.APIKey),
+		client: client,
+	}
+}
+
+type libreTranslateRequest struct {
+	Q      string `json:"q"`
+	Source string `json:"source,omitempty"`
+	Target string `json:"target,omitempty"`
+	Format string `json:"format,omitempty"`
+	APIKey string `json:"api_key,omitempty"`
+}
+
+type libreTranslateDetection struct {
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+}
+
+type libreTranslateTranslation struct {
+	TranslatedText string `json:"translatedText"`
+}
+
+type libreTranslateError struct {
+	Error string `json:"error"`
+}
+
+func (l *LibreTranslate) Detect(ctx context.Context, text string) (string, error) {
+	var detections []libreTranslateDetection
+	if err := l.do(ctx, "/detect", libreTranslateRequest{Q: text, APIKey: l.apiKey}, &detections); err != nil {
+		return "", err
+	}
+
+	if len(detections) == 0 {
+		return "", errors.New("libretranslate detected no language")
+	}
+
+	// Detections are sorted by decreasing confidence
+	return detections[0].Language, nil
+}
+
+func (l *LibreTranslate) Translate(ctx context.Context, text, source, target string) (string, error) {
+	var translation libreTranslateTranslation
+	request := libreTranslateRequest{
+		Q:      text,
+		Source: source,
+		Target: target,
+		Format: "text",
+		APIKey: l.apiKey,
+	}
+	if err := l.do(ctx, "/translate", request, &translation); err != nil {
+		return "", err
+	}
+
+	return translation.TranslatedText, nil
+}
+
+func (l *LibreTranslate) do(ctx context.Context, path string, body libreTranslateRequest, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal libretranslate request")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.url+path, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "failed to create libretranslate request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to call libretranslate %s", path)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr libreTranslateError
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&apiErr); err == nil && apiErr.Error != "" {
+			return fmt.Errorf("libretranslate %s returned status %d: %s", path, resp.StatusCode, apiErr.Error)
+		}
+		return fmt.Errorf("libretranslate %s returned status %d", path, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return errors.Wrapf(err, "failed to decode libretranslate %s response", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package autotranslation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// newFakeLibreTranslate serves /detect and /translate from a fixed dictionary of English and
+// Chinese phrases
+func newFakeLibreTranslate(t *testing.T, apiKey string) *httptest.Server {
+	t.Helper()
+
+	dictionary := map[string]map[string]string{
+		"hello":   {"zh": "你好"},
+		"你好":      {"en": "hello"},
+		"goodbye": {"zh": "再见"},
+	}
+	languages := map[string]string{
+		"hello":   "en",
+		"goodbye": "en",
+		"你好":      "zh",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req libreTranslateRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		if req.APIKey != apiKey {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(libreTranslateError{Error: "Invalid API key"})
+			return
+		}
+
+		switch r.URL.Path {
+		case "/detect":
+			language, ok := languages[req.Q]
+			if !ok {
+				_ = json.NewEncoder(w).Encode([]libreTranslateDetection{})
+				return
+			}
+			_ = json.NewEncoder(w).Encode([]libreTranslateDetection{{Language: language, Confidence: 90}, {Language: "fr", Confidence: 10}})
+		case "/translate":
+			assert.Equal(t, "text", req.Format)
+			translated, ok := dictionary[req.Q][req.Target]
+			if !ok || languages[req.Q] != req.Source {
+				w.WriteHeader(http.StatusBadRequest)
+				_ = json.NewEncoder(w).Encode(libreTranslateError{Error: "unsupported translation"})
+				return
+			}
+			_ = json.NewEncoder(w).Encode(libreTranslateTranslation{TranslatedText: translated})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestLibreTranslate(t *testing.T) {
+	server := newFakeLibreTranslate(t, "secret")
+	provider := NewLibreTranslate(&model.LibreTranslateProviderSettings{
+		URL:    model.NewPointer(server.URL + "/"),
+		APIKey: model.NewPointer("secret"),
+	}, server.Client())
+
+	t.Run("detect", func(t *testing.T) {
+		language, err := provider.Detect(context.Background(), "你好")
+		require.NoError(t, err)
+		assert.Equal(t, "zh", language)
+
+		_, err = provider.Detect(context.Background(), "???")
+		require.Error(t, err)
+	})
+
+	t.Run("translate", func(t *testing.T) {
+		translated, err := provider.Translate(context.Background(), "你好", "zh", "en")
+		require.NoError(t, err)
+		assert.Equal(t, "hello", translated)
+
+		_, err = provider.Translate(context.Background(), "hello", "en", "fr")
+		require.ErrorContains(t, err, "unsupported translation")
+	})
+
+	t.Run("invalid API key", func(t *testing.T) {
+		provider := NewLibreTranslate(&model.LibreTranslateProviderSettings{
+			URL:    model.NewPointer(server.URL),
+			APIKey: model.NewPointer("wrong"),
+		}, server.Client())
+
+		_, err := provider.Detect(context.Background(), "hello")
+		require.ErrorContains(t, err, "Invalid API key")
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+		}))
+		defer slow.Close()
+		provider := NewLibreTranslate(&model.LibreTranslateProviderSettings{URL: model.NewPointer(slow.URL)}, slow.Client())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := provider.Translate(ctx, "hello", "en", "zh")
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestLanguageForLocale(t *testing.T) {
+	for locale, expected := range map[string]string{
+		"en":    "en",
+		"en-AU": "en",
+		"zh-CN": "zh",
+		"zh_CN": "zh",
+		"zh-TW": "zt",
+		"pt-BR": "pt",
+		"ja":    "ja",
+		"":      "",
+	} {
+		assert.Equal(t, expected, LanguageForLocale(locale), locale)
+	}
+}
@@ -942,6 +942,7 @@ func (a *App) PatchChannel(rctx request.CTX, channel *model.Channel, patch *mode
 	oldChannelDisplayName := channel.DisplayName
 	oldChannelHeader := channel.Header
 	oldChannelPurpose := channel.Purpose
+	oldReadReceiptsDisabled := channel.ReadReceiptsDisabled()
 
 	channel.Patch(patch)
 	a.handleChannelCategoryName(channel)
@@ -970,6 +971,10 @@ func (a *App) PatchChannel(rctx request.CTX, channel *model.Channel, patch *mode
 		}
 	}
 
+	if channel.ReadReceiptsDisabled() && !oldReadReceiptsDisabled {
+		a.removeReadCursorsForChannel(rctx, channel.Id)
+	}
+
 	return channel, nil
 }
 
@@ -2708,6 +2713,9 @@ func (a *App) removeUserFromChannel(rctx request.CTX, userIDToRemove string, rem
 	if err := a.Srv().Store().Thread().DeleteMembershipsForChannel(userIDToRemove, channel.Id); err != nil {
 		return model.NewAppError("removeUserFromChannel", model.NoTranslation, nil, "failed to delete threadmemberships upon leaving channel", http.StatusInternalServerError).Wrap(err)
 	}
+	if err := a.Srv().Store().EmailBatch().DeleteForChannelMember(userIDToRemove, channel.Id); err != nil {
+		return model.NewAppError("removeUserFromChannel", model.NoTranslation, nil, "failed to delete batched email notifications upon leaving channel", http.StatusInternalServerError).Wrap(err)
+	}
 
 	if isGuest {
 		currentMembers, err := a.GetChannelMembersForUser(rctx, channel.TeamId, userIDToRemove)
@@ -3606,9 +3614,9 @@ func (a *App) FillInChannelsProps(rctx request.CTX, channelList model.ChannelLis
 				}
 
 				if len(channelMentionsProp) > 0 {
-					channel.AddProp("channel_mentions", channelMentionsProp)
+					channel.AddProp(model.ChannelPropsChannelMentions, channelMentionsProp)
 				} else if channel.Props != nil {
-					delete(channel.Props, "channel_mentions")
+					delete(channel.Props, model.ChannelPropsChannelMentions)
 				}
 			}
 		}
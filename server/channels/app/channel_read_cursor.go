@@ -6,30 +6,81 @@ package app
 import (
 	"context"
 	"encoding/json"
+	"hash/fnv"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/redis/rueidis"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/shared/mlog"
 	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/platform/services/cache"
+)
+
+// readCursorEventsStream is the Redis Stream consumed by the read index service
+const readCursorEventsStream = "read_cursor_events"
+
+const (
+	readReceiptsCountsCacheSize = 10000
+	readReceiptsCountsCacheTTL  = 30 * time.Second
+
+	// readReceiptsIndexCountsCacheTTL is how long the counts are cached when the read index service
+	// is configured. The service applies the cursor events after they are published, so counts
+	// computed right after an invalidation may not include the latest reads yet.
+	readReceiptsIndexCountsCacheTTL = 2 * time.Second
+
+	// readReceiptsCountsGenerationsSize is the number of invalidation counters shared by the channels
+	readReceiptsCountsGenerationsSize = 1024
+
+	// readReceiptsInvalidationDelay is how long the invalidations of the read receipt counts are
+	// gathered before being sent to the other cluster nodes
+	readReceiptsInvalidationDelay = time.Second
+
+	readStateHiddenMembersCacheSize = 10000
+	readStateHiddenMembersCacheTTL  = time.Minute
+
+	readStateHiddenReadersCacheSize = 10000
+	readStateHiddenReadersCacheTTL  = time.Minute
 )
 
 // AdvanceChannelReadCursor updates the user's read cursor in a channel
 // This is the core method that tracks what messages a user has read
+// A nil cursor means nothing was recorded because of the read receipts privacy settings
 func (a *App) AdvanceChannelReadCursor(rctx request.CTX, userId, channelId string, newSeq int64) (*model.ChannelReadCursor, *model.AppError) {
 	// 1. Permission check - user must have read access to the channel
 	if !a.SessionHasPermissionToChannel(rctx, *rctx.Session(), channelId, model.PermissionReadChannelContent) {
 		return nil, model.NewAppError("AdvanceChannelReadCursor", "api.channel.read_cursor.permission.app_error", nil, "", 403)
 	}
 
-	// 2. Get the old cursor (if exists)
+	// 2. Respect the read receipts privacy settings: nothing is recorded for users who don't share
+	// their read state or in channels where read receipts are turned off
+	channel, appErr := a.GetChannel(rctx, channelId)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if channel.ReadReceiptsDisabled() {
+		return nil, nil
+	}
+	shares, appErr := a.SharesReadState(userId, channel.TeamId)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if !shares {
+		return nil, nil
+	}
+
+	// 3. Get the old cursor (if exists)
 	oldCursor, err := a.Srv().Store().ChannelReadCursor().Get(channelId, userId)
 	prevSeq := int64(0)
 	if err == nil {
 		prevSeq = oldCursor.LastPostSeq
 	}
 
-	// 3. Only update if the new sequence is greater (prevent cursor rollback)
+	// 4. Only update if the new sequence is greater (prevent cursor rollback)
 	if newSeq <= prevSeq {
 		rctx.Logger().Debug("Read cursor not advanced - new seq not greater than previous",
 			mlog.String("user_id", userId),
@@ -40,7 +91,7 @@ func (a *App) AdvanceChannelReadCursor(rctx request.CTX, userId, channelId strin
 		return oldCursor, nil
 	}
 
-	// 4. Create and save the new cursor
+	// 5. Create and save the new cursor
 	cursor := &model.ChannelReadCursor{
 		ChannelId:   channelId,
 		UserId:      userId,
@@ -48,33 +99,31 @@ func (a *App) AdvanceChannelReadCursor(rctx request.CTX, userId, channelId strin
 		UpdatedAt:   model.GetMillis(),
 	}
 
-	if err := a.Srv().Store().ChannelReadCursor().Upsert(cursor); err != nil {
+	// The event is written to the outbox in the same transaction as the cursor
+	event, err := a.Srv().Store().ChannelReadCursor().Upsert(cursor)
+	if err != nil {
 		return nil, model.NewAppError("AdvanceChannelReadCursor", "app.channel.read_cursor.save.app_error", nil, err.Error(), 500)
 	}
 
-	// 5. Publish event for ReadIndexService to consume
-	event := &model.ReadCursorEvent{
-		Type:        "channel_read_advanced",
-		EventId:     model.NewId(),
-		ChannelId:   channelId,
-		UserId:      userId,
-		PrevLastSeq: prevSeq,
-		NewLastSeq:  newSeq,
-		Timestamp:   cursor.UpdatedAt,
+	if event == nil {
+		// A concurrent request already moved the cursor to or past newSeq
+		return a.GetChannelReadCursor(rctx, userId, channelId)
 	}
 
-	if err := a.publishReadCursorEvent(rctx, event); err != nil {
-		rctx.Logger().Error("Failed to publish read cursor event", mlog.Err(err))
-		// Don't fail the request if event publishing fails
+	if a.Metrics() != nil {
+		a.Metrics().IncrementReadCursorAdvanced("channel")
 	}
 
-	// 6. Send WebSocket event to notify other users in the channel
-	a.publishReadCursorWebSocketEvent(rctx, channelId, userId, newSeq)
+	// 6. Publish event for ReadIndexService to consume
+	a.deliverReadCursorEvent(rctx, event)
+
+	// 7. Send WebSocket event to notify other users in the channel
+	a.publishReadCursorWebSocketEvent(rctx, channel, "", userId, newSeq)
 
 	rctx.Logger().Debug("Read cursor advanced",
 		mlog.String("user_id", userId),
 		mlog.String("channel_id", channelId),
-		mlog.Int("prev_seq", int(prevSeq)),
+		mlog.Int("prev_seq", int(event.PrevLastSeq)),
 		mlog.Int("new_seq", int(newSeq)),
 	)
 
@@ -92,6 +141,60 @@ func (a *App) AdvanceChannelReadCursorByPost(rctx request.CTX, userId, postId st
 	return a.AdvanceChannelReadCursor(rctx, userId, post.ChannelId, post.CreateAt)
 }
 
+// AdvanceThreadReadCursor updates the user's read cursor in a thread. It is called when a thread
+// is marked as read in the thread view, which with collapsed reply threads doesn't move the
+// channel cursor. A nil cursor means nothing was recorded because of the read receipts privacy
+// settings or because the cursor was already at or past newSeq.
+func (a *App) AdvanceThreadReadCursor(rctx request.CTX, userId string, rootPost *model.Post, newSeq int64) (*model.ThreadReadCursor, *model.AppError) {
+	channel, appErr := a.GetChannel(rctx, rootPost.ChannelId)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if channel.ReadReceiptsDisabled() {
+		return nil, nil
+	}
+	shares, appErr := a.SharesReadState(userId, channel.TeamId)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if !shares {
+		return nil, nil
+	}
+
+	cursor := &model.ThreadReadCursor{
+		RootId:      rootPost.Id,
+		ChannelId:   channel.Id,
+		UserId:      userId,
+		LastPostSeq: newSeq,
+		UpdatedAt:   model.GetMillis(),
+	}
+
+	event, err := a.Srv().Store().ChannelReadCursor().UpsertThread(cursor)
+	if err != nil {
+		return nil, model.NewAppError("AdvanceThreadReadCursor", "app.channel.read_cursor.save.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	if event == nil {
+		return nil, nil
+	}
+
+	if a.Metrics() != nil {
+		a.Metrics().IncrementReadCursorAdvanced("thread")
+	}
+
+	a.deliverReadCursorEvent(rctx, event)
+	a.publishReadCursorWebSocketEvent(rctx, channel, rootPost.Id, userId, newSeq)
+
+	rctx.Logger().Debug("Thread read cursor advanced",
+		mlog.String("user_id", userId),
+		mlog.String("root_id", rootPost.Id),
+		mlog.Int("prev_seq", int(event.PrevLastSeq)),
+		mlog.Int("new_seq", int(newSeq)),
+	)
+
+	return cursor, nil
+}
+
 // GetChannelReadCursor retrieves the read cursor for a user in a channel
 func (a *App) GetChannelReadCursor(rctx request.CTX, userId, channelId string) (*model.ChannelReadCursor, *model.AppError) {
 	cursor, err := a.Srv().Store().ChannelReadCursor().Get(channelId, userId)
@@ -110,72 +213,176 @@ func (a *App) GetChannelReadCursorsForUser(rctx request.CTX, userId string) ([]*
 	return cursors, nil
 }
 
-// GetChannelReadCursors returns all read cursors for a channel
-func (a *App) GetChannelReadCursors(rctx request.CTX, channelId string) ([]*model.ChannelReadCursor, *model.AppError) {
-	cursors, err := a.Srv().Store().ChannelReadCursor().GetForChannel(channelId)
+// GetChannelReadCursors returns the read cursors for a channel of users who share their read state
+func (a *App) GetChannelReadCursors(rctx request.CTX, channel *model.Channel) ([]*model.ChannelReadCursor, *model.AppError) {
+	cursors, err := a.Srv().Store().ChannelReadCursor().GetForChannel(channel.Id)
 	if err != nil {
 		return nil, model.NewAppError("GetChannelReadCursors", "app.channel.read_cursor.get_for_channel.app_error", nil, err.Error(), 500)
 	}
-	return cursors, nil
+
+	hidden, appErr := a.hiddenReadStateReaders(channel)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return filterHiddenReaders(cursors, hidden), nil
 }
 
-// publishReadCursorEvent publishes the read cursor event to Redis Stream for ReadIndexService
-func (a *App) publishReadCursorEvent(rctx request.CTX, event *model.ReadCursorEvent) error {
-	// Get Redis client from platform
-	redisClientInterface := a.Srv().Platform().GetRedisClient()
-	if redisClientInterface == nil {
-		// Redis not configured, just log
-		rctx.Logger().Debug("Redis not configured, skipping event publish",
-			mlog.String("event_id", event.EventId),
-		)
-		return nil
+// GetPostReadReceipts returns up to limit read cursors of users other than the author who have
+// read the post, along with the total number of such users. Readers who don't share their read
+// state are left out of both.
+func (a *App) GetPostReadReceipts(rctx request.CTX, channel *model.Channel, post *model.Post, limit int) ([]*model.ChannelReadCursor, int64, *model.AppError) {
+	query := ReadReceiptsQuery{Seq: post.CreateAt, RootId: post.RootId, ExcludeUserId: post.UserId}
+
+	hidden, appErr := a.hiddenReadStateReaders(channel)
+	if appErr != nil {
+		return nil, 0, appErr
 	}
 
-	// Type assert to rueidis.Client
-	redisClient, ok := redisClientInterface.(rueidis.Client)
-	if !ok {
-		rctx.Logger().Error("Redis client type assertion failed",
-			mlog.String("event_id", event.EventId),
-		)
-		return nil
+	// Fetch extra readers to make up for the hidden ones, at most a page more: a page may come
+	// back short when more of its readers are hidden
+	cursors, count, err := a.Srv().readReceiptsProvider.GetReaders(rctx.Context(), post.ChannelId, query, limit+min(len(hidden), limit))
+	if err != nil {
+		return nil, 0, model.NewAppError("GetPostReadReceipts", "app.channel.read_cursor.get_read_receipts.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
 
-	// Serialize event to JSON
+	hiddenCount, err := newHiddenReadsCounter(a.Srv().Store().ChannelReadCursor(), channel.Id, hidden).count(query)
+	if err != nil {
+		return nil, 0, model.NewAppError("GetPostReadReceipts", "app.channel.read_cursor.get_read_receipts.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	cursors = filterHiddenReaders(cursors, hidden)
+	if len(cursors) > limit {
+		cursors = cursors[:limit]
+	}
+
+	return cursors, max(count-hiddenCount, 0), nil
+}
+
+// GetPostReadReceiptsCount returns the number of users other than the author who have read the post
+func (a *App) GetPostReadReceiptsCount(rctx request.CTX, post *model.Post) (int64, *model.AppError) {
+	counts, appErr := a.GetPostsReadReceiptsCounts(rctx, []*model.Post{post})
+	if appErr != nil {
+		return 0, appErr
+	}
+
+	return counts[post.Id], nil
+}
+
+// GetPostsForReadReceipts loads the posts needed to answer read receipt lookups in a single
+// query. Only the fields used by read receipts are populated; deleted posts are left out.
+func (a *App) GetPostsForReadReceipts(postIds []string) ([]*model.Post, *model.AppError) {
+	posts, err := a.Srv().Store().Post().GetPostsForReadReceipts(postIds)
+	if err != nil {
+		return nil, model.NewAppError("GetPostsForReadReceipts", "app.post.get.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	return posts, nil
+}
+
+// GetPostsReadReceiptsCounts returns read counts keyed by post id, issuing one lookup per channel.
+// Counts are cached per channel until a read cursor in the channel moves.
+func (a *App) GetPostsReadReceiptsCounts(rctx request.CTX, posts []*model.Post) (map[string]int64, *model.AppError) {
+	postsByChannel := make(map[string][]*model.Post)
+	for _, post := range posts {
+		postsByChannel[post.ChannelId] = append(postsByChannel[post.ChannelId], post)
+	}
+
+	channelIds := make([]string, 0, len(postsByChannel))
+	for channelId := range postsByChannel {
+		channelIds = append(channelIds, channelId)
+	}
+
+	// The generations are read first, so that counts computed across an invalidation aren't cached
+	generations := make([]uint64, len(channelIds))
+	for i, channelId := range channelIds {
+		generations[i] = a.Srv().readReceiptsCountsGenerations.get(channelId)
+	}
+
+	cached := make([]map[string]int64, len(channelIds))
+	values := make([]any, len(channelIds))
+	for i := range cached {
+		values[i] = &cached[i]
+	}
+	cacheErrs := a.Srv().readReceiptsCountsCache.GetMulti(channelIds, values)
+
+	results := make(map[string]int64, len(posts))
+	for i, channelId := range channelIds {
+		channelCounts := cached[i]
+		if cacheErrs[i] != nil {
+			if !errors.Is(cacheErrs[i], cache.ErrKeyNotFound) {
+				rctx.Logger().Warn("Failed to get read receipts counts from the cache", mlog.String("channel_id", channelId), mlog.Err(cacheErrs[i]))
+			}
+			channelCounts = make(map[string]int64)
+		}
+
+		var misses []*model.Post
+		for _, post := range postsByChannel[channelId] {
+			if count, ok := channelCounts[post.Id]; ok {
+				results[post.Id] = count
+			} else {
+				misses = append(misses, post)
+			}
+		}
+
+		if len(misses) == 0 {
+			continue
+		}
+
+		queries := make([]ReadReceiptsQuery, len(misses))
+		for i, post := range misses {
+			queries[i] = ReadReceiptsQuery{Seq: post.CreateAt, RootId: post.RootId, ExcludeUserId: post.UserId}
+		}
+
+		counts, err := a.Srv().readReceiptsProvider.GetReadCounts(rctx.Context(), channelId, queries)
+		if err != nil {
+			return nil, model.NewAppError("GetPostsReadReceiptsCounts", "app.channel.read_cursor.get_read_receipts_counts.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+
+		// Take off the readers who don't share their read state, as the lists do
+		channel, appErr := a.GetChannel(rctx, channelId)
+		if appErr != nil {
+			return nil, appErr
+		}
+		hidden, appErr := a.hiddenReadStateReaders(channel)
+		if appErr != nil {
+			return nil, appErr
+		}
+		hiddenReads := newHiddenReadsCounter(a.Srv().Store().ChannelReadCursor(), channelId, hidden)
+
+		for i, post := range misses {
+			hiddenCount, err := hiddenReads.count(queries[i])
+			if err != nil {
+				return nil, model.NewAppError("GetPostsReadReceiptsCounts", "app.channel.read_cursor.get_read_receipts_counts.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+			}
+			count := max(counts[i]-hiddenCount, 0)
+			results[post.Id] = count
+			channelCounts[post.Id] = count
+		}
+
+		a.cacheReadReceiptsCounts(rctx, channelId, channelCounts, generations[i])
+	}
+
+	return results, nil
+}
+
+// publishReadCursorEvent publishes the read cursor event to Redis Stream for ReadIndexService
+func (a *App) publishReadCursorEvent(rctx request.CTX, event *model.ReadCursorEvent) error {
 	eventData, err := json.Marshal(event)
 	if err != nil {
-		rctx.Logger().Error("Failed to marshal read cursor event",
-			mlog.String("event_id", event.EventId),
-			mlog.Err(err),
-		)
 		return err
 	}
 
-	// Publish to Redis Stream using rueidis
-	streamName := "read_cursor_events"
-	ctx := context.Background()
-	
-	// Use XADD command to add to stream
-	cmd := redisClient.B().Xadd().
-		Key(streamName).
-		Id("*").  // Auto-generate ID
-		FieldValue().
-		FieldValue("data", string(eventData)).
-		Build()
-	
-	err = redisClient.Do(ctx, cmd).Error()
-	if err != nil {
-		rctx.Logger().Error("Failed to publish read cursor event to Redis Stream",
-			mlog.String("stream", streamName),
-			mlog.String("event_id", event.EventId),
-			mlog.Err(err),
-		)
+	if err := a.PublishReadCursorEventPayload(rctx.Context(), string(eventData)); err != nil {
+		if a.Metrics() != nil {
+			a.Metrics().IncrementReadCursorPublishFailures()
+		}
 		return err
 	}
 
-	rctx.Logger().Info("Published read cursor event to Redis Stream",
-		mlog.String("stream", streamName),
+	rctx.Logger().Debug("Published read cursor event to Redis Stream",
 		mlog.String("event_id", event.EventId),
 		mlog.String("channel_id", event.ChannelId),
+		mlog.String("root_id", event.RootId),
 		mlog.String("user_id", event.UserId),
 		mlog.Int("new_seq", int(event.NewLastSeq)),
 	)
@@ -183,22 +390,180 @@ func (a *App) publishReadCursorEvent(rctx request.CTX, event *model.ReadCursorEv
 	return nil
 }
 
-// invalidateReadReceiptsCacheForChannel invalidates all cached read receipt counts for a channel
-func (a *App) invalidateReadReceiptsCacheForChannel(channelId string) {
-	// Note: In a production system, you might want to:
-	// 1. Keep a list of post IDs per channel
-	// 2. Or use a cache key pattern like "post_read_count:channel:{channelId}:*"
-	// For now, we rely on the 30-second TTL to eventually update
-	// The WebSocket event will trigger immediate UI updates
+// deliverReadCursorEvent publishes an event that was written to the read cursor outbox and removes
+// the outbox entry. If publishing fails the entry is delivered later by the read cursor outbox job.
+func (a *App) deliverReadCursorEvent(rctx request.CTX, event *model.ReadCursorEvent) {
+	a.invalidateReadReceiptsCacheForChannel(rctx, event.ChannelId)
+
+	if err := a.publishReadCursorEvent(rctx, event); err != nil {
+		rctx.Logger().Warn("Failed to publish read cursor event, leaving it to the outbox job", mlog.Err(err))
+	} else if err := a.Srv().Store().ReadCursorOutbox().DeleteByEventId(event.EventId); err != nil {
+		// The job will publish the event again, which the read index service ignores
+		rctx.Logger().Warn("Failed to remove published read cursor event from the outbox", mlog.Err(err))
+	}
+}
+
+// PublishReadCursorEventPayload adds an encoded read cursor event to the read_cursor_events
+// Redis Stream. It is a no-op when Redis is not configured.
+func (a *App) PublishReadCursorEventPayload(ctx context.Context, payload string) error {
+	redisClientInterface := a.Srv().Platform().GetRedisClient()
+	if redisClientInterface == nil {
+		return nil
+	}
+
+	redisClient, ok := redisClientInterface.(rueidis.Client)
+	if !ok {
+		return errors.New("redis client is not a rueidis client")
+	}
+
+	cmd := redisClient.B().Xadd().
+		Key(readCursorEventsStream).
+		Id("*"). // Auto-generate ID
+		FieldValue().
+		FieldValue("data", payload).
+		Build()
+
+	return errors.Wrapf(redisClient.Do(ctx, cmd).Error(), "failed to add event to %s", readCursorEventsStream)
+}
+
+// cacheReadReceiptsCounts caches the counts of a channel unless they were invalidated since the
+// given generation. An invalidation racing with the write bumps the generation before removing
+// the entry, so checking again once written leaves nothing stale in the cache.
+func (a *App) cacheReadReceiptsCounts(rctx request.CTX, channelId string, counts map[string]int64, generation uint64) {
+	generations := &a.Srv().readReceiptsCountsGenerations
+	if generations.get(channelId) != generation {
+		return
+	}
+
+	ttl := readReceiptsCountsCacheTTL
+	if *a.Config().ReadReceiptsSettings.ReadIndexServiceURL != "" {
+		ttl = readReceiptsIndexCountsCacheTTL
+	}
+
+	countsCache := a.Srv().readReceiptsCountsCache
+	if err := countsCache.SetWithExpiry(channelId, counts, ttl); err != nil {
+		rctx.Logger().Warn("Failed to cache read receipts counts", mlog.String("channel_id", channelId), mlog.Err(err))
+		return
+	}
+
+	if generations.get(channelId) != generation {
+		if err := countsCache.Remove(channelId); err != nil {
+			rctx.Logger().Warn("Failed to remove read receipts counts from the cache", mlog.String("channel_id", channelId), mlog.Err(err))
+		}
+	}
 }
 
-// publishReadCursorWebSocketEvent sends a WebSocket event to notify users about read cursor changes
-func (a *App) publishReadCursorWebSocketEvent(rctx request.CTX, channelId, userId string, lastPostSeq int64) {
-	message := model.NewWebSocketEvent(model.WebsocketEventReadCursorAdvanced, "", channelId, "", nil, "")
+// invalidateReadReceiptsCacheForChannel invalidates all cached read receipt counts for a channel.
+// The local cache is invalidated right away, the other nodes at most once per
+// readReceiptsInvalidationDelay.
+func (a *App) invalidateReadReceiptsCacheForChannel(rctx request.CTX, channelId string) {
+	a.Srv().readReceiptsCountsGenerations.bump(channelId)
+	countsCache := a.Srv().readReceiptsCountsCache
+	if err := countsCache.Remove(channelId); err != nil {
+		rctx.Logger().Warn("Failed to remove read receipts counts from the cache", mlog.String("channel_id", channelId), mlog.Err(err))
+	}
+
+	// Only the in-memory cache needs to be invalidated on the other nodes
+	if a.Cluster() != nil && countsCache.GetInvalidateClusterEvent() != model.ClusterEventNone {
+		a.Srv().readReceiptsInvalidations.add(channelId)
+	}
+}
+
+// sendReadReceiptsCountsInvalidation invalidates the read receipt counts of a channel on the other
+// cluster nodes
+func (s *Server) sendReadReceiptsCountsInvalidation(channelId string) {
+	if cluster := s.platform.Cluster(); cluster != nil {
+		cluster.SendClusterMessage(&model.ClusterMessage{
+			Event:    s.readReceiptsCountsCache.GetInvalidateClusterEvent(),
+			SendType: model.ClusterSendBestEffort,
+			Data:     []byte(channelId),
+		})
+	}
+}
+
+// readReceiptsCountsGenerations counts the invalidations of the read receipt counts of each
+// channel. Channels share a fixed set of counters, so an invalidation may at worst keep the counts
+// of another channel from being cached once.
+type readReceiptsCountsGenerations [readReceiptsCountsGenerationsSize]atomic.Uint64
+
+func (g *readReceiptsCountsGenerations) counter(channelId string) *atomic.Uint64 {
+	h := fnv.New32a()
+	h.Write([]byte(channelId))
+	return &g[h.Sum32()%readReceiptsCountsGenerationsSize]
+}
+
+func (g *readReceiptsCountsGenerations) get(channelId string) uint64 {
+	return g.counter(channelId).Load()
+}
+
+func (g *readReceiptsCountsGenerations) bump(channelId string) {
+	g.counter(channelId).Add(1)
+}
+
+// readReceiptsInvalidations gathers the channels whose read receipt counts must be invalidated on
+// the other cluster nodes. Every cursor advance changes the counts, so a busy channel would
+// otherwise send a cluster message per read.
+type readReceiptsInvalidations struct {
+	delay time.Duration
+	send  func(channelId string)
+
+	mu        sync.Mutex
+	pending   map[string]bool
+	scheduled bool
+}
+
+func newReadReceiptsInvalidations(delay time.Duration, send func(channelId string)) *readReceiptsInvalidations {
+	return &readReceiptsInvalidations{
+		delay:   delay,
+		send:    send,
+		pending: make(map[string]bool),
+	}
+}
+
+// add schedules the invalidation of the channel, which is sent once with the other channels
+// added within the delay
+func (i *readReceiptsInvalidations) add(channelId string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.pending[channelId] = true
+	if !i.scheduled {
+		i.scheduled = true
+		time.AfterFunc(i.delay, i.flush)
+	}
+}
+
+// flush sends the pending invalidations
+func (i *readReceiptsInvalidations) flush() {
+	i.mu.Lock()
+	pending := i.pending
+	i.pending = make(map[string]bool)
+	i.scheduled = false
+	i.mu.Unlock()
+
+	for channelId := range pending {
+		i.send(channelId)
+	}
+}
+
+// publishReadCursorWebSocketEvent sends a WebSocket event to notify users about read cursor changes.
+// rootId is set when the cursor in a thread moved. Members who don't share their own read state
+// don't receive it.
+func (a *App) publishReadCursorWebSocketEvent(rctx request.CTX, channel *model.Channel, rootId, userId string, lastPostSeq int64) {
+	omitUsers, appErr := a.readReceiptsOmitUsers(channel)
+	if appErr != nil {
+		rctx.Logger().Warn("Failed to get the users to omit from the read cursor event", mlog.String("channel_id", channel.Id), mlog.Err(appErr))
+		return
+	}
+
+	message := model.NewWebSocketEvent(model.WebsocketEventReadCursorAdvanced, "", channel.Id, "", omitUsers, "")
 	message.Add("user_id", userId)
 	message.Add("last_post_seq", lastPostSeq)
-	message.Add("channel_id", channelId)
-	
+	message.Add("channel_id", channel.Id)
+	if rootId != "" {
+		message.Add("root_id", rootId)
+	}
+
 	a.Publish(message)
 }
 
@@ -211,7 +576,7 @@ func (a *App) AutoAdvanceReadCursorOnChannelView(rctx request.CTX, userId, chann
 		Page:      0,
 		PerPage:   1,
 	})
-	
+
 	if err != nil {
 		// If we can't get posts, just log and continue (don't fail the view operation)
 		rctx.Logger().Debug("Could not get latest post for auto-advance cursor",
@@ -260,7 +625,7 @@ func (a *App) CleanupOldReadCursors(rctx request.CTX, olderThanDays int) *model.
 	}
 
 	olderThan := model.GetMillis() - int64(olderThanDays*24*60*60*1000)
-	
+
 	if err := a.Srv().Store().ChannelReadCursor().DeleteOldCursors(olderThan); err != nil {
 		return model.NewAppError("CleanupOldReadCursors", "app.channel.read_cursor.cleanup.app_error", nil, err.Error(), 500)
 	}
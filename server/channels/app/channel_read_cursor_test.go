@@ -0,0 +1,85 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestReadReceiptsInvalidations(t *testing.T) {
+	mainHelper.Parallel(t)
+
+	var mu sync.Mutex
+	sent := map[string]int{}
+	invalidations := newReadReceiptsInvalidations(50*time.Millisecond, func(channelId string) {
+		mu.Lock()
+		defer mu.Unlock()
+		sent[channelId]++
+	})
+	sentCounts := func() map[string]int {
+		mu.Lock()
+		defer mu.Unlock()
+		counts := make(map[string]int, len(sent))
+		for channelId, count := range sent {
+			counts[channelId] = count
+		}
+		return counts
+	}
+
+	busy, quiet := model.NewId(), model.NewId()
+	for range 100 {
+		invalidations.add(busy)
+	}
+	invalidations.add(quiet)
+
+	require.Eventually(t, func() bool {
+		return len(sentCounts()) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]int{busy: 1, quiet: 1}, sentCounts(), "each channel is invalidated once per delay")
+
+	invalidations.add(busy)
+	require.Eventually(t, func() bool {
+		return sentCounts()[busy] == 2
+	}, 5*time.Second, 10*time.Millisecond, "later invalidations are sent again")
+	assert.Equal(t, 1, sentCounts()[quiet])
+}
+
+func TestCacheReadReceiptsCounts(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t)
+
+	channelId := model.NewId()
+	cached := func() map[string]int64 {
+		var counts map[string]int64
+		if err := th.App.Srv().readReceiptsCountsCache.Get(channelId, &counts); err != nil {
+			return nil
+		}
+		return counts
+	}
+
+	t.Run("counts computed across an invalidation aren't cached", func(t *testing.T) {
+		generation := th.App.Srv().readReceiptsCountsGenerations.get(channelId)
+		th.App.invalidateReadReceiptsCacheForChannel(th.Context, channelId)
+
+		th.App.cacheReadReceiptsCounts(th.Context, channelId, map[string]int64{"post": 1}, generation)
+		assert.Nil(t, cached())
+	})
+
+	t.Run("counts are cached until invalidated", func(t *testing.T) {
+		generation := th.App.Srv().readReceiptsCountsGenerations.get(channelId)
+		th.App.cacheReadReceiptsCounts(th.Context, channelId, map[string]int64{"post": 2}, generation)
+		assert.Equal(t, map[string]int64{"post": 2}, cached())
+
+		th.App.invalidateReadReceiptsCacheForChannel(th.Context, channelId)
+		assert.Nil(t, cached())
+		assert.NotEqual(t, generation, th.App.Srv().readReceiptsCountsGenerations.get(channelId))
+	})
+}
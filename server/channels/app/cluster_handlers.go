@@ -54,6 +54,28 @@ func (s *Server) clusterPluginEventHandler(msg *model.ClusterMessage) {
 	})
 }
 
+func (s *Server) clusterInvalidateReadReceiptsCountsHandler(msg *model.ClusterMessage) {
+	s.readReceiptsCountsGenerations.bump(string(msg.Data))
+	if err := s.readReceiptsCountsCache.Remove(string(msg.Data)); err != nil {
+		s.Log().Warn("Failed to remove read receipts counts from the cache", mlog.Err(err))
+	}
+}
+
+func (s *Server) clusterInvalidateReadStateHiddenMembersHandler(msg *model.ClusterMessage) {
+	var err error
+	if len(msg.Data) == 0 {
+		err = s.readStateHiddenMembersCache.Purge()
+		if err == nil {
+			err = s.readStateHiddenReadersCache.Purge()
+		}
+	} else {
+		err = s.readStateHiddenMembersCache.Remove(string(msg.Data))
+	}
+	if err != nil {
+		s.Log().Warn("Failed to invalidate the hidden read state members cache", mlog.Err(err))
+	}
+}
+
 // registerClusterHandlers registers the cluster message handlers that are handled by the server.
 //
 // The cluster event handlers are spread across this function and NewLocalCacheLayer.
@@ -62,6 +84,8 @@ func (s *Server) registerClusterHandlers() {
 	s.platform.RegisterClusterMessageHandler(model.ClusterEventInstallPlugin, s.clusterInstallPluginHandler)
 	s.platform.RegisterClusterMessageHandler(model.ClusterEventRemovePlugin, s.clusterRemovePluginHandler)
 	s.platform.RegisterClusterMessageHandler(model.ClusterEventPluginEvent, s.clusterPluginEventHandler)
+	s.platform.RegisterClusterMessageHandler(model.ClusterEventInvalidateCacheForReadReceiptsCounts, s.clusterInvalidateReadReceiptsCountsHandler)
+	s.platform.RegisterClusterMessageHandler(model.ClusterEventInvalidateCacheForReadStateHiddenMembers, s.clusterInvalidateReadStateHiddenMembersHandler)
 
 	s.platform.RegisterClusterHandlers()
 }
@@ -14,14 +14,19 @@ import (
 	"sync"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/shared/i18n"
 	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
 	"github.com/mattermost/mattermost/server/v8/channels/utils"
 )
 
 const (
 	EmailBatchingTaskName = "Email Batching"
+
+	emailBatchingUsersPerPage = 100
 )
 
 type postData struct {
@@ -37,15 +42,21 @@ type postData struct {
 	MessageAttachments       []*EmailMessageAttachment
 }
 
+// InitEmailBatching starts the job sending the batched notifications. Once email batching is
+// turned off, the job is stopped and the notifications still queued are sent right away, since
+// nothing would send them later.
 func (es *Service) InitEmailBatching() {
 	if *es.config().EmailSettings.EnableEmailBatching {
 		if es.EmailBatching == nil {
-			es.EmailBatching = NewEmailBatchingJob(es, *es.config().EmailSettings.EmailBatchingBufferSize)
+			es.EmailBatching = NewEmailBatchingJob(es)
 		}
 
-		// note that we don't support changing EmailBatchingBufferSize without restarting the server
-
 		es.EmailBatching.Start()
+		return
+	}
+
+	if es.EmailBatching != nil {
+		go es.EmailBatching.stopAndFlush()
 	}
 }
 
@@ -54,9 +65,9 @@ func (es *Service) AddNotificationEmailToBatch(user *model.User, post *model.Pos
 		return model.NewAppError("AddNotificationEmailToBatch", "api.email_batching.add_notification_email_to_batch.disabled.app_error", nil, "", http.StatusNotImplemented)
 	}
 
-	if !es.EmailBatching.Add(user, post, team) {
-		mlog.Error("Email batching job's receiving buffer was full. Please increase the EmailBatchingBufferSize. Falling back to sending immediate mail.")
-		return model.NewAppError("AddNotificationEmailToBatch", "api.email_batching.add_notification_email_to_batch.channel_full.app_error", nil, "", http.StatusInternalServerError)
+	if err := es.EmailBatching.Add(user, post, team); err != nil {
+		mlog.Error("Unable to queue the email notification for batching. Falling back to sending immediate mail.", mlog.Err(err))
+		return model.NewAppError("AddNotificationEmailToBatch", "api.email_batching.add_notification_email_to_batch.save.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
 
 	return nil
@@ -68,22 +79,23 @@ type batchedNotification struct {
 	teamName string
 }
 
+// EmailBatchingJob keeps the pending notifications in the EmailBatchEntries table, so that they
+// survive a restart and any node can queue them. Only the cluster leader sends the emails.
 type EmailBatchingJob struct {
 	config  func() *model.Config
 	service *Service
 
-	newNotifications     chan *batchedNotification
-	pendingNotifications map[string][]*batchedNotification
-	task                 *model.ScheduledTask
-	taskMutex            sync.Mutex
+	task      *model.ScheduledTask
+	taskMutex sync.Mutex
+
+	// sendMutex keeps a flush from sending the notifications a run of the task is sending
+	sendMutex sync.Mutex
 }
 
-func NewEmailBatchingJob(es *Service, bufferSize int) *EmailBatchingJob {
+func NewEmailBatchingJob(es *Service) *EmailBatchingJob {
 	return &EmailBatchingJob{
-		config:               es.config,
-		service:              es,
-		newNotifications:     make(chan *batchedNotification, bufferSize),
-		pendingNotifications: make(map[string][]*batchedNotification),
+		config:  es.config,
+		service: es,
 	}
 }
 
@@ -101,140 +113,232 @@ func (job *EmailBatchingJob) Start() {
 	}
 }
 
-// Stop will cancel the task properly, flushing out any pending notifications.
-// Although this still won't send those notifications which are yet to be sent
-// due to a user's PreferenceNameEmailInterval.
+// Stop will cancel the task properly. The pending notifications stay queued and are
+// sent once the job runs again, on this node or on the next leader.
 func (job *EmailBatchingJob) Stop() {
+	job.stop()
+}
+
+// stop cancels the task, returning whether it was running
+func (job *EmailBatchingJob) stop() bool {
 	job.taskMutex.Lock()
-	if task := job.task; task != nil {
-		task.Cancel()
-	}
+	task := job.task
+	job.task = nil
 	job.taskMutex.Unlock()
-}
 
-func (job *EmailBatchingJob) Add(user *model.User, post *model.Post, team *model.Team) bool {
-	notification := &batchedNotification{
-		userID:   user.Id,
-		post:     post,
-		teamName: team.Name,
+	if task == nil {
+		return false
 	}
+	task.Cancel()
+	return true
+}
 
-	select {
-	case job.newNotifications <- notification:
-		return true
-	default:
-		// return false if we couldn't queue the email notification so that we can send an immediate email
-		return false
+// stopAndFlush stops the job and, if it was running, sends the notifications it queued
+func (job *EmailBatchingJob) stopAndFlush() {
+	if job.stop() {
+		job.Flush()
 	}
 }
 
+func (job *EmailBatchingJob) Add(user *model.User, post *model.Post, team *model.Team) error {
+	return job.service.store.EmailBatch().Save(&model.EmailBatchEntry{
+		UserId:    user.Id,
+		PostId:    post.Id,
+		ChannelId: post.ChannelId,
+		TeamName:  team.Name,
+		CreateAt:  post.CreateAt,
+	})
+}
+
 func (job *EmailBatchingJob) CheckPendingEmails() {
-	job.handleNewNotifications()
+	if !job.service.isLeader() {
+		return
+	}
 
 	// it's a bit weird to pass the send email function through here, but it makes it so that we can test
 	// without actually sending emails
 	job.checkPendingNotifications(time.Now(), job.service.sendBatchedEmailNotification)
-
-	mlog.Debug("Email batching job ran. Notifications might be still pending.", mlog.Int("number_of_users", len(job.pendingNotifications)))
 }
 
-func (job *EmailBatchingJob) handleNewNotifications() {
-	receiving := true
+// Flush sends the pending notifications without waiting for the batching interval of their
+// users. The notifications that can't be sent are discarded.
+func (job *EmailBatchingJob) Flush() {
+	if !job.service.isLeader() {
+		return
+	}
 
-	// read in new notifications to send
-	for receiving {
-		select {
-		case notification := <-job.newNotifications:
-			userID := notification.userID
+	job.sendPendingUsers(time.Now(), true, job.service.sendBatchedEmailNotification)
+}
 
-			if _, ok := job.pendingNotifications[userID]; !ok {
-				job.pendingNotifications[userID] = []*batchedNotification{notification}
-			} else {
-				job.pendingNotifications[userID] = append(job.pendingNotifications[userID], notification)
-			}
-		default:
-			receiving = false
-		}
-	}
+func (job *EmailBatchingJob) checkPendingNotifications(now time.Time, handler func(string, []*batchedNotification) error) {
+	job.sendPendingUsers(now, false, handler)
 }
 
-func (job *EmailBatchingJob) checkPendingNotifications(now time.Time, handler func(string, []*batchedNotification)) {
-	for userID, notifications := range job.pendingNotifications {
-		// Defensive code.
-		if len(notifications) == 0 {
-			mlog.Warn("Unexpected result. Got 0 pending notifications for batched email.", mlog.String("user_id", userID))
-			continue
-		}
+// sendPendingUsers sends the pending notifications of the users whose batching interval passed,
+// or of all the users when flushing
+func (job *EmailBatchingJob) sendPendingUsers(now time.Time, flush bool, handler func(string, []*batchedNotification) error) {
+	job.sendMutex.Lock()
+	defer job.sendMutex.Unlock()
 
-		// get how long we need to wait to send notifications to the user
-		var interval int64
-		preference, err := job.service.store.Preference().Get(userID, model.PreferenceCategoryNotifications, model.PreferenceNameEmailInterval)
+	users := 0
+	afterUserID := ""
+	for {
+		pending, err := job.service.store.EmailBatch().GetPendingUsers(afterUserID, emailBatchingUsersPerPage)
 		if err != nil {
-			// use the default batching interval if an error occurs while fetching user preferences
-			interval, _ = strconv.ParseInt(model.PreferenceEmailIntervalBatchingSeconds, 10, 64)
-		} else {
-			if value, err := strconv.ParseInt(preference.Value, 10, 64); err != nil {
-				// // use the default batching interval if an error occurs while deserializing user preferences
-				interval, _ = strconv.ParseInt(model.PreferenceEmailIntervalBatchingSeconds, 10, 64)
-			} else {
-				interval = value
-			}
+			mlog.Error("Unable to get the users with pending batched email notifications", mlog.Err(err))
+			return
 		}
 
-		batchStartTime := notifications[0].post.CreateAt
-		// Ignore if it isn't time yet to send.
-		if now.Sub(time.UnixMilli(batchStartTime)) <= time.Duration(interval)*time.Second {
-			continue
-		}
-
-		// If the user has viewed any channels in this team since the notification was queued, delete
-		// all queued notifications
-		inspectedTeamNames := make(map[string]string)
-		for _, notification := range notifications {
-			// at most, we'll do one check for each team that notifications were sent for
-			if inspectedTeamNames[notification.teamName] != "" {
+		for _, user := range pending {
+			// Ignore if it isn't time yet to send.
+			interval := job.getInterval(user.UserId)
+			if !flush && now.Sub(time.UnixMilli(user.OldestCreateAt)) <= time.Duration(interval)*time.Second {
 				continue
 			}
 
-			team, nErr := job.service.store.Team().GetByName(notifications[0].teamName)
-			if nErr != nil {
-				mlog.Error("Unable to find Team id for notification", mlog.Err(nErr))
+			if err := job.sendPendingNotifications(user.UserId, handler); err != nil {
+				if flush {
+					mlog.Warn("Unable to send batched email notifications. They are discarded since email batching is disabled.", mlog.String("user_id", user.UserId), mlog.Err(err))
+					job.discardPendingNotifications(user.UserId)
+					continue
+				}
+				mlog.Warn("Unable to send batched email notifications. They will be retried.", mlog.String("user_id", user.UserId), mlog.Err(err))
 				continue
 			}
+			users++
+		}
 
-			if team != nil {
-				inspectedTeamNames[notification.teamName] = team.Id
-			}
+		if len(pending) < emailBatchingUsersPerPage {
+			break
+		}
+		afterUserID = pending[len(pending)-1].UserId
+	}
 
-			channelMembers, err := job.service.store.Channel().GetMembersForUser(inspectedTeamNames[notification.teamName], userID)
-			if err != nil {
-				mlog.Error("Unable to find ChannelMembers for user", mlog.Err(err))
-				continue
-			}
+	mlog.Debug("Email batching job ran. Notifications might be still pending.", mlog.Int("number_of_users", users))
+}
 
-			deleted := false
-			for _, channelMember := range channelMembers {
-				if channelMember.LastViewedAt >= batchStartTime {
-					mlog.Debug("Deleted notifications for user", mlog.String("user_id", userID))
-					delete(job.pendingNotifications, userID)
-					deleted = true
-					break
-				}
-			}
-			if deleted {
-				break
-			}
+// discardPendingNotifications removes the pending notifications of a user without sending them
+func (job *EmailBatchingJob) discardPendingNotifications(userID string) {
+	entries, err := job.service.store.EmailBatch().GetForUser(userID)
+	if err != nil {
+		mlog.Warn("Unable to get the batched email notifications to discard", mlog.String("user_id", userID), mlog.Err(err))
+		return
+	}
+
+	entryIds := make([]string, len(entries))
+	for i, entry := range entries {
+		entryIds[i] = entry.Id
+	}
+	if err := job.service.store.EmailBatch().Delete(entryIds); err != nil {
+		mlog.Warn("Unable to discard the batched email notifications", mlog.String("user_id", userID), mlog.Err(err))
+	}
+}
+
+// getInterval returns how long, in seconds, the notifications of the user are batched
+func (job *EmailBatchingJob) getInterval(userID string) int64 {
+	preference, err := job.service.store.Preference().Get(userID, model.PreferenceCategoryNotifications, model.PreferenceNameEmailInterval)
+	if err == nil {
+		if value, err := strconv.ParseInt(preference.Value, 10, 64); err == nil {
+			return value
+		}
+	}
+
+	// use the default batching interval if an error occurs while fetching or deserializing user preferences
+	interval, _ := strconv.ParseInt(model.PreferenceEmailIntervalBatchingSeconds, 10, 64)
+	return interval
+}
+
+// sendPendingNotifications sends the queued notifications of a user, skipping the posts that
+// were deleted or read since they were queued, and those of the channels the user left. The
+// entries are removed once they are handled.
+func (job *EmailBatchingJob) sendPendingNotifications(userID string, handler func(string, []*batchedNotification) error) error {
+	entries, err := job.service.store.EmailBatch().GetForUser(userID)
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	entryIds := make([]string, 0, len(entries))
+	postIds := make([]string, 0, len(entries))
+	channelIds := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entryIds = append(entryIds, entry.Id)
+		postIds = append(postIds, entry.PostId)
+		channelIds = append(channelIds, entry.ChannelId)
+	}
+
+	posts, err := job.service.store.Post().GetPostsByIds(postIds)
+	var nfErr *store.ErrNotFound
+	if err != nil && !errors.As(err, &nfErr) {
+		return err
+	}
+	postsByID := make(map[string]*model.Post, len(posts))
+	for _, post := range posts {
+		postsByID[post.Id] = post
+	}
+
+	lastViewedAt, err := job.getLastViewedAt(userID, channelIds)
+	if err != nil {
+		return err
+	}
+
+	notifications := make([]*batchedNotification, 0, len(entries))
+	for _, entry := range entries {
+		post, ok := postsByID[entry.PostId]
+		if !ok || post.DeleteAt != 0 {
+			continue
 		}
 
-		// The notifications might have been cleared from the above step.
-		// We need to check again.
-		if len(job.pendingNotifications[userID]) == 0 {
+		// The user left the channel, or has read the post, since it was queued
+		viewedAt, isMember := lastViewedAt[entry.ChannelId]
+		if !isMember || viewedAt >= post.CreateAt {
 			continue
 		}
 
-		handler(userID, job.pendingNotifications[userID])
-		delete(job.pendingNotifications, userID)
+		notifications = append(notifications, &batchedNotification{
+			userID:   userID,
+			post:     post,
+			teamName: entry.TeamName,
+		})
+	}
+
+	if len(notifications) > 0 {
+		if err := handler(userID, notifications); err != nil {
+			return err
+		}
+	} else {
+		mlog.Debug("Deleted notifications for user", mlog.String("user_id", userID))
+	}
+
+	return job.service.store.EmailBatch().Delete(entryIds)
+}
+
+// getLastViewedAt returns, for each channel the user is a member of, the latest of their last view
+// and read cursor
+func (job *EmailBatchingJob) getLastViewedAt(userID string, channelIds []string) (map[string]int64, error) {
+	lastViewedAt := make(map[string]int64, len(channelIds))
+
+	members, err := job.service.store.Channel().GetMembersByChannelIds(channelIds, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		lastViewedAt[member.ChannelId] = member.LastViewedAt
+	}
+
+	cursors, err := job.service.store.ChannelReadCursor().GetForUser(userID)
+	if err != nil {
+		return nil, err
 	}
+	for _, cursor := range cursors {
+		if viewedAt, isMember := lastViewedAt[cursor.ChannelId]; isMember && cursor.LastPostSeq > viewedAt {
+			lastViewedAt[cursor.ChannelId] = cursor.LastPostSeq
+		}
+	}
+
+	return lastViewedAt, nil
 }
 
 /**
@@ -249,11 +353,12 @@ func truncateUserNames(name string, i int) string {
 	return name
 }
 
-func (es *Service) sendBatchedEmailNotification(userID string, notifications []*batchedNotification) {
+func (es *Service) sendBatchedEmailNotification(userID string, notifications []*batchedNotification) error {
 	user, err := es.userService.GetUser(userID)
 	if err != nil {
+		// The notifications of a deleted recipient can't ever be sent
 		mlog.Warn("Unable to find recipient for batched email notification")
-		return
+		return nil
 	}
 
 	translateFunc := i18n.GetUserTranslations(user.Locale)
@@ -375,5 +480,8 @@ func (es *Service) sendBatchedEmailNotification(userID string, notifications []*
 
 	if nErr := es.SendMailWithEmbeddedFiles(user.Email, subject, renderedPage, embeddedFiles, "", "", "", "BatchedEmailNotification"); nErr != nil {
 		mlog.Warn("Unable to send batched email notification", mlog.String("email", user.Email), mlog.Err(nErr))
+		return nErr
 	}
+
+	return nil
 }
@@ -4,200 +4,173 @@
 package email
 
 import (
-	"sync/atomic"
+	"errors"
 	"testing"
 	"time"
 
-	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
 )
 
-func TestHandleNewNotifications(t *testing.T) {
+func TestAddNotificationToBatch(t *testing.T) {
 	mainHelper.Parallel(t)
 
 	th := SetupWithStoreMock(t)
 
-	id1 := model.NewId()
-	id2 := model.NewId()
-	id3 := model.NewId()
-
-	// test queueing of received posts by user
-	job := NewEmailBatchingJob(th.service, 128)
-
-	job.handleNewNotifications()
-
-	require.Empty(t, job.pendingNotifications, "shouldn't have added any pending notifications")
-
-	job.Add(&model.User{Id: id1}, &model.Post{UserId: id1, Message: "test"}, &model.Team{Name: "team"})
-	require.Empty(t, job.pendingNotifications, "shouldn't have added any pending notifications")
-
-	job.handleNewNotifications()
-	require.Len(t, job.pendingNotifications, 1, "should have received posts for 1 user")
-	require.Len(t, job.pendingNotifications[id1], 1, "should have received 1 post for user")
-
-	job.Add(&model.User{Id: id1}, &model.Post{UserId: id1, Message: "test"}, &model.Team{Name: "team"})
-	job.handleNewNotifications()
-	require.Len(t, job.pendingNotifications, 1, "should have received posts for 1 user")
-	require.Len(t, job.pendingNotifications[id1], 2, "should have received 2 posts for user1")
-
-	job.Add(&model.User{Id: id2}, &model.Post{UserId: id1, Message: "test"}, &model.Team{Name: "team"})
-	job.handleNewNotifications()
-	require.Len(t, job.pendingNotifications, 2, "should have received posts for 2 users")
-	require.Len(t, job.pendingNotifications[id1], 2, "should have received 2 posts for user1")
-	require.Len(t, job.pendingNotifications[id2], 1, "should have received 1 post for user2")
-
-	job.Add(&model.User{Id: id2}, &model.Post{UserId: id2, Message: "test"}, &model.Team{Name: "team"})
-	job.Add(&model.User{Id: id1}, &model.Post{UserId: id3, Message: "test"}, &model.Team{Name: "team"})
-	job.Add(&model.User{Id: id3}, &model.Post{UserId: id3, Message: "test"}, &model.Team{Name: "team"})
-	job.Add(&model.User{Id: id2}, &model.Post{UserId: id2, Message: "test"}, &model.Team{Name: "team"})
-	job.handleNewNotifications()
-	require.Len(t, job.pendingNotifications, 3, "should have received posts for 3 users")
-	require.Len(t, job.pendingNotifications[id1], 3, "should have received 3 posts for user1")
-	require.Len(t, job.pendingNotifications[id2], 3, "should have received 3 posts for user2")
-	require.Len(t, job.pendingNotifications[id3], 1, "should have received 1 post for user3")
-
-	// test ordering of received posts
-	job = NewEmailBatchingJob(th.service, 128)
-
-	job.Add(&model.User{Id: id1}, &model.Post{UserId: id1, Message: "test1"}, &model.Team{Name: "team"})
-	job.Add(&model.User{Id: id1}, &model.Post{UserId: id1, Message: "test2"}, &model.Team{Name: "team"})
-	job.Add(&model.User{Id: id2}, &model.Post{UserId: id1, Message: "test3"}, &model.Team{Name: "team"})
-	job.Add(&model.User{Id: id1}, &model.Post{UserId: id1, Message: "test4"}, &model.Team{Name: "team"})
-	job.Add(&model.User{Id: id2}, &model.Post{UserId: id1, Message: "test5"}, &model.Team{Name: "team"})
-	job.handleNewNotifications()
-	assert.Equal(t, job.pendingNotifications[id1][0].post.Message, "test1", "incorrect order of received posts for user1")
-	assert.Equal(t, job.pendingNotifications[id1][1].post.Message, "test2", "incorrect order of received posts for user1")
-	assert.Equal(t, job.pendingNotifications[id1][2].post.Message, "test4", "incorrect order of received posts for user1")
-	assert.Equal(t, job.pendingNotifications[id2][0].post.Message, "test3", "incorrect order of received posts for user2")
-	assert.Equal(t, job.pendingNotifications[id2][1].post.Message, "test5", "incorrect order of received posts for user2")
+	user := &model.User{Id: model.NewId()}
+	post := &model.Post{Id: model.NewId(), ChannelId: model.NewId(), UserId: model.NewId(), Message: "test", CreateAt: 1000}
+	team := &model.Team{Name: "team"}
+
+	emailBatchMock := mocks.EmailBatchStore{}
+	emailBatchMock.On("Save", &model.EmailBatchEntry{
+		UserId:    user.Id,
+		PostId:    post.Id,
+		ChannelId: post.ChannelId,
+		TeamName:  team.Name,
+		CreateAt:  post.CreateAt,
+	}).Return(nil).Once()
+	emailBatchMock.On("Save", mock.AnythingOfType("*model.EmailBatchEntry")).Return(errors.New("failure")).Once()
+	th.service.store.(*mocks.Store).On("EmailBatch").Return(&emailBatchMock)
+
+	job := NewEmailBatchingJob(th.service)
+
+	// test that the notification is queued in the store
+	require.NoError(t, job.Add(user, post, team))
+
+	// test that a failure is returned so that an immediate email is sent instead
+	require.Error(t, job.Add(user, post, team))
+
+	emailBatchMock.AssertExpectations(t)
 }
 
+// The tests below aren't run in parallel, since the job drains the queued notifications of all users
+
 func TestCheckPendingNotifications(t *testing.T) {
-	mainHelper.Parallel(t)
 	th := Setup(t).InitBasic(t)
 
-	job := NewEmailBatchingJob(th.service, 128)
-	job.pendingNotifications[th.BasicUser.Id] = []*batchedNotification{
-		{
-			post: &model.Post{
-				UserId:    th.BasicUser.Id,
-				ChannelId: th.BasicChannel.Id,
-				CreateAt:  10000000,
-			},
-			teamName: th.BasicTeam.Name,
-		},
-	}
+	job := NewEmailBatchingJob(th.service)
 
-	channelMember, err := th.store.Channel().GetMember(th.Context, th.BasicChannel.Id, th.BasicUser.Id)
-	require.NoError(t, err)
-	channelMember.LastViewedAt = 9999999
-	_, err = th.store.Channel().UpdateMember(th.Context, channelMember)
-	require.NoError(t, err)
+	setLastViewedAt := func(lastViewedAt int64) {
+		channelMember, err := th.store.Channel().GetMember(th.Context, th.BasicChannel.Id, th.BasicUser.Id)
+		require.NoError(t, err)
+		channelMember.LastViewedAt = lastViewedAt
+		_, err = th.store.Channel().UpdateMember(th.Context, channelMember)
+		require.NoError(t, err)
+	}
+	setInterval := func(interval string) {
+		err := th.store.Preference().Save(model.Preferences{{
+			UserId:   th.BasicUser.Id,
+			Category: model.PreferenceCategoryNotifications,
+			Name:     model.PreferenceNameEmailInterval,
+			Value:    interval,
+		}})
+		require.NoError(t, err)
+	}
+	addPost := func(message string, createAt int64) *model.Post {
+		post, err := th.store.Post().Save(th.Context, &model.Post{
+			UserId:    th.BasicUser2.Id,
+			ChannelId: th.BasicChannel.Id,
+			Message:   message,
+			CreateAt:  createAt,
+		})
+		require.NoError(t, err)
+		require.NoError(t, job.Add(th.BasicUser, post, th.BasicTeam))
+		return post
+	}
+	requirePending := func(count int) {
+		entries, err := th.store.EmailBatch().GetForUser(th.BasicUser.Id)
+		require.NoError(t, err)
+		require.Len(t, entries, count)
+	}
+	failHandler := func(string, []*batchedNotification) error {
+		require.Fail(t, "email handler should not have been called")
+		return nil
+	}
 
-	nErr := th.store.Preference().Save(model.Preferences{{
-		UserId:   th.BasicUser.Id,
-		Category: model.PreferenceCategoryNotifications,
-		Name:     model.PreferenceNameEmailInterval,
-		Value:    "60",
-	}})
-	require.NoError(t, nErr)
+	setLastViewedAt(9999999)
+	setInterval("60")
+	addPost("post0", 10000000)
 
 	// test that notifications aren't sent before interval
-	job.checkPendingNotifications(time.Unix(10001, 0), func(string, []*batchedNotification) {})
-
-	require.NotNil(t, job.pendingNotifications[th.BasicUser.Id])
-	require.Len(t, job.pendingNotifications[th.BasicUser.Id], 1, "shouldn't have sent queued post")
+	job.checkPendingNotifications(time.Unix(10001, 0), failHandler)
+	requirePending(1)
 
 	// test that notifications are cleared if the user has acted
-	channelMember, err = th.store.Channel().GetMember(th.Context, th.BasicChannel.Id, th.BasicUser.Id)
-	require.NoError(t, err)
-	channelMember.LastViewedAt = 10001000
-	_, err = th.store.Channel().UpdateMember(th.Context, channelMember)
-	require.NoError(t, err)
+	setLastViewedAt(10001000)
 
 	// We reset the interval to something shorter
-	nErr = th.store.Preference().Save(model.Preferences{{
-		UserId:   th.BasicUser.Id,
-		Category: model.PreferenceCategoryNotifications,
-		Name:     model.PreferenceNameEmailInterval,
-		Value:    "10",
-	}})
-	require.NoError(t, nErr)
+	setInterval("10")
 
-	var wasCalled int32
-	job.checkPendingNotifications(time.Unix(10050, 0), func(string, []*batchedNotification) {
-		atomic.StoreInt32(&wasCalled, int32(1))
+	job.checkPendingNotifications(time.Unix(10050, 0), failHandler)
+	requirePending(0)
+
+	// test that notifications are sent if enough time passes since the first message
+	addPost("post1", 10060000)
+	addPost("post2", 10090000)
+
+	var received []*batchedNotification
+	job.checkPendingNotifications(time.Unix(10130, 0), func(userID string, notifications []*batchedNotification) error {
+		require.Equal(t, th.BasicUser.Id, userID)
+		received = notifications
+		return nil
 	})
 
-	// A hack to check whether the handler was called.
-	// It's not straightforward to just wait for it using a channel because the test should
-	// NOT call the handler, and it will be called only if the test fails.
-	time.Sleep(1 * time.Second)
-	// We do a check outside the email handler, because otherwise, failing from
-	// inside the handler doesn't let the .Go() function exit cleanly, and it gets
-	// stuck during server shutdown, trying to wait for the goroutine to exit
-	require.Equal(t, int32(0), atomic.LoadInt32(&wasCalled), "email handler should not have been called")
+	require.Len(t, received, 2)
+	require.Equal(t, "post1", received[0].post.Message, "should've received post1 first")
+	require.Equal(t, "post2", received[1].post.Message, "should've received post2 second")
+	require.Equal(t, th.BasicTeam.Name, received[0].teamName)
+	requirePending(0)
 
-	require.Nil(t, job.pendingNotifications[th.BasicUser.Id])
-	require.Empty(t, job.pendingNotifications[th.BasicUser.Id], "should've remove queued post since user acted")
+	// test that notifications stay queued when they couldn't be sent
+	addPost("post3", 10200000)
 
-	// test that notifications are sent if enough time passes since the first message
-	job.pendingNotifications[th.BasicUser.Id] = []*batchedNotification{
-		{
-			post: &model.Post{
-				UserId:    th.BasicUser.Id,
-				ChannelId: th.BasicChannel.Id,
-				CreateAt:  10060000,
-				Message:   "post1",
-			},
-			teamName: th.BasicTeam.Name,
-		},
-		{
-			post: &model.Post{
-				UserId:    th.BasicUser.Id,
-				ChannelId: th.BasicChannel.Id,
-				CreateAt:  10090000,
-				Message:   "post2",
-			},
-			teamName: th.BasicTeam.Name,
-		},
-	}
-
-	received := make(chan *model.Post, 2)
+	job.checkPendingNotifications(time.Unix(10250, 0), func(string, []*batchedNotification) error {
+		return errors.New("failure")
+	})
+	requirePending(1)
+
+	// test that the posts read since they were queued are skipped, using the read cursors
+	addPost("post4", 10300000)
+	_, err := th.store.ChannelReadCursor().Upsert(&model.ChannelReadCursor{
+		ChannelId:   th.BasicChannel.Id,
+		UserId:      th.BasicUser.Id,
+		LastPostSeq: 10200000,
+		UpdatedAt:   model.GetMillis(),
+	})
+	require.NoError(t, err)
 
-	job.checkPendingNotifications(time.Unix(10130, 0), func(s string, notifications []*batchedNotification) {
-		for _, notification := range notifications {
-			received <- notification.post
-		}
+	received = nil
+	job.checkPendingNotifications(time.Unix(10350, 0), func(userID string, notifications []*batchedNotification) error {
+		received = notifications
+		return nil
 	})
 
-	require.Nil(t, job.pendingNotifications[th.BasicUser.Id], "shouldn't have sent queued post")
+	require.Len(t, received, 1)
+	require.Equal(t, "post4", received[0].post.Message)
+	requirePending(0)
 
-	select {
-	case post := <-received:
-		require.Equal(t, post.Message, "post1", "should've received post1 first")
-	case <-time.After(5 * time.Second):
-		require.Fail(t, "timed out waiting for first post notification")
-	}
+	// test that only the cluster leader sends the notifications
+	addPost("post5", 10400000)
+	th.service.isLeaderFn = func() bool { return false }
+	defer func() { th.service.isLeaderFn = nil }()
 
-	select {
-	case post := <-received:
-		require.Equal(t, post.Message, "post2", "should've received post2 second")
-	case <-time.After(5 * time.Second):
-		require.Fail(t, "timed out waiting for second post notification")
-	}
+	job.CheckPendingEmails()
+	requirePending(1)
+
+	job.checkPendingNotifications(time.Unix(10450, 0), func(string, []*batchedNotification) error { return nil })
+	requirePending(0)
 }
 
 /**
  * Ensures that email batch interval defaults to 15 minutes for users that haven't explicitly set this preference
  */
 func TestCheckPendingNotificationsDefaultInterval(t *testing.T) {
-	mainHelper.Parallel(t)
 	th := Setup(t).InitBasic(t)
 
-	job := NewEmailBatchingJob(th.service, 128)
+	job := NewEmailBatchingJob(th.service)
 
 	// bypasses recent user activity check
 	require.NotNil(t, th.store)
@@ -211,36 +184,34 @@ func TestCheckPendingNotificationsDefaultInterval(t *testing.T) {
 	_, err = th.store.Channel().UpdateMember(th.Context, channelMember)
 	require.NoError(t, err)
 
-	job.pendingNotifications[th.BasicUser.Id] = []*batchedNotification{
-		{
-			post: &model.Post{
-				UserId:    th.BasicUser.Id,
-				ChannelId: th.BasicChannel.Id,
-				CreateAt:  10000000,
-			},
-			teamName: th.BasicTeam.Name,
-		},
-	}
+	post, err := th.store.Post().Save(th.Context, &model.Post{
+		UserId:    th.BasicUser2.Id,
+		ChannelId: th.BasicChannel.Id,
+		CreateAt:  10000000,
+	})
+	require.NoError(t, err)
+	require.NoError(t, job.Add(th.BasicUser, post, th.BasicTeam))
 
 	// notifications should not be sent 1s after post was created, because default batch interval is 15mins
-	job.checkPendingNotifications(time.Unix(10001, 0), func(string, []*batchedNotification) {})
-	require.NotNil(t, job.pendingNotifications[th.BasicUser.Id])
-	require.Len(t, job.pendingNotifications[th.BasicUser.Id], 1, "shouldn't have sent queued post")
+	job.checkPendingNotifications(time.Unix(10001, 0), func(string, []*batchedNotification) error { return nil })
+	entries, err := th.store.EmailBatch().GetForUser(th.BasicUser.Id)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "shouldn't have sent queued post")
 
 	// notifications should be sent 901s after post was created, because default batch interval is 15mins
-	job.checkPendingNotifications(time.Unix(10901, 0), func(string, []*batchedNotification) {})
-	require.Nil(t, job.pendingNotifications[th.BasicUser.Id])
-	require.Empty(t, job.pendingNotifications[th.BasicUser.Id], "should have sent queued post")
+	job.checkPendingNotifications(time.Unix(10901, 0), func(string, []*batchedNotification) error { return nil })
+	entries, err = th.store.EmailBatch().GetForUser(th.BasicUser.Id)
+	require.NoError(t, err)
+	require.Empty(t, entries, "should have sent queued post")
 }
 
 /**
  * Ensures that email batch interval defaults to 15 minutes if user preference is invalid
  */
 func TestCheckPendingNotificationsCantParseInterval(t *testing.T) {
-	mainHelper.Parallel(t)
 	th := Setup(t).InitBasic(t)
 
-	job := NewEmailBatchingJob(th.service, 128)
+	job := NewEmailBatchingJob(th.service)
 
 	require.NotNil(t, th.store)
 	require.NotNil(t, th.store.Channel())
@@ -262,24 +233,118 @@ func TestCheckPendingNotificationsCantParseInterval(t *testing.T) {
 	}})
 	require.NoError(t, nErr)
 
-	job.pendingNotifications[th.BasicUser.Id] = []*batchedNotification{
-		{
-			post: &model.Post{
-				UserId:    th.BasicUser.Id,
-				ChannelId: th.BasicChannel.Id,
-				CreateAt:  10000000,
-			},
-			teamName: th.BasicTeam.Name,
-		},
-	}
+	post, err := th.store.Post().Save(th.Context, &model.Post{
+		UserId:    th.BasicUser2.Id,
+		ChannelId: th.BasicChannel.Id,
+		CreateAt:  10000000,
+	})
+	require.NoError(t, err)
+	require.NoError(t, job.Add(th.BasicUser, post, th.BasicTeam))
 
 	// notifications should not be sent 1s after post was created, because default batch interval is 15mins
-	job.checkPendingNotifications(time.Unix(10001, 0), func(string, []*batchedNotification) {})
-	require.NotNil(t, job.pendingNotifications[th.BasicUser.Id])
-	require.Len(t, job.pendingNotifications[th.BasicUser.Id], 1, "shouldn't have sent queued post")
+	job.checkPendingNotifications(time.Unix(10001, 0), func(string, []*batchedNotification) error { return nil })
+	entries, err := th.store.EmailBatch().GetForUser(th.BasicUser.Id)
+	require.NoError(t, err)
+	require.Len(t, entries, 1, "shouldn't have sent queued post")
 
 	// notifications should be sent 901s after post was created, because default batch interval is 15mins
-	job.checkPendingNotifications(time.Unix(10901, 0), func(string, []*batchedNotification) {})
+	job.checkPendingNotifications(time.Unix(10901, 0), func(string, []*batchedNotification) error { return nil })
+
+	entries, err = th.store.EmailBatch().GetForUser(th.BasicUser.Id)
+	require.NoError(t, err)
+	require.Empty(t, entries, "should have sent queued post")
+}
+
+func TestFlushPendingNotifications(t *testing.T) {
+	th := Setup(t).InitBasic(t)
+
+	job := NewEmailBatchingJob(th.service)
+
+	channelMember, err := th.store.Channel().GetMember(th.Context, th.BasicChannel.Id, th.BasicUser.Id)
+	require.NoError(t, err)
+	channelMember.LastViewedAt = 9999000
+	_, err = th.store.Channel().UpdateMember(th.Context, channelMember)
+	require.NoError(t, err)
+
+	addPost := func(message string, createAt int64) {
+		post, err := th.store.Post().Save(th.Context, &model.Post{
+			UserId:    th.BasicUser2.Id,
+			ChannelId: th.BasicChannel.Id,
+			Message:   message,
+			CreateAt:  createAt,
+		})
+		require.NoError(t, err)
+		require.NoError(t, job.Add(th.BasicUser, post, th.BasicTeam))
+	}
+	requirePending := func(count int) {
+		entries, err := th.store.EmailBatch().GetForUser(th.BasicUser.Id)
+		require.NoError(t, err)
+		require.Len(t, entries, count)
+	}
 
-	require.Nil(t, job.pendingNotifications[th.BasicUser.Id], "should have sent queued post")
+	// test that the notifications are sent without waiting for the interval
+	addPost("post0", 10000000)
+
+	var received []*batchedNotification
+	job.sendPendingUsers(time.Unix(10001, 0), true, func(userID string, notifications []*batchedNotification) error {
+		require.Equal(t, th.BasicUser.Id, userID)
+		received = notifications
+		return nil
+	})
+	require.Len(t, received, 1)
+	require.Equal(t, "post0", received[0].post.Message)
+	requirePending(0)
+
+	// test that the notifications that can't be sent are discarded, since nothing would retry them
+	addPost("post1", 10100000)
+
+	job.sendPendingUsers(time.Unix(10101, 0), true, func(string, []*batchedNotification) error {
+		return errors.New("failure")
+	})
+	requirePending(0)
+
+	// test that stopping a job which isn't running doesn't flush
+	addPost("post2", 10200000)
+	job.stopAndFlush()
+	requirePending(1)
+}
+
+func TestCheckPendingNotificationsAfterLeavingChannel(t *testing.T) {
+	th := Setup(t).InitBasic(t)
+
+	job := NewEmailBatchingJob(th.service)
+
+	channel, err := th.store.Channel().Save(th.Context, &model.Channel{
+		TeamId:      th.BasicTeam.Id,
+		DisplayName: "Left",
+		Name:        "left-" + model.NewId(),
+		Type:        model.ChannelTypeOpen,
+	}, -1)
+	require.NoError(t, err)
+	_, err = th.store.Channel().SaveMember(th.Context, &model.ChannelMember{
+		ChannelId:   channel.Id,
+		UserId:      th.BasicUser.Id,
+		NotifyProps: model.GetDefaultChannelNotifyProps(),
+	})
+	require.NoError(t, err)
+
+	post, err := th.store.Post().Save(th.Context, &model.Post{
+		UserId:    th.BasicUser2.Id,
+		ChannelId: channel.Id,
+		CreateAt:  10000000,
+	})
+	require.NoError(t, err)
+	require.NoError(t, job.Add(th.BasicUser, post, th.BasicTeam))
+
+	// the entry isn't removed with the membership, as it would be by the app
+	require.NoError(t, th.store.Channel().RemoveMember(th.Context, channel.Id, th.BasicUser.Id))
+
+	job.checkPendingNotifications(time.Unix(20000, 0), func(string, []*batchedNotification) error {
+		require.Fail(t, "the posts of a channel the user left should not be sent")
+		return nil
+	})
+
+	entries, err := th.store.EmailBatch().GetForUser(th.BasicUser.Id)
+	require.NoError(t, err)
+	require.Empty(t, entries)
 }
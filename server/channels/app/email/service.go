@@ -42,8 +42,9 @@ func condenseSiteURL(siteURL string) string {
 }
 
 type Service struct {
-	config  func() *model.Config
-	license func() *model.License
+	config     func() *model.Config
+	license    func() *model.License
+	isLeaderFn func() bool
 
 	userService *users.UserService
 	store       store.Store
@@ -57,6 +58,9 @@ type Service struct {
 type ServiceConfig struct {
 	ConfigFn  func() *model.Config
 	LicenseFn func() *model.License
+	// IsLeaderFn reports whether this node is the cluster leader, which sends the batched
+	// emails. Without it, the node is assumed to be the only one.
+	IsLeaderFn func() bool
 
 	TemplatesContainer *templates.Container
 	UserService        *users.UserService
@@ -73,6 +77,7 @@ func NewService(config ServiceConfig) (*Service, error) {
 		license:            config.LicenseFn,
 		store:              config.Store,
 		userService:        config.UserService,
+		isLeaderFn:         config.IsLeaderFn,
 	}
 	if err := service.setUpRateLimiters(); err != nil {
 		return nil, err
@@ -88,6 +93,10 @@ func (es *Service) Stop() {
 	}
 }
 
+func (es *Service) isLeader() bool {
+	return es.isLeaderFn == nil || es.isLeaderFn()
+}
+
 func (c *ServiceConfig) validate() error {
 	if c.ConfigFn == nil || c.Store == nil || c.LicenseFn == nil || c.TemplatesContainer == nil {
 		return errors.New("invalid service config")
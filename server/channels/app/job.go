@@ -159,6 +159,8 @@ func (a *App) SessionHasPermissionToCreateJob(session model.Session, job *model.
 		model.JobTypeExportProcess,
 		model.JobTypeExportDelete,
 		model.JobTypeCloud,
+		model.JobTypeEmbeddedSearchIndexing,
+		model.JobTypeFileEncryptionKeyRotation,
 		model.JobTypeExtractContent:
 		return a.SessionHasPermissionTo(session, model.PermissionManageJobs), model.PermissionManageJobs
 	case model.JobTypeAccessControlSync:
@@ -210,6 +212,9 @@ func (a *App) SessionHasPermissionToManageJob(session model.Session, job *model.
 		model.JobTypeExportProcess,
 		model.JobTypeExportDelete,
 		model.JobTypeCloud,
+		model.JobTypeReadCursorOutbox,
+		model.JobTypeEmbeddedSearchIndexing,
+		model.JobTypeFileEncryptionKeyRotation,
 		model.JobTypeExtractContent:
 		permission = model.PermissionManageJobs
 	case model.JobTypeAccessControlSync:
@@ -247,6 +252,9 @@ func (a *App) SessionHasPermissionToReadJob(session model.Session, jobType strin
 		model.JobTypeExportDelete,
 		model.JobTypeCloud,
 		model.JobTypeMobileSessionMetadata,
+		model.JobTypeReadCursorOutbox,
+		model.JobTypeEmbeddedSearchIndexing,
+		model.JobTypeFileEncryptionKeyRotation,
 		model.JobTypeExtractContent:
 		return a.SessionHasPermissionTo(session, model.PermissionReadJobs), model.PermissionReadJobs
 	case model.JobTypeAccessControlSync:
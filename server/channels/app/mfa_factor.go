@@ -0,0 +1,309 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/mfa"
+)
+
+// mfaTokenRequiredErrorId is returned when the MFA token of a user with MFA active is missing
+// or can't be checked. Clients rely on it to prompt for the second factor.
+const mfaTokenRequiredErrorId = "mfa.validate_token.authenticate.app_error"
+
+// webAuthnCeremonyData is the server side state of a WebAuthn ceremony, kept in a token
+// until the client sends the authenticator response.
+type webAuthnCeremonyData struct {
+	UserId  string          `json:"user_id"`
+	Session json.RawMessage `json:"session"`
+}
+
+func (a *App) newWebAuthn() (*mfa.WebAuthn, *model.AppError) {
+	w, err := mfa.NewWebAuthn(a.Srv().Store().MfaFactor(), a.GetSiteURL(), *a.Config().TeamSettings.SiteName)
+	if err != nil {
+		return nil, model.NewAppError("newWebAuthn", "app.mfa_factor.webauthn.site_url.app_error", nil, "", http.StatusNotImplemented).Wrap(err)
+	}
+	return w, nil
+}
+
+func (a *App) saveWebAuthnCeremony(tokenType, userID string, options, session []byte) (*model.WebAuthnCeremony, *model.AppError) {
+	extra, err := json.Marshal(webAuthnCeremonyData{UserId: userID, Session: session})
+	if err != nil {
+		return nil, model.NewAppError("saveWebAuthnCeremony", "app.mfa_factor.webauthn.save_ceremony.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	token := model.NewToken(tokenType, string(extra))
+	if err := a.Srv().Store().Token().Save(token); err != nil {
+		return nil, model.NewAppError("saveWebAuthnCeremony", "app.mfa_factor.webauthn.save_ceremony.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return &model.WebAuthnCeremony{SessionId: token.Token, Options: options}, nil
+}
+
+// consumeWebAuthnCeremony returns the session of a ceremony started for the user, which can
+// only be answered once. It returns nil if there is no such ceremony.
+func (a *App) consumeWebAuthnCeremony(tokenType, sessionID, userID string) ([]byte, error) {
+	token, err := a.Srv().Store().Token().ConsumeOnce(tokenType, sessionID)
+	if err != nil {
+		var nfErr *store.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if token.IsExpired() {
+		return nil, nil
+	}
+
+	var data webAuthnCeremonyData
+	if err := json.Unmarshal([]byte(token.Extra), &data); err != nil {
+		return nil, err
+	}
+
+	if data.UserId != userID {
+		return nil, nil
+	}
+
+	return data.Session, nil
+}
+
+func (a *App) checkUserCanManageMfa(where string, user *model.User) *model.AppError {
+	if user.AuthService != "" && user.AuthService != model.UserAuthServiceLdap {
+		return model.NewAppError(where, "api.user.activate_mfa.email_and_ldap_only.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if !*a.Config().ServiceSettings.EnableMultifactorAuthentication {
+		return model.NewAppError(where, "mfa.mfa_disabled.app_error", nil, "", http.StatusNotImplemented)
+	}
+
+	return nil
+}
+
+// GetMfaFactors returns the second factors configured for the user.
+func (a *App) GetMfaFactors(userID string) (*model.MfaFactors, *model.AppError) {
+	user, appErr := a.GetUser(userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	credentials, err := a.Srv().Store().MfaFactor().GetWebAuthnCredentials(userID)
+	if err != nil {
+		return nil, model.NewAppError("GetMfaFactors", "app.mfa_factor.get_webauthn_credentials.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	count, err := a.Srv().Store().MfaFactor().CountRecoveryCodes(userID)
+	if err != nil {
+		return nil, model.NewAppError("GetMfaFactors", "app.mfa_factor.count_recovery_codes.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return &model.MfaFactors{
+		MfaActive:              user.MfaActive,
+		TotpActive:             user.MfaActive && user.MfaSecret != "",
+		WebAuthnCredentials:    credentials,
+		RecoveryCodesRemaining: count,
+	}, nil
+}
+
+// BeginWebAuthnRegistration starts the registration of a new WebAuthn credential for the user.
+func (a *App) BeginWebAuthnRegistration(userID string) (*model.WebAuthnCeremony, *model.AppError) {
+	user, appErr := a.GetUser(userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if appErr = a.checkUserCanManageMfa("BeginWebAuthnRegistration", user); appErr != nil {
+		return nil, appErr
+	}
+
+	w, appErr := a.newWebAuthn()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	options, session, err := w.BeginRegistration(user)
+	if err != nil {
+		return nil, model.NewAppError("BeginWebAuthnRegistration", "app.mfa_factor.webauthn.begin_registration.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return a.saveWebAuthnCeremony(model.TokenTypeWebAuthnRegistration, user.Id, options, session)
+}
+
+// FinishWebAuthnRegistration verifies the authenticator response to a registration and stores
+// the new credential. Registering the first factor of a user activates MFA.
+func (a *App) FinishWebAuthnRegistration(rctx request.CTX, userID string, registration *model.WebAuthnRegistration) (*model.WebAuthnCredential, *model.AppError) {
+	user, appErr := a.GetUser(userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if appErr = a.checkUserCanManageMfa("FinishWebAuthnRegistration", user); appErr != nil {
+		return nil, appErr
+	}
+
+	session, err := a.consumeWebAuthnCeremony(model.TokenTypeWebAuthnRegistration, registration.SessionId, user.Id)
+	if err != nil {
+		return nil, model.NewAppError("FinishWebAuthnRegistration", "app.mfa_factor.webauthn.get_ceremony.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+	if session == nil {
+		return nil, model.NewAppError("FinishWebAuthnRegistration", "app.mfa_factor.webauthn.invalid_session.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	w, appErr := a.newWebAuthn()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	credential, err := w.FinishRegistration(user, session, registration.Name, registration.Credential)
+	if err != nil {
+		var invalidErr *model.AppError
+		var leErr *store.ErrLimitExceeded
+		var cErr *store.ErrConflict
+		switch {
+		case errors.Is(err, mfa.InvalidWebAuthnResponse):
+			return nil, model.NewAppError("FinishWebAuthnRegistration", "app.mfa_factor.webauthn.invalid_response.app_error", nil, "", http.StatusBadRequest).Wrap(err)
+		case errors.As(err, &invalidErr):
+			return nil, invalidErr
+		case errors.As(err, &leErr):
+			return nil, model.NewAppError("FinishWebAuthnRegistration", "app.mfa_factor.webauthn.limit_exceeded.app_error", map[string]any{"Max": model.MaxWebAuthnCredentialsPerUser}, "", http.StatusBadRequest).Wrap(err)
+		case errors.As(err, &cErr):
+			return nil, model.NewAppError("FinishWebAuthnRegistration", "app.mfa_factor.webauthn.already_registered.app_error", nil, "", http.StatusBadRequest).Wrap(err)
+		default:
+			return nil, model.NewAppError("FinishWebAuthnRegistration", "app.mfa_factor.webauthn.save.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+	}
+
+	if !user.MfaActive {
+		if err := a.Srv().Store().User().UpdateMfaActive(user.Id, true); err != nil {
+			return nil, model.NewAppError("FinishWebAuthnRegistration", "mfa.activate.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+
+		// Make sure old MFA status is not cached locally or in cluster nodes.
+		a.InvalidateCacheForUser(user.Id)
+		a.sendMfaChangeEmail(rctx, user.Id, true)
+	}
+
+	return credential, nil
+}
+
+// DeleteWebAuthnCredential revokes a WebAuthn credential of the user. MFA is deactivated when
+// the user has no other factor left.
+func (a *App) DeleteWebAuthnCredential(rctx request.CTX, userID, credentialID string) *model.AppError {
+	user, appErr := a.GetUser(userID)
+	if appErr != nil {
+		return appErr
+	}
+
+	if err := a.Srv().Store().MfaFactor().DeleteWebAuthnCredential(user.Id, credentialID); err != nil {
+		var nfErr *store.ErrNotFound
+		if errors.As(err, &nfErr) {
+			return model.NewAppError("DeleteWebAuthnCredential", "app.mfa_factor.webauthn.not_found.app_error", nil, "", http.StatusNotFound).Wrap(err)
+		}
+		return model.NewAppError("DeleteWebAuthnCredential", "app.mfa_factor.webauthn.delete.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	if !user.MfaActive || user.MfaSecret != "" {
+		return nil
+	}
+
+	credentials, err := a.Srv().Store().MfaFactor().GetWebAuthnCredentials(user.Id)
+	if err != nil {
+		return model.NewAppError("DeleteWebAuthnCredential", "app.mfa_factor.get_webauthn_credentials.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	if len(credentials) == 0 {
+		return a.UpdateMfa(rctx, false, user.Id, "")
+	}
+
+	return nil
+}
+
+// GenerateMfaRecoveryCodes replaces the recovery codes of a user with MFA active.
+func (a *App) GenerateMfaRecoveryCodes(userID string) (*model.MfaRecoveryCodes, *model.AppError) {
+	user, appErr := a.GetUser(userID)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if appErr = a.checkUserCanManageMfa("GenerateMfaRecoveryCodes", user); appErr != nil {
+		return nil, appErr
+	}
+
+	if !user.MfaActive {
+		return nil, model.NewAppError("GenerateMfaRecoveryCodes", "app.mfa_factor.recovery_codes.mfa_inactive.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	codes, err := mfa.NewRecoveryCodes(a.Srv().Store().MfaFactor()).Generate(user.Id)
+	if err != nil {
+		return nil, model.NewAppError("GenerateMfaRecoveryCodes", "app.mfa_factor.recovery_codes.generate.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return &model.MfaRecoveryCodes{Codes: codes}, nil
+}
+
+// BeginWebAuthnLogin checks the credentials of a user logging in with a WebAuthn credential as
+// second factor, and starts the verification of one of their authenticators. The assertion is
+// then sent, JSON encoded, as the MFA token of the login.
+func (a *App) BeginWebAuthnLogin(rctx request.CTX, id, loginId, password string) (*model.WebAuthnCeremony, *model.AppError) {
+	if password == "" {
+		return nil, model.NewAppError("BeginWebAuthnLogin", "api.user.login.blank_pwd.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	user, appErr := a.GetUserForLogin(rctx, id, loginId)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	// As in a login pre-flight, a user with valid credentials and MFA active fails on the
+	// missing MFA token only.
+	user, appErr = a.authenticateUser(rctx, user, password, "")
+	if appErr == nil {
+		return nil, model.NewAppError("BeginWebAuthnLogin", "app.mfa_factor.webauthn.not_registered.app_error", nil, "", http.StatusBadRequest)
+	}
+	if appErr.Id != mfaTokenRequiredErrorId {
+		return nil, appErr
+	}
+
+	w, appErr := a.newWebAuthn()
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	options, session, err := w.BeginLogin(user)
+	if err != nil {
+		if errors.Is(err, mfa.NoWebAuthnCredentials) {
+			return nil, model.NewAppError("BeginWebAuthnLogin", "app.mfa_factor.webauthn.not_registered.app_error", nil, "", http.StatusBadRequest)
+		}
+		return nil, model.NewAppError("BeginWebAuthnLogin", "app.mfa_factor.webauthn.begin_login.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return a.saveWebAuthnCeremony(model.TokenTypeWebAuthnLogin, user.Id, options, session)
+}
+
+// validateWebAuthnAssertion checks an MFA token holding the answer to a login started with
+// BeginWebAuthnLogin.
+func (a *App) validateWebAuthnAssertion(user *model.User, token string) (bool, error) {
+	var assertion model.WebAuthnAssertion
+	if err := json.Unmarshal([]byte(token), &assertion); err != nil {
+		return false, nil
+	}
+
+	session, err := a.consumeWebAuthnCeremony(model.TokenTypeWebAuthnLogin, assertion.SessionId, user.Id)
+	if err != nil || session == nil {
+		return false, err
+	}
+
+	w, appErr := a.newWebAuthn()
+	if appErr != nil {
+		return false, appErr
+	}
+
+	return w.ValidateLogin(user, session, assertion.Credential)
+}
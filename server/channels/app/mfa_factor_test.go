@@ -0,0 +1,282 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/dgryski/dgoogauth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func saveTestWebAuthnCredential(t *testing.T, th *TestHelper, userID string) *model.WebAuthnCredential {
+	t.Helper()
+
+	credential, err := th.App.Srv().Store().MfaFactor().SaveWebAuthnCredential(&model.WebAuthnCredential{
+		UserId:       userID,
+		Name:         "Security key",
+		CredentialId: model.NewId(),
+		Credential:   `{"id":"` + model.NewId() + `"}`,
+	})
+	require.NoError(t, err)
+	return credential
+}
+
+func TestGetMfaFactors(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	factors, appErr := th.App.GetMfaFactors(th.BasicUser.Id)
+	require.Nil(t, appErr)
+	assert.False(t, factors.MfaActive)
+	assert.False(t, factors.TotpActive)
+	assert.Empty(t, factors.WebAuthnCredentials)
+	assert.Zero(t, factors.RecoveryCodesRemaining)
+
+	credential := saveTestWebAuthnCredential(t, th, th.BasicUser.Id)
+	require.NoError(t, th.App.Srv().Store().User().UpdateMfaActive(th.BasicUser.Id, true))
+	th.App.InvalidateCacheForUser(th.BasicUser.Id)
+	th.App.UpdateConfig(func(cfg *model.Config) {
+		*cfg.ServiceSettings.EnableMultifactorAuthentication = true
+	})
+	_, appErr = th.App.GenerateMfaRecoveryCodes(th.BasicUser.Id)
+	require.Nil(t, appErr)
+
+	factors, appErr = th.App.GetMfaFactors(th.BasicUser.Id)
+	require.Nil(t, appErr)
+	assert.True(t, factors.MfaActive)
+	assert.False(t, factors.TotpActive)
+	require.Len(t, factors.WebAuthnCredentials, 1)
+	assert.Equal(t, credential.Id, factors.WebAuthnCredentials[0].Id)
+	assert.Equal(t, int64(model.MfaRecoveryCodeCount), factors.RecoveryCodesRemaining)
+
+	t.Run("the credential record is not serialized", func(t *testing.T) {
+		b, err := json.Marshal(factors)
+		require.NoError(t, err)
+		assert.NotContains(t, string(b), credential.Credential)
+	})
+}
+
+func TestBeginWebAuthnRegistration(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	t.Run("MFA is disabled", func(t *testing.T) {
+		th.App.UpdateConfig(func(cfg *model.Config) {
+			*cfg.ServiceSettings.EnableMultifactorAuthentication = false
+		})
+
+		_, appErr := th.App.BeginWebAuthnRegistration(th.BasicUser.Id)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "mfa.mfa_disabled.app_error", appErr.Id)
+	})
+
+	th.App.UpdateConfig(func(cfg *model.Config) {
+		*cfg.ServiceSettings.EnableMultifactorAuthentication = true
+		*cfg.ServiceSettings.SiteURL = "http://localhost:8065"
+	})
+
+	ceremony, appErr := th.App.BeginWebAuthnRegistration(th.BasicUser.Id)
+	require.Nil(t, appErr)
+	require.NotEmpty(t, ceremony.SessionId)
+	assert.Contains(t, string(ceremony.Options), `"rp":`)
+
+	t.Run("the ceremony can only be finished by the same user", func(t *testing.T) {
+		_, appErr := th.App.FinishWebAuthnRegistration(th.Context, th.BasicUser2.Id, &model.WebAuthnRegistration{
+			SessionId:  ceremony.SessionId,
+			Name:       "Security key",
+			Credential: json.RawMessage(`{}`),
+		})
+		require.NotNil(t, appErr)
+		assert.Equal(t, "app.mfa_factor.webauthn.invalid_session.app_error", appErr.Id)
+	})
+
+	t.Run("the ceremony can only be answered once", func(t *testing.T) {
+		_, appErr := th.App.FinishWebAuthnRegistration(th.Context, th.BasicUser.Id, &model.WebAuthnRegistration{
+			SessionId:  ceremony.SessionId,
+			Name:       "Security key",
+			Credential: json.RawMessage(`{}`),
+		})
+		require.NotNil(t, appErr)
+		assert.Equal(t, "app.mfa_factor.webauthn.invalid_session.app_error", appErr.Id)
+	})
+
+	t.Run("invalid authenticator response", func(t *testing.T) {
+		ceremony, appErr := th.App.BeginWebAuthnRegistration(th.BasicUser.Id)
+		require.Nil(t, appErr)
+
+		_, appErr = th.App.FinishWebAuthnRegistration(th.Context, th.BasicUser.Id, &model.WebAuthnRegistration{
+			SessionId:  ceremony.SessionId,
+			Name:       "Security key",
+			Credential: json.RawMessage(`{}`),
+		})
+		require.NotNil(t, appErr)
+		assert.Equal(t, "app.mfa_factor.webauthn.invalid_response.app_error", appErr.Id)
+	})
+}
+
+func TestDeleteWebAuthnCredential(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	th.App.UpdateConfig(func(cfg *model.Config) {
+		*cfg.ServiceSettings.EnableMultifactorAuthentication = true
+	})
+
+	first := saveTestWebAuthnCredential(t, th, th.BasicUser.Id)
+	second := saveTestWebAuthnCredential(t, th, th.BasicUser.Id)
+	require.NoError(t, th.App.Srv().Store().User().UpdateMfaActive(th.BasicUser.Id, true))
+	th.App.InvalidateCacheForUser(th.BasicUser.Id)
+
+	t.Run("another user's credential", func(t *testing.T) {
+		appErr := th.App.DeleteWebAuthnCredential(th.Context, th.BasicUser2.Id, first.Id)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "app.mfa_factor.webauthn.not_found.app_error", appErr.Id)
+	})
+
+	appErr := th.App.DeleteWebAuthnCredential(th.Context, th.BasicUser.Id, first.Id)
+	require.Nil(t, appErr)
+
+	user, appErr := th.App.GetUser(th.BasicUser.Id)
+	require.Nil(t, appErr)
+	assert.True(t, user.MfaActive, "MFA stays active while a factor is left")
+
+	appErr = th.App.DeleteWebAuthnCredential(th.Context, th.BasicUser.Id, second.Id)
+	require.Nil(t, appErr)
+
+	user, appErr = th.App.GetUser(th.BasicUser.Id)
+	require.Nil(t, appErr)
+	assert.False(t, user.MfaActive, "MFA is deactivated with the last factor")
+}
+
+func TestGenerateMfaRecoveryCodes(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	th.App.UpdateConfig(func(cfg *model.Config) {
+		*cfg.ServiceSettings.EnableMultifactorAuthentication = true
+	})
+
+	t.Run("MFA is inactive", func(t *testing.T) {
+		_, appErr := th.App.GenerateMfaRecoveryCodes(th.BasicUser.Id)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "app.mfa_factor.recovery_codes.mfa_inactive.app_error", appErr.Id)
+	})
+
+	require.NoError(t, th.App.Srv().Store().User().UpdateMfaActive(th.BasicUser.Id, true))
+	th.App.InvalidateCacheForUser(th.BasicUser.Id)
+
+	codes, appErr := th.App.GenerateMfaRecoveryCodes(th.BasicUser.Id)
+	require.Nil(t, appErr)
+	require.Len(t, codes.Codes, model.MfaRecoveryCodeCount)
+
+	user, appErr := th.App.GetUser(th.BasicUser.Id)
+	require.Nil(t, appErr)
+
+	t.Run("a recovery code is accepted once as MFA token", func(t *testing.T) {
+		appErr := th.App.CheckUserMfa(th.Context, user, codes.Codes[0])
+		require.Nil(t, appErr)
+
+		appErr = th.App.CheckUserMfa(th.Context, user, codes.Codes[0])
+		require.NotNil(t, appErr)
+		assert.Equal(t, "api.user.check_user_mfa.bad_code.app_error", appErr.Id)
+	})
+
+	t.Run("new codes replace the previous ones", func(t *testing.T) {
+		_, appErr := th.App.GenerateMfaRecoveryCodes(th.BasicUser.Id)
+		require.Nil(t, appErr)
+
+		appErr = th.App.CheckUserMfa(th.Context, user, codes.Codes[1])
+		require.NotNil(t, appErr)
+		assert.Equal(t, "api.user.check_user_mfa.bad_code.app_error", appErr.Id)
+	})
+
+	t.Run("deactivating MFA removes the codes", func(t *testing.T) {
+		appErr := th.App.DeactivateMfa(th.BasicUser.Id)
+		require.Nil(t, appErr)
+
+		count, err := th.App.Srv().Store().MfaFactor().CountRecoveryCodes(th.BasicUser.Id)
+		require.NoError(t, err)
+		assert.Zero(t, count)
+	})
+}
+
+func TestBeginWebAuthnLogin(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	th.App.UpdateConfig(func(cfg *model.Config) {
+		*cfg.ServiceSettings.EnableMultifactorAuthentication = true
+		*cfg.ServiceSettings.SiteURL = "http://localhost:8065"
+	})
+
+	password := "newpassword1"
+	appErr := th.App.UpdatePassword(th.Context, th.BasicUser, password)
+	require.Nil(t, appErr)
+
+	t.Run("MFA is inactive", func(t *testing.T) {
+		_, appErr := th.App.BeginWebAuthnLogin(th.Context, "", th.BasicUser.Email, password)
+		require.NotNil(t, appErr)
+		assert.Equal(t, "app.mfa_factor.webauthn.not_registered.app_error", appErr.Id)
+	})
+
+	saveTestWebAuthnCredential(t, th, th.BasicUser.Id)
+	require.NoError(t, th.App.Srv().Store().User().UpdateMfaActive(th.BasicUser.Id, true))
+	th.App.InvalidateCacheForUser(th.BasicUser.Id)
+
+	t.Run("wrong password", func(t *testing.T) {
+		_, appErr := th.App.BeginWebAuthnLogin(th.Context, "", th.BasicUser.Email, "wrong password")
+		require.NotNil(t, appErr)
+		assert.Equal(t, "api.user.check_user_password.invalid.app_error", appErr.Id)
+	})
+
+	ceremony, appErr := th.App.BeginWebAuthnLogin(th.Context, "", th.BasicUser.Email, password)
+	require.Nil(t, appErr)
+	require.NotEmpty(t, ceremony.SessionId)
+	assert.Contains(t, string(ceremony.Options), `"challenge":`)
+
+	t.Run("an invalid assertion is rejected and consumes the challenge", func(t *testing.T) {
+		user, appErr := th.App.GetUser(th.BasicUser.Id)
+		require.Nil(t, appErr)
+
+		token, err := json.Marshal(model.WebAuthnAssertion{SessionId: ceremony.SessionId, Credential: json.RawMessage(`{}`)})
+		require.NoError(t, err)
+
+		appErr = th.App.CheckUserMfa(th.Context, user, string(token))
+		require.NotNil(t, appErr)
+		assert.Equal(t, "api.user.check_user_mfa.bad_code.app_error", appErr.Id)
+
+		_, err = th.App.Srv().Store().Token().GetByToken(ceremony.SessionId)
+		require.Error(t, err)
+	})
+}
+
+func TestCheckUserMfaWebAuthnOnly(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	th.App.UpdateConfig(func(cfg *model.Config) {
+		*cfg.ServiceSettings.EnableMultifactorAuthentication = true
+	})
+
+	saveTestWebAuthnCredential(t, th, th.BasicUser.Id)
+	require.NoError(t, th.App.Srv().Store().User().UpdateMfaActive(th.BasicUser.Id, true))
+	th.App.InvalidateCacheForUser(th.BasicUser.Id)
+
+	user, appErr := th.App.GetUser(th.BasicUser.Id)
+	require.Nil(t, appErr)
+	require.Empty(t, user.MfaSecret)
+
+	// A TOTP code computed with an empty secret must not be accepted
+	code := fmt.Sprintf("%06d", dgoogauth.ComputeCode("", time.Now().UTC().Unix()/30))
+	appErr = th.App.CheckUserMfa(th.Context, user, code)
+	require.NotNil(t, appErr)
+	assert.Equal(t, "api.user.check_user_mfa.bad_code.app_error", appErr.Id)
+}
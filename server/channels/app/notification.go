@@ -117,6 +117,9 @@ func (a *App) SendNotifications(rctx request.CTX, post *model.Post, team *model.
 	}
 	profileMap := pResult.Data
 
+	// Translate the post before the notifications are built so that they can use the translations
+	a.translateNewPost(rctx, post, profileMap)
+
 	cmnResult := <-cmnchan
 	if cmnResult.NErr != nil {
 		a.CountNotificationReason(model.NotificationStatusError, model.NotificationTypeAll, model.NotificationReasonFetchError, model.NotificationNoPlatform)
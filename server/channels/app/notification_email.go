@@ -96,6 +96,10 @@ func (a *App) buildEmailNotification(
 
 	var messageHTML, messageText string
 	if emailNotificationContentsType == model.EmailNotificationContentsFull {
+		if message := a.postMessageForNotification(rctx, post, user); message != post.Message {
+			post = post.Clone()
+			post.Message = message
+		}
 		messageHTML = a.GetMessageForNotification(post, team.Name, a.GetSiteURL(), translateFunc)
 		messageText = post.Message
 	}
@@ -850,7 +850,7 @@ func (a *App) buildFullPushNotificationMessage(rctx request.CTX, contentsConfig
 		msg.FromWebhook = fw
 	}
 
-	postMessage := post.Message
+	postMessage := a.postMessageForNotification(rctx, post, user)
 	stripped, err := utils.StripMarkdown(postMessage)
 	if err != nil {
 		rctx.Logger().Warn("Failed parse to markdown", mlog.String("post_id", post.Id), mlog.Err(err))
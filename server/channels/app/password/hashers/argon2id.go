@@ -0,0 +1,233 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package hashers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/mattermost/mattermost/server/v8/channels/app/password/phcparser"
+)
+
+const (
+	// Argon2idFunctionId is the name of the Argon2id hasher.
+	Argon2idFunctionId string = "argon2id"
+)
+
+const (
+	// Default parameter values, following the OWASP recommendations:
+	// https://cheatsheetseries.owasp.org/cheatsheets/Password_Storage_Cheat_Sheet.html#argon2id
+	DefaultArgon2idMemory      = 19456
+	DefaultArgon2idIterations  = 2
+	DefaultArgon2idParallelism = 1
+
+	// Length of the resulting hash, in bytes
+	argon2idKeyLength = 32
+)
+
+// argon2idSlots bounds the number of Argon2id hashes computed at once. Each of
+// them allocates the configured memory, so a burst of logins would otherwise
+// allocate it as many times as there are concurrent requests; past the limit,
+// the hashes wait for a slot instead.
+var argon2idSlots = make(chan struct{}, runtime.GOMAXPROCS(0))
+
+// Argon2id implements the [PasswordHasher] interface using
+// golang.org/x/crypto/argon2 as the hashing method. Unlike [PBKDF2], Argon2id is
+// memory-hard, which makes brute-forcing the hashes with GPUs or ASICs costly.
+//
+// It is parametrized by:
+//   - The memory: the amount of memory, in KiB, used during hashing.
+//   - The iterations: the number of passes over the memory.
+//   - The parallelism: the number of threads used during hashing.
+//
+// Its PHC string is of the form:
+//
+//	$argon2id$v=19$m=<M>,t=<T>,p=<P>$<salt>$<hash>
+//
+// Where:
+//   - <M> is an integer specifying the memory in KiB (defaults to 19456).
+//   - <T> is an integer specifying the iterations (defaults to 2).
+//   - <P> is an integer specifying the parallelism (defaults to 1).
+//   - <salt> is the base64-encoded salt.
+//   - <hash> is the base64-encoded hash.
+type Argon2id struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+
+	phcHeader string
+}
+
+// DefaultArgon2id returns an [Argon2id] already initialized with the following
+// parameters:
+//   - Memory: 19456 KiB
+//   - Iterations: 2
+//   - Parallelism: 1
+func DefaultArgon2id() Argon2id {
+	hasher, err := NewArgon2id(DefaultArgon2idMemory, DefaultArgon2idIterations, DefaultArgon2idParallelism)
+	if err != nil {
+		panic("DefaultArgon2id implementation is incorrect")
+	}
+	return hasher
+}
+
+// NewArgon2id returns an [Argon2id] initialized with the provided parameters
+func NewArgon2id(memory int, iterations int, parallelism int) (Argon2id, error) {
+	if iterations <= 0 || int64(iterations) > int64(^uint32(0)) {
+		return Argon2id{}, fmt.Errorf("iterations must be strictly positive")
+	}
+
+	if parallelism <= 0 || parallelism > int(^uint8(0)) {
+		return Argon2id{}, fmt.Errorf("parallelism must be between 1 and %d", ^uint8(0))
+	}
+
+	// Argon2 needs at least 8 KiB per thread
+	if memory < 8*parallelism || int64(memory) > int64(^uint32(0)) {
+		return Argon2id{}, fmt.Errorf("memory must be at least 8 KiB per thread")
+	}
+
+	// Precompute and store the PHC header, since it is common to every hashed
+	// password; it will be something like:
+	// $argon2id$v=19$m=19456,t=2,p=1$
+	phcHeader := new(strings.Builder)
+
+	// First, the function ID and its version
+	phcHeader.WriteRune('$')
+	phcHeader.WriteString(Argon2idFunctionId)
+	phcHeader.WriteString("$v=")
+	phcHeader.WriteString(strconv.Itoa(argon2.Version))
+
+	// Then, the parameters
+	phcHeader.WriteString("$m=")
+	phcHeader.WriteString(strconv.Itoa(memory))
+	phcHeader.WriteString(",t=")
+	phcHeader.WriteString(strconv.Itoa(iterations))
+	phcHeader.WriteString(",p=")
+	phcHeader.WriteString(strconv.Itoa(parallelism))
+
+	// Finish with the '$' that will mark the start of the salt
+	phcHeader.WriteRune('$')
+
+	return Argon2id{
+		memory:      uint32(memory),
+		iterations:  uint32(iterations),
+		parallelism: uint8(parallelism),
+		phcHeader:   phcHeader.String(),
+	}, nil
+}
+
+// NewArgon2idFromPHC returns an [Argon2id] that conforms to the provided parsed
+// PHC, using the same parameters (if valid) present there.
+func NewArgon2idFromPHC(phc phcparser.PHC) (Argon2id, error) {
+	if phc.Version != strconv.Itoa(argon2.Version) {
+		return Argon2id{}, fmt.Errorf("unsupported version 'v=%s'", phc.Version)
+	}
+
+	memory, err := strconv.Atoi(phc.Params["m"])
+	if err != nil {
+		return Argon2id{}, fmt.Errorf("invalid memory parameter 'm=%s'", phc.Params["m"])
+	}
+
+	iterations, err := strconv.Atoi(phc.Params["t"])
+	if err != nil {
+		return Argon2id{}, fmt.Errorf("invalid iterations parameter 't=%s'", phc.Params["t"])
+	}
+
+	parallelism, err := strconv.Atoi(phc.Params["p"])
+	if err != nil {
+		return Argon2id{}, fmt.Errorf("invalid parallelism parameter 'p=%s'", phc.Params["p"])
+	}
+
+	return NewArgon2id(memory, iterations, parallelism)
+}
+
+// hashWithSalt calls golang.org/x/crypto/argon2.IDKey with the provided salt
+// and the stored parameters.
+//
+// At most [runtime.GOMAXPROCS] hashes are computed at once, see argon2idSlots.
+func (a Argon2id) hashWithSalt(password string, salt []byte) string {
+	argon2idSlots <- struct{}{}
+	defer func() { <-argon2idSlots }()
+
+	hash := argon2.IDKey([]byte(password), salt, a.iterations, a.memory, a.parallelism, argon2idKeyLength)
+	return base64.RawStdEncoding.EncodeToString(hash)
+}
+
+// Hash hashes the provided password using the Argon2id algorithm with the
+// stored parameters, returning a PHC-compliant string.
+//
+// The salt is generated randomly and stored in the returned PHC string. If the
+// provided password is longer than [PasswordMaxLengthBytes], [ErrPasswordTooLong]
+// is returned.
+func (a Argon2id) Hash(password string) (string, error) {
+	if len(password) > PasswordMaxLengthBytes {
+		return "", ErrPasswordTooLong
+	}
+
+	// Create random salt
+	salt := make([]byte, saltLenBytes)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", fmt.Errorf("unable to generate salt for user: %w", err)
+	}
+
+	phcString := new(strings.Builder)
+	phcString.WriteString(a.phcHeader)
+	phcString.WriteString(base64.RawStdEncoding.EncodeToString(salt))
+	phcString.WriteRune('$')
+	phcString.WriteString(a.hashWithSalt(password, salt))
+
+	return phcString.String(), nil
+}
+
+// CompareHashAndPassword compares the provided [phcparser.PHC] with the plain-text
+// password.
+//
+// The provided [phcparser.PHC] is validated to double-check it was generated with
+// this hasher and parameters.
+func (a Argon2id) CompareHashAndPassword(hash phcparser.PHC, password string) error {
+	// Validate parameters
+	if !a.IsPHCValid(hash) {
+		return fmt.Errorf("the stored password does not comply with the Argon2id parser's PHC serialization")
+	}
+
+	// Passwords that could not have been hashed are not worth the work
+	if len(password) > PasswordMaxLengthBytes {
+		return ErrMismatchedHashAndPassword
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(hash.Salt)
+	if err != nil {
+		return fmt.Errorf("failed decoding hash's salt: %w", err)
+	}
+
+	// Hash the new password with the stored hash's salt, and compare both hashes
+	newHash := a.hashWithSalt(password, salt)
+	if subtle.ConstantTimeCompare([]byte(hash.Hash), []byte(newHash)) != 1 {
+		return ErrMismatchedHashAndPassword
+	}
+
+	return nil
+}
+
+// IsPHCValid validates that the provided [phcparser.PHC] is valid, meaning:
+//   - The function used to generate it was [Argon2idFunctionId], in the version
+//     implemented by golang.org/x/crypto/argon2.
+//   - The parameters used to generate it were the same as the ones used to
+//     create this hasher.
+func (a Argon2id) IsPHCValid(phc phcparser.PHC) bool {
+	return phc.Id == Argon2idFunctionId &&
+		phc.Version == strconv.Itoa(argon2.Version) &&
+		len(phc.Params) == 3 &&
+		phc.Params["m"] == strconv.FormatUint(uint64(a.memory), 10) &&
+		phc.Params["t"] == strconv.FormatUint(uint64(a.iterations), 10) &&
+		phc.Params["p"] == strconv.FormatUint(uint64(a.parallelism), 10)
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package hashers
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/mattermost/mattermost/server/v8/channels/app/password/phcparser"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgon2idHash(t *testing.T) {
+	password := "^a v3ery c0mp_ex Passw∙rd$"
+
+	hasher, err := NewArgon2id(65536, 3, 4)
+	require.NoError(t, err)
+
+	str, err := hasher.Hash(password)
+	require.NoError(t, err)
+
+	phc, err := phcparser.New(strings.NewReader(str)).Parse()
+	require.NoError(t, err)
+	require.Equal(t, "argon2id", phc.Id)
+	require.Equal(t, "19", phc.Version)
+	require.Equal(t, map[string]string{
+		"m": "65536",
+		"t": "3",
+		"p": "4",
+	}, phc.Params)
+
+	salt, err := base64.RawStdEncoding.DecodeString(phc.Salt)
+	require.NoError(t, err)
+
+	hash := argon2.IDKey([]byte(password), salt, 3, 65536, 4, 32)
+
+	expectedHash := base64.RawStdEncoding.EncodeToString(hash)
+	require.Equal(t, expectedHash, phc.Hash)
+
+	t.Run("passwords longer than bcrypt's limit", func(t *testing.T) {
+		_, err := hasher.Hash(strings.Repeat("1234567890", 20))
+		require.NoError(t, err)
+
+		_, err = hasher.Hash(strings.Repeat("1234567890", 26))
+		require.ErrorIs(t, err, ErrPasswordTooLong)
+	})
+}
+
+func TestNewArgon2id(t *testing.T) {
+	testCases := []struct {
+		testName    string
+		memory      int
+		iterations  int
+		parallelism int
+		expectedErr bool
+	}{
+		{"valid parameters", 19456, 2, 1, false},
+		{"zero iterations", 19456, 0, 1, true},
+		{"zero parallelism", 19456, 2, 0, true},
+		{"too much parallelism", 19456, 2, 256, true},
+		{"too little memory for the parallelism", 31, 2, 4, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			_, err := NewArgon2id(tc.memory, tc.iterations, tc.parallelism)
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestArgon2idCompareHashAndPassword(t *testing.T) {
+	testCases := []struct {
+		testName    string
+		storedPwd   string
+		inputPwd    string
+		expectedErr error
+	}{
+		{
+			"empty password",
+			"",
+			"",
+			nil,
+		},
+		{
+			"same password",
+			"one password",
+			"one password",
+			nil,
+		},
+		{
+			"different password",
+			"one password",
+			"another password",
+			ErrMismatchedHashAndPassword,
+		},
+	}
+
+	hasher := DefaultArgon2id()
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			storedPHCStr, err := hasher.Hash(tc.storedPwd)
+			require.NoError(t, err)
+
+			storedPHC, err := phcparser.New(strings.NewReader(storedPHCStr)).Parse()
+			require.NoError(t, err)
+
+			err = hasher.CompareHashAndPassword(storedPHC, tc.inputPwd)
+			if tc.expectedErr != nil {
+				require.ErrorIs(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("hash with other parameters", func(t *testing.T) {
+		other, err := NewArgon2id(DefaultArgon2idMemory, DefaultArgon2idIterations+1, DefaultArgon2idParallelism)
+		require.NoError(t, err)
+
+		storedPHCStr, err := other.Hash("one password")
+		require.NoError(t, err)
+
+		storedPHC, err := phcparser.New(strings.NewReader(storedPHCStr)).Parse()
+		require.NoError(t, err)
+
+		require.Error(t, hasher.CompareHashAndPassword(storedPHC, "one password"))
+	})
+}
+
+func TestArgon2idConcurrencyLimit(t *testing.T) {
+	hasher, err := NewArgon2id(64, 1, 1)
+	require.NoError(t, err)
+
+	// Take every slot, so that no hash can be computed
+	for range cap(argon2idSlots) {
+		argon2idSlots <- struct{}{}
+	}
+
+	var hashErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, hashErr = hasher.Hash("password")
+	}()
+
+	select {
+	case <-done:
+		require.Fail(t, "the hash should wait for a slot")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	for range cap(argon2idSlots) {
+		<-argon2idSlots
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the hash should be computed once a slot is free")
+	}
+	require.NoError(t, hashErr)
+}
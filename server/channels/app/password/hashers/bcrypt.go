@@ -50,12 +50,16 @@ func NewBCrypt() BCrypt {
 
 // Hash is a wrapper over golang.org/x/crypto/bcrypt.GenerateFromPassword, with
 // two main differences:
-//   - If the password is too long, it returns [ErrPasswordTooLong] instead of
-//     bcrypt.ErrPasswordTooLong, in order to comply with the rest of the hashers
-//     in this package.
+//   - If the password is longer than [BCryptMaxLengthBytes], it returns
+//     [ErrPasswordTooLong] instead of bcrypt.ErrPasswordTooLong, in order to
+//     comply with the rest of the hashers in this package.
 //   - It returns a string instead of a byte slice, so that [BCrypt] implements
 //     the [PasswordHasher] interface.
 func (b BCrypt) Hash(password string) (string, error) {
+	if len(password) > BCryptMaxLengthBytes {
+		return "", ErrPasswordTooLong
+	}
+
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), BCryptCost)
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrPasswordTooLong) {
@@ -72,7 +76,14 @@ func (b BCrypt) Hash(password string) (string, error) {
 // as the input for its first argument: this is why [BCrypt] is an edge case for
 // a [PasswordHasher]: it only uses the [PHC.Hash] field, and ignores anything
 // else in there.
+//
+// Passwords longer than [BCryptMaxLengthBytes] never match, instead of being
+// compared by their first [BCryptMaxLengthBytes] bytes only.
 func (b BCrypt) CompareHashAndPassword(hash phcparser.PHC, password string) error {
+	if len(password) > BCryptMaxLengthBytes {
+		return ErrMismatchedHashAndPassword
+	}
+
 	err := bcrypt.CompareHashAndPassword([]byte(hash.Hash), []byte(password))
 	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
 		return ErrMismatchedHashAndPassword
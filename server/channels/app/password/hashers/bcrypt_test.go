@@ -29,6 +29,11 @@ func TestBCryptHash(t *testing.T) {
 			pwd:         strings.Repeat("verylong", 72),
 			expectedErr: ErrPasswordTooLong,
 		},
+		{
+			testName:    "password longer than bcrypt supports",
+			pwd:         strings.Repeat("a", BCryptMaxLengthBytes+1),
+			expectedErr: ErrPasswordTooLong,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -68,6 +73,12 @@ func TestBCryptCompareHashAndPassword(t *testing.T) {
 			"another password",
 			ErrMismatchedHashAndPassword,
 		},
+		{
+			"longer password with the same first 72 bytes",
+			strings.Repeat("a", BCryptMaxLengthBytes),
+			strings.Repeat("a", BCryptMaxLengthBytes) + "b",
+			ErrMismatchedHashAndPassword,
+		},
 	}
 
 	hasher := NewBCrypt()
@@ -3,30 +3,23 @@
 
 // Package hashers provides several implementations of password hashing functions.
 //
-// This package allows for seamless migrations of password hashing methods. To
-// migrate to a new hasher in the future, the steps needed are:
+// This package allows for seamless migrations of password hashing methods. The
+// hasher used for new passwords is chosen by the caller, usually from the
+// configuration, and [Hash] uses the latest hasher, [DefaultArgon2id], when
+// there is none to choose from. To add a new hashing method, the steps needed
+// are:
 //  1. Add a new type that implements the [PasswordHasher] interface. Let's call
 //     it `NewHasher`.
-//  2. Update the [latestHasher] variable so that it points to a `NewHasher`
-//     instance:
-//
-// ``` diff
-// var (
-//
-//	// latestHasher is the hasher currently in use.
-//	// Any password hashed with a different hasher must be migrated to this one.
-//
-// -	latestHasher PasswordHasher = DefaultPBKDF2()
-// +	latestHasher PasswordHasher = DefaultNewHasher()
-// ```
+//  2. Allow configuring it, so that callers can hash new passwords with a
+//     `NewHasher` instance.
 //  3. Modify [GetHasherFromPHCString] to add a new case in the switch to
 //     identify the new function ID.
 //
 // If what is needed is to upgrade to a new set of parameters for the same
-// hashing method (let's say keep using PBKDF2 but increase the work factor
-// from 60,000 to 120,000), then no modification to [GetHasherFromPHCString]
-// is needed. Simply update the [latestHasher] varible with the new parameter,
-// and [IsPHCValid] will detect the difference in the parameter.
+// hashing method (let's say keep using Argon2id but increase the memory from
+// 19 MiB to 64 MiB), then no modification to [GetHasherFromPHCString] is
+// needed. Simply hash with the new parameters, and
+// [PasswordHasher.IsPHCValid] will detect the difference in the parameter.
 //
 // Note that the migration happens in [App.migratePassword], which is triggered
 // whenever the user enters their password and an old hashing method is
@@ -74,18 +67,23 @@ type PasswordHasher interface {
 }
 
 const (
-	// Maximum password length for all password hashers
-	PasswordMaxLengthBytes = 72
+	// Maximum password length for all password hashers but [BCrypt]. The PHC
+	// hashers accept any length, so this only bounds the work done when
+	// hashing.
+	PasswordMaxLengthBytes = 256
+
+	// Maximum password length for [BCrypt], which ignores anything past it.
+	BCryptMaxLengthBytes = 72
 )
 
 var (
-	// latestHasher is the hasher currently in use.
-	// Any password hashed with a different hasher must be migrated to this one.
-	latestHasher PasswordHasher = DefaultPBKDF2()
+	// latestHasher is the hasher used by [Hash].
+	latestHasher PasswordHasher = DefaultArgon2id()
 
 	// ErrPasswordTooLong is the error returned when the provided password is
-	// longer than [PasswordMaxLengthBytes].
-	ErrPasswordTooLong = fmt.Errorf("password too long; maximum length in bytes: %d", PasswordMaxLengthBytes)
+	// longer than what the hasher supports: [PasswordMaxLengthBytes], or
+	// [BCryptMaxLengthBytes] for [BCrypt].
+	ErrPasswordTooLong = fmt.Errorf("password too long")
 
 	// ErrMismatchedHashAndPassword is the error returned when the provided
 	// password does not match the stored hash
@@ -126,6 +124,12 @@ func GetHasherFromPHCString(phcString string) (PasswordHasher, phcparser.PHC, er
 			return PBKDF2{}, phcparser.PHC{}, fmt.Errorf("the provided PHC string is PBKDF2, but is not valid: %w", err)
 		}
 		return pbkdf2, phc, nil
+	case Argon2idFunctionId:
+		argon2id, err := NewArgon2idFromPHC(phc)
+		if err != nil {
+			return Argon2id{}, phcparser.PHC{}, fmt.Errorf("the provided PHC string is Argon2id, but is not valid: %w", err)
+		}
+		return argon2id, phc, nil
 	// If the function ID is unknown, return the original hasher
 	default:
 		bcrypt, phc := getOriginalHasher(phcString)
@@ -19,9 +19,48 @@ func TestGetHasherFromPHCString(t *testing.T) {
 		expectedErr    bool
 	}{
 		{
-			testName:       "latest hasher (PBKDF2)",
-			input:          "$pbkdf2$f=SHA256,w=600000,l=32$5Zq8TvET7nMrXof49Rp4Sw$d0Mx8467kv+3ylbGrkyu4jTd8O8SP51k4s1RuWb9S/o",
+			testName:       "latest hasher (Argon2id)",
+			input:          "$argon2id$v=19$m=19456,t=2,p=1$5Zq8TvET7nMrXof49Rp4Sw$d0Mx8467kv+3ylbGrkyu4jTd8O8SP51k4s1RuWb9S/o",
 			expectedHasher: latestHasher,
+			expectedPHC: phcparser.PHC{
+				Id:      "argon2id",
+				Version: "19",
+				Params: map[string]string{
+					"m": "19456",
+					"t": "2",
+					"p": "1",
+				},
+				Salt: "5Zq8TvET7nMrXof49Rp4Sw",
+				Hash: "d0Mx8467kv+3ylbGrkyu4jTd8O8SP51k4s1RuWb9S/o",
+			},
+			expectedErr: false,
+		},
+		{
+			testName: "valid, non-default Argon2id",
+			input:    "$argon2id$v=19$m=65536,t=3,p=4$5Zq8TvET7nMrXof49Rp4Sw$d0Mx8467kv+3ylbGrkyu4jTd8O8SP51k4s1RuWb9S/o",
+			expectedHasher: Argon2id{
+				memory:      65536,
+				iterations:  3,
+				parallelism: 4,
+				phcHeader:   "$argon2id$v=19$m=65536,t=3,p=4$",
+			},
+			expectedPHC: phcparser.PHC{
+				Id:      "argon2id",
+				Version: "19",
+				Params: map[string]string{
+					"m": "65536",
+					"t": "3",
+					"p": "4",
+				},
+				Salt: "5Zq8TvET7nMrXof49Rp4Sw",
+				Hash: "d0Mx8467kv+3ylbGrkyu4jTd8O8SP51k4s1RuWb9S/o",
+			},
+			expectedErr: false,
+		},
+		{
+			testName:       "default PBKDF2",
+			input:          "$pbkdf2$f=SHA256,w=600000,l=32$5Zq8TvET7nMrXof49Rp4Sw$d0Mx8467kv+3ylbGrkyu4jTd8O8SP51k4s1RuWb9S/o",
+			expectedHasher: DefaultPBKDF2(),
 			expectedPHC: phcparser.PHC{
 				Id:      "pbkdf2",
 				Version: "",
@@ -85,6 +124,13 @@ func TestGetHasherFromPHCString(t *testing.T) {
 			expectedPHC: phcparser.PHC{},
 			expectedErr: true,
 		},
+		{
+			testName:       "Argon2id with unsupported version",
+			input:          "$argon2id$v=16$m=19456,t=2,p=1$5Zq8TvET7nMrXof49Rp4Sw$d0Mx8467kv+3ylbGrkyu4jTd8O8SP51k4s1RuWb9S/o",
+			expectedHasher: Argon2id{},
+			expectedPHC:    phcparser.PHC{},
+			expectedErr:    true,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -116,10 +162,15 @@ func TestIsLatestHasher(t *testing.T) {
 			true,
 		},
 		{
-			"DefaultPBKDF2 is the latest hasher",
-			DefaultPBKDF2(),
+			"DefaultArgon2id is the latest hasher",
+			DefaultArgon2id(),
 			true,
 		},
+		{
+			"DefaultPBKDF2 is not the latest hasher",
+			DefaultPBKDF2(),
+			false,
+		},
 		{
 			"PBKDF2 with other parameters is not the latest hasher",
 			pbkdf2WithOtherParams,
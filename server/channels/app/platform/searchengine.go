@@ -17,6 +17,14 @@ func (ps *PlatformService) StartSearchEngine() (string, string) {
 		})
 	}
 
+	if ps.SearchEngine.EmbeddedEngine != nil && ps.SearchEngine.EmbeddedEngine.IsEnabled() {
+		ps.Go(func() {
+			if err := ps.SearchEngine.EmbeddedEngine.Start(); err != nil {
+				ps.Log().Error(err.Error())
+			}
+		})
+	}
+
 	configListenerId := ps.AddConfigListener(func(oldConfig *model.Config, newConfig *model.Config) {
 		if ps.SearchEngine == nil {
 			return
@@ -50,6 +58,33 @@ func (ps *PlatformService) StartSearchEngine() (string, string) {
 				}
 			})
 		}
+
+		if ps.SearchEngine.EmbeddedEngine != nil {
+			oldSettings, newSettings := oldConfig.EmbeddedSearchSettings, newConfig.EmbeddedSearchSettings
+			if !*oldSettings.EnableIndexing && *newSettings.EnableIndexing {
+				ps.Go(func() {
+					if err := ps.SearchEngine.EmbeddedEngine.Start(); err != nil {
+						ps.Log().Error(err.Error())
+					}
+				})
+			} else if *oldSettings.EnableIndexing && !*newSettings.EnableIndexing {
+				ps.Go(func() {
+					if err := ps.SearchEngine.EmbeddedEngine.Stop(); err != nil {
+						ps.Log().Error(err.Error())
+					}
+				})
+			} else if *newSettings.EnableIndexing && (*oldSettings.IndexDir != *newSettings.IndexDir || *oldSettings.Analyzer != *newSettings.Analyzer) {
+				// The indexes are opened again to move them or to analyze their documents again
+				ps.Go(func() {
+					if err := ps.SearchEngine.EmbeddedEngine.Stop(); err != nil {
+						ps.Log().Error(err.Error())
+					}
+					if err := ps.SearchEngine.EmbeddedEngine.Start(); err != nil {
+						ps.Log().Error(err.Error())
+					}
+				})
+			}
+		}
 	})
 
 	licenseListenerId := ps.AddLicenseListener(func(oldLicense, newLicense *model.License) {
@@ -86,4 +121,9 @@ func (ps *PlatformService) StopSearchEngine() {
 			ps.Log().Error("Failed to stop Elasticsearch engine", mlog.Err(err))
 		}
 	}
+	if ps.SearchEngine != nil && ps.SearchEngine.EmbeddedEngine != nil {
+		if err := ps.SearchEngine.EmbeddedEngine.Stop(); err != nil {
+			ps.Log().Error("Failed to stop the embedded search engine", mlog.Err(err))
+		}
+	}
 }
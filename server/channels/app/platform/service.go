@@ -30,7 +30,10 @@ import (
 	"github.com/mattermost/mattermost/server/v8/einterfaces"
 	"github.com/mattermost/mattermost/server/v8/platform/services/cache"
 	"github.com/mattermost/mattermost/server/v8/platform/services/searchengine"
+	"github.com/mattermost/mattermost/server/v8/platform/services/searchengine/embeddedengine"
 	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+
+	"github.com/redis/rueidis"
 )
 
 // PlatformService is the service for the platform related tasks. It is
@@ -230,6 +233,7 @@ func New(sc ServiceConfig, options ...Option) (*PlatformService, error) {
 
 	// Step 3: Search Engine
 	searchEngine := searchengine.NewBroker(ps.Config())
+	searchEngine.RegisterEmbeddedEngine(embeddedengine.NewEmbeddedEngine(ps.Config(), ps.Log()))
 	ps.SearchEngine = searchEngine
 
 	// Step 4: Init Enterprise
@@ -650,15 +654,15 @@ func (ps *PlatformService) GetRedisClient() interface{} {
 	if ps.cacheProvider == nil {
 		return nil
 	}
-	
+
 	// Type assert to redisProvider to access the client
 	type redisClientGetter interface {
-		GetClient() interface{}
+		GetClient() rueidis.Client
 	}
-	
+
 	if rp, ok := ps.cacheProvider.(redisClientGetter); ok {
 		return rp.GetClient()
 	}
-	
+
 	return nil
 }
@@ -798,6 +798,12 @@ func (a *App) UpdatePost(rctx request.CTX, receivedUpdatedPost *model.Post, upda
 		}
 	}
 
+	if a.IsAutoTranslationEnabled() && rpost.Message != oldPost.Message {
+		if err := a.Srv().Store().PostTranslation().DeleteForPost(rpost.Id); err != nil {
+			rctx.Logger().Warn("Failed to delete the translations of an edited post", mlog.String("post_id", rpost.Id), mlog.Err(err))
+		}
+	}
+
 	pluginOldPost := oldPost.ForPlugin()
 	pluginNewPost := newPost.ForPlugin()
 	a.Srv().Go(func() {
@@ -69,6 +69,14 @@ func (a *App) PreparePostListForClient(rctx request.CTX, originalList *model.Pos
 		list.Posts[id] = post
 	}
 
+	if a.IsAutoTranslationEnabled() && rctx.Session() != nil {
+		posts := make([]*model.Post, 0, len(list.Posts))
+		for _, post := range list.Posts {
+			posts = append(posts, post)
+		}
+		a.AddPostTranslationsForUser(rctx, posts, rctx.Session().UserId)
+	}
+
 	if a.IsPostPriorityEnabled() {
 		priority, _ := a.GetPriorityForPostList(list)
 		acknowledgements, _ := a.GetAcknowledgementsForPostList(list)
@@ -63,6 +63,12 @@ func (a *App) UpdatePreferences(rctx request.CTX, userID string, preferences mod
 		return model.NewAppError("UpdatePreferences", "api.preference.update_preferences.update_sidebar.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
 
+	for _, preference := range preferences {
+		if preference.Category == model.PreferenceCategoryReadReceipts && preference.Name == model.PreferenceNameShareReadState {
+			a.handleShareReadStateChanged(rctx, userID, preference, false)
+		}
+	}
+
 	message := model.NewWebSocketEvent(model.WebsocketEventSidebarCategoryUpdated, "", "", userID, nil, "")
 	// TODO this needs to be updated to include information on which categories changed
 	a.Publish(message)
@@ -105,6 +111,12 @@ func (a *App) DeletePreferences(rctx request.CTX, userID string, preferences mod
 		return model.NewAppError("DeletePreferences", "api.preference.delete_preferences.update_sidebar.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
 
+	for _, preference := range preferences {
+		if preference.Category == model.PreferenceCategoryReadReceipts && preference.Name == model.PreferenceNameShareReadState {
+			a.handleShareReadStateChanged(rctx, userID, preference, true)
+		}
+	}
+
 	message := model.NewWebSocketEvent(model.WebsocketEventSidebarCategoryUpdated, "", "", userID, nil, "")
 	// TODO this needs to be updated to include information on which categories changed
 	a.Publish(message)
@@ -6,12 +6,12 @@ package app
 import (
 	"math"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/throttled/throttled"
-	"github.com/throttled/throttled/store/memstore"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/shared/i18n"
@@ -19,18 +19,53 @@ import (
 	"github.com/mattermost/mattermost/server/v8/channels/utils"
 )
 
+// The routes with their own quota in RateLimitSettings
+const (
+	rateLimitRouteLogin      = "login"
+	rateLimitRouteCreatePost = "create_post"
+	rateLimitRouteUploadFile = "upload_file"
+	rateLimitRouteSearch     = "search"
+)
+
+// rateLimitRoutes matches the requests of the routes. The paths aren't anchored at the start
+// so that they match when the server is hosted on a subpath.
+var rateLimitRoutes = []struct {
+	route   string
+	method  string
+	pattern *regexp.Regexp
+}{
+	{rateLimitRouteLogin, http.MethodPost, regexp.MustCompile(`/api/v4/users/(login|login/switch|login/cws|login/sso/code-exchange|login/webauthn|mfa)$`)},
+	{rateLimitRouteCreatePost, http.MethodPost, regexp.MustCompile(`/api/v4/posts$`)},
+	{rateLimitRouteUploadFile, http.MethodPost, regexp.MustCompile(`/api/v4/(files|uploads|uploads/[A-Za-z0-9]+)$`)},
+	{rateLimitRouteSearch, http.MethodPost, regexp.MustCompile(`/api/v4/(teams/[A-Za-z0-9]+/)?(posts|files)/search$`)},
+}
+
+func rateLimitRoute(r *http.Request) string {
+	for _, route := range rateLimitRoutes {
+		if r.Method == route.method && route.pattern.MatchString(r.URL.Path) {
+			return route.route
+		}
+	}
+	return ""
+}
+
 type RateLimiter struct {
 	throttledRateLimiter *throttled.GCRARateLimiter
+	routeRateLimiters    map[string]*throttled.GCRARateLimiter
 	useAuth              bool
 	useIP                bool
 	header               string
 	trustedProxyIPHeader []string
 }
 
-func NewRateLimiter(settings *model.RateLimitSettings, trustedProxyIPHeader []string) (*RateLimiter, error) {
-	store, err := memstore.New(*settings.MemoryStoreSize)
-	if err != nil {
-		return nil, errors.Wrap(err, i18n.T("api.server.start_server.rate_limiting_memory_store"))
+// NewRateLimiter creates a rate limiter tracking the rate limits in the store, or in a memory
+// store of MemoryStoreSize when it is nil.
+func NewRateLimiter(settings *model.RateLimitSettings, trustedProxyIPHeader []string, store throttled.GCRAStore) (*RateLimiter, error) {
+	if store == nil {
+		var err error
+		if store, err = newRateLimitStore(settings, nil, ""); err != nil {
+			return nil, err
+		}
 	}
 
 	quota := throttled.RateQuota{
@@ -43,8 +78,29 @@ func NewRateLimiter(settings *model.RateLimitSettings, trustedProxyIPHeader []st
 		return nil, errors.Wrap(err, i18n.T("api.server.start_server.rate_limiting_rate_limiter"))
 	}
 
+	routeRateLimiters := map[string]*throttled.GCRARateLimiter{}
+	for route, routeQuota := range map[string]*model.RateLimitQuota{
+		rateLimitRouteLogin:      settings.LoginQuota,
+		rateLimitRouteCreatePost: settings.CreatePostQuota,
+		rateLimitRouteUploadFile: settings.UploadFileQuota,
+		rateLimitRouteSearch:     settings.SearchQuota,
+	} {
+		if routeQuota == nil || *routeQuota.PerMin == 0 {
+			continue
+		}
+
+		routeRateLimiters[route], err = throttled.NewGCRARateLimiter(store, throttled.RateQuota{
+			MaxRate:  throttled.PerMin(*routeQuota.PerMin),
+			MaxBurst: *routeQuota.MaxBurst,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, i18n.T("api.server.start_server.rate_limiting_rate_limiter"))
+		}
+	}
+
 	return &RateLimiter{
 		throttledRateLimiter: throttledRateLimiter,
+		routeRateLimiters:    routeRateLimiters,
 		useAuth:              *settings.VaryByUser,
 		useIP:                *settings.VaryByRemoteAddr,
 		header:               settings.VaryByHeader,
@@ -52,6 +108,16 @@ func NewRateLimiter(settings *model.RateLimitSettings, trustedProxyIPHeader []st
 	}, nil
 }
 
+// rateLimiterFor returns the rate limiter of the route of the request, and the prefix of its
+// keys in the store shared by the rate limiters
+func (rl *RateLimiter) rateLimiterFor(r *http.Request) (*throttled.GCRARateLimiter, string) {
+	route := rateLimitRoute(r)
+	if routeRateLimiter, ok := rl.routeRateLimiters[route]; ok {
+		return routeRateLimiter, route + ":"
+	}
+	return rl.throttledRateLimiter, ""
+}
+
 func (rl *RateLimiter) GenerateKey(r *http.Request) string {
 	key := ""
 
@@ -76,7 +142,11 @@ func (rl *RateLimiter) GenerateKey(r *http.Request) string {
 }
 
 func (rl *RateLimiter) RateLimitWriter(key string, w http.ResponseWriter) bool {
-	limited, context, err := rl.throttledRateLimiter.RateLimit(key, 1)
+	return rl.rateLimit(rl.throttledRateLimiter, key, w)
+}
+
+func (rl *RateLimiter) rateLimit(rateLimiter *throttled.GCRARateLimiter, key string, w http.ResponseWriter) bool {
+	limited, context, err := rateLimiter.RateLimit(key, 1)
 	if err != nil {
 		mlog.Error("Internal server error when rate limiting. Rate Limiting broken.", mlog.Err(err))
 		return false
@@ -92,40 +162,45 @@ func (rl *RateLimiter) RateLimitWriter(key string, w http.ResponseWriter) bool {
 	return limited
 }
 
-func (rl *RateLimiter) UserIdRateLimit(userID string, w http.ResponseWriter) bool {
+// UserIdRateLimit limits the requests of the user once authenticated, in the bucket of the route
+// of the request
+func (rl *RateLimiter) UserIdRateLimit(userID string, r *http.Request, w http.ResponseWriter) bool {
 	if rl.useAuth {
-		return rl.RateLimitWriter(userID, w)
+		rateLimiter, prefix := rl.rateLimiterFor(r)
+		return rl.rateLimit(rateLimiter, prefix+userID, w)
 	}
 	return false
 }
 
 func (rl *RateLimiter) RateLimitHandler(wrappedHandler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		key := rl.GenerateKey(r)
+		rateLimiter, prefix := rl.rateLimiterFor(r)
+		key := prefix + rl.GenerateKey(r)
 
-		if !rl.RateLimitWriter(key, w) {
+		if !rl.rateLimit(rateLimiter, key, w) {
 			wrappedHandler.ServeHTTP(w, r)
 		}
 	})
 }
 
-// Copied from https://github.com/throttled/throttled http.go
+// Adapted from https://github.com/throttled/throttled http.go. The headers are set rather than
+// added so that they reflect the last bucket the request was counted in.
 func setRateLimitHeaders(w http.ResponseWriter, context throttled.RateLimitResult) {
 	if v := context.Limit; v >= 0 {
-		w.Header().Add("X-RateLimit-Limit", strconv.Itoa(v))
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(v))
 	}
 
 	if v := context.Remaining; v >= 0 {
-		w.Header().Add("X-RateLimit-Remaining", strconv.Itoa(v))
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(v))
 	}
 
 	if v := context.ResetAfter; v >= 0 {
 		vi := int(math.Ceil(v.Seconds()))
-		w.Header().Add("X-RateLimit-Reset", strconv.Itoa(vi))
+		w.Header().Set("X-RateLimit-Reset", strconv.Itoa(vi))
 	}
 
 	if v := context.RetryAfter; v >= 0 {
 		vi := int(math.Ceil(v.Seconds()))
-		w.Header().Add("Retry-After", strconv.Itoa(vi))
+		w.Header().Set("Retry-After", strconv.Itoa(vi))
 	}
 }
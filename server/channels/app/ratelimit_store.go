@@ -0,0 +1,151 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/rueidis"
+	"github.com/throttled/throttled"
+	"github.com/throttled/throttled/store/memstore"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/i18n"
+)
+
+const rateLimitRedisTimeout = time.Second
+
+// The theoretical arrival times are read along with the clock of Redis, which all the nodes
+// share, and stored as strings since they don't fit in the numbers of Lua
+var (
+	rateLimitGetScript = rueidis.NewLuaScript(`
+local now = redis.call('TIME')
+local value = redis.call('GET', KEYS[1])
+if value == false then
+  value = '-1'
+end
+return {now[1], now[2], value}
+`)
+
+	rateLimitCompareAndSwapScript = rueidis.NewLuaScript(`
+local value = redis.call('GET', KEYS[1])
+if value == false or value ~= ARGV[1] then
+  return 0
+end
+redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+return 1
+`)
+)
+
+// NewRateLimitStore returns a store of the kind set in RateLimitSettings of the configuration,
+// sized by settings when kept in memory. The rate limiters sharing the Redis store are told apart
+// by name, left empty for the rate limiter of the whole API.
+func (s *Server) NewRateLimitStore(settings *model.RateLimitSettings, name string) (throttled.GCRAStore, error) {
+	storeSettings := *settings
+	storeSettings.Store = s.platform.Config().RateLimitSettings.Store
+
+	keyPrefix := *s.platform.Config().CacheSettings.RedisCachePrefix + "ratelimit:"
+	if name != "" {
+		keyPrefix += name + ":"
+	}
+
+	// The Redis store shares the client of the cache
+	redisClient, _ := s.platform.GetRedisClient().(rueidis.Client)
+	return newRateLimitStore(&storeSettings, redisClient, keyPrefix)
+}
+
+// newRateLimitStore returns the store of RateLimitSettings, prefixing its keys in Redis with
+// keyPrefix. The Redis store requires the client of the Redis cache.
+func newRateLimitStore(settings *model.RateLimitSettings, redisClient rueidis.Client, keyPrefix string) (throttled.GCRAStore, error) {
+	if model.SafeDereference(settings.Store) == model.RateLimitStoreRedis {
+		if redisClient == nil {
+			return nil, errors.New(i18n.T("api.server.start_server.rate_limiting_redis_store"))
+		}
+		return &redisRateLimitStore{client: redisClient, prefix: keyPrefix}, nil
+	}
+
+	store, err := memstore.New(*settings.MemoryStoreSize)
+	if err != nil {
+		return nil, errors.Wrap(err, i18n.T("api.server.start_server.rate_limiting_memory_store"))
+	}
+	return store, nil
+}
+
+// redisRateLimitStore implements throttled.GCRAStore on Redis, sharing the rate limits
+// across the cluster
+type redisRateLimitStore struct {
+	client rueidis.Client
+	prefix string
+}
+
+func (s *redisRateLimitStore) GetWithTime(key string) (int64, time.Time, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rateLimitRedisTimeout)
+	defer cancel()
+
+	values, err := rateLimitGetScript.Exec(ctx, s.client, []string{s.prefix + key}, nil).AsStrSlice()
+	if err != nil {
+		return 0, time.Time{}, errors.Wrap(err, "failed to get the rate limit")
+	}
+	if len(values) != 3 {
+		return 0, time.Time{}, errors.Errorf("unexpected rate limit reply of %d values", len(values))
+	}
+
+	seconds, err := strconv.ParseInt(values[0], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, errors.Wrap(err, "failed to parse the time of Redis")
+	}
+	microseconds, err := strconv.ParseInt(values[1], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, errors.Wrap(err, "failed to parse the time of Redis")
+	}
+	now := time.Unix(seconds, microseconds*int64(time.Microsecond))
+
+	value, err := strconv.ParseInt(values[2], 10, 64)
+	if err != nil {
+		return 0, now, errors.Wrap(err, "failed to parse the rate limit")
+	}
+
+	return value, now, nil
+}
+
+func (s *redisRateLimitStore) SetIfNotExistsWithTTL(key string, value int64, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rateLimitRedisTimeout)
+	defer cancel()
+
+	cmd := s.client.B().Set().Key(s.prefix + key).Value(strconv.FormatInt(value, 10)).Nx().PxMilliseconds(ttlMilliseconds(ttl)).Build()
+	err := s.client.Do(ctx, cmd).Error()
+	if rueidis.IsRedisNil(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "failed to set the rate limit")
+	}
+
+	return true, nil
+}
+
+func (s *redisRateLimitStore) CompareAndSwapWithTTL(key string, old, new int64, ttl time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rateLimitRedisTimeout)
+	defer cancel()
+
+	args := []string{strconv.FormatInt(old, 10), strconv.FormatInt(new, 10), strconv.FormatInt(ttlMilliseconds(ttl), 10)}
+	swapped, err := rateLimitCompareAndSwapScript.Exec(ctx, s.client, []string{s.prefix + key}, args).AsInt64()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update the rate limit")
+	}
+
+	return swapped == 1, nil
+}
+
+// ttlMilliseconds rounds the TTL up, since Redis deletes the keys expiring in 0ms right away
+func ttlMilliseconds(ttl time.Duration) int64 {
+	ms := (ttl + time.Millisecond - 1).Milliseconds()
+	if ms < 1 {
+		return 1
+	}
+	return ms
+}
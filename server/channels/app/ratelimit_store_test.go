@@ -0,0 +1,133 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/rueidis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func newTestRateLimitStore(t *testing.T) (*redisRateLimitStore, *miniredis.Miniredis) {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client, err := rueidis.NewClient(rueidis.ClientOption{
+		InitAddress:  []string{mr.Addr()},
+		DisableCache: true,
+		AlwaysRESP2:  true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(client.Close)
+
+	store, err := newRateLimitStore(&model.RateLimitSettings{Store: model.NewPointer(model.RateLimitStoreRedis)}, client, "prefix:ratelimit:")
+	require.NoError(t, err)
+	require.IsType(t, &redisRateLimitStore{}, store)
+
+	return store.(*redisRateLimitStore), mr
+}
+
+func TestRedisRateLimitStore(t *testing.T) {
+	mainHelper.Parallel(t)
+
+	t.Run("get returns the time of Redis and -1 for a missing key", func(t *testing.T) {
+		store, mr := newTestRateLimitStore(t)
+		now := time.UnixMicro(1_700_000_000_123_456)
+		mr.SetTime(now)
+
+		value, redisNow, err := store.GetWithTime("missing")
+		require.NoError(t, err)
+		assert.Equal(t, int64(-1), value)
+		assert.True(t, now.Equal(redisNow), "expected %v, got %v", now, redisNow)
+	})
+
+	t.Run("set if not exists", func(t *testing.T) {
+		store, mr := newTestRateLimitStore(t)
+
+		set, err := store.SetIfNotExistsWithTTL("key", 42, 1500*time.Microsecond)
+		require.NoError(t, err)
+		assert.True(t, set)
+		assert.Equal(t, 2*time.Millisecond, mr.TTL("prefix:ratelimit:key"), "the TTL is rounded up to the millisecond")
+
+		set, err = store.SetIfNotExistsWithTTL("key", 43, time.Second)
+		require.NoError(t, err)
+		assert.False(t, set)
+
+		value, _, err := store.GetWithTime("key")
+		require.NoError(t, err)
+		assert.Equal(t, int64(42), value)
+	})
+
+	t.Run("compare and swap", func(t *testing.T) {
+		store, mr := newTestRateLimitStore(t)
+
+		swapped, err := store.CompareAndSwapWithTTL("key", 1, 2, time.Second)
+		require.NoError(t, err)
+		assert.False(t, swapped, "a missing key is not swapped")
+
+		// The values don't fit in the numbers of Lua
+		old, new := int64(1_700_000_000_000_000_001), int64(1_700_000_000_000_000_002)
+		_, err = store.SetIfNotExistsWithTTL("key", old, time.Second)
+		require.NoError(t, err)
+
+		swapped, err = store.CompareAndSwapWithTTL("key", old+1, new, time.Minute)
+		require.NoError(t, err)
+		assert.False(t, swapped, "a different value is not swapped")
+
+		swapped, err = store.CompareAndSwapWithTTL("key", old, new, time.Minute)
+		require.NoError(t, err)
+		assert.True(t, swapped)
+		assert.Equal(t, time.Minute, mr.TTL("prefix:ratelimit:key"))
+
+		value, _, err := store.GetWithTime("key")
+		require.NoError(t, err)
+		assert.Equal(t, new, value)
+	})
+
+	t.Run("errors are returned", func(t *testing.T) {
+		store, mr := newTestRateLimitStore(t)
+		mr.SetError("unavailable")
+
+		_, _, err := store.GetWithTime("key")
+		require.Error(t, err)
+		_, err = store.SetIfNotExistsWithTTL("key", 1, time.Second)
+		require.Error(t, err)
+		_, err = store.CompareAndSwapWithTTL("key", 1, 2, time.Second)
+		require.Error(t, err)
+	})
+
+	t.Run("rate limiters sharing the store share the limits", func(t *testing.T) {
+		store, _ := newTestRateLimitStore(t)
+		settings := genRateLimitSettings(false, true, "")
+		settings.PerSec = model.NewPointer(1)
+		settings.MaxBurst = model.NewPointer(1)
+
+		serve := func(rateLimiter *RateLimiter) int {
+			handler := rateLimiter.RateLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			req := httptest.NewRequest(http.MethodGet, "/api/v4/users/me", nil)
+			req.RemoteAddr = "10.10.10.10:1000"
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		node1, err := NewRateLimiter(settings, nil, store)
+		require.NoError(t, err)
+		node2, err := NewRateLimiter(settings, nil, store)
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, serve(node1))
+		assert.Equal(t, http.StatusOK, serve(node2))
+		assert.Equal(t, http.StatusTooManyRequests, serve(node1))
+		assert.Equal(t, http.StatusTooManyRequests, serve(node2))
+	})
+}
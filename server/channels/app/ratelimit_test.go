@@ -29,11 +29,11 @@ func genRateLimitSettings(useAuth, useIP bool, header string) *model.RateLimitSe
 func TestNewRateLimiterSuccess(t *testing.T) {
 	mainHelper.Parallel(t)
 	settings := genRateLimitSettings(false, false, "")
-	rateLimiter, err := NewRateLimiter(settings, nil)
+	rateLimiter, err := NewRateLimiter(settings, nil, nil)
 	require.NotNil(t, rateLimiter)
 	require.NoError(t, err)
 
-	rateLimiter, err = NewRateLimiter(settings, []string{"X-Forwarded-For"})
+	rateLimiter, err = NewRateLimiter(settings, []string{"X-Forwarded-For"}, nil)
 	require.NotNil(t, rateLimiter)
 	require.NoError(t, err)
 }
@@ -42,11 +42,11 @@ func TestNewRateLimiterFailure(t *testing.T) {
 	mainHelper.Parallel(t)
 	invalidSettings := genRateLimitSettings(false, false, "")
 	invalidSettings.MaxBurst = model.NewPointer(-100)
-	rateLimiter, err := NewRateLimiter(invalidSettings, nil)
+	rateLimiter, err := NewRateLimiter(invalidSettings, nil, nil)
 	require.Nil(t, rateLimiter)
 	require.Error(t, err)
 
-	rateLimiter, err = NewRateLimiter(invalidSettings, []string{"X-Forwarded-For", "X-Real-Ip"})
+	rateLimiter, err = NewRateLimiter(invalidSettings, []string{"X-Forwarded-For", "X-Real-Ip"}, nil)
 	require.Nil(t, rateLimiter)
 	require.Error(t, err)
 }
@@ -85,7 +85,7 @@ func TestGenerateKey(t *testing.T) {
 			req.Header.Set(tc.header, tc.headerResult)
 		}
 
-		rateLimiter, _ := NewRateLimiter(genRateLimitSettings(tc.useAuth, tc.useIP, tc.header), nil)
+		rateLimiter, _ := NewRateLimiter(genRateLimitSettings(tc.useAuth, tc.useIP, tc.header), nil, nil)
 
 		key := rateLimiter.GenerateKey(req)
 
@@ -99,11 +99,82 @@ func TestGenerateKey_TrustedHeader(t *testing.T) {
 	req.RemoteAddr = "10.10.10.5:80"
 	req.Header.Set("X-Forwarded-For", "10.6.3.1, 10.5.1.2")
 
-	rateLimiter, _ := NewRateLimiter(genRateLimitSettings(true, true, ""), []string{"X-Forwarded-For"})
+	rateLimiter, _ := NewRateLimiter(genRateLimitSettings(true, true, ""), []string{"X-Forwarded-For"}, nil)
 	key := rateLimiter.GenerateKey(req)
 	require.Equal(t, "10.6.3.1", key, "Wrong key on test with allowed trusted proxy header")
 
-	rateLimiter, _ = NewRateLimiter(genRateLimitSettings(true, true, ""), nil)
+	rateLimiter, _ = NewRateLimiter(genRateLimitSettings(true, true, ""), nil, nil)
 	key = rateLimiter.GenerateKey(req)
 	require.Equal(t, "10.10.10.5", key, "Wrong key on test without allowed trusted proxy header")
 }
+
+func TestRateLimitRoute(t *testing.T) {
+	mainHelper.Parallel(t)
+	cases := []struct {
+		method   string
+		path     string
+		expected string
+	}{
+		{http.MethodPost, "/api/v4/users/login", rateLimitRouteLogin},
+		{http.MethodPost, "/api/v4/users/login/switch", rateLimitRouteLogin},
+		{http.MethodPost, "/api/v4/users/login/webauthn", rateLimitRouteLogin},
+		{http.MethodPost, "/subpath/api/v4/users/mfa", rateLimitRouteLogin},
+		{http.MethodPost, "/api/v4/posts", rateLimitRouteCreatePost},
+		{http.MethodPut, "/api/v4/posts", ""},
+		{http.MethodPost, "/api/v4/posts/ephemeral", ""},
+		{http.MethodPost, "/api/v4/files", rateLimitRouteUploadFile},
+		{http.MethodPost, "/api/v4/uploads/" + model.NewId(), rateLimitRouteUploadFile},
+		{http.MethodPost, "/api/v4/posts/search", rateLimitRouteSearch},
+		{http.MethodPost, "/api/v4/teams/" + model.NewId() + "/files/search", rateLimitRouteSearch},
+		{http.MethodPost, "/api/v4/users/search", ""},
+		{http.MethodGet, "/api/v4/users/me", ""},
+	}
+
+	for _, tc := range cases {
+		require.Equal(t, tc.expected, rateLimitRoute(httptest.NewRequest(tc.method, tc.path, nil)), tc.method+" "+tc.path)
+	}
+}
+
+func TestRateLimitHandlerRouteQuota(t *testing.T) {
+	mainHelper.Parallel(t)
+	settings := genRateLimitSettings(false, true, "")
+	settings.LoginQuota = &model.RateLimitQuota{PerMin: model.NewPointer(1), MaxBurst: model.NewPointer(2)}
+
+	rateLimiter, err := NewRateLimiter(settings, nil, nil)
+	require.NoError(t, err)
+	handler := rateLimiter.RateLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "10.0.0.1:80"
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := range 3 {
+		w := serve(http.MethodPost, "/api/v4/users/login")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, []string{"3"}, w.Header().Values("X-RateLimit-Limit"))
+		require.Equal(t, strconv.Itoa(2-i), w.Header().Get("X-RateLimit-Remaining"))
+	}
+
+	w := serve(http.MethodPost, "/api/v4/users/login")
+	require.Equal(t, http.StatusTooManyRequests, w.Code)
+	require.NotEmpty(t, w.Header().Get("Retry-After"))
+
+	// The other requests are counted in the global bucket
+	w = serve(http.MethodGet, "/api/v4/users/me")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "101", w.Header().Get("X-RateLimit-Limit"))
+}
+
+func TestNewRateLimiterRedisStore(t *testing.T) {
+	mainHelper.Parallel(t)
+	settings := genRateLimitSettings(false, true, "")
+	settings.Store = model.NewPointer(model.RateLimitStoreRedis)
+
+	rateLimiter, err := NewRateLimiter(settings, nil, nil)
+	require.Nil(t, rateLimiter)
+	require.Error(t, err)
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/i18n"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs/read_receipts_reminder"
+)
+
+const (
+	// readReceiptsMaxReminders caps the number of direct messages sent for a single reminder
+	readReceiptsMaxReminders = 1000
+
+	// readReceiptsRemindersPageSize is the number of non readers loaded at once when reminding them
+	readReceiptsRemindersPageSize = 200
+
+	// ReadReceiptsSummaryMaxDays is the longest period covered by the read receipts dashboard
+	ReadReceiptsSummaryMaxDays = 90
+)
+
+// GetPostNonReaders returns a page of the active, non-bot channel members other than the author
+// who haven't read the post, along with their total number. Members who don't share their read
+// state are left out, since whether they read the post isn't recorded.
+func (a *App) GetPostNonReaders(rctx request.CTX, channel *model.Channel, post *model.Post, page, perPage int) (*model.PostNonReaders, *model.AppError) {
+	cursorStore := a.Srv().Store().ChannelReadCursor()
+	sharesByDefault := a.readStateSettingsDefault(channel)
+
+	count, err := cursorStore.CountNonReaders(channel.Id, post.RootId, post.CreateAt, post.UserId, sharesByDefault)
+	if err != nil {
+		return nil, model.NewAppError("GetPostNonReaders", "app.read_receipts.get_non_readers.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	userIds, err := cursorStore.GetNonReaders(channel.Id, post.RootId, post.CreateAt, post.UserId, sharesByDefault, page*perPage, perPage)
+	if err != nil {
+		return nil, model.NewAppError("GetPostNonReaders", "app.read_receipts.get_non_readers.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	users, appErr := a.orderedUsers(rctx, userIds)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	return &model.PostNonReaders{Users: users, TotalCount: count}, nil
+}
+
+// orderedUsers loads the users with the given ids, keeping the order of userIds
+func (a *App) orderedUsers(rctx request.CTX, userIds []string) ([]*model.User, *model.AppError) {
+	if len(userIds) == 0 {
+		return []*model.User{}, nil
+	}
+
+	users, appErr := a.GetUsers(rctx, userIds)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	byId := make(map[string]*model.User, len(users))
+	for _, user := range users {
+		byId[user.Id] = user
+	}
+
+	ordered := make([]*model.User, 0, len(userIds))
+	for _, userId := range userIds {
+		if user, ok := byId[userId]; ok {
+			ordered = append(ordered, user)
+		}
+	}
+
+	return ordered, nil
+}
+
+// CanRemindPostNonReaders reports whether the user may send reminders for the post: only the
+// channel's admins may, so that reminders can't be used to message every member of the channel.
+func (a *App) CanRemindPostNonReaders(rctx request.CTX, session model.Session, post *model.Post) bool {
+	return a.SessionHasPermissionToChannel(rctx, session, post.ChannelId, model.PermissionManageChannelRoles)
+}
+
+// RemindPostNonReaders schedules a job reminding the members who haven't read the post. The non
+// readers of a post are reminded at most once per read_receipts_reminder.Cooldown.
+func (a *App) RemindPostNonReaders(rctx request.CTX, post *model.Post, remindedBy *model.User) (*model.Job, *model.AppError) {
+	existing, err := a.Srv().Store().Job().GetByTypeAndData(rctx, model.JobTypeReadReceiptsReminder, map[string]string{
+		"post_id": post.Id,
+	}, true, model.JobStatusPending, model.JobStatusInProgress, model.JobStatusSuccess)
+	if err != nil {
+		return nil, model.NewAppError("RemindPostNonReaders", "app.job.get_existing_jobs.error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	cooldownStart := model.GetMillis() - read_receipts_reminder.Cooldown.Milliseconds()
+	for _, job := range existing {
+		if job.Status != model.JobStatusSuccess || job.CreateAt >= cooldownStart {
+			return nil, model.NewAppError("RemindPostNonReaders", "app.read_receipts.remind.too_soon.app_error", map[string]any{"Minutes": int(read_receipts_reminder.Cooldown.Minutes())}, "job_id="+job.Id, http.StatusTooManyRequests)
+		}
+	}
+
+	return a.Srv().Jobs.CreateJob(rctx, model.JobTypeReadReceiptsReminder, map[string]string{
+		"post_id":     post.Id,
+		"reminded_by": remindedBy.Id,
+	})
+}
+
+// SendPostReadReminders sends a direct message from the system bot to the members who haven't read
+// the post, up to readReceiptsMaxReminders of them, and returns how many were reminded.
+func (a *App) SendPostReadReminders(rctx request.CTX, postId, remindedById string) (int, *model.AppError) {
+	post, appErr := a.GetSinglePost(rctx, postId, false)
+	if appErr != nil {
+		return 0, appErr
+	}
+
+	channel, appErr := a.GetChannel(rctx, post.ChannelId)
+	if appErr != nil {
+		return 0, appErr
+	}
+
+	remindedBy, appErr := a.GetUser(remindedById)
+	if appErr != nil {
+		return 0, appErr
+	}
+
+	systemBot, appErr := a.GetSystemBot(rctx)
+	if appErr != nil {
+		return 0, appErr
+	}
+
+	permalink, appErr := a.readReceiptsPermalink(channel, post)
+	if appErr != nil {
+		return 0, appErr
+	}
+
+	sharesByDefault := a.readStateSettingsDefault(channel)
+	reminded := 0
+	for page := 0; reminded < readReceiptsMaxReminders; page++ {
+		userIds, err := a.Srv().Store().ChannelReadCursor().GetNonReaders(channel.Id, post.RootId, post.CreateAt, post.UserId, sharesByDefault, page*readReceiptsRemindersPageSize, readReceiptsRemindersPageSize)
+		if err != nil {
+			return reminded, model.NewAppError("SendPostReadReminders", "app.read_receipts.get_non_readers.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+
+		users, appErr := a.orderedUsers(rctx, userIds)
+		if appErr != nil {
+			return reminded, appErr
+		}
+
+		for _, user := range users {
+			if reminded == readReceiptsMaxReminders {
+				break
+			}
+			if a.sendReadReminder(rctx, systemBot, user, remindedBy, post, permalink) {
+				reminded++
+			}
+		}
+
+		if len(userIds) < readReceiptsRemindersPageSize {
+			break
+		}
+	}
+
+	rctx.Logger().Debug("Reminded non readers of a post",
+		mlog.String("post_id", post.Id),
+		mlog.String("reminded_by", remindedBy.Id),
+		mlog.Int("reminded", reminded),
+	)
+
+	return reminded, nil
+}
+
+// sendReadReminder sends a single reminder and reports whether it was sent
+func (a *App) sendReadReminder(rctx request.CTX, systemBot *model.Bot, user, remindedBy *model.User, post *model.Post, permalink string) bool {
+	channel, appErr := a.GetOrCreateDirectChannel(rctx, user.Id, systemBot.UserId)
+	if appErr != nil {
+		rctx.Logger().Warn("Failed to get direct channel for read reminder", mlog.String("user_id", user.Id), mlog.Err(appErr))
+		return false
+	}
+
+	T := i18n.GetUserTranslations(user.Locale)
+	dm := &model.Post{
+		ChannelId: channel.Id,
+		UserId:    systemBot.UserId,
+		Message: T("app.read_receipts.remind_unread_dm", map[string]any{
+			"Username":  remindedBy.Username,
+			"Permalink": permalink,
+		}),
+		Props: model.StringInterface{
+			"post_id":  post.Id,
+			"username": remindedBy.Username,
+		},
+	}
+
+	if _, appErr := a.CreatePost(rctx, dm, channel, model.CreatePostFlags{SetOnline: true}); appErr != nil {
+		rctx.Logger().Warn("Failed to send read reminder", mlog.String("user_id", user.Id), mlog.Err(appErr))
+		return false
+	}
+
+	return true
+}
+
+// readReceiptsPermalink returns the permalink to the post, without a team for direct and group messages
+func (a *App) readReceiptsPermalink(channel *model.Channel, post *model.Post) (string, *model.AppError) {
+	siteURL := *a.Config().ServiceSettings.SiteURL
+	if channel.TeamId == "" {
+		return fmt.Sprintf("%s/pl/%s", siteURL, post.Id), nil
+	}
+
+	team, appErr := a.GetTeam(channel.TeamId)
+	if appErr != nil {
+		return "", appErr
+	}
+
+	return makePostLink(siteURL, team.Name, post.Id), nil
+}
+
+// GetChannelReadReceiptsSummary returns, for each of the last days, the share of the channel's
+// current members who have read the root posts created that day. Members who don't share their
+// read state count as not having read them.
+func (a *App) GetChannelReadReceiptsSummary(rctx request.CTX, channel *model.Channel, days int) (*model.ChannelReadReceiptsSummary, *model.AppError) {
+	if days <= 0 || days > ReadReceiptsSummaryMaxDays {
+		return nil, model.NewAppError("GetChannelReadReceiptsSummary", "app.read_receipts.summary.days.app_error", map[string]any{"Max": ReadReceiptsSummaryMaxDays}, "", http.StatusBadRequest)
+	}
+
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1-days).UnixMilli()
+
+	summaries, err := a.Srv().Store().ChannelReadCursor().GetDailyReadSummary(channel.Id, since)
+	if err != nil {
+		return nil, model.NewAppError("GetChannelReadReceiptsSummary", "app.read_receipts.summary.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
+	return model.NewChannelReadReceiptsSummary(channel.Id, since, summaries), nil
+}
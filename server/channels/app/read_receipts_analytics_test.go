@@ -0,0 +1,148 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs/read_receipts_reminder"
+)
+
+func TestGetPostNonReaders(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	hiding := th.CreateUser(t)
+	th.LinkUserToTeam(t, hiding, th.BasicTeam)
+	th.AddUserToChannel(t, hiding, th.BasicChannel)
+	appErr := th.App.UpdatePreferences(th.Context, hiding.Id, model.Preferences{
+		{UserId: hiding.Id, Category: model.PreferenceCategoryReadReceipts, Name: model.PreferenceNameShareReadState, Value: "false"},
+	})
+	require.Nil(t, appErr)
+
+	post := th.CreatePost(t, th.BasicChannel)
+
+	t.Run("the count matches the users left after filtering", func(t *testing.T) {
+		nonReaders, appErr := th.App.GetPostNonReaders(th.Context, th.BasicChannel, post, 0, 10)
+		require.Nil(t, appErr)
+		require.Len(t, nonReaders.Users, 1)
+		assert.Equal(t, th.BasicUser2.Id, nonReaders.Users[0].Id)
+		assert.Equal(t, int64(1), nonReaders.TotalCount)
+	})
+
+	t.Run("readers are left out", func(t *testing.T) {
+		_, err := th.App.Srv().Store().ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: th.BasicChannel.Id, UserId: th.BasicUser2.Id, LastPostSeq: post.CreateAt})
+		require.NoError(t, err)
+
+		nonReaders, appErr := th.App.GetPostNonReaders(th.Context, th.BasicChannel, post, 0, 10)
+		require.Nil(t, appErr)
+		assert.Empty(t, nonReaders.Users)
+		assert.Equal(t, int64(0), nonReaders.TotalCount)
+	})
+}
+
+func TestRemindPostNonReaders(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	t.Run("only channel admins may remind", func(t *testing.T) {
+		post := th.CreatePost(t, th.BasicChannel)
+		session := model.Session{UserId: th.BasicUser.Id, Roles: model.SystemUserRoleId}
+		assert.False(t, th.App.CanRemindPostNonReaders(th.Context, session, post), "authors may not remind")
+
+		_, appErr := th.App.UpdateChannelMemberSchemeRoles(th.Context, th.BasicChannel.Id, th.BasicUser.Id, false, true, true)
+		require.Nil(t, appErr)
+		defer func() {
+			_, appErr = th.App.UpdateChannelMemberSchemeRoles(th.Context, th.BasicChannel.Id, th.BasicUser.Id, false, true, false)
+			require.Nil(t, appErr)
+		}()
+		assert.True(t, th.App.CanRemindPostNonReaders(th.Context, session, post))
+	})
+
+	t.Run("reminders are scheduled once per cooldown", func(t *testing.T) {
+		post := th.CreatePost(t, th.BasicChannel)
+
+		job, appErr := th.App.RemindPostNonReaders(th.Context, post, th.BasicUser)
+		require.Nil(t, appErr)
+		assert.Equal(t, model.JobTypeReadReceiptsReminder, job.Type)
+		assert.Equal(t, post.Id, job.Data["post_id"])
+		assert.Equal(t, th.BasicUser.Id, job.Data["reminded_by"])
+
+		_, appErr = th.App.RemindPostNonReaders(th.Context, post, th.BasicUser)
+		require.NotNil(t, appErr)
+		assert.Equal(t, http.StatusTooManyRequests, appErr.StatusCode)
+
+		_, err := th.App.Srv().Store().Job().UpdateStatus(job.Id, model.JobStatusSuccess)
+		require.NoError(t, err)
+		_, appErr = th.App.RemindPostNonReaders(th.Context, post, th.BasicUser)
+		require.NotNil(t, appErr, "a reminder sent within the cooldown blocks the next one")
+	})
+
+	t.Run("reminders can be sent again after the cooldown", func(t *testing.T) {
+		post := th.CreatePost(t, th.BasicChannel)
+
+		_, err := th.App.Srv().Store().Job().Save(&model.Job{
+			Id:       model.NewId(),
+			Type:     model.JobTypeReadReceiptsReminder,
+			CreateAt: model.GetMillis() - (read_receipts_reminder.Cooldown + time.Minute).Milliseconds(),
+			Status:   model.JobStatusSuccess,
+			Data:     model.StringMap{"post_id": post.Id, "reminded_by": th.BasicUser.Id},
+		})
+		require.NoError(t, err)
+
+		_, appErr := th.App.RemindPostNonReaders(th.Context, post, th.BasicUser)
+		require.Nil(t, appErr)
+	})
+
+	t.Run("reminders go to the non readers", func(t *testing.T) {
+		post := th.CreatePost(t, th.BasicChannel)
+
+		reminded, appErr := th.App.SendPostReadReminders(th.Context, post.Id, th.BasicUser.Id)
+		require.Nil(t, appErr)
+		assert.Equal(t, 1, reminded)
+
+		_, err := th.App.Srv().Store().ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: th.BasicChannel.Id, UserId: th.BasicUser2.Id, LastPostSeq: post.CreateAt})
+		require.NoError(t, err)
+
+		reminded, appErr = th.App.SendPostReadReminders(th.Context, post.Id, th.BasicUser.Id)
+		require.Nil(t, appErr)
+		assert.Equal(t, 0, reminded)
+	})
+}
+
+func TestGetChannelReadReceiptsSummary(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	t.Run("the number of days is bounded", func(t *testing.T) {
+		_, appErr := th.App.GetChannelReadReceiptsSummary(th.Context, th.BasicChannel, 0)
+		require.NotNil(t, appErr)
+		assert.Equal(t, http.StatusBadRequest, appErr.StatusCode)
+
+		_, appErr = th.App.GetChannelReadReceiptsSummary(th.Context, th.BasicChannel, ReadReceiptsSummaryMaxDays+1)
+		require.NotNil(t, appErr)
+	})
+
+	t.Run("posts of the day are summarized", func(t *testing.T) {
+		channel := th.CreateChannel(t, th.BasicTeam)
+		th.AddUserToChannel(t, th.BasicUser2, channel)
+		post := th.CreatePost(t, channel)
+		_, err := th.App.Srv().Store().ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channel.Id, UserId: th.BasicUser2.Id, LastPostSeq: post.CreateAt})
+		require.NoError(t, err)
+
+		summary, appErr := th.App.GetChannelReadReceiptsSummary(th.Context, channel, 1)
+		require.Nil(t, appErr)
+		require.Len(t, summary.Days, 1)
+		assert.Equal(t, int64(1), summary.Days[0].PostCount)
+		assert.Equal(t, int64(1), summary.Days[0].ReadCount)
+		assert.Equal(t, int64(1), summary.Days[0].ExpectedCount)
+		assert.Equal(t, float64(100), summary.ReadPercentage)
+	})
+}
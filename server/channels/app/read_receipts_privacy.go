@@ -0,0 +1,270 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/platform/services/cache"
+)
+
+// Read receipts privacy
+//
+// A user's read state is recorded and shown to others only while they share it. The
+// share_read_state preference decides; when it is unset, the team default from
+// ReadReceiptsSettings.TeamShareReadStateDefaults applies, then ShareReadStateByDefault.
+// Channel admins can turn read receipts off for a channel with the read_receipts_disabled prop.
+//
+// Reciprocity: users who don't share their own read state can't see anyone else's.
+
+// sharesReadState resolves the effective sharing setting from the user's preference, if any
+func sharesReadState(preference *model.Preference, settings *model.ReadReceiptsSettings, teamId string) bool {
+	if preference != nil {
+		if share, err := strconv.ParseBool(preference.Value); err == nil {
+			return share
+		}
+	}
+	return settings.ShareReadStateDefault(teamId)
+}
+
+// SharesReadState reports whether the user's read state is recorded and visible to others in
+// channels of the given team. Use an empty teamId for direct and group messages.
+func (a *App) SharesReadState(userId, teamId string) (bool, *model.AppError) {
+	preference, err := a.Srv().Store().Preference().Get(userId, model.PreferenceCategoryReadReceipts, model.PreferenceNameShareReadState)
+	if err != nil {
+		var nfErr *store.ErrNotFound
+		if !errors.As(err, &nfErr) {
+			return false, model.NewAppError("SharesReadState", "app.preference.get.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		preference = nil
+	}
+
+	return sharesReadState(preference, &a.Config().ReadReceiptsSettings, teamId), nil
+}
+
+// readStateSettingsDefault returns whether users without a preference share their read state in the channel
+func (a *App) readStateSettingsDefault(channel *model.Channel) bool {
+	return a.Config().ReadReceiptsSettings.ShareReadStateDefault(channel.TeamId)
+}
+
+// hiddenReadStateReaders returns the users with a read cursor in the channel who don't share their
+// read state there. Cursors are removed when users stop sharing, so this is normally empty; it
+// covers the cursors left behind when the defaults change. The result is cached per channel until
+// a preference or the defaults change: users who don't share get no new cursors meanwhile.
+func (a *App) hiddenReadStateReaders(channel *model.Channel) (map[string]bool, *model.AppError) {
+	var userIds []string
+	if err := a.Srv().readStateHiddenReadersCache.Get(channel.Id, &userIds); err != nil {
+		if !errors.Is(err, cache.ErrKeyNotFound) {
+			a.Log().Warn("Failed to get hidden read state readers from the cache", mlog.String("channel_id", channel.Id), mlog.Err(err))
+		}
+
+		userIds, err = a.Srv().Store().ChannelReadCursor().GetNonSharingReaderIds(channel.Id, a.readStateSettingsDefault(channel))
+		if err != nil {
+			return nil, model.NewAppError("hiddenReadStateReaders", "app.channel.read_cursor.get_non_sharing.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+
+		if err := a.Srv().readStateHiddenReadersCache.SetWithDefaultExpiry(channel.Id, userIds); err != nil {
+			a.Log().Warn("Failed to cache hidden read state readers", mlog.String("channel_id", channel.Id), mlog.Err(err))
+		}
+	}
+
+	hidden := make(map[string]bool, len(userIds))
+	for _, userId := range userIds {
+		hidden[userId] = true
+	}
+	return hidden, nil
+}
+
+// filterHiddenReaders drops the cursors of the given hidden users
+func filterHiddenReaders(cursors []*model.ChannelReadCursor, hidden map[string]bool) []*model.ChannelReadCursor {
+	if len(hidden) == 0 {
+		return cursors
+	}
+
+	filtered := make([]*model.ChannelReadCursor, 0, len(cursors))
+	for _, cursor := range cursors {
+		if !hidden[cursor.UserId] {
+			filtered = append(filtered, cursor)
+		}
+	}
+	return filtered
+}
+
+// hiddenReadsCounter counts the reads of hidden users so they can be taken off the totals
+// returned by the read receipts provider
+type hiddenReadsCounter struct {
+	channelId string
+	userIds   []string
+	store     store.ChannelReadCursorStore
+	byRoot    map[string][]*model.ChannelReadCursor
+}
+
+func newHiddenReadsCounter(s store.ChannelReadCursorStore, channelId string, hidden map[string]bool) *hiddenReadsCounter {
+	userIds := make([]string, 0, len(hidden))
+	for userId := range hidden {
+		userIds = append(userIds, userId)
+	}
+	return &hiddenReadsCounter{channelId: channelId, userIds: userIds, store: s, byRoot: make(map[string][]*model.ChannelReadCursor)}
+}
+
+// count returns the number of hidden users matching the query
+func (c *hiddenReadsCounter) count(query ReadReceiptsQuery) (int64, error) {
+	if len(c.userIds) == 0 {
+		return 0, nil
+	}
+
+	cursors, ok := c.byRoot[query.RootId]
+	if !ok {
+		var err error
+		cursors, err = c.store.GetForChannelUsers(c.channelId, query.RootId, c.userIds)
+		if err != nil {
+			return 0, err
+		}
+		c.byRoot[query.RootId] = cursors
+	}
+
+	var count int64
+	for _, cursor := range cursors {
+		if cursor.LastPostSeq >= query.Seq && cursor.UserId != query.ExcludeUserId {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// CheckReadReceiptsVisible returns an error if the user may not see read receipts in the channel,
+// either because they are disabled there or because the user doesn't share their own read state.
+func (a *App) CheckReadReceiptsVisible(rctx request.CTX, userId string, channel *model.Channel) *model.AppError {
+	if channel.ReadReceiptsDisabled() {
+		return model.NewAppError("CheckReadReceiptsVisible", "api.post.read_receipts.disabled.app_error", nil, "", http.StatusForbidden)
+	}
+
+	shares, appErr := a.SharesReadState(userId, channel.TeamId)
+	if appErr != nil {
+		return appErr
+	}
+	if !shares {
+		return model.NewAppError("CheckReadReceiptsVisible", "api.post.read_receipts.reciprocity.app_error", nil, "", http.StatusForbidden)
+	}
+
+	return nil
+}
+
+// readReceiptsOmitUsers returns the channel members who don't share their read state and so
+// must not receive read_cursor_advanced events. Only the members who differ from the default are
+// queried, and the result is cached per channel until its members or their preferences change.
+func (a *App) readReceiptsOmitUsers(channel *model.Channel) (map[string]bool, *model.AppError) {
+	var memberIds []string
+	if err := a.Srv().readStateHiddenMembersCache.Get(channel.Id, &memberIds); err != nil {
+		if !errors.Is(err, cache.ErrKeyNotFound) {
+			a.Log().Warn("Failed to get hidden read state members from the cache", mlog.String("channel_id", channel.Id), mlog.Err(err))
+		}
+
+		memberIds, err = a.Srv().Store().ChannelReadCursor().GetNonSharingMemberIds(channel.Id, a.readStateSettingsDefault(channel))
+		if err != nil {
+			return nil, model.NewAppError("readReceiptsOmitUsers", "app.channel.read_cursor.get_non_sharing.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+
+		if err := a.Srv().readStateHiddenMembersCache.SetWithDefaultExpiry(channel.Id, memberIds); err != nil {
+			a.Log().Warn("Failed to cache hidden read state members", mlog.String("channel_id", channel.Id), mlog.Err(err))
+		}
+	}
+
+	omitUsers := make(map[string]bool, len(memberIds))
+	for _, memberId := range memberIds {
+		omitUsers[memberId] = true
+	}
+
+	return omitUsers, nil
+}
+
+// invalidateReadStateHiddenMembersCache drops the cached hidden members of a channel, or of all
+// channels when channelId is empty. The hidden readers don't depend on the channel members and
+// are only dropped along with all the hidden members.
+func (a *App) invalidateReadStateHiddenMembersCache(channelId string) {
+	hiddenCache := a.Srv().readStateHiddenMembersCache
+
+	var err error
+	if channelId == "" {
+		err = hiddenCache.Purge()
+		if err == nil {
+			err = a.Srv().readStateHiddenReadersCache.Purge()
+		}
+	} else {
+		err = hiddenCache.Remove(channelId)
+	}
+	if err != nil {
+		a.Log().Warn("Failed to invalidate the hidden read state members cache", mlog.String("channel_id", channelId), mlog.Err(err))
+	}
+
+	if cluster := a.Cluster(); cluster != nil && hiddenCache.GetInvalidateClusterEvent() != model.ClusterEventNone {
+		cluster.SendClusterMessage(&model.ClusterMessage{
+			Event:    hiddenCache.GetInvalidateClusterEvent(),
+			SendType: model.ClusterSendBestEffort,
+			Data:     []byte(channelId),
+		})
+	}
+}
+
+// handleShareReadStateChanged removes the user's read cursors where they may no longer be shared.
+// clearedPreference is true when the preference was deleted and the defaults apply again.
+func (a *App) handleShareReadStateChanged(rctx request.CTX, userId string, preference model.Preference, clearedPreference bool) {
+	a.invalidateReadStateHiddenMembersCache("")
+
+	if !clearedPreference {
+		if share, err := strconv.ParseBool(preference.Value); err == nil && !share {
+			a.removeReadCursorsForUser(rctx, userId, nil, true)
+		}
+		return
+	}
+
+	// Without a preference the user stops sharing wherever the default hides read state: in the
+	// teams overriding a sharing default, or everywhere but the teams overriding a hiding one
+	settings := a.Config().ReadReceiptsSettings
+	sharesByDefault := *settings.ShareReadStateByDefault
+	var teamIds []string
+	for teamId, share := range settings.TeamShareReadStateDefaults {
+		if share != sharesByDefault {
+			teamIds = append(teamIds, teamId)
+		}
+	}
+
+	if sharesByDefault && len(teamIds) == 0 {
+		return
+	}
+	a.removeReadCursorsForUser(rctx, userId, teamIds, !sharesByDefault)
+}
+
+// removeReadCursorsForUser deletes the user's read cursors in the channels of the given teams, or
+// outside of them when exceptTeams is set, and tells the read index service
+func (a *App) removeReadCursorsForUser(rctx request.CTX, userId string, teamIds []string, exceptTeams bool) {
+	events, err := a.Srv().Store().ChannelReadCursor().RemoveForUserInTeams(userId, teamIds, exceptTeams)
+	if err != nil {
+		rctx.Logger().Warn("Failed to remove read cursors for user", mlog.String("user_id", userId), mlog.Err(err))
+		return
+	}
+
+	for _, event := range events {
+		a.deliverReadCursorEvent(rctx, event)
+	}
+}
+
+// removeReadCursorsForChannel deletes all read cursors in the channel and tells the read index service
+func (a *App) removeReadCursorsForChannel(rctx request.CTX, channelId string) {
+	event, err := a.Srv().Store().ChannelReadCursor().RemoveForChannel(channelId)
+	if err != nil {
+		rctx.Logger().Warn("Failed to remove read cursors for channel", mlog.String("channel_id", channelId), mlog.Err(err))
+		return
+	}
+
+	if event != nil {
+		a.deliverReadCursorEvent(rctx, event)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestSharesReadState(t *testing.T) {
+	mainHelper.Parallel(t)
+
+	hidingTeamId := model.NewId()
+	settings := &model.ReadReceiptsSettings{
+		TeamShareReadStateDefaults: map[string]bool{hidingTeamId: false},
+	}
+	settings.SetDefaults()
+
+	preference := func(value string) *model.Preference {
+		return &model.Preference{
+			UserId:   model.NewId(),
+			Category: model.PreferenceCategoryReadReceipts,
+			Name:     model.PreferenceNameShareReadState,
+			Value:    value,
+		}
+	}
+
+	t.Run("falls back to the server default", func(t *testing.T) {
+		assert.True(t, sharesReadState(nil, settings, model.NewId()))
+		assert.True(t, sharesReadState(nil, settings, ""))
+	})
+
+	t.Run("falls back to the team default", func(t *testing.T) {
+		assert.False(t, sharesReadState(nil, settings, hidingTeamId))
+	})
+
+	t.Run("the user's preference wins over the defaults", func(t *testing.T) {
+		assert.False(t, sharesReadState(preference("false"), settings, model.NewId()))
+		assert.True(t, sharesReadState(preference("true"), settings, hidingTeamId))
+	})
+
+	t.Run("ignores invalid preference values", func(t *testing.T) {
+		assert.True(t, sharesReadState(preference("maybe"), settings, model.NewId()))
+		assert.False(t, sharesReadState(preference("maybe"), settings, hidingTeamId))
+	})
+}
+
+func TestReadReceiptsHiddenUsers(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	setShare := func(t *testing.T, user *model.User, value string) {
+		t.Helper()
+		appErr := th.App.UpdatePreferences(th.Context, user.Id, model.Preferences{
+			{UserId: user.Id, Category: model.PreferenceCategoryReadReceipts, Name: model.PreferenceNameShareReadState, Value: value},
+		})
+		require.Nil(t, appErr)
+	}
+	read := func(t *testing.T, user *model.User, post *model.Post) {
+		t.Helper()
+		_, err := th.App.Srv().Store().ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: post.ChannelId, UserId: user.Id, LastPostSeq: post.CreateAt})
+		require.NoError(t, err)
+	}
+
+	hiding := th.CreateUser(t)
+	th.LinkUserToTeam(t, hiding, th.BasicTeam)
+	th.AddUserToChannel(t, hiding, th.BasicChannel)
+	post := th.CreatePost(t, th.BasicChannel)
+
+	t.Run("only members who opted out are omitted from read cursor events", func(t *testing.T) {
+		setShare(t, hiding, "false")
+
+		omitUsers, appErr := th.App.readReceiptsOmitUsers(th.BasicChannel)
+		require.Nil(t, appErr)
+		assert.Equal(t, map[string]bool{hiding.Id: true}, omitUsers)
+
+		setShare(t, hiding, "true")
+		omitUsers, appErr = th.App.readReceiptsOmitUsers(th.BasicChannel)
+		require.Nil(t, appErr)
+		assert.Empty(t, omitUsers, "changing the preference must invalidate the cached set")
+	})
+
+	t.Run("counts and lists leave out the same readers", func(t *testing.T) {
+		read(t, th.BasicUser2, post)
+		read(t, hiding, post)
+
+		// Cursors left behind when the default changes are hidden rather than removed
+		th.App.UpdateConfig(func(cfg *model.Config) {
+			cfg.ReadReceiptsSettings.TeamShareReadStateDefaults = map[string]bool{th.BasicTeam.Id: false}
+		})
+		defer th.App.UpdateConfig(func(cfg *model.Config) {
+			cfg.ReadReceiptsSettings.TeamShareReadStateDefaults = nil
+		})
+		setShare(t, th.BasicUser2, "true")
+		appErr := th.App.DeletePreferences(th.Context, hiding.Id, model.Preferences{
+			{UserId: hiding.Id, Category: model.PreferenceCategoryReadReceipts, Name: model.PreferenceNameShareReadState},
+		})
+		require.Nil(t, appErr)
+		read(t, hiding, post)
+
+		cursors, count, appErr := th.App.GetPostReadReceipts(th.Context, th.BasicChannel, post, 10)
+		require.Nil(t, appErr)
+		require.Len(t, cursors, 1)
+		assert.Equal(t, th.BasicUser2.Id, cursors[0].UserId)
+		assert.Equal(t, int64(1), count)
+
+		counts, appErr := th.App.GetPostsReadReceiptsCounts(th.Context, []*model.Post{post})
+		require.Nil(t, appErr)
+		assert.Equal(t, int64(1), counts[post.Id])
+
+		cursors, appErr = th.App.GetChannelReadCursors(th.Context, th.BasicChannel)
+		require.Nil(t, appErr)
+		for _, cursor := range cursors {
+			assert.NotEqual(t, hiding.Id, cursor.UserId)
+		}
+	})
+
+	t.Run("hidden readers are cached until a preference changes", func(t *testing.T) {
+		channel := th.CreateChannel(t, th.BasicTeam)
+		read(t, hiding, th.CreatePost(t, channel))
+		setShare(t, hiding, "false")
+
+		hidden, appErr := th.App.hiddenReadStateReaders(channel)
+		require.Nil(t, appErr)
+		assert.Equal(t, map[string]bool{hiding.Id: true}, hidden)
+
+		// A cursor recorded behind the app's back isn't seen until the cache is invalidated
+		read(t, th.BasicUser2, th.CreatePost(t, channel))
+		err := th.App.Srv().Store().Preference().Save(model.Preferences{
+			{UserId: th.BasicUser2.Id, Category: model.PreferenceCategoryReadReceipts, Name: model.PreferenceNameShareReadState, Value: "false"},
+		})
+		require.NoError(t, err)
+		hidden, appErr = th.App.hiddenReadStateReaders(channel)
+		require.Nil(t, appErr)
+		assert.Equal(t, map[string]bool{hiding.Id: true}, hidden)
+
+		setShare(t, hiding, "true")
+		hidden, appErr = th.App.hiddenReadStateReaders(channel)
+		require.Nil(t, appErr)
+		assert.Equal(t, map[string]bool{th.BasicUser2.Id: true}, hidden, "changing a preference must invalidate the cached set")
+	})
+}
+
+func TestHandleShareReadStateChanged(t *testing.T) {
+	mainHelper.Parallel(t)
+	th := Setup(t).InitBasic(t)
+
+	hidingTeam := th.CreateTeam(t)
+	th.LinkUserToTeam(t, th.BasicUser, hidingTeam)
+	hidingChannel := th.CreateChannel(t, hidingTeam)
+	dmChannel := th.CreateDmChannel(t, th.BasicUser2)
+
+	th.App.UpdateConfig(func(cfg *model.Config) {
+		cfg.ReadReceiptsSettings.TeamShareReadStateDefaults = map[string]bool{hidingTeam.Id: false}
+	})
+
+	readAll := func(t *testing.T) {
+		t.Helper()
+		for _, channel := range []*model.Channel{th.BasicChannel, hidingChannel, dmChannel} {
+			_, err := th.App.Srv().Store().ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channel.Id, UserId: th.BasicUser.Id, LastPostSeq: model.GetMillis()})
+			require.NoError(t, err)
+		}
+	}
+	remaining := func(t *testing.T) []string {
+		t.Helper()
+		cursors, appErr := th.App.GetChannelReadCursorsForUser(th.Context, th.BasicUser.Id)
+		require.Nil(t, appErr)
+		channelIds := make([]string, len(cursors))
+		for i, cursor := range cursors {
+			channelIds[i] = cursor.ChannelId
+		}
+		return channelIds
+	}
+	preference := model.Preference{UserId: th.BasicUser.Id, Category: model.PreferenceCategoryReadReceipts, Name: model.PreferenceNameShareReadState}
+
+	t.Run("clearing the preference only removes cursors where the default hides read state", func(t *testing.T) {
+		readAll(t)
+		th.App.handleShareReadStateChanged(th.Context, th.BasicUser.Id, preference, true)
+		assert.ElementsMatch(t, []string{th.BasicChannel.Id, dmChannel.Id}, remaining(t))
+	})
+
+	t.Run("turning sharing off removes all cursors", func(t *testing.T) {
+		readAll(t)
+		preference.Value = "false"
+		th.App.handleShareReadStateChanged(th.Context, th.BasicUser.Id, preference, false)
+		assert.Empty(t, remaining(t))
+	})
+
+	t.Run("turning sharing on keeps the cursors", func(t *testing.T) {
+		readAll(t)
+		preference.Value = "true"
+		th.App.handleShareReadStateChanged(th.Context, th.BasicUser.Id, preference, false)
+		assert.Len(t, remaining(t), 3)
+	})
+}
@@ -0,0 +1,276 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+// ReadReceiptsQuery identifies a single read receipt lookup: the users whose read
+// cursor is at or past Seq, not counting ExcludeUserId (usually the post author).
+// For replies RootId is set, and cursors in that thread count as well.
+type ReadReceiptsQuery struct {
+	Seq           int64
+	RootId        string
+	ExcludeUserId string
+}
+
+// ReadReceiptsProvider answers read receipt queries for a channel.
+type ReadReceiptsProvider interface {
+	// GetReaders returns up to limit cursors matching the query, along with the total number of matches.
+	GetReaders(ctx context.Context, channelId string, query ReadReceiptsQuery, limit int) ([]*model.ChannelReadCursor, int64, error)
+
+	// GetReadCounts returns the number of matching cursors for each query, in order.
+	GetReadCounts(ctx context.Context, channelId string, queries []ReadReceiptsQuery) ([]int64, error)
+}
+
+// sqlReadReceiptsProvider answers read receipt queries directly from the channel_read_cursors and
+// thread_read_cursors tables.
+type sqlReadReceiptsProvider struct {
+	store store.ChannelReadCursorStore
+}
+
+func (p *sqlReadReceiptsProvider) GetReaders(ctx context.Context, channelId string, query ReadReceiptsQuery, limit int) ([]*model.ChannelReadCursor, int64, error) {
+	count, err := p.store.CountForSeq(channelId, query.RootId, query.Seq, query.ExcludeUserId)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if count == 0 {
+		return []*model.ChannelReadCursor{}, 0, nil
+	}
+
+	cursors, err := p.store.GetForSeq(channelId, query.RootId, query.Seq, query.ExcludeUserId, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return cursors, count, nil
+}
+
+func (p *sqlReadReceiptsProvider) GetReadCounts(ctx context.Context, channelId string, queries []ReadReceiptsQuery) ([]int64, error) {
+	seqQueries := make([]store.ReadCursorSeqQuery, len(queries))
+	for i, query := range queries {
+		seqQueries[i] = store.ReadCursorSeqQuery{Seq: query.Seq, RootId: query.RootId, ExcludeUserId: query.ExcludeUserId}
+	}
+
+	return p.store.CountForSeqs(channelId, seqQueries)
+}
+
+// readIndexServiceProvider answers read receipt queries using the RoaringBitmap based read-index-service.
+type readIndexServiceProvider struct {
+	baseURL    string
+	timeout    time.Duration
+	httpClient *http.Client
+	store      store.ChannelReadCursorStore
+}
+
+type readIndexReadersResponse struct {
+	Count   int64    `json:"count"`
+	Readers []string `json:"readers"`
+}
+
+type readIndexReadCountQuery struct {
+	Seq           int64  `json:"seq"`
+	RootId        string `json:"root_id,omitempty"`
+	ExcludeUserId string `json:"exclude_user_id,omitempty"`
+}
+
+type readIndexReadCountsRequest struct {
+	ChannelId string                    `json:"channel_id"`
+	Queries   []readIndexReadCountQuery `json:"queries"`
+}
+
+func (p *readIndexServiceProvider) GetReaders(ctx context.Context, channelId string, query ReadReceiptsQuery, limit int) ([]*model.ChannelReadCursor, int64, error) {
+	params := url.Values{}
+	params.Set("limit", fmt.Sprint(limit))
+	if query.ExcludeUserId != "" {
+		params.Set("exclude_user_id", query.ExcludeUserId)
+	}
+	if query.RootId != "" {
+		params.Set("root_id", query.RootId)
+	}
+	endpoint := fmt.Sprintf("%s/channels/%s/posts/%d/readers?%s", p.baseURL, url.PathEscape(channelId), query.Seq, params.Encode())
+
+	var resp readIndexReadersResponse
+	if err := p.do(ctx, http.MethodGet, endpoint, nil, &resp); err != nil {
+		return nil, 0, err
+	}
+
+	// The index only knows user ids, so load the cursors themselves for the returned page.
+	cursors, err := p.store.GetForChannelUsers(channelId, query.RootId, resp.Readers)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return cursors, resp.Count, nil
+}
+
+func (p *readIndexServiceProvider) GetReadCounts(ctx context.Context, channelId string, queries []ReadReceiptsQuery) ([]int64, error) {
+	req := readIndexReadCountsRequest{
+		ChannelId: channelId,
+		Queries:   make([]readIndexReadCountQuery, len(queries)),
+	}
+	for i, query := range queries {
+		req.Queries[i] = readIndexReadCountQuery{Seq: query.Seq, RootId: query.RootId, ExcludeUserId: query.ExcludeUserId}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var counts []int64
+	if err := p.do(ctx, http.MethodPost, p.baseURL+"/read-counts", body, &counts); err != nil {
+		return nil, err
+	}
+
+	if len(counts) != len(queries) {
+		return nil, errors.Errorf("read index service returned %d counts for %d queries", len(counts), len(queries))
+	}
+
+	return counts, nil
+}
+
+func (p *readIndexServiceProvider) do(ctx context.Context, method, endpoint string, body []byte, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to reach read index service")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("read index service returned status %d", resp.StatusCode)
+	}
+
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(out), "failed to decode read index service response")
+}
+
+// circuitBreakingReadReceiptsProvider prefers the read-index-service and falls back to SQL.
+// After CircuitBreakerFailureThreshold consecutive failures the service is skipped for
+// CircuitBreakerCooldownSeconds. Once the cooldown expires requests are let through again,
+// but a single further failure reopens the circuit until a request succeeds.
+type circuitBreakingReadReceiptsProvider struct {
+	config     func() *model.Config
+	store      func() store.Store
+	httpClient *http.Client
+	logger     mlog.LoggerIFace
+
+	mut       sync.Mutex
+	failures  int
+	openUntil time.Time
+	now       func() time.Time
+}
+
+func newReadReceiptsProvider(config func() *model.Config, store func() store.Store, httpClient *http.Client, logger mlog.LoggerIFace) *circuitBreakingReadReceiptsProvider {
+	return &circuitBreakingReadReceiptsProvider{
+		config:     config,
+		store:      store,
+		httpClient: httpClient,
+		logger:     logger,
+		now:        time.Now,
+	}
+}
+
+func (p *circuitBreakingReadReceiptsProvider) GetReaders(ctx context.Context, channelId string, query ReadReceiptsQuery, limit int) ([]*model.ChannelReadCursor, int64, error) {
+	if primary := p.primary(); primary != nil {
+		cursors, count, err := primary.GetReaders(ctx, channelId, query, limit)
+		if p.record(err) {
+			return cursors, count, nil
+		}
+	}
+
+	return p.fallback().GetReaders(ctx, channelId, query, limit)
+}
+
+func (p *circuitBreakingReadReceiptsProvider) GetReadCounts(ctx context.Context, channelId string, queries []ReadReceiptsQuery) ([]int64, error) {
+	if primary := p.primary(); primary != nil {
+		counts, err := primary.GetReadCounts(ctx, channelId, queries)
+		if p.record(err) {
+			return counts, nil
+		}
+	}
+
+	return p.fallback().GetReadCounts(ctx, channelId, queries)
+}
+
+func (p *circuitBreakingReadReceiptsProvider) fallback() ReadReceiptsProvider {
+	return &sqlReadReceiptsProvider{store: p.store().ChannelReadCursor()}
+}
+
+// primary returns the read-index-service provider, or nil if it is not configured or the circuit is open.
+func (p *circuitBreakingReadReceiptsProvider) primary() ReadReceiptsProvider {
+	settings := p.config().ReadReceiptsSettings
+	baseURL := strings.TrimRight(*settings.ReadIndexServiceURL, "/")
+	if baseURL == "" {
+		return nil
+	}
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.now().Before(p.openUntil) {
+		return nil
+	}
+
+	return &readIndexServiceProvider{
+		baseURL:    baseURL,
+		timeout:    time.Duration(*settings.ReadIndexServiceTimeoutMilliseconds) * time.Millisecond,
+		httpClient: p.httpClient,
+		store:      p.store().ChannelReadCursor(),
+	}
+}
+
+// record tracks the outcome of a call to the read-index-service and reports whether it succeeded.
+func (p *circuitBreakingReadReceiptsProvider) record(err error) bool {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if err == nil {
+		p.failures = 0
+		return true
+	}
+
+	settings := p.config().ReadReceiptsSettings
+	p.failures++
+	p.logger.Warn("Read index service request failed, falling back to database",
+		mlog.Int("consecutive_failures", p.failures),
+		mlog.Err(err),
+	)
+
+	if p.failures >= *settings.CircuitBreakerFailureThreshold {
+		cooldown := time.Duration(*settings.CircuitBreakerCooldownSeconds) * time.Second
+		p.openUntil = p.now().Add(cooldown)
+		p.logger.Error("Read index service circuit opened",
+			mlog.Duration("cooldown", cooldown),
+		)
+	}
+
+	return false
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
+)
+
+func TestCircuitBreakingReadReceiptsProvider(t *testing.T) {
+	mainHelper.Parallel(t)
+
+	channelId := model.NewId()
+	authorId := model.NewId()
+	queries := []ReadReceiptsQuery{{Seq: 100, ExcludeUserId: authorId}}
+
+	setup := func(t *testing.T, handler http.HandlerFunc) (*circuitBreakingReadReceiptsProvider, *mocks.ChannelReadCursorStore, *atomic.Int32) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			handler(w, r)
+		}))
+		t.Cleanup(server.Close)
+
+		cfg := &model.Config{}
+		cfg.SetDefaults()
+		cfg.ReadReceiptsSettings.ReadIndexServiceURL = model.NewPointer(server.URL)
+		cfg.ReadReceiptsSettings.CircuitBreakerFailureThreshold = model.NewPointer(2)
+
+		cursorStore := &mocks.ChannelReadCursorStore{}
+		mockStore := &mocks.Store{}
+		mockStore.On("ChannelReadCursor").Return(cursorStore)
+
+		p := newReadReceiptsProvider(
+			func() *model.Config { return cfg },
+			func() store.Store { return mockStore },
+			server.Client(),
+			mlog.CreateConsoleTestLogger(t),
+		)
+
+		return p, cursorStore, &calls
+	}
+
+	t.Run("uses the read index service when it is healthy", func(t *testing.T) {
+		p, cursorStore, calls := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			var req readIndexReadCountsRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			require.Equal(t, channelId, req.ChannelId)
+			require.Equal(t, authorId, req.Queries[0].ExcludeUserId)
+			require.NoError(t, json.NewEncoder(w).Encode([]int64{7}))
+		})
+
+		counts, err := p.GetReadCounts(context.Background(), channelId, queries)
+		require.NoError(t, err)
+		require.Equal(t, []int64{7}, counts)
+		require.Equal(t, int32(1), calls.Load())
+		cursorStore.AssertNotCalled(t, "CountForSeqs", mock.Anything, mock.Anything)
+	})
+
+	t.Run("falls back to the database and opens the circuit after repeated failures", func(t *testing.T) {
+		p, cursorStore, calls := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		})
+		cursorStore.On("CountForSeqs", channelId, []store.ReadCursorSeqQuery{{Seq: 100, ExcludeUserId: authorId}}).Return([]int64{3}, nil)
+
+		now := time.Now()
+		p.now = func() time.Time { return now }
+
+		for range 2 {
+			counts, err := p.GetReadCounts(context.Background(), channelId, queries)
+			require.NoError(t, err)
+			require.Equal(t, []int64{3}, counts)
+		}
+		require.Equal(t, int32(2), calls.Load())
+
+		// Circuit is open, the service is not contacted
+		_, err := p.GetReadCounts(context.Background(), channelId, queries)
+		require.NoError(t, err)
+		require.Equal(t, int32(2), calls.Load())
+
+		// Cooldown expired, the service is probed again and a single failure reopens the circuit
+		now = now.Add(time.Duration(model.ReadReceiptsSettingsDefaultCooldownSeconds+1) * time.Second)
+		_, err = p.GetReadCounts(context.Background(), channelId, queries)
+		require.NoError(t, err)
+		require.Equal(t, int32(3), calls.Load())
+
+		_, err = p.GetReadCounts(context.Background(), channelId, queries)
+		require.NoError(t, err)
+		require.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("hydrates readers returned by the read index service", func(t *testing.T) {
+		readerId := model.NewId()
+		p, cursorStore, _ := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, authorId, r.URL.Query().Get("exclude_user_id"))
+			require.NoError(t, json.NewEncoder(w).Encode(readIndexReadersResponse{Count: 1, Readers: []string{readerId}}))
+		})
+		cursor := &model.ChannelReadCursor{ChannelId: channelId, UserId: readerId, LastPostSeq: 120, UpdatedAt: 1}
+		cursorStore.On("GetForChannelUsers", channelId, "", []string{readerId}).Return([]*model.ChannelReadCursor{cursor}, nil)
+
+		cursors, count, err := p.GetReaders(context.Background(), channelId, queries[0], 10)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), count)
+		require.Equal(t, []*model.ChannelReadCursor{cursor}, cursors)
+	})
+
+	t.Run("passes the thread of replies to the read index service", func(t *testing.T) {
+		rootId := model.NewId()
+		readerId := model.NewId()
+		p, cursorStore, _ := setup(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				var req readIndexReadCountsRequest
+				require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+				require.Equal(t, rootId, req.Queries[0].RootId)
+				require.NoError(t, json.NewEncoder(w).Encode([]int64{1}))
+				return
+			}
+			require.Equal(t, rootId, r.URL.Query().Get("root_id"))
+			require.NoError(t, json.NewEncoder(w).Encode(readIndexReadersResponse{Count: 1, Readers: []string{readerId}}))
+		})
+		cursor := &model.ChannelReadCursor{ChannelId: channelId, UserId: readerId, LastPostSeq: 120, UpdatedAt: 1}
+		cursorStore.On("GetForChannelUsers", channelId, rootId, []string{readerId}).Return([]*model.ChannelReadCursor{cursor}, nil)
+
+		reply := ReadReceiptsQuery{Seq: 100, RootId: rootId, ExcludeUserId: authorId}
+		cursors, count, err := p.GetReaders(context.Background(), channelId, reply, 10)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), count)
+		require.Equal(t, []*model.ChannelReadCursor{cursor}, cursors)
+
+		counts, err := p.GetReadCounts(context.Background(), channelId, []ReadReceiptsQuery{reply})
+		require.NoError(t, err)
+		require.Equal(t, []int64{1}, counts)
+	})
+}
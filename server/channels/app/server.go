@@ -14,6 +14,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"reflect"
 	"strings"
 	"sync"
 	"syscall"
@@ -44,11 +45,13 @@ import (
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/delete_dms_preferences_migration"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/delete_empty_drafts_migration"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/delete_orphan_drafts_migration"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs/embedded_search_indexing"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/expirynotify"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/export_delete"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/export_process"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/export_users_to_csv"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/extract_content"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs/file_encryption_key_rotation"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/hosted_purchase_screening"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/import_delete"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/import_process"
@@ -60,6 +63,8 @@ import (
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/plugins"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/post_persistent_notifications"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/product_notices"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs/read_cursor_outbox"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs/read_receipts_reminder"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/refresh_materialized_views"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/resend_invitation_email"
 	"github.com/mattermost/mattermost/server/v8/channels/jobs/s3_path_migration"
@@ -111,17 +116,25 @@ type Server struct {
 	PushNotificationsHub   PushNotificationsHub
 	pushNotificationClient *http.Client // TODO: move this to it's own package
 	outgoingWebhookClient  *http.Client
+	readReceiptsProvider   ReadReceiptsProvider
 
 	runEssentialJobs bool
 	Jobs             *jobs.JobServer
 
 	timezones *timezones.Timezones
 
-	htmlTemplateWatcher     *templates.Container
-	seenPendingPostIdsCache cache.Cache
-	openGraphDataCache      cache.Cache
-	clusterLeaderListenerId string
-	loggerLicenseListenerId string
+	htmlTemplateWatcher         *templates.Container
+	seenPendingPostIdsCache     cache.Cache
+	openGraphDataCache          cache.Cache
+	readReceiptsCountsCache     cache.Cache
+	readStateHiddenMembersCache cache.Cache
+	readStateHiddenReadersCache cache.Cache
+	readReceiptsInvalidations   *readReceiptsInvalidations
+	clusterLeaderListenerId     string
+	loggerLicenseListenerId     string
+
+	// readReceiptsCountsGenerations is bumped before the counts of a channel are removed from the cache
+	readReceiptsCountsGenerations readReceiptsCountsGenerations
 
 	platform         *platform.PlatformService
 	platformOptions  []platform.Option
@@ -299,6 +312,7 @@ func NewServer(options ...Option) (*Server, error) {
 
 	s.pushNotificationClient = s.httpService.MakeClient(true)
 	s.outgoingWebhookClient = s.httpService.MakeClient(false)
+	s.readReceiptsProvider = newReadReceiptsProvider(s.Config, s.Store, s.httpService.MakeClient(true), s.Log())
 
 	if err2 := utils.TranslationsPreInit(); err2 != nil {
 		return nil, errors.Wrapf(err2, "unable to load Mattermost translation files")
@@ -317,6 +331,31 @@ func NewServer(options ...Option) (*Server, error) {
 	}); err != nil {
 		return nil, errors.Wrap(err, "Unable to create opengraphdata cache")
 	}
+	if s.readReceiptsCountsCache, err = s.platform.CacheProvider().NewCache(&cache.CacheOptions{
+		Name:                   "read_receipts_counts",
+		Size:                   readReceiptsCountsCacheSize,
+		DefaultExpiry:          readReceiptsCountsCacheTTL,
+		InvalidateClusterEvent: model.ClusterEventInvalidateCacheForReadReceiptsCounts,
+	}); err != nil {
+		return nil, errors.Wrap(err, "Unable to create read receipts counts cache")
+	}
+	s.readReceiptsInvalidations = newReadReceiptsInvalidations(readReceiptsInvalidationDelay, s.sendReadReceiptsCountsInvalidation)
+	if s.readStateHiddenMembersCache, err = s.platform.CacheProvider().NewCache(&cache.CacheOptions{
+		Name:                   "read_state_hidden_members",
+		Size:                   readStateHiddenMembersCacheSize,
+		DefaultExpiry:          readStateHiddenMembersCacheTTL,
+		InvalidateClusterEvent: model.ClusterEventInvalidateCacheForReadStateHiddenMembers,
+	}); err != nil {
+		return nil, errors.Wrap(err, "Unable to create read state hidden members cache")
+	}
+	// Invalidated along with the hidden members, whose cluster event purges both
+	if s.readStateHiddenReadersCache, err = s.platform.CacheProvider().NewCache(&cache.CacheOptions{
+		Name:          "read_state_hidden_readers",
+		Size:          readStateHiddenReadersCacheSize,
+		DefaultExpiry: readStateHiddenReadersCacheTTL,
+	}); err != nil {
+		return nil, errors.Wrap(err, "Unable to create read state hidden readers cache")
+	}
 
 	s.createPushNotificationsHub(request.EmptyContext(s.Log()))
 
@@ -349,6 +388,7 @@ func NewServer(options ...Option) (*Server, error) {
 	emailService, err := email.NewService(email.ServiceConfig{
 		ConfigFn:           s.platform.Config,
 		LicenseFn:          s.License,
+		IsLeaderFn:         s.IsLeader,
 		TemplatesContainer: s.TemplatesContainer(),
 		UserService:        s.userService,
 		Store:              s.GetStore(),
@@ -471,6 +511,21 @@ func NewServer(options ...Option) (*Server, error) {
 		}
 	})
 
+	// Who shares their read state depends on the defaults, so drop what was cached with the old ones
+	s.platform.AddConfigListener(func(oldCfg, newCfg *model.Config) {
+		if !reflect.DeepEqual(oldCfg.ReadReceiptsSettings, newCfg.ReadReceiptsSettings) {
+			if err = s.readStateHiddenMembersCache.Purge(); err != nil {
+				mlog.Error("Failed to purge hidden read state members cache after config change", mlog.Err(err))
+			}
+			if err = s.readStateHiddenReadersCache.Purge(); err != nil {
+				mlog.Error("Failed to purge hidden read state readers cache after config change", mlog.Err(err))
+			}
+			if err = s.readReceiptsCountsCache.Purge(); err != nil {
+				mlog.Error("Failed to purge read receipts counts cache after config change", mlog.Err(err))
+			}
+		}
+	})
+
 	return s, nil
 }
 
@@ -825,7 +880,7 @@ func (s *Server) Start() error {
 	err := s.FileBackend().TestConnection()
 	if err != nil {
 		if _, ok := err.(*filestore.S3FileBackendNoBucketError); ok {
-			err = s.FileBackend().(*filestore.S3FileBackend).MakeBucket()
+			err = filestore.UnwrapFileBackend(s.FileBackend()).(*filestore.S3FileBackend).MakeBucket()
 		}
 		if err != nil {
 			mlog.Error("Problem with file storage settings", mlog.Err(err))
@@ -878,7 +933,12 @@ func (s *Server) Start() error {
 	if *s.platform.Config().RateLimitSettings.Enable {
 		mlog.Info("RateLimiter is enabled")
 
-		rateLimiter, err2 := NewRateLimiter(&s.platform.Config().RateLimitSettings, s.platform.Config().ServiceSettings.TrustedProxyIPHeader)
+		store, err2 := s.NewRateLimitStore(&s.platform.Config().RateLimitSettings, "")
+		if err2 != nil {
+			return err2
+		}
+
+		rateLimiter, err2 := NewRateLimiter(&s.platform.Config().RateLimitSettings, s.platform.Config().ServiceSettings.TrustedProxyIPHeader, store)
 		if err2 != nil {
 			return err2
 		}
@@ -1500,6 +1560,16 @@ func (s *Server) initJobs() {
 		s3_path_migration.MakeWorker(s.Jobs, s.Store(), s.FileBackend()),
 		nil)
 
+	s.Jobs.RegisterJobType(
+		model.JobTypeFileEncryptionKeyRotation,
+		file_encryption_key_rotation.MakeWorker(s.Jobs, New(ServerConnector(s.Channels()))),
+		nil)
+
+	s.Jobs.RegisterJobType(
+		model.JobTypeReadReceiptsReminder,
+		read_receipts_reminder.MakeWorker(s.Jobs, New(ServerConnector(s.Channels()))),
+		nil)
+
 	s.Jobs.RegisterJobType(
 		model.JobTypeDeleteEmptyDraftsMigration,
 		delete_empty_drafts_migration.MakeWorker(s.Jobs, s.Store(), New(ServerConnector(s.Channels()))),
@@ -1546,6 +1616,13 @@ func (s *Server) initJobs() {
 		nil,
 	)
 
+	embeddedEngine, _ := s.platform.SearchEngine.EmbeddedEngine.(embedded_search_indexing.Indexer)
+	s.Jobs.RegisterJobType(
+		model.JobTypeEmbeddedSearchIndexing,
+		embedded_search_indexing.MakeWorker(s.Jobs, s.Store(), embeddedEngine),
+		nil,
+	)
+
 	s.Jobs.RegisterJobType(
 		model.JobTypeLastAccessiblePost,
 		last_accessible_post.MakeWorker(s.Jobs, s.License(), New(ServerConnector(s.Channels()))),
@@ -1594,6 +1671,12 @@ func (s *Server) initJobs() {
 		cleanup_desktop_tokens.MakeScheduler(s.Jobs),
 	)
 
+	s.Jobs.RegisterJobType(
+		model.JobTypeReadCursorOutbox,
+		read_cursor_outbox.MakeWorker(s.Jobs, s.Store(), s.GetMetrics(), New(ServerConnector(s.Channels()))),
+		read_cursor_outbox.MakeScheduler(s.Jobs),
+	)
+
 	s.Jobs.RegisterJobType(
 		model.JobTypeRefreshMaterializedViews,
 		refresh_materialized_views.MakeWorker(s.Jobs, *s.platform.Config().SqlSettings.DriverName),
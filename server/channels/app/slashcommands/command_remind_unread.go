@@ -0,0 +1,96 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package slashcommands
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/i18n"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/app"
+)
+
+type RemindUnreadProvider struct {
+}
+
+const (
+	CmdRemindUnread = "remind-unread"
+)
+
+func init() {
+	app.RegisterCommandProvider(&RemindUnreadProvider{})
+}
+
+func (*RemindUnreadProvider) GetTrigger() string {
+	return CmdRemindUnread
+}
+
+func (*RemindUnreadProvider) GetCommand(a *app.App, T i18n.TranslateFunc) *model.Command {
+	return &model.Command{
+		Trigger:          CmdRemindUnread,
+		AutoComplete:     true,
+		AutoCompleteDesc: T("api.command_remind_unread.desc"),
+		AutoCompleteHint: T("api.command_remind_unread.hint"),
+		DisplayName:      T("api.command_remind_unread.name"),
+	}
+}
+
+func (*RemindUnreadProvider) DoCommand(a *app.App, rctx request.CTX, args *model.CommandArgs, message string) *model.CommandResponse {
+	postId := remindUnreadPostId(message)
+	if postId == "" {
+		postId = args.RootId
+	}
+	if postId == "" {
+		return &model.CommandResponse{Text: args.T("api.command_remind_unread.usage"), ResponseType: model.CommandResponseTypeEphemeral}
+	}
+
+	post, appErr := a.GetSinglePost(rctx, postId, false)
+	if appErr != nil || !a.SessionHasPermissionToChannel(rctx, *rctx.Session(), post.ChannelId, model.PermissionReadChannelContent) {
+		return &model.CommandResponse{Text: args.T("api.command_remind_unread.not_found"), ResponseType: model.CommandResponseTypeEphemeral}
+	}
+
+	channel, appErr := a.GetChannel(rctx, post.ChannelId)
+	if appErr != nil {
+		return &model.CommandResponse{Text: args.T("api.command_remind_unread.not_found"), ResponseType: model.CommandResponseTypeEphemeral}
+	}
+
+	if appErr = a.CheckReadReceiptsVisible(rctx, args.UserId, channel); appErr != nil {
+		return &model.CommandResponse{Text: args.T("api.command_remind_unread.unavailable"), ResponseType: model.CommandResponseTypeEphemeral}
+	}
+
+	if !a.CanRemindPostNonReaders(rctx, *rctx.Session(), post) {
+		return &model.CommandResponse{Text: args.T("api.command_remind_unread.permission"), ResponseType: model.CommandResponseTypeEphemeral}
+	}
+
+	user, appErr := a.GetUser(args.UserId)
+	if appErr != nil {
+		return &model.CommandResponse{Text: args.T("api.command_remind_unread.error"), ResponseType: model.CommandResponseTypeEphemeral}
+	}
+
+	if _, appErr = a.RemindPostNonReaders(rctx, post, user); appErr != nil {
+		if appErr.StatusCode == http.StatusTooManyRequests {
+			appErr.Translate(args.T)
+			return &model.CommandResponse{Text: appErr.Message, ResponseType: model.CommandResponseTypeEphemeral}
+		}
+		return &model.CommandResponse{Text: args.T("api.command_remind_unread.error"), ResponseType: model.CommandResponseTypeEphemeral}
+	}
+
+	return &model.CommandResponse{Text: args.T("api.command_remind_unread.sent"), ResponseType: model.CommandResponseTypeEphemeral}
+}
+
+// remindUnreadPostId returns the id of the post given either as an id or as a permalink
+func remindUnreadPostId(message string) string {
+	message = strings.TrimSpace(message)
+	if i := strings.LastIndex(message, "/pl/"); i != -1 {
+		message = message[i+len("/pl/"):]
+	}
+	message = strings.TrimRight(message, "/")
+
+	if !model.IsValidId(message) {
+		return ""
+	}
+	return message
+}
@@ -0,0 +1,34 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package slashcommands
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+func TestRemindUnreadPostId(t *testing.T) {
+	postId := model.NewId()
+
+	for name, tc := range map[string]struct {
+		Message  string
+		Expected string
+	}{
+		"empty":             {Message: "", Expected: ""},
+		"id":                {Message: postId, Expected: postId},
+		"id with spaces":    {Message: "  " + postId + " ", Expected: postId},
+		"permalink":         {Message: "https://example.com/team/pl/" + postId, Expected: postId},
+		"permalink no team": {Message: "https://example.com/pl/" + postId + "/", Expected: postId},
+		"invalid id":        {Message: "not-a-post", Expected: ""},
+		"invalid permalink": {Message: "https://example.com/team/pl/abc", Expected: ""},
+		"channel link":      {Message: "https://example.com/team/channels/town-square", Expected: ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, remindUnreadPostId(tc.Message))
+		})
+	}
+}
@@ -25,7 +25,6 @@ import (
 	"github.com/mattermost/mattermost/server/public/shared/request"
 	"github.com/mattermost/mattermost/server/v8/channels/app/email"
 	"github.com/mattermost/mattermost/server/v8/channels/app/imaging"
-	"github.com/mattermost/mattermost/server/v8/channels/app/password/hashers"
 	"github.com/mattermost/mattermost/server/v8/channels/app/users"
 	"github.com/mattermost/mattermost/server/v8/channels/store"
 	"github.com/mattermost/mattermost/server/v8/einterfaces"
@@ -842,6 +841,10 @@ func (a *App) DeactivateMfa(userID string) *model.AppError {
 		return model.NewAppError("DeactivateMfa", "mfa.deactivate.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
 
+	if err := a.Srv().Store().MfaFactor().DeleteAllForUser(user.Id); err != nil {
+		return model.NewAppError("DeactivateMfa", "app.mfa_factor.delete_all.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
 	// Make sure old MFA status is not cached locally or in cluster nodes.
 	a.InvalidateCacheForUser(userID)
 
@@ -1466,6 +1469,12 @@ func (a *App) UpdateMfa(rctx request.CTX, activate bool, userID, token string) *
 		}
 	}
 
+	a.sendMfaChangeEmail(rctx, userID, activate)
+
+	return nil
+}
+
+func (a *App) sendMfaChangeEmail(rctx request.CTX, userID string, activated bool) {
 	a.Srv().Go(func() {
 		user, err := a.GetUser(userID)
 		if err != nil {
@@ -1473,12 +1482,10 @@ func (a *App) UpdateMfa(rctx request.CTX, activate bool, userID, token string) *
 			return
 		}
 
-		if err := a.Srv().EmailService.SendMfaChangeEmail(user.Email, activate, user.Locale, a.GetSiteURL()); err != nil {
+		if err := a.Srv().EmailService.SendMfaChangeEmail(user.Email, activated, user.Locale, a.GetSiteURL()); err != nil {
 			rctx.Logger().Error("Failed to send mfa change email", mlog.Err(err))
 		}
 	})
-
-	return nil
 }
 
 func (a *App) UpdatePasswordByUserIdSendEmail(rctx request.CTX, userID, newPassword, method string) *model.AppError {
@@ -1500,7 +1507,12 @@ func (a *App) UpdatePassword(rctx request.CTX, user *model.User, newPassword str
 		return model.NewAppError("UpdatePassword", "api.user.update_password.failed.app_error", nil, "", http.StatusInternalServerError)
 	}
 
-	hashedPassword, err := hashers.Hash(newPassword)
+	hasher, err := a.passwordHasher()
+	if err != nil {
+		return model.NewAppError("UpdatePassword", "api.user.update_password.password_hash.app_error", nil, "user_id="+user.Id, http.StatusInternalServerError).Wrap(err)
+	}
+
+	hashedPassword, err := hasher.Hash(newPassword)
 	if err != nil {
 		// can't be password length (checked in IsPasswordValid)
 		return model.NewAppError("UpdatePassword", "api.user.update_password.password_hash.app_error", nil, "user_id="+user.Id, http.StatusInternalServerError).Wrap(err)
@@ -1869,6 +1881,10 @@ func (a *App) PermanentDeleteUser(rctx request.CTX, user *model.User) *model.App
 		return model.NewAppError("PermanentDeleteUser", "app.user_access_token.delete.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
 
+	if err := a.Srv().Store().MfaFactor().DeleteAllForUser(user.Id); err != nil {
+		return model.NewAppError("PermanentDeleteUser", "app.mfa_factor.delete_all.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
+	}
+
 	if err := a.Srv().Store().OAuth().PermanentDeleteAuthDataByUser(user.Id); err != nil {
 		return model.NewAppError("PermanentDeleteUser", "app.oauth.permanent_delete_auth_data_by_user.app_error", nil, "", http.StatusInternalServerError).Wrap(err)
 	}
@@ -2961,6 +2977,11 @@ func (a *App) UpdateThreadReadForUser(rctx request.CTX, currentSessionId, userID
 	if nErr != nil {
 		return nil, model.NewAppError("UpdateThreadReadForUser", "app.user.update_thread_read_for_user.app_error", nil, "", http.StatusInternalServerError).Wrap(nErr)
 	}
+
+	// Feed read receipts for replies; failing to record them must not fail marking the thread as read
+	if _, appErr := a.AdvanceThreadReadCursor(rctx, userID, post, timestamp); appErr != nil {
+		rctx.Logger().Warn("Failed to advance thread read cursor", mlog.String("thread_id", threadID), mlog.Err(appErr))
+	}
 	thread, err := a.GetThreadForUser(rctx, membership, false)
 	if err != nil {
 		return nil, err
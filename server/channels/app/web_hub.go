@@ -26,6 +26,7 @@ func (ch *Channels) Publish(message *model.WebSocketEvent) {
 
 func (a *App) invalidateCacheForChannelMembers(channelID string) {
 	a.Srv().Platform().InvalidateCacheForChannelMembers(channelID)
+	a.invalidateReadStateHiddenMembersCache(channelID)
 }
 
 func (a *App) invalidateCacheForChannelMembersNotifyProps(channelID string) {
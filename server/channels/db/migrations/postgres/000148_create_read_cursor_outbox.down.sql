@@ -0,0 +1,5 @@
+-- Rollback migration for read_cursor_outbox table
+
+DROP INDEX IF EXISTS idx_read_cursor_outbox_event_id;
+DROP INDEX IF EXISTS idx_read_cursor_outbox_next_attempt_at;
+DROP TABLE IF EXISTS read_cursor_outbox;
@@ -0,0 +1,19 @@
+-- Create read_cursor_outbox table for reliable delivery of read cursor events
+-- Rows are written in the same transaction as channel_read_cursors and removed once published to Redis
+
+CREATE TABLE IF NOT EXISTS read_cursor_outbox (
+    id              BIGSERIAL PRIMARY KEY,
+    event_id        VARCHAR(26) NOT NULL,
+    channel_id      VARCHAR(26) NOT NULL,
+    payload         TEXT NOT NULL,
+    create_at       BIGINT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at BIGINT NOT NULL,
+    last_error      VARCHAR(1024) NOT NULL DEFAULT ''
+);
+
+-- Index for picking up entries that are due for delivery
+CREATE INDEX IF NOT EXISTS idx_read_cursor_outbox_next_attempt_at ON read_cursor_outbox(next_attempt_at);
+
+-- Index for removing entries published by the request that created them
+CREATE INDEX IF NOT EXISTS idx_read_cursor_outbox_event_id ON read_cursor_outbox(event_id);
@@ -0,0 +1,5 @@
+-- Rollback migration for thread_read_cursors table
+
+DROP INDEX IF EXISTS idx_thread_read_cursors_user;
+DROP INDEX IF EXISTS idx_thread_read_cursors_channel;
+DROP TABLE IF EXISTS thread_read_cursors;
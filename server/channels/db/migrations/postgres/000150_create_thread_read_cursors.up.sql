@@ -0,0 +1,20 @@
+-- Create thread_read_cursors table for read receipts on thread replies
+-- With collapsed reply threads users read replies in the thread view, which doesn't move their channel cursor
+
+CREATE TABLE IF NOT EXISTS thread_read_cursors (
+    root_id       VARCHAR(26) NOT NULL,
+    channel_id    VARCHAR(26) NOT NULL,
+    user_id       VARCHAR(26) NOT NULL,
+    last_post_seq BIGINT NOT NULL DEFAULT 0,
+    updated_at    BIGINT NOT NULL,
+    PRIMARY KEY (root_id, user_id)
+);
+
+-- Index for rebuilding and removing the cursors of a channel
+CREATE INDEX IF NOT EXISTS idx_thread_read_cursors_channel ON thread_read_cursors(channel_id, root_id, user_id);
+
+-- Index for removing the cursors of a user
+CREATE INDEX IF NOT EXISTS idx_thread_read_cursors_user ON thread_read_cursors(user_id);
+
+COMMENT ON TABLE thread_read_cursors IS 'Stores user reading progress cursors in threads for read receipts feature';
+COMMENT ON COLUMN thread_read_cursors.last_post_seq IS 'The timestamp up to which the user has read the replies in this thread';
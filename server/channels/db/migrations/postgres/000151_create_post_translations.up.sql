@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS PostTranslations (
+    PostId VARCHAR(26) NOT NULL,
+    Locale VARCHAR(16) NOT NULL,
+    SourceLocale VARCHAR(16) NOT NULL,
+    Message TEXT NOT NULL,
+    CreateAt BIGINT NOT NULL,
+    PRIMARY KEY (PostId, Locale)
+);
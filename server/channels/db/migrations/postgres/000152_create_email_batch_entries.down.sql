@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_emailbatchentries_userid_createat;
+DROP TABLE IF EXISTS EmailBatchEntries;
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS EmailBatchEntries (
+    Id VARCHAR(26) PRIMARY KEY,
+    UserId VARCHAR(26) NOT NULL,
+    PostId VARCHAR(26) NOT NULL,
+    ChannelId VARCHAR(26) NOT NULL,
+    TeamName VARCHAR(64) NOT NULL,
+    CreateAt BIGINT NOT NULL,
+    UNIQUE (UserId, PostId)
+);
+
+CREATE INDEX IF NOT EXISTS idx_emailbatchentries_userid_createat ON EmailBatchEntries(UserId, CreateAt);
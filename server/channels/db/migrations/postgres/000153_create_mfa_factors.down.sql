@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS MfaRecoveryCodes;
+DROP INDEX IF EXISTS idx_webauthncredentials_userid;
+DROP TABLE IF EXISTS WebAuthnCredentials;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS WebAuthnCredentials (
+    Id VARCHAR(26) PRIMARY KEY,
+    UserId VARCHAR(26) NOT NULL,
+    Name VARCHAR(256) NOT NULL,
+    CredentialId VARCHAR(1400) NOT NULL,
+    Credential TEXT NOT NULL,
+    CreateAt BIGINT NOT NULL,
+    LastUsedAt BIGINT NOT NULL DEFAULT 0,
+    UNIQUE (CredentialId)
+);
+
+CREATE INDEX IF NOT EXISTS idx_webauthncredentials_userid ON WebAuthnCredentials(UserId);
+
+CREATE TABLE IF NOT EXISTS MfaRecoveryCodes (
+    UserId VARCHAR(26) NOT NULL,
+    CodeHash VARCHAR(64) NOT NULL,
+    CreateAt BIGINT NOT NULL,
+    PRIMARY KEY (UserId, CodeHash)
+);
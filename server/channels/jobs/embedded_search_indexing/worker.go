@@ -0,0 +1,220 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embedded_search_indexing
+
+import (
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+// Indexer is the part of the embedded search engine used to index the existing content in bulk.
+type Indexer interface {
+	IsIndexingEnabled() bool
+	BulkIndexPosts(posts []*model.PostForIndexing) *model.AppError
+	BulkIndexFiles(files []*model.FileForIndexing) *model.AppError
+	BulkIndexUsers(users []*model.UserForIndexing) *model.AppError
+	SyncBulkIndexChannels(rctx request.CTX, channels []*model.Channel, getUserIDsForChannel func(channel *model.Channel) ([]string, error), teamMemberIDs []string) *model.AppError
+}
+
+func MakeWorker(jobServer *jobs.JobServer, store store.Store, engine Indexer) *jobs.SimpleWorker {
+	const workerName = "EmbeddedSearchIndexing"
+
+	isEnabled := func(cfg *model.Config) bool {
+		return *cfg.EmbeddedSearchSettings.EnableIndexing
+	}
+	execute := func(logger mlog.LoggerIFace, job *model.Job) error {
+		defer jobServer.HandleJobPanic(logger, job)
+
+		if engine == nil || !engine.IsIndexingEnabled() {
+			return errors.New("the embedded search engine is not started")
+		}
+
+		if job.Data == nil {
+			job.Data = make(model.StringMap)
+		}
+
+		idx := &indexer{
+			store:     store,
+			engine:    engine,
+			rctx:      request.EmptyContext(logger),
+			batchSize: *jobServer.Config().EmbeddedSearchSettings.BatchSize,
+			progress: func(key string, count int) {
+				job.Data[key] = strconv.Itoa(count)
+				if err := jobServer.UpdateInProgressJobData(job); err != nil {
+					logger.Warn("Failed to update the progress of the job", mlog.Err(err))
+				}
+			},
+		}
+		return idx.indexAll()
+	}
+	return jobs.NewSimpleWorker(workerName, jobServer, execute, isEnabled)
+}
+
+// indexer walks the posts, files, channels and users in creation order and indexes them in batches.
+type indexer struct {
+	store     store.Store
+	engine    Indexer
+	rctx      request.CTX
+	batchSize int
+	progress  func(key string, count int)
+}
+
+func (i *indexer) indexAll() error {
+	for _, step := range []struct {
+		key   string
+		index func() (int, error)
+	}{
+		{"posts_indexed", i.indexPosts},
+		{"files_indexed", i.indexFiles},
+		{"channels_indexed", i.indexChannels},
+		{"users_indexed", i.indexUsers},
+	} {
+		count, err := step.index()
+		i.progress(step.key, count)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *indexer) indexPosts() (int, error) {
+	var startTime int64
+	var startID string
+	var count int
+	for {
+		posts, err := i.store.Post().GetPostsBatchForIndexing(startTime, startID, i.batchSize)
+		if err != nil {
+			return count, errors.Wrap(err, "failed to get a batch of posts")
+		}
+		if len(posts) == 0 {
+			return count, nil
+		}
+
+		if appErr := i.engine.BulkIndexPosts(posts); appErr != nil {
+			return count, appErr
+		}
+		count += len(posts)
+		i.progress("posts_indexed", count)
+
+		last := posts[len(posts)-1]
+		startTime, startID = last.CreateAt, last.Id
+		if len(posts) < i.batchSize {
+			return count, nil
+		}
+	}
+}
+
+func (i *indexer) indexFiles() (int, error) {
+	var startTime int64
+	var startID string
+	var count int
+	for {
+		files, err := i.store.FileInfo().GetFilesBatchForIndexing(startTime, startID, true, i.batchSize)
+		if err != nil {
+			return count, errors.Wrap(err, "failed to get a batch of files")
+		}
+		if len(files) == 0 {
+			return count, nil
+		}
+
+		if appErr := i.engine.BulkIndexFiles(files); appErr != nil {
+			return count, appErr
+		}
+		count += len(files)
+		i.progress("files_indexed", count)
+
+		last := files[len(files)-1]
+		startTime, startID = last.CreateAt, last.Id
+		if len(files) < i.batchSize {
+			return count, nil
+		}
+	}
+}
+
+// indexChannels indexes the channels team by team, as the members of the team can find its
+// public channels.
+func (i *indexer) indexChannels() (int, error) {
+	getUserIDsForChannel := func(channel *model.Channel) ([]string, error) {
+		return i.store.Channel().GetAllChannelMemberIdsByChannelId(channel.Id)
+	}
+
+	var startTime int64
+	var startID string
+	var count int
+	for {
+		channels, err := i.store.Channel().GetChannelsBatchForIndexing(startTime, startID, i.batchSize)
+		if err != nil {
+			return count, errors.Wrap(err, "failed to get a batch of channels")
+		}
+		if len(channels) == 0 {
+			return count, nil
+		}
+
+		var teamIDs []string
+		byTeam := make(map[string][]*model.Channel)
+		for _, channel := range channels {
+			if _, ok := byTeam[channel.TeamId]; !ok {
+				teamIDs = append(teamIDs, channel.TeamId)
+			}
+			byTeam[channel.TeamId] = append(byTeam[channel.TeamId], channel)
+		}
+
+		for _, teamID := range teamIDs {
+			teamChannels := byTeam[teamID]
+			var teamMemberIDs []string
+			if teamID != "" {
+				teamMemberIDs, err = i.store.Channel().GetTeamMembersForChannel(i.rctx, teamChannels[0].Id)
+				if err != nil {
+					return count, errors.Wrapf(err, "failed to get the members of team %s", teamID)
+				}
+			}
+			if appErr := i.engine.SyncBulkIndexChannels(i.rctx, teamChannels, getUserIDsForChannel, teamMemberIDs); appErr != nil {
+				return count, appErr
+			}
+		}
+		count += len(channels)
+		i.progress("channels_indexed", count)
+
+		last := channels[len(channels)-1]
+		startTime, startID = last.CreateAt, last.Id
+		if len(channels) < i.batchSize {
+			return count, nil
+		}
+	}
+}
+
+func (i *indexer) indexUsers() (int, error) {
+	var startTime int64
+	var startID string
+	var count int
+	for {
+		users, err := i.store.User().GetUsersBatchForIndexing(startTime, startID, i.batchSize)
+		if err != nil {
+			return count, errors.Wrap(err, "failed to get a batch of users")
+		}
+		if len(users) == 0 {
+			return count, nil
+		}
+
+		if appErr := i.engine.BulkIndexUsers(users); appErr != nil {
+			return count, appErr
+		}
+		count += len(users)
+		i.progress("users_indexed", count)
+
+		last := users[len(users)-1]
+		startTime, startID = last.CreateAt, last.Id
+		if len(users) < i.batchSize {
+			return count, nil
+		}
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embedded_search_indexing
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
+)
+
+type fakeIndexer struct {
+	posts        []*model.PostForIndexing
+	files        []*model.FileForIndexing
+	users        []*model.UserForIndexing
+	channels     []*model.Channel
+	channelUsers map[string][]string
+	teamMembers  map[string][]string
+}
+
+func (f *fakeIndexer) IsIndexingEnabled() bool { return true }
+
+func (f *fakeIndexer) BulkIndexPosts(posts []*model.PostForIndexing) *model.AppError {
+	f.posts = append(f.posts, posts...)
+	return nil
+}
+
+func (f *fakeIndexer) BulkIndexFiles(files []*model.FileForIndexing) *model.AppError {
+	f.files = append(f.files, files...)
+	return nil
+}
+
+func (f *fakeIndexer) BulkIndexUsers(users []*model.UserForIndexing) *model.AppError {
+	f.users = append(f.users, users...)
+	return nil
+}
+
+func (f *fakeIndexer) SyncBulkIndexChannels(rctx request.CTX, channels []*model.Channel, getUserIDsForChannel func(channel *model.Channel) ([]string, error), teamMemberIDs []string) *model.AppError {
+	for _, channel := range channels {
+		userIDs, err := getUserIDsForChannel(channel)
+		if err != nil {
+			return model.NewAppError("SyncBulkIndexChannels", "test", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		f.channels = append(f.channels, channel)
+		f.channelUsers[channel.Id] = userIDs
+		f.teamMembers[channel.Id] = teamMemberIDs
+	}
+	return nil
+}
+
+func TestIndexAll(t *testing.T) {
+	setup := func(t *testing.T) (*indexer, *fakeIndexer, *mocks.Store, map[string]int) {
+		mockStore := &mocks.Store{}
+		engine := &fakeIndexer{channelUsers: map[string][]string{}, teamMembers: map[string][]string{}}
+		progress := map[string]int{}
+		return &indexer{
+			store:     mockStore,
+			engine:    engine,
+			rctx:      request.TestContext(t),
+			batchSize: 2,
+			progress:  func(key string, count int) { progress[key] = count },
+		}, engine, mockStore, progress
+	}
+
+	posts := []*model.PostForIndexing{
+		{Post: model.Post{Id: "post1", CreateAt: 1}},
+		{Post: model.Post{Id: "post2", CreateAt: 2}},
+		{Post: model.Post{Id: "post3", CreateAt: 2}},
+	}
+	files := []*model.FileForIndexing{{FileInfo: model.FileInfo{Id: "file1", CreateAt: 1}}}
+	channels := []*model.Channel{
+		{Id: "channel1", TeamId: "team1", CreateAt: 1},
+		{Id: "channel2", TeamId: "team2", CreateAt: 2},
+	}
+	users := []*model.UserForIndexing{{Id: "user1", CreateAt: 1}}
+
+	t.Run("indexes everything in batches", func(t *testing.T) {
+		idx, engine, mockStore, progress := setup(t)
+
+		postStore := &mocks.PostStore{}
+		postStore.On("GetPostsBatchForIndexing", int64(0), "", 2).Return(posts[:2], nil).Once()
+		postStore.On("GetPostsBatchForIndexing", int64(2), "post2", 2).Return(posts[2:], nil).Once()
+		fileStore := &mocks.FileInfoStore{}
+		fileStore.On("GetFilesBatchForIndexing", int64(0), "", true, 2).Return(files, nil).Once()
+		channelStore := &mocks.ChannelStore{}
+		channelStore.On("GetChannelsBatchForIndexing", int64(0), "", 2).Return(channels, nil).Once()
+		channelStore.On("GetChannelsBatchForIndexing", int64(2), "channel2", 2).Return([]*model.Channel{}, nil).Once()
+		channelStore.On("GetTeamMembersForChannel", mock.Anything, "channel1").Return([]string{"user1"}, nil).Once()
+		channelStore.On("GetTeamMembersForChannel", mock.Anything, "channel2").Return([]string{"user2"}, nil).Once()
+		channelStore.On("GetAllChannelMemberIdsByChannelId", "channel1").Return([]string{"user1"}, nil).Once()
+		channelStore.On("GetAllChannelMemberIdsByChannelId", "channel2").Return([]string{}, nil).Once()
+		userStore := &mocks.UserStore{}
+		userStore.On("GetUsersBatchForIndexing", int64(0), "", 2).Return(users, nil).Once()
+
+		mockStore.On("Post").Return(postStore)
+		mockStore.On("FileInfo").Return(fileStore)
+		mockStore.On("Channel").Return(channelStore)
+		mockStore.On("User").Return(userStore)
+
+		require.NoError(t, idx.indexAll())
+
+		assert.Equal(t, posts, engine.posts)
+		assert.Equal(t, files, engine.files)
+		assert.Equal(t, channels, engine.channels)
+		assert.Equal(t, users, engine.users)
+		assert.Equal(t, []string{"user1"}, engine.teamMembers["channel1"])
+		assert.Equal(t, []string{"user2"}, engine.teamMembers["channel2"])
+		assert.Equal(t, []string{"user1"}, engine.channelUsers["channel1"])
+		assert.Equal(t, map[string]int{"posts_indexed": 3, "files_indexed": 1, "channels_indexed": 2, "users_indexed": 1}, progress)
+
+		postStore.AssertExpectations(t)
+		fileStore.AssertExpectations(t)
+		channelStore.AssertExpectations(t)
+		userStore.AssertExpectations(t)
+	})
+
+	t.Run("stops on store errors", func(t *testing.T) {
+		idx, engine, mockStore, progress := setup(t)
+
+		postStore := &mocks.PostStore{}
+		postStore.On("GetPostsBatchForIndexing", int64(0), "", 2).Return(posts[:2], nil).Once()
+		postStore.On("GetPostsBatchForIndexing", int64(2), "post2", 2).Return(nil, errors.New("connection lost")).Once()
+		mockStore.On("Post").Return(postStore)
+
+		require.Error(t, idx.indexAll())
+		assert.Equal(t, posts[:2], engine.posts)
+		assert.Equal(t, map[string]int{"posts_indexed": 2}, progress)
+		postStore.AssertExpectations(t)
+	})
+}
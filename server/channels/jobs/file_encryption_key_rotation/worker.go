@@ -0,0 +1,104 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package file_encryption_key_rotation
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+type AppIface interface {
+	FileBackend() filestore.FileBackend
+	ExportFileBackend() filestore.FileBackend
+}
+
+// KeyRotator is implemented by the file backends able to wrap the data keys of their files with
+// the current master key.
+type KeyRotator interface {
+	ListDirectoryRecursively(path string) ([]string, error)
+	RewrapFile(path string) (bool, error)
+}
+
+// MakeWorker returns a worker wrapping the data keys of the encrypted files with the current
+// master key, after which the previous master keys can be removed from the configuration. While
+// unencrypted files are allowed, it also encrypts the files stored before encryption was enabled.
+func MakeWorker(jobServer *jobs.JobServer, app AppIface) *jobs.SimpleWorker {
+	const workerName = "FileEncryptionKeyRotation"
+
+	isEnabled := func(cfg *model.Config) bool {
+		return *cfg.FileSettings.EncryptAtRest || (*cfg.FileSettings.DedicatedExportStore && *cfg.FileSettings.ExportEncryptAtRest)
+	}
+	execute := func(logger mlog.LoggerIFace, job *model.Job) error {
+		defer jobServer.HandleJobPanic(logger, job)
+
+		backends := []filestore.FileBackend{app.FileBackend()}
+		if exportBackend := app.ExportFileBackend(); exportBackend != app.FileBackend() {
+			backends = append(backends, exportBackend)
+		}
+
+		var rewrapped, failed int
+		for _, backend := range backends {
+			rotator, ok := backend.(KeyRotator)
+			if !ok {
+				continue
+			}
+
+			r, f, err := rotateKeys(logger, rotator)
+			rewrapped += r
+			failed += f
+			if err != nil {
+				return err
+			}
+		}
+
+		if job.Data == nil {
+			job.Data = make(model.StringMap)
+		}
+		job.Data["rewrapped_file_count"] = strconv.Itoa(rewrapped)
+		job.Data["failed_file_count"] = strconv.Itoa(failed)
+
+		if failed > 0 {
+			return errors.Errorf("failed to rewrap the data keys of %d files", failed)
+		}
+		return nil
+	}
+	return jobs.NewSimpleWorker(workerName, jobServer, execute, isEnabled)
+}
+
+// rotateKeys rewraps the data keys of all the files of a backend, returning the number of files
+// rewrapped and the number of files that failed. Uploads in progress are left to the next run,
+// once they are moved to their final path.
+func rotateKeys(logger mlog.LoggerIFace, rotator KeyRotator) (int, int, error) {
+	paths, err := rotator.ListDirectoryRecursively("")
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "failed to list the files")
+	}
+
+	var rewrapped, failed int
+	for _, path := range paths {
+		if strings.HasSuffix(path, model.IncompleteUploadSuffix) {
+			continue
+		}
+
+		ok, err := rotator.RewrapFile(path)
+		if err != nil {
+			logger.Warn("Worker: Failed to rewrap the data key of the file", mlog.String("path", path), mlog.Err(err))
+			failed++
+			continue
+		}
+		if ok {
+			rewrapped++
+		}
+	}
+
+	logger.Info("Worker: Rewrapped the data keys of the files", mlog.Int("rewrapped", rewrapped), mlog.Int("failed", failed))
+	return rewrapped, failed, nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package file_encryption_key_rotation
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/platform/shared/filestore"
+)
+
+type failingRotator struct {
+	KeyRotator
+	failing string
+}
+
+func (r *failingRotator) RewrapFile(path string) (bool, error) {
+	if path == r.failing {
+		return false, errors.New("rewrap failed")
+	}
+	return r.KeyRotator.RewrapFile(path)
+}
+
+func newMasterKey(t *testing.T) []byte {
+	t.Helper()
+
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func newBackend(t *testing.T, dir string, allowUnencrypted bool, keys ...[]byte) *filestore.EncryptedFileBackend {
+	t.Helper()
+
+	local, err := filestore.NewFileBackend(filestore.FileBackendSettings{DriverName: model.ImageDriverLocal, Directory: dir})
+	require.NoError(t, err)
+	backend, err := filestore.NewEncryptedFileBackend(local, keys, allowUnencrypted)
+	require.NoError(t, err)
+	return backend
+}
+
+func TestRotateKeys(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := newMasterKey(t)
+	newKey := newMasterKey(t)
+
+	oldBackend := newBackend(t, dir, false, oldKey)
+	for _, path := range []string{"a/file1", "a/b/file2", "file3"} {
+		_, err := oldBackend.WriteFile(bytes.NewReader([]byte(path)), path)
+		require.NoError(t, err)
+	}
+	_, err := oldBackend.Unwrap().WriteFile(bytes.NewReader([]byte("legacy")), "legacy")
+	require.NoError(t, err)
+	upload := "upload" + model.IncompleteUploadSuffix
+	_, err = oldBackend.WriteFile(bytes.NewReader([]byte(upload)), upload)
+	require.NoError(t, err)
+
+	logger := mlog.CreateConsoleTestLogger(t)
+
+	t.Run("unencrypted files fail unless allowed", func(t *testing.T) {
+		rewrapped, failed, err := rotateKeys(logger, &failingRotator{KeyRotator: newBackend(t, dir, false, newKey, oldKey), failing: "file3"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, rewrapped)
+		assert.Equal(t, 2, failed)
+	})
+
+	backend := newBackend(t, dir, true, newKey, oldKey)
+	rewrapped, failed, err := rotateKeys(logger, backend)
+	require.NoError(t, err)
+	assert.Equal(t, 2, rewrapped, "the file left with the previous key and the unencrypted file should be rewritten")
+	assert.Zero(t, failed)
+
+	newOnly := newBackend(t, dir, false, newKey)
+	for _, path := range []string{"a/file1", "a/b/file2", "file3", "legacy"} {
+		data, err := newOnly.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, path, string(data))
+	}
+
+	t.Run("uploads in progress are skipped", func(t *testing.T) {
+		data, err := oldBackend.ReadFile(upload)
+		require.NoError(t, err)
+		assert.Equal(t, upload, string(data))
+	})
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package read_cursor_outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+	"github.com/mattermost/mattermost/server/v8/einterfaces"
+)
+
+const (
+	batchSize = 500
+	// maxBatchesPerRun bounds a single run; anything left is picked up by the next one.
+	maxBatchesPerRun = 100
+
+	// publishAttempts is the number of times an entry is retried within a run before it is backed off.
+	publishAttempts = 3
+	retryInterval   = 200 * time.Millisecond
+
+	minBackoff = 5 * time.Second
+	maxBackoff = 10 * time.Minute
+)
+
+type AppIface interface {
+	PublishReadCursorEventPayload(ctx context.Context, payload string) error
+}
+
+func MakeWorker(jobServer *jobs.JobServer, store store.Store, metrics einterfaces.MetricsInterface, app AppIface) *jobs.SimpleWorker {
+	const workerName = "ReadCursorOutbox"
+
+	isEnabled := func(cfg *model.Config) bool {
+		return true
+	}
+	execute := func(logger mlog.LoggerIFace, job *model.Job) error {
+		defer jobServer.HandleJobPanic(logger, job)
+
+		d := &drainer{
+			outbox:  store.ReadCursorOutbox(),
+			metrics: metrics,
+			publish: app.PublishReadCursorEventPayload,
+			logger:  logger,
+			now:     time.Now,
+			sleep:   time.Sleep,
+		}
+		return d.drain(context.Background())
+	}
+	return jobs.NewSimpleWorker(workerName, jobServer, execute, isEnabled)
+}
+
+// drainer publishes due read_cursor_outbox entries to the read_cursor_events stream in insertion order.
+type drainer struct {
+	outbox  store.ReadCursorOutboxStore
+	metrics einterfaces.MetricsInterface
+	publish func(ctx context.Context, payload string) error
+	logger  mlog.LoggerIFace
+	now     func() time.Time
+	sleep   func(time.Duration)
+}
+
+func (d *drainer) drain(ctx context.Context) error {
+	published := 0
+	defer func() {
+		d.reportBacklog()
+		if published > 0 {
+			d.logger.Info("Published read cursor events from the outbox", mlog.Int("count", published))
+		}
+	}()
+
+	for range maxBatchesPerRun {
+		entries, err := d.outbox.GetDue(d.now().UnixMilli(), batchSize)
+		if err != nil {
+			return errors.Wrap(err, "failed to get read cursor outbox entries")
+		}
+
+		done := make([]int64, 0, len(entries))
+		var failed *model.ReadCursorOutboxEntry
+		var publishErr error
+		for _, entry := range entries {
+			if publishErr = d.publishWithRetry(ctx, entry); publishErr != nil {
+				failed = entry
+				break
+			}
+			done = append(done, entry.Id)
+		}
+
+		if err := d.outbox.Delete(done); err != nil {
+			// The entries are published again on the next run, which is harmless
+			return errors.Wrap(err, "failed to delete published read cursor outbox entries")
+		}
+		published += len(done)
+		if d.metrics != nil && len(done) > 0 {
+			d.metrics.IncrementReadCursorOutboxPublished(len(done))
+		}
+
+		if failed != nil {
+			// Redis is most likely unavailable, so stop here and back off the entry at the head
+			// of the queue; the remaining entries are retried on the next run.
+			if d.metrics != nil {
+				d.metrics.IncrementReadCursorOutboxPublishFailures(1)
+			}
+			nextAttemptAt := d.now().Add(backoff(failed.Attempts + 1)).UnixMilli()
+			if err := d.outbox.MarkFailed(failed.Id, nextAttemptAt, publishErr.Error()); err != nil {
+				return errors.Wrap(err, "failed to record read cursor outbox failure")
+			}
+			d.logger.Warn("Failed to publish read cursor event from the outbox",
+				mlog.String("event_id", failed.EventId),
+				mlog.Int("attempts", failed.Attempts+1),
+				mlog.Err(publishErr),
+			)
+			return nil
+		}
+
+		if len(entries) < batchSize {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (d *drainer) publishWithRetry(ctx context.Context, entry *model.ReadCursorOutboxEntry) error {
+	var err error
+	for attempt := range publishAttempts {
+		if attempt > 0 {
+			d.sleep(retryInterval << (attempt - 1))
+		}
+		if err = d.publish(ctx, entry.Payload); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (d *drainer) reportBacklog() {
+	if d.metrics == nil {
+		return
+	}
+
+	stats, err := d.outbox.GetStats()
+	if err != nil {
+		d.logger.Warn("Failed to get read cursor outbox stats", mlog.Err(err))
+		return
+	}
+
+	d.metrics.SetReadCursorOutboxPending(stats.Pending)
+	age := 0.0
+	if stats.OldestCreateAt > 0 {
+		age = d.now().Sub(time.UnixMilli(stats.OldestCreateAt)).Seconds()
+	}
+	d.metrics.SetReadCursorOutboxOldestAge(age)
+}
+
+// backoff returns the delay before the next delivery attempt after the given number of failures.
+func backoff(failures int) time.Duration {
+	delay := minBackoff
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
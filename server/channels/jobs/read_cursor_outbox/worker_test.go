@@ -0,0 +1,109 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package read_cursor_outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
+	emocks "github.com/mattermost/mattermost/server/v8/einterfaces/mocks"
+)
+
+func TestDrain(t *testing.T) {
+	now := time.UnixMilli(1_700_000_000_000)
+	entries := []*model.ReadCursorOutboxEntry{
+		{Id: 1, EventId: "event1", Payload: `{"event_id":"event1"}`, CreateAt: now.Add(-time.Minute).UnixMilli()},
+		{Id: 2, EventId: "event2", Payload: `{"event_id":"event2"}`, CreateAt: now.UnixMilli(), Attempts: 2},
+		{Id: 3, EventId: "event3", Payload: `{"event_id":"event3"}`, CreateAt: now.UnixMilli()},
+	}
+
+	setup := func(t *testing.T, publish func(ctx context.Context, payload string) error) (*drainer, *mocks.ReadCursorOutboxStore, *emocks.MetricsInterface) {
+		outbox := &mocks.ReadCursorOutboxStore{}
+		metrics := &emocks.MetricsInterface{}
+		return &drainer{
+			outbox:  outbox,
+			metrics: metrics,
+			publish: publish,
+			logger:  mlog.CreateConsoleTestLogger(t),
+			now:     func() time.Time { return now },
+			sleep:   func(time.Duration) {},
+		}, outbox, metrics
+	}
+
+	t.Run("publishes due entries in order and removes them", func(t *testing.T) {
+		var payloads []string
+		d, outbox, metrics := setup(t, func(_ context.Context, payload string) error {
+			payloads = append(payloads, payload)
+			return nil
+		})
+
+		outbox.On("GetDue", now.UnixMilli(), batchSize).Return(entries, nil).Once()
+		outbox.On("Delete", []int64{1, 2, 3}).Return(nil).Once()
+		outbox.On("GetStats").Return(&model.ReadCursorOutboxStats{}, nil)
+		metrics.On("IncrementReadCursorOutboxPublished", 3).Once()
+		metrics.On("SetReadCursorOutboxPending", int64(0)).Once()
+		metrics.On("SetReadCursorOutboxOldestAge", 0.0).Once()
+
+		require.NoError(t, d.drain(context.Background()))
+		assert.Equal(t, []string{entries[0].Payload, entries[1].Payload, entries[2].Payload}, payloads)
+		outbox.AssertExpectations(t)
+		metrics.AssertExpectations(t)
+	})
+
+	t.Run("retries and then backs off the failing entry", func(t *testing.T) {
+		calls := map[string]int{}
+		d, outbox, metrics := setup(t, func(_ context.Context, payload string) error {
+			calls[payload]++
+			if payload == entries[1].Payload {
+				return errors.New("connection refused")
+			}
+			return nil
+		})
+
+		outbox.On("GetDue", now.UnixMilli(), batchSize).Return(entries, nil).Once()
+		outbox.On("Delete", []int64{1}).Return(nil).Once()
+		// Third failure in total for this entry
+		outbox.On("MarkFailed", int64(2), now.Add(4*minBackoff).UnixMilli(), "connection refused").Return(nil).Once()
+		outbox.On("GetStats").Return(&model.ReadCursorOutboxStats{Pending: 2, OldestCreateAt: now.Add(-time.Minute).UnixMilli()}, nil)
+		metrics.On("IncrementReadCursorOutboxPublished", 1).Once()
+		metrics.On("IncrementReadCursorOutboxPublishFailures", 1).Once()
+		metrics.On("SetReadCursorOutboxPending", int64(2)).Once()
+		metrics.On("SetReadCursorOutboxOldestAge", 60.0).Once()
+
+		require.NoError(t, d.drain(context.Background()))
+		assert.Equal(t, publishAttempts, calls[entries[1].Payload])
+		assert.Zero(t, calls[entries[2].Payload], "entries after a failure should wait for the next run")
+		outbox.AssertExpectations(t)
+		metrics.AssertExpectations(t)
+	})
+
+	t.Run("does not publish when the store fails", func(t *testing.T) {
+		d, outbox, metrics := setup(t, func(_ context.Context, _ string) error {
+			t.Fatal("unexpected publish")
+			return nil
+		})
+
+		outbox.On("GetDue", mock.Anything, batchSize).Return(nil, errors.New("db down")).Once()
+		outbox.On("GetStats").Return(nil, errors.New("db down"))
+
+		require.Error(t, d.drain(context.Background()))
+		metrics.AssertExpectations(t)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, minBackoff, backoff(1))
+	assert.Equal(t, 2*minBackoff, backoff(2))
+	assert.Equal(t, 4*minBackoff, backoff(3))
+	assert.Equal(t, maxBackoff, backoff(100))
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package read_receipts_reminder
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+)
+
+// Cooldown is the time during which the non readers of a post are reminded at most once.
+const Cooldown = time.Hour
+
+type AppIface interface {
+	SendPostReadReminders(rctx request.CTX, postId, remindedById string) (int, *model.AppError)
+}
+
+// MakeWorker returns a worker sending the reminders requested for a post to its non readers. The
+// jobs are only created once the cooldown has passed, but two of them can still be created at the
+// same time, so a job is skipped when an earlier one for the same post already ran.
+func MakeWorker(jobServer *jobs.JobServer, app AppIface) *jobs.SimpleWorker {
+	const workerName = "ReadReceiptsReminder"
+
+	isEnabled := func(cfg *model.Config) bool {
+		return true
+	}
+	execute := func(logger mlog.LoggerIFace, job *model.Job) error {
+		defer jobServer.HandleJobPanic(logger, job)
+
+		postId := job.Data["post_id"]
+		remindedById := job.Data["reminded_by"]
+		if postId == "" || remindedById == "" {
+			return errors.New("missing post_id or reminded_by")
+		}
+
+		rctx := request.EmptyContext(logger)
+		duplicate, err := isDuplicate(rctx, jobServer, job)
+		if err != nil {
+			return err
+		}
+		if duplicate {
+			logger.Info("Skipping a read receipts reminder already sent", mlog.String("post_id", postId))
+			job.Data["reminded"] = "0"
+			return nil
+		}
+
+		reminded, appErr := app.SendPostReadReminders(rctx, postId, remindedById)
+		job.Data["reminded"] = strconv.Itoa(reminded)
+		if appErr != nil {
+			return appErr
+		}
+		return nil
+	}
+	return jobs.NewSimpleWorker(workerName, jobServer, execute, isEnabled)
+}
+
+// isDuplicate reports whether another reminder for the same post, created before the job and
+// within the cooldown, is running or succeeded
+func isDuplicate(rctx request.CTX, jobServer *jobs.JobServer, job *model.Job) (bool, error) {
+	others, err := jobServer.Store.Job().GetByTypeAndData(rctx, model.JobTypeReadReceiptsReminder, map[string]string{
+		"post_id": job.Data["post_id"],
+	}, true, model.JobStatusInProgress, model.JobStatusSuccess)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get read receipts reminder jobs")
+	}
+
+	for _, other := range others {
+		if other.Id == job.Id || other.CreateAt < job.CreateAt-Cooldown.Milliseconds() {
+			continue
+		}
+		if other.CreateAt < job.CreateAt || (other.CreateAt == job.CreateAt && other.Id < job.Id) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
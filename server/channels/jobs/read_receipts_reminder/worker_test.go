@@ -0,0 +1,55 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package read_receipts_reminder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/jobs"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest"
+)
+
+func TestIsDuplicate(t *testing.T) {
+	postId := model.NewId()
+	job := &model.Job{Id: model.NewId(), Type: model.JobTypeReadReceiptsReminder, CreateAt: model.GetMillis(), Data: model.StringMap{"post_id": postId}}
+	rctx := request.EmptyContext(mlog.CreateConsoleTestLogger(t))
+
+	check := func(t *testing.T, others ...*model.Job) bool {
+		t.Helper()
+
+		mockStore := &storetest.Store{}
+		t.Cleanup(func() {
+			mockStore.AssertExpectations(t)
+		})
+		mockStore.JobStore.On("GetByTypeAndData", mock.Anything, model.JobTypeReadReceiptsReminder, map[string]string{"post_id": postId}, true, model.JobStatusInProgress, model.JobStatusSuccess).
+			Return(append([]*model.Job{job}, others...), nil)
+
+		duplicate, err := isDuplicate(rctx, &jobs.JobServer{Store: mockStore}, job)
+		require.NoError(t, err)
+		return duplicate
+	}
+
+	t.Run("only job", func(t *testing.T) {
+		assert.False(t, check(t))
+	})
+
+	t.Run("earlier job within the cooldown", func(t *testing.T) {
+		assert.True(t, check(t, &model.Job{Id: model.NewId(), CreateAt: job.CreateAt - 1000}))
+	})
+
+	t.Run("earlier job before the cooldown", func(t *testing.T) {
+		assert.False(t, check(t, &model.Job{Id: model.NewId(), CreateAt: job.CreateAt - Cooldown.Milliseconds() - 1}))
+	})
+
+	t.Run("later job", func(t *testing.T) {
+		assert.False(t, check(t, &model.Job{Id: model.NewId(), CreateAt: job.CreateAt + 1}))
+	})
+}
@@ -245,6 +245,8 @@ func generateLayer(name, templateFile string) ([]byte, error) {
 					paramsWithType = append(paramsWithType, fmt.Sprintf("%s store.%s", param.Name, param.Type))
 				case "*UserGetByIdsOpts", "*SidebarCategorySearchOpts":
 					paramsWithType = append(paramsWithType, fmt.Sprintf("%s *store.%s", param.Name, strings.TrimPrefix(param.Type, "*")))
+				case "[]ReadCursorSeqQuery":
+					paramsWithType = append(paramsWithType, fmt.Sprintf("%s []store.%s", param.Name, strings.TrimPrefix(param.Type, "[]")))
 				default:
 					paramsWithType = append(paramsWithType, fmt.Sprintf("%s %s", param.Name, param.Type))
 				}
@@ -259,6 +261,8 @@ func generateLayer(name, templateFile string) ([]byte, error) {
 					paramsWithType = append(paramsWithType, fmt.Sprintf("%s store.%s", param.Name, param.Type))
 				case "*UserGetByIdsOpts", "*SidebarCategorySearchOpts":
 					paramsWithType = append(paramsWithType, fmt.Sprintf("%s *store.%s", param.Name, strings.TrimPrefix(param.Type, "*")))
+				case "[]ReadCursorSeqQuery":
+					paramsWithType = append(paramsWithType, fmt.Sprintf("%s []store.%s", param.Name, strings.TrimPrefix(param.Type, "[]")))
 				default:
 					paramsWithType = append(paramsWithType, fmt.Sprintf("%s %s", param.Name, param.Type))
 				}
@@ -35,12 +35,14 @@ type RetryLayer struct {
 	ContentFlaggingStore            store.ContentFlaggingStore
 	DesktopTokensStore              store.DesktopTokensStore
 	DraftStore                      store.DraftStore
+	EmailBatchStore                 store.EmailBatchStore
 	EmojiStore                      store.EmojiStore
 	FileInfoStore                   store.FileInfoStore
 	GroupStore                      store.GroupStore
 	JobStore                        store.JobStore
 	LicenseStore                    store.LicenseStore
 	LinkMetadataStore               store.LinkMetadataStore
+	MfaFactorStore                  store.MfaFactorStore
 	NotifyAdminStore                store.NotifyAdminStore
 	OAuthStore                      store.OAuthStore
 	OutgoingOAuthConnectionStore    store.OutgoingOAuthConnectionStore
@@ -49,12 +51,14 @@ type RetryLayer struct {
 	PostAcknowledgementStore        store.PostAcknowledgementStore
 	PostPersistentNotificationStore store.PostPersistentNotificationStore
 	PostPriorityStore               store.PostPriorityStore
+	PostTranslationStore            store.PostTranslationStore
 	PreferenceStore                 store.PreferenceStore
 	ProductNoticesStore             store.ProductNoticesStore
 	PropertyFieldStore              store.PropertyFieldStore
 	PropertyGroupStore              store.PropertyGroupStore
 	PropertyValueStore              store.PropertyValueStore
 	ReactionStore                   store.ReactionStore
+	ReadCursorOutboxStore           store.ReadCursorOutboxStore
 	RemoteClusterStore              store.RemoteClusterStore
 	RetentionPolicyStore            store.RetentionPolicyStore
 	RoleStore                       store.RoleStore
@@ -135,6 +139,10 @@ func (s *RetryLayer) Draft() store.DraftStore {
 	return s.DraftStore
 }
 
+func (s *RetryLayer) EmailBatch() store.EmailBatchStore {
+	return s.EmailBatchStore
+}
+
 func (s *RetryLayer) Emoji() store.EmojiStore {
 	return s.EmojiStore
 }
@@ -159,6 +167,10 @@ func (s *RetryLayer) LinkMetadata() store.LinkMetadataStore {
 	return s.LinkMetadataStore
 }
 
+func (s *RetryLayer) MfaFactor() store.MfaFactorStore {
+	return s.MfaFactorStore
+}
+
 func (s *RetryLayer) NotifyAdmin() store.NotifyAdminStore {
 	return s.NotifyAdminStore
 }
@@ -191,6 +203,10 @@ func (s *RetryLayer) PostPriority() store.PostPriorityStore {
 	return s.PostPriorityStore
 }
 
+func (s *RetryLayer) PostTranslation() store.PostTranslationStore {
+	return s.PostTranslationStore
+}
+
 func (s *RetryLayer) Preference() store.PreferenceStore {
 	return s.PreferenceStore
 }
@@ -215,6 +231,10 @@ func (s *RetryLayer) Reaction() store.ReactionStore {
 	return s.ReactionStore
 }
 
+func (s *RetryLayer) ReadCursorOutbox() store.ReadCursorOutboxStore {
+	return s.ReadCursorOutboxStore
+}
+
 func (s *RetryLayer) RemoteCluster() store.RemoteClusterStore {
 	return s.RemoteClusterStore
 }
@@ -362,6 +382,11 @@ type RetryLayerDraftStore struct {
 	Root *RetryLayer
 }
 
+type RetryLayerEmailBatchStore struct {
+	store.EmailBatchStore
+	Root *RetryLayer
+}
+
 type RetryLayerEmojiStore struct {
 	store.EmojiStore
 	Root *RetryLayer
@@ -392,6 +417,11 @@ type RetryLayerLinkMetadataStore struct {
 	Root *RetryLayer
 }
 
+type RetryLayerMfaFactorStore struct {
+	store.MfaFactorStore
+	Root *RetryLayer
+}
+
 type RetryLayerNotifyAdminStore struct {
 	store.NotifyAdminStore
 	Root *RetryLayer
@@ -432,6 +462,11 @@ type RetryLayerPostPriorityStore struct {
 	Root *RetryLayer
 }
 
+type RetryLayerPostTranslationStore struct {
+	store.PostTranslationStore
+	Root *RetryLayer
+}
+
 type RetryLayerPreferenceStore struct {
 	store.PreferenceStore
 	Root *RetryLayer
@@ -462,6 +497,11 @@ type RetryLayerReactionStore struct {
 	Root *RetryLayer
 }
 
+type RetryLayerReadCursorOutboxStore struct {
+	store.ReadCursorOutboxStore
+	Root *RetryLayer
+}
+
 type RetryLayerRemoteClusterStore struct {
 	store.RemoteClusterStore
 	Root *RetryLayer
@@ -3668,6 +3708,69 @@ func (s *RetryLayerChannelMemberHistoryStore) PermanentDeleteBatchForRetentionPo
 
 }
 
+func (s *RetryLayerChannelReadCursorStore) CountForSeq(channelId string, rootId string, seq int64, excludeUserId string) (int64, error) {
+
+	tries := 0
+	for {
+		result, err := s.ChannelReadCursorStore.CountForSeq(channelId, rootId, seq, excludeUserId)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerChannelReadCursorStore) CountForSeqs(channelId string, queries []store.ReadCursorSeqQuery) ([]int64, error) {
+
+	tries := 0
+	for {
+		result, err := s.ChannelReadCursorStore.CountForSeqs(channelId, queries)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerChannelReadCursorStore) CountNonReaders(channelId string, rootId string, seq int64, excludeUserId string, sharesByDefault bool) (int64, error) {
+
+	tries := 0
+	for {
+		result, err := s.ChannelReadCursorStore.CountNonReaders(channelId, rootId, seq, excludeUserId, sharesByDefault)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
 func (s *RetryLayerChannelReadCursorStore) Delete(channelId string, userId string) error {
 
 	tries := 0
@@ -3752,11 +3855,11 @@ func (s *RetryLayerChannelReadCursorStore) Get(channelId string, userId string)
 
 }
 
-func (s *RetryLayerChannelReadCursorStore) GetForChannel(channelId string) ([]*model.ChannelReadCursor, error) {
+func (s *RetryLayerChannelReadCursorStore) GetDailyReadSummary(channelId string, since int64) ([]*model.ReadReceiptsDailySummary, error) {
 
 	tries := 0
 	for {
-		result, err := s.ChannelReadCursorStore.GetForChannel(channelId)
+		result, err := s.ChannelReadCursorStore.GetDailyReadSummary(channelId, since)
 		if err == nil {
 			return result, nil
 		}
@@ -3773,11 +3876,11 @@ func (s *RetryLayerChannelReadCursorStore) GetForChannel(channelId string) ([]*m
 
 }
 
-func (s *RetryLayerChannelReadCursorStore) GetForUser(userId string) ([]*model.ChannelReadCursor, error) {
+func (s *RetryLayerChannelReadCursorStore) GetForChannel(channelId string) ([]*model.ChannelReadCursor, error) {
 
 	tries := 0
 	for {
-		result, err := s.ChannelReadCursorStore.GetForUser(userId)
+		result, err := s.ChannelReadCursorStore.GetForChannel(channelId)
 		if err == nil {
 			return result, nil
 		}
@@ -3794,53 +3897,53 @@ func (s *RetryLayerChannelReadCursorStore) GetForUser(userId string) ([]*model.C
 
 }
 
-func (s *RetryLayerChannelReadCursorStore) Upsert(cursor *model.ChannelReadCursor) error {
+func (s *RetryLayerChannelReadCursorStore) GetForChannelUsers(channelId string, rootId string, userIds []string) ([]*model.ChannelReadCursor, error) {
 
 	tries := 0
 	for {
-		err := s.ChannelReadCursorStore.Upsert(cursor)
+		result, err := s.ChannelReadCursorStore.GetForChannelUsers(channelId, rootId, userIds)
 		if err == nil {
-			return nil
+			return result, nil
 		}
 		if !isRepeatableError(err) {
-			return err
+			return result, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return err
+			return result, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerClusterDiscoveryStore) Cleanup() error {
+func (s *RetryLayerChannelReadCursorStore) GetForSeq(channelId string, rootId string, seq int64, excludeUserId string, limit int) ([]*model.ChannelReadCursor, error) {
 
 	tries := 0
 	for {
-		err := s.ClusterDiscoveryStore.Cleanup()
+		result, err := s.ChannelReadCursorStore.GetForSeq(channelId, rootId, seq, excludeUserId, limit)
 		if err == nil {
-			return nil
+			return result, nil
 		}
 		if !isRepeatableError(err) {
-			return err
+			return result, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return err
+			return result, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerClusterDiscoveryStore) Delete(discovery *model.ClusterDiscovery) (bool, error) {
+func (s *RetryLayerChannelReadCursorStore) GetForUser(userId string) ([]*model.ChannelReadCursor, error) {
 
 	tries := 0
 	for {
-		result, err := s.ClusterDiscoveryStore.Delete(discovery)
+		result, err := s.ChannelReadCursorStore.GetForUser(userId)
 		if err == nil {
 			return result, nil
 		}
@@ -3857,11 +3960,11 @@ func (s *RetryLayerClusterDiscoveryStore) Delete(discovery *model.ClusterDiscove
 
 }
 
-func (s *RetryLayerClusterDiscoveryStore) Exists(discovery *model.ClusterDiscovery) (bool, error) {
+func (s *RetryLayerChannelReadCursorStore) GetNonReaders(channelId string, rootId string, seq int64, excludeUserId string, sharesByDefault bool, offset int, limit int) ([]string, error) {
 
 	tries := 0
 	for {
-		result, err := s.ClusterDiscoveryStore.Exists(discovery)
+		result, err := s.ChannelReadCursorStore.GetNonReaders(channelId, rootId, seq, excludeUserId, sharesByDefault, offset, limit)
 		if err == nil {
 			return result, nil
 		}
@@ -3878,11 +3981,11 @@ func (s *RetryLayerClusterDiscoveryStore) Exists(discovery *model.ClusterDiscove
 
 }
 
-func (s *RetryLayerClusterDiscoveryStore) GetAll(discoveryType string, clusterName string) ([]*model.ClusterDiscovery, error) {
+func (s *RetryLayerChannelReadCursorStore) GetNonSharingMemberIds(channelId string, sharesByDefault bool) ([]string, error) {
 
 	tries := 0
 	for {
-		result, err := s.ClusterDiscoveryStore.GetAll(discoveryType, clusterName)
+		result, err := s.ChannelReadCursorStore.GetNonSharingMemberIds(channelId, sharesByDefault)
 		if err == nil {
 			return result, nil
 		}
@@ -3899,95 +4002,95 @@ func (s *RetryLayerClusterDiscoveryStore) GetAll(discoveryType string, clusterNa
 
 }
 
-func (s *RetryLayerClusterDiscoveryStore) Save(discovery *model.ClusterDiscovery) error {
+func (s *RetryLayerChannelReadCursorStore) GetNonSharingReaderIds(channelId string, sharesByDefault bool) ([]string, error) {
 
 	tries := 0
 	for {
-		err := s.ClusterDiscoveryStore.Save(discovery)
+		result, err := s.ChannelReadCursorStore.GetNonSharingReaderIds(channelId, sharesByDefault)
 		if err == nil {
-			return nil
+			return result, nil
 		}
 		if !isRepeatableError(err) {
-			return err
+			return result, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return err
+			return result, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerClusterDiscoveryStore) SetLastPingAt(discovery *model.ClusterDiscovery) error {
+func (s *RetryLayerChannelReadCursorStore) GetThread(rootId string, userId string) (*model.ThreadReadCursor, error) {
 
 	tries := 0
 	for {
-		err := s.ClusterDiscoveryStore.SetLastPingAt(discovery)
+		result, err := s.ChannelReadCursorStore.GetThread(rootId, userId)
 		if err == nil {
-			return nil
+			return result, nil
 		}
 		if !isRepeatableError(err) {
-			return err
+			return result, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return err
+			return result, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerCommandStore) AnalyticsCommandCount(teamID string) (int64, error) {
+func (s *RetryLayerChannelReadCursorStore) PermanentDeleteBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error) {
 
 	tries := 0
 	for {
-		result, err := s.CommandStore.AnalyticsCommandCount(teamID)
+		result, resultVar1, err := s.ChannelReadCursorStore.PermanentDeleteBatchForRetentionPolicies(retentionPolicyBatchConfigs, cursor)
 		if err == nil {
-			return result, nil
+			return result, resultVar1, nil
 		}
 		if !isRepeatableError(err) {
-			return result, err
+			return result, resultVar1, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, err
+			return result, resultVar1, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerCommandStore) Delete(commandID string, timestamp int64) error {
+func (s *RetryLayerChannelReadCursorStore) PermanentDeleteBatchThreadCursorsForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error) {
 
 	tries := 0
 	for {
-		err := s.CommandStore.Delete(commandID, timestamp)
+		result, resultVar1, err := s.ChannelReadCursorStore.PermanentDeleteBatchThreadCursorsForRetentionPolicies(retentionPolicyBatchConfigs, cursor)
 		if err == nil {
-			return nil
+			return result, resultVar1, nil
 		}
 		if !isRepeatableError(err) {
-			return err
+			return result, resultVar1, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return err
+			return result, resultVar1, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerCommandStore) Get(id string) (*model.Command, error) {
+func (s *RetryLayerChannelReadCursorStore) RemoveForChannel(channelId string) (*model.ReadCursorEvent, error) {
 
 	tries := 0
 	for {
-		result, err := s.CommandStore.Get(id)
+		result, err := s.ChannelReadCursorStore.RemoveForChannel(channelId)
 		if err == nil {
 			return result, nil
 		}
@@ -4004,11 +4107,11 @@ func (s *RetryLayerCommandStore) Get(id string) (*model.Command, error) {
 
 }
 
-func (s *RetryLayerCommandStore) GetByTeam(teamID string) ([]*model.Command, error) {
+func (s *RetryLayerChannelReadCursorStore) RemoveForUser(userId string) ([]*model.ReadCursorEvent, error) {
 
 	tries := 0
 	for {
-		result, err := s.CommandStore.GetByTeam(teamID)
+		result, err := s.ChannelReadCursorStore.RemoveForUser(userId)
 		if err == nil {
 			return result, nil
 		}
@@ -4025,11 +4128,11 @@ func (s *RetryLayerCommandStore) GetByTeam(teamID string) ([]*model.Command, err
 
 }
 
-func (s *RetryLayerCommandStore) GetByTrigger(teamID string, trigger string) (*model.Command, error) {
+func (s *RetryLayerChannelReadCursorStore) RemoveForUserInTeams(userId string, teamIds []string, exceptTeams bool) ([]*model.ReadCursorEvent, error) {
 
 	tries := 0
 	for {
-		result, err := s.CommandStore.GetByTrigger(teamID, trigger)
+		result, err := s.ChannelReadCursorStore.RemoveForUserInTeams(userId, teamIds, exceptTeams)
 		if err == nil {
 			return result, nil
 		}
@@ -4046,74 +4149,74 @@ func (s *RetryLayerCommandStore) GetByTrigger(teamID string, trigger string) (*m
 
 }
 
-func (s *RetryLayerCommandStore) PermanentDeleteByTeam(teamID string) error {
+func (s *RetryLayerChannelReadCursorStore) Upsert(cursor *model.ChannelReadCursor) (*model.ReadCursorEvent, error) {
 
 	tries := 0
 	for {
-		err := s.CommandStore.PermanentDeleteByTeam(teamID)
+		result, err := s.ChannelReadCursorStore.Upsert(cursor)
 		if err == nil {
-			return nil
+			return result, nil
 		}
 		if !isRepeatableError(err) {
-			return err
+			return result, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return err
+			return result, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerCommandStore) PermanentDeleteByUser(userID string) error {
+func (s *RetryLayerChannelReadCursorStore) UpsertThread(cursor *model.ThreadReadCursor) (*model.ReadCursorEvent, error) {
 
 	tries := 0
 	for {
-		err := s.CommandStore.PermanentDeleteByUser(userID)
+		result, err := s.ChannelReadCursorStore.UpsertThread(cursor)
 		if err == nil {
-			return nil
+			return result, nil
 		}
 		if !isRepeatableError(err) {
-			return err
+			return result, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return err
+			return result, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerCommandStore) Save(webhook *model.Command) (*model.Command, error) {
+func (s *RetryLayerClusterDiscoveryStore) Cleanup() error {
 
 	tries := 0
 	for {
-		result, err := s.CommandStore.Save(webhook)
+		err := s.ClusterDiscoveryStore.Cleanup()
 		if err == nil {
-			return result, nil
+			return nil
 		}
 		if !isRepeatableError(err) {
-			return result, err
+			return err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, err
+			return err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerCommandStore) Update(hook *model.Command) (*model.Command, error) {
+func (s *RetryLayerClusterDiscoveryStore) Delete(discovery *model.ClusterDiscovery) (bool, error) {
 
 	tries := 0
 	for {
-		result, err := s.CommandStore.Update(hook)
+		result, err := s.ClusterDiscoveryStore.Delete(discovery)
 		if err == nil {
 			return result, nil
 		}
@@ -4130,17 +4233,11 @@ func (s *RetryLayerCommandStore) Update(hook *model.Command) (*model.Command, er
 
 }
 
-func (s *RetryLayerCommandWebhookStore) Cleanup() {
-
-	s.CommandWebhookStore.Cleanup()
-
-}
-
-func (s *RetryLayerCommandWebhookStore) Get(id string) (*model.CommandWebhook, error) {
+func (s *RetryLayerClusterDiscoveryStore) Exists(discovery *model.ClusterDiscovery) (bool, error) {
 
 	tries := 0
 	for {
-		result, err := s.CommandWebhookStore.Get(id)
+		result, err := s.ClusterDiscoveryStore.Exists(discovery)
 		if err == nil {
 			return result, nil
 		}
@@ -4157,11 +4254,11 @@ func (s *RetryLayerCommandWebhookStore) Get(id string) (*model.CommandWebhook, e
 
 }
 
-func (s *RetryLayerCommandWebhookStore) Save(webhook *model.CommandWebhook) (*model.CommandWebhook, error) {
+func (s *RetryLayerClusterDiscoveryStore) GetAll(discoveryType string, clusterName string) ([]*model.ClusterDiscovery, error) {
 
 	tries := 0
 	for {
-		result, err := s.CommandWebhookStore.Save(webhook)
+		result, err := s.ClusterDiscoveryStore.GetAll(discoveryType, clusterName)
 		if err == nil {
 			return result, nil
 		}
@@ -4178,11 +4275,11 @@ func (s *RetryLayerCommandWebhookStore) Save(webhook *model.CommandWebhook) (*mo
 
 }
 
-func (s *RetryLayerCommandWebhookStore) TryUse(id string, limit int) error {
+func (s *RetryLayerClusterDiscoveryStore) Save(discovery *model.ClusterDiscovery) error {
 
 	tries := 0
 	for {
-		err := s.CommandWebhookStore.TryUse(id, limit)
+		err := s.ClusterDiscoveryStore.Save(discovery)
 		if err == nil {
 			return nil
 		}
@@ -4199,32 +4296,32 @@ func (s *RetryLayerCommandWebhookStore) TryUse(id string, limit int) error {
 
 }
 
-func (s *RetryLayerComplianceStore) ComplianceExport(compliance *model.Compliance, cursor model.ComplianceExportCursor, limit int) ([]*model.CompliancePost, model.ComplianceExportCursor, error) {
+func (s *RetryLayerClusterDiscoveryStore) SetLastPingAt(discovery *model.ClusterDiscovery) error {
 
 	tries := 0
 	for {
-		result, resultVar1, err := s.ComplianceStore.ComplianceExport(compliance, cursor, limit)
+		err := s.ClusterDiscoveryStore.SetLastPingAt(discovery)
 		if err == nil {
-			return result, resultVar1, nil
+			return nil
 		}
 		if !isRepeatableError(err) {
-			return result, resultVar1, err
+			return err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, resultVar1, err
+			return err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerComplianceStore) Get(id string) (*model.Compliance, error) {
+func (s *RetryLayerCommandStore) AnalyticsCommandCount(teamID string) (int64, error) {
 
 	tries := 0
 	for {
-		result, err := s.ComplianceStore.Get(id)
+		result, err := s.CommandStore.AnalyticsCommandCount(teamID)
 		if err == nil {
 			return result, nil
 		}
@@ -4241,53 +4338,53 @@ func (s *RetryLayerComplianceStore) Get(id string) (*model.Compliance, error) {
 
 }
 
-func (s *RetryLayerComplianceStore) GetAll(offset int, limit int) (model.Compliances, error) {
+func (s *RetryLayerCommandStore) Delete(commandID string, timestamp int64) error {
 
 	tries := 0
 	for {
-		result, err := s.ComplianceStore.GetAll(offset, limit)
+		err := s.CommandStore.Delete(commandID, timestamp)
 		if err == nil {
-			return result, nil
+			return nil
 		}
 		if !isRepeatableError(err) {
-			return result, err
+			return err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, err
+			return err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerComplianceStore) MessageExport(rctx request.CTX, cursor model.MessageExportCursor, limit int) ([]*model.MessageExport, model.MessageExportCursor, error) {
+func (s *RetryLayerCommandStore) Get(id string) (*model.Command, error) {
 
 	tries := 0
 	for {
-		result, resultVar1, err := s.ComplianceStore.MessageExport(rctx, cursor, limit)
+		result, err := s.CommandStore.Get(id)
 		if err == nil {
-			return result, resultVar1, nil
+			return result, nil
 		}
 		if !isRepeatableError(err) {
-			return result, resultVar1, err
+			return result, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, resultVar1, err
+			return result, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerComplianceStore) Save(compliance *model.Compliance) (*model.Compliance, error) {
+func (s *RetryLayerCommandStore) GetByTeam(teamID string) ([]*model.Command, error) {
 
 	tries := 0
 	for {
-		result, err := s.ComplianceStore.Save(compliance)
+		result, err := s.CommandStore.GetByTeam(teamID)
 		if err == nil {
 			return result, nil
 		}
@@ -4304,11 +4401,11 @@ func (s *RetryLayerComplianceStore) Save(compliance *model.Compliance) (*model.C
 
 }
 
-func (s *RetryLayerComplianceStore) Update(compliance *model.Compliance) (*model.Compliance, error) {
+func (s *RetryLayerCommandStore) GetByTrigger(teamID string, trigger string) (*model.Command, error) {
 
 	tries := 0
 	for {
-		result, err := s.ComplianceStore.Update(compliance)
+		result, err := s.CommandStore.GetByTrigger(teamID, trigger)
 		if err == nil {
 			return result, nil
 		}
@@ -4325,38 +4422,32 @@ func (s *RetryLayerComplianceStore) Update(compliance *model.Compliance) (*model
 
 }
 
-func (s *RetryLayerContentFlaggingStore) ClearCaches() {
-
-	s.ContentFlaggingStore.ClearCaches()
-
-}
-
-func (s *RetryLayerContentFlaggingStore) GetReviewerSettings() (*model.ReviewerIDsSettings, error) {
+func (s *RetryLayerCommandStore) PermanentDeleteByTeam(teamID string) error {
 
 	tries := 0
 	for {
-		result, err := s.ContentFlaggingStore.GetReviewerSettings()
+		err := s.CommandStore.PermanentDeleteByTeam(teamID)
 		if err == nil {
-			return result, nil
+			return nil
 		}
 		if !isRepeatableError(err) {
-			return result, err
+			return err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, err
+			return err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerContentFlaggingStore) SaveReviewerSettings(reviewerSettings model.ReviewerIDsSettings) error {
+func (s *RetryLayerCommandStore) PermanentDeleteByUser(userID string) error {
 
 	tries := 0
 	for {
-		err := s.ContentFlaggingStore.SaveReviewerSettings(reviewerSettings)
+		err := s.CommandStore.PermanentDeleteByUser(userID)
 		if err == nil {
 			return nil
 		}
@@ -4373,11 +4464,296 @@ func (s *RetryLayerContentFlaggingStore) SaveReviewerSettings(reviewerSettings m
 
 }
 
-func (s *RetryLayerDesktopTokensStore) Delete(token string) error {
+func (s *RetryLayerCommandStore) Save(webhook *model.Command) (*model.Command, error) {
 
 	tries := 0
 	for {
-		err := s.DesktopTokensStore.Delete(token)
+		result, err := s.CommandStore.Save(webhook)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerCommandStore) Update(hook *model.Command) (*model.Command, error) {
+
+	tries := 0
+	for {
+		result, err := s.CommandStore.Update(hook)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerCommandWebhookStore) Cleanup() {
+
+	s.CommandWebhookStore.Cleanup()
+
+}
+
+func (s *RetryLayerCommandWebhookStore) Get(id string) (*model.CommandWebhook, error) {
+
+	tries := 0
+	for {
+		result, err := s.CommandWebhookStore.Get(id)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerCommandWebhookStore) Save(webhook *model.CommandWebhook) (*model.CommandWebhook, error) {
+
+	tries := 0
+	for {
+		result, err := s.CommandWebhookStore.Save(webhook)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerCommandWebhookStore) TryUse(id string, limit int) error {
+
+	tries := 0
+	for {
+		err := s.CommandWebhookStore.TryUse(id, limit)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerComplianceStore) ComplianceExport(compliance *model.Compliance, cursor model.ComplianceExportCursor, limit int) ([]*model.CompliancePost, model.ComplianceExportCursor, error) {
+
+	tries := 0
+	for {
+		result, resultVar1, err := s.ComplianceStore.ComplianceExport(compliance, cursor, limit)
+		if err == nil {
+			return result, resultVar1, nil
+		}
+		if !isRepeatableError(err) {
+			return result, resultVar1, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, resultVar1, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerComplianceStore) Get(id string) (*model.Compliance, error) {
+
+	tries := 0
+	for {
+		result, err := s.ComplianceStore.Get(id)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerComplianceStore) GetAll(offset int, limit int) (model.Compliances, error) {
+
+	tries := 0
+	for {
+		result, err := s.ComplianceStore.GetAll(offset, limit)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerComplianceStore) MessageExport(rctx request.CTX, cursor model.MessageExportCursor, limit int) ([]*model.MessageExport, model.MessageExportCursor, error) {
+
+	tries := 0
+	for {
+		result, resultVar1, err := s.ComplianceStore.MessageExport(rctx, cursor, limit)
+		if err == nil {
+			return result, resultVar1, nil
+		}
+		if !isRepeatableError(err) {
+			return result, resultVar1, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, resultVar1, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerComplianceStore) Save(compliance *model.Compliance) (*model.Compliance, error) {
+
+	tries := 0
+	for {
+		result, err := s.ComplianceStore.Save(compliance)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerComplianceStore) Update(compliance *model.Compliance) (*model.Compliance, error) {
+
+	tries := 0
+	for {
+		result, err := s.ComplianceStore.Update(compliance)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerContentFlaggingStore) ClearCaches() {
+
+	s.ContentFlaggingStore.ClearCaches()
+
+}
+
+func (s *RetryLayerContentFlaggingStore) GetReviewerSettings() (*model.ReviewerIDsSettings, error) {
+
+	tries := 0
+	for {
+		result, err := s.ContentFlaggingStore.GetReviewerSettings()
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerContentFlaggingStore) SaveReviewerSettings(reviewerSettings model.ReviewerIDsSettings) error {
+
+	tries := 0
+	for {
+		err := s.ContentFlaggingStore.SaveReviewerSettings(reviewerSettings)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerDesktopTokensStore) Delete(token string) error {
+
+	tries := 0
+	for {
+		err := s.DesktopTokensStore.Delete(token)
 		if err == nil {
 			return nil
 		}
@@ -4583,84 +4959,189 @@ func (s *RetryLayerDraftStore) Get(userID string, channelID string, rootID strin
 
 }
 
-func (s *RetryLayerDraftStore) GetDraftsForUser(userID string, teamID string) ([]*model.Draft, error) {
+func (s *RetryLayerDraftStore) GetDraftsForUser(userID string, teamID string) ([]*model.Draft, error) {
+
+	tries := 0
+	for {
+		result, err := s.DraftStore.GetDraftsForUser(userID, teamID)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerDraftStore) GetLastCreateAtAndUserIdValuesForEmptyDraftsMigration(createAt int64, userID string) (int64, string, error) {
+
+	tries := 0
+	for {
+		result, resultVar1, err := s.DraftStore.GetLastCreateAtAndUserIdValuesForEmptyDraftsMigration(createAt, userID)
+		if err == nil {
+			return result, resultVar1, nil
+		}
+		if !isRepeatableError(err) {
+			return result, resultVar1, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, resultVar1, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerDraftStore) PermanentDeleteByUser(userId string) error {
+
+	tries := 0
+	for {
+		err := s.DraftStore.PermanentDeleteByUser(userId)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerDraftStore) Upsert(d *model.Draft) (*model.Draft, error) {
+
+	tries := 0
+	for {
+		result, err := s.DraftStore.Upsert(d)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerEmailBatchStore) Delete(ids []string) error {
+
+	tries := 0
+	for {
+		err := s.EmailBatchStore.Delete(ids)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerEmailBatchStore) DeleteForChannelMember(userId string, channelId string) error {
 
 	tries := 0
 	for {
-		result, err := s.DraftStore.GetDraftsForUser(userID, teamID)
+		err := s.EmailBatchStore.DeleteForChannelMember(userId, channelId)
 		if err == nil {
-			return result, nil
+			return nil
 		}
 		if !isRepeatableError(err) {
-			return result, err
+			return err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, err
+			return err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerDraftStore) GetLastCreateAtAndUserIdValuesForEmptyDraftsMigration(createAt int64, userID string) (int64, string, error) {
+func (s *RetryLayerEmailBatchStore) GetForUser(userId string) ([]*model.EmailBatchEntry, error) {
 
 	tries := 0
 	for {
-		result, resultVar1, err := s.DraftStore.GetLastCreateAtAndUserIdValuesForEmptyDraftsMigration(createAt, userID)
+		result, err := s.EmailBatchStore.GetForUser(userId)
 		if err == nil {
-			return result, resultVar1, nil
+			return result, nil
 		}
 		if !isRepeatableError(err) {
-			return result, resultVar1, err
+			return result, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, resultVar1, err
+			return result, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerDraftStore) PermanentDeleteByUser(userId string) error {
+func (s *RetryLayerEmailBatchStore) GetPendingUsers(afterUserId string, limit int) ([]*model.EmailBatchUser, error) {
 
 	tries := 0
 	for {
-		err := s.DraftStore.PermanentDeleteByUser(userId)
+		result, err := s.EmailBatchStore.GetPendingUsers(afterUserId, limit)
 		if err == nil {
-			return nil
+			return result, nil
 		}
 		if !isRepeatableError(err) {
-			return err
+			return result, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return err
+			return result, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerDraftStore) Upsert(d *model.Draft) (*model.Draft, error) {
+func (s *RetryLayerEmailBatchStore) Save(entry *model.EmailBatchEntry) error {
 
 	tries := 0
 	for {
-		result, err := s.DraftStore.Upsert(d)
+		err := s.EmailBatchStore.Save(entry)
 		if err == nil {
-			return result, nil
+			return nil
 		}
 		if !isRepeatableError(err) {
-			return result, err
+			return err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, err
+			return err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
@@ -4925,6 +5406,27 @@ func (s *RetryLayerFileInfoStore) Get(id string) (*model.FileInfo, error) {
 
 }
 
+func (s *RetryLayerFileInfoStore) GetBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) ([]*model.FileInfo, model.RetentionPolicyCursor, error) {
+
+	tries := 0
+	for {
+		result, resultVar1, err := s.FileInfoStore.GetBatchForRetentionPolicies(retentionPolicyBatchConfigs, cursor)
+		if err == nil {
+			return result, resultVar1, nil
+		}
+		if !isRepeatableError(err) {
+			return result, resultVar1, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, resultVar1, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
 func (s *RetryLayerFileInfoStore) GetByIds(ids []string, includeDeleted bool, allowFromCache bool) ([]*model.FileInfo, error) {
 
 	tries := 0
@@ -5009,6 +5511,27 @@ func (s *RetryLayerFileInfoStore) GetForPost(postID string, readFromMaster bool,
 
 }
 
+func (s *RetryLayerFileInfoStore) GetForPostIds(postIDs []string) ([]*model.FileInfo, error) {
+
+	tries := 0
+	for {
+		result, err := s.FileInfoStore.GetForPostIds(postIDs)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
 func (s *RetryLayerFileInfoStore) GetForUser(userID string) ([]*model.FileInfo, error) {
 
 	tries := 0
@@ -5162,6 +5685,27 @@ func (s *RetryLayerFileInfoStore) PermanentDeleteBatch(rctx request.CTX, endTime
 
 }
 
+func (s *RetryLayerFileInfoStore) PermanentDeleteByIds(rctx request.CTX, fileIDs []string) (int64, error) {
+
+	tries := 0
+	for {
+		result, err := s.FileInfoStore.PermanentDeleteByIds(rctx, fileIDs)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
 func (s *RetryLayerFileInfoStore) PermanentDeleteByUser(rctx request.CTX, userID string) (int64, error) {
 
 	tries := 0
@@ -6800,11 +7344,179 @@ func (s *RetryLayerJobStore) SaveOnce(job *model.Job) (*model.Job, error) {
 
 }
 
-func (s *RetryLayerJobStore) UpdateOptimistically(job *model.Job, currentStatus string) (bool, error) {
+func (s *RetryLayerJobStore) UpdateOptimistically(job *model.Job, currentStatus string) (bool, error) {
+
+	tries := 0
+	for {
+		result, err := s.JobStore.UpdateOptimistically(job, currentStatus)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerJobStore) UpdateStatus(id string, status string) (*model.Job, error) {
+
+	tries := 0
+	for {
+		result, err := s.JobStore.UpdateStatus(id, status)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerJobStore) UpdateStatusOptimistically(id string, currentStatus string, newStatus string) (*model.Job, error) {
+
+	tries := 0
+	for {
+		result, err := s.JobStore.UpdateStatusOptimistically(id, currentStatus, newStatus)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerLicenseStore) Get(rctx request.CTX, id string) (*model.LicenseRecord, error) {
+
+	tries := 0
+	for {
+		result, err := s.LicenseStore.Get(rctx, id)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerLicenseStore) GetAll() ([]*model.LicenseRecord, error) {
+
+	tries := 0
+	for {
+		result, err := s.LicenseStore.GetAll()
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerLicenseStore) Save(license *model.LicenseRecord) error {
+
+	tries := 0
+	for {
+		err := s.LicenseStore.Save(license)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerLinkMetadataStore) Get(url string, timestamp int64) (*model.LinkMetadata, error) {
+
+	tries := 0
+	for {
+		result, err := s.LinkMetadataStore.Get(url, timestamp)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerLinkMetadataStore) Save(linkMetadata *model.LinkMetadata) (*model.LinkMetadata, error) {
+
+	tries := 0
+	for {
+		result, err := s.LinkMetadataStore.Save(linkMetadata)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerMfaFactorStore) CountRecoveryCodes(userId string) (int64, error) {
 
 	tries := 0
 	for {
-		result, err := s.JobStore.UpdateOptimistically(job, currentStatus)
+		result, err := s.MfaFactorStore.CountRecoveryCodes(userId)
 		if err == nil {
 			return result, nil
 		}
@@ -6821,53 +7533,53 @@ func (s *RetryLayerJobStore) UpdateOptimistically(job *model.Job, currentStatus
 
 }
 
-func (s *RetryLayerJobStore) UpdateStatus(id string, status string) (*model.Job, error) {
+func (s *RetryLayerMfaFactorStore) DeleteAllForUser(userId string) error {
 
 	tries := 0
 	for {
-		result, err := s.JobStore.UpdateStatus(id, status)
+		err := s.MfaFactorStore.DeleteAllForUser(userId)
 		if err == nil {
-			return result, nil
+			return nil
 		}
 		if !isRepeatableError(err) {
-			return result, err
+			return err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, err
+			return err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerJobStore) UpdateStatusOptimistically(id string, currentStatus string, newStatus string) (*model.Job, error) {
+func (s *RetryLayerMfaFactorStore) DeleteWebAuthnCredential(userId string, id string) error {
 
 	tries := 0
 	for {
-		result, err := s.JobStore.UpdateStatusOptimistically(id, currentStatus, newStatus)
+		err := s.MfaFactorStore.DeleteWebAuthnCredential(userId, id)
 		if err == nil {
-			return result, nil
+			return nil
 		}
 		if !isRepeatableError(err) {
-			return result, err
+			return err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, err
+			return err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerLicenseStore) Get(rctx request.CTX, id string) (*model.LicenseRecord, error) {
+func (s *RetryLayerMfaFactorStore) GetWebAuthnCredentials(userId string) ([]*model.WebAuthnCredential, error) {
 
 	tries := 0
 	for {
-		result, err := s.LicenseStore.Get(rctx, id)
+		result, err := s.MfaFactorStore.GetWebAuthnCredentials(userId)
 		if err == nil {
 			return result, nil
 		}
@@ -6884,74 +7596,74 @@ func (s *RetryLayerLicenseStore) Get(rctx request.CTX, id string) (*model.Licens
 
 }
 
-func (s *RetryLayerLicenseStore) GetAll() ([]*model.LicenseRecord, error) {
+func (s *RetryLayerMfaFactorStore) SaveRecoveryCodes(userId string, codeHashes []string) error {
 
 	tries := 0
 	for {
-		result, err := s.LicenseStore.GetAll()
+		err := s.MfaFactorStore.SaveRecoveryCodes(userId, codeHashes)
 		if err == nil {
-			return result, nil
+			return nil
 		}
 		if !isRepeatableError(err) {
-			return result, err
+			return err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, err
+			return err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerLicenseStore) Save(license *model.LicenseRecord) error {
+func (s *RetryLayerMfaFactorStore) SaveWebAuthnCredential(credential *model.WebAuthnCredential) (*model.WebAuthnCredential, error) {
 
 	tries := 0
 	for {
-		err := s.LicenseStore.Save(license)
+		result, err := s.MfaFactorStore.SaveWebAuthnCredential(credential)
 		if err == nil {
-			return nil
+			return result, nil
 		}
 		if !isRepeatableError(err) {
-			return err
+			return result, err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return err
+			return result, err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerLinkMetadataStore) Get(url string, timestamp int64) (*model.LinkMetadata, error) {
+func (s *RetryLayerMfaFactorStore) UpdateWebAuthnCredential(credential *model.WebAuthnCredential) error {
 
 	tries := 0
 	for {
-		result, err := s.LinkMetadataStore.Get(url, timestamp)
+		err := s.MfaFactorStore.UpdateWebAuthnCredential(credential)
 		if err == nil {
-			return result, nil
+			return nil
 		}
 		if !isRepeatableError(err) {
-			return result, err
+			return err
 		}
 		tries++
 		if tries >= 3 {
 			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
-			return result, err
+			return err
 		}
 		timepkg.Sleep(100 * timepkg.Millisecond)
 	}
 
 }
 
-func (s *RetryLayerLinkMetadataStore) Save(linkMetadata *model.LinkMetadata) (*model.LinkMetadata, error) {
+func (s *RetryLayerMfaFactorStore) UseRecoveryCode(userId string, codeHash string) (bool, error) {
 
 	tries := 0
 	for {
-		result, err := s.LinkMetadataStore.Save(linkMetadata)
+		result, err := s.MfaFactorStore.UseRecoveryCode(userId, codeHash)
 		if err == nil {
 			return result, nil
 		}
@@ -8414,6 +9126,27 @@ func (s *RetryLayerPostStore) GetPostsCreatedAt(channelID string, timestamp int6
 
 }
 
+func (s *RetryLayerPostStore) GetPostsForReadReceipts(postIds []string) ([]*model.Post, error) {
+
+	tries := 0
+	for {
+		result, err := s.PostStore.GetPostsForReadReceipts(postIds)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
 func (s *RetryLayerPostStore) GetPostsSince(rctx request.CTX, options model.GetPostsSinceOptions, allowFromCache bool, sanitizeOptions map[string]bool) (*model.PostList, error) {
 
 	tries := 0
@@ -9281,6 +10014,90 @@ func (s *RetryLayerPostPriorityStore) Save(priority *model.PostPriority) (*model
 
 }
 
+func (s *RetryLayerPostTranslationStore) DeleteForPost(postID string) error {
+
+	tries := 0
+	for {
+		err := s.PostTranslationStore.DeleteForPost(postID)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerPostTranslationStore) Get(postID string, locale string) (*model.PostTranslation, error) {
+
+	tries := 0
+	for {
+		result, err := s.PostTranslationStore.Get(postID, locale)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerPostTranslationStore) GetForPosts(postIDs []string, locale string) ([]*model.PostTranslation, error) {
+
+	tries := 0
+	for {
+		result, err := s.PostTranslationStore.GetForPosts(postIDs, locale)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerPostTranslationStore) Save(translation *model.PostTranslation) (*model.PostTranslation, error) {
+
+	tries := 0
+	for {
+		result, err := s.PostTranslationStore.Save(translation)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
 func (s *RetryLayerPreferenceStore) CleanupFlagsBatch(limit int64) (int64, error) {
 
 	tries := 0
@@ -9491,6 +10308,27 @@ func (s *RetryLayerPreferenceStore) GetCategoryAndName(category string, name str
 
 }
 
+func (s *RetryLayerPreferenceStore) GetCategoryAndNameForUsers(userIDs []string, category string, name string) (model.Preferences, error) {
+
+	tries := 0
+	for {
+		result, err := s.PreferenceStore.GetCategoryAndNameForUsers(userIDs, category, name)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
 func (s *RetryLayerPreferenceStore) PermanentDeleteByUser(userID string) error {
 
 	tries := 0
@@ -10310,6 +11148,111 @@ func (s *RetryLayerReactionStore) Save(reaction *model.Reaction) (*model.Reactio
 
 }
 
+func (s *RetryLayerReadCursorOutboxStore) Delete(ids []int64) error {
+
+	tries := 0
+	for {
+		err := s.ReadCursorOutboxStore.Delete(ids)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerReadCursorOutboxStore) DeleteByEventId(eventId string) error {
+
+	tries := 0
+	for {
+		err := s.ReadCursorOutboxStore.DeleteByEventId(eventId)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerReadCursorOutboxStore) GetDue(now int64, limit int) ([]*model.ReadCursorOutboxEntry, error) {
+
+	tries := 0
+	for {
+		result, err := s.ReadCursorOutboxStore.GetDue(now, limit)
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerReadCursorOutboxStore) GetStats() (*model.ReadCursorOutboxStats, error) {
+
+	tries := 0
+	for {
+		result, err := s.ReadCursorOutboxStore.GetStats()
+		if err == nil {
+			return result, nil
+		}
+		if !isRepeatableError(err) {
+			return result, err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return result, err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
+func (s *RetryLayerReadCursorOutboxStore) MarkFailed(id int64, nextAttemptAt int64, lastError string) error {
+
+	tries := 0
+	for {
+		err := s.ReadCursorOutboxStore.MarkFailed(id, nextAttemptAt, lastError)
+		if err == nil {
+			return nil
+		}
+		if !isRepeatableError(err) {
+			return err
+		}
+		tries++
+		if tries >= 3 {
+			err = errors.Wrap(err, "giving up after 3 consecutive repeatable transaction failures")
+			return err
+		}
+		timepkg.Sleep(100 * timepkg.Millisecond)
+	}
+
+}
+
 func (s *RetryLayerRemoteClusterStore) Delete(remoteClusterID string) (bool, error) {
 
 	tries := 0
@@ -17244,12 +18187,14 @@ func New(childStore store.Store) *RetryLayer {
 	newStore.ContentFlaggingStore = &RetryLayerContentFlaggingStore{ContentFlaggingStore: childStore.ContentFlagging(), Root: &newStore}
 	newStore.DesktopTokensStore = &RetryLayerDesktopTokensStore{DesktopTokensStore: childStore.DesktopTokens(), Root: &newStore}
 	newStore.DraftStore = &RetryLayerDraftStore{DraftStore: childStore.Draft(), Root: &newStore}
+	newStore.EmailBatchStore = &RetryLayerEmailBatchStore{EmailBatchStore: childStore.EmailBatch(), Root: &newStore}
 	newStore.EmojiStore = &RetryLayerEmojiStore{EmojiStore: childStore.Emoji(), Root: &newStore}
 	newStore.FileInfoStore = &RetryLayerFileInfoStore{FileInfoStore: childStore.FileInfo(), Root: &newStore}
 	newStore.GroupStore = &RetryLayerGroupStore{GroupStore: childStore.Group(), Root: &newStore}
 	newStore.JobStore = &RetryLayerJobStore{JobStore: childStore.Job(), Root: &newStore}
 	newStore.LicenseStore = &RetryLayerLicenseStore{LicenseStore: childStore.License(), Root: &newStore}
 	newStore.LinkMetadataStore = &RetryLayerLinkMetadataStore{LinkMetadataStore: childStore.LinkMetadata(), Root: &newStore}
+	newStore.MfaFactorStore = &RetryLayerMfaFactorStore{MfaFactorStore: childStore.MfaFactor(), Root: &newStore}
 	newStore.NotifyAdminStore = &RetryLayerNotifyAdminStore{NotifyAdminStore: childStore.NotifyAdmin(), Root: &newStore}
 	newStore.OAuthStore = &RetryLayerOAuthStore{OAuthStore: childStore.OAuth(), Root: &newStore}
 	newStore.OutgoingOAuthConnectionStore = &RetryLayerOutgoingOAuthConnectionStore{OutgoingOAuthConnectionStore: childStore.OutgoingOAuthConnection(), Root: &newStore}
@@ -17258,12 +18203,14 @@ func New(childStore store.Store) *RetryLayer {
 	newStore.PostAcknowledgementStore = &RetryLayerPostAcknowledgementStore{PostAcknowledgementStore: childStore.PostAcknowledgement(), Root: &newStore}
 	newStore.PostPersistentNotificationStore = &RetryLayerPostPersistentNotificationStore{PostPersistentNotificationStore: childStore.PostPersistentNotification(), Root: &newStore}
 	newStore.PostPriorityStore = &RetryLayerPostPriorityStore{PostPriorityStore: childStore.PostPriority(), Root: &newStore}
+	newStore.PostTranslationStore = &RetryLayerPostTranslationStore{PostTranslationStore: childStore.PostTranslation(), Root: &newStore}
 	newStore.PreferenceStore = &RetryLayerPreferenceStore{PreferenceStore: childStore.Preference(), Root: &newStore}
 	newStore.ProductNoticesStore = &RetryLayerProductNoticesStore{ProductNoticesStore: childStore.ProductNotices(), Root: &newStore}
 	newStore.PropertyFieldStore = &RetryLayerPropertyFieldStore{PropertyFieldStore: childStore.PropertyField(), Root: &newStore}
 	newStore.PropertyGroupStore = &RetryLayerPropertyGroupStore{PropertyGroupStore: childStore.PropertyGroup(), Root: &newStore}
 	newStore.PropertyValueStore = &RetryLayerPropertyValueStore{PropertyValueStore: childStore.PropertyValue(), Root: &newStore}
 	newStore.ReactionStore = &RetryLayerReactionStore{ReactionStore: childStore.Reaction(), Root: &newStore}
+	newStore.ReadCursorOutboxStore = &RetryLayerReadCursorOutboxStore{ReadCursorOutboxStore: childStore.ReadCursorOutbox(), Root: &newStore}
 	newStore.RemoteClusterStore = &RetryLayerRemoteClusterStore{RemoteClusterStore: childStore.RemoteCluster(), Root: &newStore}
 	newStore.RetentionPolicyStore = &RetryLayerRetentionPolicyStore{RetentionPolicyStore: childStore.RetentionPolicy(), Root: &newStore}
 	newStore.RoleStore = &RetryLayerRoleStore{RoleStore: childStore.Role(), Root: &newStore}
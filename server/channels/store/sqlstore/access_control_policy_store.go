@@ -587,7 +587,7 @@ func (s *SqlAccessControlPolicyStore) SearchPolicies(rctx request.CTX, opts mode
 		limit = MaxPerPage
 	}
 
-	query = query.Limit(limit)
+	query = query.OrderBy("Id ASC").Limit(limit)
 
 	err := s.GetReplica().SelectBuilder(&p, query)
 	if err != nil {
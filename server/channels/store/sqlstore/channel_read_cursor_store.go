@@ -5,7 +5,11 @@ package sqlstore
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
+
+	sq "github.com/mattermost/squirrel"
 
 	"github.com/mattermost/mattermost/server/public/model"
 	"github.com/mattermost/mattermost/server/public/shared/mlog"
@@ -19,10 +23,10 @@ type SqlChannelReadCursorStore struct {
 
 func newSqlChannelReadCursorStore(sqlStore *SqlStore) store.ChannelReadCursorStore {
 	s := &SqlChannelReadCursorStore{SqlStore: sqlStore}
-	
+
 	// Create indexes for better query performance
 	s.createIndexesIfNotExists()
-	
+
 	return s
 }
 
@@ -33,7 +37,7 @@ func (s *SqlChannelReadCursorStore) createIndexesIfNotExists() {
 		CREATE INDEX IF NOT EXISTS idx_channel_read_cursors_channel_seq 
 		ON channel_read_cursors(channel_id, last_post_seq DESC)
 	`)
-	
+
 	// Index on user_id for efficient user-level queries
 	s.GetMaster().Exec(`
 		CREATE INDEX IF NOT EXISTS idx_channel_read_cursors_user 
@@ -41,12 +45,35 @@ func (s *SqlChannelReadCursorStore) createIndexesIfNotExists() {
 	`)
 }
 
-// Upsert inserts or updates a read cursor for a user in a channel
-func (s *SqlChannelReadCursorStore) Upsert(cursor *model.ChannelReadCursor) error {
+// Upsert advances a read cursor and enqueues the matching event in read_cursor_outbox
+// within the same transaction, so the event is published even if Redis is unavailable
+func (s *SqlChannelReadCursorStore) Upsert(cursor *model.ChannelReadCursor) (_ *model.ReadCursorEvent, err error) {
 	cursor.PreSave()
 
-	if err := cursor.IsValid(); err != nil {
-		return err
+	if appErr := cursor.IsValid(); appErr != nil {
+		return nil, appErr
+	}
+
+	tx, err := s.GetMaster().Beginx()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	// Lock the existing row so concurrent advances compute the previous sequence correctly
+	var prevSeq int64
+	err = tx.GetBuilder(&prevSeq, s.getQueryBuilder().
+		Select("last_post_seq").
+		From("channel_read_cursors").
+		Where(sq.Eq{"channel_id": cursor.ChannelId, "user_id": cursor.UserId}).
+		Suffix("FOR UPDATE"))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "failed to get channel read cursor")
+	}
+
+	if cursor.LastPostSeq <= prevSeq {
+		err = tx.Commit()
+		return nil, errors.Wrap(err, "commit_transaction")
 	}
 
 	query := s.getQueryBuilder().
@@ -55,13 +82,331 @@ func (s *SqlChannelReadCursorStore) Upsert(cursor *model.ChannelReadCursor) erro
 		Values(cursor.ChannelId, cursor.UserId, cursor.LastPostSeq, cursor.UpdatedAt).
 		Suffix("ON CONFLICT (channel_id, user_id) DO UPDATE SET last_post_seq = GREATEST(channel_read_cursors.last_post_seq, EXCLUDED.last_post_seq), updated_at = EXCLUDED.updated_at")
 
-	queryString, args, err := query.ToSql()
+	if _, err = tx.ExecBuilder(query); err != nil {
+		return nil, errors.Wrap(err, "failed to upsert channel read cursor")
+	}
+
+	event := &model.ReadCursorEvent{
+		Type:        model.ReadCursorEventTypeAdvanced,
+		EventId:     model.NewId(),
+		ChannelId:   cursor.ChannelId,
+		UserId:      cursor.UserId,
+		PrevLastSeq: prevSeq,
+		NewLastSeq:  cursor.LastPostSeq,
+		Timestamp:   cursor.UpdatedAt,
+	}
+
+	if err = s.enqueueEvents(tx, []*model.ReadCursorEvent{event}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit_transaction")
+	}
+
+	return event, nil
+}
+
+// UpsertThread advances a thread read cursor and enqueues the matching event, carrying the
+// root id, in read_cursor_outbox within the same transaction
+func (s *SqlChannelReadCursorStore) UpsertThread(cursor *model.ThreadReadCursor) (_ *model.ReadCursorEvent, err error) {
+	cursor.PreSave()
+
+	if appErr := cursor.IsValid(); appErr != nil {
+		return nil, appErr
+	}
+
+	tx, err := s.GetMaster().Beginx()
 	if err != nil {
-		return errors.Wrap(err, "failed to build upsert query")
+		return nil, errors.Wrap(err, "failed to start transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	var prevSeq int64
+	err = tx.GetBuilder(&prevSeq, s.getQueryBuilder().
+		Select("last_post_seq").
+		From("thread_read_cursors").
+		Where(sq.Eq{"root_id": cursor.RootId, "user_id": cursor.UserId}).
+		Suffix("FOR UPDATE"))
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.Wrap(err, "failed to get thread read cursor")
 	}
 
-	if _, err := s.GetMaster().Exec(queryString, args...); err != nil {
-		return errors.Wrap(err, "failed to upsert channel read cursor")
+	if cursor.LastPostSeq <= prevSeq {
+		err = tx.Commit()
+		return nil, errors.Wrap(err, "commit_transaction")
+	}
+
+	query := s.getQueryBuilder().
+		Insert("thread_read_cursors").
+		Columns("root_id", "channel_id", "user_id", "last_post_seq", "updated_at").
+		Values(cursor.RootId, cursor.ChannelId, cursor.UserId, cursor.LastPostSeq, cursor.UpdatedAt).
+		Suffix("ON CONFLICT (root_id, user_id) DO UPDATE SET last_post_seq = GREATEST(thread_read_cursors.last_post_seq, EXCLUDED.last_post_seq), updated_at = EXCLUDED.updated_at")
+
+	if _, err = tx.ExecBuilder(query); err != nil {
+		return nil, errors.Wrap(err, "failed to upsert thread read cursor")
+	}
+
+	event := &model.ReadCursorEvent{
+		Type:        model.ReadCursorEventTypeAdvanced,
+		EventId:     model.NewId(),
+		ChannelId:   cursor.ChannelId,
+		RootId:      cursor.RootId,
+		UserId:      cursor.UserId,
+		PrevLastSeq: prevSeq,
+		NewLastSeq:  cursor.LastPostSeq,
+		Timestamp:   cursor.UpdatedAt,
+	}
+
+	if err = s.enqueueEvents(tx, []*model.ReadCursorEvent{event}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit_transaction")
+	}
+
+	return event, nil
+}
+
+// RemoveForUser deletes all read cursors of a user and enqueues a removal event for each
+// affected channel in read_cursor_outbox within the same transaction
+func (s *SqlChannelReadCursorStore) RemoveForUser(userId string) ([]*model.ReadCursorEvent, error) {
+	return s.removeForUser(userId, nil)
+}
+
+// RemoveForUserInTeams deletes the read cursors of a user in the channels of the given teams, or
+// in all the other channels with exceptTeams, like RemoveForUser
+func (s *SqlChannelReadCursorStore) RemoveForUserInTeams(userId string, teamIds []string, exceptTeams bool) ([]*model.ReadCursorEvent, error) {
+	if len(teamIds) == 0 {
+		if exceptTeams {
+			return s.removeForUser(userId, nil)
+		}
+		return []*model.ReadCursorEvent{}, nil
+	}
+
+	var teamFilter sq.Sqlizer = sq.Eq{"TeamId": teamIds}
+	if exceptTeams {
+		teamFilter = sq.NotEq{"TeamId": teamIds}
+	}
+
+	channels, args, err := sq.Select("Id").From("Channels").Where(teamFilter).ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build the channels query")
+	}
+
+	return s.removeForUser(userId, sq.Expr("channel_id IN ("+channels+")", args...))
+}
+
+// removeForUser deletes the read cursors of a user in the channels matching channelFilter, or in
+// all channels when it is nil
+func (s *SqlChannelReadCursorStore) removeForUser(userId string, channelFilter sq.Sqlizer) (_ []*model.ReadCursorEvent, err error) {
+	filter := sq.And{sq.Eq{"user_id": userId}}
+	if channelFilter != nil {
+		filter = append(filter, channelFilter)
+	}
+
+	tx, err := s.GetMaster().Beginx()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	channelIds := []string{}
+	err = tx.SelectBuilder(&channelIds, s.getQueryBuilder().
+		Delete("channel_read_cursors").
+		Where(filter).
+		Suffix("RETURNING channel_id"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to delete channel read cursors for user_id=%s", userId)
+	}
+
+	threadChannelIds := []string{}
+	err = tx.SelectBuilder(&threadChannelIds, s.getQueryBuilder().
+		Delete("thread_read_cursors").
+		Where(filter).
+		Suffix("RETURNING channel_id"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to delete thread read cursors for user_id=%s", userId)
+	}
+
+	// A single removal event per channel also drops the user's thread cursors in it
+	now := model.GetMillis()
+	events := []*model.ReadCursorEvent{}
+	seen := make(map[string]bool, len(channelIds))
+	for _, channelId := range append(channelIds, threadChannelIds...) {
+		if seen[channelId] {
+			continue
+		}
+		seen[channelId] = true
+		events = append(events, &model.ReadCursorEvent{
+			Type:      model.ReadCursorEventTypeRemoved,
+			EventId:   model.NewId(),
+			ChannelId: channelId,
+			UserId:    userId,
+			Timestamp: now,
+		})
+	}
+
+	if err = s.enqueueEvents(tx, events); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit_transaction")
+	}
+
+	return events, nil
+}
+
+// readStateValues are the values of the share_read_state preference, as parsed by
+// strconv.ParseBool. Any other value falls back to the defaults.
+var readStateValues = map[bool][]string{
+	true:  {"1", "t", "T", "TRUE", "true", "True"},
+	false: {"0", "f", "F", "FALSE", "false", "False"},
+}
+
+// notSharingReadState matches the users, identified by userIdColumn, who don't share their read
+// state: those who turned the preference off and, unless sharesByDefault, those who didn't turn it on
+func notSharingReadState(userIdColumn string, sharesByDefault bool) sq.Sqlizer {
+	return readStatePreference(userIdColumn, sharesByDefault, false)
+}
+
+// sharingReadState matches the users who share their read state, the opposite of
+// notSharingReadState
+func sharingReadState(userIdColumn string, sharesByDefault bool) sq.Sqlizer {
+	return readStatePreference(userIdColumn, sharesByDefault, true)
+}
+
+// readStatePreference matches the users whose read state sharing is share, looking for the
+// preference that differs from sharesByDefault
+func readStatePreference(userIdColumn string, sharesByDefault, share bool) sq.Sqlizer {
+	preference, args, _ := sq.Select("1").
+		From("Preferences p").
+		Where("p.UserId = " + userIdColumn).
+		Where(sq.Eq{
+			"p.Category": model.PreferenceCategoryReadReceipts,
+			"p.Name":     model.PreferenceNameShareReadState,
+			"p.Value":    readStateValues[!sharesByDefault],
+		}).
+		ToSql()
+
+	if sharesByDefault != share {
+		return sq.Expr("EXISTS ("+preference+")", args...)
+	}
+	return sq.Expr("NOT EXISTS ("+preference+")", args...)
+}
+
+// GetNonSharingMemberIds retrieves the ids of the channel members who don't share their read state
+func (s *SqlChannelReadCursorStore) GetNonSharingMemberIds(channelId string, sharesByDefault bool) ([]string, error) {
+	userIds := []string{}
+
+	query := s.getQueryBuilder().
+		Select("cm.UserId").
+		From("ChannelMembers cm").
+		Where(sq.Eq{"cm.ChannelId": channelId}).
+		Where(notSharingReadState("cm.UserId", sharesByDefault))
+
+	if err := s.GetReplica().SelectBuilder(&userIds, query); err != nil {
+		return nil, errors.Wrapf(err, "failed to get non sharing members for channel_id=%s", channelId)
+	}
+
+	return userIds, nil
+}
+
+// GetNonSharingReaderIds retrieves the ids of the users with a read cursor in the channel, or in one
+// of its threads, who don't share their read state
+func (s *SqlChannelReadCursorStore) GetNonSharingReaderIds(channelId string, sharesByDefault bool) ([]string, error) {
+	userIds := []string{}
+
+	readers := sq.Select("user_id").
+		From("channel_read_cursors").
+		Where(sq.Eq{"channel_id": channelId}).
+		SuffixExpr(sq.ConcatExpr("UNION ", sq.Select("user_id").
+			From("thread_read_cursors").
+			Where(sq.Eq{"channel_id": channelId})))
+
+	query := s.getQueryBuilder().
+		Select("r.user_id").
+		FromSelect(readers, "r").
+		Where(notSharingReadState("r.user_id", sharesByDefault))
+
+	if err := s.GetReplica().SelectBuilder(&userIds, query); err != nil {
+		return nil, errors.Wrapf(err, "failed to get non sharing readers for channel_id=%s", channelId)
+	}
+
+	return userIds, nil
+}
+
+// RemoveForChannel deletes all read cursors in a channel and enqueues a removal event for the
+// whole channel in read_cursor_outbox within the same transaction
+func (s *SqlChannelReadCursorStore) RemoveForChannel(channelId string) (_ *model.ReadCursorEvent, err error) {
+	tx, err := s.GetMaster().Beginx()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to start transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	result, err := tx.ExecBuilder(s.getQueryBuilder().
+		Delete("channel_read_cursors").
+		Where(sq.Eq{"channel_id": channelId}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to delete channel read cursors for channel_id=%s", channelId)
+	}
+
+	threadResult, err := tx.ExecBuilder(s.getQueryBuilder().
+		Delete("thread_read_cursors").
+		Where(sq.Eq{"channel_id": channelId}))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to delete thread read cursors for channel_id=%s", channelId)
+	}
+
+	rows, _ := result.RowsAffected()
+	threadRows, _ := threadResult.RowsAffected()
+	if rows+threadRows == 0 {
+		err = tx.Commit()
+		return nil, errors.Wrap(err, "commit_transaction")
+	}
+
+	event := &model.ReadCursorEvent{
+		Type:      model.ReadCursorEventTypeRemoved,
+		EventId:   model.NewId(),
+		ChannelId: channelId,
+		Timestamp: model.GetMillis(),
+	}
+
+	if err = s.enqueueEvents(tx, []*model.ReadCursorEvent{event}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit_transaction")
+	}
+
+	return event, nil
+}
+
+// enqueueEvents writes events to read_cursor_outbox, held back for the delivery delay
+func (s *SqlChannelReadCursorStore) enqueueEvents(tx *sqlxTxWrapper, events []*model.ReadCursorEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	now := model.GetMillis()
+	query := s.getQueryBuilder().
+		Insert("read_cursor_outbox").
+		Columns("event_id", "channel_id", "payload", "create_at", "next_attempt_at")
+
+	for _, event := range events {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return errors.Wrap(err, "failed to encode read cursor event")
+		}
+		query = query.Values(event.EventId, event.ChannelId, string(payload), now, now+model.ReadCursorOutboxDeliveryDelay)
+	}
+
+	if _, err := tx.ExecBuilder(query); err != nil {
+		return errors.Wrap(err, "failed to enqueue read cursor events")
 	}
 
 	return nil
@@ -91,6 +436,25 @@ func (s *SqlChannelReadCursorStore) Get(channelId, userId string) (*model.Channe
 	return &cursor, nil
 }
 
+// GetThread retrieves the read cursor for a specific user in a thread
+func (s *SqlChannelReadCursorStore) GetThread(rootId, userId string) (*model.ThreadReadCursor, error) {
+	var cursor model.ThreadReadCursor
+
+	query := s.getQueryBuilder().
+		Select("root_id", "channel_id", "user_id", "last_post_seq", "updated_at").
+		From("thread_read_cursors").
+		Where(sq.Eq{"root_id": rootId, "user_id": userId})
+
+	if err := s.GetReplica().GetBuilder(&cursor, query); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.NewErrNotFound("ThreadReadCursor", fmt.Sprintf("root=%s,user=%s", rootId, userId))
+		}
+		return nil, errors.Wrap(err, "failed to get thread read cursor")
+	}
+
+	return &cursor, nil
+}
+
 // GetForChannel retrieves all read cursors for a channel
 func (s *SqlChannelReadCursorStore) GetForChannel(channelId string) ([]*model.ChannelReadCursor, error) {
 	var cursors []*model.ChannelReadCursor
@@ -135,6 +499,255 @@ func (s *SqlChannelReadCursorStore) GetForUser(userId string) ([]*model.ChannelR
 	return cursors, nil
 }
 
+// cursorsFor selects the cursors in a channel matching where. With a rootId the cursors in that
+// thread are added, and each user's further cursor is kept.
+func (s *SqlChannelReadCursorStore) cursorsFor(channelId, rootId string, where sq.Sqlizer) sq.SelectBuilder {
+	channelCursors := s.getQueryBuilder().
+		Select("channel_id", "user_id", "last_post_seq", "updated_at").
+		From("channel_read_cursors").
+		Where(sq.Eq{"channel_id": channelId}).
+		Where(where)
+
+	if rootId == "" {
+		return channelCursors
+	}
+
+	threadCursors := sq.Select("channel_id", "user_id", "last_post_seq", "updated_at").
+		From("thread_read_cursors").
+		Where(sq.Eq{"root_id": rootId}).
+		Where(where)
+
+	return s.getQueryBuilder().
+		Select("channel_id", "user_id", "MAX(last_post_seq) AS last_post_seq", "MAX(updated_at) AS updated_at").
+		FromSelect(channelCursors.SuffixExpr(sq.ConcatExpr("UNION ALL ", threadCursors)), "c").
+		GroupBy("channel_id", "user_id")
+}
+
+// GetForChannelUsers retrieves the read cursors of the given users in a channel, including
+// their cursors in the thread when rootId is set
+func (s *SqlChannelReadCursorStore) GetForChannelUsers(channelId, rootId string, userIds []string) ([]*model.ChannelReadCursor, error) {
+	cursors := []*model.ChannelReadCursor{}
+	if len(userIds) == 0 {
+		return cursors, nil
+	}
+
+	query := s.getQueryBuilder().
+		Select("channel_id", "user_id", "last_post_seq", "updated_at").
+		FromSelect(s.cursorsFor(channelId, rootId, sq.Eq{"user_id": userIds}), "cursors").
+		OrderBy("updated_at DESC")
+
+	if err := s.GetReplica().SelectBuilder(&cursors, query); err != nil {
+		return nil, errors.Wrap(err, "failed to get channel read cursors for users")
+	}
+
+	return cursors, nil
+}
+
+// GetForSeq retrieves up to limit cursors in a channel that are at or past seq, excluding excludeUserId.
+// Cursors in the thread count as well when rootId is set.
+func (s *SqlChannelReadCursorStore) GetForSeq(channelId, rootId string, seq int64, excludeUserId string, limit int) ([]*model.ChannelReadCursor, error) {
+	cursors := []*model.ChannelReadCursor{}
+
+	query := s.getQueryBuilder().
+		Select("channel_id", "user_id", "last_post_seq", "updated_at").
+		FromSelect(s.cursorsFor(channelId, rootId, seqFilter(seq, excludeUserId)), "cursors").
+		OrderBy("updated_at DESC").
+		Limit(uint64(limit))
+
+	if err := s.GetReplica().SelectBuilder(&cursors, query); err != nil {
+		return nil, errors.Wrap(err, "failed to get channel read cursors for seq")
+	}
+
+	return cursors, nil
+}
+
+// CountForSeq counts the users in a channel whose cursor is at or past seq, excluding excludeUserId.
+// Cursors in the thread count as well when rootId is set.
+func (s *SqlChannelReadCursorStore) CountForSeq(channelId, rootId string, seq int64, excludeUserId string) (int64, error) {
+	query := s.getQueryBuilder().
+		Select("COUNT(*)").
+		FromSelect(s.cursorsFor(channelId, rootId, seqFilter(seq, excludeUserId)), "cursors")
+
+	var count int64
+	if err := s.GetReplica().GetBuilder(&count, query); err != nil {
+		return 0, errors.Wrap(err, "failed to count channel read cursors for seq")
+	}
+
+	return count, nil
+}
+
+// CountForSeqs counts, in a single query, the users CountForSeq would count for each of the queries
+func (s *SqlChannelReadCursorStore) CountForSeqs(channelId string, queries []store.ReadCursorSeqQuery) ([]int64, error) {
+	counts := make([]int64, len(queries))
+	if len(queries) == 0 {
+		return counts, nil
+	}
+
+	values := make([]string, len(queries))
+	args := make([]any, 0, 4*len(queries))
+	rootIds := []string{}
+	minSeq := queries[0].Seq
+	for i, query := range queries {
+		values[i] = "(?::integer, ?::bigint, ?::varchar, ?::varchar)"
+		args = append(args, i, query.Seq, query.RootId, query.ExcludeUserId)
+		if query.RootId != "" {
+			rootIds = append(rootIds, query.RootId)
+		}
+		minSeq = min(minSeq, query.Seq)
+	}
+
+	// The thread cursors only count for the queries of their thread, and a user with both cursors
+	// is counted once
+	cursors := s.getQueryBuilder().
+		Select("user_id", "last_post_seq", "'' AS root_id").
+		From("channel_read_cursors").
+		Where(sq.Eq{"channel_id": channelId}).
+		Where(sq.GtOrEq{"last_post_seq": minSeq})
+	if len(rootIds) > 0 {
+		cursors = cursors.SuffixExpr(sq.ConcatExpr("UNION ALL ", sq.Select("user_id", "last_post_seq", "root_id").
+			From("thread_read_cursors").
+			Where(sq.Eq{"root_id": rootIds}).
+			Where(sq.GtOrEq{"last_post_seq": minSeq})))
+	}
+
+	query := s.getQueryBuilder().
+		Select("q.idx", "COUNT(DISTINCT c.user_id) AS count").
+		FromSelect(cursors, "c").
+		JoinClause("JOIN (VALUES "+strings.Join(values, ", ")+") AS q(idx, seq, root_id, exclude_user_id)"+
+			" ON c.last_post_seq >= q.seq AND c.user_id <> q.exclude_user_id AND (c.root_id = '' OR c.root_id = q.root_id)", args...).
+		GroupBy("q.idx")
+
+	var rows []struct {
+		Idx   int   `db:"idx"`
+		Count int64 `db:"count"`
+	}
+	if err := s.GetReplica().SelectBuilder(&rows, query); err != nil {
+		return nil, errors.Wrapf(err, "failed to count channel read cursors for seqs in channel_id=%s", channelId)
+	}
+
+	for _, row := range rows {
+		counts[row.Idx] = row.Count
+	}
+
+	return counts, nil
+}
+
+// seqFilter matches cursors at or past seq that don't belong to excludeUserId
+func seqFilter(seq int64, excludeUserId string) sq.Sqlizer {
+	filter := sq.And{sq.GtOrEq{"last_post_seq": seq}}
+	if excludeUserId != "" {
+		filter = append(filter, sq.NotEq{"user_id": excludeUserId})
+	}
+	return filter
+}
+
+// nonReaders selects the active, non-bot members of a channel other than excludeUserId who share
+// their read state and whose cursor is before seq, also considering their cursor in the thread
+// when rootId is set
+func (s *SqlChannelReadCursorStore) nonReaders(channelId, rootId string, seq int64, excludeUserId string, sharesByDefault bool) sq.SelectBuilder {
+	query := s.getQueryBuilder().
+		Select().
+		From("ChannelMembers cm").
+		Join("Users u ON u.Id = cm.UserId").
+		LeftJoin("Bots b ON b.UserId = cm.UserId").
+		Where(sq.Eq{"cm.ChannelId": channelId, "u.DeleteAt": 0, "b.UserId": nil}).
+		Where(sharingReadState("cm.UserId", sharesByDefault)).
+		Where("NOT EXISTS (SELECT 1 FROM channel_read_cursors c WHERE c.channel_id = cm.ChannelId AND c.user_id = cm.UserId AND c.last_post_seq >= ?)", seq)
+
+	if rootId != "" {
+		query = query.Where("NOT EXISTS (SELECT 1 FROM thread_read_cursors t WHERE t.root_id = ? AND t.user_id = cm.UserId AND t.last_post_seq >= ?)", rootId, seq)
+	}
+
+	if excludeUserId != "" {
+		query = query.Where(sq.NotEq{"cm.UserId": excludeUserId})
+	}
+
+	return query
+}
+
+// GetNonReaders retrieves, ordered by username, the ids of the active, non-bot channel members
+// other than excludeUserId who share their read state and whose cursor is before seq
+func (s *SqlChannelReadCursorStore) GetNonReaders(channelId, rootId string, seq int64, excludeUserId string, sharesByDefault bool, offset, limit int) ([]string, error) {
+	userIds := []string{}
+
+	query := s.nonReaders(channelId, rootId, seq, excludeUserId, sharesByDefault).
+		Columns("cm.UserId").
+		OrderBy("u.Username").
+		Offset(uint64(offset)).
+		Limit(uint64(limit))
+
+	if err := s.GetReplica().SelectBuilder(&userIds, query); err != nil {
+		return nil, errors.Wrapf(err, "failed to get non readers for channel_id=%s", channelId)
+	}
+
+	return userIds, nil
+}
+
+// CountNonReaders counts the active, non-bot channel members other than excludeUserId who share
+// their read state and whose cursor is before seq
+func (s *SqlChannelReadCursorStore) CountNonReaders(channelId, rootId string, seq int64, excludeUserId string, sharesByDefault bool) (int64, error) {
+	query := s.nonReaders(channelId, rootId, seq, excludeUserId, sharesByDefault).
+		Columns("COUNT(*)")
+
+	var count int64
+	if err := s.GetReplica().GetBuilder(&count, query); err != nil {
+		return 0, errors.Wrapf(err, "failed to count non readers for channel_id=%s", channelId)
+	}
+
+	return count, nil
+}
+
+// GetDailyReadSummary summarizes, per UTC day, how many of the channel's current active, non-bot
+// members have read the root posts created since the given timestamp. Rather than counting the
+// readers of each post separately, the posts and the cursors are sorted together so that a running
+// count gives the number of cursors at or past each post.
+func (s *SqlChannelReadCursorStore) GetDailyReadSummary(channelId string, since int64) ([]*model.ReadReceiptsDailySummary, error) {
+	summaries := []*model.ReadReceiptsDailySummary{}
+
+	query := `
+		WITH members AS (
+			SELECT cm.UserId
+			FROM ChannelMembers cm
+			JOIN Users u ON u.Id = cm.UserId
+			LEFT JOIN Bots b ON b.UserId = cm.UserId
+			WHERE cm.ChannelId = ? AND u.DeleteAt = 0 AND b.UserId IS NULL
+		), reads AS (
+			SELECT c.user_id, c.last_post_seq
+			FROM channel_read_cursors c
+			JOIN members m ON m.UserId = c.user_id
+			WHERE c.channel_id = ? AND c.last_post_seq >= ?
+		), timeline AS (
+			SELECT p.CreateAt AS seq, p.UserId AS author_id, 0 AS is_read
+			FROM Posts p
+			WHERE p.ChannelId = ? AND p.RootId = '' AND p.DeleteAt = 0 AND p.Type NOT LIKE 'system_%' AND p.CreateAt >= ?
+			UNION ALL
+			SELECT last_post_seq, NULL, 1
+			FROM reads
+		), posts AS (
+			SELECT seq, author_id, is_read,
+				SUM(is_read) OVER (ORDER BY seq DESC, is_read DESC ROWS UNBOUNDED PRECEDING) AS readers
+			FROM timeline
+		)
+		SELECT
+			to_char(to_timestamp(p.seq / 1000.0) AT TIME ZONE 'UTC', 'YYYY-MM-DD') AS date,
+			COUNT(*) AS post_count,
+			SUM(p.readers - CASE WHEN r.user_id IS NULL THEN 0 ELSE 1 END)::bigint AS read_count,
+			SUM(mc.total - CASE WHEN am.UserId IS NULL THEN 0 ELSE 1 END)::bigint AS expected_count
+		FROM posts p
+		CROSS JOIN (SELECT COUNT(*) AS total FROM members) mc
+		LEFT JOIN reads r ON r.user_id = p.author_id AND r.last_post_seq >= p.seq
+		LEFT JOIN members am ON am.UserId = p.author_id
+		WHERE p.is_read = 0
+		GROUP BY 1
+		ORDER BY 1`
+
+	if err := s.GetReplica().Select(&summaries, query, channelId, channelId, since, channelId, since); err != nil {
+		return nil, errors.Wrapf(err, "failed to get daily read summary for channel_id=%s", channelId)
+	}
+
+	return summaries, nil
+}
+
 // Delete removes a read cursor
 func (s *SqlChannelReadCursorStore) Delete(channelId, userId string) error {
 	query := s.getQueryBuilder().
@@ -194,3 +807,96 @@ func (s *SqlChannelReadCursorStore) DeleteOldCursors(olderThan int64) error {
 
 	return nil
 }
+
+// PermanentDeleteBatchForRetentionPolicies removes the channel read cursors positioned before the
+// retention cutoff of their channel. The posts they point at are gone, so they no longer count for
+// any post; the read position of users who simply haven't read in a while is kept. An expiry
+// event is enqueued for each removed cursor.
+func (s *SqlChannelReadCursorStore) PermanentDeleteBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error) {
+	builder := s.getQueryBuilder().
+		Select("channel_read_cursors.channel_id", "channel_read_cursors.user_id").
+		From("channel_read_cursors")
+	return genericPermanentDeleteBatchForRetentionPolicies(RetentionPolicyBatchDeletionInfo{
+		BaseBuilder:         builder,
+		Table:               "channel_read_cursors",
+		TimeColumn:          "last_post_seq",
+		PrimaryKeys:         []string{"channel_id", "user_id"},
+		ChannelIDTable:      "channel_read_cursors",
+		ChannelIDColumn:     "channel_id",
+		NowMillis:           retentionPolicyBatchConfigs.Now,
+		GlobalPolicyEndTime: retentionPolicyBatchConfigs.GlobalPolicyEndTime,
+		Limit:               retentionPolicyBatchConfigs.Limit,
+		Delete: func(builder sq.SelectBuilder) (int64, error) {
+			return s.deleteExpired("channel_read_cursors", "(channel_id, user_id)", "'' AS root_id", builder)
+		},
+	}, s.SqlStore, cursor)
+}
+
+// PermanentDeleteBatchThreadCursorsForRetentionPolicies removes the thread read cursors positioned
+// before the retention cutoff of their channel, enqueuing an expiry event for each of them.
+func (s *SqlChannelReadCursorStore) PermanentDeleteBatchThreadCursorsForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error) {
+	builder := s.getQueryBuilder().
+		Select("thread_read_cursors.root_id", "thread_read_cursors.user_id").
+		From("thread_read_cursors")
+	return genericPermanentDeleteBatchForRetentionPolicies(RetentionPolicyBatchDeletionInfo{
+		BaseBuilder:         builder,
+		Table:               "thread_read_cursors",
+		TimeColumn:          "last_post_seq",
+		PrimaryKeys:         []string{"root_id", "user_id"},
+		ChannelIDTable:      "thread_read_cursors",
+		ChannelIDColumn:     "channel_id",
+		NowMillis:           retentionPolicyBatchConfigs.Now,
+		GlobalPolicyEndTime: retentionPolicyBatchConfigs.GlobalPolicyEndTime,
+		Limit:               retentionPolicyBatchConfigs.Limit,
+		Delete: func(builder sq.SelectBuilder) (int64, error) {
+			return s.deleteExpired("thread_read_cursors", "(root_id, user_id)", "root_id", builder)
+		},
+	}, s.SqlStore, cursor)
+}
+
+// deleteExpired deletes the cursors of table whose primary keys are selected by the builder and
+// enqueues an expiry event for each of them in the same transaction
+func (s *SqlChannelReadCursorStore) deleteExpired(table, primaryKeys, rootIdColumn string, builder sq.SelectBuilder) (_ int64, err error) {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return 0, errors.Wrap(err, table+"_tosql")
+	}
+
+	tx, err := s.GetMaster().Beginx()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to start transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	var expired []struct {
+		ChannelId string `db:"channel_id"`
+		RootId    string `db:"root_id"`
+		UserId    string `db:"user_id"`
+	}
+	query = fmt.Sprintf("DELETE FROM %s WHERE %s IN (%s) RETURNING channel_id, %s, user_id", table, primaryKeys, query, rootIdColumn)
+	if err = tx.Select(&expired, query, args...); err != nil {
+		return 0, errors.Wrapf(err, "failed to delete %s", table)
+	}
+
+	now := model.GetMillis()
+	events := make([]*model.ReadCursorEvent, len(expired))
+	for i, row := range expired {
+		events[i] = &model.ReadCursorEvent{
+			Type:      model.ReadCursorEventTypeExpired,
+			EventId:   model.NewId(),
+			ChannelId: row.ChannelId,
+			RootId:    row.RootId,
+			UserId:    row.UserId,
+			Timestamp: now,
+		}
+	}
+	if err = s.enqueueEvents(tx, events); err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit_transaction")
+	}
+
+	return int64(len(expired)), nil
+}
@@ -137,6 +137,7 @@ func channelSliceColumns(isSelect bool, prefix ...string) []string {
 		p + "LastRootPostAt",
 		p + "BannerInfo",
 		p + "DefaultCategoryName",
+		p + "Props",
 	}
 
 	if isSelect {
@@ -173,6 +174,7 @@ func channelToSlice(channel *model.Channel) []any {
 		channel.LastRootPostAt,
 		channel.BannerInfo,
 		channel.DefaultCategoryName,
+		channel.PersistentProps(),
 	}
 }
 
@@ -784,6 +786,10 @@ func (s SqlChannelStore) updateChannelT(transaction *sqlxTxWrapper, channel *mod
 		return nil, err
 	}
 
+	// The props filled in for clients are left out, without changing the returned channel
+	toSave := *channel
+	toSave.Props = channel.PersistentProps()
+
 	res, err := transaction.NamedExec(`UPDATE Channels
 		SET CreateAt=:CreateAt,
 			UpdateAt=:UpdateAt,
@@ -804,8 +810,9 @@ func (s SqlChannelStore) updateChannelT(transaction *sqlxTxWrapper, channel *mod
 			TotalMsgCountRoot=:TotalMsgCountRoot,
 			LastRootPostAt=:LastRootPostAt,
 		    BannerInfo=:BannerInfo,
-			DefaultCategoryName=:DefaultCategoryName
-		WHERE Id=:Id`, channel)
+			DefaultCategoryName=:DefaultCategoryName,
+			Props=:Props
+		WHERE Id=:Id`, &toSave)
 	if err != nil {
 		if IsUniqueConstraintError(err, []string{"Name", "channels_name_teamid_key"}) {
 			return nil, store.NewErrUniqueConstraint("Name")
@@ -0,0 +1,99 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	sq "github.com/mattermost/squirrel"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+type SqlEmailBatchStore struct {
+	*SqlStore
+}
+
+func newSqlEmailBatchStore(sqlStore *SqlStore) store.EmailBatchStore {
+	return &SqlEmailBatchStore{sqlStore}
+}
+
+func (s *SqlEmailBatchStore) Save(entry *model.EmailBatchEntry) error {
+	entry.PreSave()
+
+	// A post is notified once even when it is queued again, e.g. for a mention and a reply
+	query := s.getQueryBuilder().
+		Insert("EmailBatchEntries").
+		Columns("Id", "UserId", "PostId", "ChannelId", "TeamName", "CreateAt").
+		Values(entry.Id, entry.UserId, entry.PostId, entry.ChannelId, entry.TeamName, entry.CreateAt).
+		Suffix("ON CONFLICT (UserId, PostId) DO NOTHING")
+
+	if _, err := s.GetMaster().ExecBuilder(query); err != nil {
+		return errors.Wrapf(err, "failed to save EmailBatchEntry with userId=%s, postId=%s", entry.UserId, entry.PostId)
+	}
+
+	return nil
+}
+
+func (s *SqlEmailBatchStore) GetPendingUsers(afterUserId string, limit int) ([]*model.EmailBatchUser, error) {
+	users := []*model.EmailBatchUser{}
+
+	query := s.getQueryBuilder().
+		Select("UserId", "MIN(CreateAt) AS OldestCreateAt").
+		From("EmailBatchEntries").
+		Where(sq.Gt{"UserId": afterUserId}).
+		GroupBy("UserId").
+		OrderBy("UserId").
+		Limit(uint64(limit))
+
+	if err := s.GetMaster().SelectBuilder(&users, query); err != nil {
+		return nil, errors.Wrap(err, "failed to get users with pending EmailBatchEntries")
+	}
+
+	return users, nil
+}
+
+func (s *SqlEmailBatchStore) GetForUser(userId string) ([]*model.EmailBatchEntry, error) {
+	entries := []*model.EmailBatchEntry{}
+
+	query := s.getQueryBuilder().
+		Select("Id", "UserId", "PostId", "ChannelId", "TeamName", "CreateAt").
+		From("EmailBatchEntries").
+		Where(sq.Eq{"UserId": userId}).
+		OrderBy("CreateAt", "Id")
+
+	if err := s.GetMaster().SelectBuilder(&entries, query); err != nil {
+		return nil, errors.Wrapf(err, "failed to get EmailBatchEntries for userId=%s", userId)
+	}
+
+	return entries, nil
+}
+
+func (s *SqlEmailBatchStore) Delete(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := s.getQueryBuilder().
+		Delete("EmailBatchEntries").
+		Where(sq.Eq{"Id": ids})
+
+	if _, err := s.GetMaster().ExecBuilder(query); err != nil {
+		return errors.Wrap(err, "failed to delete EmailBatchEntries")
+	}
+
+	return nil
+}
+
+func (s *SqlEmailBatchStore) DeleteForChannelMember(userId, channelId string) error {
+	query := s.getQueryBuilder().
+		Delete("EmailBatchEntries").
+		Where(sq.Eq{"UserId": userId, "ChannelId": channelId})
+
+	if _, err := s.GetMaster().ExecBuilder(query); err != nil {
+		return errors.Wrapf(err, "failed to delete EmailBatchEntries with userId=%s, channelId=%s", userId, channelId)
+	}
+
+	return nil
+}
@@ -530,6 +530,129 @@ func (fs SqlFileInfoStore) PermanentDeleteByUser(rctx request.CTX, userId string
 	return rowsAffected, nil
 }
 
+// GetForPostIds returns the file infos attached to any of the posts, including the deleted ones.
+func (fs SqlFileInfoStore) GetForPostIds(postIDs []string) ([]*model.FileInfo, error) {
+	infos := []*model.FileInfo{}
+	if len(postIDs) == 0 {
+		return infos, nil
+	}
+
+	query := fs.getQueryBuilder().
+		Select(fs.queryFields...).
+		From("FileInfo").
+		Where(sq.Eq{"PostId": postIDs}).
+		OrderBy("CreateAt", "Id")
+
+	if err := fs.GetMaster().SelectBuilder(&infos, query); err != nil {
+		return nil, errors.Wrap(err, "failed to find FileInfos for posts")
+	}
+	return infos, nil
+}
+
+// GetBatchForRetentionPolicies returns up to limit file infos past the retention period of the
+// policy of their post's channel, oldest first. As for posts, channel policies take precedence over
+// team policies, which take precedence over the global policy ending at GlobalPolicyEndTime, and
+// the files of pinned posts are kept with PreservePinnedPosts. Files which aren't attached to a
+// post only fall under the global policy, and the files of channel bookmarks never expire.
+//
+// Each call looks at a single policy scope and moves the cursor past it once it has no more
+// files. Since the files are returned rather than deleted, the caller must delete them before
+// asking for the next batch.
+func (fs SqlFileInfoStore) GetBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) ([]*model.FileInfo, model.RetentionPolicyCursor, error) {
+	if retentionPolicyBatchConfigs.GlobalPolicyEndTime <= 0 {
+		cursor.GlobalPoliciesDone = true
+	}
+	if retentionPolicyBatchConfigs.Now <= 0 {
+		cursor.ChannelPoliciesDone = true
+		cursor.TeamPoliciesDone = true
+	}
+
+	builder := fs.getQueryBuilder().
+		Select(fs.queryFields...).
+		From("FileInfo").
+		LeftJoin("Posts ON FileInfo.PostId = Posts.Id").
+		LeftJoin("Channels ON Posts.ChannelId = Channels.Id").
+		Where(sq.NotEq{"FileInfo.CreatorId": model.BookmarkFileOwner}).
+		OrderBy("FileInfo.CreateAt", "FileInfo.Id").
+		Limit(uint64(retentionPolicyBatchConfigs.Limit))
+
+	if retentionPolicyBatchConfigs.PreservePinnedPosts {
+		builder = builder.Where(sq.Or{
+			sq.Eq{"Posts.Id": nil},
+			sq.Eq{"Posts.IsPinned": false},
+			sq.Gt{"Posts.DeleteAt": 0},
+		})
+	}
+
+	const millisecondsInADay = 24 * 60 * 60 * 1000
+	fallsUnderGranularPolicy := sq.And{
+		sq.GtOrEq{"RetentionPolicies.PostDuration": 0},
+		sq.Expr("? - FileInfo.CreateAt > RetentionPolicies.PostDuration * ?", retentionPolicyBatchConfigs.Now, millisecondsInADay),
+	}
+
+	var scopeDone *bool
+	switch {
+	case !cursor.ChannelPoliciesDone:
+		builder = builder.
+			InnerJoin("RetentionPoliciesChannels ON Posts.ChannelId = RetentionPoliciesChannels.ChannelId").
+			InnerJoin("RetentionPolicies ON RetentionPoliciesChannels.PolicyId = RetentionPolicies.Id").
+			Where(fallsUnderGranularPolicy)
+		scopeDone = &cursor.ChannelPoliciesDone
+	case !cursor.TeamPoliciesDone:
+		// Channel-specific policies override team-specific policies
+		builder = builder.
+			LeftJoin("RetentionPoliciesChannels ON Posts.ChannelId = RetentionPoliciesChannels.ChannelId").
+			InnerJoin("RetentionPoliciesTeams ON Channels.TeamId = RetentionPoliciesTeams.TeamId").
+			InnerJoin("RetentionPolicies ON RetentionPoliciesTeams.PolicyId = RetentionPolicies.Id").
+			Where(sq.Eq{"RetentionPoliciesChannels.PolicyId": nil}).
+			Where(fallsUnderGranularPolicy)
+		scopeDone = &cursor.TeamPoliciesDone
+	case !cursor.GlobalPoliciesDone:
+		// Granular policies override the global policy
+		builder = builder.
+			LeftJoin("RetentionPoliciesChannels ON Posts.ChannelId = RetentionPoliciesChannels.ChannelId").
+			LeftJoin("RetentionPoliciesTeams ON Channels.TeamId = RetentionPoliciesTeams.TeamId").
+			Where(sq.Eq{
+				"RetentionPoliciesChannels.PolicyId": nil,
+				"RetentionPoliciesTeams.PolicyId":    nil,
+			}).
+			Where(sq.Lt{"FileInfo.CreateAt": retentionPolicyBatchConfigs.GlobalPolicyEndTime})
+		scopeDone = &cursor.GlobalPoliciesDone
+	default:
+		return []*model.FileInfo{}, cursor, nil
+	}
+
+	infos := []*model.FileInfo{}
+	if err := fs.GetMaster().SelectBuilder(&infos, builder); err != nil {
+		return nil, cursor, errors.Wrap(err, "failed to find FileInfos past their retention policy")
+	}
+	if int64(len(infos)) < retentionPolicyBatchConfigs.Limit {
+		*scopeDone = true
+	}
+
+	return infos, cursor, nil
+}
+
+func (fs SqlFileInfoStore) PermanentDeleteByIds(rctx request.CTX, fileIDs []string) (int64, error) {
+	if len(fileIDs) == 0 {
+		return 0, nil
+	}
+
+	sqlResult, err := fs.GetMaster().ExecBuilder(fs.getQueryBuilder().
+		Delete("FileInfo").
+		Where(sq.Eq{"Id": fileIDs}))
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to delete FileInfos by ids")
+	}
+
+	rowsAffected, err := sqlResult.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to retrieve rows affected")
+	}
+
+	return rowsAffected, nil
+}
+
 func (fs SqlFileInfoStore) Search(rctx request.CTX, paramsList []*model.SearchParams, userId, teamId string, page, perPage int) (*model.FileInfoList, error) {
 	// Since we don't support paging for DB search, we just return nothing for later pages
 	if page > 0 {
@@ -0,0 +1,210 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	sq "github.com/mattermost/squirrel"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+type SqlMfaFactorStore struct {
+	*SqlStore
+}
+
+func newSqlMfaFactorStore(sqlStore *SqlStore) store.MfaFactorStore {
+	return &SqlMfaFactorStore{sqlStore}
+}
+
+func (s *SqlMfaFactorStore) SaveWebAuthnCredential(credential *model.WebAuthnCredential) (_ *model.WebAuthnCredential, err error) {
+	credential.PreSave()
+	if appErr := credential.IsValid(); appErr != nil {
+		return nil, appErr
+	}
+
+	tx, err := s.GetMaster().Beginx()
+	if err != nil {
+		return nil, errors.Wrap(err, "begin_transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	var count int64
+	query := s.getQueryBuilder().
+		Select("COUNT(*)").
+		From("WebAuthnCredentials").
+		Where(sq.Eq{"UserId": credential.UserId})
+	if err = tx.GetBuilder(&count, query); err != nil {
+		return nil, errors.Wrapf(err, "failed to count WebAuthnCredentials for userId=%s", credential.UserId)
+	}
+
+	if count >= model.MaxWebAuthnCredentialsPerUser {
+		return nil, store.NewErrLimitExceeded("webauthn_credentials_per_user", int(count), "userId="+credential.UserId)
+	}
+
+	insert := s.getQueryBuilder().
+		Insert("WebAuthnCredentials").
+		Columns("Id", "UserId", "Name", "CredentialId", "Credential", "CreateAt", "LastUsedAt").
+		Values(credential.Id, credential.UserId, credential.Name, credential.CredentialId, credential.Credential, credential.CreateAt, credential.LastUsedAt)
+	if _, err = tx.ExecBuilder(insert); err != nil {
+		if IsUniqueConstraintError(err, []string{"CredentialId", "webauthncredentials_credentialid_key"}) {
+			return nil, store.NewErrConflict("WebAuthnCredential", err, "credentialId="+credential.CredentialId)
+		}
+		return nil, errors.Wrapf(err, "failed to save WebAuthnCredential with userId=%s", credential.UserId)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit_transaction")
+	}
+
+	return credential, nil
+}
+
+func (s *SqlMfaFactorStore) GetWebAuthnCredentials(userId string) ([]*model.WebAuthnCredential, error) {
+	credentials := []*model.WebAuthnCredential{}
+
+	query := s.getQueryBuilder().
+		Select("Id", "UserId", "Name", "CredentialId", "Credential", "CreateAt", "LastUsedAt").
+		From("WebAuthnCredentials").
+		Where(sq.Eq{"UserId": userId}).
+		OrderBy("CreateAt", "Id")
+
+	if err := s.GetMaster().SelectBuilder(&credentials, query); err != nil {
+		return nil, errors.Wrapf(err, "failed to get WebAuthnCredentials for userId=%s", userId)
+	}
+
+	return credentials, nil
+}
+
+func (s *SqlMfaFactorStore) UpdateWebAuthnCredential(credential *model.WebAuthnCredential) error {
+	query := s.getQueryBuilder().
+		Update("WebAuthnCredentials").
+		Set("Credential", credential.Credential).
+		Set("LastUsedAt", credential.LastUsedAt).
+		Where(sq.Eq{"Id": credential.Id, "UserId": credential.UserId})
+
+	res, err := s.GetMaster().ExecBuilder(query)
+	if err != nil {
+		return errors.Wrapf(err, "failed to update WebAuthnCredential with id=%s", credential.Id)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get affected rows after updating WebAuthnCredential with id=%s", credential.Id)
+	}
+	if rowsAffected == 0 {
+		return store.NewErrNotFound("WebAuthnCredential", credential.Id)
+	}
+
+	return nil
+}
+
+func (s *SqlMfaFactorStore) DeleteWebAuthnCredential(userId, id string) error {
+	query := s.getQueryBuilder().
+		Delete("WebAuthnCredentials").
+		Where(sq.Eq{"Id": id, "UserId": userId})
+
+	res, err := s.GetMaster().ExecBuilder(query)
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete WebAuthnCredential with id=%s", id)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "failed to get affected rows after deleting WebAuthnCredential with id=%s", id)
+	}
+	if rowsAffected == 0 {
+		return store.NewErrNotFound("WebAuthnCredential", id)
+	}
+
+	return nil
+}
+
+func (s *SqlMfaFactorStore) SaveRecoveryCodes(userId string, codeHashes []string) (err error) {
+	tx, err := s.GetMaster().Beginx()
+	if err != nil {
+		return errors.Wrap(err, "begin_transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	if _, err = tx.ExecBuilder(s.getQueryBuilder().Delete("MfaRecoveryCodes").Where(sq.Eq{"UserId": userId})); err != nil {
+		return errors.Wrapf(err, "failed to delete MfaRecoveryCodes for userId=%s", userId)
+	}
+
+	if len(codeHashes) > 0 {
+		createAt := model.GetMillis()
+		insert := s.getQueryBuilder().
+			Insert("MfaRecoveryCodes").
+			Columns("UserId", "CodeHash", "CreateAt")
+		for _, codeHash := range codeHashes {
+			insert = insert.Values(userId, codeHash, createAt)
+		}
+
+		if _, err = tx.ExecBuilder(insert); err != nil {
+			return errors.Wrapf(err, "failed to save MfaRecoveryCodes for userId=%s", userId)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit_transaction")
+	}
+
+	return nil
+}
+
+func (s *SqlMfaFactorStore) UseRecoveryCode(userId, codeHash string) (bool, error) {
+	query := s.getQueryBuilder().
+		Delete("MfaRecoveryCodes").
+		Where(sq.Eq{"UserId": userId, "CodeHash": codeHash})
+
+	res, err := s.GetMaster().ExecBuilder(query)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to use MfaRecoveryCode for userId=%s", userId)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to get affected rows after using MfaRecoveryCode for userId=%s", userId)
+	}
+
+	return rowsAffected > 0, nil
+}
+
+func (s *SqlMfaFactorStore) CountRecoveryCodes(userId string) (int64, error) {
+	var count int64
+
+	query := s.getQueryBuilder().
+		Select("COUNT(*)").
+		From("MfaRecoveryCodes").
+		Where(sq.Eq{"UserId": userId})
+
+	if err := s.GetMaster().GetBuilder(&count, query); err != nil {
+		return 0, errors.Wrapf(err, "failed to count MfaRecoveryCodes for userId=%s", userId)
+	}
+
+	return count, nil
+}
+
+func (s *SqlMfaFactorStore) DeleteAllForUser(userId string) (err error) {
+	tx, err := s.GetMaster().Beginx()
+	if err != nil {
+		return errors.Wrap(err, "begin_transaction")
+	}
+	defer finalizeTransactionX(tx, &err)
+
+	if _, err = tx.ExecBuilder(s.getQueryBuilder().Delete("WebAuthnCredentials").Where(sq.Eq{"UserId": userId})); err != nil {
+		return errors.Wrapf(err, "failed to delete WebAuthnCredentials for userId=%s", userId)
+	}
+
+	if _, err = tx.ExecBuilder(s.getQueryBuilder().Delete("MfaRecoveryCodes").Where(sq.Eq{"UserId": userId})); err != nil {
+		return errors.Wrapf(err, "failed to delete MfaRecoveryCodes for userId=%s", userId)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit_transaction")
+	}
+
+	return nil
+}
@@ -2460,6 +2460,24 @@ func (s *SqlPostStore) GetPostsByIds(postIds []string) ([]*model.Post, error) {
 	return posts, nil
 }
 
+func (s *SqlPostStore) GetPostsForReadReceipts(postIds []string) ([]*model.Post, error) {
+	posts := []*model.Post{}
+	if len(postIds) == 0 {
+		return posts, nil
+	}
+
+	query := s.getQueryBuilder().
+		Select("Id", "ChannelId", "RootId", "UserId", "CreateAt").
+		From("Posts").
+		Where(sq.Eq{"Id": postIds, "DeleteAt": 0})
+
+	if err := s.GetReplica().SelectBuilder(&posts, query); err != nil {
+		return nil, errors.Wrap(err, "failed to get posts for read receipts")
+	}
+
+	return posts, nil
+}
+
 func (s *SqlPostStore) GetEditHistoryForPost(postId string) ([]*model.Post, error) {
 	builder := s.getQueryBuilder().
 		Select("*").
@@ -0,0 +1,93 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	"database/sql"
+
+	sq "github.com/mattermost/squirrel"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+type SqlPostTranslationStore struct {
+	*SqlStore
+}
+
+func newSqlPostTranslationStore(sqlStore *SqlStore) store.PostTranslationStore {
+	return &SqlPostTranslationStore{sqlStore}
+}
+
+func (s *SqlPostTranslationStore) Save(translation *model.PostTranslation) (*model.PostTranslation, error) {
+	translation.PreSave()
+	if err := translation.IsValid(); err != nil {
+		return nil, err
+	}
+
+	query := s.getQueryBuilder().
+		Insert("PostTranslations").
+		Columns("PostId", "Locale", "SourceLocale", "Message", "CreateAt").
+		Values(translation.PostId, translation.Locale, translation.SourceLocale, translation.Message, translation.CreateAt).
+		SuffixExpr(sq.Expr("ON CONFLICT (PostId, Locale) DO UPDATE SET SourceLocale = ?, Message = ?, CreateAt = ?", translation.SourceLocale, translation.Message, translation.CreateAt))
+
+	if _, err := s.GetMaster().ExecBuilder(query); err != nil {
+		return nil, errors.Wrapf(err, "failed to save PostTranslation with postId=%s, locale=%s", translation.PostId, translation.Locale)
+	}
+
+	return translation, nil
+}
+
+func (s *SqlPostTranslationStore) Get(postID, locale string) (*model.PostTranslation, error) {
+	query := s.getQueryBuilder().
+		Select("PostId", "Locale", "SourceLocale", "Message", "CreateAt").
+		From("PostTranslations").
+		Where(sq.Eq{"PostId": postID, "Locale": locale})
+
+	var translation model.PostTranslation
+	if err := s.GetReplica().GetBuilder(&translation, query); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, store.NewErrNotFound("PostTranslation", postID)
+		}
+		return nil, errors.Wrapf(err, "failed to get PostTranslation with postId=%s, locale=%s", postID, locale)
+	}
+
+	return &translation, nil
+}
+
+func (s *SqlPostTranslationStore) GetForPosts(postIDs []string, locale string) ([]*model.PostTranslation, error) {
+	translations := []*model.PostTranslation{}
+
+	perPage := 200
+	for i := 0; i < len(postIDs); i += perPage {
+		j := min(len(postIDs), i+perPage)
+
+		query := s.getQueryBuilder().
+			Select("PostId", "Locale", "SourceLocale", "Message", "CreateAt").
+			From("PostTranslations").
+			Where(sq.Eq{"PostId": postIDs[i:j], "Locale": locale})
+
+		var translationsBatch []*model.PostTranslation
+		if err := s.GetReplica().SelectBuilder(&translationsBatch, query); err != nil {
+			return nil, errors.Wrapf(err, "failed to get PostTranslations for post list with locale=%s", locale)
+		}
+
+		translations = append(translations, translationsBatch...)
+	}
+
+	return translations, nil
+}
+
+func (s *SqlPostTranslationStore) DeleteForPost(postID string) error {
+	query := s.getQueryBuilder().
+		Delete("PostTranslations").
+		Where(sq.Eq{"PostId": postID})
+
+	if _, err := s.GetMaster().ExecBuilder(query); err != nil {
+		return errors.Wrapf(err, "failed to delete PostTranslations with postId=%s", postID)
+	}
+
+	return nil
+}
@@ -136,6 +136,23 @@ func (s SqlPreferenceStore) GetCategoryAndName(category string, name string) (mo
 	return preferences, nil
 }
 
+func (s SqlPreferenceStore) GetCategoryAndNameForUsers(userIds []string, category string, name string) (model.Preferences, error) {
+	preferences := model.Preferences{}
+	if len(userIds) == 0 {
+		return preferences, nil
+	}
+
+	query := s.preferenceSelectQuery.
+		Where(sq.Eq{"UserId": userIds}).
+		Where(sq.Eq{"Category": category}).
+		Where(sq.Eq{"Name": name})
+
+	if err := s.GetReplica().SelectBuilder(&preferences, query); err != nil {
+		return nil, errors.Wrapf(err, "failed to find Preferences for users with category=%s, name=%s", category, name)
+	}
+	return preferences, nil
+}
+
 func (s SqlPreferenceStore) GetCategory(userId string, category string) (model.Preferences, error) {
 	var preferences model.Preferences
 	query := s.preferenceSelectQuery.
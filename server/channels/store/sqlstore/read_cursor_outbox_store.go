@@ -0,0 +1,110 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	sq "github.com/mattermost/squirrel"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+// maxReadCursorOutboxErrorLength matches the size of the last_error column
+const maxReadCursorOutboxErrorLength = 1024
+
+type SqlReadCursorOutboxStore struct {
+	*SqlStore
+}
+
+func newSqlReadCursorOutboxStore(sqlStore *SqlStore) store.ReadCursorOutboxStore {
+	return &SqlReadCursorOutboxStore{SqlStore: sqlStore}
+}
+
+func readCursorOutboxColumns() []string {
+	return []string{"id", "event_id", "channel_id", "payload", "create_at", "attempts", "next_attempt_at", "last_error"}
+}
+
+// GetDue retrieves up to limit entries whose next attempt is due at now, oldest first
+func (s *SqlReadCursorOutboxStore) GetDue(now int64, limit int) ([]*model.ReadCursorOutboxEntry, error) {
+	entries := []*model.ReadCursorOutboxEntry{}
+
+	query := s.getQueryBuilder().
+		Select(readCursorOutboxColumns()...).
+		From("read_cursor_outbox").
+		Where(sq.LtOrEq{"next_attempt_at": now}).
+		OrderBy("id").
+		Limit(uint64(limit))
+
+	if err := s.GetMaster().SelectBuilder(&entries, query); err != nil {
+		return nil, errors.Wrap(err, "failed to get due read cursor outbox entries")
+	}
+
+	return entries, nil
+}
+
+// Delete removes published entries
+func (s *SqlReadCursorOutboxStore) Delete(ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	query := s.getQueryBuilder().
+		Delete("read_cursor_outbox").
+		Where(sq.Eq{"id": ids})
+
+	if _, err := s.GetMaster().ExecBuilder(query); err != nil {
+		return errors.Wrap(err, "failed to delete read cursor outbox entries")
+	}
+
+	return nil
+}
+
+// DeleteByEventId removes the entry for an event that was published directly
+func (s *SqlReadCursorOutboxStore) DeleteByEventId(eventId string) error {
+	query := s.getQueryBuilder().
+		Delete("read_cursor_outbox").
+		Where(sq.Eq{"event_id": eventId})
+
+	if _, err := s.GetMaster().ExecBuilder(query); err != nil {
+		return errors.Wrapf(err, "failed to delete read cursor outbox entry for event_id=%s", eventId)
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed delivery attempt and schedules the next one
+func (s *SqlReadCursorOutboxStore) MarkFailed(id int64, nextAttemptAt int64, lastError string) error {
+	if len(lastError) > maxReadCursorOutboxErrorLength {
+		lastError = lastError[:maxReadCursorOutboxErrorLength]
+	}
+
+	query := s.getQueryBuilder().
+		Update("read_cursor_outbox").
+		Set("attempts", sq.Expr("attempts + 1")).
+		Set("next_attempt_at", nextAttemptAt).
+		Set("last_error", lastError).
+		Where(sq.Eq{"id": id})
+
+	if _, err := s.GetMaster().ExecBuilder(query); err != nil {
+		return errors.Wrapf(err, "failed to update read cursor outbox entry with id=%d", id)
+	}
+
+	return nil
+}
+
+// GetStats returns the number of pending entries and the creation time of the oldest one
+func (s *SqlReadCursorOutboxStore) GetStats() (*model.ReadCursorOutboxStats, error) {
+	var stats model.ReadCursorOutboxStats
+
+	query := s.getQueryBuilder().
+		Select("COUNT(*) AS pending", "COALESCE(MIN(create_at), 0) AS oldest_create_at").
+		From("read_cursor_outbox")
+
+	if err := s.GetMaster().GetBuilder(&stats, query); err != nil {
+		return nil, errors.Wrap(err, "failed to get read cursor outbox stats")
+	}
+
+	return &stats, nil
+}
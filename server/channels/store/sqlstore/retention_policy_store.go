@@ -915,6 +915,7 @@ func insertRetentionIdsForDeletion(txn *sqlxTxWrapper, row *model.RetentionIdsFo
 // `From` clause in `baseBuilder`.
 // `ChannelIDTable` is the table which contains the ChannelId column, it may be the
 // same as `table`, or will be different if a join was used.
+// `ChannelIDColumn` is the name of that column when it isn't ChannelId.
 // `NowMillis` must be a Unix timestamp in milliseconds and is used by the granular
 // policies; if `nowMillis - timestamp(record)` is greater than
 // the post duration of a granular policy, than the record will be deleted.
@@ -928,10 +929,14 @@ type RetentionPolicyBatchDeletionInfo struct {
 	TimeColumn          string
 	PrimaryKeys         []string
 	ChannelIDTable      string
+	ChannelIDColumn     string
 	NowMillis           int64
 	GlobalPolicyEndTime int64
 	Limit               int64
 	StoreDeletedIds     bool
+	// Delete, when set, deletes the rows selected by the builder in place of
+	// genericRetentionPoliciesDeletion and returns the number of deleted rows.
+	Delete func(builder sq.SelectBuilder) (int64, error)
 }
 
 // genericPermanentDeleteBatchForRetentionPolicies is a helper function for tables
@@ -941,7 +946,11 @@ func genericPermanentDeleteBatchForRetentionPolicies(
 	s *SqlStore,
 	cursor model.RetentionPolicyCursor,
 ) (int64, model.RetentionPolicyCursor, error) {
-	baseBuilder := r.BaseBuilder.InnerJoin("Channels ON " + r.ChannelIDTable + ".ChannelId = Channels.Id")
+	channelIDColumn := r.ChannelIDTable + ".ChannelId"
+	if r.ChannelIDColumn != "" {
+		channelIDColumn = r.ChannelIDTable + "." + r.ChannelIDColumn
+	}
+	baseBuilder := r.BaseBuilder.InnerJoin("Channels ON " + channelIDColumn + " = Channels.Id")
 
 	scopedTimeColumn := r.Table + "." + r.TimeColumn
 	nowStr := strconv.FormatInt(r.NowMillis, 10)
@@ -964,16 +973,23 @@ func genericPermanentDeleteBatchForRetentionPolicies(
 		cursor.TeamPoliciesDone = true
 	}
 
+	deleteRows := func(builder sq.SelectBuilder) (int64, error) {
+		if r.Delete != nil {
+			return r.Delete(builder)
+		}
+		return genericRetentionPoliciesDeletion(builder, r, s)
+	}
+
 	var totalRowsAffected int64
 
 	// First, delete all of the records which fall under the scope of a channel-specific policy
 	if !cursor.ChannelPoliciesDone {
 		channelPoliciesBuilder := baseBuilder.
-			InnerJoin("RetentionPoliciesChannels ON " + r.ChannelIDTable + ".ChannelId = RetentionPoliciesChannels.ChannelId").
+			InnerJoin("RetentionPoliciesChannels ON " + channelIDColumn + " = RetentionPoliciesChannels.ChannelId").
 			InnerJoin("RetentionPolicies ON RetentionPoliciesChannels.PolicyId = RetentionPolicies.Id").
 			Where(fallsUnderGranularPolicy).
 			Limit(uint64(r.Limit))
-		rowsAffected, err := genericRetentionPoliciesDeletion(channelPoliciesBuilder, r, s)
+		rowsAffected, err := deleteRows(channelPoliciesBuilder)
 		if err != nil {
 			return 0, cursor, err
 		}
@@ -988,7 +1004,7 @@ func genericPermanentDeleteBatchForRetentionPolicies(
 	if cursor.ChannelPoliciesDone && !cursor.TeamPoliciesDone {
 		// Channel-specific policies override team-specific policies.
 		teamPoliciesBuilder := baseBuilder.
-			LeftJoin("RetentionPoliciesChannels ON " + r.ChannelIDTable + ".ChannelId = RetentionPoliciesChannels.ChannelId").
+			LeftJoin("RetentionPoliciesChannels ON " + channelIDColumn + " = RetentionPoliciesChannels.ChannelId").
 			InnerJoin("RetentionPoliciesTeams ON Channels.TeamId = RetentionPoliciesTeams.TeamId").
 			InnerJoin("RetentionPolicies ON RetentionPoliciesTeams.PolicyId = RetentionPolicies.Id").
 			Where(sq.And{
@@ -997,7 +1013,7 @@ func genericPermanentDeleteBatchForRetentionPolicies(
 			}).
 			Where(fallsUnderGranularPolicy).
 			Limit(uint64(r.Limit))
-		rowsAffected, err := genericRetentionPoliciesDeletion(teamPoliciesBuilder, r, s)
+		rowsAffected, err := deleteRows(teamPoliciesBuilder)
 		if err != nil {
 			return 0, cursor, err
 		}
@@ -1012,7 +1028,7 @@ func genericPermanentDeleteBatchForRetentionPolicies(
 	if cursor.ChannelPoliciesDone && cursor.TeamPoliciesDone && !cursor.GlobalPoliciesDone {
 		// Granular policies override the global policy.
 		globalPolicyBuilder := baseBuilder.
-			LeftJoin("RetentionPoliciesChannels ON " + r.ChannelIDTable + ".ChannelId = RetentionPoliciesChannels.ChannelId").
+			LeftJoin("RetentionPoliciesChannels ON " + channelIDColumn + " = RetentionPoliciesChannels.ChannelId").
 			LeftJoin("RetentionPoliciesTeams ON Channels.TeamId = RetentionPoliciesTeams.TeamId").
 			LeftJoin("RetentionPolicies ON RetentionPoliciesChannels.PolicyId = RetentionPolicies.Id").
 			Where(sq.And{
@@ -1021,7 +1037,7 @@ func genericPermanentDeleteBatchForRetentionPolicies(
 			}).
 			Where(sq.Lt{scopedTimeColumn: r.GlobalPolicyEndTime}).
 			Limit(uint64(r.Limit))
-		rowsAffected, err := genericRetentionPoliciesDeletion(globalPolicyBuilder, r, s)
+		rowsAffected, err := deleteRows(globalPolicyBuilder)
 		if err != nil {
 			return 0, cursor, err
 		}
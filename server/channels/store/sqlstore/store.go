@@ -101,6 +101,7 @@ type SqlStoreStores struct {
 	notifyAdmin                store.NotifyAdminStore
 	postPriority               store.PostPriorityStore
 	postAcknowledgement        store.PostAcknowledgementStore
+	postTranslation            store.PostTranslationStore
 	postPersistentNotification store.PostPersistentNotificationStore
 	desktopTokens              store.DesktopTokensStore
 	channelBookmarks           store.ChannelBookmarkStore
@@ -112,6 +113,9 @@ type SqlStoreStores struct {
 	Attributes                 store.AttributesStore
 	ContentFlagging            store.ContentFlaggingStore
 	channelReadCursor          store.ChannelReadCursorStore
+	readCursorOutbox           store.ReadCursorOutboxStore
+	emailBatch                 store.EmailBatchStore
+	mfaFactor                  store.MfaFactorStore
 }
 
 type SqlStore struct {
@@ -252,6 +256,7 @@ func New(settings model.SqlSettings, logger mlog.LoggerIFace, metrics einterface
 	store.stores.notifyAdmin = newSqlNotifyAdminStore(store)
 	store.stores.postPriority = newSqlPostPriorityStore(store)
 	store.stores.postAcknowledgement = newSqlPostAcknowledgementStore(store)
+	store.stores.postTranslation = newSqlPostTranslationStore(store)
 	store.stores.postPersistentNotification = newSqlPostPersistentNotificationStore(store)
 	store.stores.desktopTokens = newSqlDesktopTokensStore(store, metrics)
 	store.stores.channelBookmarks = newSqlChannelBookmarkStore(store)
@@ -263,6 +268,9 @@ func New(settings model.SqlSettings, logger mlog.LoggerIFace, metrics einterface
 	store.stores.Attributes = newSqlAttributesStore(store, metrics)
 	store.stores.ContentFlagging = newContentFlaggingStore(store)
 	store.stores.channelReadCursor = newSqlChannelReadCursorStore(store)
+	store.stores.readCursorOutbox = newSqlReadCursorOutboxStore(store)
+	store.stores.emailBatch = newSqlEmailBatchStore(store)
+	store.stores.mfaFactor = newSqlMfaFactorStore(store)
 
 	store.stores.preference.(*SqlPreferenceStore).deleteUnusedFeatures()
 
@@ -846,6 +854,10 @@ func (ss *SqlStore) PostAcknowledgement() store.PostAcknowledgementStore {
 	return ss.stores.postAcknowledgement
 }
 
+func (ss *SqlStore) PostTranslation() store.PostTranslationStore {
+	return ss.stores.postTranslation
+}
+
 func (ss *SqlStore) PostPersistentNotification() store.PostPersistentNotificationStore {
 	return ss.stores.postPersistentNotification
 }
@@ -1067,3 +1079,15 @@ func (ss *SqlStore) ContentFlagging() store.ContentFlaggingStore {
 func (ss *SqlStore) ChannelReadCursor() store.ChannelReadCursorStore {
 	return ss.stores.channelReadCursor
 }
+
+func (ss *SqlStore) ReadCursorOutbox() store.ReadCursorOutboxStore {
+	return ss.stores.readCursorOutbox
+}
+
+func (ss *SqlStore) EmailBatch() store.EmailBatchStore {
+	return ss.stores.emailBatch
+}
+
+func (ss *SqlStore) MfaFactor() store.MfaFactorStore {
+	return ss.stores.mfaFactor
+}
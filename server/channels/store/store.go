@@ -86,6 +86,7 @@ type Store interface {
 	NotifyAdmin() NotifyAdminStore
 	PostPriority() PostPriorityStore
 	PostAcknowledgement() PostAcknowledgementStore
+	PostTranslation() PostTranslationStore
 	PostPersistentNotification() PostPersistentNotificationStore
 	DesktopTokens() DesktopTokensStore
 	ChannelBookmark() ChannelBookmarkStore
@@ -98,6 +99,9 @@ type Store interface {
 	GetSchemaDefinition() (*model.SupportPacketDatabaseSchema, error)
 	ContentFlagging() ContentFlaggingStore
 	ChannelReadCursor() ChannelReadCursorStore
+	ReadCursorOutbox() ReadCursorOutboxStore
+	EmailBatch() EmailBatchStore
+	MfaFactor() MfaFactorStore
 }
 
 type RetentionPolicyStore interface {
@@ -399,6 +403,9 @@ type PostStore interface {
 	Overwrite(rctx request.CTX, post *model.Post) (*model.Post, error)
 	OverwriteMultiple(rctx request.CTX, posts []*model.Post) ([]*model.Post, int, error)
 	GetPostsByIds(postIds []string) ([]*model.Post, error)
+	// GetPostsForReadReceipts returns the non-deleted posts among postIds with only Id, ChannelId,
+	// RootId, UserId and CreateAt populated, which is all read receipt lookups need.
+	GetPostsForReadReceipts(postIds []string) ([]*model.Post, error)
 	GetEditHistoryForPost(postID string) ([]*model.Post, error)
 	GetPostsBatchForIndexing(startTime int64, startPostID string, limit int) ([]*model.PostForIndexing, error)
 	PermanentDeleteBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error)
@@ -675,6 +682,7 @@ type PreferenceStore interface {
 	Save(preferences model.Preferences) error
 	GetCategory(userID string, category string) (model.Preferences, error)
 	GetCategoryAndName(category string, name string) (model.Preferences, error)
+	GetCategoryAndNameForUsers(userIDs []string, category string, name string) (model.Preferences, error)
 	Get(userID string, category string, name string) (*model.Preference, error)
 	GetAll(userID string) (model.Preferences, error)
 	Delete(userID, category, name string) error
@@ -750,6 +758,14 @@ type FileInfoStore interface {
 	PermanentDelete(rctx request.CTX, fileID string) error
 	PermanentDeleteBatch(rctx request.CTX, endTime int64, limit int64) (int64, error)
 	PermanentDeleteByUser(rctx request.CTX, userID string) (int64, error)
+	// PermanentDeleteByIds removes the file infos with the given ids.
+	PermanentDeleteByIds(rctx request.CTX, fileIDs []string) (int64, error)
+	// GetForPostIds returns the file infos attached to any of the posts, including the deleted ones.
+	GetForPostIds(postIDs []string) ([]*model.FileInfo, error)
+	// GetBatchForRetentionPolicies returns up to limit file infos past the retention policy of
+	// their post's channel, oldest first, along with the cursor for the next batch. The returned
+	// files must be deleted before asking for the next batch.
+	GetBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) ([]*model.FileInfo, model.RetentionPolicyCursor, error)
 	SetContent(rctx request.CTX, fileID, content string) error
 	Search(rctx request.CTX, paramsList []*model.SearchParams, userID, teamID string, page, perPage int) (*model.FileInfoList, error)
 	CountAll() (int64, error)
@@ -1072,6 +1088,21 @@ type PostAcknowledgementStore interface {
 	BatchDelete(acknowledgements []*model.PostAcknowledgement) error
 }
 
+// PostTranslationStore caches the translations of posts per locale
+type PostTranslationStore interface {
+	// Save inserts a translation, replacing the one of the post in the same locale
+	Save(translation *model.PostTranslation) (*model.PostTranslation, error)
+
+	// Get retrieves the translation of a post in a locale
+	Get(postID, locale string) (*model.PostTranslation, error)
+
+	// GetForPosts retrieves the translations in a locale of the given posts which have one
+	GetForPosts(postIDs []string, locale string) ([]*model.PostTranslation, error)
+
+	// DeleteForPost removes the translations of a post in every locale
+	DeleteForPost(postID string) error
+}
+
 type PostPersistentNotificationStore interface {
 	Get(params model.GetPersistentNotificationsPostsParams) ([]*model.PostPersistentNotifications, error)
 	GetSingle(postID string) (*model.PostPersistentNotifications, error)
@@ -1242,10 +1273,20 @@ type ThreadMembershipImportData struct {
 	UnreadMentions int64
 }
 
+// ReadCursorSeqQuery counts the users whose cursor is at or past Seq, excluding ExcludeUserId.
+// With a RootId, cursors in that thread count as well.
+type ReadCursorSeqQuery struct {
+	Seq           int64
+	RootId        string
+	ExcludeUserId string
+}
+
 // ChannelReadCursorStore provides methods to interact with channel_read_cursors table
 type ChannelReadCursorStore interface {
-	// Upsert inserts or updates a read cursor for a user in a channel
-	Upsert(cursor *model.ChannelReadCursor) error
+	// Upsert advances a read cursor for a user in a channel. When the cursor moves forward, the
+	// matching event is written to the read cursor outbox in the same transaction and returned.
+	// A nil event means the stored cursor was already at or past the given one.
+	Upsert(cursor *model.ChannelReadCursor) (*model.ReadCursorEvent, error)
 
 	// Get retrieves the read cursor for a specific user in a channel
 	Get(channelId, userId string) (*model.ChannelReadCursor, error)
@@ -1256,12 +1297,142 @@ type ChannelReadCursorStore interface {
 	// GetForUser retrieves all read cursors for a user across all channels
 	GetForUser(userId string) ([]*model.ChannelReadCursor, error)
 
+	// UpsertThread advances a user's read cursor in a thread. It behaves like Upsert, and the
+	// returned event carries the thread's root id.
+	UpsertThread(cursor *model.ThreadReadCursor) (*model.ReadCursorEvent, error)
+
+	// GetThread retrieves the read cursor for a specific user in a thread
+	GetThread(rootId, userId string) (*model.ThreadReadCursor, error)
+
+	// GetForChannelUsers retrieves the read cursors of the given users in a channel. With a
+	// rootId, a user's cursor is the further of their channel cursor and their cursor in that thread.
+	GetForChannelUsers(channelId, rootId string, userIds []string) ([]*model.ChannelReadCursor, error)
+
+	// GetForSeq retrieves up to limit cursors in a channel that are at or past seq, excluding excludeUserId.
+	// With a rootId, cursors in that thread count as well.
+	GetForSeq(channelId, rootId string, seq int64, excludeUserId string, limit int) ([]*model.ChannelReadCursor, error)
+
+	// CountForSeq counts the users in a channel whose cursor is at or past seq, excluding excludeUserId.
+	// With a rootId, cursors in that thread count as well.
+	CountForSeq(channelId, rootId string, seq int64, excludeUserId string) (int64, error)
+
+	// CountForSeqs counts, in a single query, the users CountForSeq would count for each of the
+	// queries, in order
+	CountForSeqs(channelId string, queries []ReadCursorSeqQuery) ([]int64, error)
+
 	// Delete removes a read cursor
 	Delete(channelId, userId string) error
 
 	// DeleteForChannel removes all read cursors for a channel
 	DeleteForChannel(channelId string) error
 
+	// GetNonReaders retrieves, ordered by username, the ids of the active, non-bot channel members
+	// other than excludeUserId who share their read state and whose cursor is before seq. With a
+	// rootId, cursors in that thread count as well. Members without a preference share their read
+	// state when sharesByDefault is set.
+	GetNonReaders(channelId, rootId string, seq int64, excludeUserId string, sharesByDefault bool, offset, limit int) ([]string, error)
+
+	// CountNonReaders counts the users GetNonReaders would return without pagination
+	CountNonReaders(channelId, rootId string, seq int64, excludeUserId string, sharesByDefault bool) (int64, error)
+
+	// GetDailyReadSummary summarizes, per UTC day, how many of the channel's current active, non-bot
+	// members have read the root posts created since the given timestamp
+	GetDailyReadSummary(channelId string, since int64) ([]*model.ReadReceiptsDailySummary, error)
+
+	// RemoveForUser deletes all channel and thread read cursors of a user and enqueues a removal
+	// event per affected channel in the read cursor outbox within the same transaction. The events
+	// are returned.
+	RemoveForUser(userId string) ([]*model.ReadCursorEvent, error)
+
+	// RemoveForUserInTeams deletes the channel and thread read cursors of a user in the channels of
+	// the given teams or, with exceptTeams, in every other channel, direct and group messages
+	// included. Like RemoveForUser, it enqueues and returns a removal event per affected channel.
+	RemoveForUserInTeams(userId string, teamIds []string, exceptTeams bool) ([]*model.ReadCursorEvent, error)
+
+	// GetNonSharingMemberIds retrieves the ids of the channel members who don't share their read
+	// state: those who turned the share_read_state preference off and, unless sharesByDefault,
+	// those who didn't turn it on.
+	GetNonSharingMemberIds(channelId string, sharesByDefault bool) ([]string, error)
+
+	// GetNonSharingReaderIds retrieves the ids of the users with a channel or thread read cursor in
+	// the channel who don't share their read state, as decided by GetNonSharingMemberIds.
+	GetNonSharingReaderIds(channelId string, sharesByDefault bool) ([]string, error)
+
+	// RemoveForChannel deletes all channel and thread read cursors in a channel and enqueues a
+	// single removal event for the channel. A nil event means the channel had no cursors.
+	RemoveForChannel(channelId string) (*model.ReadCursorEvent, error)
+
 	// DeleteOldCursors removes cursors older than the specified timestamp
 	DeleteOldCursors(olderThan int64) error
+
+	// PermanentDeleteBatchForRetentionPolicies removes the channel read cursors positioned before
+	// the retention cutoff of their channel and enqueues an expiry event for each of them
+	PermanentDeleteBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error)
+
+	// PermanentDeleteBatchThreadCursorsForRetentionPolicies removes the thread read cursors positioned
+	// before the retention cutoff of their channel and enqueues an expiry event for each of them
+	PermanentDeleteBatchThreadCursorsForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error)
+}
+
+// ReadCursorOutboxStore provides methods to drain the read_cursor_outbox table
+type ReadCursorOutboxStore interface {
+	// GetDue retrieves up to limit entries whose next attempt is due at now, oldest first
+	GetDue(now int64, limit int) ([]*model.ReadCursorOutboxEntry, error)
+
+	// Delete removes published entries
+	Delete(ids []int64) error
+
+	// DeleteByEventId removes the entry for an event that was published directly
+	DeleteByEventId(eventId string) error
+
+	// MarkFailed records a failed delivery attempt and schedules the next one
+	MarkFailed(id int64, nextAttemptAt int64, lastError string) error
+
+	// GetStats returns the number of pending entries and the creation time of the oldest one
+	GetStats() (*model.ReadCursorOutboxStats, error)
+}
+
+// EmailBatchStore persists the posts waiting to be notified in batched notification emails
+type EmailBatchStore interface {
+	// Save adds the entry unless the post is already pending for the user
+	Save(entry *model.EmailBatchEntry) error
+
+	// GetPendingUsers retrieves up to limit users with pending entries, ordered by id after afterUserId
+	GetPendingUsers(afterUserId string, limit int) ([]*model.EmailBatchUser, error)
+
+	// GetForUser retrieves the pending entries of the user, oldest first
+	GetForUser(userId string) ([]*model.EmailBatchEntry, error)
+
+	// Delete removes notified or discarded entries
+	Delete(ids []string) error
+
+	// DeleteForChannelMember removes the pending entries of a user in a channel they left
+	DeleteForChannelMember(userId, channelId string) error
+}
+
+// MfaFactorStore persists the WebAuthn credentials and recovery codes used as second factors
+type MfaFactorStore interface {
+	// SaveWebAuthnCredential adds a credential, failing once the user reached MaxWebAuthnCredentialsPerUser
+	SaveWebAuthnCredential(credential *model.WebAuthnCredential) (*model.WebAuthnCredential, error)
+
+	// GetWebAuthnCredentials retrieves the credentials of the user, oldest first
+	GetWebAuthnCredentials(userId string) ([]*model.WebAuthnCredential, error)
+
+	// UpdateWebAuthnCredential stores the credential record and last use time after a login
+	UpdateWebAuthnCredential(credential *model.WebAuthnCredential) error
+
+	// DeleteWebAuthnCredential revokes a credential of the user
+	DeleteWebAuthnCredential(userId, id string) error
+
+	// SaveRecoveryCodes replaces the recovery codes of the user with the given hashes
+	SaveRecoveryCodes(userId string, codeHashes []string) error
+
+	// UseRecoveryCode consumes a recovery code, returning false if it doesn't exist
+	UseRecoveryCode(userId, codeHash string) (bool, error)
+
+	// CountRecoveryCodes returns the number of unused recovery codes of the user
+	CountRecoveryCodes(userId string) (int64, error)
+
+	// DeleteAllForUser removes every credential and recovery code of the user
+	DeleteAllForUser(userId string) error
 }
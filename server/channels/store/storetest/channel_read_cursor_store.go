@@ -0,0 +1,552 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package storetest
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+func TestChannelReadCursorStore(t *testing.T, rctx request.CTX, ss store.Store, s SqlStore) {
+	t.Run("NonSharingUsers", func(t *testing.T) { testChannelReadCursorNonSharingUsers(t, rctx, ss) })
+	t.Run("RemoveForUserInTeams", func(t *testing.T) { testChannelReadCursorRemoveForUserInTeams(t, rctx, ss) })
+	t.Run("NonReadersAndDailySummary", func(t *testing.T) { testChannelReadCursorNonReadersAndDailySummary(t, rctx, ss) })
+	t.Run("ForSeq", func(t *testing.T) { testChannelReadCursorForSeq(t, rctx, ss) })
+	t.Run("ThreadCursors", func(t *testing.T) { testChannelReadCursorThreadCursors(t, rctx, ss) })
+	t.Run("RetentionPolicies", func(t *testing.T) { testChannelReadCursorRetentionPolicies(t, rctx, ss) })
+}
+
+func testChannelReadCursorNonSharingUsers(t *testing.T, rctx request.CTX, ss store.Store) {
+	channel, err := ss.Channel().Save(rctx, &model.Channel{TeamId: model.NewId(), Name: model.NewId(), DisplayName: "Town", Type: model.ChannelTypeOpen}, -1)
+	require.NoError(t, err)
+
+	newMember := func(share string) string {
+		t.Helper()
+		userId := model.NewId()
+		_, err := ss.Channel().SaveMember(rctx, &model.ChannelMember{ChannelId: channel.Id, UserId: userId, NotifyProps: model.GetDefaultChannelNotifyProps()})
+		require.NoError(t, err)
+		if share != "" {
+			require.NoError(t, ss.Preference().Save(model.Preferences{
+				{UserId: userId, Category: model.PreferenceCategoryReadReceipts, Name: model.PreferenceNameShareReadState, Value: share},
+			}))
+		}
+		return userId
+	}
+
+	sharing := newMember("true")
+	hiding := newMember("false")
+	unset := newMember("")
+	invalid := newMember("maybe")
+
+	// A former member who stopped sharing still has a cursor
+	formerId := model.NewId()
+	require.NoError(t, ss.Preference().Save(model.Preferences{
+		{UserId: formerId, Category: model.PreferenceCategoryReadReceipts, Name: model.PreferenceNameShareReadState, Value: "0"},
+	}))
+	for _, userId := range []string{sharing, hiding, unset, formerId} {
+		_, err = ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channel.Id, UserId: userId, LastPostSeq: 100})
+		require.NoError(t, err)
+	}
+	_, err = ss.ChannelReadCursor().UpsertThread(&model.ThreadReadCursor{RootId: model.NewId(), ChannelId: channel.Id, UserId: invalid, LastPostSeq: 100})
+	require.NoError(t, err)
+	drainReadCursorOutbox(t, ss)
+
+	t.Run("members who opted out when sharing by default", func(t *testing.T) {
+		userIds, err := ss.ChannelReadCursor().GetNonSharingMemberIds(channel.Id, true)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{hiding}, userIds)
+	})
+
+	t.Run("members who didn't opt in when hiding by default", func(t *testing.T) {
+		userIds, err := ss.ChannelReadCursor().GetNonSharingMemberIds(channel.Id, false)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{hiding, unset, invalid}, userIds)
+	})
+
+	t.Run("readers include thread cursors and former members", func(t *testing.T) {
+		userIds, err := ss.ChannelReadCursor().GetNonSharingReaderIds(channel.Id, true)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{hiding, formerId}, userIds)
+
+		userIds, err = ss.ChannelReadCursor().GetNonSharingReaderIds(channel.Id, false)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{hiding, unset, invalid, formerId}, userIds)
+	})
+}
+
+func testChannelReadCursorRemoveForUserInTeams(t *testing.T, rctx request.CTX, ss store.Store) {
+	teamId1 := model.NewId()
+	teamId2 := model.NewId()
+	userId := model.NewId()
+
+	var channelIds []string
+	for _, teamId := range []string{teamId1, teamId2, ""} {
+		channel, err := ss.Channel().Save(rctx, &model.Channel{TeamId: teamId, Name: model.NewId(), DisplayName: "Channel", Type: model.ChannelTypeOpen}, -1)
+		require.NoError(t, err)
+		channelIds = append(channelIds, channel.Id)
+	}
+
+	upsertAll := func() {
+		t.Helper()
+		for _, channelId := range channelIds {
+			_, err := ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channelId, UserId: userId, LastPostSeq: model.GetMillis()})
+			require.NoError(t, err)
+		}
+		drainReadCursorOutbox(t, ss)
+	}
+	remaining := func() []string {
+		t.Helper()
+		cursors, err := ss.ChannelReadCursor().GetForUser(userId)
+		require.NoError(t, err)
+		channelIds := make([]string, len(cursors))
+		for i, cursor := range cursors {
+			channelIds[i] = cursor.ChannelId
+		}
+		return channelIds
+	}
+
+	t.Run("only in the given teams", func(t *testing.T) {
+		upsertAll()
+		events, err := ss.ChannelReadCursor().RemoveForUserInTeams(userId, []string{teamId1}, false)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, channelIds[0], events[0].ChannelId)
+		assert.Equal(t, model.ReadCursorEventTypeRemoved, events[0].Type)
+		assert.ElementsMatch(t, channelIds[1:], remaining())
+	})
+
+	t.Run("outside of the given teams, including direct channels", func(t *testing.T) {
+		upsertAll()
+		events, err := ss.ChannelReadCursor().RemoveForUserInTeams(userId, []string{teamId1}, true)
+		require.NoError(t, err)
+		assert.Len(t, events, 2)
+		assert.ElementsMatch(t, channelIds[:1], remaining())
+	})
+
+	t.Run("no teams", func(t *testing.T) {
+		upsertAll()
+		events, err := ss.ChannelReadCursor().RemoveForUserInTeams(userId, nil, false)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+
+		events, err = ss.ChannelReadCursor().RemoveForUserInTeams(userId, nil, true)
+		require.NoError(t, err)
+		assert.Len(t, events, 3)
+		assert.Empty(t, remaining())
+	})
+
+	drainReadCursorOutbox(t, ss)
+}
+
+func testChannelReadCursorNonReadersAndDailySummary(t *testing.T, rctx request.CTX, ss store.Store) {
+	channel, err := ss.Channel().Save(rctx, &model.Channel{TeamId: model.NewId(), Name: model.NewId(), DisplayName: "Announcements", Type: model.ChannelTypeOpen}, -1)
+	require.NoError(t, err)
+
+	addMember := func(user *model.User) *model.User {
+		t.Helper()
+		_, err := ss.Channel().SaveMember(rctx, &model.ChannelMember{ChannelId: channel.Id, UserId: user.Id, NotifyProps: model.GetDefaultChannelNotifyProps()})
+		require.NoError(t, err)
+		return user
+	}
+	newMember := func(username string) *model.User {
+		t.Helper()
+		user, err := ss.User().Save(rctx, &model.User{Email: MakeEmail(), Username: username + model.NewId()[:8]})
+		require.NoError(t, err)
+		return addMember(user)
+	}
+
+	author := newMember("a")
+	reader := newMember("b")
+	threadReader := newMember("c")
+	nonReader1 := newMember("d")
+	nonReader2 := newMember("e")
+	deactivated := newMember("f")
+	deactivated.DeleteAt = model.GetMillis()
+	_, err = ss.User().Update(rctx, deactivated, true)
+	require.NoError(t, err)
+	_, bot := makeBotWithUser(t, rctx, ss, &model.Bot{Username: "bot" + model.NewId()[:8], OwnerId: author.Id})
+	addMember(bot)
+	hiding := newMember("g")
+	require.NoError(t, ss.Preference().Save(model.Preferences{
+		{UserId: hiding.Id, Category: model.PreferenceCategoryReadReceipts, Name: model.PreferenceNameShareReadState, Value: "false"},
+		{UserId: nonReader2.Id, Category: model.PreferenceCategoryReadReceipts, Name: model.PreferenceNameShareReadState, Value: "true"},
+	}))
+
+	post, err := ss.Post().Save(rctx, &model.Post{ChannelId: channel.Id, UserId: author.Id, Message: "announcement"})
+	require.NoError(t, err)
+
+	_, err = ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channel.Id, UserId: reader.Id, LastPostSeq: post.CreateAt})
+	require.NoError(t, err)
+	_, err = ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channel.Id, UserId: nonReader1.Id, LastPostSeq: post.CreateAt - 1})
+	require.NoError(t, err)
+	_, err = ss.ChannelReadCursor().UpsertThread(&model.ThreadReadCursor{RootId: post.Id, ChannelId: channel.Id, UserId: threadReader.Id, LastPostSeq: post.CreateAt})
+	require.NoError(t, err)
+	question, err := ss.Post().Save(rctx, &model.Post{ChannelId: channel.Id, UserId: reader.Id, Message: "question", CreateAt: post.CreateAt + 1})
+	require.NoError(t, err)
+	_, err = ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channel.Id, UserId: author.Id, LastPostSeq: question.CreateAt})
+	require.NoError(t, err)
+	drainReadCursorOutbox(t, ss)
+
+	t.Run("non readers exclude readers, the author, bots, deactivated users and users who don't share their read state", func(t *testing.T) {
+		userIds, err := ss.ChannelReadCursor().GetNonReaders(channel.Id, "", post.CreateAt, author.Id, true, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{threadReader.Id, nonReader1.Id, nonReader2.Id}, userIds)
+
+		count, err := ss.ChannelReadCursor().CountNonReaders(channel.Id, "", post.CreateAt, author.Id, true)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+
+	t.Run("thread cursors count for replies", func(t *testing.T) {
+		userIds, err := ss.ChannelReadCursor().GetNonReaders(channel.Id, post.Id, post.CreateAt, author.Id, true, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{nonReader1.Id, nonReader2.Id}, userIds)
+	})
+
+	t.Run("only users who opted in count when hiding by default", func(t *testing.T) {
+		userIds, err := ss.ChannelReadCursor().GetNonReaders(channel.Id, "", post.CreateAt, author.Id, false, 0, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{nonReader2.Id}, userIds)
+
+		count, err := ss.ChannelReadCursor().CountNonReaders(channel.Id, "", post.CreateAt, author.Id, false)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("non readers are paginated", func(t *testing.T) {
+		userIds, err := ss.ChannelReadCursor().GetNonReaders(channel.Id, "", post.CreateAt, author.Id, true, 1, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{nonReader1.Id}, userIds)
+	})
+
+	t.Run("daily summary", func(t *testing.T) {
+		summaries, err := ss.ChannelReadCursor().GetDailyReadSummary(channel.Id, post.CreateAt)
+		require.NoError(t, err)
+		require.Len(t, summaries, 1)
+		assert.Equal(t, time.UnixMilli(post.CreateAt).UTC().Format("2006-01-02"), summaries[0].Date)
+		// The reader read the announcement, and its author the question; authors' reads don't count
+		assert.Equal(t, int64(2), summaries[0].PostCount)
+		assert.Equal(t, int64(2), summaries[0].ReadCount)
+		assert.Equal(t, int64(10), summaries[0].ExpectedCount)
+
+		summaries, err = ss.ChannelReadCursor().GetDailyReadSummary(channel.Id, question.CreateAt)
+		require.NoError(t, err)
+		require.Len(t, summaries, 1)
+		assert.Equal(t, int64(1), summaries[0].PostCount)
+		assert.Equal(t, int64(1), summaries[0].ReadCount)
+		assert.Equal(t, int64(5), summaries[0].ExpectedCount)
+
+		summaries, err = ss.ChannelReadCursor().GetDailyReadSummary(channel.Id, question.CreateAt+1)
+		require.NoError(t, err)
+		assert.Empty(t, summaries)
+	})
+
+	_, err = ss.ChannelReadCursor().RemoveForChannel(channel.Id)
+	require.NoError(t, err)
+	drainReadCursorOutbox(t, ss)
+}
+
+func testChannelReadCursorForSeq(t *testing.T, rctx request.CTX, ss store.Store) {
+	channelId := model.NewId()
+	rootId := model.NewId()
+	authorId := model.NewId()
+	earlyId := model.NewId()
+	lateId := model.NewId()
+	threadReaderId := model.NewId()
+	otherChannelId := model.NewId()
+
+	for _, cursor := range []*model.ChannelReadCursor{
+		{ChannelId: channelId, UserId: authorId, LastPostSeq: 500, UpdatedAt: 1000},
+		{ChannelId: channelId, UserId: earlyId, LastPostSeq: 200, UpdatedAt: 2000},
+		{ChannelId: channelId, UserId: lateId, LastPostSeq: 400, UpdatedAt: 3000},
+		{ChannelId: channelId, UserId: threadReaderId, LastPostSeq: 100, UpdatedAt: 4000},
+		{ChannelId: otherChannelId, UserId: earlyId, LastPostSeq: 500},
+	} {
+		_, err := ss.ChannelReadCursor().Upsert(cursor)
+		require.NoError(t, err)
+	}
+	_, err := ss.ChannelReadCursor().UpsertThread(&model.ThreadReadCursor{RootId: rootId, ChannelId: channelId, UserId: threadReaderId, LastPostSeq: 300, UpdatedAt: 5000})
+	require.NoError(t, err)
+	_, err = ss.ChannelReadCursor().UpsertThread(&model.ThreadReadCursor{RootId: rootId, ChannelId: channelId, UserId: lateId, LastPostSeq: 300, UpdatedAt: 5000})
+	require.NoError(t, err)
+	defer func() {
+		for _, id := range []string{channelId, otherChannelId} {
+			_, err = ss.ChannelReadCursor().RemoveForChannel(id)
+			require.NoError(t, err)
+		}
+		drainReadCursorOutbox(t, ss)
+	}()
+
+	userIds := func(cursors []*model.ChannelReadCursor) []string {
+		ids := make([]string, len(cursors))
+		for i, cursor := range cursors {
+			ids[i] = cursor.UserId
+		}
+		return ids
+	}
+
+	t.Run("get the cursors at or past seq, most recently updated first", func(t *testing.T) {
+		cursors, err := ss.ChannelReadCursor().GetForSeq(channelId, "", 200, authorId, 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{lateId, earlyId}, userIds(cursors))
+
+		cursors, err = ss.ChannelReadCursor().GetForSeq(channelId, "", 200, "", 10)
+		require.NoError(t, err)
+		assert.Equal(t, []string{lateId, earlyId, authorId}, userIds(cursors))
+
+		cursors, err = ss.ChannelReadCursor().GetForSeq(channelId, "", 200, authorId, 1)
+		require.NoError(t, err)
+		assert.Equal(t, []string{lateId}, userIds(cursors))
+
+		cursors, err = ss.ChannelReadCursor().GetForSeq(channelId, "", 600, "", 10)
+		require.NoError(t, err)
+		assert.Empty(t, cursors)
+	})
+
+	t.Run("count the cursors at or past seq", func(t *testing.T) {
+		for _, tc := range []struct {
+			seq           int64
+			excludeUserId string
+			expected      int64
+		}{
+			{0, "", 4},
+			{200, authorId, 2},
+			{200, "", 3},
+			{401, "", 1},
+			{600, "", 0},
+		} {
+			count, err := ss.ChannelReadCursor().CountForSeq(channelId, "", tc.seq, tc.excludeUserId)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, count, "seq=%d exclude=%s", tc.seq, tc.excludeUserId)
+		}
+	})
+
+	t.Run("count several seqs at once", func(t *testing.T) {
+		queries := []store.ReadCursorSeqQuery{
+			{Seq: 200, ExcludeUserId: authorId},
+			{Seq: 600},
+			{Seq: 250, RootId: rootId, ExcludeUserId: authorId},
+			{Seq: 250, ExcludeUserId: authorId},
+			{Seq: 0, RootId: rootId},
+			{Seq: 250, RootId: model.NewId()},
+		}
+
+		counts, err := ss.ChannelReadCursor().CountForSeqs(channelId, queries)
+		require.NoError(t, err)
+		require.Len(t, counts, len(queries))
+		for i, query := range queries {
+			count, err := ss.ChannelReadCursor().CountForSeq(channelId, query.RootId, query.Seq, query.ExcludeUserId)
+			require.NoError(t, err)
+			assert.Equal(t, count, counts[i], "query %d", i)
+		}
+		assert.Equal(t, []int64{2, 0, 2, 1, 4, 2}, counts, "users with both cursors are counted once")
+
+		counts, err = ss.ChannelReadCursor().CountForSeqs(channelId, nil)
+		require.NoError(t, err)
+		assert.Empty(t, counts)
+	})
+}
+
+func testChannelReadCursorThreadCursors(t *testing.T, rctx request.CTX, ss store.Store) {
+	drainReadCursorOutbox(t, ss)
+
+	channelId := model.NewId()
+	rootId := model.NewId()
+	channelReaderId := model.NewId()
+	threadReaderId := model.NewId()
+	authorId := model.NewId()
+
+	_, err := ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channelId, UserId: channelReaderId, LastPostSeq: 500})
+	require.NoError(t, err)
+	_, err = ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channelId, UserId: threadReaderId, LastPostSeq: 100})
+	require.NoError(t, err)
+
+	event, err := ss.ChannelReadCursor().UpsertThread(&model.ThreadReadCursor{RootId: rootId, ChannelId: channelId, UserId: threadReaderId, LastPostSeq: 300})
+	require.NoError(t, err)
+	require.NotNil(t, event)
+	assert.Equal(t, rootId, event.RootId)
+	assert.Equal(t, int64(0), event.PrevLastSeq)
+	assert.Equal(t, int64(300), event.NewLastSeq)
+
+	_, err = ss.ChannelReadCursor().UpsertThread(&model.ThreadReadCursor{RootId: rootId, ChannelId: channelId, UserId: authorId, LastPostSeq: 300})
+	require.NoError(t, err)
+
+	t.Run("no event when the thread cursor does not advance", func(t *testing.T) {
+		event, err := ss.ChannelReadCursor().UpsertThread(&model.ThreadReadCursor{RootId: rootId, ChannelId: channelId, UserId: threadReaderId, LastPostSeq: 200})
+		require.NoError(t, err)
+		assert.Nil(t, event)
+
+		cursor, err := ss.ChannelReadCursor().GetThread(rootId, threadReaderId)
+		require.NoError(t, err)
+		assert.Equal(t, int64(300), cursor.LastPostSeq)
+	})
+
+	t.Run("thread events are enqueued with their root id", func(t *testing.T) {
+		entries, err := ss.ReadCursorOutbox().GetDue(model.GetMillis()+model.ReadCursorOutboxDeliveryDelay, 100)
+		require.NoError(t, err)
+		require.Len(t, entries, 4)
+
+		var payload model.ReadCursorEvent
+		require.NoError(t, json.Unmarshal([]byte(entries[2].Payload), &payload))
+		assert.Equal(t, *event, payload)
+	})
+
+	t.Run("replies count channel and thread cursors", func(t *testing.T) {
+		count, err := ss.ChannelReadCursor().CountForSeq(channelId, rootId, 250, authorId)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		cursors, err := ss.ChannelReadCursor().GetForSeq(channelId, rootId, 250, authorId, 10)
+		require.NoError(t, err)
+		require.Len(t, cursors, 2)
+		assert.ElementsMatch(t, []string{channelReaderId, threadReaderId}, []string{cursors[0].UserId, cursors[1].UserId})
+
+		count, err = ss.ChannelReadCursor().CountForSeq(channelId, rootId, 50, authorId)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), count, "users with both cursors must be counted once")
+	})
+
+	t.Run("thread cursors don't count for the channel", func(t *testing.T) {
+		count, err := ss.ChannelReadCursor().CountForSeq(channelId, "", 250, authorId)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		count, err = ss.ChannelReadCursor().CountForSeq(channelId, model.NewId(), 250, authorId)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("users' cursors are the further of both", func(t *testing.T) {
+		cursors, err := ss.ChannelReadCursor().GetForChannelUsers(channelId, rootId, []string{threadReaderId})
+		require.NoError(t, err)
+		require.Len(t, cursors, 1)
+		assert.Equal(t, int64(300), cursors[0].LastPostSeq)
+
+		cursors, err = ss.ChannelReadCursor().GetForChannelUsers(channelId, "", []string{threadReaderId})
+		require.NoError(t, err)
+		require.Len(t, cursors, 1)
+		assert.Equal(t, int64(100), cursors[0].LastPostSeq)
+	})
+
+	drainReadCursorOutbox(t, ss)
+
+	t.Run("removing a user's cursors removes their thread cursors", func(t *testing.T) {
+		events, err := ss.ChannelReadCursor().RemoveForUser(authorId)
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		assert.Equal(t, channelId, events[0].ChannelId)
+
+		_, err = ss.ChannelReadCursor().GetThread(rootId, authorId)
+		var nfErr *store.ErrNotFound
+		assert.ErrorAs(t, err, &nfErr)
+	})
+
+	t.Run("removing a channel's cursors removes its thread cursors", func(t *testing.T) {
+		event, err := ss.ChannelReadCursor().RemoveForChannel(channelId)
+		require.NoError(t, err)
+		require.NotNil(t, event)
+
+		count, err := ss.ChannelReadCursor().CountForSeq(channelId, rootId, 0, "")
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), count)
+	})
+
+	drainReadCursorOutbox(t, ss)
+}
+
+func testChannelReadCursorRetentionPolicies(t *testing.T, rctx request.CTX, ss store.Store) {
+	team, err := ss.Team().Save(&model.Team{
+		DisplayName: "DisplayName",
+		Name:        "team" + model.NewId(),
+		Email:       MakeEmail(),
+		Type:        model.TeamOpen,
+	})
+	require.NoError(t, err)
+	channel, err := ss.Channel().Save(rctx, &model.Channel{
+		TeamId:      team.Id,
+		DisplayName: "DisplayName",
+		Name:        "channel" + model.NewId(),
+		Type:        model.ChannelTypeOpen,
+	}, -1)
+	require.NoError(t, err)
+
+	// The old reader's position is past the retention period, while the idle reader hasn't read
+	// in a long time but is positioned on a post which is kept
+	now := model.GetMillis()
+	oldUserID := model.NewId()
+	idleUserID := model.NewId()
+	rootID := model.NewId()
+	for userID, lastPostSeq := range map[string]int64{oldUserID: 1000, idleUserID: now - model.DayInMilliseconds} {
+		_, err = ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channel.Id, UserId: userID, LastPostSeq: lastPostSeq, UpdatedAt: 1000})
+		require.NoError(t, err)
+		_, err = ss.ChannelReadCursor().UpsertThread(&model.ThreadReadCursor{RootId: rootID, ChannelId: channel.Id, UserId: userID, LastPostSeq: lastPostSeq, UpdatedAt: 1000})
+		require.NoError(t, err)
+	}
+	drainReadCursorOutbox(t, ss)
+
+	policy, err := ss.RetentionPolicy().Save(&model.RetentionPolicyWithTeamAndChannelIDs{
+		RetentionPolicy: model.RetentionPolicy{
+			DisplayName:      "DisplayName",
+			PostDurationDays: model.NewPointer(int64(30)),
+		},
+		ChannelIDs: []string{channel.Id},
+	})
+	require.NoError(t, err)
+	defer ss.RetentionPolicy().Delete(policy.ID)
+
+	configs := model.RetentionPolicyBatchConfigs{Now: now, Limit: 1000}
+	due := model.GetMillis() + model.ReadCursorOutboxDeliveryDelay
+	var nfErr *store.ErrNotFound
+
+	t.Run("channel cursors", func(t *testing.T) {
+		deleted, _, err := ss.ChannelReadCursor().PermanentDeleteBatchForRetentionPolicies(configs, model.RetentionPolicyCursor{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+		_, err = ss.ChannelReadCursor().Get(channel.Id, oldUserID)
+		assert.ErrorAs(t, err, &nfErr)
+		_, err = ss.ChannelReadCursor().Get(channel.Id, idleUserID)
+		assert.NoError(t, err)
+
+		entries, err := ss.ReadCursorOutbox().GetDue(due, 100)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		var event model.ReadCursorEvent
+		require.NoError(t, json.Unmarshal([]byte(entries[0].Payload), &event))
+		assert.Equal(t, model.ReadCursorEventTypeExpired, event.Type)
+		assert.Equal(t, oldUserID, event.UserId)
+		assert.Empty(t, event.RootId)
+		drainReadCursorOutbox(t, ss)
+	})
+
+	t.Run("thread cursors", func(t *testing.T) {
+		deleted, _, err := ss.ChannelReadCursor().PermanentDeleteBatchThreadCursorsForRetentionPolicies(configs, model.RetentionPolicyCursor{})
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted)
+		_, err = ss.ChannelReadCursor().GetThread(rootID, oldUserID)
+		assert.ErrorAs(t, err, &nfErr)
+		_, err = ss.ChannelReadCursor().GetThread(rootID, idleUserID)
+		assert.NoError(t, err)
+
+		entries, err := ss.ReadCursorOutbox().GetDue(due, 100)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		var event model.ReadCursorEvent
+		require.NoError(t, json.Unmarshal([]byte(entries[0].Payload), &event))
+		assert.Equal(t, model.ReadCursorEventTypeExpired, event.Type)
+		assert.Equal(t, rootID, event.RootId)
+		drainReadCursorOutbox(t, ss)
+	})
+
+	_, err = ss.ChannelReadCursor().RemoveForChannel(channel.Id)
+	require.NoError(t, err)
+	drainReadCursorOutbox(t, ss)
+}
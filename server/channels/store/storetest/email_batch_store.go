@@ -0,0 +1,116 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package storetest
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+func TestEmailBatchStore(t *testing.T, rctx request.CTX, ss store.Store, s SqlStore) {
+	t.Run("SaveAndGetForUser", func(t *testing.T) { testEmailBatchStoreSaveAndGetForUser(t, ss) })
+	t.Run("GetPendingUsers", func(t *testing.T) { testEmailBatchStoreGetPendingUsers(t, ss) })
+	t.Run("DeleteForChannelMember", func(t *testing.T) { testEmailBatchStoreDeleteForChannelMember(t, ss) })
+}
+
+func testEmailBatchStoreSaveAndGetForUser(t *testing.T, ss store.Store) {
+	userId := model.NewId()
+	channelId := model.NewId()
+
+	second := &model.EmailBatchEntry{UserId: userId, PostId: model.NewId(), ChannelId: channelId, TeamName: "team", CreateAt: 2000}
+	first := &model.EmailBatchEntry{UserId: userId, PostId: model.NewId(), ChannelId: channelId, TeamName: "team", CreateAt: 1000}
+	require.NoError(t, ss.EmailBatch().Save(second))
+	require.NoError(t, ss.EmailBatch().Save(first))
+	require.NoError(t, ss.EmailBatch().Save(&model.EmailBatchEntry{UserId: model.NewId(), PostId: first.PostId, ChannelId: channelId, TeamName: "team", CreateAt: 1000}))
+
+	t.Run("a post is queued once per user", func(t *testing.T) {
+		require.NoError(t, ss.EmailBatch().Save(&model.EmailBatchEntry{UserId: userId, PostId: first.PostId, ChannelId: channelId, TeamName: "team", CreateAt: 1000}))
+	})
+
+	entries, err := ss.EmailBatch().GetForUser(userId)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.EmailBatchEntry{first, second}, entries)
+
+	require.NoError(t, ss.EmailBatch().Delete([]string{first.Id}))
+	require.NoError(t, ss.EmailBatch().Delete(nil))
+
+	entries, err = ss.EmailBatch().GetForUser(userId)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.EmailBatchEntry{second}, entries)
+}
+
+func testEmailBatchStoreGetPendingUsers(t *testing.T, ss store.Store) {
+	userIds := []string{model.NewId(), model.NewId(), model.NewId()}
+	sort.Strings(userIds)
+
+	var entryIds []string
+	for i, userId := range userIds {
+		for j := range 2 {
+			entry := &model.EmailBatchEntry{UserId: userId, PostId: model.NewId(), ChannelId: model.NewId(), TeamName: "team", CreateAt: int64(1000*(i+1) + j)}
+			require.NoError(t, ss.EmailBatch().Save(entry))
+			entryIds = append(entryIds, entry.Id)
+		}
+	}
+	t.Cleanup(func() {
+		require.NoError(t, ss.EmailBatch().Delete(entryIds))
+	})
+
+	// Other tests may have left entries, so only the users of this test are checked
+	var pending []*model.EmailBatchUser
+	afterUserId := ""
+	for {
+		users, err := ss.EmailBatch().GetPendingUsers(afterUserId, 2)
+		require.NoError(t, err)
+		if len(users) == 0 {
+			break
+		}
+		require.LessOrEqual(t, len(users), 2)
+		for _, user := range users {
+			if user.UserId == userIds[0] || user.UserId == userIds[1] || user.UserId == userIds[2] {
+				pending = append(pending, user)
+			}
+		}
+		afterUserId = users[len(users)-1].UserId
+	}
+
+	assert.Equal(t, []*model.EmailBatchUser{
+		{UserId: userIds[0], OldestCreateAt: 1000},
+		{UserId: userIds[1], OldestCreateAt: 2000},
+		{UserId: userIds[2], OldestCreateAt: 3000},
+	}, pending)
+}
+
+func testEmailBatchStoreDeleteForChannelMember(t *testing.T, ss store.Store) {
+	userId := model.NewId()
+	otherUserId := model.NewId()
+	channelId := model.NewId()
+	otherChannelId := model.NewId()
+
+	left := &model.EmailBatchEntry{UserId: userId, PostId: model.NewId(), ChannelId: channelId, TeamName: "team", CreateAt: 1000}
+	kept := &model.EmailBatchEntry{UserId: userId, PostId: model.NewId(), ChannelId: otherChannelId, TeamName: "team", CreateAt: 2000}
+	otherUser := &model.EmailBatchEntry{UserId: otherUserId, PostId: left.PostId, ChannelId: channelId, TeamName: "team", CreateAt: 1000}
+	for _, entry := range []*model.EmailBatchEntry{left, kept, otherUser} {
+		require.NoError(t, ss.EmailBatch().Save(entry))
+	}
+	t.Cleanup(func() {
+		require.NoError(t, ss.EmailBatch().Delete([]string{left.Id, kept.Id, otherUser.Id}))
+	})
+
+	require.NoError(t, ss.EmailBatch().DeleteForChannelMember(userId, channelId))
+
+	entries, err := ss.EmailBatch().GetForUser(userId)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.EmailBatchEntry{kept}, entries)
+
+	entries, err = ss.EmailBatch().GetForUser(otherUserId)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.EmailBatchEntry{otherUser}, entries, "the entries of the other members are kept")
+}
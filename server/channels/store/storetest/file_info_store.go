@@ -32,6 +32,9 @@ func TestFileInfoStore(t *testing.T, rctx request.CTX, ss store.Store, s SqlStor
 	t.Run("FileInfoPermanentDelete", func(t *testing.T) { testFileInfoPermanentDelete(t, rctx, ss) })
 	t.Run("FileInfoPermanentDeleteBatch", func(t *testing.T) { testFileInfoPermanentDeleteBatch(t, rctx, ss) })
 	t.Run("FileInfoPermanentDeleteByUser", func(t *testing.T) { testFileInfoPermanentDeleteByUser(t, rctx, ss) })
+	t.Run("FileInfoPermanentDeleteByIds", func(t *testing.T) { testFileInfoPermanentDeleteByIds(t, rctx, ss) })
+	t.Run("FileInfoGetForPostIds", func(t *testing.T) { testFileInfoGetForPostIds(t, rctx, ss) })
+	t.Run("FileInfoGetBatchForRetentionPolicies", func(t *testing.T) { testFileInfoGetBatchForRetentionPolicies(t, rctx, ss) })
 	t.Run("FileInfoUpdateMinipreview", func(t *testing.T) { testFileInfoUpdateMinipreview(t, rctx, ss) })
 	t.Run("GetFilesBatchForIndexing", func(t *testing.T) { testFileInfoStoreGetFilesBatchForIndexing(t, rctx, ss) })
 	t.Run("CountAll", func(t *testing.T) { testFileInfoStoreCountAll(t, rctx, ss) })
@@ -658,6 +661,170 @@ func testFileInfoPermanentDeleteByUser(t *testing.T, rctx request.CTX, ss store.
 	require.NoError(t, err)
 }
 
+func testFileInfoPermanentDeleteByIds(t *testing.T, rctx request.CTX, ss store.Store) {
+	postID := model.NewId()
+
+	var ids []string
+	for range 3 {
+		info, err := ss.FileInfo().Save(rctx, &model.FileInfo{
+			PostId:    postID,
+			CreatorId: model.NewId(),
+			Path:      "file.txt",
+		})
+		require.NoError(t, err)
+		ids = append(ids, info.Id)
+	}
+
+	deleted, err := ss.FileInfo().PermanentDeleteByIds(rctx, ids[:2])
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+
+	postFiles, err := ss.FileInfo().GetForPost(postID, true, true, false)
+	require.NoError(t, err)
+	require.Len(t, postFiles, 1)
+	assert.Equal(t, ids[2], postFiles[0].Id)
+
+	deleted, err = ss.FileInfo().PermanentDeleteByIds(rctx, nil)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func testFileInfoGetForPostIds(t *testing.T, rctx request.CTX, ss store.Store) {
+	postIDs := []string{model.NewId(), model.NewId(), model.NewId()}
+
+	var expected []string
+	for i, postID := range postIDs {
+		info, err := ss.FileInfo().Save(rctx, &model.FileInfo{
+			PostId:    postID,
+			CreatorId: model.NewId(),
+			Path:      "file.txt",
+			CreateAt:  int64(1000 + i),
+		})
+		require.NoError(t, err)
+		defer ss.FileInfo().PermanentDelete(rctx, info.Id)
+		if i < 2 {
+			expected = append(expected, info.Id)
+		}
+	}
+
+	// Deleted files are returned as well
+	_, err := ss.FileInfo().DeleteForPost(rctx, postIDs[1])
+	require.NoError(t, err)
+
+	infos, err := ss.FileInfo().GetForPostIds(postIDs[:2])
+	require.NoError(t, err)
+	var ids []string
+	for _, info := range infos {
+		ids = append(ids, info.Id)
+	}
+	assert.Equal(t, expected, ids)
+
+	infos, err = ss.FileInfo().GetForPostIds(nil)
+	require.NoError(t, err)
+	assert.Empty(t, infos)
+}
+
+func testFileInfoGetBatchForRetentionPolicies(t *testing.T, rctx request.CTX, ss store.Store) {
+	const day = 24 * 60 * 60 * 1000
+	now := model.GetMillis()
+
+	team, err := ss.Team().Save(&model.Team{DisplayName: "DisplayName", Name: "team" + model.NewId(), Email: MakeEmail(), Type: model.TeamOpen})
+	require.NoError(t, err)
+	newChannel := func() *model.Channel {
+		t.Helper()
+		channel, err := ss.Channel().Save(rctx, &model.Channel{TeamId: team.Id, DisplayName: "DisplayName", Name: "channel" + model.NewId(), Type: model.ChannelTypeOpen}, -1)
+		require.NoError(t, err)
+		return channel
+	}
+	policyChannel := newChannel()
+	teamChannel := newChannel()
+
+	channelPolicy, err := ss.RetentionPolicy().Save(&model.RetentionPolicyWithTeamAndChannelIDs{
+		RetentionPolicy: model.RetentionPolicy{DisplayName: "DisplayName", PostDurationDays: model.NewPointer(int64(10))},
+		ChannelIDs:      []string{policyChannel.Id},
+	})
+	require.NoError(t, err)
+	defer ss.RetentionPolicy().Delete(channelPolicy.ID)
+	teamPolicy, err := ss.RetentionPolicy().Save(&model.RetentionPolicyWithTeamAndChannelIDs{
+		RetentionPolicy: model.RetentionPolicy{DisplayName: "DisplayName", PostDurationDays: model.NewPointer(int64(30))},
+		TeamIDs:         []string{team.Id},
+	})
+	require.NoError(t, err)
+	defer ss.RetentionPolicy().Delete(teamPolicy.ID)
+
+	newFile := func(channel *model.Channel, createAt int64, pinned bool) string {
+		t.Helper()
+		postId := ""
+		if channel != nil {
+			post, err := ss.Post().Save(rctx, &model.Post{ChannelId: channel.Id, UserId: model.NewId(), Message: "message", CreateAt: createAt, IsPinned: pinned})
+			require.NoError(t, err)
+			postId = post.Id
+		}
+		info, err := ss.FileInfo().Save(rctx, &model.FileInfo{PostId: postId, CreatorId: model.NewId(), Path: "file.txt", CreateAt: createAt})
+		require.NoError(t, err)
+		t.Cleanup(func() { ss.FileInfo().PermanentDelete(rctx, info.Id) })
+		return info.Id
+	}
+
+	channelExpired := newFile(policyChannel, now-20*day, false)
+	channelKept := newFile(policyChannel, now-5*day, false)
+	channelPinned := newFile(policyChannel, now-20*day, true)
+	teamExpired := newFile(teamChannel, now-40*day, false)
+	teamKept := newFile(teamChannel, now-20*day, false)
+	unattachedExpired := newFile(nil, 10, false)
+	bookmarkFile, err := ss.FileInfo().Save(rctx, &model.FileInfo{CreatorId: model.BookmarkFileOwner, Path: "file.txt", CreateAt: 10})
+	require.NoError(t, err)
+	defer ss.FileInfo().PermanentDelete(rctx, bookmarkFile.Id)
+
+	getAll := func(configs model.RetentionPolicyBatchConfigs) []string {
+		t.Helper()
+		var ids []string
+		cursor := model.RetentionPolicyCursor{}
+		for !(cursor.ChannelPoliciesDone && cursor.TeamPoliciesDone && cursor.GlobalPoliciesDone) {
+			var infos []*model.FileInfo
+			infos, cursor, err = ss.FileInfo().GetBatchForRetentionPolicies(configs, cursor)
+			require.NoError(t, err)
+			for _, info := range infos {
+				ids = append(ids, info.Id)
+			}
+		}
+		return ids
+	}
+
+	t.Run("granular policies", func(t *testing.T) {
+		ids := getAll(model.RetentionPolicyBatchConfigs{Now: now, Limit: 1000})
+		assert.Contains(t, ids, channelExpired)
+		assert.Contains(t, ids, channelPinned)
+		assert.Contains(t, ids, teamExpired)
+		assert.NotContains(t, ids, channelKept)
+		assert.NotContains(t, ids, teamKept)
+		assert.NotContains(t, ids, unattachedExpired)
+	})
+
+	t.Run("pinned posts are preserved", func(t *testing.T) {
+		ids := getAll(model.RetentionPolicyBatchConfigs{Now: now, Limit: 1000, PreservePinnedPosts: true})
+		assert.Contains(t, ids, channelExpired)
+		assert.NotContains(t, ids, channelPinned)
+	})
+
+	t.Run("the global policy leaves out the channels with a granular policy", func(t *testing.T) {
+		ids := getAll(model.RetentionPolicyBatchConfigs{GlobalPolicyEndTime: now, Limit: 1000})
+		assert.Contains(t, ids, unattachedExpired)
+		assert.NotContains(t, ids, channelExpired)
+		assert.NotContains(t, ids, teamExpired)
+		assert.NotContains(t, ids, bookmarkFile.Id)
+	})
+
+	t.Run("batches", func(t *testing.T) {
+		configs := model.RetentionPolicyBatchConfigs{Now: now, Limit: 1}
+		infos, cursor, err := ss.FileInfo().GetBatchForRetentionPolicies(configs, model.RetentionPolicyCursor{})
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		assert.False(t, cursor.ChannelPoliciesDone)
+		assert.True(t, cursor.GlobalPoliciesDone, "the global policy is skipped without an end time")
+	})
+}
+
 func testFileInfoUpdateMinipreview(t *testing.T, rctx request.CTX, ss store.Store) {
 	info := &model.FileInfo{
 		CreatorId: model.NewId(),
@@ -0,0 +1,173 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package storetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+func TestMfaFactorStore(t *testing.T, rctx request.CTX, ss store.Store, s SqlStore) {
+	t.Run("WebAuthnCredentials", func(t *testing.T) { testMfaFactorStoreWebAuthnCredentials(t, ss) })
+	t.Run("WebAuthnCredentialsLimit", func(t *testing.T) { testMfaFactorStoreWebAuthnCredentialsLimit(t, ss) })
+	t.Run("RecoveryCodes", func(t *testing.T) { testMfaFactorStoreRecoveryCodes(t, ss) })
+	t.Run("DeleteAllForUser", func(t *testing.T) { testMfaFactorStoreDeleteAllForUser(t, ss) })
+}
+
+func newTestWebAuthnCredential(userId string) *model.WebAuthnCredential {
+	return &model.WebAuthnCredential{
+		UserId:       userId,
+		Name:         "Security key",
+		CredentialId: model.NewId(),
+		Credential:   `{"id":"` + model.NewId() + `"}`,
+	}
+}
+
+func testMfaFactorStoreWebAuthnCredentials(t *testing.T, ss store.Store) {
+	userId := model.NewId()
+
+	first, err := ss.MfaFactor().SaveWebAuthnCredential(newTestWebAuthnCredential(userId))
+	require.NoError(t, err)
+	require.NotEmpty(t, first.Id)
+
+	second := newTestWebAuthnCredential(userId)
+	second.CreateAt = first.CreateAt + 1
+	_, err = ss.MfaFactor().SaveWebAuthnCredential(second)
+	require.NoError(t, err)
+
+	_, err = ss.MfaFactor().SaveWebAuthnCredential(newTestWebAuthnCredential(model.NewId()))
+	require.NoError(t, err)
+
+	t.Run("invalid credential", func(t *testing.T) {
+		invalid := newTestWebAuthnCredential(userId)
+		invalid.Name = ""
+		_, err := ss.MfaFactor().SaveWebAuthnCredential(invalid)
+		require.Error(t, err)
+	})
+
+	t.Run("duplicated credential id", func(t *testing.T) {
+		duplicated := newTestWebAuthnCredential(model.NewId())
+		duplicated.CredentialId = first.CredentialId
+		_, err := ss.MfaFactor().SaveWebAuthnCredential(duplicated)
+		var cErr *store.ErrConflict
+		require.ErrorAs(t, err, &cErr)
+	})
+
+	credentials, err := ss.MfaFactor().GetWebAuthnCredentials(userId)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.WebAuthnCredential{first, second}, credentials)
+
+	first.Credential = `{"updated":true}`
+	first.LastUsedAt = model.GetMillis()
+	require.NoError(t, ss.MfaFactor().UpdateWebAuthnCredential(first))
+
+	t.Run("update another user's credential", func(t *testing.T) {
+		other := *second
+		other.UserId = model.NewId()
+		var nfErr *store.ErrNotFound
+		require.ErrorAs(t, ss.MfaFactor().UpdateWebAuthnCredential(&other), &nfErr)
+	})
+
+	t.Run("delete another user's credential", func(t *testing.T) {
+		var nfErr *store.ErrNotFound
+		require.ErrorAs(t, ss.MfaFactor().DeleteWebAuthnCredential(model.NewId(), second.Id), &nfErr)
+	})
+
+	require.NoError(t, ss.MfaFactor().DeleteWebAuthnCredential(userId, second.Id))
+
+	credentials, err = ss.MfaFactor().GetWebAuthnCredentials(userId)
+	require.NoError(t, err)
+	assert.Equal(t, []*model.WebAuthnCredential{first}, credentials)
+}
+
+func testMfaFactorStoreWebAuthnCredentialsLimit(t *testing.T, ss store.Store) {
+	userId := model.NewId()
+
+	for range model.MaxWebAuthnCredentialsPerUser {
+		_, err := ss.MfaFactor().SaveWebAuthnCredential(newTestWebAuthnCredential(userId))
+		require.NoError(t, err)
+	}
+
+	_, err := ss.MfaFactor().SaveWebAuthnCredential(newTestWebAuthnCredential(userId))
+	var leErr *store.ErrLimitExceeded
+	require.ErrorAs(t, err, &leErr)
+}
+
+func testMfaFactorStoreRecoveryCodes(t *testing.T, ss store.Store) {
+	userId := model.NewId()
+
+	require.NoError(t, ss.MfaFactor().SaveRecoveryCodes(userId, []string{"hash1", "hash2"}))
+	require.NoError(t, ss.MfaFactor().SaveRecoveryCodes(model.NewId(), []string{"hash3"}))
+
+	count, err := ss.MfaFactor().CountRecoveryCodes(userId)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	t.Run("another user's code", func(t *testing.T) {
+		used, err := ss.MfaFactor().UseRecoveryCode(userId, "hash3")
+		require.NoError(t, err)
+		assert.False(t, used)
+	})
+
+	used, err := ss.MfaFactor().UseRecoveryCode(userId, "hash1")
+	require.NoError(t, err)
+	assert.True(t, used)
+
+	t.Run("a code is used once", func(t *testing.T) {
+		used, err := ss.MfaFactor().UseRecoveryCode(userId, "hash1")
+		require.NoError(t, err)
+		assert.False(t, used)
+	})
+
+	count, err = ss.MfaFactor().CountRecoveryCodes(userId)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	t.Run("new codes replace the previous ones", func(t *testing.T) {
+		require.NoError(t, ss.MfaFactor().SaveRecoveryCodes(userId, []string{"hash4", "hash5", "hash6"}))
+
+		used, err := ss.MfaFactor().UseRecoveryCode(userId, "hash2")
+		require.NoError(t, err)
+		assert.False(t, used)
+
+		count, err := ss.MfaFactor().CountRecoveryCodes(userId)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+}
+
+func testMfaFactorStoreDeleteAllForUser(t *testing.T, ss store.Store) {
+	userId := model.NewId()
+	otherUserId := model.NewId()
+
+	for _, id := range []string{userId, otherUserId} {
+		_, err := ss.MfaFactor().SaveWebAuthnCredential(newTestWebAuthnCredential(id))
+		require.NoError(t, err)
+		require.NoError(t, ss.MfaFactor().SaveRecoveryCodes(id, []string{"hash"}))
+	}
+
+	require.NoError(t, ss.MfaFactor().DeleteAllForUser(userId))
+
+	credentials, err := ss.MfaFactor().GetWebAuthnCredentials(userId)
+	require.NoError(t, err)
+	assert.Empty(t, credentials)
+
+	count, err := ss.MfaFactor().CountRecoveryCodes(userId)
+	require.NoError(t, err)
+	assert.Zero(t, count)
+
+	credentials, err = ss.MfaFactor().GetWebAuthnCredentials(otherUserId)
+	require.NoError(t, err)
+	assert.Len(t, credentials, 1)
+
+	count, err = ss.MfaFactor().CountRecoveryCodes(otherUserId)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+}
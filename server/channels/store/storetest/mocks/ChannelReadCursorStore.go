@@ -7,6 +7,8 @@ package mocks
 import (
 	model "github.com/mattermost/mattermost/server/public/model"
 	mock "github.com/stretchr/testify/mock"
+
+	store "github.com/mattermost/mattermost/server/v8/channels/store"
 )
 
 // ChannelReadCursorStore is an autogenerated mock type for the ChannelReadCursorStore type
@@ -14,6 +16,92 @@ type ChannelReadCursorStore struct {
 	mock.Mock
 }
 
+// CountForSeq provides a mock function with given fields: channelId, rootId, seq, excludeUserId
+func (_m *ChannelReadCursorStore) CountForSeq(channelId string, rootId string, seq int64, excludeUserId string) (int64, error) {
+	ret := _m.Called(channelId, rootId, seq, excludeUserId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountForSeq")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, int64, string) (int64, error)); ok {
+		return rf(channelId, rootId, seq, excludeUserId)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, int64, string) int64); ok {
+		r0 = rf(channelId, rootId, seq, excludeUserId)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, int64, string) error); ok {
+		r1 = rf(channelId, rootId, seq, excludeUserId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountForSeqs provides a mock function with given fields: channelId, queries
+func (_m *ChannelReadCursorStore) CountForSeqs(channelId string, queries []store.ReadCursorSeqQuery) ([]int64, error) {
+	ret := _m.Called(channelId, queries)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountForSeqs")
+	}
+
+	var r0 []int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, []store.ReadCursorSeqQuery) ([]int64, error)); ok {
+		return rf(channelId, queries)
+	}
+	if rf, ok := ret.Get(0).(func(string, []store.ReadCursorSeqQuery) []int64); ok {
+		r0 = rf(channelId, queries)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]int64)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, []store.ReadCursorSeqQuery) error); ok {
+		r1 = rf(channelId, queries)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CountNonReaders provides a mock function with given fields: channelId, rootId, seq, excludeUserId, sharesByDefault
+func (_m *ChannelReadCursorStore) CountNonReaders(channelId string, rootId string, seq int64, excludeUserId string, sharesByDefault bool) (int64, error) {
+	ret := _m.Called(channelId, rootId, seq, excludeUserId, sharesByDefault)
+
+	if len(ret) == 0 {
+		panic("no return value specified for CountNonReaders")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, int64, string, bool) (int64, error)); ok {
+		return rf(channelId, rootId, seq, excludeUserId, sharesByDefault)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, int64, string, bool) int64); ok {
+		r0 = rf(channelId, rootId, seq, excludeUserId, sharesByDefault)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, int64, string, bool) error); ok {
+		r1 = rf(channelId, rootId, seq, excludeUserId, sharesByDefault)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Delete provides a mock function with given fields: channelId, userId
 func (_m *ChannelReadCursorStore) Delete(channelId string, userId string) error {
 	ret := _m.Called(channelId, userId)
@@ -98,6 +186,36 @@ func (_m *ChannelReadCursorStore) Get(channelId string, userId string) (*model.C
 	return r0, r1
 }
 
+// GetDailyReadSummary provides a mock function with given fields: channelId, since
+func (_m *ChannelReadCursorStore) GetDailyReadSummary(channelId string, since int64) ([]*model.ReadReceiptsDailySummary, error) {
+	ret := _m.Called(channelId, since)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetDailyReadSummary")
+	}
+
+	var r0 []*model.ReadReceiptsDailySummary
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, int64) ([]*model.ReadReceiptsDailySummary, error)); ok {
+		return rf(channelId, since)
+	}
+	if rf, ok := ret.Get(0).(func(string, int64) []*model.ReadReceiptsDailySummary); ok {
+		r0 = rf(channelId, since)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.ReadReceiptsDailySummary)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, int64) error); ok {
+		r1 = rf(channelId, since)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetForChannel provides a mock function with given fields: channelId
 func (_m *ChannelReadCursorStore) GetForChannel(channelId string) ([]*model.ChannelReadCursor, error) {
 	ret := _m.Called(channelId)
@@ -128,6 +246,66 @@ func (_m *ChannelReadCursorStore) GetForChannel(channelId string) ([]*model.Chan
 	return r0, r1
 }
 
+// GetForChannelUsers provides a mock function with given fields: channelId, rootId, userIds
+func (_m *ChannelReadCursorStore) GetForChannelUsers(channelId string, rootId string, userIds []string) ([]*model.ChannelReadCursor, error) {
+	ret := _m.Called(channelId, rootId, userIds)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetForChannelUsers")
+	}
+
+	var r0 []*model.ChannelReadCursor
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, []string) ([]*model.ChannelReadCursor, error)); ok {
+		return rf(channelId, rootId, userIds)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, []string) []*model.ChannelReadCursor); ok {
+		r0 = rf(channelId, rootId, userIds)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.ChannelReadCursor)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, []string) error); ok {
+		r1 = rf(channelId, rootId, userIds)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetForSeq provides a mock function with given fields: channelId, rootId, seq, excludeUserId, limit
+func (_m *ChannelReadCursorStore) GetForSeq(channelId string, rootId string, seq int64, excludeUserId string, limit int) ([]*model.ChannelReadCursor, error) {
+	ret := _m.Called(channelId, rootId, seq, excludeUserId, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetForSeq")
+	}
+
+	var r0 []*model.ChannelReadCursor
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, int64, string, int) ([]*model.ChannelReadCursor, error)); ok {
+		return rf(channelId, rootId, seq, excludeUserId, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, int64, string, int) []*model.ChannelReadCursor); ok {
+		r0 = rf(channelId, rootId, seq, excludeUserId, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.ChannelReadCursor)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, int64, string, int) error); ok {
+		r1 = rf(channelId, rootId, seq, excludeUserId, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetForUser provides a mock function with given fields: userId
 func (_m *ChannelReadCursorStore) GetForUser(userId string) ([]*model.ChannelReadCursor, error) {
 	ret := _m.Called(userId)
@@ -158,22 +336,344 @@ func (_m *ChannelReadCursorStore) GetForUser(userId string) ([]*model.ChannelRea
 	return r0, r1
 }
 
+// GetNonReaders provides a mock function with given fields: channelId, rootId, seq, excludeUserId, sharesByDefault, offset, limit
+func (_m *ChannelReadCursorStore) GetNonReaders(channelId string, rootId string, seq int64, excludeUserId string, sharesByDefault bool, offset int, limit int) ([]string, error) {
+	ret := _m.Called(channelId, rootId, seq, excludeUserId, sharesByDefault, offset, limit)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetNonReaders")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string, int64, string, bool, int, int) ([]string, error)); ok {
+		return rf(channelId, rootId, seq, excludeUserId, sharesByDefault, offset, limit)
+	}
+	if rf, ok := ret.Get(0).(func(string, string, int64, string, bool, int, int) []string); ok {
+		r0 = rf(channelId, rootId, seq, excludeUserId, sharesByDefault, offset, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string, int64, string, bool, int, int) error); ok {
+		r1 = rf(channelId, rootId, seq, excludeUserId, sharesByDefault, offset, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetNonSharingMemberIds provides a mock function with given fields: channelId, sharesByDefault
+func (_m *ChannelReadCursorStore) GetNonSharingMemberIds(channelId string, sharesByDefault bool) ([]string, error) {
+	ret := _m.Called(channelId, sharesByDefault)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetNonSharingMemberIds")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, bool) ([]string, error)); ok {
+		return rf(channelId, sharesByDefault)
+	}
+	if rf, ok := ret.Get(0).(func(string, bool) []string); ok {
+		r0 = rf(channelId, sharesByDefault)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, bool) error); ok {
+		r1 = rf(channelId, sharesByDefault)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetNonSharingReaderIds provides a mock function with given fields: channelId, sharesByDefault
+func (_m *ChannelReadCursorStore) GetNonSharingReaderIds(channelId string, sharesByDefault bool) ([]string, error) {
+	ret := _m.Called(channelId, sharesByDefault)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetNonSharingReaderIds")
+	}
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, bool) ([]string, error)); ok {
+		return rf(channelId, sharesByDefault)
+	}
+	if rf, ok := ret.Get(0).(func(string, bool) []string); ok {
+		r0 = rf(channelId, sharesByDefault)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, bool) error); ok {
+		r1 = rf(channelId, sharesByDefault)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetThread provides a mock function with given fields: rootId, userId
+func (_m *ChannelReadCursorStore) GetThread(rootId string, userId string) (*model.ThreadReadCursor, error) {
+	ret := _m.Called(rootId, userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetThread")
+	}
+
+	var r0 *model.ThreadReadCursor
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, string) (*model.ThreadReadCursor, error)); ok {
+		return rf(rootId, userId)
+	}
+	if rf, ok := ret.Get(0).(func(string, string) *model.ThreadReadCursor); ok {
+		r0 = rf(rootId, userId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ThreadReadCursor)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, string) error); ok {
+		r1 = rf(rootId, userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PermanentDeleteBatchForRetentionPolicies provides a mock function with given fields: retentionPolicyBatchConfigs, cursor
+func (_m *ChannelReadCursorStore) PermanentDeleteBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error) {
+	ret := _m.Called(retentionPolicyBatchConfigs, cursor)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PermanentDeleteBatchForRetentionPolicies")
+	}
+
+	var r0 int64
+	var r1 model.RetentionPolicyCursor
+	var r2 error
+	if rf, ok := ret.Get(0).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error)); ok {
+		return rf(retentionPolicyBatchConfigs, cursor)
+	}
+	if rf, ok := ret.Get(0).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) int64); ok {
+		r0 = rf(retentionPolicyBatchConfigs, cursor)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) model.RetentionPolicyCursor); ok {
+		r1 = rf(retentionPolicyBatchConfigs, cursor)
+	} else {
+		r1 = ret.Get(1).(model.RetentionPolicyCursor)
+	}
+
+	if rf, ok := ret.Get(2).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) error); ok {
+		r2 = rf(retentionPolicyBatchConfigs, cursor)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// PermanentDeleteBatchThreadCursorsForRetentionPolicies provides a mock function with given fields: retentionPolicyBatchConfigs, cursor
+func (_m *ChannelReadCursorStore) PermanentDeleteBatchThreadCursorsForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error) {
+	ret := _m.Called(retentionPolicyBatchConfigs, cursor)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PermanentDeleteBatchThreadCursorsForRetentionPolicies")
+	}
+
+	var r0 int64
+	var r1 model.RetentionPolicyCursor
+	var r2 error
+	if rf, ok := ret.Get(0).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error)); ok {
+		return rf(retentionPolicyBatchConfigs, cursor)
+	}
+	if rf, ok := ret.Get(0).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) int64); ok {
+		r0 = rf(retentionPolicyBatchConfigs, cursor)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) model.RetentionPolicyCursor); ok {
+		r1 = rf(retentionPolicyBatchConfigs, cursor)
+	} else {
+		r1 = ret.Get(1).(model.RetentionPolicyCursor)
+	}
+
+	if rf, ok := ret.Get(2).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) error); ok {
+		r2 = rf(retentionPolicyBatchConfigs, cursor)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// RemoveForChannel provides a mock function with given fields: channelId
+func (_m *ChannelReadCursorStore) RemoveForChannel(channelId string) (*model.ReadCursorEvent, error) {
+	ret := _m.Called(channelId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveForChannel")
+	}
+
+	var r0 *model.ReadCursorEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*model.ReadCursorEvent, error)); ok {
+		return rf(channelId)
+	}
+	if rf, ok := ret.Get(0).(func(string) *model.ReadCursorEvent); ok {
+		r0 = rf(channelId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ReadCursorEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(channelId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveForUser provides a mock function with given fields: userId
+func (_m *ChannelReadCursorStore) RemoveForUser(userId string) ([]*model.ReadCursorEvent, error) {
+	ret := _m.Called(userId)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveForUser")
+	}
+
+	var r0 []*model.ReadCursorEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) ([]*model.ReadCursorEvent, error)); ok {
+		return rf(userId)
+	}
+	if rf, ok := ret.Get(0).(func(string) []*model.ReadCursorEvent); ok {
+		r0 = rf(userId)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.ReadCursorEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(userId)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RemoveForUserInTeams provides a mock function with given fields: userId, teamIds, exceptTeams
+func (_m *ChannelReadCursorStore) RemoveForUserInTeams(userId string, teamIds []string, exceptTeams bool) ([]*model.ReadCursorEvent, error) {
+	ret := _m.Called(userId, teamIds, exceptTeams)
+
+	if len(ret) == 0 {
+		panic("no return value specified for RemoveForUserInTeams")
+	}
+
+	var r0 []*model.ReadCursorEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string, []string, bool) ([]*model.ReadCursorEvent, error)); ok {
+		return rf(userId, teamIds, exceptTeams)
+	}
+	if rf, ok := ret.Get(0).(func(string, []string, bool) []*model.ReadCursorEvent); ok {
+		r0 = rf(userId, teamIds, exceptTeams)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.ReadCursorEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string, []string, bool) error); ok {
+		r1 = rf(userId, teamIds, exceptTeams)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Upsert provides a mock function with given fields: cursor
-func (_m *ChannelReadCursorStore) Upsert(cursor *model.ChannelReadCursor) error {
+func (_m *ChannelReadCursorStore) Upsert(cursor *model.ChannelReadCursor) (*model.ReadCursorEvent, error) {
 	ret := _m.Called(cursor)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Upsert")
 	}
 
-	var r0 error
-	if rf, ok := ret.Get(0).(func(*model.ChannelReadCursor) error); ok {
+	var r0 *model.ReadCursorEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.ChannelReadCursor) (*model.ReadCursorEvent, error)); ok {
+		return rf(cursor)
+	}
+	if rf, ok := ret.Get(0).(func(*model.ChannelReadCursor) *model.ReadCursorEvent); ok {
 		r0 = rf(cursor)
 	} else {
-		r0 = ret.Error(0)
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ReadCursorEvent)
+		}
 	}
 
-	return r0
+	if rf, ok := ret.Get(1).(func(*model.ChannelReadCursor) error); ok {
+		r1 = rf(cursor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpsertThread provides a mock function with given fields: cursor
+func (_m *ChannelReadCursorStore) UpsertThread(cursor *model.ThreadReadCursor) (*model.ReadCursorEvent, error) {
+	ret := _m.Called(cursor)
+
+	if len(ret) == 0 {
+		panic("no return value specified for UpsertThread")
+	}
+
+	var r0 *model.ReadCursorEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(*model.ThreadReadCursor) (*model.ReadCursorEvent, error)); ok {
+		return rf(cursor)
+	}
+	if rf, ok := ret.Get(0).(func(*model.ThreadReadCursor) *model.ReadCursorEvent); ok {
+		r0 = rf(cursor)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.ReadCursorEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(*model.ThreadReadCursor) error); ok {
+		r1 = rf(cursor)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // NewChannelReadCursorStore creates a new instance of ChannelReadCursorStore. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
@@ -142,6 +142,43 @@ func (_m *FileInfoStore) Get(id string) (*model.FileInfo, error) {
 	return r0, r1
 }
 
+// GetBatchForRetentionPolicies provides a mock function with given fields: retentionPolicyBatchConfigs, cursor
+func (_m *FileInfoStore) GetBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) ([]*model.FileInfo, model.RetentionPolicyCursor, error) {
+	ret := _m.Called(retentionPolicyBatchConfigs, cursor)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetBatchForRetentionPolicies")
+	}
+
+	var r0 []*model.FileInfo
+	var r1 model.RetentionPolicyCursor
+	var r2 error
+	if rf, ok := ret.Get(0).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) ([]*model.FileInfo, model.RetentionPolicyCursor, error)); ok {
+		return rf(retentionPolicyBatchConfigs, cursor)
+	}
+	if rf, ok := ret.Get(0).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) []*model.FileInfo); ok {
+		r0 = rf(retentionPolicyBatchConfigs, cursor)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.FileInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) model.RetentionPolicyCursor); ok {
+		r1 = rf(retentionPolicyBatchConfigs, cursor)
+	} else {
+		r1 = ret.Get(1).(model.RetentionPolicyCursor)
+	}
+
+	if rf, ok := ret.Get(2).(func(model.RetentionPolicyBatchConfigs, model.RetentionPolicyCursor) error); ok {
+		r2 = rf(retentionPolicyBatchConfigs, cursor)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 // GetByIds provides a mock function with given fields: ids, includeDeleted, allowFromCache
 func (_m *FileInfoStore) GetByIds(ids []string, includeDeleted bool, allowFromCache bool) ([]*model.FileInfo, error) {
 	ret := _m.Called(ids, includeDeleted, allowFromCache)
@@ -262,6 +299,36 @@ func (_m *FileInfoStore) GetForPost(postID string, readFromMaster bool, includeD
 	return r0, r1
 }
 
+// GetForPostIds provides a mock function with given fields: postIDs
+func (_m *FileInfoStore) GetForPostIds(postIDs []string) ([]*model.FileInfo, error) {
+	ret := _m.Called(postIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetForPostIds")
+	}
+
+	var r0 []*model.FileInfo
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]string) ([]*model.FileInfo, error)); ok {
+		return rf(postIDs)
+	}
+	if rf, ok := ret.Get(0).(func([]string) []*model.FileInfo); ok {
+		r0 = rf(postIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.FileInfo)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]string) error); ok {
+		r1 = rf(postIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetForUser provides a mock function with given fields: userID
 func (_m *FileInfoStore) GetForUser(userID string) ([]*model.FileInfo, error) {
 	ret := _m.Called(userID)
@@ -459,6 +526,34 @@ func (_m *FileInfoStore) PermanentDeleteBatch(rctx request.CTX, endTime int64, l
 	return r0, r1
 }
 
+// PermanentDeleteByIds provides a mock function with given fields: rctx, fileIDs
+func (_m *FileInfoStore) PermanentDeleteByIds(rctx request.CTX, fileIDs []string) (int64, error) {
+	ret := _m.Called(rctx, fileIDs)
+
+	if len(ret) == 0 {
+		panic("no return value specified for PermanentDeleteByIds")
+	}
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(request.CTX, []string) (int64, error)); ok {
+		return rf(rctx, fileIDs)
+	}
+	if rf, ok := ret.Get(0).(func(request.CTX, []string) int64); ok {
+		r0 = rf(rctx, fileIDs)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(request.CTX, []string) error); ok {
+		r1 = rf(rctx, fileIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // PermanentDeleteByUser provides a mock function with given fields: rctx, userID
 func (_m *FileInfoStore) PermanentDeleteByUser(rctx request.CTX, userID string) (int64, error) {
 	ret := _m.Called(rctx, userID)
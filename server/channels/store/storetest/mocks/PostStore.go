@@ -862,6 +862,36 @@ func (_m *PostStore) GetPostsCreatedAt(channelID string, timestamp int64) ([]*mo
 	return r0, r1
 }
 
+// GetPostsForReadReceipts provides a mock function with given fields: postIds
+func (_m *PostStore) GetPostsForReadReceipts(postIds []string) ([]*model.Post, error) {
+	ret := _m.Called(postIds)
+
+	if len(ret) == 0 {
+		panic("no return value specified for GetPostsForReadReceipts")
+	}
+
+	var r0 []*model.Post
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]string) ([]*model.Post, error)); ok {
+		return rf(postIds)
+	}
+	if rf, ok := ret.Get(0).(func([]string) []*model.Post); ok {
+		r0 = rf(postIds)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*model.Post)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]string) error); ok {
+		r1 = rf(postIds)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetPostsSince provides a mock function with given fields: rctx, options, allowFromCache, sanitizeOptions
 func (_m *PostStore) GetPostsSince(rctx request.CTX, options model.GetPostsSinceOptions, allowFromCache bool, sanitizeOptions map[string]bool) (*model.PostList, error) {
 	ret := _m.Called(rctx, options, allowFromCache, sanitizeOptions)
@@ -352,6 +352,26 @@ func (_m *Store) DropAllTables() {
 	_m.Called()
 }
 
+// EmailBatch provides a mock function with no fields
+func (_m *Store) EmailBatch() store.EmailBatchStore {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for EmailBatch")
+	}
+
+	var r0 store.EmailBatchStore
+	if rf, ok := ret.Get(0).(func() store.EmailBatchStore); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(store.EmailBatchStore)
+		}
+	}
+
+	return r0
+}
+
 // Emoji provides a mock function with no fields
 func (_m *Store) Emoji() store.EmojiStore {
 	ret := _m.Called()
@@ -686,6 +706,26 @@ func (_m *Store) MarkSystemRanUnitTests() {
 	_m.Called()
 }
 
+// MfaFactor provides a mock function with no fields
+func (_m *Store) MfaFactor() store.MfaFactorStore {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for MfaFactor")
+	}
+
+	var r0 store.MfaFactorStore
+	if rf, ok := ret.Get(0).(func() store.MfaFactorStore); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(store.MfaFactorStore)
+		}
+	}
+
+	return r0
+}
+
 // NotifyAdmin provides a mock function with no fields
 func (_m *Store) NotifyAdmin() store.NotifyAdminStore {
 	ret := _m.Called()
@@ -846,6 +886,26 @@ func (_m *Store) PostPriority() store.PostPriorityStore {
 	return r0
 }
 
+// PostTranslation provides a mock function with no fields
+func (_m *Store) PostTranslation() store.PostTranslationStore {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for PostTranslation")
+	}
+
+	var r0 store.PostTranslationStore
+	if rf, ok := ret.Get(0).(func() store.PostTranslationStore); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(store.PostTranslationStore)
+		}
+	}
+
+	return r0
+}
+
 // Preference provides a mock function with no fields
 func (_m *Store) Preference() store.PreferenceStore {
 	ret := _m.Called()
@@ -966,6 +1026,26 @@ func (_m *Store) Reaction() store.ReactionStore {
 	return r0
 }
 
+// ReadCursorOutbox provides a mock function with no fields
+func (_m *Store) ReadCursorOutbox() store.ReadCursorOutboxStore {
+	ret := _m.Called()
+
+	if len(ret) == 0 {
+		panic("no return value specified for ReadCursorOutbox")
+	}
+
+	var r0 store.ReadCursorOutboxStore
+	if rf, ok := ret.Get(0).(func() store.ReadCursorOutboxStore); ok {
+		r0 = rf()
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(store.ReadCursorOutboxStore)
+		}
+	}
+
+	return r0
+}
+
 // RecycleDBConnections provides a mock function with given fields: d
 func (_m *Store) RecycleDBConnections(d time.Duration) {
 	_m.Called(d)
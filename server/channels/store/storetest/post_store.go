@@ -49,6 +49,7 @@ func TestPostStore(t *testing.T, rctx request.CTX, ss store.Store, s SqlStore) {
 	t.Run("Overwrite", func(t *testing.T) { testPostStoreOverwrite(t, rctx, ss) })
 	t.Run("OverwriteMultiple", func(t *testing.T) { testPostStoreOverwriteMultiple(t, rctx, ss) })
 	t.Run("GetPostsByIds", func(t *testing.T) { testPostStoreGetPostsByIds(t, rctx, ss) })
+	t.Run("GetPostsForReadReceipts", func(t *testing.T) { testPostStoreGetPostsForReadReceipts(t, rctx, ss) })
 	t.Run("GetPostsBatchForIndexing", func(t *testing.T) { testPostStoreGetPostsBatchForIndexing(t, rctx, ss) })
 	t.Run("PermanentDeleteBatch", func(t *testing.T) { testPostStorePermanentDeleteBatch(t, rctx, ss) })
 	t.Run("GetOldest", func(t *testing.T) { testPostStoreGetOldest(t, rctx, ss) })
@@ -4122,6 +4123,37 @@ func testPostStoreGetPostsByIds(t *testing.T, rctx request.CTX, ss store.Store)
 	require.Len(t, posts, 3, "Expected 3 posts in results. Got %v", len(posts))
 }
 
+func testPostStoreGetPostsForReadReceipts(t *testing.T, rctx request.CTX, ss store.Store) {
+	channelId := model.NewId()
+
+	root, err := ss.Post().Save(rctx, &model.Post{ChannelId: channelId, UserId: model.NewId(), Message: NewTestID()})
+	require.NoError(t, err)
+	reply, err := ss.Post().Save(rctx, &model.Post{ChannelId: channelId, UserId: model.NewId(), RootId: root.Id, Message: NewTestID()})
+	require.NoError(t, err)
+	deleted, err := ss.Post().Save(rctx, &model.Post{ChannelId: channelId, UserId: model.NewId(), Message: NewTestID()})
+	require.NoError(t, err)
+	require.NoError(t, ss.Post().Delete(rctx, deleted.Id, model.GetMillis(), deleted.UserId))
+
+	posts, err := ss.Post().GetPostsForReadReceipts([]string{root.Id, reply.Id, deleted.Id, model.NewId()})
+	require.NoError(t, err)
+	require.Len(t, posts, 2)
+
+	byId := map[string]*model.Post{}
+	for _, post := range posts {
+		byId[post.Id] = post
+	}
+	require.Contains(t, byId, reply.Id)
+	assert.Equal(t, channelId, byId[reply.Id].ChannelId)
+	assert.Equal(t, root.Id, byId[reply.Id].RootId)
+	assert.Equal(t, reply.UserId, byId[reply.Id].UserId)
+	assert.Equal(t, reply.CreateAt, byId[reply.Id].CreateAt)
+	assert.Empty(t, byId[reply.Id].Message)
+
+	posts, err = ss.Post().GetPostsForReadReceipts([]string{})
+	require.NoError(t, err)
+	assert.Empty(t, posts)
+}
+
 func testPostStoreGetPostsBatchForIndexing(t *testing.T, rctx request.CTX, ss store.Store) {
 	c1 := &model.Channel{}
 	c1.TeamId = model.NewId()
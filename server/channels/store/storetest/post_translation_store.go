@@ -0,0 +1,107 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package storetest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+func TestPostTranslationStore(t *testing.T, rctx request.CTX, ss store.Store, s SqlStore) {
+	t.Run("Save", func(t *testing.T) { testPostTranslationStoreSave(t, ss) })
+	t.Run("GetForPosts", func(t *testing.T) { testPostTranslationStoreGetForPosts(t, ss) })
+	t.Run("DeleteForPost", func(t *testing.T) { testPostTranslationStoreDeleteForPost(t, ss) })
+}
+
+func testPostTranslationStoreSave(t *testing.T, ss store.Store) {
+	postID := model.NewId()
+
+	_, err := ss.PostTranslation().Get(postID, "en")
+	var nfErr *store.ErrNotFound
+	require.ErrorAs(t, err, &nfErr)
+
+	saved, err := ss.PostTranslation().Save(&model.PostTranslation{PostId: postID, Locale: "en", SourceLocale: "zh-CN", Message: "hello"})
+	require.NoError(t, err)
+	assert.NotZero(t, saved.CreateAt)
+
+	translation, err := ss.PostTranslation().Get(postID, "en")
+	require.NoError(t, err)
+	assert.Equal(t, saved, translation)
+
+	t.Run("replaces the translation in the same locale", func(t *testing.T) {
+		_, err := ss.PostTranslation().Save(&model.PostTranslation{PostId: postID, Locale: "en", SourceLocale: "zh-CN", Message: "hello again", CreateAt: saved.CreateAt + 1})
+		require.NoError(t, err)
+
+		translation, err := ss.PostTranslation().Get(postID, "en")
+		require.NoError(t, err)
+		assert.Equal(t, "hello again", translation.Message)
+		assert.Equal(t, saved.CreateAt+1, translation.CreateAt)
+	})
+
+	t.Run("invalid translation", func(t *testing.T) {
+		_, err := ss.PostTranslation().Save(&model.PostTranslation{PostId: postID, SourceLocale: "zh-CN", Message: "hello"})
+		require.Error(t, err)
+	})
+}
+
+func testPostTranslationStoreGetForPosts(t *testing.T, ss store.Store) {
+	postID1 := model.NewId()
+	postID2 := model.NewId()
+	postID3 := model.NewId()
+
+	for _, translation := range []*model.PostTranslation{
+		{PostId: postID1, Locale: "en", SourceLocale: "zh-CN", Message: "hello"},
+		{PostId: postID1, Locale: "zh-CN", SourceLocale: "zh-CN", Message: "你好"},
+		{PostId: postID2, Locale: "en", SourceLocale: "en", Message: "good morning"},
+		{PostId: postID3, Locale: "zh-CN", SourceLocale: "en", Message: "再见"},
+	} {
+		_, err := ss.PostTranslation().Save(translation)
+		require.NoError(t, err)
+	}
+
+	translations, err := ss.PostTranslation().GetForPosts([]string{postID1, postID2, postID3}, "en")
+	require.NoError(t, err)
+	require.Len(t, translations, 2)
+	messages := map[string]string{}
+	for _, translation := range translations {
+		assert.Equal(t, "en", translation.Locale)
+		messages[translation.PostId] = translation.Message
+	}
+	assert.Equal(t, map[string]string{postID1: "hello", postID2: "good morning"}, messages)
+
+	translations, err = ss.PostTranslation().GetForPosts([]string{}, "en")
+	require.NoError(t, err)
+	assert.Empty(t, translations)
+}
+
+func testPostTranslationStoreDeleteForPost(t *testing.T, ss store.Store) {
+	postID := model.NewId()
+	otherPostID := model.NewId()
+
+	for _, translation := range []*model.PostTranslation{
+		{PostId: postID, Locale: "en", SourceLocale: "zh-CN", Message: "hello"},
+		{PostId: postID, Locale: "fr", SourceLocale: "zh-CN", Message: "bonjour"},
+		{PostId: otherPostID, Locale: "en", SourceLocale: "zh-CN", Message: "goodbye"},
+	} {
+		_, err := ss.PostTranslation().Save(translation)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, ss.PostTranslation().DeleteForPost(postID))
+
+	translations, err := ss.PostTranslation().GetForPosts([]string{postID, otherPostID}, "en")
+	require.NoError(t, err)
+	require.Len(t, translations, 1)
+	assert.Equal(t, otherPostID, translations[0].PostId)
+
+	_, err = ss.PostTranslation().Get(postID, "fr")
+	var nfErr *store.ErrNotFound
+	require.ErrorAs(t, err, &nfErr)
+}
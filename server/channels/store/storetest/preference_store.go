@@ -19,6 +19,7 @@ func TestPreferenceStore(t *testing.T, rctx request.CTX, ss store.Store, s SqlSt
 	t.Run("PreferenceGet", func(t *testing.T) { testPreferenceGet(t, rctx, ss) })
 	t.Run("PreferenceGetCategory", func(t *testing.T) { testPreferenceGetCategory(t, rctx, ss) })
 	t.Run("PreferenceGetCategoryAndName", func(t *testing.T) { testPreferenceGetCategoryAndName(t, rctx, ss) })
+	t.Run("PreferenceGetCategoryAndNameForUsers", func(t *testing.T) { testPreferenceGetCategoryAndNameForUsers(t, rctx, ss) })
 	t.Run("PreferenceGetAll", func(t *testing.T) { testPreferenceGetAll(t, rctx, ss) })
 	t.Run("PreferenceDeleteByUser", func(t *testing.T) { testPreferenceDeleteByUser(t, rctx, ss) })
 	t.Run("PreferenceDelete", func(t *testing.T) { testPreferenceDelete(t, rctx, ss) })
@@ -169,6 +170,31 @@ func testPreferenceGetCategoryAndName(t *testing.T, _ request.CTX, ss store.Stor
 	require.Equal(t, 0, len(actualPreferences), "shouldn't have got any preferences")
 }
 
+func testPreferenceGetCategoryAndNameForUsers(t *testing.T, _ request.CTX, ss store.Store) {
+	userId1 := model.NewId()
+	userId2 := model.NewId()
+	otherUserId := model.NewId()
+	category := model.PreferenceCategoryReadReceipts
+	name := model.PreferenceNameShareReadState
+
+	preferences := model.Preferences{
+		{UserId: userId1, Category: category, Name: name, Value: "false"},
+		{UserId: userId2, Category: category, Name: name, Value: "true"},
+		{UserId: otherUserId, Category: category, Name: name, Value: "false"},
+		// same user/category, different name
+		{UserId: userId1, Category: category, Name: model.NewId(), Value: "true"},
+	}
+	require.NoError(t, ss.Preference().Save(preferences))
+
+	actualPreferences, err := ss.Preference().GetCategoryAndNameForUsers([]string{userId1, userId2, model.NewId()}, category, name)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, preferences[:2], actualPreferences)
+
+	actualPreferences, err = ss.Preference().GetCategoryAndNameForUsers(nil, category, name)
+	require.NoError(t, err)
+	assert.Empty(t, actualPreferences)
+}
+
 func testPreferenceGetCategory(t *testing.T, _ request.CTX, ss store.Store) {
 	userId := model.NewId()
 	category := model.PreferenceCategoryDirectChannelShow
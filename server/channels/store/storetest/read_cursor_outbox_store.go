@@ -0,0 +1,196 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package storetest
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/channels/store"
+)
+
+func TestReadCursorOutboxStore(t *testing.T, rctx request.CTX, ss store.Store, s SqlStore) {
+	t.Run("UpsertEnqueuesEvent", func(t *testing.T) { testReadCursorOutboxUpsertEnqueuesEvent(t, rctx, ss) })
+	t.Run("Drain", func(t *testing.T) { testReadCursorOutboxDrain(t, rctx, ss) })
+	t.Run("RemoveEnqueuesEvents", func(t *testing.T) { testReadCursorOutboxRemoveEnqueuesEvents(t, rctx, ss) })
+}
+
+func drainReadCursorOutbox(t *testing.T, ss store.Store) {
+	t.Helper()
+	entries, err := ss.ReadCursorOutbox().GetDue(model.GetMillis()+model.ReadCursorOutboxDeliveryDelay, 1000)
+	require.NoError(t, err)
+	ids := make([]int64, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.Id
+	}
+	require.NoError(t, ss.ReadCursorOutbox().Delete(ids))
+}
+
+func testReadCursorOutboxUpsertEnqueuesEvent(t *testing.T, rctx request.CTX, ss store.Store) {
+	drainReadCursorOutbox(t, ss)
+
+	channelId := model.NewId()
+	userId := model.NewId()
+
+	event, err := ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channelId, UserId: userId, LastPostSeq: 100})
+	require.NoError(t, err)
+	require.NotNil(t, event)
+	assert.Equal(t, model.ReadCursorEventTypeAdvanced, event.Type)
+	assert.Equal(t, int64(0), event.PrevLastSeq)
+	assert.Equal(t, int64(100), event.NewLastSeq)
+
+	event, err = ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channelId, UserId: userId, LastPostSeq: 200})
+	require.NoError(t, err)
+	require.NotNil(t, event)
+	assert.Equal(t, int64(100), event.PrevLastSeq)
+
+	t.Run("no event when the cursor does not advance", func(t *testing.T) {
+		event, err := ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: channelId, UserId: userId, LastPostSeq: 150})
+		require.NoError(t, err)
+		assert.Nil(t, event)
+
+		cursor, err := ss.ChannelReadCursor().Get(channelId, userId)
+		require.NoError(t, err)
+		assert.Equal(t, int64(200), cursor.LastPostSeq)
+	})
+
+	t.Run("entries are held back for the delivery delay", func(t *testing.T) {
+		entries, err := ss.ReadCursorOutbox().GetDue(model.GetMillis(), 100)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	entries, err := ss.ReadCursorOutbox().GetDue(model.GetMillis()+model.ReadCursorOutboxDeliveryDelay, 100)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Less(t, entries[0].Id, entries[1].Id)
+
+	var payload model.ReadCursorEvent
+	require.NoError(t, json.Unmarshal([]byte(entries[1].Payload), &payload))
+	assert.Equal(t, *event, payload)
+	assert.Equal(t, event.EventId, entries[1].EventId)
+	assert.Equal(t, channelId, entries[1].ChannelId)
+
+	require.NoError(t, ss.ReadCursorOutbox().DeleteByEventId(event.EventId))
+	entries, err = ss.ReadCursorOutbox().GetDue(model.GetMillis()+model.ReadCursorOutboxDeliveryDelay, 100)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.NotEqual(t, event.EventId, entries[0].EventId)
+
+	drainReadCursorOutbox(t, ss)
+}
+
+func testReadCursorOutboxDrain(t *testing.T, rctx request.CTX, ss store.Store) {
+	drainReadCursorOutbox(t, ss)
+
+	for range 3 {
+		_, err := ss.ChannelReadCursor().Upsert(&model.ChannelReadCursor{ChannelId: model.NewId(), UserId: model.NewId(), LastPostSeq: 100})
+		require.NoError(t, err)
+	}
+
+	due := model.GetMillis() + model.ReadCursorOutboxDeliveryDelay
+	entries, err := ss.ReadCursorOutbox().GetDue(due, 100)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+
+	stats, err := ss.ReadCursorOutbox().GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), stats.Pending)
+	assert.Equal(t, entries[0].CreateAt, stats.OldestCreateAt)
+
+	t.Run("failed entries are rescheduled", func(t *testing.T) {
+		require.NoError(t, ss.ReadCursorOutbox().MarkFailed(entries[0].Id, due+60000, "connection refused"))
+
+		rescheduled, err := ss.ReadCursorOutbox().GetDue(due, 100)
+		require.NoError(t, err)
+		require.Len(t, rescheduled, 2)
+
+		rescheduled, err = ss.ReadCursorOutbox().GetDue(due+60000, 100)
+		require.NoError(t, err)
+		require.Len(t, rescheduled, 3)
+		assert.Equal(t, 1, rescheduled[0].Attempts)
+		assert.Equal(t, "connection refused", rescheduled[0].LastError)
+	})
+
+	require.NoError(t, ss.ReadCursorOutbox().Delete([]int64{entries[0].Id, entries[1].Id, entries[2].Id}))
+
+	stats, err = ss.ReadCursorOutbox().GetStats()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), stats.Pending)
+	assert.Equal(t, int64(0), stats.OldestCreateAt)
+}
+
+func testReadCursorOutboxRemoveEnqueuesEvents(t *testing.T, rctx request.CTX, ss store.Store) {
+	drainReadCursorOutbox(t, ss)
+
+	channelId1 := model.NewId()
+	channelId2 := model.NewId()
+	userId1 := model.NewId()
+	userId2 := model.NewId()
+	for _, cursor := range []*model.ChannelReadCursor{
+		{ChannelId: channelId1, UserId: userId1, LastPostSeq: 100},
+		{ChannelId: channelId2, UserId: userId1, LastPostSeq: 100},
+		{ChannelId: channelId1, UserId: userId2, LastPostSeq: 100},
+	} {
+		_, err := ss.ChannelReadCursor().Upsert(cursor)
+		require.NoError(t, err)
+	}
+	drainReadCursorOutbox(t, ss)
+
+	due := model.GetMillis() + model.ReadCursorOutboxDeliveryDelay
+
+	t.Run("removing a user's cursors enqueues an event per channel", func(t *testing.T) {
+		events, err := ss.ChannelReadCursor().RemoveForUser(userId1)
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		for _, event := range events {
+			assert.Equal(t, model.ReadCursorEventTypeRemoved, event.Type)
+			assert.Equal(t, userId1, event.UserId)
+		}
+		assert.ElementsMatch(t, []string{channelId1, channelId2}, []string{events[0].ChannelId, events[1].ChannelId})
+
+		cursors, err := ss.ChannelReadCursor().GetForUser(userId1)
+		require.NoError(t, err)
+		assert.Empty(t, cursors)
+
+		entries, err := ss.ReadCursorOutbox().GetDue(due, 100)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		events, err = ss.ChannelReadCursor().RemoveForUser(userId1)
+		require.NoError(t, err)
+		assert.Empty(t, events)
+
+		drainReadCursorOutbox(t, ss)
+	})
+
+	t.Run("removing a channel's cursors enqueues a single event", func(t *testing.T) {
+		event, err := ss.ChannelReadCursor().RemoveForChannel(channelId1)
+		require.NoError(t, err)
+		require.NotNil(t, event)
+		assert.Equal(t, model.ReadCursorEventTypeRemoved, event.Type)
+		assert.Equal(t, channelId1, event.ChannelId)
+		assert.Empty(t, event.UserId)
+
+		cursors, err := ss.ChannelReadCursor().GetForChannel(channelId1)
+		require.NoError(t, err)
+		assert.Empty(t, cursors)
+
+		entries, err := ss.ReadCursorOutbox().GetDue(due, 100)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, event.EventId, entries[0].EventId)
+
+		event, err = ss.ChannelReadCursor().RemoveForChannel(channelId1)
+		require.NoError(t, err)
+		assert.Nil(t, event)
+
+		drainReadCursorOutbox(t, ss)
+	})
+}
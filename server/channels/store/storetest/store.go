@@ -60,6 +60,7 @@ type Store struct {
 	NotifyAdminStore                mocks.NotifyAdminStore
 	PostPriorityStore               mocks.PostPriorityStore
 	PostAcknowledgementStore        mocks.PostAcknowledgementStore
+	PostTranslationStore            mocks.PostTranslationStore
 	PostPersistentNotificationStore mocks.PostPersistentNotificationStore
 	DesktopTokensStore              mocks.DesktopTokensStore
 	ChannelBookmarkStore            mocks.ChannelBookmarkStore
@@ -70,6 +71,10 @@ type Store struct {
 	AccessControlPolicyStore        mocks.AccessControlPolicyStore
 	AttributesStore                 mocks.AttributesStore
 	ContentFlaggingStore            mocks.ContentFlaggingStore
+	ChannelReadCursorStore          mocks.ChannelReadCursorStore
+	ReadCursorOutboxStore           mocks.ReadCursorOutboxStore
+	EmailBatchStore                 mocks.EmailBatchStore
+	MfaFactorStore                  mocks.MfaFactorStore
 }
 
 func (s *Store) Logger() mlog.LoggerIFace                      { return s.logger }
@@ -127,6 +132,9 @@ func (s *Store) PropertyValue() store.PropertyValueStore     { return &s.Propert
 func (s *Store) PostAcknowledgement() store.PostAcknowledgementStore {
 	return &s.PostAcknowledgementStore
 }
+func (s *Store) PostTranslation() store.PostTranslationStore {
+	return &s.PostTranslationStore
+}
 func (s *Store) PostPersistentNotification() store.PostPersistentNotificationStore {
 	return &s.PostPersistentNotificationStore
 }
@@ -162,6 +170,18 @@ func (s *Store) Attributes() store.AttributesStore {
 func (s *Store) ContentFlagging() store.ContentFlaggingStore {
 	return &s.ContentFlaggingStore
 }
+func (s *Store) ChannelReadCursor() store.ChannelReadCursorStore {
+	return &s.ChannelReadCursorStore
+}
+func (s *Store) ReadCursorOutbox() store.ReadCursorOutboxStore {
+	return &s.ReadCursorOutboxStore
+}
+func (s *Store) EmailBatch() store.EmailBatchStore {
+	return &s.EmailBatchStore
+}
+func (s *Store) MfaFactor() store.MfaFactorStore {
+	return &s.MfaFactorStore
+}
 
 func (s *Store) GetSchemaDefinition() (*model.SupportPacketDatabaseSchema, error) {
 	return &model.SupportPacketDatabaseSchema{
@@ -207,6 +227,7 @@ func (s *Store) AssertExpectations(t mock.TestingT) bool {
 		&s.NotifyAdminStore,
 		&s.PostPriorityStore,
 		&s.PostAcknowledgementStore,
+		&s.PostTranslationStore,
 		&s.PostPersistentNotificationStore,
 		&s.DesktopTokensStore,
 		&s.ChannelBookmarkStore,
@@ -214,5 +235,9 @@ func (s *Store) AssertExpectations(t mock.TestingT) bool {
 		&s.AccessControlPolicyStore,
 		&s.AttributesStore,
 		&s.ContentFlaggingStore,
+		&s.ChannelReadCursorStore,
+		&s.ReadCursorOutboxStore,
+		&s.EmailBatchStore,
+		&s.MfaFactorStore,
 	)
 }
@@ -2368,7 +2368,7 @@ func testUserStoreUpdatePassword(t *testing.T, rctx request.CTX, ss store.Store)
 	_, nErr := ss.Team().SaveMember(rctx, &model.TeamMember{TeamId: teamID, UserId: u1.Id}, -1)
 	require.NoError(t, nErr)
 
-	_, err = hashers.Hash(strings.Repeat("1234567890", 8))
+	_, err = hashers.Hash(strings.Repeat("1234567890", 26))
 	require.ErrorIs(t, err, hashers.ErrPasswordTooLong)
 
 	hashedPassword, err := hashers.Hash("newpwd")
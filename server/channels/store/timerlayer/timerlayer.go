@@ -34,12 +34,14 @@ type TimerLayer struct {
 	ContentFlaggingStore            store.ContentFlaggingStore
 	DesktopTokensStore              store.DesktopTokensStore
 	DraftStore                      store.DraftStore
+	EmailBatchStore                 store.EmailBatchStore
 	EmojiStore                      store.EmojiStore
 	FileInfoStore                   store.FileInfoStore
 	GroupStore                      store.GroupStore
 	JobStore                        store.JobStore
 	LicenseStore                    store.LicenseStore
 	LinkMetadataStore               store.LinkMetadataStore
+	MfaFactorStore                  store.MfaFactorStore
 	NotifyAdminStore                store.NotifyAdminStore
 	OAuthStore                      store.OAuthStore
 	OutgoingOAuthConnectionStore    store.OutgoingOAuthConnectionStore
@@ -48,12 +50,14 @@ type TimerLayer struct {
 	PostAcknowledgementStore        store.PostAcknowledgementStore
 	PostPersistentNotificationStore store.PostPersistentNotificationStore
 	PostPriorityStore               store.PostPriorityStore
+	PostTranslationStore            store.PostTranslationStore
 	PreferenceStore                 store.PreferenceStore
 	ProductNoticesStore             store.ProductNoticesStore
 	PropertyFieldStore              store.PropertyFieldStore
 	PropertyGroupStore              store.PropertyGroupStore
 	PropertyValueStore              store.PropertyValueStore
 	ReactionStore                   store.ReactionStore
+	ReadCursorOutboxStore           store.ReadCursorOutboxStore
 	RemoteClusterStore              store.RemoteClusterStore
 	RetentionPolicyStore            store.RetentionPolicyStore
 	RoleStore                       store.RoleStore
@@ -134,6 +138,10 @@ func (s *TimerLayer) Draft() store.DraftStore {
 	return s.DraftStore
 }
 
+func (s *TimerLayer) EmailBatch() store.EmailBatchStore {
+	return s.EmailBatchStore
+}
+
 func (s *TimerLayer) Emoji() store.EmojiStore {
 	return s.EmojiStore
 }
@@ -158,6 +166,10 @@ func (s *TimerLayer) LinkMetadata() store.LinkMetadataStore {
 	return s.LinkMetadataStore
 }
 
+func (s *TimerLayer) MfaFactor() store.MfaFactorStore {
+	return s.MfaFactorStore
+}
+
 func (s *TimerLayer) NotifyAdmin() store.NotifyAdminStore {
 	return s.NotifyAdminStore
 }
@@ -190,6 +202,10 @@ func (s *TimerLayer) PostPriority() store.PostPriorityStore {
 	return s.PostPriorityStore
 }
 
+func (s *TimerLayer) PostTranslation() store.PostTranslationStore {
+	return s.PostTranslationStore
+}
+
 func (s *TimerLayer) Preference() store.PreferenceStore {
 	return s.PreferenceStore
 }
@@ -214,6 +230,10 @@ func (s *TimerLayer) Reaction() store.ReactionStore {
 	return s.ReactionStore
 }
 
+func (s *TimerLayer) ReadCursorOutbox() store.ReadCursorOutboxStore {
+	return s.ReadCursorOutboxStore
+}
+
 func (s *TimerLayer) RemoteCluster() store.RemoteClusterStore {
 	return s.RemoteClusterStore
 }
@@ -361,6 +381,11 @@ type TimerLayerDraftStore struct {
 	Root *TimerLayer
 }
 
+type TimerLayerEmailBatchStore struct {
+	store.EmailBatchStore
+	Root *TimerLayer
+}
+
 type TimerLayerEmojiStore struct {
 	store.EmojiStore
 	Root *TimerLayer
@@ -391,6 +416,11 @@ type TimerLayerLinkMetadataStore struct {
 	Root *TimerLayer
 }
 
+type TimerLayerMfaFactorStore struct {
+	store.MfaFactorStore
+	Root *TimerLayer
+}
+
 type TimerLayerNotifyAdminStore struct {
 	store.NotifyAdminStore
 	Root *TimerLayer
@@ -431,6 +461,11 @@ type TimerLayerPostPriorityStore struct {
 	Root *TimerLayer
 }
 
+type TimerLayerPostTranslationStore struct {
+	store.PostTranslationStore
+	Root *TimerLayer
+}
+
 type TimerLayerPreferenceStore struct {
 	store.PreferenceStore
 	Root *TimerLayer
@@ -461,6 +496,11 @@ type TimerLayerReactionStore struct {
 	Root *TimerLayer
 }
 
+type TimerLayerReadCursorOutboxStore struct {
+	store.ReadCursorOutboxStore
+	Root *TimerLayer
+}
+
 type TimerLayerRemoteClusterStore struct {
 	store.RemoteClusterStore
 	Root *TimerLayer
@@ -3022,6 +3062,54 @@ func (s *TimerLayerChannelMemberHistoryStore) PermanentDeleteBatchForRetentionPo
 	return result, resultVar1, err
 }
 
+func (s *TimerLayerChannelReadCursorStore) CountForSeq(channelId string, rootId string, seq int64, excludeUserId string) (int64, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.CountForSeq(channelId, rootId, seq, excludeUserId)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.CountForSeq", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) CountForSeqs(channelId string, queries []store.ReadCursorSeqQuery) ([]int64, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.CountForSeqs(channelId, queries)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.CountForSeqs", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) CountNonReaders(channelId string, rootId string, seq int64, excludeUserId string, sharesByDefault bool) (int64, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.CountNonReaders(channelId, rootId, seq, excludeUserId, sharesByDefault)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.CountNonReaders", success, elapsed)
+	}
+	return result, err
+}
+
 func (s *TimerLayerChannelReadCursorStore) Delete(channelId string, userId string) error {
 	start := time.Now()
 
@@ -3086,6 +3174,22 @@ func (s *TimerLayerChannelReadCursorStore) Get(channelId string, userId string)
 	return result, err
 }
 
+func (s *TimerLayerChannelReadCursorStore) GetDailyReadSummary(channelId string, since int64) ([]*model.ReadReceiptsDailySummary, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.GetDailyReadSummary(channelId, since)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.GetDailyReadSummary", success, elapsed)
+	}
+	return result, err
+}
+
 func (s *TimerLayerChannelReadCursorStore) GetForChannel(channelId string) ([]*model.ChannelReadCursor, error) {
 	start := time.Now()
 
@@ -3102,6 +3206,38 @@ func (s *TimerLayerChannelReadCursorStore) GetForChannel(channelId string) ([]*m
 	return result, err
 }
 
+func (s *TimerLayerChannelReadCursorStore) GetForChannelUsers(channelId string, rootId string, userIds []string) ([]*model.ChannelReadCursor, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.GetForChannelUsers(channelId, rootId, userIds)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.GetForChannelUsers", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) GetForSeq(channelId string, rootId string, seq int64, excludeUserId string, limit int) ([]*model.ChannelReadCursor, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.GetForSeq(channelId, rootId, seq, excludeUserId, limit)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.GetForSeq", success, elapsed)
+	}
+	return result, err
+}
+
 func (s *TimerLayerChannelReadCursorStore) GetForUser(userId string) ([]*model.ChannelReadCursor, error) {
 	start := time.Now()
 
@@ -3118,10 +3254,154 @@ func (s *TimerLayerChannelReadCursorStore) GetForUser(userId string) ([]*model.C
 	return result, err
 }
 
-func (s *TimerLayerChannelReadCursorStore) Upsert(cursor *model.ChannelReadCursor) error {
+func (s *TimerLayerChannelReadCursorStore) GetNonReaders(channelId string, rootId string, seq int64, excludeUserId string, sharesByDefault bool, offset int, limit int) ([]string, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.GetNonReaders(channelId, rootId, seq, excludeUserId, sharesByDefault, offset, limit)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.GetNonReaders", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) GetNonSharingMemberIds(channelId string, sharesByDefault bool) ([]string, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.GetNonSharingMemberIds(channelId, sharesByDefault)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.GetNonSharingMemberIds", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) GetNonSharingReaderIds(channelId string, sharesByDefault bool) ([]string, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.GetNonSharingReaderIds(channelId, sharesByDefault)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.GetNonSharingReaderIds", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) GetThread(rootId string, userId string) (*model.ThreadReadCursor, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.GetThread(rootId, userId)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.GetThread", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) PermanentDeleteBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error) {
 	start := time.Now()
 
-	err := s.ChannelReadCursorStore.Upsert(cursor)
+	result, resultVar1, err := s.ChannelReadCursorStore.PermanentDeleteBatchForRetentionPolicies(retentionPolicyBatchConfigs, cursor)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.PermanentDeleteBatchForRetentionPolicies", success, elapsed)
+	}
+	return result, resultVar1, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) PermanentDeleteBatchThreadCursorsForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) (int64, model.RetentionPolicyCursor, error) {
+	start := time.Now()
+
+	result, resultVar1, err := s.ChannelReadCursorStore.PermanentDeleteBatchThreadCursorsForRetentionPolicies(retentionPolicyBatchConfigs, cursor)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.PermanentDeleteBatchThreadCursorsForRetentionPolicies", success, elapsed)
+	}
+	return result, resultVar1, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) RemoveForChannel(channelId string) (*model.ReadCursorEvent, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.RemoveForChannel(channelId)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.RemoveForChannel", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) RemoveForUser(userId string) ([]*model.ReadCursorEvent, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.RemoveForUser(userId)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.RemoveForUser", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) RemoveForUserInTeams(userId string, teamIds []string, exceptTeams bool) ([]*model.ReadCursorEvent, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.RemoveForUserInTeams(userId, teamIds, exceptTeams)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.RemoveForUserInTeams", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) Upsert(cursor *model.ChannelReadCursor) (*model.ReadCursorEvent, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.Upsert(cursor)
 
 	elapsed := float64(time.Since(start)) / float64(time.Second)
 	if s.Root.Metrics != nil {
@@ -3131,7 +3411,23 @@ func (s *TimerLayerChannelReadCursorStore) Upsert(cursor *model.ChannelReadCurso
 		}
 		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.Upsert", success, elapsed)
 	}
-	return err
+	return result, err
+}
+
+func (s *TimerLayerChannelReadCursorStore) UpsertThread(cursor *model.ThreadReadCursor) (*model.ReadCursorEvent, error) {
+	start := time.Now()
+
+	result, err := s.ChannelReadCursorStore.UpsertThread(cursor)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ChannelReadCursorStore.UpsertThread", success, elapsed)
+	}
+	return result, err
 }
 
 func (s *TimerLayerClusterDiscoveryStore) Cleanup() error {
@@ -3804,6 +4100,86 @@ func (s *TimerLayerDraftStore) Upsert(d *model.Draft) (*model.Draft, error) {
 	return result, err
 }
 
+func (s *TimerLayerEmailBatchStore) Delete(ids []string) error {
+	start := time.Now()
+
+	err := s.EmailBatchStore.Delete(ids)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("EmailBatchStore.Delete", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerEmailBatchStore) DeleteForChannelMember(userId string, channelId string) error {
+	start := time.Now()
+
+	err := s.EmailBatchStore.DeleteForChannelMember(userId, channelId)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("EmailBatchStore.DeleteForChannelMember", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerEmailBatchStore) GetForUser(userId string) ([]*model.EmailBatchEntry, error) {
+	start := time.Now()
+
+	result, err := s.EmailBatchStore.GetForUser(userId)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("EmailBatchStore.GetForUser", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerEmailBatchStore) GetPendingUsers(afterUserId string, limit int) ([]*model.EmailBatchUser, error) {
+	start := time.Now()
+
+	result, err := s.EmailBatchStore.GetPendingUsers(afterUserId, limit)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("EmailBatchStore.GetPendingUsers", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerEmailBatchStore) Save(entry *model.EmailBatchEntry) error {
+	start := time.Now()
+
+	err := s.EmailBatchStore.Save(entry)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("EmailBatchStore.Save", success, elapsed)
+	}
+	return err
+}
+
 func (s *TimerLayerEmojiStore) Delete(emoji *model.Emoji, timestamp int64) error {
 	start := time.Now()
 
@@ -4011,6 +4387,22 @@ func (s *TimerLayerFileInfoStore) Get(id string) (*model.FileInfo, error) {
 	return result, err
 }
 
+func (s *TimerLayerFileInfoStore) GetBatchForRetentionPolicies(retentionPolicyBatchConfigs model.RetentionPolicyBatchConfigs, cursor model.RetentionPolicyCursor) ([]*model.FileInfo, model.RetentionPolicyCursor, error) {
+	start := time.Now()
+
+	result, resultVar1, err := s.FileInfoStore.GetBatchForRetentionPolicies(retentionPolicyBatchConfigs, cursor)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileInfoStore.GetBatchForRetentionPolicies", success, elapsed)
+	}
+	return result, resultVar1, err
+}
+
 func (s *TimerLayerFileInfoStore) GetByIds(ids []string, includeDeleted bool, allowFromCache bool) ([]*model.FileInfo, error) {
 	start := time.Now()
 
@@ -4075,6 +4467,22 @@ func (s *TimerLayerFileInfoStore) GetForPost(postID string, readFromMaster bool,
 	return result, err
 }
 
+func (s *TimerLayerFileInfoStore) GetForPostIds(postIDs []string) ([]*model.FileInfo, error) {
+	start := time.Now()
+
+	result, err := s.FileInfoStore.GetForPostIds(postIDs)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileInfoStore.GetForPostIds", success, elapsed)
+	}
+	return result, err
+}
+
 func (s *TimerLayerFileInfoStore) GetForUser(userID string) ([]*model.FileInfo, error) {
 	start := time.Now()
 
@@ -4173,7 +4581,23 @@ func (s *TimerLayerFileInfoStore) InvalidateFileInfosForPostCache(postID string,
 func (s *TimerLayerFileInfoStore) PermanentDelete(rctx request.CTX, fileID string) error {
 	start := time.Now()
 
-	err := s.FileInfoStore.PermanentDelete(rctx, fileID)
+	err := s.FileInfoStore.PermanentDelete(rctx, fileID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("FileInfoStore.PermanentDelete", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerFileInfoStore) PermanentDeleteBatch(rctx request.CTX, endTime int64, limit int64) (int64, error) {
+	start := time.Now()
+
+	result, err := s.FileInfoStore.PermanentDeleteBatch(rctx, endTime, limit)
 
 	elapsed := float64(time.Since(start)) / float64(time.Second)
 	if s.Root.Metrics != nil {
@@ -4181,15 +4605,15 @@ func (s *TimerLayerFileInfoStore) PermanentDelete(rctx request.CTX, fileID strin
 		if err == nil {
 			success = "true"
 		}
-		s.Root.Metrics.ObserveStoreMethodDuration("FileInfoStore.PermanentDelete", success, elapsed)
+		s.Root.Metrics.ObserveStoreMethodDuration("FileInfoStore.PermanentDeleteBatch", success, elapsed)
 	}
-	return err
+	return result, err
 }
 
-func (s *TimerLayerFileInfoStore) PermanentDeleteBatch(rctx request.CTX, endTime int64, limit int64) (int64, error) {
+func (s *TimerLayerFileInfoStore) PermanentDeleteByIds(rctx request.CTX, fileIDs []string) (int64, error) {
 	start := time.Now()
 
-	result, err := s.FileInfoStore.PermanentDeleteBatch(rctx, endTime, limit)
+	result, err := s.FileInfoStore.PermanentDeleteByIds(rctx, fileIDs)
 
 	elapsed := float64(time.Since(start)) / float64(time.Second)
 	if s.Root.Metrics != nil {
@@ -4197,7 +4621,7 @@ func (s *TimerLayerFileInfoStore) PermanentDeleteBatch(rctx request.CTX, endTime
 		if err == nil {
 			success = "true"
 		}
-		s.Root.Metrics.ObserveStoreMethodDuration("FileInfoStore.PermanentDeleteBatch", success, elapsed)
+		s.Root.Metrics.ObserveStoreMethodDuration("FileInfoStore.PermanentDeleteByIds", success, elapsed)
 	}
 	return result, err
 }
@@ -5578,6 +6002,134 @@ func (s *TimerLayerLinkMetadataStore) Save(linkMetadata *model.LinkMetadata) (*m
 	return result, err
 }
 
+func (s *TimerLayerMfaFactorStore) CountRecoveryCodes(userId string) (int64, error) {
+	start := time.Now()
+
+	result, err := s.MfaFactorStore.CountRecoveryCodes(userId)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("MfaFactorStore.CountRecoveryCodes", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerMfaFactorStore) DeleteAllForUser(userId string) error {
+	start := time.Now()
+
+	err := s.MfaFactorStore.DeleteAllForUser(userId)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("MfaFactorStore.DeleteAllForUser", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerMfaFactorStore) DeleteWebAuthnCredential(userId string, id string) error {
+	start := time.Now()
+
+	err := s.MfaFactorStore.DeleteWebAuthnCredential(userId, id)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("MfaFactorStore.DeleteWebAuthnCredential", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerMfaFactorStore) GetWebAuthnCredentials(userId string) ([]*model.WebAuthnCredential, error) {
+	start := time.Now()
+
+	result, err := s.MfaFactorStore.GetWebAuthnCredentials(userId)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("MfaFactorStore.GetWebAuthnCredentials", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerMfaFactorStore) SaveRecoveryCodes(userId string, codeHashes []string) error {
+	start := time.Now()
+
+	err := s.MfaFactorStore.SaveRecoveryCodes(userId, codeHashes)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("MfaFactorStore.SaveRecoveryCodes", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerMfaFactorStore) SaveWebAuthnCredential(credential *model.WebAuthnCredential) (*model.WebAuthnCredential, error) {
+	start := time.Now()
+
+	result, err := s.MfaFactorStore.SaveWebAuthnCredential(credential)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("MfaFactorStore.SaveWebAuthnCredential", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerMfaFactorStore) UpdateWebAuthnCredential(credential *model.WebAuthnCredential) error {
+	start := time.Now()
+
+	err := s.MfaFactorStore.UpdateWebAuthnCredential(credential)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("MfaFactorStore.UpdateWebAuthnCredential", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerMfaFactorStore) UseRecoveryCode(userId string, codeHash string) (bool, error) {
+	start := time.Now()
+
+	result, err := s.MfaFactorStore.UseRecoveryCode(userId, codeHash)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("MfaFactorStore.UseRecoveryCode", success, elapsed)
+	}
+	return result, err
+}
+
 func (s *TimerLayerNotifyAdminStore) DeleteBefore(trial bool, now int64) error {
 	start := time.Now()
 
@@ -6713,6 +7265,22 @@ func (s *TimerLayerPostStore) GetPostsCreatedAt(channelID string, timestamp int6
 	return result, err
 }
 
+func (s *TimerLayerPostStore) GetPostsForReadReceipts(postIds []string) ([]*model.Post, error) {
+	start := time.Now()
+
+	result, err := s.PostStore.GetPostsForReadReceipts(postIds)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("PostStore.GetPostsForReadReceipts", success, elapsed)
+	}
+	return result, err
+}
+
 func (s *TimerLayerPostStore) GetPostsSince(rctx request.CTX, options model.GetPostsSinceOptions, allowFromCache bool, sanitizeOptions map[string]bool) (*model.PostList, error) {
 	start := time.Now()
 
@@ -7384,6 +7952,70 @@ func (s *TimerLayerPostPriorityStore) Save(priority *model.PostPriority) (*model
 	return result, err
 }
 
+func (s *TimerLayerPostTranslationStore) DeleteForPost(postID string) error {
+	start := time.Now()
+
+	err := s.PostTranslationStore.DeleteForPost(postID)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("PostTranslationStore.DeleteForPost", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerPostTranslationStore) Get(postID string, locale string) (*model.PostTranslation, error) {
+	start := time.Now()
+
+	result, err := s.PostTranslationStore.Get(postID, locale)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("PostTranslationStore.Get", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerPostTranslationStore) GetForPosts(postIDs []string, locale string) ([]*model.PostTranslation, error) {
+	start := time.Now()
+
+	result, err := s.PostTranslationStore.GetForPosts(postIDs, locale)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("PostTranslationStore.GetForPosts", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerPostTranslationStore) Save(translation *model.PostTranslation) (*model.PostTranslation, error) {
+	start := time.Now()
+
+	result, err := s.PostTranslationStore.Save(translation)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("PostTranslationStore.Save", success, elapsed)
+	}
+	return result, err
+}
+
 func (s *TimerLayerPreferenceStore) CleanupFlagsBatch(limit int64) (int64, error) {
 	start := time.Now()
 
@@ -7544,6 +8176,22 @@ func (s *TimerLayerPreferenceStore) GetCategoryAndName(category string, name str
 	return result, err
 }
 
+func (s *TimerLayerPreferenceStore) GetCategoryAndNameForUsers(userIDs []string, category string, name string) (model.Preferences, error) {
+	start := time.Now()
+
+	result, err := s.PreferenceStore.GetCategoryAndNameForUsers(userIDs, category, name)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("PreferenceStore.GetCategoryAndNameForUsers", success, elapsed)
+	}
+	return result, err
+}
+
 func (s *TimerLayerPreferenceStore) PermanentDeleteByUser(userID string) error {
 	start := time.Now()
 
@@ -8168,6 +8816,86 @@ func (s *TimerLayerReactionStore) Save(reaction *model.Reaction) (*model.Reactio
 	return result, err
 }
 
+func (s *TimerLayerReadCursorOutboxStore) Delete(ids []int64) error {
+	start := time.Now()
+
+	err := s.ReadCursorOutboxStore.Delete(ids)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ReadCursorOutboxStore.Delete", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerReadCursorOutboxStore) DeleteByEventId(eventId string) error {
+	start := time.Now()
+
+	err := s.ReadCursorOutboxStore.DeleteByEventId(eventId)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ReadCursorOutboxStore.DeleteByEventId", success, elapsed)
+	}
+	return err
+}
+
+func (s *TimerLayerReadCursorOutboxStore) GetDue(now int64, limit int) ([]*model.ReadCursorOutboxEntry, error) {
+	start := time.Now()
+
+	result, err := s.ReadCursorOutboxStore.GetDue(now, limit)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ReadCursorOutboxStore.GetDue", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerReadCursorOutboxStore) GetStats() (*model.ReadCursorOutboxStats, error) {
+	start := time.Now()
+
+	result, err := s.ReadCursorOutboxStore.GetStats()
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ReadCursorOutboxStore.GetStats", success, elapsed)
+	}
+	return result, err
+}
+
+func (s *TimerLayerReadCursorOutboxStore) MarkFailed(id int64, nextAttemptAt int64, lastError string) error {
+	start := time.Now()
+
+	err := s.ReadCursorOutboxStore.MarkFailed(id, nextAttemptAt, lastError)
+
+	elapsed := float64(time.Since(start)) / float64(time.Second)
+	if s.Root.Metrics != nil {
+		success := "false"
+		if err == nil {
+			success = "true"
+		}
+		s.Root.Metrics.ObserveStoreMethodDuration("ReadCursorOutboxStore.MarkFailed", success, elapsed)
+	}
+	return err
+}
+
 func (s *TimerLayerRemoteClusterStore) Delete(remoteClusterID string) (bool, error) {
 	start := time.Now()
 
@@ -13604,12 +14332,14 @@ func New(childStore store.Store, metrics einterfaces.MetricsInterface) *TimerLay
 	newStore.ContentFlaggingStore = &TimerLayerContentFlaggingStore{ContentFlaggingStore: childStore.ContentFlagging(), Root: &newStore}
 	newStore.DesktopTokensStore = &TimerLayerDesktopTokensStore{DesktopTokensStore: childStore.DesktopTokens(), Root: &newStore}
 	newStore.DraftStore = &TimerLayerDraftStore{DraftStore: childStore.Draft(), Root: &newStore}
+	newStore.EmailBatchStore = &TimerLayerEmailBatchStore{EmailBatchStore: childStore.EmailBatch(), Root: &newStore}
 	newStore.EmojiStore = &TimerLayerEmojiStore{EmojiStore: childStore.Emoji(), Root: &newStore}
 	newStore.FileInfoStore = &TimerLayerFileInfoStore{FileInfoStore: childStore.FileInfo(), Root: &newStore}
 	newStore.GroupStore = &TimerLayerGroupStore{GroupStore: childStore.Group(), Root: &newStore}
 	newStore.JobStore = &TimerLayerJobStore{JobStore: childStore.Job(), Root: &newStore}
 	newStore.LicenseStore = &TimerLayerLicenseStore{LicenseStore: childStore.License(), Root: &newStore}
 	newStore.LinkMetadataStore = &TimerLayerLinkMetadataStore{LinkMetadataStore: childStore.LinkMetadata(), Root: &newStore}
+	newStore.MfaFactorStore = &TimerLayerMfaFactorStore{MfaFactorStore: childStore.MfaFactor(), Root: &newStore}
 	newStore.NotifyAdminStore = &TimerLayerNotifyAdminStore{NotifyAdminStore: childStore.NotifyAdmin(), Root: &newStore}
 	newStore.OAuthStore = &TimerLayerOAuthStore{OAuthStore: childStore.OAuth(), Root: &newStore}
 	newStore.OutgoingOAuthConnectionStore = &TimerLayerOutgoingOAuthConnectionStore{OutgoingOAuthConnectionStore: childStore.OutgoingOAuthConnection(), Root: &newStore}
@@ -13618,12 +14348,14 @@ func New(childStore store.Store, metrics einterfaces.MetricsInterface) *TimerLay
 	newStore.PostAcknowledgementStore = &TimerLayerPostAcknowledgementStore{PostAcknowledgementStore: childStore.PostAcknowledgement(), Root: &newStore}
 	newStore.PostPersistentNotificationStore = &TimerLayerPostPersistentNotificationStore{PostPersistentNotificationStore: childStore.PostPersistentNotification(), Root: &newStore}
 	newStore.PostPriorityStore = &TimerLayerPostPriorityStore{PostPriorityStore: childStore.PostPriority(), Root: &newStore}
+	newStore.PostTranslationStore = &TimerLayerPostTranslationStore{PostTranslationStore: childStore.PostTranslation(), Root: &newStore}
 	newStore.PreferenceStore = &TimerLayerPreferenceStore{PreferenceStore: childStore.Preference(), Root: &newStore}
 	newStore.ProductNoticesStore = &TimerLayerProductNoticesStore{ProductNoticesStore: childStore.ProductNotices(), Root: &newStore}
 	newStore.PropertyFieldStore = &TimerLayerPropertyFieldStore{PropertyFieldStore: childStore.PropertyField(), Root: &newStore}
 	newStore.PropertyGroupStore = &TimerLayerPropertyGroupStore{PropertyGroupStore: childStore.PropertyGroup(), Root: &newStore}
 	newStore.PropertyValueStore = &TimerLayerPropertyValueStore{PropertyValueStore: childStore.PropertyValue(), Root: &newStore}
 	newStore.ReactionStore = &TimerLayerReactionStore{ReactionStore: childStore.Reaction(), Root: &newStore}
+	newStore.ReadCursorOutboxStore = &TimerLayerReadCursorOutboxStore{ReadCursorOutboxStore: childStore.ReadCursorOutbox(), Root: &newStore}
 	newStore.RemoteClusterStore = &TimerLayerRemoteClusterStore{RemoteClusterStore: childStore.RemoteCluster(), Root: &newStore}
 	newStore.RetentionPolicyStore = &TimerLayerRetentionPolicyStore{RetentionPolicyStore: childStore.RetentionPolicy(), Root: &newStore}
 	newStore.RoleStore = &TimerLayerRoleStore{RoleStore: childStore.Role(), Root: &newStore}
@@ -325,8 +325,12 @@ func dbVersionCmdF(command *cobra.Command, args []string) error {
 func ConfigToFileBackendSettings(s *model.FileSettings, enableComplianceFeature bool, skipVerify bool) filestore.FileBackendSettings {
 	if *s.DriverName == model.ImageDriverLocal {
 		return filestore.FileBackendSettings{
-			DriverName: *s.DriverName,
-			Directory:  *s.Directory,
+			DriverName:                      *s.DriverName,
+			Directory:                       *s.Directory,
+			EncryptAtRest:                   *s.EncryptAtRest,
+			EncryptionKey:                   *s.EncryptionKey,
+			EncryptionKeyFile:               *s.EncryptionKeyFile,
+			EncryptionAllowUnencryptedFiles: *s.EncryptionAllowUnencryptedFiles,
 		}
 	}
 	return filestore.FileBackendSettings{
@@ -343,5 +347,9 @@ func ConfigToFileBackendSettings(s *model.FileSettings, enableComplianceFeature
 		AmazonS3Trace:                      s.AmazonS3Trace != nil && *s.AmazonS3Trace,
 		AmazonS3RequestTimeoutMilliseconds: *s.AmazonS3RequestTimeoutMilliseconds,
 		SkipVerify:                         skipVerify,
+		EncryptAtRest:                      *s.EncryptAtRest,
+		EncryptionKey:                      *s.EncryptionKey,
+		EncryptionKeyFile:                  *s.EncryptionKeyFile,
+		EncryptionAllowUnencryptedFiles:    *s.EncryptionAllowUnencryptedFiles,
 	}
 }
@@ -28,6 +28,7 @@ type MetricsInterface interface {
 	IncrementClusterRequest()
 	ObserveClusterRequestDuration(elapsed float64)
 	IncrementClusterEventType(eventType model.ClusterEvent)
+	IncrementClusterEventDropped(eventType model.ClusterEvent)
 
 	IncrementLogin()
 	IncrementLoginFail()
@@ -97,6 +98,13 @@ type MetricsInterface interface {
 	SetReplicaLagAbsolute(node string, value float64)
 	SetReplicaLagTime(node string, value float64)
 
+	IncrementReadCursorAdvanced(cursorType string)
+	IncrementReadCursorPublishFailures()
+	SetReadCursorOutboxPending(count int64)
+	SetReadCursorOutboxOldestAge(seconds float64)
+	IncrementReadCursorOutboxPublished(count int)
+	IncrementReadCursorOutboxPublishFailures(count int)
+
 	IncrementNotificationCounter(notificationType model.NotificationType, platform string)
 	IncrementNotificationAckCounter(notificationType model.NotificationType, platform string)
 	IncrementNotificationSuccessCounter(notificationType model.NotificationType, platform string)
@@ -88,6 +88,11 @@ func (_m *MetricsInterface) IncrementClientLongTasks(platform string, agent stri
 	_m.Called(platform, agent, userID, inc)
 }
 
+// IncrementClusterEventDropped provides a mock function with given fields: eventType
+func (_m *MetricsInterface) IncrementClusterEventDropped(eventType model.ClusterEvent) {
+	_m.Called(eventType)
+}
+
 // IncrementClusterEventType provides a mock function with given fields: eventType
 func (_m *MetricsInterface) IncrementClusterEventType(eventType model.ClusterEvent) {
 	_m.Called(eventType)
@@ -243,6 +248,26 @@ func (_m *MetricsInterface) IncrementPostsSearchCounter() {
 	_m.Called()
 }
 
+// IncrementReadCursorAdvanced provides a mock function with given fields: cursorType
+func (_m *MetricsInterface) IncrementReadCursorAdvanced(cursorType string) {
+	_m.Called(cursorType)
+}
+
+// IncrementReadCursorOutboxPublishFailures provides a mock function with given fields: count
+func (_m *MetricsInterface) IncrementReadCursorOutboxPublishFailures(count int) {
+	_m.Called(count)
+}
+
+// IncrementReadCursorOutboxPublished provides a mock function with given fields: count
+func (_m *MetricsInterface) IncrementReadCursorOutboxPublished(count int) {
+	_m.Called(count)
+}
+
+// IncrementReadCursorPublishFailures provides a mock function with no fields
+func (_m *MetricsInterface) IncrementReadCursorPublishFailures() {
+	_m.Called()
+}
+
 // IncrementRemoteClusterConnStateChangeCounter provides a mock function with given fields: remoteID, online
 func (_m *MetricsInterface) IncrementRemoteClusterConnStateChangeCounter(remoteID string, online bool) {
 	_m.Called(remoteID, online)
@@ -563,6 +588,16 @@ func (_m *MetricsInterface) RegisterDBCollector(db *sql.DB, name string) {
 	_m.Called(db, name)
 }
 
+// SetReadCursorOutboxOldestAge provides a mock function with given fields: seconds
+func (_m *MetricsInterface) SetReadCursorOutboxOldestAge(seconds float64) {
+	_m.Called(seconds)
+}
+
+// SetReadCursorOutboxPending provides a mock function with given fields: count
+func (_m *MetricsInterface) SetReadCursorOutboxPending(count int64) {
+	_m.Called(count)
+}
+
 // SetReplicaLagAbsolute provides a mock function with given fields: node, value
 func (_m *MetricsInterface) SetReplicaLagAbsolute(node string, value float64) {
 	_m.Called(node, value)
@@ -0,0 +1,179 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	AnalyzerStandard = "standard"
+	AnalyzerCJK      = "cjk"
+	AnalyzerKeyword  = "keyword"
+)
+
+// Token is a term produced by an analyzer. Start and End are the byte offsets of the
+// text the term was produced from, used to highlight matches.
+type Token struct {
+	Term     string
+	Position int
+	Start    int
+	End      int
+}
+
+// Analyzer splits a text into the terms stored in and looked up from an index.
+// Terms produced at consecutive positions are matched as phrases.
+type Analyzer interface {
+	Analyze(text string) []Token
+}
+
+// AnalyzerFunc adapts a function to the Analyzer interface.
+type AnalyzerFunc func(text string) []Token
+
+func (f AnalyzerFunc) Analyze(text string) []Token {
+	return f(text)
+}
+
+var (
+	analyzers = map[string]Analyzer{
+		AnalyzerStandard: AnalyzerFunc(analyzeStandard),
+		AnalyzerCJK:      AnalyzerFunc(analyzeCJK),
+		AnalyzerKeyword:  AnalyzerFunc(analyzeKeyword),
+	}
+	analyzersMut sync.RWMutex
+)
+
+// RegisterAnalyzer makes an analyzer available to EmbeddedSearchSettings.Analyzer under the
+// given name, replacing any analyzer already registered with it.
+func RegisterAnalyzer(name string, analyzer Analyzer) {
+	analyzersMut.Lock()
+	defer analyzersMut.Unlock()
+
+	analyzers[name] = analyzer
+}
+
+// GetAnalyzer returns the analyzer registered under the given name.
+func GetAnalyzer(name string) (Analyzer, bool) {
+	analyzersMut.RLock()
+	defer analyzersMut.RUnlock()
+
+	analyzer, ok := analyzers[name]
+	return analyzer, ok
+}
+
+// isCJK reports whether r belongs to a script written without spaces between words
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || r == '_'
+}
+
+// segment is a run of word characters that are either all CJK or all not
+type segment struct {
+	text  string
+	start int
+	cjk   bool
+}
+
+// segments splits text on non-word characters and on the boundaries between CJK and other scripts
+func segments(text string) []segment {
+	var result []segment
+	start := -1
+	cjk := false
+
+	for i, r := range text {
+		if !isWordRune(r) {
+			if start != -1 {
+				result = append(result, segment{text: text[start:i], start: start, cjk: cjk})
+				start = -1
+			}
+			continue
+		}
+
+		if start != -1 && isCJK(r) != cjk {
+			result = append(result, segment{text: text[start:i], start: start, cjk: cjk})
+			start = -1
+		}
+		if start == -1 {
+			start = i
+			cjk = isCJK(r)
+		}
+	}
+	if start != -1 {
+		result = append(result, segment{text: text[start:], start: start, cjk: cjk})
+	}
+
+	return result
+}
+
+// analyzeStandard produces a lowercased term for every word. CJK text is only split where it
+// meets other scripts or punctuation, so it is kept as whole sentences.
+func analyzeStandard(text string) []Token {
+	var tokens []Token
+	for _, seg := range segments(text) {
+		tokens = append(tokens, Token{
+			Term:     strings.ToLower(seg.text),
+			Position: len(tokens),
+			Start:    seg.start,
+			End:      seg.start + len(seg.text),
+		})
+	}
+	return tokens
+}
+
+// analyzeCJK works as analyzeStandard but splits CJK text into overlapping bigrams, so that
+// "北京大学" produces "北京", "京大" and "大学". A lone CJK character is kept as a unigram.
+func analyzeCJK(text string) []Token {
+	var tokens []Token
+	for _, seg := range segments(text) {
+		if !seg.cjk || utf8.RuneCountInString(seg.text) == 1 {
+			tokens = append(tokens, Token{
+				Term:     strings.ToLower(seg.text),
+				Position: len(tokens),
+				Start:    seg.start,
+				End:      seg.start + len(seg.text),
+			})
+			continue
+		}
+
+		for i, r := range seg.text {
+			next := i + utf8.RuneLen(r)
+			if next == len(seg.text) {
+				break
+			}
+			_, size := utf8.DecodeRuneInString(seg.text[next:])
+			tokens = append(tokens, Token{
+				Term:     seg.text[i : next+size],
+				Position: len(tokens),
+				Start:    seg.start + i,
+				End:      seg.start + next + size,
+			})
+		}
+	}
+	return tokens
+}
+
+// analyzeKeyword produces the whole lowercased text as a single term
+func analyzeKeyword(text string) []Token {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return nil
+	}
+
+	start := strings.Index(text, trimmed)
+	return []Token{{
+		Term:     strings.ToLower(trimmed),
+		Position: 0,
+		Start:    start,
+		End:      start + len(trimmed),
+	}}
+}
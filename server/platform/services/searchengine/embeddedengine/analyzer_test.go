@@ -0,0 +1,74 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func terms(tokens []Token) []string {
+	result := make([]string, len(tokens))
+	for i, token := range tokens {
+		result[i] = token.Term
+	}
+	return result
+}
+
+func TestAnalyzers(t *testing.T) {
+	t.Run("standard", func(t *testing.T) {
+		analyzer, ok := GetAnalyzer(AnalyzerStandard)
+		require.True(t, ok)
+
+		assert.Equal(t, []string{"hello", "world", "42"}, terms(analyzer.Analyze("Hello, World! 42")))
+		assert.Equal(t, []string{"我们明天开会", "meeting"}, terms(analyzer.Analyze("我们明天开会 meeting")))
+		assert.Empty(t, analyzer.Analyze(" ... "))
+	})
+
+	t.Run("cjk", func(t *testing.T) {
+		analyzer, ok := GetAnalyzer(AnalyzerCJK)
+		require.True(t, ok)
+
+		assert.Equal(t, []string{"我们", "们明", "明天", "天开", "开会"}, terms(analyzer.Analyze("我们明天开会")))
+		assert.Equal(t, []string{"meeting", "会议", "at", "3pm"}, terms(analyzer.Analyze("Meeting会议 at 3pm")))
+		assert.Equal(t, []string{"好"}, terms(analyzer.Analyze("好")))
+		assert.Equal(t, []string{"こん", "んに", "にち", "ちは"}, terms(analyzer.Analyze("こんにちは")))
+		assert.Equal(t, []string{"안녕", "녕하", "하세", "세요"}, terms(analyzer.Analyze("안녕하세요")))
+	})
+
+	t.Run("cjk positions and offsets", func(t *testing.T) {
+		analyzer, _ := GetAnalyzer(AnalyzerCJK)
+
+		tokens := analyzer.Analyze("ok 你好吗")
+		require.Len(t, tokens, 3)
+		for i, token := range tokens {
+			assert.Equal(t, i, token.Position)
+		}
+		text := "ok 你好吗"
+		assert.Equal(t, "你好", text[tokens[1].Start:tokens[1].End])
+		assert.Equal(t, "好吗", text[tokens[2].Start:tokens[2].End])
+	})
+
+	t.Run("keyword", func(t *testing.T) {
+		analyzer, ok := GetAnalyzer(AnalyzerKeyword)
+		require.True(t, ok)
+
+		assert.Equal(t, []string{"hello world"}, terms(analyzer.Analyze("  Hello World ")))
+		assert.Empty(t, analyzer.Analyze("  "))
+	})
+
+	t.Run("register", func(t *testing.T) {
+		_, ok := GetAnalyzer("reversed")
+		require.False(t, ok)
+
+		RegisterAnalyzer("reversed", AnalyzerFunc(func(text string) []Token {
+			return []Token{{Term: text + "!", End: len(text)}}
+		}))
+		analyzer, ok := GetAnalyzer("reversed")
+		require.True(t, ok)
+		assert.Equal(t, []string{"a!"}, terms(analyzer.Analyze("a")))
+	})
+}
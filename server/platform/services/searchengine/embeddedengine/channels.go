@@ -0,0 +1,136 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+)
+
+// shouldIndexChannel reports whether a channel can be found by autocompletion
+func shouldIndexChannel(channel *model.Channel) bool {
+	return channel.Type == model.ChannelTypeOpen || channel.Type == model.ChannelTypePrivate
+}
+
+// IndexChannel indexes a channel along with the users allowed to find it: the members of
+// private channels and the members of the team for public ones
+func (e *EmbeddedEngine) IndexChannel(rctx request.CTX, channel *model.Channel, userIDs, teamMemberIDs []string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.IndexChannel"); appErr != nil {
+		return appErr
+	}
+
+	if !shouldIndexChannel(channel) {
+		return nil
+	}
+
+	if err := e.channels.put(channelDocument(channel, userIDs, teamMemberIDs)); err != nil {
+		return indexError("EmbeddedEngine.IndexChannel", err)
+	}
+
+	return nil
+}
+
+func (e *EmbeddedEngine) SyncBulkIndexChannels(rctx request.CTX, channels []*model.Channel, getUserIDsForChannel func(channel *model.Channel) ([]string, error), teamMemberIDs []string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.SyncBulkIndexChannels"); appErr != nil {
+		return appErr
+	}
+
+	docs := make([]*document, 0, len(channels))
+	for _, channel := range channels {
+		if !shouldIndexChannel(channel) {
+			continue
+		}
+
+		userIDs, err := getUserIDsForChannel(channel)
+		if err != nil {
+			return model.NewAppError("EmbeddedEngine.SyncBulkIndexChannels", "embeddedengine.sync_bulk_index_channels.get_user_ids.error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		docs = append(docs, channelDocument(channel, userIDs, teamMemberIDs))
+	}
+
+	if err := e.channels.put(docs...); err != nil {
+		return indexError("EmbeddedEngine.SyncBulkIndexChannels", err)
+	}
+
+	return nil
+}
+
+// SearchChannels returns the ids of the channels the user can see whose name starts with every
+// word of the term. Guests only see the channels they are a member of.
+func (e *EmbeddedEngine) SearchChannels(teamId, userID, term string, isGuest, includeDeleted bool) ([]string, *model.AppError) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.SearchChannels"); appErr != nil {
+		return nil, appErr
+	}
+
+	q := &query{fields: []string{fieldName, fieldDisplayName}}
+	for word := range strings.FieldsSeq(term) {
+		q.terms = append(q.terms, termQuery{text: word, prefix: true})
+	}
+	if teamId != "" {
+		q.filters = append(q.filters, keywordFilter{field: fieldTeamId, values: []string{teamId}})
+	}
+	if !includeDeleted {
+		q.ranges = append(q.ranges, numberRange{field: fieldDeleteAt, from: 0, to: 0})
+	}
+
+	var memberOf []string
+	if isGuest {
+		if user, ok := e.users.get(userID); ok {
+			memberOf = user.Keywords[fieldChannelId]
+		}
+	}
+
+	var docs []*document
+	for _, doc := range e.channels.search(q) {
+		visible := false
+		switch {
+		case isGuest:
+			visible = slices.Contains(memberOf, doc.Id)
+		case doc.keyword(fieldType) == string(model.ChannelTypeOpen):
+			visible = slices.Contains(doc.Keywords[fieldTeamMemberId], userID)
+		default:
+			visible = slices.Contains(doc.Keywords[fieldUserId], userID)
+		}
+		if visible {
+			docs = append(docs, doc)
+		}
+	}
+
+	slices.SortFunc(docs, func(a, b *document) int {
+		if c := strings.Compare(strings.ToLower(a.Text[fieldDisplayName]), strings.ToLower(b.Text[fieldDisplayName])); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+
+	return ids(pageOf(docs, 0, model.ChannelSearchDefaultLimit)), nil
+}
+
+func (e *EmbeddedEngine) DeleteChannel(channel *model.Channel) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.DeleteChannel"); appErr != nil {
+		return appErr
+	}
+
+	if err := e.channels.delete(channel.Id); err != nil {
+		return indexError("EmbeddedEngine.DeleteChannel", err)
+	}
+
+	return nil
+}
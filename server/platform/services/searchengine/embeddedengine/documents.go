@@ -0,0 +1,211 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	fieldMessage     = "message"
+	fieldName        = "name"
+	fieldContent     = "content"
+	fieldDisplayName = "display_name"
+	fieldUsername    = "username"
+	fieldNickname    = "nickname"
+	fieldFirstName   = "first_name"
+	fieldLastName    = "last_name"
+
+	fieldChannelId    = "channel_id"
+	fieldUserId       = "user_id"
+	fieldTeamId       = "team_id"
+	fieldPostId       = "post_id"
+	fieldHashtags     = "hashtags"
+	fieldExtension    = "extension"
+	fieldType         = "type"
+	fieldRoles        = "roles"
+	fieldTeamMemberId = "team_member_id"
+
+	fieldCreateAt = "create_at"
+	fieldDeleteAt = "delete_at"
+)
+
+func postDocument(post *model.Post, teamId string) *document {
+	return &document{
+		Id:   post.Id,
+		Text: map[string]string{fieldMessage: post.Message},
+		Keywords: map[string][]string{
+			fieldChannelId: {post.ChannelId},
+			fieldUserId:    {post.UserId},
+			fieldTeamId:    {teamId},
+			fieldHashtags:  strings.Fields(strings.ToLower(post.Hashtags)),
+		},
+		Numbers: map[string]int64{fieldCreateAt: post.CreateAt},
+	}
+}
+
+func fileDocument(file *model.FileInfo, channelId string) *document {
+	return &document{
+		Id:   file.Id,
+		Text: map[string]string{fieldName: file.Name, fieldContent: file.Content},
+		Keywords: map[string][]string{
+			fieldChannelId: {channelId},
+			fieldUserId:    {file.CreatorId},
+			fieldPostId:    {file.PostId},
+			fieldExtension: {strings.ToLower(file.Extension)},
+		},
+		Numbers: map[string]int64{fieldCreateAt: file.CreateAt},
+	}
+}
+
+func channelDocument(channel *model.Channel, userIDs, teamMemberIDs []string) *document {
+	return &document{
+		Id:   channel.Id,
+		Text: map[string]string{fieldName: channel.Name, fieldDisplayName: channel.DisplayName},
+		Keywords: map[string][]string{
+			fieldTeamId:       {channel.TeamId},
+			fieldType:         {string(channel.Type)},
+			fieldUserId:       userIDs,
+			fieldTeamMemberId: teamMemberIDs,
+		},
+		Numbers: map[string]int64{fieldDeleteAt: channel.DeleteAt},
+	}
+}
+
+func userDocument(user *model.UserForIndexing) *document {
+	return &document{
+		Id: user.Id,
+		Text: map[string]string{
+			fieldUsername:  user.Username,
+			fieldNickname:  user.Nickname,
+			fieldFirstName: user.FirstName,
+			fieldLastName:  user.LastName,
+		},
+		Keywords: map[string][]string{
+			fieldTeamId:    user.TeamsIds,
+			fieldChannelId: user.ChannelsIds,
+			fieldRoles:     strings.Fields(user.Roles),
+		},
+		Numbers: map[string]int64{fieldCreateAt: user.CreateAt, fieldDeleteAt: user.DeleteAt},
+	}
+}
+
+// paramsQuery returns the query matching the search parameters in the given channels, or nil
+// if the parameters don't narrow the search down
+func paramsQuery(params *model.SearchParams, channelIds []string, fields []string) *query {
+	if params.Terms == "" && params.ExcludedTerms == "" &&
+		len(params.InChannels) == 0 && len(params.ExcludedChannels) == 0 &&
+		len(params.FromUsers) == 0 && len(params.ExcludedUsers) == 0 &&
+		len(params.Extensions) == 0 &&
+		params.OnDate == "" && params.AfterDate == "" && params.BeforeDate == "" {
+		return nil
+	}
+
+	q := &query{
+		fields:  fields,
+		anyTerm: params.OrTerms,
+	}
+
+	if params.IsHashtag {
+		q.terms = keywordTerms(params.Terms, fieldHashtags)
+		q.excludedTerms = keywordTerms(params.ExcludedTerms, fieldHashtags)
+	} else {
+		q.terms = parseTerms(params.Terms)
+		q.excludedTerms = parseTerms(params.ExcludedTerms)
+	}
+
+	// The first filter narrows the documents down when searching without terms
+	if len(params.FromUsers) > 0 {
+		q.filters = append(q.filters, keywordFilter{field: fieldUserId, values: params.FromUsers})
+	}
+
+	inChannels := channelIds
+	if len(params.InChannels) > 0 {
+		inChannels = nil
+		for _, channelId := range params.InChannels {
+			if slices.Contains(channelIds, channelId) {
+				inChannels = append(inChannels, channelId)
+			}
+		}
+	}
+	q.filters = append(q.filters, keywordFilter{field: fieldChannelId, values: inChannels})
+
+	if len(params.Extensions) > 0 {
+		q.filters = append(q.filters, keywordFilter{field: fieldExtension, values: lowerAll(params.Extensions)})
+	}
+
+	if len(params.ExcludedChannels) > 0 {
+		q.excludedFilters = append(q.excludedFilters, keywordFilter{field: fieldChannelId, values: params.ExcludedChannels})
+	}
+	if len(params.ExcludedUsers) > 0 {
+		q.excludedFilters = append(q.excludedFilters, keywordFilter{field: fieldUserId, values: params.ExcludedUsers})
+	}
+	if len(params.ExcludedExtensions) > 0 {
+		q.excludedFilters = append(q.excludedFilters, keywordFilter{field: fieldExtension, values: lowerAll(params.ExcludedExtensions)})
+	}
+
+	if params.OnDate != "" {
+		from, to := params.GetOnDateMillis()
+		q.ranges = append(q.ranges, numberRange{field: fieldCreateAt, from: from, to: to})
+	} else {
+		if params.AfterDate != "" {
+			q.ranges = append(q.ranges, numberRange{field: fieldCreateAt, from: params.GetAfterDateMillis(), to: math.MaxInt64})
+		}
+		if params.BeforeDate != "" {
+			q.ranges = append(q.ranges, numberRange{field: fieldCreateAt, from: math.MinInt64, to: params.GetBeforeDateMillis()})
+		}
+		if params.ExcludedDate != "" {
+			from, to := params.GetExcludedDateMillis()
+			q.excludedRanges = append(q.excludedRanges, numberRange{field: fieldCreateAt, from: from, to: to})
+		}
+		if params.ExcludedAfterDate != "" {
+			q.excludedRanges = append(q.excludedRanges, numberRange{field: fieldCreateAt, from: params.GetExcludedAfterDateMillis(), to: math.MaxInt64})
+		}
+		if params.ExcludedBeforeDate != "" {
+			q.excludedRanges = append(q.excludedRanges, numberRange{field: fieldCreateAt, from: math.MinInt64, to: params.GetExcludedBeforeDateMillis()})
+		}
+	}
+
+	return q
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, value := range values {
+		lowered[i] = strings.ToLower(value)
+	}
+	return lowered
+}
+
+// newestFirst sorts documents by creation time, newest first
+func newestFirst(docs []*document) {
+	slices.SortFunc(docs, func(a, b *document) int {
+		if c := cmp.Compare(b.Numbers[fieldCreateAt], a.Numbers[fieldCreateAt]); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Id, b.Id)
+	})
+}
+
+// pageOf returns the given page of docs
+func pageOf(docs []*document, page, perPage int) []*document {
+	start := page * perPage
+	if page < 0 || perPage <= 0 || start >= len(docs) {
+		return nil
+	}
+	return docs[start:min(start+perPage, len(docs))]
+}
+
+func ids(docs []*document) []string {
+	result := make([]string, len(docs))
+	for i, doc := range docs {
+		result[i] = doc.Id
+	}
+	return result
+}
@@ -0,0 +1,283 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package embeddedengine implements a search engine running inside the server, for
+// installations without an Elasticsearch cluster. Posts, files, channels and users are kept in
+// inverted indexes persisted under EmbeddedSearchSettings.IndexDir, and text is split into terms
+// by a pluggable analyzer, such as the cjk analyzer indexing Chinese, Japanese and Korean text
+// as bigrams.
+package embeddedengine
+
+import (
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+	"github.com/mattermost/mattermost/server/v8/platform/services/searchengine"
+)
+
+const (
+	EngineName = "embedded"
+
+	// indexVersion is increased whenever documents are indexed differently
+	indexVersion = 1
+
+	PostIndex    = "posts"
+	FileIndex    = "files"
+	ChannelIndex = "channels"
+	UserIndex    = "users"
+)
+
+var indexNames = []string{PostIndex, FileIndex, ChannelIndex, UserIndex}
+
+var _ searchengine.SearchEngineInterface = (*EmbeddedEngine)(nil)
+
+type EmbeddedEngine struct {
+	cfg    atomic.Pointer[model.Config]
+	logger mlog.LoggerIFace
+	ready  atomic.Bool
+
+	// mutex protects the indexes from being closed while in use
+	mutex    sync.RWMutex
+	posts    *index
+	files    *index
+	channels *index
+	users    *index
+}
+
+func NewEmbeddedEngine(cfg *model.Config, logger mlog.LoggerIFace) *EmbeddedEngine {
+	e := &EmbeddedEngine{
+		logger: logger,
+	}
+	e.cfg.Store(cfg)
+	return e
+}
+
+func (e *EmbeddedEngine) settings() *model.EmbeddedSearchSettings {
+	return &e.cfg.Load().EmbeddedSearchSettings
+}
+
+func (e *EmbeddedEngine) Start() *model.AppError {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.ready.Load() {
+		return nil
+	}
+
+	settings := e.settings()
+	analyzer, ok := GetAnalyzer(*settings.Analyzer)
+	if !ok {
+		return model.NewAppError("EmbeddedEngine.Start", "embeddedengine.unknown_analyzer.error", map[string]any{"Analyzer": *settings.Analyzer}, "", http.StatusInternalServerError)
+	}
+
+	opened := make(map[string]*index, len(indexNames))
+	for _, name := range indexNames {
+		idx, err := openIndex(*settings.IndexDir, name, analyzer)
+		if err != nil {
+			for _, idx := range opened {
+				idx.close()
+			}
+			return model.NewAppError("EmbeddedEngine.Start", "embeddedengine.start.error", nil, "", http.StatusInternalServerError).Wrap(err)
+		}
+		opened[name] = idx
+	}
+
+	e.posts = opened[PostIndex]
+	e.files = opened[FileIndex]
+	e.channels = opened[ChannelIndex]
+	e.users = opened[UserIndex]
+	e.ready.Store(true)
+
+	e.logger.Info("Started the embedded search engine",
+		mlog.String("index_dir", *settings.IndexDir),
+		mlog.String("analyzer", *settings.Analyzer),
+		mlog.Int("posts", e.posts.count()),
+		mlog.Int("files", e.files.count()),
+		mlog.Int("channels", e.channels.count()),
+		mlog.Int("users", e.users.count()),
+	)
+
+	return nil
+}
+
+func (e *EmbeddedEngine) Stop() *model.AppError {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.ready.Load() {
+		return nil
+	}
+	e.ready.Store(false)
+
+	var firstErr error
+	for _, idx := range []*index{e.posts, e.files, e.channels, e.users} {
+		if err := idx.close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	e.posts, e.files, e.channels, e.users = nil, nil, nil, nil
+
+	if firstErr != nil {
+		return model.NewAppError("EmbeddedEngine.Stop", "embeddedengine.stop.error", nil, "", http.StatusInternalServerError).Wrap(firstErr)
+	}
+
+	e.logger.Info("Stopped the embedded search engine")
+	return nil
+}
+
+func (e *EmbeddedEngine) GetFullVersion() string {
+	return "embedded-1"
+}
+
+func (e *EmbeddedEngine) GetVersion() int {
+	return indexVersion
+}
+
+func (e *EmbeddedEngine) GetPlugins() []string {
+	return []string{}
+}
+
+func (e *EmbeddedEngine) UpdateConfig(cfg *model.Config) {
+	e.cfg.Store(cfg)
+}
+
+func (e *EmbeddedEngine) GetName() string {
+	return EngineName
+}
+
+func (e *EmbeddedEngine) IsEnabled() bool {
+	return *e.settings().EnableIndexing
+}
+
+func (e *EmbeddedEngine) IsActive() bool {
+	return e.ready.Load() && *e.settings().EnableIndexing
+}
+
+func (e *EmbeddedEngine) IsIndexingEnabled() bool {
+	return e.IsActive()
+}
+
+func (e *EmbeddedEngine) IsSearchEnabled() bool {
+	return e.IsActive() && *e.settings().EnableSearching
+}
+
+func (e *EmbeddedEngine) IsAutocompletionEnabled() bool {
+	return e.IsActive() && *e.settings().EnableAutocomplete
+}
+
+// IsIndexingSync is false as changes are indexed in the background
+func (e *EmbeddedEngine) IsIndexingSync() bool {
+	return false
+}
+
+// checkStarted must be called with the mutex held
+func (e *EmbeddedEngine) checkStarted(where string) *model.AppError {
+	if !e.ready.Load() {
+		return model.NewAppError(where, "embeddedengine.not_started.error", nil, "", http.StatusInternalServerError)
+	}
+	return nil
+}
+
+func indexError(where string, err error) *model.AppError {
+	return model.NewAppError(where, "embeddedengine.index.error", nil, "", http.StatusInternalServerError).Wrap(err)
+}
+
+func (e *EmbeddedEngine) TestConfig(rctx request.CTX, cfg *model.Config) *model.AppError {
+	settings := cfg.EmbeddedSearchSettings
+	if _, ok := GetAnalyzer(*settings.Analyzer); !ok {
+		return model.NewAppError("EmbeddedEngine.TestConfig", "embeddedengine.unknown_analyzer.error", map[string]any{"Analyzer": *settings.Analyzer}, "", http.StatusBadRequest)
+	}
+
+	if err := os.MkdirAll(*settings.IndexDir, 0700); err != nil {
+		return model.NewAppError("EmbeddedEngine.TestConfig", "embeddedengine.test_config.index_dir.error", nil, "", http.StatusBadRequest).Wrap(err)
+	}
+	f, err := os.CreateTemp(*settings.IndexDir, ".test")
+	if err != nil {
+		return model.NewAppError("EmbeddedEngine.TestConfig", "embeddedengine.test_config.index_dir.error", nil, "", http.StatusBadRequest).Wrap(err)
+	}
+	f.Close()
+	os.Remove(f.Name())
+
+	return nil
+}
+
+func (e *EmbeddedEngine) PurgeIndexes(rctx request.CTX) *model.AppError {
+	return e.PurgeIndexList(rctx, indexNames)
+}
+
+func (e *EmbeddedEngine) PurgeIndexList(rctx request.CTX, indexes []string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.PurgeIndexList"); appErr != nil {
+		return appErr
+	}
+
+	byName := map[string]*index{PostIndex: e.posts, FileIndex: e.files, ChannelIndex: e.channels, UserIndex: e.users}
+	for _, name := range indexes {
+		if byName[name] == nil {
+			return model.NewAppError("EmbeddedEngine.PurgeIndexList", "embeddedengine.purge_list.unknown_index.error", map[string]any{"Index": name}, "", http.StatusBadRequest)
+		}
+	}
+
+	for _, name := range indexes {
+		if err := byName[name].purge(); err != nil {
+			return indexError("EmbeddedEngine.PurgeIndexList", err)
+		}
+		rctx.Logger().Info("Purged the embedded search index", mlog.String("index", name))
+	}
+
+	return nil
+}
+
+// RefreshIndexes makes sure every change made to the indexes is on disk
+func (e *EmbeddedEngine) RefreshIndexes(rctx request.CTX) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.RefreshIndexes"); appErr != nil {
+		return appErr
+	}
+
+	for _, idx := range []*index{e.posts, e.files, e.channels, e.users} {
+		if err := idx.sync(); err != nil {
+			return indexError("EmbeddedEngine.RefreshIndexes", err)
+		}
+	}
+
+	return nil
+}
+
+// DataRetentionDeleteIndexes removes the posts and files created before the cutoff
+func (e *EmbeddedEngine) DataRetentionDeleteIndexes(rctx request.CTX, cutoff time.Time) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.DataRetentionDeleteIndexes"); appErr != nil {
+		return appErr
+	}
+
+	deletedPosts, err := e.posts.deleteBefore(fieldCreateAt, cutoff.UnixMilli(), 0)
+	if err != nil {
+		return indexError("EmbeddedEngine.DataRetentionDeleteIndexes", err)
+	}
+
+	deletedFiles, err := e.files.deleteBefore(fieldCreateAt, cutoff.UnixMilli(), 0)
+	if err != nil {
+		return indexError("EmbeddedEngine.DataRetentionDeleteIndexes", err)
+	}
+
+	rctx.Logger().Info("Removed the posts and files past the retention period from the embedded search indexes",
+		mlog.Any("cutoff", cutoff),
+		mlog.Int("posts", deletedPosts),
+		mlog.Int("files", deletedFiles),
+	)
+
+	return nil
+}
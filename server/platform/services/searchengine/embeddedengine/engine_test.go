@@ -0,0 +1,366 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+)
+
+func setupEngine(t *testing.T, dir string) *EmbeddedEngine {
+	t.Helper()
+
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	cfg.EmbeddedSearchSettings.EnableIndexing = model.NewPointer(true)
+	cfg.EmbeddedSearchSettings.EnableSearching = model.NewPointer(true)
+	cfg.EmbeddedSearchSettings.EnableAutocomplete = model.NewPointer(true)
+	cfg.EmbeddedSearchSettings.IndexDir = model.NewPointer(dir)
+
+	engine := NewEmbeddedEngine(cfg, mlog.CreateConsoleTestLogger(t))
+	require.Nil(t, engine.Start())
+	t.Cleanup(func() {
+		require.Nil(t, engine.Stop())
+	})
+	return engine
+}
+
+func searchParams(terms string) []*model.SearchParams {
+	return model.ParseSearchParams(terms, 0)
+}
+
+func TestEngineLifecycle(t *testing.T) {
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	cfg.EmbeddedSearchSettings.IndexDir = model.NewPointer(t.TempDir())
+	engine := NewEmbeddedEngine(cfg, mlog.CreateConsoleTestLogger(t))
+
+	assert.False(t, engine.IsEnabled())
+	assert.False(t, engine.IsActive())
+
+	appErr := engine.IndexPost(&model.Post{Id: model.NewId()}, model.NewId())
+	require.NotNil(t, appErr)
+	assert.Equal(t, "embeddedengine.not_started.error", appErr.Id)
+
+	cfg.EmbeddedSearchSettings.Analyzer = model.NewPointer("unknown")
+	appErr = engine.Start()
+	require.NotNil(t, appErr)
+	assert.Equal(t, "embeddedengine.unknown_analyzer.error", appErr.Id)
+
+	cfg.EmbeddedSearchSettings.Analyzer = model.NewPointer(AnalyzerCJK)
+	cfg.EmbeddedSearchSettings.EnableIndexing = model.NewPointer(true)
+	require.Nil(t, engine.Start())
+	assert.True(t, engine.IsActive())
+	assert.True(t, engine.IsIndexingEnabled())
+	assert.False(t, engine.IsSearchEnabled())
+
+	require.Nil(t, engine.Stop())
+	assert.False(t, engine.IsActive())
+}
+
+func TestSearchPosts(t *testing.T) {
+	engine := setupEngine(t, t.TempDir())
+
+	teamId := model.NewId()
+	channel := &model.Channel{Id: model.NewId(), TeamId: teamId}
+	otherChannel := &model.Channel{Id: model.NewId(), TeamId: teamId}
+	userId := model.NewId()
+
+	post := func(channelId, message string, createAt int64) *model.Post {
+		p := &model.Post{Id: model.NewId(), ChannelId: channelId, UserId: userId, Message: message, CreateAt: createAt}
+		p.Hashtags, _ = model.ParseHashtags(message)
+		require.Nil(t, engine.IndexPost(p, teamId))
+		return p
+	}
+
+	english := post(channel.Id, "The release is ready for testing", 1000)
+	chinese := post(channel.Id, "我们明天下午开会讨论发布计划", 2000)
+	hashtag := post(channel.Id, "Deploying now #shipit", 3000)
+	hidden := post(otherChannel.Id, "The release of the hidden channel", 4000)
+	system := &model.Post{Id: model.NewId(), ChannelId: channel.Id, Message: "release joined", Type: model.PostTypeJoinChannel, CreateAt: 5000}
+	require.Nil(t, engine.IndexPost(system, teamId))
+
+	channels := model.ChannelList{channel}
+
+	t.Run("terms are highlighted", func(t *testing.T) {
+		ids, matches, appErr := engine.SearchPosts(channels, searchParams("release"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{english.Id}, ids)
+		assert.Equal(t, []string{"release"}, matches[english.Id])
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		ids, matches, appErr := engine.SearchPosts(channels, searchParams("test*"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{english.Id}, ids)
+		assert.Equal(t, []string{"testing"}, matches[english.Id])
+	})
+
+	t.Run("phrase", func(t *testing.T) {
+		ids, _, appErr := engine.SearchPosts(channels, searchParams(`"ready for testing"`), 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{english.Id}, ids)
+
+		ids, _, appErr = engine.SearchPosts(channels, searchParams(`"testing for ready"`), 0, 20)
+		require.Nil(t, appErr)
+		assert.Empty(t, ids)
+	})
+
+	t.Run("chinese", func(t *testing.T) {
+		ids, matches, appErr := engine.SearchPosts(channels, searchParams("开会"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{chinese.Id}, ids)
+		assert.Equal(t, []string{"开会"}, matches[chinese.Id])
+
+		ids, matches, appErr = engine.SearchPosts(channels, searchParams("发布计划"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{chinese.Id}, ids)
+		assert.Equal(t, []string{"发布计划"}, matches[chinese.Id])
+
+		ids, _, appErr = engine.SearchPosts(channels, searchParams("会"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{chinese.Id}, ids)
+
+		ids, _, appErr = engine.SearchPosts(channels, searchParams("计划发布"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Empty(t, ids)
+	})
+
+	t.Run("hashtags", func(t *testing.T) {
+		ids, matches, appErr := engine.SearchPosts(channels, searchParams("#ShipIt"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{hashtag.Id}, ids)
+		assert.Equal(t, []string{"#shipit"}, matches[hashtag.Id])
+	})
+
+	t.Run("channels the user can't see are ignored", func(t *testing.T) {
+		ids, _, appErr := engine.SearchPosts(channels, searchParams("hidden"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Empty(t, ids)
+
+		ids, _, appErr = engine.SearchPosts(model.ChannelList{channel, otherChannel}, searchParams("hidden"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{hidden.Id}, ids)
+	})
+
+	t.Run("excluded terms and paging", func(t *testing.T) {
+		ids, _, appErr := engine.SearchPosts(model.ChannelList{channel, otherChannel}, searchParams("release -hidden"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Equal(t, []string{english.Id}, ids)
+
+		ids, _, appErr = engine.SearchPosts(channels, searchParams("from:"+userId), 0, 1)
+		require.Nil(t, appErr)
+		require.Len(t, ids, 1)
+	})
+
+	t.Run("deleted posts are removed", func(t *testing.T) {
+		english.DeleteAt = model.GetMillis()
+		require.Nil(t, engine.IndexPost(english, teamId))
+
+		ids, _, appErr := engine.SearchPosts(channels, searchParams("release"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Empty(t, ids)
+	})
+
+	t.Run("posts of a channel are removed", func(t *testing.T) {
+		require.Nil(t, engine.DeleteChannelPosts(request.TestContext(t), otherChannel.Id))
+
+		ids, _, appErr := engine.SearchPosts(model.ChannelList{otherChannel}, searchParams("hidden"), 0, 20)
+		require.Nil(t, appErr)
+		assert.Empty(t, ids)
+	})
+}
+
+func TestSearchFiles(t *testing.T) {
+	engine := setupEngine(t, t.TempDir())
+
+	channel := &model.Channel{Id: model.NewId()}
+	file := &model.FileInfo{Id: model.NewId(), CreatorId: model.NewId(), PostId: model.NewId(), Name: "季度报告.pdf", Extension: "pdf", CreateAt: 1000}
+	require.Nil(t, engine.IndexFile(file, channel.Id))
+
+	ids, appErr := engine.SearchFiles(model.ChannelList{channel}, searchParams("报告"), 0, 20)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{file.Id}, ids)
+
+	// The extracted content is indexed once available and kept on later updates
+	file.Content = "quarterly revenue"
+	require.Nil(t, engine.IndexFile(file, channel.Id))
+	file.Content = ""
+	require.Nil(t, engine.IndexFile(file, channel.Id))
+
+	ids, appErr = engine.SearchFiles(model.ChannelList{channel}, searchParams("revenue ext:pdf"), 0, 20)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{file.Id}, ids)
+
+	ids, appErr = engine.SearchFiles(model.ChannelList{channel}, searchParams("revenue ext:docx"), 0, 20)
+	require.Nil(t, appErr)
+	assert.Empty(t, ids)
+
+	require.Nil(t, engine.DeletePostFiles(request.TestContext(t), file.PostId))
+	ids, appErr = engine.SearchFiles(model.ChannelList{channel}, searchParams("revenue"), 0, 20)
+	require.Nil(t, appErr)
+	assert.Empty(t, ids)
+}
+
+func TestSearchChannels(t *testing.T) {
+	engine := setupEngine(t, t.TempDir())
+	rctx := request.TestContext(t)
+
+	teamId := model.NewId()
+	member := model.NewId()
+	outsider := model.NewId()
+
+	public := &model.Channel{Id: model.NewId(), TeamId: teamId, Type: model.ChannelTypeOpen, Name: "release-planning", DisplayName: "发布计划"}
+	private := &model.Channel{Id: model.NewId(), TeamId: teamId, Type: model.ChannelTypePrivate, Name: "release-secret", DisplayName: "Release Secret"}
+	direct := &model.Channel{Id: model.NewId(), Type: model.ChannelTypeDirect, Name: "release-direct"}
+
+	require.Nil(t, engine.SyncBulkIndexChannels(rctx, []*model.Channel{public, private, direct}, func(channel *model.Channel) ([]string, error) {
+		return []string{member}, nil
+	}, []string{member, outsider}))
+
+	ids, appErr := engine.SearchChannels(teamId, member, "rel", false, false)
+	require.Nil(t, appErr)
+	assert.ElementsMatch(t, []string{public.Id, private.Id}, ids)
+
+	ids, appErr = engine.SearchChannels(teamId, outsider, "rel", false, false)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{public.Id}, ids)
+
+	ids, appErr = engine.SearchChannels(teamId, outsider, "计划", false, false)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{public.Id}, ids)
+
+	// Guests only find the channels they are a member of
+	require.Nil(t, engine.IndexUser(rctx, &model.User{Id: outsider, Username: "guest"}, []string{teamId}, []string{private.Id}))
+	ids, appErr = engine.SearchChannels(teamId, outsider, "rel", true, false)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{private.Id}, ids)
+
+	private.DeleteAt = model.GetMillis()
+	require.Nil(t, engine.IndexChannel(rctx, private, []string{member}, nil))
+	ids, appErr = engine.SearchChannels(teamId, member, "secret", false, false)
+	require.Nil(t, appErr)
+	assert.Empty(t, ids)
+	ids, appErr = engine.SearchChannels(teamId, member, "secret", false, true)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{private.Id}, ids)
+}
+
+func TestSearchUsers(t *testing.T) {
+	engine := setupEngine(t, t.TempDir())
+
+	teamId := model.NewId()
+	channelId := model.NewId()
+	alice := &model.UserForIndexing{Id: model.NewId(), Username: "alice", FirstName: "爱丽丝", LastName: "Wang", TeamsIds: []string{teamId}, ChannelsIds: []string{channelId}}
+	alfred := &model.UserForIndexing{Id: model.NewId(), Username: "alfred", Nickname: "Al", TeamsIds: []string{teamId}}
+	bob := &model.UserForIndexing{Id: model.NewId(), Username: "bob", TeamsIds: []string{model.NewId()}}
+	require.Nil(t, engine.BulkIndexUsers([]*model.UserForIndexing{alice, alfred, bob}))
+
+	ids, appErr := engine.SearchUsersInTeam(teamId, nil, "@al", &model.UserSearchOptions{})
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{alfred.Id, alice.Id}, ids)
+
+	ids, appErr = engine.SearchUsersInTeam(teamId, nil, "爱丽", &model.UserSearchOptions{})
+	require.Nil(t, appErr)
+	assert.Empty(t, ids)
+
+	ids, appErr = engine.SearchUsersInTeam(teamId, nil, "爱丽", &model.UserSearchOptions{AllowFullNames: true})
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{alice.Id}, ids)
+
+	ids, appErr = engine.SearchUsersInTeam(teamId, []string{channelId}, "al", &model.UserSearchOptions{})
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{alice.Id}, ids)
+
+	inChannel, notInChannel, appErr := engine.SearchUsersInChannel(teamId, channelId, nil, "al", &model.UserSearchOptions{})
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{alice.Id}, inChannel)
+	assert.Equal(t, []string{alfred.Id}, notInChannel)
+
+	ids, appErr = engine.SearchUsersInTeam(teamId, nil, "al", &model.UserSearchOptions{Limit: 1})
+	require.Nil(t, appErr)
+	assert.Len(t, ids, 1)
+
+	require.Nil(t, engine.DeleteUser(&model.User{Id: alfred.Id}))
+	ids, appErr = engine.SearchUsersInTeam(teamId, nil, "al", &model.UserSearchOptions{})
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{alice.Id}, ids)
+}
+
+func TestPersistence(t *testing.T) {
+	dir := t.TempDir()
+	teamId := model.NewId()
+	channel := &model.Channel{Id: model.NewId(), TeamId: teamId}
+	kept := &model.Post{Id: model.NewId(), ChannelId: channel.Id, Message: "持久化的消息", CreateAt: 1000}
+	removed := &model.Post{Id: model.NewId(), ChannelId: channel.Id, Message: "删除的消息", CreateAt: 2000}
+
+	cfg := &model.Config{}
+	cfg.SetDefaults()
+	cfg.EmbeddedSearchSettings.EnableIndexing = model.NewPointer(true)
+	cfg.EmbeddedSearchSettings.IndexDir = model.NewPointer(dir)
+	engine := NewEmbeddedEngine(cfg, mlog.CreateConsoleTestLogger(t))
+
+	require.Nil(t, engine.Start())
+	require.Nil(t, engine.IndexPost(kept, teamId))
+	require.Nil(t, engine.IndexPost(removed, teamId))
+	require.Nil(t, engine.DeletePost(removed))
+	require.Nil(t, engine.Stop())
+
+	// A write cut short by a crash is ignored
+	wal, err := os.OpenFile(filepath.Join(dir, PostIndex, "wal.jsonl"), os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = wal.WriteString(`{"op":"put","id":"`)
+	require.NoError(t, err)
+	require.NoError(t, wal.Close())
+
+	require.Nil(t, engine.Start())
+	defer func() {
+		require.Nil(t, engine.Stop())
+	}()
+
+	ids, _, appErr := engine.SearchPosts(model.ChannelList{channel}, searchParams("消息"), 0, 20)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{kept.Id}, ids)
+
+	require.Nil(t, engine.IndexPost(removed, teamId))
+	ids, _, appErr = engine.SearchPosts(model.ChannelList{channel}, searchParams("消息"), 0, 20)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{removed.Id, kept.Id}, ids)
+}
+
+func TestDataRetentionDeleteIndexes(t *testing.T) {
+	engine := setupEngine(t, t.TempDir())
+	rctx := request.TestContext(t)
+
+	teamId := model.NewId()
+	channel := &model.Channel{Id: model.NewId(), TeamId: teamId}
+	cutoff := time.UnixMilli(5000)
+
+	old := &model.Post{Id: model.NewId(), ChannelId: channel.Id, Message: "old message", CreateAt: 1000}
+	recent := &model.Post{Id: model.NewId(), ChannelId: channel.Id, Message: "recent message", CreateAt: 9000}
+	require.Nil(t, engine.IndexPost(old, teamId))
+	require.Nil(t, engine.IndexPost(recent, teamId))
+
+	oldFile := &model.FileInfo{Id: model.NewId(), Name: "old.txt", Extension: "txt", CreateAt: 1000}
+	require.Nil(t, engine.IndexFile(oldFile, channel.Id))
+
+	require.Nil(t, engine.DataRetentionDeleteIndexes(rctx, cutoff))
+
+	ids, _, appErr := engine.SearchPosts(model.ChannelList{channel}, searchParams("message"), 0, 20)
+	require.Nil(t, appErr)
+	assert.Equal(t, []string{recent.Id}, ids)
+
+	fileIds, appErr := engine.SearchFiles(model.ChannelList{channel}, searchParams("old"), 0, 20)
+	require.Nil(t, appErr)
+	assert.Empty(t, fileIds)
+}
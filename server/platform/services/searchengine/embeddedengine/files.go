@@ -0,0 +1,175 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+)
+
+// IndexFile indexes the name of a file and the text extracted from it. File infos are saved
+// before their text is extracted, so the text already indexed is kept when the file info comes
+// without it.
+func (e *EmbeddedEngine) IndexFile(file *model.FileInfo, channelId string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.IndexFile"); appErr != nil {
+		return appErr
+	}
+
+	doc := fileDocument(file, channelId)
+	if file.Content == "" {
+		if existing, ok := e.files.get(file.Id); ok {
+			doc.Text[fieldContent] = existing.Text[fieldContent]
+		}
+	}
+
+	var err error
+	if file.DeleteAt == 0 {
+		err = e.files.put(doc)
+	} else {
+		err = e.files.delete(file.Id)
+	}
+	if err != nil {
+		return indexError("EmbeddedEngine.IndexFile", err)
+	}
+
+	return nil
+}
+
+// BulkIndexFiles indexes a batch of files, removing those which shouldn't be found anymore
+func (e *EmbeddedEngine) BulkIndexFiles(files []*model.FileForIndexing) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.BulkIndexFiles"); appErr != nil {
+		return appErr
+	}
+
+	var docs []*document
+	var deleted []string
+	for _, file := range files {
+		if file.ShouldIndex() {
+			doc := fileDocument(&file.FileInfo, file.ChannelId)
+			doc.Text[fieldContent] = file.Content
+			docs = append(docs, doc)
+		} else {
+			deleted = append(deleted, file.Id)
+		}
+	}
+
+	if err := e.files.put(docs...); err != nil {
+		return indexError("EmbeddedEngine.BulkIndexFiles", err)
+	}
+	if err := e.files.delete(deleted...); err != nil {
+		return indexError("EmbeddedEngine.BulkIndexFiles", err)
+	}
+
+	return nil
+}
+
+// SearchFiles returns the ids of the files whose name or text match any of the search
+// parameters, newest first
+func (e *EmbeddedEngine) SearchFiles(channels model.ChannelList, searchParams []*model.SearchParams, page, perPage int) ([]string, *model.AppError) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.SearchFiles"); appErr != nil {
+		return nil, appErr
+	}
+
+	channelIds := make([]string, len(channels))
+	for i, channel := range channels {
+		channelIds[i] = channel.Id
+	}
+
+	found := make(map[string]*document)
+	for _, params := range searchParams {
+		q := paramsQuery(params, channelIds, []string{fieldName, fieldContent})
+		if q == nil {
+			continue
+		}
+
+		for _, doc := range e.files.search(q) {
+			found[doc.Id] = doc
+		}
+	}
+
+	docs := make([]*document, 0, len(found))
+	for _, doc := range found {
+		docs = append(docs, doc)
+	}
+	newestFirst(docs)
+
+	return ids(pageOf(docs, page, perPage)), nil
+}
+
+func (e *EmbeddedEngine) DeleteFile(fileID string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.DeleteFile"); appErr != nil {
+		return appErr
+	}
+
+	if err := e.files.delete(fileID); err != nil {
+		return indexError("EmbeddedEngine.DeleteFile", err)
+	}
+
+	return nil
+}
+
+func (e *EmbeddedEngine) DeletePostFiles(rctx request.CTX, postID string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.DeletePostFiles"); appErr != nil {
+		return appErr
+	}
+
+	deleted, err := e.files.deleteByKeyword(fieldPostId, postID)
+	if err != nil {
+		return indexError("EmbeddedEngine.DeletePostFiles", err)
+	}
+
+	rctx.Logger().Debug("Removed the files of the post from the embedded search index", mlog.String("post_id", postID), mlog.Int("files", deleted))
+	return nil
+}
+
+func (e *EmbeddedEngine) DeleteUserFiles(rctx request.CTX, userID string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.DeleteUserFiles"); appErr != nil {
+		return appErr
+	}
+
+	deleted, err := e.files.deleteByKeyword(fieldUserId, userID)
+	if err != nil {
+		return indexError("EmbeddedEngine.DeleteUserFiles", err)
+	}
+
+	rctx.Logger().Debug("Removed the files of the user from the embedded search index", mlog.String("user_id", userID), mlog.Int("files", deleted))
+	return nil
+}
+
+// DeleteFilesBatch removes up to limit files created before endTime, oldest first
+func (e *EmbeddedEngine) DeleteFilesBatch(rctx request.CTX, endTime, limit int64) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.DeleteFilesBatch"); appErr != nil {
+		return appErr
+	}
+
+	deleted, err := e.files.deleteBefore(fieldCreateAt, endTime, int(limit))
+	if err != nil {
+		return indexError("EmbeddedEngine.DeleteFilesBatch", err)
+	}
+
+	rctx.Logger().Debug("Removed a batch of files from the embedded search index", mlog.Int("end_time", endTime), mlog.Int("files", deleted))
+	return nil
+}
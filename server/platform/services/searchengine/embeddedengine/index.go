@@ -0,0 +1,526 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	snapshotFileName = "snapshot.jsonl"
+	walFileName      = "wal.jsonl"
+
+	// minCompactEntries is the number of changes logged before the log may be folded into
+	// the snapshot. Compaction also waits for the log to outgrow the index itself.
+	minCompactEntries = 10000
+
+	// maxLineSize bounds a single document read back from disk
+	maxLineSize = 64 * 1024 * 1024
+
+	// maxExpandedTerms bounds the number of terms a wildcard is expanded to
+	maxExpandedTerms = 1024
+
+	walOpPut    = "put"
+	walOpDelete = "delete"
+)
+
+// document is the unit stored in an index. Text fields are analyzed, keyword fields are
+// matched exactly and number fields are used for ranges and sorting.
+type document struct {
+	Id       string              `json:"id"`
+	Text     map[string]string   `json:"text,omitempty"`
+	Keywords map[string][]string `json:"keywords,omitempty"`
+	Numbers  map[string]int64    `json:"numbers,omitempty"`
+}
+
+func (d *document) keyword(field string) string {
+	if values := d.Keywords[field]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+type walEntry struct {
+	Op  string    `json:"op"`
+	Id  string    `json:"id,omitempty"`
+	Doc *document `json:"doc,omitempty"`
+}
+
+// index is an inverted index kept in memory and persisted to its own directory as a snapshot
+// of every document plus a log of the changes made since the snapshot was written. Terms are
+// not persisted: they are produced again by the analyzer when the index is opened, so that
+// changing the analyzer doesn't require anything but a restart.
+type index struct {
+	name     string
+	dir      string
+	analyzer Analyzer
+
+	mut      sync.RWMutex
+	docs     map[string]*document
+	terms    map[string]map[string]map[string][]int    // field -> term -> document id -> positions
+	keywords map[string]map[string]map[string]struct{} // field -> value -> document ids
+
+	wal        *os.File
+	walWriter  *bufio.Writer
+	walEntries int
+}
+
+// openIndex loads the index stored in dir/name, creating it if needed. An empty dir keeps the
+// index in memory only.
+func openIndex(dir, name string, analyzer Analyzer) (*index, error) {
+	idx := &index{
+		name:     name,
+		analyzer: analyzer,
+	}
+	idx.reset()
+
+	if dir == "" {
+		return idx, nil
+	}
+
+	idx.dir = filepath.Join(dir, name)
+	if err := os.MkdirAll(idx.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create the directory of index %s: %w", name, err)
+	}
+
+	if _, err := idx.readFile(snapshotFileName, func(line []byte) error {
+		var doc document
+		if err := json.Unmarshal(line, &doc); err != nil {
+			return err
+		}
+		idx.add(&doc)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to read the snapshot of index %s: %w", name, err)
+	}
+
+	walSize, err := idx.readFile(walFileName, func(line []byte) error {
+		var entry walEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return err
+		}
+		idx.apply(&entry)
+		idx.walEntries++
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to replay the log of index %s: %w", name, err)
+	}
+
+	wal, err := os.OpenFile(filepath.Join(idx.dir, walFileName), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the log of index %s: %w", name, err)
+	}
+	// Drop a truncated last entry so that new entries start on a line of their own
+	if err := wal.Truncate(walSize); err != nil {
+		wal.Close()
+		return nil, fmt.Errorf("failed to truncate the log of index %s: %w", name, err)
+	}
+	idx.wal = wal
+	idx.walWriter = bufio.NewWriter(wal)
+
+	return idx, nil
+}
+
+// readFile calls fn with every line of the given file and returns the size of the lines
+// read. A truncated last line, left behind by a crash while it was being written, is ignored.
+func (idx *index) readFile(name string, fn func(line []byte) error) (int64, error) {
+	f, err := os.Open(filepath.Join(idx.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var size int64
+	reader := bufio.NewReaderSize(f, 64*1024)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > maxLineSize {
+			return size, fmt.Errorf("line of %d bytes is too long", len(line))
+		}
+		if errors.Is(err, io.EOF) {
+			// Only complete lines end with a newline
+			return size, nil
+		} else if err != nil {
+			return size, err
+		}
+
+		if err := fn(line); err != nil {
+			return size, err
+		}
+		size += int64(len(line))
+	}
+}
+
+func (idx *index) reset() {
+	idx.docs = make(map[string]*document)
+	idx.terms = make(map[string]map[string]map[string][]int)
+	idx.keywords = make(map[string]map[string]map[string]struct{})
+}
+
+func (idx *index) apply(entry *walEntry) {
+	switch entry.Op {
+	case walOpPut:
+		if entry.Doc != nil {
+			idx.remove(entry.Doc.Id)
+			idx.add(entry.Doc)
+		}
+	case walOpDelete:
+		idx.remove(entry.Id)
+	}
+}
+
+func (idx *index) add(doc *document) {
+	idx.docs[doc.Id] = doc
+
+	for field, text := range doc.Text {
+		fieldTerms := idx.terms[field]
+		if fieldTerms == nil {
+			fieldTerms = make(map[string]map[string][]int)
+			idx.terms[field] = fieldTerms
+		}
+		for _, token := range idx.analyzer.Analyze(text) {
+			postings := fieldTerms[token.Term]
+			if postings == nil {
+				postings = make(map[string][]int)
+				fieldTerms[token.Term] = postings
+			}
+			postings[doc.Id] = append(postings[doc.Id], token.Position)
+		}
+	}
+
+	for field, values := range doc.Keywords {
+		fieldValues := idx.keywords[field]
+		if fieldValues == nil {
+			fieldValues = make(map[string]map[string]struct{})
+			idx.keywords[field] = fieldValues
+		}
+		for _, value := range values {
+			ids := fieldValues[value]
+			if ids == nil {
+				ids = make(map[string]struct{})
+				fieldValues[value] = ids
+			}
+			ids[doc.Id] = struct{}{}
+		}
+	}
+}
+
+func (idx *index) remove(id string) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	delete(idx.docs, id)
+
+	for field, text := range doc.Text {
+		fieldTerms := idx.terms[field]
+		for _, token := range idx.analyzer.Analyze(text) {
+			if postings := fieldTerms[token.Term]; postings != nil {
+				delete(postings, id)
+				if len(postings) == 0 {
+					delete(fieldTerms, token.Term)
+				}
+			}
+		}
+	}
+
+	for field, values := range doc.Keywords {
+		fieldValues := idx.keywords[field]
+		for _, value := range values {
+			if ids := fieldValues[value]; ids != nil {
+				delete(ids, id)
+				if len(ids) == 0 {
+					delete(fieldValues, value)
+				}
+			}
+		}
+	}
+}
+
+// log appends entries to the change log. Entries reach the disk when the log is flushed.
+func (idx *index) log(entries ...walEntry) error {
+	if idx.wal == nil {
+		return nil
+	}
+
+	for i := range entries {
+		line, err := json.Marshal(&entries[i])
+		if err != nil {
+			return err
+		}
+		if _, err := idx.walWriter.Write(append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	idx.walEntries += len(entries)
+
+	if idx.walEntries >= minCompactEntries && idx.walEntries > len(idx.docs) {
+		return idx.compact()
+	}
+	return idx.walWriter.Flush()
+}
+
+// compact writes a new snapshot and empties the change log
+func (idx *index) compact() error {
+	tmpPath := filepath.Join(idx.dir, snapshotFileName+".tmp")
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	writer := bufio.NewWriter(f)
+	encoder := json.NewEncoder(writer)
+	for _, doc := range idx.docs {
+		if err = encoder.Encode(doc); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write the snapshot of index %s: %w", idx.name, err)
+	}
+
+	if err := os.Rename(tmpPath, filepath.Join(idx.dir, snapshotFileName)); err != nil {
+		return fmt.Errorf("failed to replace the snapshot of index %s: %w", idx.name, err)
+	}
+
+	// Everything logged is in the snapshot now
+	idx.walWriter.Reset(idx.wal)
+	if err := idx.wal.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate the log of index %s: %w", idx.name, err)
+	}
+	idx.walEntries = 0
+
+	return nil
+}
+
+// put adds the documents to the index, replacing those with the same ids
+func (idx *index) put(docs ...*document) error {
+	idx.mut.Lock()
+	defer idx.mut.Unlock()
+
+	entries := make([]walEntry, 0, len(docs))
+	for _, doc := range docs {
+		idx.remove(doc.Id)
+		idx.add(doc)
+		entries = append(entries, walEntry{Op: walOpPut, Doc: doc})
+	}
+
+	return idx.log(entries...)
+}
+
+// delete removes the documents with the given ids
+func (idx *index) delete(ids ...string) error {
+	idx.mut.Lock()
+	defer idx.mut.Unlock()
+
+	entries := make([]walEntry, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := idx.docs[id]; !ok {
+			continue
+		}
+		idx.remove(id)
+		entries = append(entries, walEntry{Op: walOpDelete, Id: id})
+	}
+
+	return idx.log(entries...)
+}
+
+// deleteByKeyword removes the documents having the given value in a keyword field
+func (idx *index) deleteByKeyword(field, value string) (int, error) {
+	idx.mut.RLock()
+	ids := make([]string, 0, len(idx.keywords[field][value]))
+	for id := range idx.keywords[field][value] {
+		ids = append(ids, id)
+	}
+	idx.mut.RUnlock()
+
+	return len(ids), idx.delete(ids...)
+}
+
+// deleteBefore removes up to limit documents whose number field is lower than endTime,
+// oldest first. A limit of zero removes them all.
+func (idx *index) deleteBefore(field string, endTime int64, limit int) (int, error) {
+	idx.mut.RLock()
+	var docs []*document
+	for _, doc := range idx.docs {
+		if value, ok := doc.Numbers[field]; ok && value < endTime {
+			docs = append(docs, doc)
+		}
+	}
+	idx.mut.RUnlock()
+
+	if limit > 0 && len(docs) > limit {
+		slices.SortFunc(docs, func(a, b *document) int {
+			return cmp.Compare(a.Numbers[field], b.Numbers[field])
+		})
+		docs = docs[:limit]
+	}
+
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.Id
+	}
+	return len(ids), idx.delete(ids...)
+}
+
+// purge removes every document
+func (idx *index) purge() error {
+	idx.mut.Lock()
+	defer idx.mut.Unlock()
+
+	idx.reset()
+	if idx.wal == nil {
+		return nil
+	}
+
+	idx.walEntries = 0
+	idx.walWriter.Reset(idx.wal)
+	if err := idx.wal.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate the log of index %s: %w", idx.name, err)
+	}
+	if err := os.Remove(filepath.Join(idx.dir, snapshotFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove the snapshot of index %s: %w", idx.name, err)
+	}
+	return nil
+}
+
+// sync makes sure every change is on disk
+func (idx *index) sync() error {
+	idx.mut.Lock()
+	defer idx.mut.Unlock()
+
+	if idx.wal == nil {
+		return nil
+	}
+	if err := idx.walWriter.Flush(); err != nil {
+		return err
+	}
+	return idx.wal.Sync()
+}
+
+func (idx *index) close() error {
+	if err := idx.sync(); err != nil {
+		return err
+	}
+
+	idx.mut.Lock()
+	defer idx.mut.Unlock()
+
+	if idx.wal == nil {
+		return nil
+	}
+	err := idx.wal.Close()
+	idx.wal = nil
+	return err
+}
+
+func (idx *index) get(id string) (*document, bool) {
+	idx.mut.RLock()
+	defer idx.mut.RUnlock()
+
+	doc, ok := idx.docs[id]
+	return doc, ok
+}
+
+func (idx *index) count() int {
+	idx.mut.RLock()
+	defer idx.mut.RUnlock()
+
+	return len(idx.docs)
+}
+
+// termMatches reports whether an indexed term matches a term of the query. A lone CJK
+// character matches every term containing it, since CJK text is indexed as bigrams.
+func termMatches(term string, queryTerm string, prefix bool) bool {
+	if prefix {
+		return strings.HasPrefix(term, queryTerm)
+	}
+	if r, size := utf8.DecodeRuneInString(queryTerm); size == len(queryTerm) && isCJK(r) {
+		return strings.ContainsRune(term, r)
+	}
+	return term == queryTerm
+}
+
+// postings returns the positions of the terms matching a term of the query, by document id
+func (idx *index) postings(field, queryTerm string, prefix bool) map[string][]int {
+	fieldTerms := idx.terms[field]
+	if r, size := utf8.DecodeRuneInString(queryTerm); !prefix && !(size == len(queryTerm) && isCJK(r)) {
+		return fieldTerms[queryTerm]
+	}
+
+	merged := make(map[string][]int)
+	expanded := 0
+	for term, postings := range fieldTerms {
+		if !termMatches(term, queryTerm, prefix) {
+			continue
+		}
+		for id, positions := range postings {
+			merged[id] = append(merged[id], positions...)
+		}
+		if expanded++; expanded == maxExpandedTerms {
+			break
+		}
+	}
+	return merged
+}
+
+// matchPhrase returns the ids of the documents in which the terms of the query appear at
+// consecutive positions of the field. When prefix is set, the last term matches as a prefix.
+func (idx *index) matchPhrase(field string, tokens []Token, prefix bool) map[string]struct{} {
+	matched := make(map[string]struct{})
+	if len(tokens) == 0 {
+		return matched
+	}
+
+	postings := make([]map[string][]int, len(tokens))
+	for i, token := range tokens {
+		postings[i] = idx.postings(field, token.Term, prefix && i == len(tokens)-1)
+		if len(postings[i]) == 0 {
+			return matched
+		}
+	}
+
+	for id, starts := range postings[0] {
+		for _, start := range starts {
+			if phraseAt(postings, id, start) {
+				matched[id] = struct{}{}
+				break
+			}
+		}
+	}
+	return matched
+}
+
+func phraseAt(postings []map[string][]int, id string, start int) bool {
+	for i := 1; i < len(postings); i++ {
+		if !slices.Contains(postings[i][id], start+i) {
+			return false
+		}
+	}
+	return true
+}
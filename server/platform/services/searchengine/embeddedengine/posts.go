@@ -0,0 +1,160 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/mlog"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+)
+
+// shouldIndexPost reports whether a post can be found by searching, as in the database search
+func shouldIndexPost(post *model.Post) bool {
+	return post.DeleteAt == 0 && !post.IsSystemMessage()
+}
+
+func (e *EmbeddedEngine) IndexPost(post *model.Post, teamId string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.IndexPost"); appErr != nil {
+		return appErr
+	}
+
+	var err error
+	if shouldIndexPost(post) {
+		err = e.posts.put(postDocument(post, teamId))
+	} else {
+		err = e.posts.delete(post.Id)
+	}
+	if err != nil {
+		return indexError("EmbeddedEngine.IndexPost", err)
+	}
+
+	return nil
+}
+
+// BulkIndexPosts indexes a batch of posts, removing the deleted ones from the index
+func (e *EmbeddedEngine) BulkIndexPosts(posts []*model.PostForIndexing) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.BulkIndexPosts"); appErr != nil {
+		return appErr
+	}
+
+	var docs []*document
+	var deleted []string
+	for _, post := range posts {
+		if shouldIndexPost(&post.Post) {
+			docs = append(docs, postDocument(&post.Post, post.TeamId))
+		} else {
+			deleted = append(deleted, post.Id)
+		}
+	}
+
+	if err := e.posts.put(docs...); err != nil {
+		return indexError("EmbeddedEngine.BulkIndexPosts", err)
+	}
+	if err := e.posts.delete(deleted...); err != nil {
+		return indexError("EmbeddedEngine.BulkIndexPosts", err)
+	}
+
+	return nil
+}
+
+// SearchPosts returns the ids of the posts matching any of the search parameters, newest first,
+// along with the parts of their messages that matched
+func (e *EmbeddedEngine) SearchPosts(channels model.ChannelList, searchParams []*model.SearchParams, page, perPage int) ([]string, model.PostSearchMatches, *model.AppError) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.SearchPosts"); appErr != nil {
+		return nil, nil, appErr
+	}
+
+	channelIds := make([]string, len(channels))
+	for i, channel := range channels {
+		channelIds[i] = channel.Id
+	}
+
+	found := make(map[string]*document)
+	matchedTerms := make(map[string][]termQuery)
+	for _, params := range searchParams {
+		q := paramsQuery(params, channelIds, []string{fieldMessage})
+		if q == nil {
+			continue
+		}
+
+		for _, doc := range e.posts.search(q) {
+			found[doc.Id] = doc
+			matchedTerms[doc.Id] = append(matchedTerms[doc.Id], q.terms...)
+		}
+	}
+
+	docs := make([]*document, 0, len(found))
+	for _, doc := range found {
+		docs = append(docs, doc)
+	}
+	newestFirst(docs)
+	docs = pageOf(docs, page, perPage)
+
+	matches := make(model.PostSearchMatches, len(docs))
+	for _, doc := range docs {
+		if highlighted := e.posts.highlight(doc, fieldMessage, matchedTerms[doc.Id]); len(highlighted) > 0 {
+			matches[doc.Id] = highlighted
+		}
+	}
+
+	return ids(docs), matches, nil
+}
+
+func (e *EmbeddedEngine) DeletePost(post *model.Post) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.DeletePost"); appErr != nil {
+		return appErr
+	}
+
+	if err := e.posts.delete(post.Id); err != nil {
+		return indexError("EmbeddedEngine.DeletePost", err)
+	}
+
+	return nil
+}
+
+func (e *EmbeddedEngine) DeleteChannelPosts(rctx request.CTX, channelID string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.DeleteChannelPosts"); appErr != nil {
+		return appErr
+	}
+
+	deleted, err := e.posts.deleteByKeyword(fieldChannelId, channelID)
+	if err != nil {
+		return indexError("EmbeddedEngine.DeleteChannelPosts", err)
+	}
+
+	rctx.Logger().Debug("Removed the posts of the channel from the embedded search index", mlog.String("channel_id", channelID), mlog.Int("posts", deleted))
+	return nil
+}
+
+func (e *EmbeddedEngine) DeleteUserPosts(rctx request.CTX, userID string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.DeleteUserPosts"); appErr != nil {
+		return appErr
+	}
+
+	deleted, err := e.posts.deleteByKeyword(fieldUserId, userID)
+	if err != nil {
+		return indexError("EmbeddedEngine.DeleteUserPosts", err)
+	}
+
+	rctx.Logger().Debug("Removed the posts of the user from the embedded search index", mlog.String("user_id", userID), mlog.Int("posts", deleted))
+	return nil
+}
@@ -0,0 +1,241 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var termsRegex = regexp.MustCompile(`"[^"]*"\*?|\S+`)
+
+// termQuery is a word or quoted phrase of a search
+type termQuery struct {
+	text   string
+	prefix bool
+	// keyword is the keyword field matched exactly instead of the text fields, if any
+	keyword string
+}
+
+// parseTerms splits search terms into words and quoted phrases. A trailing * makes the
+// term match as a prefix.
+func parseTerms(terms string) []termQuery {
+	var result []termQuery
+	for _, match := range termsRegex.FindAllString(terms, -1) {
+		term := termQuery{text: match}
+		if strings.HasSuffix(term.text, "*") {
+			term.text = strings.TrimSuffix(term.text, "*")
+			term.prefix = true
+		}
+		term.text = strings.Trim(term.text, `"`)
+		if strings.TrimSpace(term.text) != "" {
+			result = append(result, term)
+		}
+	}
+	return result
+}
+
+// keywordTerms returns the terms matched exactly against the given keyword field
+func keywordTerms(terms, field string) []termQuery {
+	var result []termQuery
+	for term := range strings.FieldsSeq(terms) {
+		result = append(result, termQuery{text: strings.ToLower(term), keyword: field})
+	}
+	return result
+}
+
+type keywordFilter struct {
+	field  string
+	values []string
+}
+
+// numberRange matches the values of a number field between from and to, inclusive
+type numberRange struct {
+	field string
+	from  int64
+	to    int64
+}
+
+type query struct {
+	// fields are the text fields the terms are looked up in
+	fields          []string
+	terms           []termQuery
+	excludedTerms   []termQuery
+	anyTerm         bool
+	filters         []keywordFilter
+	excludedFilters []keywordFilter
+	ranges          []numberRange
+	excludedRanges  []numberRange
+}
+
+// matchTerm returns the ids of the documents matching the term in any of the fields. It
+// returns false if the term has nothing to search for.
+func (idx *index) matchTerm(fields []string, term termQuery) (map[string]struct{}, bool) {
+	if term.keyword != "" {
+		matched := make(map[string]struct{})
+		for id := range idx.keywords[term.keyword][term.text] {
+			matched[id] = struct{}{}
+		}
+		return matched, true
+	}
+
+	tokens := idx.analyzer.Analyze(term.text)
+	if len(tokens) == 0 {
+		return nil, false
+	}
+
+	matched := make(map[string]struct{})
+	for _, field := range fields {
+		for id := range idx.matchPhrase(field, tokens, term.prefix) {
+			matched[id] = struct{}{}
+		}
+	}
+	return matched, true
+}
+
+// search returns the documents matching the query, in no particular order
+func (idx *index) search(q *query) []*document {
+	idx.mut.RLock()
+	defer idx.mut.RUnlock()
+
+	var candidates map[string]struct{}
+	for _, term := range q.terms {
+		matched, ok := idx.matchTerm(q.fields, term)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case candidates == nil:
+			candidates = matched
+		case q.anyTerm:
+			for id := range matched {
+				candidates[id] = struct{}{}
+			}
+		default:
+			for id := range candidates {
+				if _, ok := matched[id]; !ok {
+					delete(candidates, id)
+				}
+			}
+		}
+	}
+
+	// Without terms, narrow the documents down with the first filter
+	if candidates == nil && len(q.filters) > 0 {
+		candidates = make(map[string]struct{})
+		for _, value := range q.filters[0].values {
+			for id := range idx.keywords[q.filters[0].field][value] {
+				candidates[id] = struct{}{}
+			}
+		}
+	}
+
+	excluded := make(map[string]struct{})
+	for _, term := range q.excludedTerms {
+		matched, _ := idx.matchTerm(q.fields, term)
+		for id := range matched {
+			excluded[id] = struct{}{}
+		}
+	}
+
+	var docs []*document
+	accept := func(doc *document) {
+		if _, ok := excluded[doc.Id]; !ok && q.matchesFilters(doc) {
+			docs = append(docs, doc)
+		}
+	}
+
+	if candidates == nil {
+		for _, doc := range idx.docs {
+			accept(doc)
+		}
+	} else {
+		for id := range candidates {
+			if doc, ok := idx.docs[id]; ok {
+				accept(doc)
+			}
+		}
+	}
+
+	return docs
+}
+
+func (q *query) matchesFilters(doc *document) bool {
+	for _, filter := range q.filters {
+		if !hasAnyKeyword(doc, filter) {
+			return false
+		}
+	}
+	for _, filter := range q.excludedFilters {
+		if hasAnyKeyword(doc, filter) {
+			return false
+		}
+	}
+	for _, r := range q.ranges {
+		if value := doc.Numbers[r.field]; value < r.from || value > r.to {
+			return false
+		}
+	}
+	for _, r := range q.excludedRanges {
+		if value := doc.Numbers[r.field]; value >= r.from && value <= r.to {
+			return false
+		}
+	}
+	return true
+}
+
+func hasAnyKeyword(doc *document, filter keywordFilter) bool {
+	for _, value := range doc.Keywords[filter.field] {
+		if slices.Contains(filter.values, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// highlight returns the parts of the text of a field matched by the terms, as written in it
+func (idx *index) highlight(doc *document, field string, terms []termQuery) []string {
+	text := doc.Text[field]
+	tokens := idx.analyzer.Analyze(text)
+
+	var matches []string
+	for _, term := range terms {
+		if term.keyword != "" {
+			// Keywords such as hashtags are highlighted where they appear in the text
+			lowerText := strings.ToLower(text)
+			if i := strings.Index(lowerText, term.text); i != -1 && len(lowerText) == len(text) && slices.Contains(doc.Keywords[term.keyword], term.text) {
+				if match := text[i : i+len(term.text)]; !slices.Contains(matches, match) {
+					matches = append(matches, match)
+				}
+			}
+			continue
+		}
+
+		queryTokens := idx.analyzer.Analyze(term.text)
+		if len(queryTokens) == 0 {
+			continue
+		}
+
+		for start := 0; start+len(queryTokens) <= len(tokens); start++ {
+			matched := true
+			for i, queryToken := range queryTokens {
+				if !termMatches(tokens[start+i].Term, queryToken.Term, term.prefix && i == len(queryTokens)-1) {
+					matched = false
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+
+			match := text[tokens[start].Start:tokens[start+len(queryTokens)-1].End]
+			if !slices.Contains(matches, match) {
+				matches = append(matches, match)
+			}
+		}
+	}
+	return matches
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package embeddedengine
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/shared/request"
+)
+
+func (e *EmbeddedEngine) IndexUser(rctx request.CTX, user *model.User, teamsIds, channelsIds []string) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.IndexUser"); appErr != nil {
+		return appErr
+	}
+
+	doc := userDocument(&model.UserForIndexing{
+		Id:          user.Id,
+		Username:    user.Username,
+		Nickname:    user.Nickname,
+		FirstName:   user.FirstName,
+		LastName:    user.LastName,
+		Roles:       user.Roles,
+		CreateAt:    user.CreateAt,
+		DeleteAt:    user.DeleteAt,
+		TeamsIds:    teamsIds,
+		ChannelsIds: channelsIds,
+	})
+	if err := e.users.put(doc); err != nil {
+		return indexError("EmbeddedEngine.IndexUser", err)
+	}
+
+	return nil
+}
+
+func (e *EmbeddedEngine) BulkIndexUsers(users []*model.UserForIndexing) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.BulkIndexUsers"); appErr != nil {
+		return appErr
+	}
+
+	docs := make([]*document, len(users))
+	for i, user := range users {
+		docs[i] = userDocument(user)
+	}
+	if err := e.users.put(docs...); err != nil {
+		return indexError("EmbeddedEngine.BulkIndexUsers", err)
+	}
+
+	return nil
+}
+
+// searchUsers returns the users of the team matching every word of the term, sorted by username
+func (e *EmbeddedEngine) searchUsers(teamId string, restrictedToChannels []string, term string, options *model.UserSearchOptions) []*document {
+	fields := []string{fieldUsername, fieldNickname}
+	if options.AllowFullNames {
+		fields = append(fields, fieldFirstName, fieldLastName)
+	}
+
+	q := &query{fields: fields}
+	for word := range strings.FieldsSeq(strings.TrimPrefix(term, "@")) {
+		q.terms = append(q.terms, termQuery{text: word, prefix: true})
+	}
+	if teamId != "" {
+		q.filters = append(q.filters, keywordFilter{field: fieldTeamId, values: []string{teamId}})
+	}
+	if restrictedToChannels != nil {
+		q.filters = append(q.filters, keywordFilter{field: fieldChannelId, values: restrictedToChannels})
+	}
+	if options.Role != "" {
+		q.filters = append(q.filters, keywordFilter{field: fieldRoles, values: []string{options.Role}})
+	}
+	if len(options.Roles) > 0 {
+		q.filters = append(q.filters, keywordFilter{field: fieldRoles, values: options.Roles})
+	}
+	if !options.AllowInactive {
+		q.ranges = append(q.ranges, numberRange{field: fieldDeleteAt, from: 0, to: 0})
+	}
+
+	docs := e.users.search(q)
+	slices.SortFunc(docs, func(a, b *document) int {
+		return strings.Compare(a.Text[fieldUsername], b.Text[fieldUsername])
+	})
+	return docs
+}
+
+func userSearchLimit(options *model.UserSearchOptions) int {
+	if options.Limit > 0 {
+		return options.Limit
+	}
+	return model.UserSearchDefaultLimit
+}
+
+func (e *EmbeddedEngine) SearchUsersInTeam(teamId string, restrictedToChannels []string, term string, options *model.UserSearchOptions) ([]string, *model.AppError) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.SearchUsersInTeam"); appErr != nil {
+		return nil, appErr
+	}
+
+	docs := e.searchUsers(teamId, restrictedToChannels, term, options)
+	return ids(pageOf(docs, 0, userSearchLimit(options))), nil
+}
+
+// SearchUsersInChannel returns the users of the team matching the term, split between the members
+// of the channel and the others
+func (e *EmbeddedEngine) SearchUsersInChannel(teamId, channelId string, restrictedToChannels []string, term string, options *model.UserSearchOptions) ([]string, []string, *model.AppError) {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.SearchUsersInChannel"); appErr != nil {
+		return nil, nil, appErr
+	}
+
+	limit := userSearchLimit(options)
+	inChannel := []string{}
+	notInChannel := []string{}
+	for _, doc := range e.searchUsers(teamId, restrictedToChannels, term, options) {
+		if slices.Contains(doc.Keywords[fieldChannelId], channelId) {
+			if len(inChannel) < limit {
+				inChannel = append(inChannel, doc.Id)
+			}
+		} else if len(notInChannel) < limit {
+			notInChannel = append(notInChannel, doc.Id)
+		}
+	}
+
+	return inChannel, notInChannel, nil
+}
+
+func (e *EmbeddedEngine) DeleteUser(user *model.User) *model.AppError {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+
+	if appErr := e.checkStarted("EmbeddedEngine.DeleteUser"); appErr != nil {
+		return appErr
+	}
+
+	if err := e.users.delete(user.Id); err != nil {
+		return indexError("EmbeddedEngine.DeleteUser", err)
+	}
+
+	return nil
+}
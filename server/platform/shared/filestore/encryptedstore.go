@@ -0,0 +1,1008 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package filestore
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/fnv"
+	"io"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+// Encrypted files start with a header holding the data key of the file, wrapped with one of the
+// master keys, followed by one segment per write or append. A segment holds the content split in
+// chunks sealed independently with the data key, followed by the size of that content. Every chunk
+// but the last one of a segment holds encryptionChunkSize bytes, which is what allows seeking.
+//
+// The chunks are numbered across the segments and authenticated along with the file id and, for the
+// last chunk of a segment, the size of the segment. This way chunks can't be reordered, moved to
+// another file or cut short. Only whole segments can be removed from the end of a file, which
+// restores the file as it was before an append.
+//
+//	header:  magic (5) | version (1) | file id (16) | master key id (8) | nonce (12) | wrapped data key (48)
+//	chunk:   AES-GCM(data key, nonce = chunk index, plaintext, file id | last | segment size if last) (up to encryptionChunkSize + 16)
+//	trailer: segment size (8)
+const (
+	encryptionMagic     = "MMENC"
+	encryptionVersion   = 1
+	encryptionChunkSize = 64 * 1024
+
+	masterKeySize         = 32
+	masterKeyIdSize       = 8
+	dataKeySize           = 32
+	encryptionFileIdSize  = 16
+	encryptionNonceSize   = 12
+	encryptionTagSize     = 16
+	encryptionTrailerSize = 8
+
+	encryptionBindingSize      = len(encryptionMagic) + 1 + encryptionFileIdSize
+	encryptionHeaderPrefixSize = encryptionBindingSize + masterKeyIdSize
+	encryptionHeaderSize       = encryptionHeaderPrefixSize + encryptionNonceSize + dataKeySize + encryptionTagSize
+	encryptionRecordSize       = encryptionChunkSize + encryptionTagSize
+
+	// encryptionPathLocks is the number of locks the paths being appended to or rewritten are
+	// spread over.
+	encryptionPathLocks = 64
+)
+
+var (
+	_ FileBackend = (*EncryptedFileBackend)(nil)
+)
+
+type masterKey struct {
+	id   []byte
+	aead cipher.AEAD
+}
+
+func newMasterKey(key []byte) (*masterKey, error) {
+	if len(key) != masterKeySize {
+		return nil, errors.Errorf("encryption keys must be %d bytes long, got %d", masterKeySize, len(key))
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(key)
+	return &masterKey{id: sum[:masterKeyIdSize], aead: aead}, nil
+}
+
+// EncryptedFileBackend decorates a FileBackend to encrypt the files at rest with AES-GCM. Each
+// file is encrypted with its own data key, wrapped with the current master key. Files written by
+// the underlying backend before encryption was enabled are only read when explicitly allowed, while
+// migrating them.
+//
+// Since the stored objects are encrypted, it doesn't generate public links even when the
+// underlying backend does.
+type EncryptedFileBackend struct {
+	backend          FileBackend
+	current          *masterKey
+	keys             map[string]*masterKey
+	allowUnencrypted bool
+
+	// locks serialize the appends and the rewrites of a path on this server.
+	locks [encryptionPathLocks]sync.Mutex
+}
+
+// NewEncryptedFileBackend returns a FileBackend encrypting the files stored in backend. The first
+// master key is used to wrap the data keys of new files, while the others are only used to read
+// files written before a key rotation. Unless allowUnencrypted is set, files that aren't encrypted
+// can't be read.
+func NewEncryptedFileBackend(backend FileBackend, masterKeys [][]byte, allowUnencrypted bool) (*EncryptedFileBackend, error) {
+	if len(masterKeys) == 0 {
+		return nil, errors.New("missing encryption key")
+	}
+
+	b := &EncryptedFileBackend{
+		backend:          backend,
+		keys:             make(map[string]*masterKey, len(masterKeys)),
+		allowUnencrypted: allowUnencrypted,
+	}
+	for i, key := range masterKeys {
+		mk, err := newMasterKey(key)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid encryption key %d", i)
+		}
+		if i == 0 {
+			b.current = mk
+		}
+		b.keys[string(mk.id)] = mk
+	}
+
+	return b, nil
+}
+
+// ParseEncryptionKeys decodes a list of base64 encoded master keys separated by commas or
+// whitespace, the current key first.
+func ParseEncryptionKeys(s string) ([][]byte, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+
+	keys := make([][]byte, 0, len(fields))
+	for i, field := range fields {
+		key, err := base64.StdEncoding.DecodeString(field)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decode encryption key %d", i)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// UnwrapFileBackend returns the driver backend of a possibly decorated FileBackend, for the
+// callers needing driver specific operations.
+func UnwrapFileBackend(backend FileBackend) FileBackend {
+	if b, ok := backend.(*EncryptedFileBackend); ok {
+		return b.Unwrap()
+	}
+	return backend
+}
+
+// Unwrap returns the decorated backend.
+func (b *EncryptedFileBackend) Unwrap() FileBackend {
+	return b.backend
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create the cipher")
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create the cipher")
+	}
+	return aead, nil
+}
+
+func chunkNonce(index uint64) []byte {
+	nonce := make([]byte, encryptionNonceSize)
+	binary.BigEndian.PutUint64(nonce[encryptionNonceSize-8:], index)
+	return nonce
+}
+
+// chunkAAD returns the additional data authenticated with a chunk, binding it to its file and, for
+// the last chunk of a segment, to the size of the segment.
+func chunkAAD(binding []byte, last bool, segmentSize int64) []byte {
+	aad := make([]byte, 0, len(binding)+1+encryptionTrailerSize)
+	aad = append(aad, binding...)
+	if !last {
+		return append(aad, 0)
+	}
+	aad = append(aad, 1)
+	return binary.BigEndian.AppendUint64(aad, uint64(segmentSize))
+}
+
+// segmentChunks returns the number of chunks of a segment holding size bytes, empty segments
+// holding a single empty chunk.
+func segmentChunks(size int64) int64 {
+	if size == 0 {
+		return 1
+	}
+	return (size + encryptionChunkSize - 1) / encryptionChunkSize
+}
+
+// segmentStoredSize returns the size of a segment holding size bytes once encrypted, trailer
+// included.
+func segmentStoredSize(size int64) int64 {
+	return size + segmentChunks(size)*encryptionTagSize + encryptionTrailerSize
+}
+
+func isEncryptedHeader(header []byte) bool {
+	return len(header) > len(encryptionMagic) && bytes.HasPrefix(header, []byte(encryptionMagic))
+}
+
+func errUnencryptedFile(path string) error {
+	return errors.Errorf("file %s is not encrypted", path)
+}
+
+// lockPath locks path against the other appends and rewrites done by this server, returning the
+// function unlocking it.
+func (b *EncryptedFileBackend) lockPath(path string) func() {
+	h := fnv.New32a()
+	h.Write([]byte(path))
+	mut := &b.locks[h.Sum32()%encryptionPathLocks]
+	mut.Lock()
+	return mut.Unlock
+}
+
+// sealDataKey returns the header of the file fileId encrypted with dataKey, wrapped with the
+// current key.
+func (b *EncryptedFileBackend) sealDataKey(fileId, dataKey []byte) ([]byte, error) {
+	header := make([]byte, 0, encryptionHeaderSize)
+	header = append(header, encryptionMagic...)
+	header = append(header, encryptionVersion)
+	header = append(header, fileId...)
+	header = append(header, b.current.id...)
+
+	nonce := make([]byte, encryptionNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "unable to generate the nonce")
+	}
+	header = append(header, nonce...)
+
+	return b.current.aead.Seal(header, nonce, dataKey, header[:encryptionHeaderPrefixSize]), nil
+}
+
+// openDataKey returns the data key wrapped in the header of an encrypted file.
+func (b *EncryptedFileBackend) openDataKey(header []byte) ([]byte, error) {
+	if len(header) < encryptionHeaderSize {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	if version := header[len(encryptionMagic)]; version != encryptionVersion {
+		return nil, errors.Errorf("unsupported encryption version %d", version)
+	}
+
+	id := header[encryptionBindingSize:encryptionHeaderPrefixSize]
+	key, ok := b.keys[string(id)]
+	if !ok {
+		return nil, errors.New("the file is encrypted with an unknown key")
+	}
+
+	nonce := header[encryptionHeaderPrefixSize : encryptionHeaderPrefixSize+encryptionNonceSize]
+	dataKey, err := key.aead.Open(nil, nonce, header[encryptionHeaderPrefixSize+encryptionNonceSize:encryptionHeaderSize], header[:encryptionHeaderPrefixSize])
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to unwrap the data key")
+	}
+	return dataKey, nil
+}
+
+// open opens a file and reads its encryption header, returning false if the file isn't encrypted.
+// The returned reader is positioned after the header of encrypted files, and at the start of the
+// others.
+func (b *EncryptedFileBackend) open(path string) (ReadCloseSeeker, []byte, bool, error) {
+	r, err := b.backend.Reader(path)
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	header := make([]byte, encryptionHeaderSize)
+	n, err := io.ReadFull(r, header)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		r.Close()
+		return nil, nil, false, errors.Wrapf(err, "unable to read file %s", path)
+	}
+
+	if !isEncryptedHeader(header[:n]) {
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			r.Close()
+			return nil, nil, false, errors.Wrapf(err, "unable to read file %s", path)
+		}
+		return r, nil, false, nil
+	}
+	return r, header[:n], true, nil
+}
+
+// newDecryptingReader returns a reader of the content of the encrypted file src, of the given
+// header.
+func (b *EncryptedFileBackend) newDecryptingReader(src ReadCloseSeeker, header []byte) (*decryptingReader, error) {
+	dataKey, err := b.openDataKey(header)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return newDecryptingReader(src, aead, bytes.Clone(header[:encryptionBindingSize])), nil
+}
+
+// newEncryptingReader returns a reader of the encrypted form of r, header included, under a new
+// file id and data key.
+func (b *EncryptedFileBackend) newEncryptingReader(r io.Reader) (*encryptingReader, error) {
+	fileId := make([]byte, encryptionFileIdSize)
+	if _, err := rand.Read(fileId); err != nil {
+		return nil, errors.Wrap(err, "unable to generate the file id")
+	}
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, errors.Wrap(err, "unable to generate the data key")
+	}
+
+	header, err := b.sealDataKey(fileId, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	er := newEncryptingReader(r, aead, bytes.Clone(header[:encryptionBindingSize]), 0)
+	er.pending = header
+	return er, nil
+}
+
+func (b *EncryptedFileBackend) DriverName() string {
+	return b.backend.DriverName()
+}
+
+func (b *EncryptedFileBackend) TestConnection() error {
+	return b.backend.TestConnection()
+}
+
+func (b *EncryptedFileBackend) Reader(path string) (ReadCloseSeeker, error) {
+	r, header, encrypted, err := b.open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !encrypted {
+		if !b.allowUnencrypted {
+			r.Close()
+			return nil, errUnencryptedFile(path)
+		}
+		return r, nil
+	}
+
+	dr, err := b.newDecryptingReader(r, header)
+	if err != nil {
+		r.Close()
+		return nil, errors.Wrapf(err, "unable to decrypt file %s", path)
+	}
+	return dr, nil
+}
+
+func (b *EncryptedFileBackend) ReadFile(path string) ([]byte, error) {
+	r, err := b.Reader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to read file %s", path)
+	}
+	return data, nil
+}
+
+func (b *EncryptedFileBackend) FileExists(path string) (bool, error) {
+	return b.backend.FileExists(path)
+}
+
+func (b *EncryptedFileBackend) FileSize(path string) (int64, error) {
+	r, err := b.Reader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	dr, ok := r.(*decryptingReader)
+	if !ok {
+		return b.backend.FileSize(path)
+	}
+
+	size, err := dr.Size()
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to get file size for %s", path)
+	}
+	return size, nil
+}
+
+func (b *EncryptedFileBackend) FileModTime(path string) (time.Time, error) {
+	return b.backend.FileModTime(path)
+}
+
+// CopyFile copies the encrypted file as is, the data key being stored within.
+func (b *EncryptedFileBackend) CopyFile(oldPath, newPath string) error {
+	return b.backend.CopyFile(oldPath, newPath)
+}
+
+func (b *EncryptedFileBackend) MoveFile(oldPath, newPath string) error {
+	return b.backend.MoveFile(oldPath, newPath)
+}
+
+func (b *EncryptedFileBackend) WriteFile(fr io.Reader, path string) (int64, error) {
+	return b.writeFile(context.Background(), fr, path, b.backend.WriteFile)
+}
+
+// WriteFileContext writes the file, stopping once ctx is done even when the underlying backend
+// doesn't support contexts.
+func (b *EncryptedFileBackend) WriteFileContext(ctx context.Context, fr io.Reader, path string) (int64, error) {
+	return b.writeFile(ctx, fr, path, func(r io.Reader, path string) (int64, error) {
+		return TryWriteFileContext(ctx, b.backend, r, path)
+	})
+}
+
+func (b *EncryptedFileBackend) writeFile(ctx context.Context, fr io.Reader, path string, write func(io.Reader, string) (int64, error)) (int64, error) {
+	er, err := b.newEncryptingReader(fr)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to encrypt the file %s", path)
+	}
+	er.ctx = ctx
+
+	if _, err := write(er, path); err != nil {
+		return 0, err
+	}
+	return er.read, nil
+}
+
+// AppendFile appends the data to the file as a new segment, leaving the existing ones untouched.
+func (b *EncryptedFileBackend) AppendFile(fr io.Reader, path string) (int64, error) {
+	unlock := b.lockPath(path)
+	defer unlock()
+
+	r, header, encrypted, err := b.open(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to find the file %s to append the data", path)
+	}
+	if !encrypted {
+		r.Close()
+		if !b.allowUnencrypted {
+			return 0, errUnencryptedFile(path)
+		}
+		return b.appendToUnencryptedFile(fr, path)
+	}
+
+	dr, err := b.newDecryptingReader(r, header)
+	if err != nil {
+		r.Close()
+		return 0, errors.Wrapf(err, "unable to decrypt file %s", path)
+	}
+	index, err := dr.chunkCount()
+	dr.Close()
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable append the data in the file %s", path)
+	}
+
+	er := newEncryptingReader(fr, dr.aead, dr.binding, uint64(index))
+	if _, err := b.backend.AppendFile(er, path); err != nil {
+		return 0, err
+	}
+	return er.read, nil
+}
+
+// appendToUnencryptedFile encrypts a file stored before encryption was enabled along with the
+// appended data.
+func (b *EncryptedFileBackend) appendToUnencryptedFile(fr io.Reader, path string) (int64, error) {
+	size, err := b.backend.FileSize(path)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to find the file %s to append the data", path)
+	}
+
+	r, err := b.backend.Reader(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	er, err := b.newEncryptingReader(io.MultiReader(r, fr))
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to encrypt the file %s", path)
+	}
+
+	if err := b.replaceFile(er, path, size); err != nil {
+		return 0, errors.Wrapf(err, "unable append the data in the file %s", path)
+	}
+	return er.read - size, nil
+}
+
+// replaceFile writes r next to path before moving it in place, so that path can be read while
+// writing r. Since the lock of the path only covers this server, the file isn't replaced if its
+// stored size changed from size meanwhile, as another server appended data to it.
+func (b *EncryptedFileBackend) replaceFile(r io.Reader, path string, size int64) error {
+	tmpPath := path + ".tmp-" + model.NewId()
+	if _, err := b.backend.WriteFile(r, tmpPath); err != nil {
+		b.backend.RemoveFile(tmpPath)
+		return err
+	}
+
+	current, err := b.backend.FileSize(path)
+	if err == nil && current != size {
+		err = errors.Errorf("file %s was modified while being rewritten", path)
+	}
+	if err != nil {
+		b.backend.RemoveFile(tmpPath)
+		return err
+	}
+
+	if err := b.backend.MoveFile(tmpPath, path); err != nil {
+		b.backend.RemoveFile(tmpPath)
+		return err
+	}
+	return nil
+}
+
+func (b *EncryptedFileBackend) RemoveFile(path string) error {
+	return b.backend.RemoveFile(path)
+}
+
+func (b *EncryptedFileBackend) ListDirectory(path string) ([]string, error) {
+	return b.backend.ListDirectory(path)
+}
+
+func (b *EncryptedFileBackend) ListDirectoryRecursively(path string) ([]string, error) {
+	return b.backend.ListDirectoryRecursively(path)
+}
+
+func (b *EncryptedFileBackend) RemoveDirectory(path string) error {
+	return b.backend.RemoveDirectory(path)
+}
+
+// ZipReader will create a zip of path, decrypting its files. If path is a single file, it will
+// zip the single file. If deflate is true, the contents will be compressed. It will stream the
+// zip to io.ReadCloser.
+func (b *EncryptedFileBackend) ZipReader(path string, deflate bool) (io.ReadCloser, error) {
+	deflateMethod := zip.Store
+	if deflate {
+		deflateMethod = zip.Deflate
+	}
+
+	stripPath := strings.TrimSuffix(path, "/") + "/"
+	paths, err := b.backend.ListDirectoryRecursively(path)
+	if err != nil || len(paths) == 0 {
+		if !b.isFile(path) {
+			// There is nothing to decrypt, so the backend handles missing paths and empty directories.
+			return b.backend.ZipReader(path, deflate)
+		}
+
+		// We want the zipped file to be at the root of the zip.
+		paths = []string{path}
+		stripPath = filepath.Dir(path) + "/"
+	}
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		defer pw.Close()
+
+		zipWriter := zip.NewWriter(pw)
+		defer zipWriter.Close()
+
+		for _, p := range paths {
+			if err := b.copyFileToZipWriter(zipWriter, p, strings.TrimPrefix(filepath.ToSlash(p), stripPath), deflateMethod); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	return pr, nil
+}
+
+func (b *EncryptedFileBackend) isFile(path string) bool {
+	r, err := b.backend.Reader(path)
+	if err != nil {
+		return false
+	}
+	defer r.Close()
+
+	// Directories can be opened on the local driver, but not read.
+	_, err = r.Read(make([]byte, 1))
+	return err == nil || err == io.EOF
+}
+
+func (b *EncryptedFileBackend) copyFileToZipWriter(zipWriter *zip.Writer, path, name string, deflateMethod uint16) error {
+	modTime, err := b.backend.FileModTime(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create zip entry for %s", path)
+	}
+
+	header := &zip.FileHeader{
+		Name:     name,
+		Method:   deflateMethod,
+		Modified: modTime,
+	}
+	header.SetMode(0644) // rw-r--r-- permissions
+
+	writer, err := zipWriter.CreateHeader(header)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create zip entry for %s", path)
+	}
+
+	reader, err := b.Reader(path)
+	if err != nil {
+		return errors.Wrapf(err, "unable to create reader for %s", path)
+	}
+	defer reader.Close()
+
+	if _, err := io.Copy(writer, reader); err != nil {
+		return errors.Wrapf(err, "unable to copy content for %s", path)
+	}
+	return nil
+}
+
+// RewrapFile wraps the data key of an encrypted file with the current master key, returning false
+// if the file was already using it. The content is copied as is. When unencrypted files are
+// allowed, the files stored before encryption was enabled are encrypted instead, to migrate them.
+func (b *EncryptedFileBackend) RewrapFile(path string) (bool, error) {
+	unlock := b.lockPath(path)
+	defer unlock()
+
+	size, err := b.backend.FileSize(path)
+	if err != nil {
+		return false, err
+	}
+
+	r, header, encrypted, err := b.open(path)
+	if err != nil {
+		return false, err
+	}
+	defer r.Close()
+
+	var content io.Reader
+	if encrypted {
+		if bytes.Equal(header[encryptionBindingSize:encryptionHeaderPrefixSize], b.current.id) {
+			return false, nil
+		}
+
+		dataKey, err := b.openDataKey(header)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to decrypt file %s", path)
+		}
+		newHeader, err := b.sealDataKey(header[len(encryptionMagic)+1:encryptionBindingSize], dataKey)
+		if err != nil {
+			return false, err
+		}
+		content = io.MultiReader(bytes.NewReader(newHeader), r)
+	} else {
+		if !b.allowUnencrypted {
+			return false, errUnencryptedFile(path)
+		}
+
+		er, err := b.newEncryptingReader(r)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to encrypt the file %s", path)
+		}
+		content = er
+	}
+
+	if err := b.replaceFile(content, path, size); err != nil {
+		return false, errors.Wrapf(err, "unable to rewrap the data key of file %s", path)
+	}
+	return true, nil
+}
+
+// encryptingReader reads from src and returns a segment of sealed chunks, numbered from index,
+// followed by its trailer.
+type encryptingReader struct {
+	ctx     context.Context
+	src     io.Reader
+	aead    cipher.AEAD
+	binding []byte
+	index   uint64
+	buf     []byte
+	pending []byte
+	done    bool
+
+	// peeked tells whether peek holds the first byte of the next chunk, read ahead to know whether
+	// a full chunk is the last one.
+	peeked bool
+	peek   [1]byte
+
+	// read is the number of plaintext bytes read from src.
+	read int64
+}
+
+func newEncryptingReader(src io.Reader, aead cipher.AEAD, binding []byte, index uint64) *encryptingReader {
+	return &encryptingReader{
+		ctx:     context.Background(),
+		src:     src,
+		aead:    aead,
+		binding: binding,
+		index:   index,
+		buf:     make([]byte, encryptionRecordSize+encryptionTrailerSize),
+	}
+}
+
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.sealChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+func (r *encryptingReader) sealChunk() error {
+	n := 0
+	if r.peeked {
+		r.buf[0] = r.peek[0]
+		r.peeked = false
+		n = 1
+	}
+
+	m, err := io.ReadFull(r.src, r.buf[n:encryptionChunkSize])
+	n += m
+	last := false
+	switch err {
+	case nil:
+		m, err = io.ReadFull(r.src, r.peek[:])
+		r.peeked = m == 1
+		last = err == io.EOF
+		if err == io.EOF {
+			err = nil
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		last = true
+		err = nil
+	}
+	r.read += int64(n)
+	if ctxErr := r.ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	if err != nil {
+		return err
+	}
+
+	r.pending = r.aead.Seal(r.buf[:0], chunkNonce(r.index), r.buf[:n], chunkAAD(r.binding, last, r.read))
+	r.index++
+	if last {
+		r.pending = binary.BigEndian.AppendUint64(r.pending, uint64(r.read))
+		r.done = true
+	}
+	return nil
+}
+
+// encryptedSegment locates a segment of an encrypted file.
+type encryptedSegment struct {
+	// offset is the position of the content of the segment in the content of the file.
+	offset int64
+	size   int64
+	// srcOffset is the position of the first chunk of the segment in the stored file.
+	srcOffset int64
+	// index is the index of the first chunk of the segment.
+	index int64
+}
+
+// decryptingReader decrypts the chunks of an encrypted file as they are read, keeping the last
+// decrypted one.
+type decryptingReader struct {
+	mut        sync.Mutex
+	src        ReadCloseSeeker
+	aead       cipher.AEAD
+	binding    []byte
+	segments   []encryptedSegment
+	offset     int64
+	srcOffset  int64
+	buf        []byte
+	chunk      []byte
+	chunkIndex int64
+}
+
+func newDecryptingReader(src ReadCloseSeeker, aead cipher.AEAD, binding []byte) *decryptingReader {
+	return &decryptingReader{
+		src:        src,
+		aead:       aead,
+		binding:    binding,
+		srcOffset:  int64(encryptionHeaderSize),
+		buf:        make([]byte, encryptionRecordSize),
+		chunk:      make([]byte, 0, encryptionChunkSize),
+		chunkIndex: -1,
+	}
+}
+
+// loadSegments locates the segments of the file by walking their trailers from its end, once.
+// The trailers are authenticated along with the last chunk of their segment when it is read.
+func (r *decryptingReader) loadSegments() error {
+	if r.segments != nil {
+		return nil
+	}
+
+	end, err := r.src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.Wrap(err, "unable to seek in the encrypted file")
+	}
+	r.srcOffset = end
+
+	var segments []encryptedSegment
+	trailer := make([]byte, encryptionTrailerSize)
+	for end > int64(encryptionHeaderSize) {
+		if end-int64(encryptionHeaderSize) < encryptionTagSize+encryptionTrailerSize {
+			return errors.New("encrypted file is truncated")
+		}
+
+		if _, err := r.src.Seek(end-encryptionTrailerSize, io.SeekStart); err != nil {
+			return errors.Wrap(err, "unable to seek in the encrypted file")
+		}
+		if _, err := io.ReadFull(r.src, trailer); err != nil {
+			return errors.Wrap(err, "unable to read the encrypted file")
+		}
+		r.srcOffset = end
+
+		size := binary.BigEndian.Uint64(trailer)
+		if size > uint64(end) {
+			return errors.New("encrypted file is corrupted")
+		}
+		start := end - segmentStoredSize(int64(size))
+		if start < int64(encryptionHeaderSize) {
+			return errors.New("encrypted file is corrupted")
+		}
+
+		segments = append(segments, encryptedSegment{size: int64(size), srcOffset: start})
+		end = start
+	}
+	if len(segments) == 0 {
+		return errors.New("encrypted file is truncated")
+	}
+
+	slices.Reverse(segments)
+	var offset, index int64
+	for i := range segments {
+		segments[i].offset = offset
+		segments[i].index = index
+		offset += segments[i].size
+		index += segmentChunks(segments[i].size)
+	}
+	r.segments = segments
+	return nil
+}
+
+// Size returns the size of the content of the file.
+func (r *decryptingReader) Size() (int64, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if err := r.loadSegments(); err != nil {
+		return 0, err
+	}
+	last := r.segments[len(r.segments)-1]
+	return last.offset + last.size, nil
+}
+
+// chunkCount returns the number of chunks of the file, which is the index of the next chunk to
+// append.
+func (r *decryptingReader) chunkCount() (int64, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	if err := r.loadSegments(); err != nil {
+		return 0, err
+	}
+	last := r.segments[len(r.segments)-1]
+	return last.index + segmentChunks(last.size), nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	n, err := r.readAt(p, r.offset)
+	r.offset += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *decryptingReader) ReadAt(p []byte, off int64) (int, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	return r.readAt(p, off)
+}
+
+func (r *decryptingReader) readAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if err := r.loadSegments(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for n < len(p) {
+		i := sort.Search(len(r.segments), func(i int) bool {
+			return r.segments[i].offset+r.segments[i].size > off
+		})
+		if i == len(r.segments) {
+			return n, io.EOF
+		}
+
+		segment := &r.segments[i]
+		pos := off - segment.offset
+		if err := r.loadChunk(segment, pos/encryptionChunkSize); err != nil {
+			return n, err
+		}
+
+		c := copy(p[n:], r.chunk[pos%encryptionChunkSize:])
+		n += c
+		off += int64(c)
+	}
+	return n, nil
+}
+
+// loadChunk decrypts the chunk of the given index within a segment.
+func (r *decryptingReader) loadChunk(segment *encryptedSegment, chunk int64) error {
+	index := segment.index + chunk
+	if index == r.chunkIndex {
+		return nil
+	}
+
+	pos := segment.srcOffset + chunk*encryptionRecordSize
+	if pos != r.srcOffset {
+		if _, err := r.src.Seek(pos, io.SeekStart); err != nil {
+			return errors.Wrap(err, "unable to seek in the encrypted file")
+		}
+		r.srcOffset = pos
+	}
+
+	last := chunk == segmentChunks(segment.size)-1
+	size := int64(encryptionRecordSize)
+	if last {
+		size = segment.size - chunk*encryptionChunkSize + encryptionTagSize
+	}
+
+	r.chunkIndex = -1
+	n, err := io.ReadFull(r.src, r.buf[:size])
+	r.srcOffset += int64(n)
+	if err != nil {
+		return errors.Wrap(err, "unable to read the encrypted file")
+	}
+
+	decrypted, err := r.aead.Open(r.chunk[:0], chunkNonce(uint64(index)), r.buf[:size], chunkAAD(r.binding, last, segment.size))
+	if err != nil {
+		return errors.Wrapf(err, "unable to decrypt chunk %d", index)
+	}
+	r.chunk = decrypted
+	r.chunkIndex = index
+	return nil
+}
+
+func (r *decryptingReader) Seek(offset int64, whence int) (int64, error) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.offset + offset
+	case io.SeekEnd:
+		if err := r.loadSegments(); err != nil {
+			return 0, err
+		}
+		last := r.segments[len(r.segments)-1]
+		abs = last.offset + last.size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.offset = abs
+	return abs, nil
+}
+
+func (r *decryptingReader) Close() error {
+	return r.src.Close()
+}
+
+// CancelTimeout cancels the timeout of the underlying reader, if any.
+func (r *decryptingReader) CancelTimeout() bool {
+	if tc, ok := r.src.(interface{ CancelTimeout() bool }); ok {
+		return tc.CancelTimeout()
+	}
+	return true
+}
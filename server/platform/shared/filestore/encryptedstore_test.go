@@ -0,0 +1,507 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package filestore
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testEncryptionKey = base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x42}, masterKeySize))
+
+func newTestMasterKey(t *testing.T) []byte {
+	t.Helper()
+
+	key := make([]byte, masterKeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func newTestEncryptedBackend(t *testing.T, dir string, keys ...[]byte) *EncryptedFileBackend {
+	t.Helper()
+
+	backend, err := NewEncryptedFileBackend(&LocalFileBackend{directory: dir}, keys, false)
+	require.NoError(t, err)
+	return backend
+}
+
+// hookedFileBackend runs beforeWrite before writing a file, to simulate another server modifying
+// the files.
+type hookedFileBackend struct {
+	*LocalFileBackend
+	beforeWrite func(path string)
+}
+
+func (b *hookedFileBackend) WriteFile(fr io.Reader, path string) (int64, error) {
+	b.beforeWrite(path)
+	return b.LocalFileBackend.WriteFile(fr, path)
+}
+
+func randomBytes(t *testing.T, size int) []byte {
+	t.Helper()
+
+	data := make([]byte, size)
+	_, err := rand.Read(data)
+	require.NoError(t, err)
+	return data
+}
+
+func TestNewEncryptedFileBackend(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("no key", func(t *testing.T) {
+		_, err := NewEncryptedFileBackend(&LocalFileBackend{directory: dir}, nil, false)
+		require.Error(t, err)
+	})
+
+	t.Run("invalid key size", func(t *testing.T) {
+		_, err := NewEncryptedFileBackend(&LocalFileBackend{directory: dir}, [][]byte{[]byte("too short")}, false)
+		require.Error(t, err)
+	})
+
+	t.Run("from settings", func(t *testing.T) {
+		backend, err := NewFileBackend(FileBackendSettings{
+			DriverName:    driverLocal,
+			Directory:     dir,
+			EncryptAtRest: true,
+			EncryptionKey: testEncryptionKey,
+		})
+		require.NoError(t, err)
+		require.IsType(t, &EncryptedFileBackend{}, backend)
+		assert.Equal(t, driverLocal, backend.DriverName())
+		assert.IsType(t, &LocalFileBackend{}, UnwrapFileBackend(backend))
+	})
+
+	t.Run("from settings with an invalid key", func(t *testing.T) {
+		_, err := NewFileBackend(FileBackendSettings{
+			DriverName:    driverLocal,
+			Directory:     dir,
+			EncryptAtRest: true,
+			EncryptionKey: "not base64",
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestEncryptionKeys(t *testing.T) {
+	key1 := newTestMasterKey(t)
+	key2 := newTestMasterKey(t)
+	encoded1 := base64.StdEncoding.EncodeToString(key1)
+	encoded2 := base64.StdEncoding.EncodeToString(key2)
+
+	t.Run("from the settings", func(t *testing.T) {
+		settings := FileBackendSettings{EncryptionKey: encoded1 + "," + encoded2}
+		keys, err := settings.EncryptionKeys()
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{key1, key2}, keys)
+	})
+
+	t.Run("from a key file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys")
+		require.NoError(t, os.WriteFile(path, []byte(encoded1+"\n"+encoded2+"\n"), 0600))
+
+		settings := FileBackendSettings{EncryptionKeyFile: path}
+		keys, err := settings.EncryptionKeys()
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{key1, key2}, keys)
+	})
+
+	t.Run("missing key file", func(t *testing.T) {
+		settings := FileBackendSettings{EncryptionKeyFile: filepath.Join(t.TempDir(), "keys")}
+		_, err := settings.EncryptionKeys()
+		require.Error(t, err)
+	})
+
+	t.Run("both set", func(t *testing.T) {
+		settings := FileBackendSettings{EncryptionKey: encoded1, EncryptionKeyFile: "keys"}
+		_, err := settings.EncryptionKeys()
+		require.Error(t, err)
+	})
+}
+
+func TestEncryptedFileBackend(t *testing.T) {
+	dir := t.TempDir()
+	local := &LocalFileBackend{directory: dir}
+	backend := newTestEncryptedBackend(t, dir, newTestMasterKey(t))
+
+	t.Run("files are encrypted at rest", func(t *testing.T) {
+		data := bytes.Repeat([]byte("plaintext"), 1000)
+		written, err := backend.WriteFile(bytes.NewReader(data), "encrypted")
+		require.NoError(t, err)
+		assert.EqualValues(t, len(data), written)
+
+		raw, err := local.ReadFile("encrypted")
+		require.NoError(t, err)
+		assert.NotContains(t, string(raw), "plaintext")
+		assert.Len(t, raw, encryptionHeaderSize+len(data)+encryptionTagSize+encryptionTrailerSize)
+
+		read, err := backend.ReadFile("encrypted")
+		require.NoError(t, err)
+		assert.Equal(t, data, read)
+
+		size, err := backend.FileSize("encrypted")
+		require.NoError(t, err)
+		assert.EqualValues(t, len(data), size)
+	})
+
+	t.Run("empty file", func(t *testing.T) {
+		_, err := backend.WriteFile(bytes.NewReader(nil), "empty")
+		require.NoError(t, err)
+
+		read, err := backend.ReadFile("empty")
+		require.NoError(t, err)
+		assert.Empty(t, read)
+
+		size, err := backend.FileSize("empty")
+		require.NoError(t, err)
+		assert.Zero(t, size)
+	})
+
+	t.Run("seek and read at across chunks", func(t *testing.T) {
+		data := randomBytes(t, 3*encryptionChunkSize+100)
+		_, err := backend.WriteFile(bytes.NewReader(data), "chunks")
+		require.NoError(t, err)
+
+		r, err := backend.Reader("chunks")
+		require.NoError(t, err)
+		defer r.Close()
+
+		end, err := r.Seek(0, io.SeekEnd)
+		require.NoError(t, err)
+		assert.EqualValues(t, len(data), end)
+
+		offset := int64(encryptionChunkSize - 10)
+		pos, err := r.Seek(offset, io.SeekStart)
+		require.NoError(t, err)
+		assert.Equal(t, offset, pos)
+
+		buf := make([]byte, 20)
+		_, err = io.ReadFull(r, buf)
+		require.NoError(t, err)
+		assert.Equal(t, data[offset:offset+20], buf)
+
+		pos, err = r.Seek(-50, io.SeekEnd)
+		require.NoError(t, err)
+		rest, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, data[pos:], rest)
+
+		readerAt, ok := r.(io.ReaderAt)
+		require.True(t, ok)
+		buf = make([]byte, encryptionChunkSize+2)
+		_, err = readerAt.ReadAt(buf, encryptionChunkSize*2-1)
+		require.NoError(t, err)
+		assert.Equal(t, data[encryptionChunkSize*2-1:encryptionChunkSize*3+1], buf)
+
+		n, err := readerAt.ReadAt(buf, int64(len(data)-10))
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, 10, n)
+	})
+
+	t.Run("tampered files are not read", func(t *testing.T) {
+		_, err := backend.WriteFile(bytes.NewReader([]byte("some data")), "tampered")
+		require.NoError(t, err)
+
+		raw, err := local.ReadFile("tampered")
+		require.NoError(t, err)
+		raw[len(raw)-1] ^= 0xff
+		_, err = local.WriteFile(bytes.NewReader(raw), "tampered")
+		require.NoError(t, err)
+
+		_, err = backend.ReadFile("tampered")
+		require.Error(t, err)
+	})
+
+	t.Run("truncated files are not read", func(t *testing.T) {
+		data := randomBytes(t, 2*encryptionChunkSize+10)
+		_, err := backend.WriteFile(bytes.NewReader(data), "truncated")
+		require.NoError(t, err)
+
+		raw, err := local.ReadFile("truncated")
+		require.NoError(t, err)
+
+		for _, size := range []int{
+			len(raw) - 1,
+			len(raw) - encryptionTrailerSize,
+			encryptionHeaderSize + 2*encryptionRecordSize,
+			encryptionHeaderSize + encryptionRecordSize,
+			encryptionHeaderSize,
+		} {
+			_, err = local.WriteFile(bytes.NewReader(raw[:size]), "truncated")
+			require.NoError(t, err)
+
+			_, err = backend.ReadFile("truncated")
+			require.Error(t, err, "truncated to %d bytes", size)
+		}
+	})
+
+	t.Run("reordered chunks are not read", func(t *testing.T) {
+		data := randomBytes(t, 3*encryptionChunkSize)
+		_, err := backend.WriteFile(bytes.NewReader(data), "reordered")
+		require.NoError(t, err)
+
+		raw, err := local.ReadFile("reordered")
+		require.NoError(t, err)
+
+		first := raw[encryptionHeaderSize : encryptionHeaderSize+encryptionRecordSize]
+		second := raw[encryptionHeaderSize+encryptionRecordSize : encryptionHeaderSize+2*encryptionRecordSize]
+		reordered := slices.Concat(raw[:encryptionHeaderSize], second, first, raw[encryptionHeaderSize+2*encryptionRecordSize:])
+		_, err = local.WriteFile(bytes.NewReader(reordered), "reordered")
+		require.NoError(t, err)
+
+		_, err = backend.ReadFile("reordered")
+		require.Error(t, err)
+	})
+
+	t.Run("unencrypted files are not read", func(t *testing.T) {
+		_, err := local.WriteFile(bytes.NewReader([]byte("legacy data")), "legacy")
+		require.NoError(t, err)
+
+		_, err = backend.ReadFile("legacy")
+		require.Error(t, err)
+
+		_, err = backend.FileSize("legacy")
+		require.Error(t, err)
+
+		_, err = backend.AppendFile(bytes.NewReader([]byte(" appended")), "legacy")
+		require.Error(t, err)
+
+		raw, err := local.ReadFile("legacy")
+		require.NoError(t, err)
+		assert.Equal(t, "legacy data", string(raw))
+	})
+
+	t.Run("unencrypted files are read when allowed", func(t *testing.T) {
+		migratingBackend, err := NewEncryptedFileBackend(local, [][]byte{newTestMasterKey(t)}, true)
+		require.NoError(t, err)
+
+		data := []byte("legacy data")
+		_, err = local.WriteFile(bytes.NewReader(data), "legacy")
+		require.NoError(t, err)
+
+		read, err := migratingBackend.ReadFile("legacy")
+		require.NoError(t, err)
+		assert.Equal(t, data, read)
+
+		size, err := migratingBackend.FileSize("legacy")
+		require.NoError(t, err)
+		assert.EqualValues(t, len(data), size)
+
+		written, err := migratingBackend.AppendFile(bytes.NewReader([]byte(" appended")), "legacy")
+		require.NoError(t, err)
+		assert.EqualValues(t, 9, written)
+
+		raw, err := local.ReadFile("legacy")
+		require.NoError(t, err)
+		assert.True(t, isEncryptedHeader(raw))
+
+		read, err = migratingBackend.ReadFile("legacy")
+		require.NoError(t, err)
+		assert.Equal(t, "legacy data appended", string(read))
+	})
+
+	for name, size := range map[string]int{
+		"append to a full last chunk":    2 * encryptionChunkSize,
+		"append to a partial last chunk": encryptionChunkSize + 10,
+		"append to an empty file":        0,
+	} {
+		t.Run(name, func(t *testing.T) {
+			data := randomBytes(t, size)
+			_, err := backend.WriteFile(bytes.NewReader(data), "appended")
+			require.NoError(t, err)
+
+			for _, appendedSize := range []int{1000, encryptionChunkSize, 0, 2*encryptionChunkSize + 1} {
+				stored, err := local.ReadFile("appended")
+				require.NoError(t, err)
+
+				appended := randomBytes(t, appendedSize)
+				written, err := backend.AppendFile(bytes.NewReader(appended), "appended")
+				require.NoError(t, err)
+				assert.EqualValues(t, len(appended), written)
+				data = append(data, appended...)
+
+				raw, err := local.ReadFile("appended")
+				require.NoError(t, err)
+				assert.Equal(t, stored, raw[:len(stored)], "the stored data should not be rewritten")
+
+				read, err := backend.ReadFile("appended")
+				require.NoError(t, err)
+				assert.Equal(t, data, read)
+
+				fileSize, err := backend.FileSize("appended")
+				require.NoError(t, err)
+				assert.EqualValues(t, len(data), fileSize)
+			}
+
+			r, err := backend.Reader("appended")
+			require.NoError(t, err)
+			defer r.Close()
+
+			readerAt, ok := r.(io.ReaderAt)
+			require.True(t, ok)
+			offset := int64(size + 990)
+			buf := make([]byte, encryptionChunkSize+20)
+			_, err = readerAt.ReadAt(buf, offset)
+			require.NoError(t, err)
+			assert.Equal(t, data[offset:offset+int64(len(buf))], buf, "reads should span the segments")
+		})
+	}
+
+	t.Run("appended segments can't be reordered", func(t *testing.T) {
+		_, err := backend.WriteFile(bytes.NewReader([]byte("first")), "segments")
+		require.NoError(t, err)
+		stored, err := local.ReadFile("segments")
+		require.NoError(t, err)
+
+		_, err = backend.AppendFile(bytes.NewReader([]byte("second")), "segments")
+		require.NoError(t, err)
+		raw, err := local.ReadFile("segments")
+		require.NoError(t, err)
+
+		reordered := slices.Concat(raw[:encryptionHeaderSize], raw[len(stored):], raw[encryptionHeaderSize:len(stored)])
+		_, err = local.WriteFile(bytes.NewReader(reordered), "segments")
+		require.NoError(t, err)
+
+		_, err = backend.ReadFile("segments")
+		require.Error(t, err)
+	})
+
+	t.Run("copied and moved files can be read", func(t *testing.T) {
+		data := []byte("moving data")
+		_, err := backend.WriteFile(bytes.NewReader(data), "original")
+		require.NoError(t, err)
+
+		require.NoError(t, backend.CopyFile("original", "copy"))
+		require.NoError(t, backend.MoveFile("original", "moved"))
+
+		for _, path := range []string{"copy", "moved"} {
+			read, err := backend.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, data, read)
+		}
+	})
+}
+
+func TestEncryptedFileBackendKeyRotation(t *testing.T) {
+	dir := t.TempDir()
+	oldKey := newTestMasterKey(t)
+	newKey := newTestMasterKey(t)
+
+	oldBackend := newTestEncryptedBackend(t, dir, oldKey)
+	data := randomBytes(t, encryptionChunkSize+100)
+	_, err := oldBackend.WriteFile(bytes.NewReader(data), "rotated")
+	require.NoError(t, err)
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, err := newTestEncryptedBackend(t, dir, newKey).ReadFile("rotated")
+		require.Error(t, err)
+	})
+
+	rotatingBackend := newTestEncryptedBackend(t, dir, newKey, oldKey)
+
+	t.Run("previous keys are used to read files", func(t *testing.T) {
+		read, err := rotatingBackend.ReadFile("rotated")
+		require.NoError(t, err)
+		assert.Equal(t, data, read)
+	})
+
+	ok, err := rotatingBackend.RewrapFile("rotated")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	t.Run("the data key is rewrapped with the current key", func(t *testing.T) {
+		read, err := newTestEncryptedBackend(t, dir, newKey).ReadFile("rotated")
+		require.NoError(t, err)
+		assert.Equal(t, data, read)
+
+		_, err = oldBackend.ReadFile("rotated")
+		require.Error(t, err)
+	})
+
+	t.Run("files already using the current key are left as is", func(t *testing.T) {
+		ok, err := rotatingBackend.RewrapFile("rotated")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("unencrypted files are encrypted when allowed", func(t *testing.T) {
+		_, err := rotatingBackend.Unwrap().WriteFile(bytes.NewReader([]byte("legacy data")), "legacy")
+		require.NoError(t, err)
+
+		_, err = rotatingBackend.RewrapFile("legacy")
+		require.Error(t, err)
+
+		migratingBackend, err := NewEncryptedFileBackend(rotatingBackend.Unwrap(), [][]byte{newKey, oldKey}, true)
+		require.NoError(t, err)
+		ok, err := migratingBackend.RewrapFile("legacy")
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		read, err := newTestEncryptedBackend(t, dir, newKey).ReadFile("legacy")
+		require.NoError(t, err)
+		assert.Equal(t, "legacy data", string(read))
+	})
+
+	t.Run("appends during a rewrap are kept", func(t *testing.T) {
+		_, err := oldBackend.WriteFile(bytes.NewReader(data), "concurrent")
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := rotatingBackend.RewrapFile("concurrent")
+			assert.NoError(t, err)
+		}()
+		appended := randomBytes(t, encryptionChunkSize)
+		_, err = rotatingBackend.AppendFile(bytes.NewReader(appended), "concurrent")
+		require.NoError(t, err)
+		wg.Wait()
+
+		read, err := rotatingBackend.ReadFile("concurrent")
+		require.NoError(t, err)
+		assert.Equal(t, append(slices.Clone(data), appended...), read)
+	})
+
+	t.Run("appends by another server during a rewrap are kept", func(t *testing.T) {
+		_, err := oldBackend.WriteFile(bytes.NewReader(data), "remote")
+		require.NoError(t, err)
+
+		appended := randomBytes(t, 1000)
+		otherServer := newTestEncryptedBackend(t, dir, newKey, oldKey)
+		hooked := &hookedFileBackend{
+			LocalFileBackend: &LocalFileBackend{directory: dir},
+			beforeWrite: func(path string) {
+				_, err := otherServer.AppendFile(bytes.NewReader(appended), "remote")
+				require.NoError(t, err)
+			},
+		}
+		hookedBackend, err := NewEncryptedFileBackend(hooked, [][]byte{newKey, oldKey}, false)
+		require.NoError(t, err)
+
+		_, err = hookedBackend.RewrapFile("remote")
+		require.Error(t, err)
+
+		read, err := otherServer.ReadFile("remote")
+		require.NoError(t, err)
+		assert.Equal(t, append(slices.Clone(data), appended...), read)
+
+		paths, err := otherServer.ListDirectory("")
+		require.NoError(t, err)
+		for _, path := range paths {
+			assert.NotContains(t, path, ".tmp-", "temporary files should be removed")
+		}
+	})
+}
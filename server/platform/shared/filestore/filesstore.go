@@ -6,6 +6,7 @@ package filestore
 import (
 	"context"
 	"io"
+	"os"
 	"time"
 
 	"github.com/mattermost/mattermost/server/public/model"
@@ -65,13 +66,21 @@ type FileBackendSettings struct {
 	AmazonS3PresignExpiresSeconds      int64
 	AmazonS3UploadPartSizeBytes        int64
 	AmazonS3StorageClass               string
+	EncryptAtRest                      bool
+	EncryptionKey                      string
+	EncryptionKeyFile                  string
+	EncryptionAllowUnencryptedFiles    bool
 }
 
 func NewFileBackendSettingsFromConfig(fileSettings *model.FileSettings, enableComplianceFeature bool, skipVerify bool) FileBackendSettings {
 	if *fileSettings.DriverName == model.ImageDriverLocal {
 		return FileBackendSettings{
-			DriverName: *fileSettings.DriverName,
-			Directory:  *fileSettings.Directory,
+			DriverName:                      *fileSettings.DriverName,
+			Directory:                       *fileSettings.Directory,
+			EncryptAtRest:                   fileSettings.EncryptAtRest != nil && *fileSettings.EncryptAtRest,
+			EncryptionKey:                   model.SafeDereference(fileSettings.EncryptionKey),
+			EncryptionKeyFile:               model.SafeDereference(fileSettings.EncryptionKeyFile),
+			EncryptionAllowUnencryptedFiles: fileSettings.EncryptionAllowUnencryptedFiles != nil && *fileSettings.EncryptionAllowUnencryptedFiles,
 		}
 	}
 	return FileBackendSettings{
@@ -90,14 +99,22 @@ func NewFileBackendSettingsFromConfig(fileSettings *model.FileSettings, enableCo
 		SkipVerify:                         skipVerify,
 		AmazonS3UploadPartSizeBytes:        *fileSettings.AmazonS3UploadPartSizeBytes,
 		AmazonS3StorageClass:               *fileSettings.AmazonS3StorageClass,
+		EncryptAtRest:                      fileSettings.EncryptAtRest != nil && *fileSettings.EncryptAtRest,
+		EncryptionKey:                      model.SafeDereference(fileSettings.EncryptionKey),
+		EncryptionKeyFile:                  model.SafeDereference(fileSettings.EncryptionKeyFile),
+		EncryptionAllowUnencryptedFiles:    fileSettings.EncryptionAllowUnencryptedFiles != nil && *fileSettings.EncryptionAllowUnencryptedFiles,
 	}
 }
 
 func NewExportFileBackendSettingsFromConfig(fileSettings *model.FileSettings, enableComplianceFeature bool, skipVerify bool) FileBackendSettings {
 	if *fileSettings.ExportDriverName == model.ImageDriverLocal {
 		return FileBackendSettings{
-			DriverName: *fileSettings.ExportDriverName,
-			Directory:  *fileSettings.ExportDirectory,
+			DriverName:                      *fileSettings.ExportDriverName,
+			Directory:                       *fileSettings.ExportDirectory,
+			EncryptAtRest:                   fileSettings.ExportEncryptAtRest != nil && *fileSettings.ExportEncryptAtRest,
+			EncryptionKey:                   model.SafeDereference(fileSettings.EncryptionKey),
+			EncryptionKeyFile:               model.SafeDereference(fileSettings.EncryptionKeyFile),
+			EncryptionAllowUnencryptedFiles: fileSettings.EncryptionAllowUnencryptedFiles != nil && *fileSettings.EncryptionAllowUnencryptedFiles,
 		}
 	}
 	return FileBackendSettings{
@@ -117,6 +134,10 @@ func NewExportFileBackendSettingsFromConfig(fileSettings *model.FileSettings, en
 		AmazonS3UploadPartSizeBytes:        *fileSettings.ExportAmazonS3UploadPartSizeBytes,
 		AmazonS3StorageClass:               *fileSettings.ExportAmazonS3StorageClass,
 		SkipVerify:                         skipVerify,
+		EncryptAtRest:                      fileSettings.ExportEncryptAtRest != nil && *fileSettings.ExportEncryptAtRest,
+		EncryptionKey:                      model.SafeDereference(fileSettings.EncryptionKey),
+		EncryptionKeyFile:                  model.SafeDereference(fileSettings.EncryptionKeyFile),
+		EncryptionAllowUnencryptedFiles:    fileSettings.EncryptionAllowUnencryptedFiles != nil && *fileSettings.EncryptionAllowUnencryptedFiles,
 	}
 }
 
@@ -133,6 +154,24 @@ func (settings *FileBackendSettings) CheckMandatoryS3Fields() error {
 	return nil
 }
 
+// EncryptionKeys returns the master keys to encrypt files with, from the key file if set.
+func (settings *FileBackendSettings) EncryptionKeys() ([][]byte, error) {
+	if settings.EncryptionKeyFile != "" && settings.EncryptionKey != "" {
+		return nil, errors.New("only one of the encryption key and the encryption key file can be set")
+	}
+
+	encoded := settings.EncryptionKey
+	if settings.EncryptionKeyFile != "" {
+		data, err := os.ReadFile(settings.EncryptionKeyFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to read the encryption key file %s", settings.EncryptionKeyFile)
+		}
+		encoded = string(data)
+	}
+
+	return ParseEncryptionKeys(encoded)
+}
+
 // NewFileBackend creates a new file backend
 func NewFileBackend(settings FileBackendSettings) (FileBackend, error) {
 	return newFileBackend(settings, true)
@@ -144,6 +183,23 @@ func NewExportFileBackend(settings FileBackendSettings) (FileBackend, error) {
 }
 
 func newFileBackend(settings FileBackendSettings, canBeCloud bool) (FileBackend, error) {
+	backend, err := newDriverFileBackend(settings, canBeCloud)
+	if err != nil || !settings.EncryptAtRest {
+		return backend, err
+	}
+
+	keys, err := settings.EncryptionKeys()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load the encryption keys")
+	}
+	encryptedBackend, err := NewEncryptedFileBackend(backend, keys, settings.EncryptionAllowUnencryptedFiles)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create the encrypted backend")
+	}
+	return encryptedBackend, nil
+}
+
+func newDriverFileBackend(settings FileBackendSettings, canBeCloud bool) (FileBackend, error) {
 	switch settings.DriverName {
 	case driverS3:
 		newBackendFn := NewS3FileBackend
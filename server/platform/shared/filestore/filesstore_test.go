@@ -81,6 +81,24 @@ func TestLocalFileBackendTestSuite(t *testing.T) {
 	})
 }
 
+func TestEncryptedLocalFileBackendTestSuite(t *testing.T) {
+	dir, err := os.MkdirTemp("", "")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		err := os.RemoveAll(dir)
+		require.NoError(t, err)
+	})
+
+	suite.Run(t, &FileBackendTestSuite{
+		settings: FileBackendSettings{
+			DriverName:    driverLocal,
+			Directory:     dir,
+			EncryptAtRest: true,
+			EncryptionKey: testEncryptionKey,
+		},
+	})
+}
+
 func TestS3FileBackendTestSuite(t *testing.T) {
 	runBackendTest(t, false)
 }
@@ -89,7 +107,23 @@ func TestS3FileBackendTestSuiteWithEncryption(t *testing.T) {
 	runBackendTest(t, true)
 }
 
+func TestEncryptedS3FileBackendTestSuite(t *testing.T) {
+	settings := s3BackendTestSettings(false)
+	settings.EncryptAtRest = true
+	settings.EncryptionKey = testEncryptionKey
+
+	suite.Run(t, &FileBackendTestSuite{
+		settings: settings,
+	})
+}
+
 func runBackendTest(t *testing.T, encrypt bool) {
+	suite.Run(t, &FileBackendTestSuite{
+		settings: s3BackendTestSettings(encrypt),
+	})
+}
+
+func s3BackendTestSettings(encrypt bool) FileBackendSettings {
 	s3Host := os.Getenv("CI_MINIO_HOST")
 	if s3Host == "" {
 		s3Host = "localhost"
@@ -102,20 +136,18 @@ func runBackendTest(t *testing.T, encrypt bool) {
 
 	s3Endpoint := fmt.Sprintf("%s:%s", s3Host, s3Port)
 
-	suite.Run(t, &FileBackendTestSuite{
-		settings: FileBackendSettings{
-			DriverName:                         driverS3,
-			AmazonS3AccessKeyId:                "minioaccesskey",
-			AmazonS3SecretAccessKey:            "miniosecretkey",
-			AmazonS3Bucket:                     "mattermost-test",
-			AmazonS3Region:                     "",
-			AmazonS3Endpoint:                   s3Endpoint,
-			AmazonS3PathPrefix:                 "",
-			AmazonS3SSL:                        false,
-			AmazonS3SSE:                        encrypt,
-			AmazonS3RequestTimeoutMilliseconds: 5000,
-		},
-	})
+	return FileBackendSettings{
+		DriverName:                         driverS3,
+		AmazonS3AccessKeyId:                "minioaccesskey",
+		AmazonS3SecretAccessKey:            "miniosecretkey",
+		AmazonS3Bucket:                     "mattermost-test",
+		AmazonS3Region:                     "",
+		AmazonS3Endpoint:                   s3Endpoint,
+		AmazonS3PathPrefix:                 "",
+		AmazonS3SSL:                        false,
+		AmazonS3SSE:                        encrypt,
+		AmazonS3RequestTimeoutMilliseconds: 5000,
+	}
 }
 
 func (s *FileBackendTestSuite) SetupTest() {
@@ -126,7 +158,7 @@ func (s *FileBackendTestSuite) SetupTest() {
 	// This is needed to create the bucket if it doesn't exist.
 	err = s.backend.TestConnection()
 	if _, ok := err.(*S3FileBackendNoBucketError); ok {
-		s3Backend := s.backend.(*S3FileBackend)
+		s3Backend := UnwrapFileBackend(s.backend).(*S3FileBackend)
 		s.NoError(s3Backend.MakeBucket())
 	} else {
 		s.NoError(err)
@@ -122,6 +122,10 @@ func (m *MFA) Deactivate(userId string) error {
 
 // Validate the provide token using the secret provided
 func (m *MFA) ValidateToken(user *model.User, token string) (bool, error) {
+	if user.MfaSecret == "" {
+		return false, nil
+	}
+
 	usedTs, err := m.store.GetMfaUsedTimestamps(user.Id)
 	if err != nil {
 		return false, errors.Wrap(err, "unable to retrieve the DisallowReuse slice")
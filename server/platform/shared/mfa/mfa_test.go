@@ -196,6 +196,18 @@ func TestValidateToken(t *testing.T) {
 		require.Contains(t, err.Error(), "unable to parse the token")
 	})
 
+	t.Run("reject tokens for users without a secret", func(t *testing.T) {
+		u := &model.User{Id: model.NewId()}
+
+		code := fmt.Sprintf("%06d", dgoogauth.ComputeCode("", time.Now().UTC().Unix()/30))
+
+		usMock := mocks.UserStore{}
+		ok, err := New(&usMock).ValidateToken(u, code)
+		require.NoError(t, err)
+		require.False(t, ok)
+		usMock.AssertNotCalled(t, "StoreMfaUsedTimestamps", mock.Anything, mock.Anything)
+	})
+
 	t.Run("successful validation", func(t *testing.T) {
 		id := model.NewId()
 		secret := newRandomBase32String(mfaSecretSize)
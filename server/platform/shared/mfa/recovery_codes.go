@@ -0,0 +1,88 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package mfa
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// Each half of a recovery code holds 5 base32 characters, i.e. 50 bits of entropy per code.
+	recoveryCodeHalfSize = 5
+)
+
+var recoveryCodeRegex = regexp.MustCompile(`^[a-z2-7]{5}-[a-z2-7]{5}$`)
+
+type RecoveryCodeStore interface {
+	SaveRecoveryCodes(userId string, codeHashes []string) error
+	UseRecoveryCode(userId, codeHash string) (bool, error)
+}
+
+// RecoveryCodes manages the one-time codes that let a user log in after losing their
+// authenticators. Only the hashes of the codes are stored.
+type RecoveryCodes struct {
+	store RecoveryCodeStore
+}
+
+func NewRecoveryCodes(store RecoveryCodeStore) *RecoveryCodes {
+	return &RecoveryCodes{store}
+}
+
+// IsRecoveryCode reports whether the token has the format of a recovery code rather than of
+// a TOTP token.
+func IsRecoveryCode(token string) bool {
+	return recoveryCodeRegex.MatchString(normalizeRecoveryCode(token))
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+func hashRecoveryCode(code string) string {
+	hash := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(hash[:])
+}
+
+func newRecoveryCode() string {
+	code := strings.ToLower(newRandomBase32String(2 * recoveryCodeHalfSize))
+	return code[:recoveryCodeHalfSize] + "-" + code[recoveryCodeHalfSize:2*recoveryCodeHalfSize]
+}
+
+// Generate replaces the recovery codes of the user with new ones, returned in plain text.
+func (r *RecoveryCodes) Generate(userId string) ([]string, error) {
+	codes := make([]string, 0, model.MfaRecoveryCodeCount)
+	hashes := make([]string, 0, model.MfaRecoveryCodeCount)
+	for range model.MfaRecoveryCodeCount {
+		code := newRecoveryCode()
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := r.store.SaveRecoveryCodes(userId, hashes); err != nil {
+		return nil, errors.Wrap(err, "unable to store the recovery codes")
+	}
+
+	return codes, nil
+}
+
+// Validate consumes the recovery code, returning false if it isn't one of the user's unused codes.
+func (r *RecoveryCodes) Validate(userId, code string) (bool, error) {
+	if !IsRecoveryCode(code) {
+		return false, nil
+	}
+
+	ok, err := r.store.UseRecoveryCode(userId, hashRecoveryCode(code))
+	if err != nil {
+		return false, errors.Wrap(err, "unable to use the recovery code")
+	}
+
+	return ok, nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package mfa
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
+)
+
+func TestIsRecoveryCode(t *testing.T) {
+	assert.True(t, IsRecoveryCode("abcde-23457"))
+	assert.True(t, IsRecoveryCode(" ABCDE-23457 "))
+	assert.False(t, IsRecoveryCode("123456"))
+	assert.False(t, IsRecoveryCode("abcde23457"))
+	assert.False(t, IsRecoveryCode("abcde-23458"))
+	assert.False(t, IsRecoveryCode(""))
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	userID := "user-id"
+
+	t.Run("fail on store action fail", func(t *testing.T) {
+		storeMock := mocks.MfaFactorStore{}
+		storeMock.On("SaveRecoveryCodes", userID, mock.AnythingOfType("[]string")).Return(errors.New("failed to save codes"))
+
+		_, err := NewRecoveryCodes(&storeMock).Generate(userID)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unable to store the recovery codes")
+	})
+
+	t.Run("successful generate", func(t *testing.T) {
+		var hashes []string
+		storeMock := mocks.MfaFactorStore{}
+		storeMock.On("SaveRecoveryCodes", userID, mock.AnythingOfType("[]string")).Return(func(userId string, codeHashes []string) error {
+			hashes = codeHashes
+			return nil
+		})
+
+		codes, err := NewRecoveryCodes(&storeMock).Generate(userID)
+		require.NoError(t, err)
+		require.Len(t, codes, model.MfaRecoveryCodeCount)
+		require.Len(t, hashes, model.MfaRecoveryCodeCount)
+
+		for i, code := range codes {
+			assert.True(t, IsRecoveryCode(code), code)
+			assert.Equal(t, hashRecoveryCode(code), hashes[i])
+			assert.NotContains(t, hashes, code)
+		}
+	})
+}
+
+func TestValidateRecoveryCode(t *testing.T) {
+	userID := "user-id"
+	code := "abcde-23457"
+
+	t.Run("not a recovery code", func(t *testing.T) {
+		storeMock := mocks.MfaFactorStore{}
+
+		ok, err := NewRecoveryCodes(&storeMock).Validate(userID, "123456")
+		require.NoError(t, err)
+		assert.False(t, ok)
+		storeMock.AssertNotCalled(t, "UseRecoveryCode", mock.Anything, mock.Anything)
+	})
+
+	t.Run("fail on store action fail", func(t *testing.T) {
+		storeMock := mocks.MfaFactorStore{}
+		storeMock.On("UseRecoveryCode", userID, hashRecoveryCode(code)).Return(false, errors.New("failed to use code"))
+
+		_, err := NewRecoveryCodes(&storeMock).Validate(userID, code)
+		require.Error(t, err)
+	})
+
+	t.Run("unknown or used code", func(t *testing.T) {
+		storeMock := mocks.MfaFactorStore{}
+		storeMock.On("UseRecoveryCode", userID, hashRecoveryCode(code)).Return(false, nil)
+
+		ok, err := NewRecoveryCodes(&storeMock).Validate(userID, code)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("valid code, ignoring case and spaces", func(t *testing.T) {
+		storeMock := mocks.MfaFactorStore{}
+		storeMock.On("UseRecoveryCode", userID, hashRecoveryCode(code)).Return(true, nil)
+
+		ok, err := NewRecoveryCodes(&storeMock).Validate(userID, " "+strings.ToUpper(code))
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
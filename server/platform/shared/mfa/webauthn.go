@@ -0,0 +1,259 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package mfa
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/pkg/errors"
+
+	"github.com/mattermost/mattermost/server/public/model"
+)
+
+const (
+	// webAuthnCeremonyTimeout is how long the user has to answer a registration or login challenge.
+	webAuthnCeremonyTimeout = 5 * time.Minute
+)
+
+// InvalidWebAuthnResponse indicates the case where the authenticator response could not be verified.
+var InvalidWebAuthnResponse = errors.New("invalid webauthn response")
+
+// NoWebAuthnCredentials indicates the case where a login is started for a user without credentials.
+var NoWebAuthnCredentials = errors.New("no webauthn credentials registered")
+
+type WebAuthnStore interface {
+	SaveWebAuthnCredential(credential *model.WebAuthnCredential) (*model.WebAuthnCredential, error)
+	GetWebAuthnCredentials(userId string) ([]*model.WebAuthnCredential, error)
+	UpdateWebAuthnCredential(credential *model.WebAuthnCredential) error
+}
+
+// WebAuthn implements the registration and login ceremonies of WebAuthn credentials used as
+// second factor. The ceremony state is returned serialized to the caller, who is responsible
+// for keeping it server side between the two steps of a ceremony.
+type WebAuthn struct {
+	store    WebAuthnStore
+	webAuthn *webauthn.WebAuthn
+}
+
+// NewWebAuthn returns a WebAuthn relying party for the given site URL. Credentials are scoped
+// to the host of the site URL, and only assertions made from its origin are accepted.
+func NewWebAuthn(store WebAuthnStore, siteURL, siteName string) (*WebAuthn, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil || u.Hostname() == "" {
+		return nil, errors.Errorf("invalid site url %q", siteURL)
+	}
+
+	if siteName == "" {
+		siteName = "Mattermost"
+	}
+
+	timeout := webauthn.TimeoutConfig{Enforce: true, Timeout: webAuthnCeremonyTimeout, TimeoutUVD: webAuthnCeremonyTimeout}
+	w, err := webauthn.New(&webauthn.Config{
+		RPID:          u.Hostname(),
+		RPDisplayName: siteName,
+		RPOrigins:     []string{u.Scheme + "://" + u.Host},
+		Timeouts: webauthn.TimeoutsConfig{
+			Login:        timeout,
+			Registration: timeout,
+		},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create the webauthn relying party")
+	}
+
+	return &WebAuthn{store: store, webAuthn: w}, nil
+}
+
+// webAuthnUser adapts a user and its stored credentials to the webauthn.User interface.
+type webAuthnUser struct {
+	user        *model.User
+	stored      []*model.WebAuthnCredential
+	credentials []webauthn.Credential
+}
+
+func (m *WebAuthn) getWebAuthnUser(user *model.User) (*webAuthnUser, error) {
+	stored, err := m.store.GetWebAuthnCredentials(user.Id)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to retrieve the webauthn credentials")
+	}
+
+	credentials := make([]webauthn.Credential, 0, len(stored))
+	for _, s := range stored {
+		var credential webauthn.Credential
+		if err := json.Unmarshal([]byte(s.Credential), &credential); err != nil {
+			return nil, errors.Wrapf(err, "unable to decode the webauthn credential id=%s", s.Id)
+		}
+		credentials = append(credentials, credential)
+	}
+
+	return &webAuthnUser{user: user, stored: stored, credentials: credentials}, nil
+}
+
+func (u *webAuthnUser) WebAuthnID() []byte {
+	return []byte(u.user.Id)
+}
+
+func (u *webAuthnUser) WebAuthnName() string {
+	return u.user.Username
+}
+
+func (u *webAuthnUser) WebAuthnDisplayName() string {
+	return u.user.GetDisplayName(model.ShowNicknameFullName)
+}
+
+func (u *webAuthnUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// BeginRegistration starts the registration of a new credential for the user, returning the
+// options to pass to the browser and the serialized ceremony state.
+func (m *WebAuthn) BeginRegistration(user *model.User) ([]byte, []byte, error) {
+	u, err := m.getWebAuthnUser(user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	creation, session, err := m.webAuthn.BeginRegistration(u,
+		webauthn.WithExclusions(webauthn.Credentials(u.credentials).CredentialDescriptors()),
+		webauthn.WithConveyancePreference(protocol.PreferNoAttestation),
+	)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to begin the webauthn registration")
+	}
+
+	return marshalCeremony(creation, session)
+}
+
+// FinishRegistration verifies the authenticator response to a registration started with
+// BeginRegistration and stores the new credential under the given name.
+func (m *WebAuthn) FinishRegistration(user *model.User, sessionData []byte, name string, response []byte) (*model.WebAuthnCredential, error) {
+	var session webauthn.SessionData
+	if err := json.Unmarshal(sessionData, &session); err != nil {
+		return nil, errors.Wrap(err, "unable to decode the webauthn session")
+	}
+
+	u, err := m.getWebAuthnUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(response)
+	if err != nil {
+		return nil, errors.Wrap(InvalidWebAuthnResponse, err.Error())
+	}
+
+	credential, err := m.webAuthn.CreateCredential(u, session, parsed)
+	if err != nil {
+		return nil, errors.Wrap(InvalidWebAuthnResponse, err.Error())
+	}
+
+	serialized, err := json.Marshal(credential)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to encode the webauthn credential")
+	}
+
+	saved, err := m.store.SaveWebAuthnCredential(&model.WebAuthnCredential{
+		UserId:       user.Id,
+		Name:         name,
+		CredentialId: base64.RawURLEncoding.EncodeToString(credential.ID),
+		Credential:   string(serialized),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to store the webauthn credential")
+	}
+
+	return saved, nil
+}
+
+// BeginLogin starts the verification of one of the user's credentials, returning the options
+// to pass to the browser and the serialized ceremony state.
+func (m *WebAuthn) BeginLogin(user *model.User) ([]byte, []byte, error) {
+	u, err := m.getWebAuthnUser(user)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(u.credentials) == 0 {
+		return nil, nil, NoWebAuthnCredentials
+	}
+
+	assertion, session, err := m.webAuthn.BeginLogin(u)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to begin the webauthn login")
+	}
+
+	// The response is verified against the user's credentials anyway, so the allowed ones
+	// aren't kept to keep the session small.
+	session.AllowedCredentialIDs = nil
+
+	return marshalCeremony(assertion, session)
+}
+
+// ValidateLogin verifies the authenticator response to a login started with BeginLogin. As
+// with ValidateToken, a response that doesn't verify is not an error but returns false.
+func (m *WebAuthn) ValidateLogin(user *model.User, sessionData []byte, response []byte) (bool, error) {
+	var session webauthn.SessionData
+	if err := json.Unmarshal(sessionData, &session); err != nil {
+		return false, errors.Wrap(err, "unable to decode the webauthn session")
+	}
+
+	u, err := m.getWebAuthnUser(user)
+	if err != nil {
+		return false, err
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(response)
+	if err != nil {
+		return false, nil
+	}
+
+	credential, err := m.webAuthn.ValidateLogin(u, session, parsed)
+	if err != nil {
+		return false, nil
+	}
+
+	// A signature counter going backwards means the authenticator may have been cloned
+	if credential.Authenticator.CloneWarning {
+		return false, nil
+	}
+
+	serialized, err := json.Marshal(credential)
+	if err != nil {
+		return true, errors.Wrap(err, "unable to encode the webauthn credential")
+	}
+
+	credentialId := base64.RawURLEncoding.EncodeToString(credential.ID)
+	for _, stored := range u.stored {
+		if stored.CredentialId != credentialId {
+			continue
+		}
+
+		stored.Credential = string(serialized)
+		stored.LastUsedAt = model.GetMillis()
+		if err := m.store.UpdateWebAuthnCredential(stored); err != nil {
+			return true, errors.Wrap(err, "unable to store the webauthn credential")
+		}
+	}
+
+	return true, nil
+}
+
+func marshalCeremony(options any, session *webauthn.SessionData) ([]byte, []byte, error) {
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to encode the webauthn options")
+	}
+
+	sessionJSON, err := json.Marshal(session)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "unable to encode the webauthn session")
+	}
+
+	return optionsJSON, sessionJSON, nil
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package mfa
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/mattermost/mattermost/server/public/model"
+	"github.com/mattermost/mattermost/server/public/plugin/plugintest/mock"
+	"github.com/mattermost/mattermost/server/v8/channels/store/storetest/mocks"
+)
+
+const testSiteURL = "http://localhost:8065"
+
+// softwareAuthenticator is a minimal WebAuthn authenticator answering the ceremonies with a
+// P-256 key and "none" attestation, as a browser would relay them.
+type softwareAuthenticator struct {
+	origin       string
+	rpID         string
+	credentialID []byte
+	key          *ecdsa.PrivateKey
+	signCount    uint32
+}
+
+func newSoftwareAuthenticator(t *testing.T, origin, rpID string) *softwareAuthenticator {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	credentialID := make([]byte, 32)
+	_, err = rand.Read(credentialID)
+	require.NoError(t, err)
+
+	return &softwareAuthenticator{origin: origin, rpID: rpID, credentialID: credentialID, key: key}
+}
+
+func (a *softwareAuthenticator) clientData(t *testing.T, ceremonyType string, challenge protocol.URLEncodedBase64) []byte {
+	t.Helper()
+
+	clientData, err := json.Marshal(map[string]any{
+		"type":      ceremonyType,
+		"challenge": challenge.String(),
+		"origin":    a.origin,
+	})
+	require.NoError(t, err)
+	return clientData
+}
+
+func (a *softwareAuthenticator) authenticatorData(attestedCredentialData []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	flags := byte(protocol.FlagUserPresent | protocol.FlagUserVerified)
+	if attestedCredentialData != nil {
+		flags |= byte(protocol.FlagAttestedCredentialData)
+	}
+
+	a.signCount++
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attestedCredentialData...)
+}
+
+func (a *softwareAuthenticator) create(t *testing.T, options []byte) []byte {
+	t.Helper()
+
+	var creation protocol.CredentialCreation
+	require.NoError(t, json.Unmarshal(options, &creation))
+
+	publicKey, err := webauthncbor.Marshal(map[int]any{
+		1:  2,  // kty: EC2
+		3:  -7, // alg: ES256
+		-1: 1,  // crv: P-256
+		-2: a.key.X.FillBytes(make([]byte, 32)),
+		-3: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	require.NoError(t, err)
+
+	attestedCredentialData := make([]byte, 16) // AAGUID
+	attestedCredentialData = binary.BigEndian.AppendUint16(attestedCredentialData, uint16(len(a.credentialID)))
+	attestedCredentialData = append(attestedCredentialData, a.credentialID...)
+	attestedCredentialData = append(attestedCredentialData, publicKey...)
+
+	attestationObject, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": a.authenticatorData(attestedCredentialData),
+	})
+	require.NoError(t, err)
+
+	response, err := json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(a.clientData(t, "webauthn.create", creation.Response.Challenge)),
+			"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+		},
+	})
+	require.NoError(t, err)
+	return response
+}
+
+func (a *softwareAuthenticator) get(t *testing.T, options []byte, userHandle string) []byte {
+	t.Helper()
+
+	var assertion protocol.CredentialAssertion
+	require.NoError(t, json.Unmarshal(options, &assertion))
+
+	clientData := a.clientData(t, "webauthn.get", assertion.Response.Challenge)
+	authenticatorData := a.authenticatorData(nil)
+	clientDataHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authenticatorData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+
+	response, err := json.Marshal(map[string]any{
+		"id":    base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"rawId": base64.RawURLEncoding.EncodeToString(a.credentialID),
+		"type":  "public-key",
+		"response": map[string]any{
+			"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData),
+			"authenticatorData": base64.RawURLEncoding.EncodeToString(authenticatorData),
+			"signature":         base64.RawURLEncoding.EncodeToString(signature),
+			"userHandle":        base64.RawURLEncoding.EncodeToString([]byte(userHandle)),
+		},
+	})
+	require.NoError(t, err)
+	return response
+}
+
+// newWebAuthnStoreMock returns a store mock keeping the saved credentials in memory.
+func newWebAuthnStoreMock(t *testing.T) *mocks.MfaFactorStore {
+	var credentials []*model.WebAuthnCredential
+
+	storeMock := mocks.NewMfaFactorStore(t)
+	storeMock.On("GetWebAuthnCredentials", mock.AnythingOfType("string")).Return(func(userId string) ([]*model.WebAuthnCredential, error) {
+		var userCredentials []*model.WebAuthnCredential
+		for _, c := range credentials {
+			if c.UserId == userId {
+				copied := *c
+				userCredentials = append(userCredentials, &copied)
+			}
+		}
+		return userCredentials, nil
+	}).Maybe()
+	storeMock.On("SaveWebAuthnCredential", mock.AnythingOfType("*model.WebAuthnCredential")).Return(func(credential *model.WebAuthnCredential) (*model.WebAuthnCredential, error) {
+		credential.PreSave()
+		if appErr := credential.IsValid(); appErr != nil {
+			return nil, appErr
+		}
+		copied := *credential
+		credentials = append(credentials, &copied)
+		return credential, nil
+	}).Maybe()
+	storeMock.On("UpdateWebAuthnCredential", mock.AnythingOfType("*model.WebAuthnCredential")).Return(func(credential *model.WebAuthnCredential) error {
+		for i, c := range credentials {
+			if c.Id == credential.Id {
+				copied := *credential
+				credentials[i] = &copied
+			}
+		}
+		return nil
+	}).Maybe()
+
+	return storeMock
+}
+
+func TestNewWebAuthn(t *testing.T) {
+	t.Run("invalid site url", func(t *testing.T) {
+		_, err := NewWebAuthn(mocks.NewMfaFactorStore(t), "", "")
+		require.Error(t, err)
+	})
+
+	t.Run("relying party from the site url", func(t *testing.T) {
+		w, err := NewWebAuthn(mocks.NewMfaFactorStore(t), "https://chat.example.com:8443/subpath", "Example")
+		require.NoError(t, err)
+		assert.Equal(t, "chat.example.com", w.webAuthn.Config.RPID)
+		assert.Equal(t, []string{"https://chat.example.com:8443"}, w.webAuthn.Config.RPOrigins)
+		assert.Equal(t, "Example", w.webAuthn.Config.RPDisplayName)
+	})
+}
+
+func TestWebAuthnCeremonies(t *testing.T) {
+	user := &model.User{Id: model.NewId(), Username: "user"}
+
+	register := func(t *testing.T, w *WebAuthn, authenticator *softwareAuthenticator) *model.WebAuthnCredential {
+		t.Helper()
+
+		options, session, err := w.BeginRegistration(user)
+		require.NoError(t, err)
+
+		credential, err := w.FinishRegistration(user, session, "Security key", authenticator.create(t, options))
+		require.NoError(t, err)
+		return credential
+	}
+
+	t.Run("register and login", func(t *testing.T) {
+		w, err := NewWebAuthn(newWebAuthnStoreMock(t), testSiteURL, "")
+		require.NoError(t, err)
+		authenticator := newSoftwareAuthenticator(t, testSiteURL, "localhost")
+
+		credential := register(t, w, authenticator)
+		assert.Equal(t, "Security key", credential.Name)
+		assert.Equal(t, base64.RawURLEncoding.EncodeToString(authenticator.credentialID), credential.CredentialId)
+
+		options, session, err := w.BeginLogin(user)
+		require.NoError(t, err)
+
+		ok, err := w.ValidateLogin(user, session, authenticator.get(t, options, user.Id))
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		credentials, err := w.store.GetWebAuthnCredentials(user.Id)
+		require.NoError(t, err)
+		require.Len(t, credentials, 1)
+		assert.NotZero(t, credentials[0].LastUsedAt)
+	})
+
+	t.Run("registered credentials are excluded", func(t *testing.T) {
+		w, err := NewWebAuthn(newWebAuthnStoreMock(t), testSiteURL, "")
+		require.NoError(t, err)
+		register(t, w, newSoftwareAuthenticator(t, testSiteURL, "localhost"))
+
+		options, _, err := w.BeginRegistration(user)
+		require.NoError(t, err)
+
+		var creation protocol.CredentialCreation
+		require.NoError(t, json.Unmarshal(options, &creation))
+		assert.Len(t, creation.Response.CredentialExcludeList, 1)
+	})
+
+	t.Run("registration from another origin", func(t *testing.T) {
+		w, err := NewWebAuthn(newWebAuthnStoreMock(t), testSiteURL, "")
+		require.NoError(t, err)
+
+		options, session, err := w.BeginRegistration(user)
+		require.NoError(t, err)
+
+		_, err = w.FinishRegistration(user, session, "Security key", newSoftwareAuthenticator(t, "http://evil.example.com", "localhost").create(t, options))
+		require.ErrorIs(t, err, InvalidWebAuthnResponse)
+	})
+
+	t.Run("login without credentials", func(t *testing.T) {
+		w, err := NewWebAuthn(newWebAuthnStoreMock(t), testSiteURL, "")
+		require.NoError(t, err)
+
+		_, _, err = w.BeginLogin(user)
+		require.ErrorIs(t, err, NoWebAuthnCredentials)
+	})
+
+	t.Run("login with an unregistered authenticator", func(t *testing.T) {
+		w, err := NewWebAuthn(newWebAuthnStoreMock(t), testSiteURL, "")
+		require.NoError(t, err)
+		register(t, w, newSoftwareAuthenticator(t, testSiteURL, "localhost"))
+
+		options, session, err := w.BeginLogin(user)
+		require.NoError(t, err)
+
+		ok, err := w.ValidateLogin(user, session, newSoftwareAuthenticator(t, testSiteURL, "localhost").get(t, options, user.Id))
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("login answering another challenge", func(t *testing.T) {
+		w, err := NewWebAuthn(newWebAuthnStoreMock(t), testSiteURL, "")
+		require.NoError(t, err)
+		authenticator := newSoftwareAuthenticator(t, testSiteURL, "localhost")
+		register(t, w, authenticator)
+
+		options, _, err := w.BeginLogin(user)
+		require.NoError(t, err)
+		_, session, err := w.BeginLogin(user)
+		require.NoError(t, err)
+
+		ok, err := w.ValidateLogin(user, session, authenticator.get(t, options, user.Id))
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("login with a cloned authenticator", func(t *testing.T) {
+		w, err := NewWebAuthn(newWebAuthnStoreMock(t), testSiteURL, "")
+		require.NoError(t, err)
+		authenticator := newSoftwareAuthenticator(t, testSiteURL, "localhost")
+		register(t, w, authenticator)
+
+		options, session, err := w.BeginLogin(user)
+		require.NoError(t, err)
+		ok, err := w.ValidateLogin(user, session, authenticator.get(t, options, user.Id))
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		// The clone replays an older signature counter
+		authenticator.signCount -= 2
+		options, session, err = w.BeginLogin(user)
+		require.NoError(t, err)
+		ok, err = w.ValidateLogin(user, session, authenticator.get(t, options, user.Id))
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
@@ -242,15 +242,16 @@ const (
 
 // Posts
 const (
-	AuditEventCreatePost         = "createPost"         // create post
-	AuditEventDeletePost         = "deletePost"         // delete post
-	AuditEventLocalDeletePost    = "localDeletePost"    // delete post locally
-	AuditEventMoveThread         = "moveThread"         // move thread and replies to different channel
-	AuditEventPatchPost          = "patchPost"          // update post meta properties
-	AuditEventRestorePostVersion = "restorePostVersion" // restore post to previous version
-	AuditEventSaveIsPinnedPost   = "saveIsPinnedPost"   // pin or unpin post
-	AuditEventSearchPosts        = "searchPosts"        // search for posts
-	AuditEventUpdatePost         = "updatePost"         // update post content
+	AuditEventCreatePost           = "createPost"           // create post
+	AuditEventDeletePost           = "deletePost"           // delete post
+	AuditEventLocalDeletePost      = "localDeletePost"      // delete post locally
+	AuditEventMoveThread           = "moveThread"           // move thread and replies to different channel
+	AuditEventPatchPost            = "patchPost"            // update post meta properties
+	AuditEventRemindPostNonReaders = "remindPostNonReaders" // remind the channel members who haven't read a post
+	AuditEventRestorePostVersion   = "restorePostVersion"   // restore post to previous version
+	AuditEventSaveIsPinnedPost     = "saveIsPinnedPost"     // pin or unpin post
+	AuditEventSearchPosts          = "searchPosts"          // search for posts
+	AuditEventUpdatePost           = "updatePost"           // update post content
 )
 
 // Preferences
@@ -380,11 +381,14 @@ const (
 	AuditEventAttachDeviceId               = "attachDeviceId"               // attach device ID to user session for mobile app
 	AuditEventCreateUser                   = "createUser"                   // create user account
 	AuditEventCreateUserAccessToken        = "createUserAccessToken"        // create personal access token for user API access
+	AuditEventCreateWebAuthnCredential     = "createWebAuthnCredential"     // register WebAuthn credential as second factor for user
 	AuditEventDeleteUser                   = "deleteUser"                   // delete user account
+	AuditEventDeleteWebAuthnCredential     = "deleteWebAuthnCredential"     // revoke WebAuthn credential of user
 	AuditEventDemoteUserToGuest            = "demoteUserToGuest"            // demote regular user to guest account with limited permissions
 	AuditEventDisableUserAccessToken       = "disableUserAccessToken"       // disable user personal access token
 	AuditEventEnableUserAccessToken        = "enableUserAccessToken"        // enable user personal access token
 	AuditEventExtendSessionExpiry          = "extendSessionExpiry"          // extend user session expiration time
+	AuditEventGenerateMfaRecoveryCodes     = "generateMfaRecoveryCodes"     // generate new multi-factor authentication recovery codes for user
 	AuditEventLocalDeleteUser              = "localDeleteUser"              // delete user locally
 	AuditEventLocalPermanentDeleteAllUsers = "localPermanentDeleteAllUsers" // permanently delete all users locally
 	AuditEventLogin                        = "login"                        // user login to system
@@ -395,6 +399,7 @@ const (
 	AuditEventPromoteGuestToUser           = "promoteGuestToUser"           // promote guest account to regular user
 	AuditEventResetPassword                = "resetPassword"                // reset user password
 	AuditEventResetPasswordFailedAttempts  = "resetPasswordFailedAttempts"  // reset failed password attempt counter
+	AuditEventResetUserMfaFactors          = "resetUserMfaFactors"          // remove all multi-factor authentication factors of user
 	AuditEventRevokeAllSessionsAllUsers    = "revokeAllSessionsAllUsers"    // revoke all active sessions for all users
 	AuditEventRevokeAllSessionsForUser     = "revokeAllSessionsForUser"     // revoke all active sessions for specific user
 	AuditEventRevokeSession                = "revokeSession"                // revoke specific user session
@@ -41,6 +41,12 @@ const (
 	ChannelCacheSize           = 25000
 	ChannelBannerInfoMaxLength = 1024
 
+	// ChannelPropsReadReceiptsDisabled is set to true by channel admins to turn off read receipts in the channel
+	ChannelPropsReadReceiptsDisabled = "read_receipts_disabled"
+
+	// ChannelPropsChannelMentions is filled in when channels are returned to clients and is never persisted
+	ChannelPropsChannelMentions = "channel_mentions"
+
 	ChannelSortByUsername = "username"
 	ChannelSortByStatus   = "status"
 )
@@ -92,7 +98,7 @@ type Channel struct {
 	ExtraUpdateAt       int64              `json:"extra_update_at"`
 	CreatorId           string             `json:"creator_id"`
 	SchemeId            *string            `json:"scheme_id"`
-	Props               map[string]any     `json:"props"`
+	Props               StringInterface    `json:"props"`
 	GroupConstrained    *bool              `json:"group_constrained"`
 	Shared              *bool              `json:"shared"`
 	TotalMsgCountRoot   int64              `json:"total_msg_count_root"`
@@ -142,19 +148,21 @@ type ChannelsWithCount struct {
 }
 
 type ChannelPatch struct {
-	DisplayName      *string            `json:"display_name"`
-	Name             *string            `json:"name"`
-	Header           *string            `json:"header"`
-	Purpose          *string            `json:"purpose"`
-	GroupConstrained *bool              `json:"group_constrained"`
-	BannerInfo       *ChannelBannerInfo `json:"banner_info"`
+	DisplayName          *string            `json:"display_name"`
+	Name                 *string            `json:"name"`
+	Header               *string            `json:"header"`
+	Purpose              *string            `json:"purpose"`
+	GroupConstrained     *bool              `json:"group_constrained"`
+	BannerInfo           *ChannelBannerInfo `json:"banner_info"`
+	ReadReceiptsDisabled *bool              `json:"read_receipts_disabled"`
 }
 
 func (c *ChannelPatch) Auditable() map[string]any {
 	return map[string]any{
-		"header":            c.Header,
-		"group_constrained": c.GroupConstrained,
-		"purpose":           c.Purpose,
+		"header":                 c.Header,
+		"group_constrained":      c.GroupConstrained,
+		"purpose":                c.Purpose,
+		"read_receipts_disabled": c.ReadReceiptsDisabled,
 	}
 }
 
@@ -381,6 +389,10 @@ func (o *Channel) Patch(patch *ChannelPatch) {
 		o.GroupConstrained = patch.GroupConstrained
 	}
 
+	if patch.ReadReceiptsDisabled != nil {
+		o.AddProp(ChannelPropsReadReceiptsDisabled, *patch.ReadReceiptsDisabled)
+	}
+
 	// patching channel banner info
 	if patch.BannerInfo != nil {
 		if o.BannerInfo == nil {
@@ -413,6 +425,27 @@ func (o *Channel) AddProp(key string, value any) {
 	o.Props[key] = value
 }
 
+// PersistentProps returns the props to store, leaving out those only filled in for clients
+func (o *Channel) PersistentProps() StringInterface {
+	if _, ok := o.Props[ChannelPropsChannelMentions]; !ok {
+		return o.Props
+	}
+
+	props := make(StringInterface, len(o.Props)-1)
+	for key, value := range o.Props {
+		if key != ChannelPropsChannelMentions {
+			props[key] = value
+		}
+	}
+	return props
+}
+
+// ReadReceiptsDisabled reports whether channel admins turned off read receipts in the channel
+func (o *Channel) ReadReceiptsDisabled() bool {
+	disabled, _ := o.Props[ChannelPropsReadReceiptsDisabled].(bool)
+	return disabled
+}
+
 func (o *Channel) IsGroupConstrained() bool {
 	return o.GroupConstrained != nil && *o.GroupConstrained
 }
@@ -17,23 +17,134 @@ type ChannelReadCursor struct {
 	UpdatedAt   int64  `json:"updated_at" db:"updated_at"`
 }
 
+// ThreadReadCursor represents a user's reading progress in a thread. With collapsed reply threads
+// replies are read in the thread view, which doesn't move the channel cursor.
+type ThreadReadCursor struct {
+	RootId      string `json:"root_id" db:"root_id"`
+	ChannelId   string `json:"channel_id" db:"channel_id"`
+	UserId      string `json:"user_id" db:"user_id"`
+	LastPostSeq int64  `json:"last_post_seq" db:"last_post_seq"` // Replies created at or before this timestamp have been read
+	UpdatedAt   int64  `json:"updated_at" db:"updated_at"`
+}
+
 // ReadCursorAdvanceRequest is the request body for advancing a read cursor
 type ReadCursorAdvanceRequest struct {
 	LastPostSeq int64  `json:"last_post_seq,omitempty"` // Direct sequence number
 	PostId      string `json:"post_id,omitempty"`       // Alternative: derive seq from post
 }
 
+const (
+	// ReadCursorEventTypeAdvanced is the type of the event published when a read cursor moves forward
+	ReadCursorEventTypeAdvanced = "channel_read_advanced"
+	// ReadCursorEventTypeRemoved is the type of the event published when read cursors are deleted
+	// because a user stopped sharing their read state or a channel disabled read receipts. An empty
+	// UserId means every cursor in the channel was removed.
+	ReadCursorEventTypeRemoved = "channel_read_cursor_removed"
+	// ReadCursorEventTypeExpired is the type of the event published when data retention deletes a
+	// cursor positioned before the retention cutoff. It is the user's cursor in the thread when
+	// RootId is set, their channel cursor otherwise; their other cursors are kept.
+	ReadCursorEventTypeExpired = "channel_read_cursor_expired"
+)
+
 // ReadCursorEvent is the event published to the read index service
 type ReadCursorEvent struct {
 	Type        string `json:"type"`
 	EventId     string `json:"event_id"`
 	ChannelId   string `json:"channel_id"`
+	RootId      string `json:"root_id,omitempty"` // Set for thread cursors
 	UserId      string `json:"user_id"`
 	PrevLastSeq int64  `json:"prev_last_seq"`
 	NewLastSeq  int64  `json:"new_last_seq"`
 	Timestamp   int64  `json:"timestamp"`
 }
 
+// ReadCursorOutboxEntry is a read cursor event waiting to be published to the read index service.
+// Entries are written in the same transaction as the cursor they describe.
+type ReadCursorOutboxEntry struct {
+	Id            int64  `json:"id" db:"id"`
+	EventId       string `json:"event_id" db:"event_id"`
+	ChannelId     string `json:"channel_id" db:"channel_id"`
+	Payload       string `json:"payload" db:"payload"` // JSON encoded ReadCursorEvent
+	CreateAt      int64  `json:"create_at" db:"create_at"`
+	Attempts      int    `json:"attempts" db:"attempts"`
+	NextAttemptAt int64  `json:"next_attempt_at" db:"next_attempt_at"`
+	LastError     string `json:"last_error" db:"last_error"`
+}
+
+// ReadCursorOutboxDeliveryDelay is how long, in milliseconds, a new outbox entry is left for the
+// request that created it to publish directly before the drain job picks it up
+const ReadCursorOutboxDeliveryDelay int64 = 30 * 1000
+
+// ReadCursorOutboxStats describes the backlog of unpublished read cursor events
+type ReadCursorOutboxStats struct {
+	Pending        int64 `json:"pending" db:"pending"`
+	OldestCreateAt int64 `json:"oldest_create_at" db:"oldest_create_at"`
+}
+
+// Reasons a post is missing from PostsReadReceiptsCounts.Counts
+const (
+	ReadReceiptsCountErrorNotFound  = "not_found"            // The post doesn't exist or was deleted
+	ReadReceiptsCountErrorForbidden = "forbidden"            // The user can't read the post's channel
+	ReadReceiptsCountErrorHidden    = "read_receipts_hidden" // Read receipts are off in the channel or for the user
+)
+
+// PostsReadReceiptsCounts is the response of the batch read receipts count endpoint. Every
+// requested post id is a key of exactly one of Counts and Errors.
+type PostsReadReceiptsCounts struct {
+	Counts map[string]int64  `json:"counts"`
+	Errors map[string]string `json:"errors"`
+}
+
+// PostNonReaders is a page of the members who haven't read a post
+type PostNonReaders struct {
+	Users      []*User `json:"users"`
+	TotalCount int64   `json:"total_count"`
+}
+
+// ReadReceiptsDailySummary summarizes how many channel members read the root posts created on a
+// given UTC day. Counts are summed over the posts and based on the channel's current members.
+type ReadReceiptsDailySummary struct {
+	Date           string  `json:"date" db:"date"` // YYYY-MM-DD
+	PostCount      int64   `json:"post_count" db:"post_count"`
+	ReadCount      int64   `json:"read_count" db:"read_count"`         // Members other than the author who read the posts
+	ExpectedCount  int64   `json:"expected_count" db:"expected_count"` // Members other than the author
+	ReadPercentage float64 `json:"read_percentage" db:"-"`
+}
+
+// readPercentage returns the share of expected reads that happened, from 0 to 100
+func readPercentage(readCount, expectedCount int64) float64 {
+	if expectedCount == 0 {
+		return 0
+	}
+	return float64(readCount) * 100 / float64(expectedCount)
+}
+
+// ChannelReadReceiptsSummary is the read receipts dashboard of a channel
+type ChannelReadReceiptsSummary struct {
+	ChannelId      string                      `json:"channel_id"`
+	Since          int64                       `json:"since"`
+	ReadPercentage float64                     `json:"read_percentage"` // Over the whole period
+	Days           []*ReadReceiptsDailySummary `json:"days"`
+}
+
+// NewChannelReadReceiptsSummary builds the summary of a channel from its daily summaries,
+// computing the read percentages
+func NewChannelReadReceiptsSummary(channelId string, since int64, days []*ReadReceiptsDailySummary) *ChannelReadReceiptsSummary {
+	var readCount, expectedCount int64
+	for _, day := range days {
+		day.ReadPercentage = readPercentage(day.ReadCount, day.ExpectedCount)
+		readCount += day.ReadCount
+		expectedCount += day.ExpectedCount
+	}
+
+	return &ChannelReadReceiptsSummary{
+		ChannelId:      channelId,
+		Since:          since,
+		ReadPercentage: readPercentage(readCount, expectedCount),
+		Days:           days,
+	}
+}
+
 // IsValid validates the ChannelReadCursor
 func (c *ChannelReadCursor) IsValid() *AppError {
 	if !IsValidId(c.ChannelId) {
@@ -62,6 +173,38 @@ func (c *ChannelReadCursor) PreSave() {
 	}
 }
 
+// IsValid validates the ThreadReadCursor
+func (c *ThreadReadCursor) IsValid() *AppError {
+	if !IsValidId(c.RootId) {
+		return NewAppError("ThreadReadCursor.IsValid", "model.thread_read_cursor.is_valid.root_id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if !IsValidId(c.ChannelId) {
+		return NewAppError("ThreadReadCursor.IsValid", "model.channel_read_cursor.is_valid.channel_id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if !IsValidId(c.UserId) {
+		return NewAppError("ThreadReadCursor.IsValid", "model.channel_read_cursor.is_valid.user_id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if c.LastPostSeq < 0 {
+		return NewAppError("ThreadReadCursor.IsValid", "model.channel_read_cursor.is_valid.seq.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if c.UpdatedAt == 0 {
+		return NewAppError("ThreadReadCursor.IsValid", "model.channel_read_cursor.is_valid.updated_at.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// PreSave will set default values
+func (c *ThreadReadCursor) PreSave() {
+	if c.UpdatedAt == 0 {
+		c.UpdatedAt = GetMillis()
+	}
+}
+
 // ToJSON converts ChannelReadCursor to JSON
 func (c *ChannelReadCursor) ToJSON() string {
 	b, _ := json.Marshal(c)
@@ -167,3 +167,21 @@ func TestReadCursorEventJSON(t *testing.T) {
 	assert.Equal(t, event.NewLastSeq, decoded.NewLastSeq)
 	assert.Equal(t, event.Timestamp, decoded.Timestamp)
 }
+
+func TestNewChannelReadReceiptsSummary(t *testing.T) {
+	days := []*ReadReceiptsDailySummary{
+		{Date: "2026-01-01", PostCount: 2, ReadCount: 3, ExpectedCount: 4},
+		{Date: "2026-01-02", PostCount: 1, ReadCount: 1, ExpectedCount: 4},
+		{Date: "2026-01-03", PostCount: 1, ReadCount: 0, ExpectedCount: 0},
+	}
+
+	summary := NewChannelReadReceiptsSummary("channel", 1000, days)
+	assert.Equal(t, "channel", summary.ChannelId)
+	assert.Equal(t, int64(1000), summary.Since)
+	assert.Equal(t, 50.0, summary.ReadPercentage)
+	assert.Equal(t, 75.0, summary.Days[0].ReadPercentage)
+	assert.Equal(t, 25.0, summary.Days[1].ReadPercentage)
+	assert.Equal(t, 0.0, summary.Days[2].ReadPercentage)
+
+	assert.Equal(t, 0.0, NewChannelReadReceiptsSummary("channel", 1000, nil).ReadPercentage)
+}
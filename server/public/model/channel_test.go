@@ -33,6 +33,26 @@ func TestChannelPatch(t *testing.T) {
 	require.Equal(t, *p.Header, o.Header)
 	require.Equal(t, *p.Purpose, o.Purpose)
 	require.Equal(t, *p.GroupConstrained, *o.GroupConstrained)
+	require.False(t, o.ReadReceiptsDisabled())
+
+	o.Patch(&ChannelPatch{ReadReceiptsDisabled: NewPointer(true)})
+	require.True(t, o.ReadReceiptsDisabled())
+	require.Equal(t, true, o.Props[ChannelPropsReadReceiptsDisabled])
+
+	o.Patch(&ChannelPatch{ReadReceiptsDisabled: NewPointer(false)})
+	require.False(t, o.ReadReceiptsDisabled())
+}
+
+func TestChannelPersistentProps(t *testing.T) {
+	o := Channel{Id: NewId()}
+	require.Nil(t, o.PersistentProps())
+
+	o.AddProp(ChannelPropsReadReceiptsDisabled, true)
+	o.AddProp(ChannelPropsChannelMentions, map[string]any{"town-square": map[string]any{"display_name": "Town Square"}})
+
+	props := o.PersistentProps()
+	require.Equal(t, StringInterface{ChannelPropsReadReceiptsDisabled: true}, props)
+	require.Contains(t, o.Props, ChannelPropsChannelMentions, "the channel itself is left unchanged")
 }
 
 func TestChannelIsValid(t *testing.T) {
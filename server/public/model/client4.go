@@ -845,6 +845,21 @@ func (c *Client4) LoginWithMFA(ctx context.Context, loginId, password, mfaToken
 	return c.login(ctx, m)
 }
 
+// GetWebAuthnLoginChallenge checks the credentials of a user and returns the challenge to
+// sign with one of their WebAuthn credentials. The resulting WebAuthnAssertion is then passed,
+// JSON encoded, as the MFA token of LoginWithMFA.
+func (c *Client4) GetWebAuthnLoginChallenge(ctx context.Context, loginId, password string) (*WebAuthnCeremony, *Response, error) {
+	m := make(map[string]string)
+	m["login_id"] = loginId
+	m["password"] = password
+	r, err := c.DoAPIPostJSON(ctx, "/users/login/webauthn", m)
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return DecodeJSONFromResponse[*WebAuthnCeremony](r)
+}
+
 func (c *Client4) login(ctx context.Context, m map[string]string) (*User, *Response, error) {
 	r, err := c.DoAPIPostJSON(ctx, "/users/login", m)
 	if err != nil {
@@ -1363,6 +1378,70 @@ func (c *Client4) GenerateMfaSecret(ctx context.Context, userId string) (*MfaSec
 	return DecodeJSONFromResponse[*MfaSecret](r)
 }
 
+// GetUserMfaFactors returns the multi-factor authentication factors configured for a user.
+func (c *Client4) GetUserMfaFactors(ctx context.Context, userId string) (*MfaFactors, *Response, error) {
+	r, err := c.DoAPIGet(ctx, c.userRoute(userId)+"/mfa/factors", "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return DecodeJSONFromResponse[*MfaFactors](r)
+}
+
+// ResetUserMfaFactors removes all the multi-factor authentication factors of a user,
+// deactivating multi-factor authentication.
+func (c *Client4) ResetUserMfaFactors(ctx context.Context, userId string) (*Response, error) {
+	r, err := c.DoAPIDelete(ctx, c.userRoute(userId)+"/mfa/factors")
+	if err != nil {
+		return BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return BuildResponse(r), nil
+}
+
+// BeginWebAuthnRegistration starts the registration of a WebAuthn credential for a user,
+// returning the options to pass to navigator.credentials.create().
+func (c *Client4) BeginWebAuthnRegistration(ctx context.Context, userId string) (*WebAuthnCeremony, *Response, error) {
+	r, err := c.DoAPIPost(ctx, c.userRoute(userId)+"/mfa/webauthn/register/begin", "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return DecodeJSONFromResponse[*WebAuthnCeremony](r)
+}
+
+// FinishWebAuthnRegistration registers the WebAuthn credential created by the authenticator
+// as a second factor of the user.
+func (c *Client4) FinishWebAuthnRegistration(ctx context.Context, userId string, registration *WebAuthnRegistration) (*WebAuthnCredential, *Response, error) {
+	r, err := c.DoAPIPostJSON(ctx, c.userRoute(userId)+"/mfa/webauthn/register/finish", registration)
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return DecodeJSONFromResponse[*WebAuthnCredential](r)
+}
+
+// DeleteWebAuthnCredential revokes a WebAuthn credential of a user.
+func (c *Client4) DeleteWebAuthnCredential(ctx context.Context, userId, credentialId string) (*Response, error) {
+	r, err := c.DoAPIDelete(ctx, c.userRoute(userId)+"/mfa/webauthn/"+credentialId)
+	if err != nil {
+		return BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return BuildResponse(r), nil
+}
+
+// GenerateMfaRecoveryCodes replaces the multi-factor authentication recovery codes of a user
+// and returns the new ones.
+func (c *Client4) GenerateMfaRecoveryCodes(ctx context.Context, userId string) (*MfaRecoveryCodes, *Response, error) {
+	r, err := c.DoAPIPost(ctx, c.userRoute(userId)+"/mfa/recovery_codes", "")
+	if err != nil {
+		return nil, BuildResponse(r), err
+	}
+	defer closeBody(r)
+	return DecodeJSONFromResponse[*MfaRecoveryCodes](r)
+}
+
 // UpdateUserPassword updates a user's password. Must be logged in as the user or be a system administrator.
 func (c *Client4) UpdateUserPassword(ctx context.Context, userId, currentPassword, newPassword string) (*Response, error) {
 	requestBody := map[string]string{"current_password": currentPassword, "new_password": newPassword}
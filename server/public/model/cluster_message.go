@@ -37,6 +37,8 @@ const (
 	ClusterEventInvalidateCacheForPostsUsage                ClusterEvent = "inv_posts_usage"
 	ClusterEventInvalidateCacheForTeams                     ClusterEvent = "inv_teams"
 	ClusterEventInvalidateCacheForContentFlagging           ClusterEvent = "inv_content_flagging"
+	ClusterEventInvalidateCacheForReadReceiptsCounts        ClusterEvent = "inv_read_receipts_counts"
+	ClusterEventInvalidateCacheForReadStateHiddenMembers    ClusterEvent = "inv_read_state_hidden_members"
 	ClusterEventClearSessionCacheForAllUsers                ClusterEvent = "inv_all_user_sessions"
 	ClusterEventInstallPlugin                               ClusterEvent = "install_plugin"
 	ClusterEventRemovePlugin                                ClusterEvent = "remove_plugin"
@@ -61,6 +63,8 @@ const (
 	ClusterGossipEventResponseWebConnCount          = "gossip_response_webconn_count"
 	ClusterGossipEventRequestWSQueues               = "gossip_request_ws_queues"
 	ClusterGossipEventResponseWSQueues              = "gossip_response_ws_queues"
+	ClusterGossipEventRequestGetClusterInfo         = "gossip_request_cluster_info"
+	ClusterGossipEventResponseGetClusterInfo        = "gossip_response_cluster_info"
 
 	// SendTypes for ClusterMessage.
 	ClusterSendBestEffort = "best_effort"
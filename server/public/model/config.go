@@ -44,9 +44,17 @@ const (
 	MinioSecretKey = "miniosecretkey"
 	MinioBucket    = "mattermost-test"
 
-	PasswordMaximumLength = 72
+	PasswordMaximumLength = 256
 	PasswordMinimumLength = 5
 
+	PasswordHashingAlgorithmArgon2id = "argon2id"
+	PasswordHashingAlgorithmPBKDF2   = "pbkdf2"
+
+	PasswordArgon2idDefaultMemory      = 19456 // in KiB
+	PasswordArgon2idDefaultIterations  = 2
+	PasswordArgon2idDefaultParallelism = 1
+	PasswordArgon2idMaximumParallelism = 255
+
 	ServiceGitlab = "gitlab"
 
 	ServiceGoogle    = "google"
@@ -106,6 +114,9 @@ const (
 	CacheTypeLRU   = "lru"
 	CacheTypeRedis = "redis"
 
+	RateLimitStoreMemory = "memory"
+	RateLimitStoreRedis  = "redis"
+
 	SitenameMaxLength = 30
 
 	ServiceSettingsDefaultSiteURL                = "http://localhost:8065"
@@ -141,6 +152,10 @@ const (
 	ExportSettingsDefaultDirectory     = "./export"
 	ExportSettingsDefaultRetentionDays = 30
 
+	ReadReceiptsSettingsDefaultTimeoutMilliseconds = 500
+	ReadReceiptsSettingsDefaultFailureThreshold    = 5
+	ReadReceiptsSettingsDefaultCooldownSeconds     = 30
+
 	EmailSettingsDefaultFeedbackOrganization = ""
 
 	SupportSettingsDefaultTermsOfServiceLink = "https://mattermost.com/pl/terms-of-use/"
@@ -224,6 +239,9 @@ const (
 	ElasticsearchSettingsESBackend                          = "elasticsearch"
 	ElasticsearchSettingsOSBackend                          = "opensearch"
 
+	EmbeddedSearchSettingsDefaultAnalyzer  = "cjk"
+	EmbeddedSearchSettingsDefaultBatchSize = 10000
+
 	DataRetentionSettingsDefaultMessageRetentionDays           = 365
 	DataRetentionSettingsDefaultMessageRetentionHours          = 0
 	DataRetentionSettingsDefaultFileRetentionDays              = 365
@@ -1657,6 +1675,13 @@ type PasswordSettings struct {
 	Uppercase        *bool `access:"authentication_password"`
 	Symbol           *bool `access:"authentication_password"`
 	EnableForgotLink *bool `access:"authentication_password"`
+
+	// HashingAlgorithm is the hasher of new passwords. The existing passwords are
+	// rehashed with it, and with the parameters below, when their users log in.
+	HashingAlgorithm    *string `access:"authentication_password"`
+	Argon2idMemory      *int    `access:"authentication_password"` // in KiB
+	Argon2idIterations  *int    `access:"authentication_password"`
+	Argon2idParallelism *int    `access:"authentication_password"`
 }
 
 func (s *PasswordSettings) SetDefaults() {
@@ -1683,6 +1708,43 @@ func (s *PasswordSettings) SetDefaults() {
 	if s.EnableForgotLink == nil {
 		s.EnableForgotLink = NewPointer(true)
 	}
+
+	if s.HashingAlgorithm == nil {
+		s.HashingAlgorithm = NewPointer(PasswordHashingAlgorithmArgon2id)
+	}
+
+	if s.Argon2idMemory == nil {
+		s.Argon2idMemory = NewPointer(PasswordArgon2idDefaultMemory)
+	}
+
+	if s.Argon2idIterations == nil {
+		s.Argon2idIterations = NewPointer(PasswordArgon2idDefaultIterations)
+	}
+
+	if s.Argon2idParallelism == nil {
+		s.Argon2idParallelism = NewPointer(PasswordArgon2idDefaultParallelism)
+	}
+}
+
+func (s *PasswordSettings) isValid() *AppError {
+	if *s.MinimumLength < PasswordMinimumLength || *s.MinimumLength > PasswordMaximumLength {
+		return NewAppError("Config.IsValid", "model.config.is_valid.password_length.app_error", map[string]any{"MinLength": PasswordMinimumLength, "MaxLength": PasswordMaximumLength}, "", http.StatusBadRequest)
+	}
+
+	switch *s.HashingAlgorithm {
+	case PasswordHashingAlgorithmArgon2id:
+		// Argon2 needs at least 8 KiB of memory per thread
+		if *s.Argon2idIterations <= 0 || int64(*s.Argon2idIterations) > math.MaxUint32 ||
+			*s.Argon2idParallelism <= 0 || *s.Argon2idParallelism > PasswordArgon2idMaximumParallelism ||
+			*s.Argon2idMemory < 8**s.Argon2idParallelism || int64(*s.Argon2idMemory) > math.MaxUint32 {
+			return NewAppError("Config.IsValid", "model.config.is_valid.password_argon2id.app_error", nil, "", http.StatusBadRequest)
+		}
+	case PasswordHashingAlgorithmPBKDF2:
+	default:
+		return NewAppError("Config.IsValid", "model.config.is_valid.password_hashing_algorithm.app_error", map[string]any{"Algorithm": *s.HashingAlgorithm}, "", http.StatusBadRequest)
+	}
+
+	return nil
 }
 
 type FileSettings struct {
@@ -1712,6 +1774,10 @@ type FileSettings struct {
 	AmazonS3RequestTimeoutMilliseconds *int64  `access:"environment_file_storage,write_restrictable,cloud_restrictable"` // telemetry: none
 	AmazonS3UploadPartSizeBytes        *int64  `access:"environment_file_storage,write_restrictable,cloud_restrictable"` // telemetry: none
 	AmazonS3StorageClass               *string `access:"environment_file_storage,write_restrictable,cloud_restrictable"` // telemetry: none
+	EncryptAtRest                      *bool   `access:"environment_file_storage,write_restrictable,cloud_restrictable"`
+	EncryptionKey                      *string `access:"environment_file_storage,write_restrictable,cloud_restrictable"` // telemetry: none
+	EncryptionKeyFile                  *string `access:"environment_file_storage,write_restrictable,cloud_restrictable"` // telemetry: none
+	EncryptionAllowUnencryptedFiles    *bool   `access:"environment_file_storage,write_restrictable,cloud_restrictable"`
 	// Export store settings
 	DedicatedExportStore                     *bool   `access:"environment_file_storage,write_restrictable"`
 	ExportDriverName                         *string `access:"environment_file_storage,write_restrictable"`
@@ -1730,6 +1796,7 @@ type FileSettings struct {
 	ExportAmazonS3PresignExpiresSeconds      *int64  `access:"environment_file_storage,write_restrictable"` // telemetry: none
 	ExportAmazonS3UploadPartSizeBytes        *int64  `access:"environment_file_storage,write_restrictable"` // telemetry: none
 	ExportAmazonS3StorageClass               *string `access:"environment_file_storage,write_restrictable"` // telemetry: none
+	ExportEncryptAtRest                      *bool   `access:"environment_file_storage,write_restrictable"`
 }
 
 func (s *FileSettings) SetDefaults(isUpdate bool) {
@@ -1915,6 +1982,26 @@ func (s *FileSettings) SetDefaults(isUpdate bool) {
 	if s.ExportAmazonS3StorageClass == nil {
 		s.ExportAmazonS3StorageClass = NewPointer("")
 	}
+
+	if s.EncryptAtRest == nil {
+		s.EncryptAtRest = NewPointer(false)
+	}
+
+	if s.EncryptionKey == nil {
+		s.EncryptionKey = NewPointer("")
+	}
+
+	if s.EncryptionKeyFile == nil {
+		s.EncryptionKeyFile = NewPointer("")
+	}
+
+	if s.EncryptionAllowUnencryptedFiles == nil {
+		s.EncryptionAllowUnencryptedFiles = NewPointer(false)
+	}
+
+	if s.ExportEncryptAtRest == nil {
+		s.ExportEncryptAtRest = NewPointer(false)
+	}
 }
 
 type EmailSettings struct {
@@ -1940,7 +2027,7 @@ type EmailSettings struct {
 	PushNotificationContents          *string `access:"site_notifications"`
 	PushNotificationBuffer            *int    // telemetry: none
 	EnableEmailBatching               *bool   `access:"site_notifications"`
-	EmailBatchingBufferSize           *int    `access:"experimental_features"`
+	EmailBatchingBufferSize           *int    `access:"experimental_features"` // Deprecated: the batched notifications are kept in the database, no buffer limits them anymore
 	EmailBatchingInterval             *int    `access:"experimental_features"`
 	EnablePreviewModeBanner           *bool   `access:"site_notifications"`
 	SkipServerCertificateVerification *bool   `access:"environment_smtp,write_restrictable,cloud_restrictable"`
@@ -2100,6 +2187,20 @@ type RateLimitSettings struct {
 	VaryByRemoteAddr *bool  `access:"environment_rate_limiting,write_restrictable,cloud_restrictable"`
 	VaryByUser       *bool  `access:"environment_rate_limiting,write_restrictable,cloud_restrictable"`
 	VaryByHeader     string `access:"environment_rate_limiting,write_restrictable,cloud_restrictable"`
+	// Store is where the rate limits are tracked. The memory store is per node, while the Redis
+	// store, which uses the client of CacheSettings, shares them across the cluster.
+	Store *string `access:"environment_rate_limiting,write_restrictable,cloud_restrictable"`
+	// The quotas of the routes apply instead of PerSec and MaxBurst to their requests
+	LoginQuota      *RateLimitQuota `access:"environment_rate_limiting,write_restrictable,cloud_restrictable"`
+	CreatePostQuota *RateLimitQuota `access:"environment_rate_limiting,write_restrictable,cloud_restrictable"`
+	UploadFileQuota *RateLimitQuota `access:"environment_rate_limiting,write_restrictable,cloud_restrictable"`
+	SearchQuota     *RateLimitQuota `access:"environment_rate_limiting,write_restrictable,cloud_restrictable"`
+}
+
+// RateLimitQuota is the quota of a route. The route shares the global quota while PerMin is zero.
+type RateLimitQuota struct {
+	PerMin   *int `access:"environment_rate_limiting,write_restrictable,cloud_restrictable"`
+	MaxBurst *int `access:"environment_rate_limiting,write_restrictable,cloud_restrictable"`
 }
 
 func (s *RateLimitSettings) SetDefaults() {
@@ -2126,6 +2227,40 @@ func (s *RateLimitSettings) SetDefaults() {
 	if s.VaryByUser == nil {
 		s.VaryByUser = NewPointer(false)
 	}
+
+	if s.Store == nil {
+		s.Store = NewPointer(RateLimitStoreMemory)
+	}
+
+	if s.LoginQuota == nil {
+		s.LoginQuota = &RateLimitQuota{}
+	}
+	s.LoginQuota.setDefaults()
+
+	if s.CreatePostQuota == nil {
+		s.CreatePostQuota = &RateLimitQuota{}
+	}
+	s.CreatePostQuota.setDefaults()
+
+	if s.UploadFileQuota == nil {
+		s.UploadFileQuota = &RateLimitQuota{}
+	}
+	s.UploadFileQuota.setDefaults()
+
+	if s.SearchQuota == nil {
+		s.SearchQuota = &RateLimitQuota{}
+	}
+	s.SearchQuota.setDefaults()
+}
+
+func (s *RateLimitQuota) setDefaults() {
+	if s.PerMin == nil {
+		s.PerMin = NewPointer(0)
+	}
+
+	if s.MaxBurst == nil {
+		s.MaxBurst = NewPointer(0)
+	}
 }
 
 type PrivacySettings struct {
@@ -3160,6 +3295,42 @@ func (s *ElasticsearchSettings) SetDefaults() {
 	}
 }
 
+type EmbeddedSearchSettings struct {
+	IndexDir           *string `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"` // telemetry: none
+	EnableIndexing     *bool   `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
+	EnableSearching    *bool   `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
+	EnableAutocomplete *bool   `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
+	// Analyzer is the name of the analyzer splitting text into terms, such as standard or cjk
+	Analyzer  *string `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
+	BatchSize *int    `access:"environment_elasticsearch,write_restrictable,cloud_restrictable"`
+}
+
+func (s *EmbeddedSearchSettings) SetDefaults() {
+	if s.IndexDir == nil {
+		s.IndexDir = NewPointer("")
+	}
+
+	if s.EnableIndexing == nil {
+		s.EnableIndexing = NewPointer(false)
+	}
+
+	if s.EnableSearching == nil {
+		s.EnableSearching = NewPointer(false)
+	}
+
+	if s.EnableAutocomplete == nil {
+		s.EnableAutocomplete = NewPointer(false)
+	}
+
+	if s.Analyzer == nil {
+		s.Analyzer = NewPointer(EmbeddedSearchSettingsDefaultAnalyzer)
+	}
+
+	if s.BatchSize == nil {
+		s.BatchSize = NewPointer(EmbeddedSearchSettingsDefaultBatchSize)
+	}
+}
+
 type DataRetentionSettings struct {
 	EnableMessageDeletion          *bool   `access:"compliance_data_retention_policy"`
 	EnableFileDeletion             *bool   `access:"compliance_data_retention_policy"`
@@ -3792,6 +3963,77 @@ func (s *AccessControlSettings) SetDefaults() {
 	}
 }
 
+type ReadReceiptsSettings struct {
+	ReadIndexServiceURL                 *string `access:"site_posts,write_restrictable,cloud_restrictable"` // telemetry: none
+	ReadIndexServiceTimeoutMilliseconds *int    `access:"site_posts,write_restrictable,cloud_restrictable"`
+	CircuitBreakerFailureThreshold      *int    `access:"site_posts,write_restrictable,cloud_restrictable"`
+	CircuitBreakerCooldownSeconds       *int    `access:"site_posts,write_restrictable,cloud_restrictable"`
+	// ShareReadStateByDefault applies to users who have not set the share_read_state preference
+	ShareReadStateByDefault *bool `access:"site_posts"`
+	// TeamShareReadStateDefaults overrides ShareReadStateByDefault for the given team ids
+	TeamShareReadStateDefaults map[string]bool `access:"site_posts"` // telemetry: none
+}
+
+func (s *ReadReceiptsSettings) SetDefaults() {
+	if s.ReadIndexServiceURL == nil {
+		s.ReadIndexServiceURL = NewPointer("")
+	}
+
+	if s.ReadIndexServiceTimeoutMilliseconds == nil {
+		s.ReadIndexServiceTimeoutMilliseconds = NewPointer(ReadReceiptsSettingsDefaultTimeoutMilliseconds)
+	}
+
+	if s.CircuitBreakerFailureThreshold == nil {
+		s.CircuitBreakerFailureThreshold = NewPointer(ReadReceiptsSettingsDefaultFailureThreshold)
+	}
+
+	if s.CircuitBreakerCooldownSeconds == nil {
+		s.CircuitBreakerCooldownSeconds = NewPointer(ReadReceiptsSettingsDefaultCooldownSeconds)
+	}
+
+	if s.ShareReadStateByDefault == nil {
+		s.ShareReadStateByDefault = NewPointer(true)
+	}
+
+	if s.TeamShareReadStateDefaults == nil {
+		s.TeamShareReadStateDefaults = make(map[string]bool)
+	}
+}
+
+// ShareReadStateDefault returns whether users in the team share their read state unless they choose otherwise
+func (s *ReadReceiptsSettings) ShareReadStateDefault(teamId string) bool {
+	if teamDefault, ok := s.TeamShareReadStateDefaults[teamId]; ok && teamId != "" {
+		return teamDefault
+	}
+	return *s.ShareReadStateByDefault
+}
+
+func (s *ReadReceiptsSettings) isValid() *AppError {
+	if *s.ReadIndexServiceURL != "" && !IsValidHTTPURL(*s.ReadIndexServiceURL) {
+		return NewAppError("Config.IsValid", "model.config.is_valid.read_receipts.read_index_service_url.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if *s.ReadIndexServiceTimeoutMilliseconds <= 0 {
+		return NewAppError("Config.IsValid", "model.config.is_valid.read_receipts.timeout.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if *s.CircuitBreakerFailureThreshold <= 0 {
+		return NewAppError("Config.IsValid", "model.config.is_valid.read_receipts.failure_threshold.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if *s.CircuitBreakerCooldownSeconds <= 0 {
+		return NewAppError("Config.IsValid", "model.config.is_valid.read_receipts.cooldown.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	for teamId := range s.TeamShareReadStateDefaults {
+		if !IsValidId(teamId) {
+			return NewAppError("Config.IsValid", "model.config.is_valid.read_receipts.team_defaults.app_error", map[string]any{"TeamId": teamId}, "", http.StatusBadRequest)
+		}
+	}
+
+	return nil
+}
+
 type ConfigFunc func() *Config
 
 const (
@@ -3869,6 +4111,7 @@ type Config struct {
 	ExperimentalSettings        ExperimentalSettings
 	AnalyticsSettings           AnalyticsSettings
 	ElasticsearchSettings       ElasticsearchSettings
+	EmbeddedSearchSettings      EmbeddedSearchSettings
 	DataRetentionSettings       DataRetentionSettings
 	MessageExportSettings       MessageExportSettings
 	JobSettings                 JobSettings
@@ -3885,6 +4128,7 @@ type Config struct {
 	AccessControlSettings       AccessControlSettings
 	ContentFlaggingSettings     ContentFlaggingSettings
 	AutoTranslationSettings     AutoTranslationSettings
+	ReadReceiptsSettings        ReadReceiptsSettings
 }
 
 func (o *Config) Auditable() map[string]any {
@@ -3982,6 +4226,7 @@ func (o *Config) SetDefaults() {
 	o.LocalizationSettings.SetDefaults()
 	o.AutoTranslationSettings.SetDefaults()
 	o.ElasticsearchSettings.SetDefaults()
+	o.EmbeddedSearchSettings.SetDefaults()
 	o.NativeAppSettings.SetDefaults()
 	o.DataRetentionSettings.SetDefaults()
 	o.RateLimitSettings.SetDefaults()
@@ -4003,6 +4248,7 @@ func (o *Config) SetDefaults() {
 	o.ConnectedWorkspacesSettings.SetDefaults(isUpdate, o.ExperimentalSettings)
 	o.AccessControlSettings.SetDefaults()
 	o.ContentFlaggingSettings.SetDefaults()
+	o.ReadReceiptsSettings.SetDefaults()
 }
 
 func (o *Config) IsValid() *AppError {
@@ -4010,10 +4256,6 @@ func (o *Config) IsValid() *AppError {
 		return NewAppError("Config.IsValid", "model.config.is_valid.site_url_email_batching.app_error", nil, "", http.StatusBadRequest)
 	}
 
-	if *o.ClusterSettings.Enable && *o.EmailSettings.EnableEmailBatching {
-		return NewAppError("Config.IsValid", "model.config.is_valid.cluster_email_batching.app_error", nil, "", http.StatusBadRequest)
-	}
-
 	if appErr := o.MetricsSettings.isValid(); appErr != nil {
 		return appErr
 	}
@@ -4054,14 +4296,18 @@ func (o *Config) IsValid() *AppError {
 		return appErr
 	}
 
-	if *o.PasswordSettings.MinimumLength < PasswordMinimumLength || *o.PasswordSettings.MinimumLength > PasswordMaximumLength {
-		return NewAppError("Config.IsValid", "model.config.is_valid.password_length.app_error", map[string]any{"MinLength": PasswordMinimumLength, "MaxLength": PasswordMaximumLength}, "", http.StatusBadRequest)
+	if appErr := o.PasswordSettings.isValid(); appErr != nil {
+		return appErr
 	}
 
 	if appErr := o.RateLimitSettings.isValid(); appErr != nil {
 		return appErr
 	}
 
+	if *o.RateLimitSettings.Enable && *o.RateLimitSettings.Store == RateLimitStoreRedis && *o.CacheSettings.CacheType != CacheTypeRedis {
+		return NewAppError("Config.IsValid", "model.config.is_valid.rate_limit_redis_store.app_error", nil, "", http.StatusBadRequest)
+	}
+
 	if appErr := o.ServiceSettings.isValid(); appErr != nil {
 		return appErr
 	}
@@ -4070,6 +4316,10 @@ func (o *Config) IsValid() *AppError {
 		return appErr
 	}
 
+	if appErr := o.EmbeddedSearchSettings.isValid(); appErr != nil {
+		return appErr
+	}
+
 	if appErr := o.DataRetentionSettings.isValid(); appErr != nil {
 		return appErr
 	}
@@ -4134,6 +4384,10 @@ func (o *Config) IsValid() *AppError {
 		return appErr
 	}
 
+	if appErr := o.ReadReceiptsSettings.isValid(); appErr != nil {
+		return appErr
+	}
+
 	return nil
 }
 
@@ -4271,6 +4525,12 @@ func (s *FileSettings) isValid() *AppError {
 		return NewAppError("Config.IsValid", "model.config.is_valid.directory_whitespace.app_error", map[string]any{"Setting": "FileSettings.ExportDirectory", "Value": *s.ExportDirectory}, "", http.StatusBadRequest)
 	}
 
+	if *s.EncryptAtRest || (*s.DedicatedExportStore && *s.ExportEncryptAtRest) {
+		if (*s.EncryptionKey == "") == (*s.EncryptionKeyFile == "") {
+			return NewAppError("Config.IsValid", "model.config.is_valid.file_encryption_key.app_error", nil, "", http.StatusBadRequest)
+		}
+	}
+
 	return nil
 }
 
@@ -4279,10 +4539,6 @@ func (s *EmailSettings) isValid() *AppError {
 		return NewAppError("Config.IsValid", "model.config.is_valid.email_security.app_error", nil, "", http.StatusBadRequest)
 	}
 
-	if *s.EmailBatchingBufferSize <= 0 {
-		return NewAppError("Config.IsValid", "model.config.is_valid.email_batching_buffer_size.app_error", nil, "", http.StatusBadRequest)
-	}
-
 	if *s.EmailBatchingInterval < 30 {
 		return NewAppError("Config.IsValid", "model.config.is_valid.email_batching_interval.app_error", nil, "", http.StatusBadRequest)
 	}
@@ -4307,6 +4563,16 @@ func (s *RateLimitSettings) isValid() *AppError {
 		return NewAppError("Config.IsValid", "model.config.is_valid.max_burst.app_error", nil, "", http.StatusBadRequest)
 	}
 
+	if *s.Store != RateLimitStoreMemory && *s.Store != RateLimitStoreRedis {
+		return NewAppError("Config.IsValid", "model.config.is_valid.rate_limit_store.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	for _, quota := range []*RateLimitQuota{s.LoginQuota, s.CreatePostQuota, s.UploadFileQuota, s.SearchQuota} {
+		if *quota.PerMin < 0 || *quota.MaxBurst < 0 {
+			return NewAppError("Config.IsValid", "model.config.is_valid.rate_limit_quota.app_error", nil, "", http.StatusBadRequest)
+		}
+	}
+
 	return nil
 }
 
@@ -4640,6 +4906,36 @@ func (s *ElasticsearchSettings) isValid() *AppError {
 	return nil
 }
 
+func (s *EmbeddedSearchSettings) isValid() *AppError {
+	if *s.EnableIndexing && *s.IndexDir == "" {
+		return NewAppError("Config.IsValid", "model.config.is_valid.embedded_search.index_dir.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if *s.EnableSearching && !*s.EnableIndexing {
+		return NewAppError("Config.IsValid", "model.config.is_valid.embedded_search.enable_searching.app_error", map[string]any{
+			"Searching":      "EmbeddedSearchSettings.EnableSearching",
+			"EnableIndexing": "EmbeddedSearchSettings.EnableIndexing",
+		}, "", http.StatusBadRequest)
+	}
+
+	if *s.EnableAutocomplete && !*s.EnableIndexing {
+		return NewAppError("Config.IsValid", "model.config.is_valid.embedded_search.enable_autocomplete.app_error", map[string]any{
+			"Autocomplete":   "EmbeddedSearchSettings.EnableAutocomplete",
+			"EnableIndexing": "EmbeddedSearchSettings.EnableIndexing",
+		}, "", http.StatusBadRequest)
+	}
+
+	if *s.Analyzer == "" {
+		return NewAppError("Config.IsValid", "model.config.is_valid.embedded_search.analyzer.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if *s.BatchSize < 1 {
+		return NewAppError("Config.IsValid", "model.config.is_valid.embedded_search.batch_size.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	return nil
+}
+
 func (s *DataRetentionSettings) isValid() *AppError {
 	if s.MessageRetentionDays == nil || *s.MessageRetentionDays < 0 {
 		return NewAppError("Config.IsValid", "model.config.is_valid.data_retention.message_retention_days_too_low.app_error", nil, "", http.StatusBadRequest)
@@ -4690,7 +4986,7 @@ func (s *AutoTranslationSettings) isValid() *AppError {
 	}
 
 	switch *s.Provider {
-	case "libretranslate":
+	case AutoTranslationProviderLibreTranslate:
 		if s.LibreTranslate == nil || s.LibreTranslate.URL == nil || *s.LibreTranslate.URL == "" || !IsValidHTTPURL(*s.LibreTranslate.URL) {
 			return NewAppError("Config.IsValid", "model.config.is_valid.autotranslation.libretranslate.url.app_error", nil, "", http.StatusBadRequest)
 		}
@@ -4862,6 +5158,10 @@ func (o *Config) Sanitize(pluginManifests []*Manifest, opts *SanitizeOptions) {
 		*o.FileSettings.AmazonS3SecretAccessKey = FakeSetting
 	}
 
+	if o.FileSettings.EncryptionKey != nil && *o.FileSettings.EncryptionKey != "" {
+		*o.FileSettings.EncryptionKey = FakeSetting
+	}
+
 	if o.EmailSettings.SMTPPassword != nil && *o.EmailSettings.SMTPPassword != "" {
 		*o.EmailSettings.SMTPPassword = FakeSetting
 	}
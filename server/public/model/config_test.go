@@ -296,6 +296,79 @@ func TestFileSettingsDirectoryWhitespaceValidation(t *testing.T) {
 	}
 }
 
+func TestPasswordSettingsHashingValidation(t *testing.T) {
+	for name, tc := range map[string]struct {
+		update func(s *PasswordSettings)
+		errId  string
+	}{
+		"defaults":                      {update: func(s *PasswordSettings) {}},
+		"pbkdf2":                        {update: func(s *PasswordSettings) { *s.HashingAlgorithm = PasswordHashingAlgorithmPBKDF2 }},
+		"unknown algorithm":             {update: func(s *PasswordSettings) { *s.HashingAlgorithm = "md5" }, errId: "model.config.is_valid.password_hashing_algorithm.app_error"},
+		"no iterations":                 {update: func(s *PasswordSettings) { *s.Argon2idIterations = 0 }, errId: "model.config.is_valid.password_argon2id.app_error"},
+		"no parallelism":                {update: func(s *PasswordSettings) { *s.Argon2idParallelism = 0 }, errId: "model.config.is_valid.password_argon2id.app_error"},
+		"too much parallelism":          {update: func(s *PasswordSettings) { *s.Argon2idParallelism = 256 }, errId: "model.config.is_valid.password_argon2id.app_error"},
+		"not enough memory per thread":  {update: func(s *PasswordSettings) { *s.Argon2idParallelism = 4; *s.Argon2idMemory = 31 }, errId: "model.config.is_valid.password_argon2id.app_error"},
+		"minimum memory for the thread": {update: func(s *PasswordSettings) { *s.Argon2idParallelism = 4; *s.Argon2idMemory = 32 }},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.SetDefaults()
+			tc.update(&cfg.PasswordSettings)
+
+			appErr := cfg.PasswordSettings.isValid()
+			if tc.errId == "" {
+				require.Nil(t, appErr)
+			} else {
+				require.NotNil(t, appErr)
+				assert.Equal(t, tc.errId, appErr.Id)
+			}
+		})
+	}
+}
+
+func TestFileSettingsEncryptionValidation(t *testing.T) {
+	t.Run("disabled", func(t *testing.T) {
+		cfg := &Config{}
+		cfg.SetDefaults()
+
+		require.Nil(t, cfg.FileSettings.isValid())
+	})
+
+	for name, tc := range map[string]struct {
+		key, keyFile string
+		valid        bool
+	}{
+		"no key":            {valid: false},
+		"key":               {key: "key", valid: true},
+		"key file":          {keyFile: "/path/to/keys", valid: true},
+		"key and key file":  {key: "key", keyFile: "/path/to/keys", valid: false},
+		"export store only": {},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg := &Config{}
+			cfg.SetDefaults()
+			if name == "export store only" {
+				*cfg.FileSettings.ExportEncryptAtRest = true
+				require.Nil(t, cfg.FileSettings.isValid(), "the export setting only applies to a dedicated export store")
+
+				*cfg.FileSettings.DedicatedExportStore = true
+			} else {
+				*cfg.FileSettings.EncryptAtRest = true
+			}
+			*cfg.FileSettings.EncryptionKey = tc.key
+			*cfg.FileSettings.EncryptionKeyFile = tc.keyFile
+
+			err := cfg.FileSettings.isValid()
+			if tc.valid {
+				require.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+				assert.Equal(t, "model.config.is_valid.file_encryption_key.app_error", err.Id)
+			}
+		})
+	}
+}
+
 func TestConfigDefaultSignatureAlgorithm(t *testing.T) {
 	c1 := Config{}
 	c1.SetDefaults()
@@ -1513,6 +1586,7 @@ func TestConfigSanitize(t *testing.T) {
 
 	*c.LdapSettings.BindPassword = "foo"
 	*c.FileSettings.AmazonS3SecretAccessKey = "bar"
+	*c.FileSettings.EncryptionKey = "qux"
 	*c.EmailSettings.SMTPPassword = "baz"
 	*c.GitLabSettings.Secret = "bingo"
 	*c.OpenIdSettings.Secret = "secret"
@@ -1529,6 +1603,7 @@ func TestConfigSanitize(t *testing.T) {
 	assert.Equal(t, FakeSetting, *c.LdapSettings.BindPassword)
 	assert.Equal(t, FakeSetting, *c.FileSettings.PublicLinkSalt)
 	assert.Equal(t, FakeSetting, *c.FileSettings.AmazonS3SecretAccessKey)
+	assert.Equal(t, FakeSetting, *c.FileSettings.EncryptionKey)
 	assert.Equal(t, FakeSetting, *c.EmailSettings.SMTPPassword)
 	assert.Equal(t, FakeSetting, *c.GitLabSettings.Secret)
 	assert.Equal(t, FakeSetting, *c.OpenIdSettings.Secret)
@@ -2588,3 +2663,102 @@ func TestAutoTranslationSettingsIsValid(t *testing.T) {
 		})
 	}
 }
+
+func TestReadReceiptsSettingsIsValid(t *testing.T) {
+	testCases := []struct {
+		name        string
+		settings    ReadReceiptsSettings
+		expectError bool
+		errorId     string
+	}{
+		{
+			name:        "defaults should be valid",
+			settings:    ReadReceiptsSettings{},
+			expectError: false,
+		},
+		{
+			name: "valid read index service URL",
+			settings: ReadReceiptsSettings{
+				ReadIndexServiceURL: NewPointer("http://localhost:8066"),
+			},
+			expectError: false,
+		},
+		{
+			name: "invalid read index service URL should fail",
+			settings: ReadReceiptsSettings{
+				ReadIndexServiceURL: NewPointer("localhost:8066"),
+			},
+			expectError: true,
+			errorId:     "model.config.is_valid.read_receipts.read_index_service_url.app_error",
+		},
+		{
+			name: "zero timeout should fail",
+			settings: ReadReceiptsSettings{
+				ReadIndexServiceTimeoutMilliseconds: NewPointer(0),
+			},
+			expectError: true,
+			errorId:     "model.config.is_valid.read_receipts.timeout.app_error",
+		},
+		{
+			name: "zero failure threshold should fail",
+			settings: ReadReceiptsSettings{
+				CircuitBreakerFailureThreshold: NewPointer(0),
+			},
+			expectError: true,
+			errorId:     "model.config.is_valid.read_receipts.failure_threshold.app_error",
+		},
+		{
+			name: "negative cooldown should fail",
+			settings: ReadReceiptsSettings{
+				CircuitBreakerCooldownSeconds: NewPointer(-1),
+			},
+			expectError: true,
+			errorId:     "model.config.is_valid.read_receipts.cooldown.app_error",
+		},
+		{
+			name: "team defaults keyed by team id",
+			settings: ReadReceiptsSettings{
+				TeamShareReadStateDefaults: map[string]bool{NewId(): false},
+			},
+			expectError: false,
+		},
+		{
+			name: "team defaults with an invalid team id should fail",
+			settings: ReadReceiptsSettings{
+				TeamShareReadStateDefaults: map[string]bool{"engineering": false},
+			},
+			expectError: true,
+			errorId:     "model.config.is_valid.read_receipts.team_defaults.app_error",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.settings.SetDefaults()
+			err := tc.settings.isValid()
+			if tc.expectError {
+				require.NotNil(t, err)
+				require.Equal(t, tc.errorId, err.Id)
+			} else {
+				require.Nil(t, err)
+			}
+		})
+	}
+}
+
+func TestReadReceiptsSettingsShareReadStateDefault(t *testing.T) {
+	teamId := NewId()
+	settings := ReadReceiptsSettings{
+		TeamShareReadStateDefaults: map[string]bool{teamId: false},
+	}
+	settings.SetDefaults()
+
+	assert.True(t, settings.ShareReadStateDefault(""))
+	assert.True(t, settings.ShareReadStateDefault(NewId()))
+	assert.False(t, settings.ShareReadStateDefault(teamId))
+
+	settings.ShareReadStateByDefault = NewPointer(false)
+	settings.TeamShareReadStateDefaults[teamId] = true
+	assert.False(t, settings.ShareReadStateDefault(NewId()))
+	assert.True(t, settings.ShareReadStateDefault(teamId))
+}
@@ -0,0 +1,29 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+// EmailBatchEntry is a post waiting to be notified to a user in their next batched notification
+// email. The entries are persisted so that they survive restarts and are drained by whichever
+// node leads the cluster.
+type EmailBatchEntry struct {
+	Id        string `json:"id"`
+	UserId    string `json:"user_id"`
+	PostId    string `json:"post_id"`
+	ChannelId string `json:"channel_id"`
+	TeamName  string `json:"team_name"`
+	CreateAt  int64  `json:"create_at"` // CreateAt of the post
+}
+
+// EmailBatchUser is a user with pending batched notifications, along with the creation time of
+// the oldest post pending
+type EmailBatchUser struct {
+	UserId         string
+	OldestCreateAt int64
+}
+
+func (e *EmailBatchEntry) PreSave() {
+	if e.Id == "" {
+		e.Id = NewId()
+	}
+}
@@ -45,6 +45,10 @@ const (
 	JobTypeMobileSessionMetadata         = "mobile_session_metadata"
 	JobTypeAccessControlSync             = "access_control_sync"
 	JobTypePushProxyAuth                 = "push_proxy_auth"
+	JobTypeReadCursorOutbox              = "read_cursor_outbox"
+	JobTypeEmbeddedSearchIndexing        = "embedded_search_indexing"
+	JobTypeFileEncryptionKeyRotation     = "file_encryption_key_rotation"
+	JobTypeReadReceiptsReminder          = "read_receipts_reminder"
 
 	JobStatusPending         = "pending"
 	JobStatusInProgress      = "in_progress"
@@ -77,6 +81,9 @@ var AllJobTypes = [...]string{
 	JobTypeCleanupDesktopTokens,
 	JobTypeRefreshMaterializedViews,
 	JobTypeMobileSessionMetadata,
+	JobTypeReadCursorOutbox,
+	JobTypeEmbeddedSearchIndexing,
+	JobTypeFileEncryptionKeyRotation,
 }
 
 type Job struct {
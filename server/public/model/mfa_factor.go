@@ -0,0 +1,111 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"encoding/json"
+	"net/http"
+	"unicode/utf8"
+)
+
+const (
+	TokenTypeWebAuthnRegistration = "webauthn-registration"
+	TokenTypeWebAuthnLogin        = "webauthn-login"
+
+	MaxWebAuthnCredentialsPerUser   = 10
+	WebAuthnCredentialNameMaxRunes  = 64
+	WebAuthnCredentialIdMaxLength   = 1400
+	WebAuthnCredentialDataMaxLength = 65535
+
+	// MfaRecoveryCodeCount is the number of recovery codes generated at once for a user.
+	MfaRecoveryCodeCount = 10
+)
+
+// WebAuthnCredential is a WebAuthn authenticator (security key or passkey) registered
+// by a user as a second factor.
+type WebAuthnCredential struct {
+	Id     string `json:"id"`
+	UserId string `json:"user_id"`
+	Name   string `json:"name"`
+	// CredentialId is the base64url encoded credential ID chosen by the authenticator.
+	CredentialId string `json:"credential_id"`
+	// Credential is the serialized credential record, including the public key and
+	// the signature counter. It is never sent to clients.
+	Credential string `json:"-"`
+	CreateAt   int64  `json:"create_at"`
+	LastUsedAt int64  `json:"last_used_at"`
+}
+
+func (c *WebAuthnCredential) PreSave() {
+	if c.Id == "" {
+		c.Id = NewId()
+	}
+
+	if c.CreateAt == 0 {
+		c.CreateAt = GetMillis()
+	}
+}
+
+func (c *WebAuthnCredential) IsValid() *AppError {
+	if !IsValidId(c.Id) {
+		return NewAppError("WebAuthnCredential.IsValid", "model.webauthn_credential.is_valid.id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if !IsValidId(c.UserId) {
+		return NewAppError("WebAuthnCredential.IsValid", "model.webauthn_credential.is_valid.user_id.app_error", nil, "id="+c.Id, http.StatusBadRequest)
+	}
+
+	if c.Name == "" || utf8.RuneCountInString(c.Name) > WebAuthnCredentialNameMaxRunes {
+		return NewAppError("WebAuthnCredential.IsValid", "model.webauthn_credential.is_valid.name.app_error", map[string]any{"MaxLength": WebAuthnCredentialNameMaxRunes}, "id="+c.Id, http.StatusBadRequest)
+	}
+
+	if c.CredentialId == "" || len(c.CredentialId) > WebAuthnCredentialIdMaxLength {
+		return NewAppError("WebAuthnCredential.IsValid", "model.webauthn_credential.is_valid.credential_id.app_error", nil, "id="+c.Id, http.StatusBadRequest)
+	}
+
+	if c.Credential == "" || len(c.Credential) > WebAuthnCredentialDataMaxLength {
+		return NewAppError("WebAuthnCredential.IsValid", "model.webauthn_credential.is_valid.credential.app_error", nil, "id="+c.Id, http.StatusBadRequest)
+	}
+
+	if c.CreateAt == 0 {
+		return NewAppError("WebAuthnCredential.IsValid", "model.webauthn_credential.is_valid.create_at.app_error", nil, "id="+c.Id, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// MfaFactors describes the second factors configured for a user.
+type MfaFactors struct {
+	MfaActive              bool                  `json:"mfa_active"`
+	TotpActive             bool                  `json:"totp_active"`
+	WebAuthnCredentials    []*WebAuthnCredential `json:"webauthn_credentials"`
+	RecoveryCodesRemaining int64                 `json:"recovery_codes_remaining"`
+}
+
+// WebAuthnCeremony is returned when starting a WebAuthn registration or login. Options
+// must be passed to navigator.credentials.create() or navigator.credentials.get(), and
+// SessionId sent back along with the authenticator response.
+type WebAuthnCeremony struct {
+	SessionId string          `json:"session_id"`
+	Options   json.RawMessage `json:"options"`
+}
+
+// WebAuthnRegistration finishes the registration of a new WebAuthn credential.
+type WebAuthnRegistration struct {
+	SessionId  string          `json:"session_id"`
+	Name       string          `json:"name"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+// WebAuthnAssertion is the second factor sent on login by users authenticating with a
+// WebAuthn credential. It is passed JSON encoded in place of the MFA token.
+type WebAuthnAssertion struct {
+	SessionId  string          `json:"session_id"`
+	Credential json.RawMessage `json:"credential"`
+}
+
+// MfaRecoveryCodes holds freshly generated recovery codes. They are only shown once.
+type MfaRecoveryCodes struct {
+	Codes []string `json:"codes"`
+}
@@ -32,6 +32,9 @@ type PostMetadata struct {
 
 	// Acknowledgements holds acknowledgements made by users to the post
 	Acknowledgements []*PostAcknowledgement `json:"acknowledgements,omitempty"`
+
+	// Translation holds the message of the post translated into the locale of the user it is sent to.
+	Translation *PostTranslation `json:"translation,omitempty"`
 }
 
 func (p *PostMetadata) Auditable() map[string]any {
@@ -51,6 +54,7 @@ func (p *PostMetadata) Auditable() map[string]any {
 		"reactions":        p.Reactions,
 		"priority":         p.Priority,
 		"acknowledgements": p.Acknowledgements,
+		"translation":      p.Translation,
 	}
 }
 
@@ -96,6 +100,12 @@ func (p *PostMetadata) Copy() *PostMetadata {
 		}
 	}
 
+	var translationCopy *PostTranslation
+	if p.Translation != nil {
+		translation := *p.Translation
+		translationCopy = &translation
+	}
+
 	return &PostMetadata{
 		Embeds:           embedsCopy,
 		Emojis:           emojisCopy,
@@ -104,5 +114,6 @@ func (p *PostMetadata) Copy() *PostMetadata {
 		Reactions:        reactionsCopy,
 		Priority:         postPriorityCopy,
 		Acknowledgements: acknowledgementsCopy,
+		Translation:      translationCopy,
 	}
 }
@@ -0,0 +1,61 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"net/http"
+	"unicode/utf8"
+)
+
+const (
+	AutoTranslationProviderLibreTranslate = "libretranslate"
+
+	PostTranslationLocaleMaxLength = 16
+)
+
+// PostTranslation is the message of a post translated into a locale. When the post is already
+// written in the locale, SourceLocale is the locale itself and Message is the original message.
+type PostTranslation struct {
+	PostId       string `json:"post_id"`
+	Locale       string `json:"locale"`
+	SourceLocale string `json:"source_locale"`
+	Message      string `json:"message"`
+	CreateAt     int64  `json:"create_at"`
+}
+
+// IsTranslated tells whether the post was written in another locale than the translation's
+func (o *PostTranslation) IsTranslated() bool {
+	return o.SourceLocale != o.Locale
+}
+
+// IsStaleFor tells whether the post was edited after it was translated
+func (o *PostTranslation) IsStaleFor(post *Post) bool {
+	return post.EditAt > o.CreateAt
+}
+
+func (o *PostTranslation) IsValid() *AppError {
+	if !IsValidId(o.PostId) {
+		return NewAppError("PostTranslation.IsValid", "model.post_translation.is_valid.post_id.app_error", nil, "post_id="+o.PostId, http.StatusBadRequest)
+	}
+
+	if o.Locale == "" || len(o.Locale) > PostTranslationLocaleMaxLength {
+		return NewAppError("PostTranslation.IsValid", "model.post_translation.is_valid.locale.app_error", nil, "locale="+o.Locale, http.StatusBadRequest)
+	}
+
+	if o.SourceLocale == "" || len(o.SourceLocale) > PostTranslationLocaleMaxLength {
+		return NewAppError("PostTranslation.IsValid", "model.post_translation.is_valid.locale.app_error", nil, "source_locale="+o.SourceLocale, http.StatusBadRequest)
+	}
+
+	if utf8.RuneCountInString(o.Message) > PostMessageMaxRunesV2 {
+		return NewAppError("PostTranslation.IsValid", "model.post_translation.is_valid.message.app_error", nil, "post_id="+o.PostId, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+func (o *PostTranslation) PreSave() {
+	if o.CreateAt == 0 {
+		o.CreateAt = GetMillis()
+	}
+}
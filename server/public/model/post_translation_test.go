@@ -0,0 +1,45 @@
+// Copyright (c) 2015-present Mattermost, Inc. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostTranslationIsValid(t *testing.T) {
+	translation := &PostTranslation{PostId: NewId(), Locale: "en", SourceLocale: "zh", Message: "hello"}
+	require.Nil(t, translation.IsValid())
+
+	invalid := *translation
+	invalid.PostId = "junk"
+	assert.NotNil(t, invalid.IsValid())
+
+	invalid = *translation
+	invalid.Locale = ""
+	assert.NotNil(t, invalid.IsValid())
+
+	invalid = *translation
+	invalid.SourceLocale = strings.Repeat("z", PostTranslationLocaleMaxLength+1)
+	assert.NotNil(t, invalid.IsValid())
+
+	invalid = *translation
+	invalid.Message = strings.Repeat("a", PostMessageMaxRunesV2+1)
+	assert.NotNil(t, invalid.IsValid())
+}
+
+func TestPostTranslationIsStaleFor(t *testing.T) {
+	translation := &PostTranslation{PostId: NewId(), Locale: "en", SourceLocale: "zh", CreateAt: 100}
+	assert.True(t, translation.IsTranslated())
+
+	assert.False(t, translation.IsStaleFor(&Post{EditAt: 0}))
+	assert.False(t, translation.IsStaleFor(&Post{EditAt: 100}))
+	assert.True(t, translation.IsStaleFor(&Post{EditAt: 101}))
+
+	translation.SourceLocale = "en"
+	assert.False(t, translation.IsTranslated())
+}
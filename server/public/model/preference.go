@@ -66,6 +66,10 @@ const (
 	// Possible Name values are:
 	// - PreferenceNameEmailInterval
 	PreferenceCategoryNotifications = "notifications"
+	// PreferenceCategoryReadReceipts is used to store the user's read receipt privacy settings.
+	// Possible Name values are:
+	// - PreferenceNameShareReadState
+	PreferenceCategoryReadReceipts = "read_receipts"
 
 	// Deprecated: PreferenceRecommendedNextSteps is not used anymore.
 	// Use PreferenceCategoryRecommendedNextSteps instead.
@@ -106,6 +110,9 @@ const (
 
 	PreferenceNameEmailInterval = "email_interval"
 
+	// PreferenceNameShareReadState is "true" or "false". When unset, the team or server default applies.
+	PreferenceNameShareReadState = "share_read_state"
+
 	PreferenceEmailIntervalNoBatchingSeconds = "30"  // the "immediate" setting is actually 30s
 	PreferenceEmailIntervalBatchingSeconds   = "900" // fifteen minutes is 900 seconds
 	PreferenceEmailIntervalImmediately       = "immediately"
@@ -151,6 +158,12 @@ func (o *Preference) IsValid() *AppError {
 		}
 	}
 
+	if o.Category == PreferenceCategoryReadReceipts && o.Name == PreferenceNameShareReadState {
+		if _, err := strconv.ParseBool(o.Value); err != nil {
+			return NewAppError("Preference.IsValid", "model.preference.is_valid.share_read_state.app_error", nil, "value="+o.Value, http.StatusBadRequest)
+		}
+	}
+
 	if o.Category == PreferenceCategorySidebarSettings && o.Name == PreferenceLimitVisibleDmsGms {
 		visibleDmsGmsValue, convErr := strconv.Atoi(o.Value)
 		if convErr != nil || visibleDmsGmsValue < 1 || visibleDmsGmsValue > PreferenceMaxLimitVisibleDmsGmsValue {
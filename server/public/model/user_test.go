@@ -218,7 +218,7 @@ func TestUserPreSave(t *testing.T) {
 }
 
 func TestUserPreSavePwdTooLong(t *testing.T) {
-	user := User{Password: strings.Repeat("1234567890", 8)}
+	user := User{Password: strings.Repeat("1234567890", 26)}
 	err := user.PreSave()
 	assert.ErrorIs(t, err, hashers.ErrPasswordTooLong)
 }
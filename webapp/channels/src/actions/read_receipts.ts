@@ -105,10 +105,13 @@ async function processBatchQueue() {
     batchTimeout = null;
 
     try {
-        const results = await Client4.getBatchPostReadReceiptsCounts(postIds);
-        
+        const {counts, errors} = await Client4.getBatchPostReadReceiptsCounts(postIds);
+
+        // Posts without a count (deleted, not visible or read receipts hidden) are not retried
+        Object.keys(errors).forEach((postId) => pendingRequests.delete(postId));
+
         // Dispatch all results
-        Object.entries(results).forEach(([postId, count]) => {
+        Object.entries(counts).forEach(([postId, count]) => {
             dispatch({
                 type: ActionTypes.RECEIVED_READ_RECEIPTS_COUNT,
                 data: {
@@ -2644,12 +2644,33 @@ export default class Client4 {
     };
 
     getBatchPostReadReceiptsCounts = (postIds: string[]) => {
-        return this.doFetch<Record<string, number>>(
+        return this.doFetch<{counts: Record<string, number>; errors: Record<string, string>}>(
             `${this.getPostsRoute()}/read_receipts/counts`,
             {method: 'post', body: postIds},
         );
     };
 
+    getPostNonReaders = (postId: string, page = 0, perPage = PER_PAGE_DEFAULT) => {
+        return this.doFetch<{users: UserProfile[]; total_count: number}>(
+            `${this.getPostRoute(postId)}/read_receipts/non_readers${buildQueryString({page, per_page: perPage})}`,
+            {method: 'get'},
+        );
+    };
+
+    remindPostNonReaders = (postId: string) => {
+        return this.doFetch<{job_id: string}>(
+            `${this.getPostRoute(postId)}/read_receipts/remind`,
+            {method: 'post'},
+        );
+    };
+
+    getChannelReadReceiptsSummary = (channelId: string, days = 30) => {
+        return this.doFetch<{channel_id: string; since: number; read_percentage: number; days: Array<{date: string; post_count: number; read_count: number; expected_count: number; read_percentage: number}>}>(
+            `${this.getChannelRoute(channelId)}/read_receipts/summary${buildQueryString({days})}`,
+            {method: 'get'},
+        );
+    };
+
     // Preference Routes
 
     savePreferences = (userId: string, preferences: PreferenceType[]) => {
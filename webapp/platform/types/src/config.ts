@@ -1021,6 +1021,15 @@ export type ContentFlaggingSettings = {
     AdditionalSettings: ContentFlaggingAdditionalSettings;
 }
 
+export type ReadReceiptsSettings = {
+    ReadIndexServiceURL: string;
+    ReadIndexServiceTimeoutMilliseconds: number;
+    CircuitBreakerFailureThreshold: number;
+    CircuitBreakerCooldownSeconds: number;
+    ShareReadStateByDefault: boolean;
+    TeamShareReadStateDefaults: Record<string, boolean>;
+};
+
 export type AdminConfig = {
     ServiceSettings: ServiceSettings;
     TeamSettings: TeamSettings;
@@ -1067,6 +1076,7 @@ export type AdminConfig = {
     AccessControlSettings: AccessControlSettings;
     ContentFlaggingSettings: ContentFlaggingSettings;
     AutoTranslationSettings: AutoTranslationSettings;
+    ReadReceiptsSettings: ReadReceiptsSettings;
 };
 
 export type ReplicaLagSetting = {